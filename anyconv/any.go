@@ -0,0 +1,184 @@
+package anyconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+)
+
+// Any holds a protobuf message of any of the types in the protobuf
+// registry, like the values of an interface implemented by messages. It
+// implements the methods required by the gogoproto.customtype option, so
+// the fields of the structs declared as Any, or slices of them, are
+// generated as google.protobuf.Any fields, with the type URL of the message.
+// The zero Any holds no message and is encoded as an empty Any.
+type Any struct {
+	Message proto.Message
+}
+
+func (a Any) any() (*types.Any, error) {
+	if a.Message == nil {
+		return new(types.Any), nil
+	}
+	return Pack(a.Message)
+}
+
+// Marshal returns the encoding of the message as a google.protobuf.Any.
+func (a Any) Marshal() ([]byte, error) {
+	any, err := a.any()
+	if err != nil {
+		return nil, err
+	}
+	return any.Marshal()
+}
+
+// MarshalTo writes the encoding of the message as a google.protobuf.Any to
+// the given buffer, which must have room for it, and returns its size.
+func (a Any) MarshalTo(data []byte) (int, error) {
+	encoded, err := a.Marshal()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data) < len(encoded) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(data, encoded), nil
+}
+
+// Unmarshal decodes the message from the encoding of a google.protobuf.Any,
+// whose type must be registered. An Any without a type URL holds no message.
+func (a *Any) Unmarshal(data []byte) error {
+	var any types.Any
+	if err := any.Unmarshal(data); err != nil {
+		return err
+	}
+	return a.setAny(&any)
+}
+
+func (a *Any) setAny(any *types.Any) error {
+	if any.TypeUrl == "" {
+		*a = Any{}
+		return nil
+	}
+
+	msg, err := Unpack(any)
+	if err != nil {
+		return err
+	}
+
+	*a = Any{Message: msg.(proto.Message)}
+	return nil
+}
+
+// Size returns the size of the encoding of the message, which is 0 if it
+// can not be encoded, in which case MarshalTo returns the error.
+func (a Any) Size() int {
+	encoded, err := a.Marshal()
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (a Any) ProtoSize() int {
+	return a.Size()
+}
+
+// MarshalJSON encodes the message as a JSON object with the type URL in
+// "@type" and the fields of the message as they are encoded as JSON, like
+// the JSON of a google.protobuf.Any, or null if it holds no message.
+func (a Any) MarshalJSON() ([]byte, error) {
+	if a.Message == nil {
+		return []byte("null"), nil
+	}
+
+	name, err := messageName(a.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := json.Marshal(a.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	fields = bytes.TrimSpace(fields)
+	if len(fields) < 2 || fields[0] != '{' {
+		return nil, fmt.Errorf("anyconv: the JSON encoding of %T is not an object", a.Message)
+	}
+
+	typ, _ := json.Marshal(typeURLPrefix + name)
+	result := append([]byte(`{"@type":`), typ...)
+	if len(bytes.TrimSpace(fields[1:len(fields)-1])) > 0 {
+		result = append(result, ',')
+	}
+	return append(result, fields[1:]...), nil
+}
+
+// UnmarshalJSON decodes the message from a JSON object encoded by
+// MarshalJSON, whose type must be registered.
+func (a *Any) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		*a = Any{}
+		return nil
+	}
+
+	var typ struct {
+		URL string `json:"@type"`
+	}
+	if err := json.Unmarshal(data, &typ); err != nil {
+		return err
+	}
+
+	msg, err := emptyMessage(typ.URL)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	*a = Any{Message: msg}
+	return nil
+}
+
+// ToAnys converts all the elements of the given slice, like a slice of an
+// interface implemented by messages, to Any. Every element must be a
+// registered protobuf message.
+func ToAnys(slice interface{}) ([]Any, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("anyconv: %T is not a slice", slice)
+	}
+
+	result := make([]Any, v.Len())
+	for i := range result {
+		e := v.Index(i).Interface()
+		msg, ok := e.(proto.Message)
+		if !ok {
+			return nil, fmt.Errorf("anyconv: element %d: %T is not a protobuf message", i, e)
+		}
+		result[i] = Any{Message: msg}
+	}
+	return result, nil
+}
+
+// FromAnys stores the messages of the given Anys in the slice dst points to,
+// like a slice of an interface implemented by them. Every message must be
+// assignable to the elements of the slice.
+func FromAnys(anys []Any, dst interface{}) error {
+	return unpackInto(dst, len(anys), func(i int) (interface{}, error) {
+		if anys[i].Message == nil {
+			return nil, errors.New("it holds no message")
+		}
+		return anys[i].Message, nil
+	})
+}
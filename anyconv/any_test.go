@@ -0,0 +1,79 @@
+package anyconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAny(t *testing.T) {
+	require := require.New(t)
+
+	a := Any{Message: &types.StringValue{Value: "foo"}}
+	data, err := a.Marshal()
+	require.Nil(err)
+	require.Equal(len(data), a.Size())
+
+	buf := make([]byte, a.Size())
+	n, err := a.MarshalTo(buf)
+	require.Nil(err)
+	require.Equal(data, buf[:n])
+
+	var decoded Any
+	require.Nil(decoded.Unmarshal(data))
+	require.Equal(a, decoded)
+
+	_, err = a.MarshalTo(nil)
+	require.NotNil(err)
+
+	require.Nil(decoded.Unmarshal(nil))
+	require.Equal(Any{}, decoded)
+	require.Equal(0, decoded.Size())
+}
+
+func TestAnyJSON(t *testing.T) {
+	require := require.New(t)
+
+	data, err := json.Marshal([]Any{{Message: &types.Int64Value{Value: 42}}, {}})
+	require.Nil(err)
+	require.Equal(`[{"@type":"type.googleapis.com/google.protobuf.Int64Value","value":42},null]`, string(data))
+
+	var decoded []Any
+	require.Nil(json.Unmarshal(data, &decoded))
+	require.Equal([]Any{{Message: &types.Int64Value{Value: 42}}, {}}, decoded)
+
+	data, err = json.Marshal(Any{Message: &types.Empty{}})
+	require.Nil(err)
+	require.Equal(`{"@type":"type.googleapis.com/google.protobuf.Empty"}`, string(data))
+
+	require.NotNil(json.Unmarshal([]byte(`{"@type":"type.googleapis.com/foo.Bar"}`), &decoded[1]))
+}
+
+func TestToFromAnys(t *testing.T) {
+	require := require.New(t)
+
+	values := []fmt.Stringer{
+		&types.StringValue{Value: "foo"},
+		&types.Int64Value{Value: 42},
+	}
+
+	anys, err := ToAnys(values)
+	require.Nil(err)
+	require.Len(anys, 2)
+
+	var result []fmt.Stringer
+	require.Nil(FromAnys(anys, &result))
+	require.Equal(values, result)
+
+	var ints []*types.Int64Value
+	require.NotNil(FromAnys(anys, &ints))
+	require.NotNil(FromAnys([]Any{{}}, &result))
+
+	_, err = ToAnys([]interface{}{"foo"})
+	require.NotNil(err)
+	_, err = ToAnys(1)
+	require.NotNil(err)
+}
@@ -0,0 +1,146 @@
+// Package anyconv converts between Go values and the protobuf Any type, so
+// the fields generated as google.protobuf.Any can hold the values of an
+// interface. The fields declared as Any, a message of any type, or
+// *types.Any are generated as such. The concrete types are looked up in the
+// protobuf registries of gogo and golang/protobuf, so they need to be
+// registered, which the code generated by protoc already does. It also
+// converts between Go values that can be encoded as JSON and the protobuf
+// Value type, which is used for the fields declared as Value or
+// *types.Value.
+package anyconv // import "gitlab.com/ThatTomPerson/proteus/anyconv"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// typeURLPrefix is the prefix of the type URLs of the messages packed in an
+// Any.
+const typeURLPrefix = "type.googleapis.com/"
+
+// Pack converts the given value, which must be a registered protobuf
+// message, to an Any.
+func Pack(v interface{}) (*types.Any, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("anyconv: %T is not a protobuf message", v)
+	}
+
+	name, err := messageName(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Any{TypeUrl: typeURLPrefix + name, Value: value}, nil
+}
+
+// Unpack converts the given Any to a value of the type registered for its
+// type URL.
+func Unpack(any *types.Any) (interface{}, error) {
+	msg, err := emptyMessage(any.TypeUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := proto.Unmarshal(any.Value, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// messageName returns the full name of the type of the given message, which
+// must be registered in the registry of gogo or, like the messages generated
+// by protoc-gen-gofast, of golang/protobuf.
+func messageName(msg proto.Message) (string, error) {
+	if name := proto.MessageName(msg); name != "" {
+		return name, nil
+	}
+
+	name := protoadapt.MessageV2Of(msg).ProtoReflect().Descriptor().FullName()
+	if _, err := protoregistry.GlobalTypes.FindMessageByName(name); err != nil {
+		return "", fmt.Errorf("anyconv: %T is not a registered protobuf message", msg)
+	}
+	return string(name), nil
+}
+
+// emptyMessage returns a new message of the type with the given URL, which
+// is looked up in the registry of gogo first and then in the one of
+// golang/protobuf.
+func emptyMessage(url string) (proto.Message, error) {
+	name := url[strings.LastIndex(url, "/")+1:]
+	if t := proto.MessageType(name); t != nil {
+		return reflect.New(t.Elem()).Interface().(proto.Message), nil
+	}
+
+	typ, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("anyconv: message type %q is not registered", name)
+	}
+	return protoadapt.MessageV1Of(typ.New().Interface()), nil
+}
+
+// PackSlice converts all the elements of the given slice to Any.
+func PackSlice(slice interface{}) ([]*types.Any, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("anyconv: %T is not a slice", slice)
+	}
+
+	result := make([]*types.Any, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		any, err := Pack(v.Index(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("anyconv: element %d: %s", i, err)
+		}
+		result[i] = any
+	}
+
+	return result, nil
+}
+
+// UnpackSlice converts all the given Any values and stores them in the slice
+// dst points to. Every value must be assignable to the elements of the slice.
+func UnpackSlice(anys []*types.Any, dst interface{}) error {
+	return unpackInto(dst, len(anys), func(i int) (interface{}, error) {
+		return Unpack(anys[i])
+	})
+}
+
+// unpackInto stores the n values returned by unpack in the slice dst points
+// to. Every value must be assignable to the elements of the slice.
+func unpackInto(dst interface{}, n int, unpack func(int) (interface{}, error)) error {
+	ptr := reflect.ValueOf(dst)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("anyconv: %T is not a pointer to a slice", dst)
+	}
+
+	slice := ptr.Elem()
+	elem := slice.Type().Elem()
+	result := reflect.MakeSlice(slice.Type(), 0, n)
+	for i := 0; i < n; i++ {
+		v, err := unpack(i)
+		if err != nil {
+			return fmt.Errorf("anyconv: element %d: %s", i, err)
+		}
+
+		val := reflect.ValueOf(v)
+		if !val.Type().AssignableTo(elem) {
+			return fmt.Errorf("anyconv: element %d: %T is not assignable to %s", i, v, elem)
+		}
+		result = reflect.Append(result, val)
+	}
+
+	slice.Set(result)
+	return nil
+}
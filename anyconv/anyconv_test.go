@@ -0,0 +1,48 @@
+package anyconv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackUnpack(t *testing.T) {
+	require := require.New(t)
+
+	any, err := Pack(&types.StringValue{Value: "foo"})
+	require.Nil(err)
+	require.Equal("type.googleapis.com/google.protobuf.StringValue", any.TypeUrl)
+
+	v, err := Unpack(any)
+	require.Nil(err)
+	require.Equal(&types.StringValue{Value: "foo"}, v)
+
+	_, err = Pack("foo")
+	require.NotNil(err)
+}
+
+func TestPackUnpackSlice(t *testing.T) {
+	require := require.New(t)
+
+	values := []fmt.Stringer{
+		&types.StringValue{Value: "foo"},
+		&types.Int64Value{Value: 42},
+	}
+
+	anys, err := PackSlice(values)
+	require.Nil(err)
+	require.Len(anys, 2)
+
+	var result []fmt.Stringer
+	require.Nil(UnpackSlice(anys, &result))
+	require.Equal(values, result)
+
+	var ints []*types.Int64Value
+	require.NotNil(UnpackSlice(anys, &ints))
+
+	_, err = PackSlice(1)
+	require.NotNil(err)
+	require.NotNil(UnpackSlice(anys, result))
+}
@@ -0,0 +1,204 @@
+package anyconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/gogo/protobuf/types"
+)
+
+// Value holds JSON-like data, like the values decoded from JSON into an
+// interface. It implements the methods required by the gogoproto.customtype
+// option, so the fields of the structs declared as Value, or slices of them,
+// are generated as google.protobuf.Value fields. The data is converted with
+// ToValue when it is encoded, so it must be possible to encode it as JSON,
+// and it is decoded as the values returned by FromValue. The zero Value holds
+// nil, which is encoded as a null.
+type Value struct {
+	Data interface{}
+}
+
+// Marshal returns the encoding of the data as a google.protobuf.Value.
+func (v Value) Marshal() ([]byte, error) {
+	value, err := ToValue(v.Data)
+	if err != nil {
+		return nil, err
+	}
+	return value.Marshal()
+}
+
+// MarshalTo writes the encoding of the data as a google.protobuf.Value to
+// the given buffer, which must have room for it, and returns its size.
+func (v Value) MarshalTo(data []byte) (int, error) {
+	encoded, err := v.Marshal()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data) < len(encoded) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(data, encoded), nil
+}
+
+// Unmarshal decodes the data from the encoding of a google.protobuf.Value.
+func (v *Value) Unmarshal(data []byte) error {
+	var value types.Value
+	if err := value.Unmarshal(data); err != nil {
+		return err
+	}
+
+	*v = Value{Data: FromValue(&value)}
+	return nil
+}
+
+// Size returns the size of the encoding of the data, which is 0 if it can
+// not be encoded, in which case MarshalTo returns the error.
+func (v Value) Size() int {
+	encoded, err := v.Marshal()
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (v Value) ProtoSize() int {
+	return v.Size()
+}
+
+// MarshalJSON encodes the data as JSON.
+func (v Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Data)
+}
+
+// UnmarshalJSON decodes the data from JSON, as the values returned by
+// FromValue.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	*v = Value{}
+	return json.Unmarshal(data, &v.Data)
+}
+
+// ToValue converts the given value to a Value. Nil, booleans, numbers,
+// strings, slices of interfaces and maps with string keys are converted
+// directly, and any other value, or number with its own JSON encoding, is
+// converted from its JSON encoding, so it must be possible to encode it as
+// JSON.
+func ToValue(v interface{}) (*types.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return &types.Value{Kind: &types.Value_NullValue{NullValue: types.NULL_VALUE}}, nil
+	case bool:
+		return &types.Value{Kind: &types.Value_BoolValue{BoolValue: v}}, nil
+	case string:
+		return &types.Value{Kind: &types.Value_StringValue{StringValue: v}}, nil
+	case float64:
+		return &types.Value{Kind: &types.Value_NumberValue{NumberValue: v}}, nil
+	case []interface{}:
+		values, err := toValues(v)
+		if err != nil {
+			return nil, err
+		}
+		return &types.Value{Kind: &types.Value_ListValue{ListValue: &types.ListValue{Values: values}}}, nil
+	case map[string]interface{}:
+		fields := make(map[string]*types.Value, len(v))
+		for k, e := range v {
+			value, err := ToValue(e)
+			if err != nil {
+				return nil, fmt.Errorf("anyconv: key %q: %s", k, err)
+			}
+			fields[k] = value
+		}
+		return &types.Value{Kind: &types.Value_StructValue{StructValue: &types.Struct{Fields: fields}}}, nil
+	}
+
+	if _, ok := v.(json.Marshaler); !ok {
+		if n, ok := toNumber(reflect.ValueOf(v)); ok {
+			return &types.Value{Kind: &types.Value_NumberValue{NumberValue: n}}, nil
+		}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("anyconv: %T can not be converted to a value: %s", v, err)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("anyconv: %T can not be converted to a value: %s", v, err)
+	}
+	return ToValue(decoded)
+}
+
+// FromValue converts the given Value to the Go value it holds, which is nil,
+// a bool, a float64, a string, a []interface{} or a map[string]interface{},
+// like the values decoded from JSON into an interface.
+func FromValue(v *types.Value) interface{} {
+	switch kind := v.GetKind().(type) {
+	case *types.Value_BoolValue:
+		return kind.BoolValue
+	case *types.Value_NumberValue:
+		return kind.NumberValue
+	case *types.Value_StringValue:
+		return kind.StringValue
+	case *types.Value_ListValue:
+		return FromValues(kind.ListValue.GetValues())
+	case *types.Value_StructValue:
+		result := make(map[string]interface{}, len(kind.StructValue.GetFields()))
+		for k, e := range kind.StructValue.GetFields() {
+			result[k] = FromValue(e)
+		}
+		return result
+	}
+	return nil
+}
+
+// ToValues converts all the elements of the given slice to Value.
+func ToValues(slice interface{}) ([]*types.Value, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("anyconv: %T is not a slice", slice)
+	}
+
+	elems := make([]interface{}, v.Len())
+	for i := range elems {
+		elems[i] = v.Index(i).Interface()
+	}
+	return toValues(elems)
+}
+
+// FromValues converts all the given Value to the Go values they hold.
+func FromValues(values []*types.Value) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = FromValue(v)
+	}
+	return result
+}
+
+func toValues(elems []interface{}) ([]*types.Value, error) {
+	result := make([]*types.Value, len(elems))
+	for i, e := range elems {
+		v, err := ToValue(e)
+		if err != nil {
+			return nil, fmt.Errorf("anyconv: element %d: %s", i, err)
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+// toNumber returns the given value as a float64 if it is a number.
+func toNumber(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
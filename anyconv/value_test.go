@@ -0,0 +1,101 @@
+package anyconv
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToFromValue(t *testing.T) {
+	require := require.New(t)
+
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+
+	v, err := ToValue(map[string]interface{}{
+		"name":   "foo",
+		"age":    int32(42),
+		"admin":  true,
+		"tags":   []interface{}{"a", nil},
+		"origin": point{1, 2},
+	})
+	require.Nil(err)
+	require.Equal(map[string]interface{}{
+		"name":   "foo",
+		"age":    float64(42),
+		"admin":  true,
+		"tags":   []interface{}{"a", nil},
+		"origin": map[string]interface{}{"x": float64(1), "y": float64(2)},
+	}, FromValue(v))
+
+	v, err = ToValue(nil)
+	require.Nil(err)
+	require.Equal(&types.Value{Kind: &types.Value_NullValue{NullValue: types.NULL_VALUE}}, v)
+	require.Nil(FromValue(nil))
+
+	_, err = ToValue(make(chan int))
+	require.NotNil(err)
+}
+
+func TestToFromValues(t *testing.T) {
+	require := require.New(t)
+
+	values, err := ToValues([]interface{}{"foo", 1.5, false})
+	require.Nil(err)
+	require.Len(values, 3)
+	require.Equal([]interface{}{"foo", 1.5, false}, FromValues(values))
+
+	values, err = ToValues([]string{"foo", "bar"})
+	require.Nil(err)
+	require.Equal([]interface{}{"foo", "bar"}, FromValues(values))
+
+	_, err = ToValues(1)
+	require.NotNil(err)
+
+	_, err = ToValues([]interface{}{func() {}})
+	require.NotNil(err)
+}
+
+func TestValue(t *testing.T) {
+	require := require.New(t)
+
+	v := Value{Data: map[string]interface{}{"name": "foo", "tags": []interface{}{"a", 1.5}}}
+	data, err := v.Marshal()
+	require.Nil(err)
+	require.Equal(len(data), v.Size())
+
+	buf := make([]byte, v.Size())
+	n, err := v.MarshalTo(buf)
+	require.Nil(err)
+	require.Equal(data, buf[:n])
+
+	var decoded Value
+	require.Nil(decoded.Unmarshal(data))
+	require.Equal(v, decoded)
+
+	_, err = v.MarshalTo(nil)
+	require.NotNil(err)
+
+	require.Nil(decoded.Unmarshal(nil))
+	require.Equal(Value{}, decoded)
+
+	_, err = Value{Data: make(chan int)}.Marshal()
+	require.NotNil(err)
+	require.Equal(0, Value{Data: make(chan int)}.Size())
+}
+
+func TestValueJSON(t *testing.T) {
+	require := require.New(t)
+
+	data, err := json.Marshal([]Value{{Data: "foo"}, {}})
+	require.Nil(err)
+	require.Equal(`["foo",null]`, string(data))
+
+	var decoded []Value
+	require.Nil(json.Unmarshal([]byte(`[{"x":1},null]`), &decoded))
+	require.Equal([]Value{{Data: map[string]interface{}{"x": float64(1)}}, {}}, decoded)
+}
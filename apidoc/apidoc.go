@@ -0,0 +1,349 @@
+package apidoc // import "gitlab.com/ThatTomPerson/proteus/apidoc"
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+// Title is the title of the documentation.
+const Title = "API reference"
+
+// Format is the format of the reference documentation.
+type Format string
+
+const (
+	// Markdown writes the documentation to an API.md file.
+	Markdown Format = "markdown"
+	// HTML writes the documentation to a standalone api.html file.
+	HTML Format = "html"
+)
+
+// ParseFormat returns the documentation format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case Markdown, HTML:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid documentation format %q, expecting markdown or html", name)
+}
+
+// fileNames are the names of the documentation files of every format.
+var fileNames = map[Format]string{
+	Markdown: "API.md",
+	HTML:     "api.html",
+}
+
+// Generator writes the reference documentation of the generated packages to
+// the base path, for the teams that treat the .proto files as the contract
+// of their API.
+//
+// There is a section per protobuf package with its services, messages and
+// enums, in the order in which they are in the .proto files, along with the
+// docs they have in them. RPCs have their request and response messages and
+// their HTTP rule, if any. Fields have their number, type, label and unit,
+// given with the (proteus.unit) option. Messages and enums of the packages
+// are linked wherever they are used, and deprecated ones are marked as such.
+type Generator struct {
+	basePath string
+	format   Format
+}
+
+// NewGenerator creates a new Generator with the given base path and format.
+func NewGenerator(basePath string, format Format) *Generator {
+	return &Generator{basePath: basePath, format: format}
+}
+
+// FileName returns the path of the documentation written by the generator,
+// which is API.md for Markdown and api.html for HTML.
+func (g *Generator) FileName() string {
+	return filepath.Join(g.basePath, fileNames[g.format])
+}
+
+// Generate writes the documentation of the given packages to disk.
+func (g *Generator) Generate(pkgs []*protobuf.Package) error {
+	fi, err := os.Stat(g.basePath)
+	if err != nil {
+		return err
+	}
+
+	if err := output.WriteFile(g.FileName(), g.build(pkgs), fi.Mode()); err != nil {
+		return err
+	}
+
+	report.Info("Generated API documentation: %s", g.FileName())
+	return nil
+}
+
+func (g *Generator) build(pkgs []*protobuf.Package) []byte {
+	var w writer = &markdownWriter{}
+	if g.format == HTML {
+		w = &htmlWriter{}
+	}
+
+	var buf bytes.Buffer
+	d := &document{w: w, buf: &buf, types: newTypeIndex(pkgs)}
+	d.write(pkgs)
+	return append(bytes.TrimRight(buf.Bytes(), "\n"), '\n')
+}
+
+// writer writes the parts of the documentation in a format.
+type writer interface {
+	// begin writes the start of the document with the given title.
+	begin(buf *bytes.Buffer, title string)
+	// end writes the end of the document.
+	end(buf *bytes.Buffer)
+	// heading writes a heading of the given level with the given anchor.
+	heading(buf *bytes.Buffer, level int, anchor, text string)
+	// paragraph writes the given lines as a paragraph.
+	paragraph(buf *bytes.Buffer, lines []string)
+	// list writes a list of links.
+	list(buf *bytes.Buffer, links []link)
+	// table writes a table with the given header and rows.
+	table(buf *bytes.Buffer, header []string, rows [][]cell)
+	// link returns the text of a link to the given anchor.
+	link(l link) string
+	// escape returns the given text escaped, so it is written as it is.
+	escape(text string) string
+	// code returns the given text formatted as code.
+	code(text string) string
+	// strong returns the given text formatted as strong.
+	strong(text string) string
+	// lines returns the given lines as a single cell.
+	lines(lines []string) string
+}
+
+type link struct {
+	anchor string
+	text   string
+}
+
+// cell is the content of a table cell, which is already formatted.
+type cell string
+
+type document struct {
+	w     writer
+	buf   *bytes.Buffer
+	types typeIndex
+}
+
+func (d *document) write(pkgs []*protobuf.Package) {
+	d.w.begin(d.buf, Title)
+
+	var links []link
+	for _, pkg := range pkgs {
+		links = append(links, link{pkg.Name, pkg.Name})
+	}
+	d.w.heading(d.buf, 2, "", "Packages")
+	d.w.list(d.buf, links)
+
+	for _, pkg := range pkgs {
+		d.writePackage(pkg)
+	}
+	d.w.end(d.buf)
+}
+
+func (d *document) writePackage(pkg *protobuf.Package) {
+	d.w.heading(d.buf, 2, pkg.Name, pkg.Name)
+	d.w.paragraph(d.buf, []string{
+		fmt.Sprintf("Generated from the Go package %s.", d.w.code(pkg.Path)),
+	})
+
+	if len(pkg.Services) > 0 {
+		d.w.heading(d.buf, 3, "", "Services")
+		for _, svc := range pkg.Services {
+			d.writeService(pkg, svc)
+		}
+	}
+
+	if len(pkg.Messages) > 0 {
+		d.w.heading(d.buf, 3, "", "Messages")
+		for _, msg := range pkg.Messages {
+			d.writeMessage(pkg, msg)
+		}
+	}
+
+	if len(pkg.Enums) > 0 {
+		d.w.heading(d.buf, 3, "", "Enums")
+		for _, e := range pkg.Enums {
+			d.writeEnum(pkg, e)
+		}
+	}
+}
+
+func (d *document) writeService(pkg *protobuf.Package, svc *protobuf.Service) {
+	d.w.heading(d.buf, 4, fullName(pkg.Name, svc.Name), svc.Name)
+	d.writeDocs(svc.Docs)
+
+	var rows [][]cell
+	for _, rpc := range svc.RPCs {
+		var http string
+		if rpc.HTTP != nil {
+			http = d.w.code(fmt.Sprintf("%s %s", strings.ToUpper(rpc.HTTP.Method), rpc.HTTP.Path))
+		}
+
+		rows = append(rows, []cell{
+			cell(d.w.escape(rpc.Name)),
+			cell(d.typeName(pkg, rpc.Input)),
+			cell(d.typeName(pkg, rpc.Output)),
+			cell(http),
+			d.description(rpc.Docs, rpc.Options),
+		})
+	}
+	d.w.table(d.buf, []string{"RPC", "Request", "Response", "HTTP", "Description"}, rows)
+}
+
+func (d *document) writeMessage(pkg *protobuf.Package, msg *protobuf.Message) {
+	d.w.heading(d.buf, 4, fullName(pkg.Name, msg.Name), msg.Name)
+	d.writeDocs(msg.Docs)
+	if isDeprecated(msg.Options) {
+		d.w.paragraph(d.buf, []string{d.w.strong("Deprecated.")})
+	}
+
+	if len(msg.Fields) == 0 {
+		d.w.paragraph(d.buf, []string{"This message has no fields."})
+		return
+	}
+
+	var rows [][]cell
+	for _, f := range msg.Fields {
+		var label string
+		if f.Repeated {
+			label = "repeated"
+		} else if f.Optional {
+			label = "optional"
+		}
+
+		var unit []string
+		if u := f.Unit(); u != "" {
+			unit = append(unit, fmt.Sprintf("Unit: %s.", d.w.code(u)))
+		}
+
+		rows = append(rows, []cell{
+			cell(d.w.escape(f.Name)),
+			cell(fmt.Sprint(f.Pos)),
+			cell(d.typeName(pkg, f.Type)),
+			cell(label),
+			d.description(f.Docs, f.Options, unit...),
+		})
+	}
+	d.w.table(d.buf, []string{"Field", "Number", "Type", "Label", "Description"}, rows)
+}
+
+func (d *document) writeEnum(pkg *protobuf.Package, e *protobuf.Enum) {
+	d.w.heading(d.buf, 4, fullName(pkg.Name, e.Name), e.Name)
+	d.writeDocs(e.Docs)
+	if isDeprecated(e.Options) {
+		d.w.paragraph(d.buf, []string{d.w.strong("Deprecated.")})
+	}
+
+	var rows [][]cell
+	for _, v := range e.Values {
+		rows = append(rows, []cell{
+			cell(d.w.escape(v.Name)),
+			cell(fmt.Sprint(v.Value)),
+			d.description(v.Docs, v.Options),
+		})
+	}
+	d.w.table(d.buf, []string{"Name", "Number", "Description"}, rows)
+}
+
+func (d *document) writeDocs(docs []string) {
+	if len(docs) == 0 {
+		return
+	}
+
+	lines := make([]string, len(docs))
+	for i, l := range docs {
+		lines[i] = d.w.escape(l)
+	}
+	d.w.paragraph(d.buf, lines)
+}
+
+// description returns the cell with the given docs, which starts by saying
+// it is deprecated if the options say so, followed by the given lines, which
+// are already formatted.
+func (d *document) description(docs []string, opts protobuf.Options, extra ...string) cell {
+	var lines []string
+	if isDeprecated(opts) {
+		lines = append(lines, d.w.strong("Deprecated."))
+	}
+
+	for _, l := range docs {
+		lines = append(lines, d.w.escape(l))
+	}
+	return cell(d.w.lines(append(lines, extra...)))
+}
+
+// typeName returns the name of the given type used in the package, linking
+// to the messages and enums of the packages.
+func (d *document) typeName(pkg *protobuf.Package, typ protobuf.Type) string {
+	switch t := underlying(typ).(type) {
+	case *protobuf.Map:
+		return fmt.Sprintf(
+			"%s%s, %s%s",
+			d.w.escape("map<"),
+			d.typeName(pkg, t.Key),
+			d.typeName(pkg, t.Value),
+			d.w.escape(">"),
+		)
+	case *protobuf.Named:
+		name := namedName(pkg, t)
+		if d.types[name] {
+			return d.w.link(link{name, t.Name})
+		}
+		return d.w.escape(name)
+	case nil:
+		return ""
+	}
+	return d.w.escape(typ.String())
+}
+
+// typeIndex are the full protobuf names of the messages and enums of the
+// packages, which have their own section.
+type typeIndex map[string]bool
+
+func newTypeIndex(pkgs []*protobuf.Package) typeIndex {
+	idx := make(typeIndex)
+	for _, pkg := range pkgs {
+		for _, msg := range pkg.Messages {
+			idx[fullName(pkg.Name, msg.Name)] = true
+		}
+
+		for _, e := range pkg.Enums {
+			idx[fullName(pkg.Name, e.Name)] = true
+		}
+	}
+	return idx
+}
+
+// namedName returns the full protobuf name of the given named type used in
+// the package.
+func namedName(pkg *protobuf.Package, n *protobuf.Named) string {
+	if n.Package == "" {
+		return fullName(pkg.Name, n.Name)
+	}
+	return fullName(n.Package, n.Name)
+}
+
+func underlying(typ protobuf.Type) protobuf.Type {
+	if a, ok := typ.(*protobuf.Alias); ok {
+		return underlying(a.Underlying)
+	}
+	return typ
+}
+
+func isDeprecated(opts protobuf.Options) bool {
+	v, ok := opts["deprecated"]
+	return ok && v.String() == "true"
+}
+
+func fullName(pkg, name string) string {
+	return pkg + "." + name
+}
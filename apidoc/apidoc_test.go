@@ -0,0 +1,172 @@
+package apidoc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func testPackages() []*protobuf.Package {
+	return []*protobuf.Package{
+		{
+			Name: "foo.users",
+			Path: "gitlab.com/foo/users",
+			Services: []*protobuf.Service{
+				{
+					Docs: []string{"UsersService manages users."},
+					Name: "UsersService",
+					RPCs: []*protobuf.RPC{
+						{
+							Docs:   []string{"GetUser returns the user | or nothing."},
+							Name:   "GetUser",
+							Input:  protobuf.NewNamed("foo.users", "GetUserRequest"),
+							Output: protobuf.NewNamed("foo.users", "User"),
+							HTTP:   &scanner.HTTPRule{Method: "get", Path: "/v1/users/{id}"},
+						},
+						{
+							Name:    "Ping",
+							Input:   protobuf.NewNamed("google.protobuf", "Empty"),
+							Output:  protobuf.NewNamed("google.protobuf", "Empty"),
+							Options: protobuf.Options{"deprecated": protobuf.NewLiteralValue("true")},
+						},
+					},
+				},
+			},
+			Messages: []*protobuf.Message{
+				{
+					Docs: []string{"User is a <registered> user."},
+					Name: "User",
+					Fields: []*protobuf.Field{
+						{Docs: []string{"ID of the user.", "It is unique."}, Name: "id", Pos: 1, Type: protobuf.NewBasic("int64")},
+						{Name: "timeout", Pos: 6, Type: protobuf.NewBasic("int64"), Options: protobuf.Options{"(proteus.unit)": protobuf.NewStringValue("ms")}},
+						{Name: "status", Pos: 2, Type: protobuf.NewNamed("foo.users", "Status")},
+						{Name: "tags", Pos: 3, Repeated: true, Type: protobuf.NewBasic("string")},
+						{Name: "groups", Pos: 4, Type: protobuf.NewMap(protobuf.NewBasic("string"), protobuf.NewNamed("foo.users", "User"))},
+						{Name: "nick", Pos: 5, Optional: true, Type: protobuf.NewBasic("string"), Options: protobuf.Options{"deprecated": protobuf.NewLiteralValue("true")}},
+					},
+				},
+				{Name: "GetUserRequest"},
+			},
+			Enums: []*protobuf.Enum{
+				{
+					Name: "Status",
+					Values: []*protobuf.EnumValue{
+						{Name: "STATUS_ACTIVE", Value: 0},
+						{Docs: []string{"Banned users can not log in."}, Name: "STATUS_BANNED", Value: 1},
+					},
+				},
+			},
+		},
+	}
+}
+
+const expectedMarkdown = "# API reference\n" + `
+## Packages
+
+- [foo.users](#foo.users)
+
+<a name="foo.users"></a>
+
+## foo.users
+
+Generated from the Go package ` + "`gitlab.com/foo/users`" + `.
+
+### Services
+
+<a name="foo.users.UsersService"></a>
+
+#### UsersService
+
+UsersService manages users.
+
+| RPC | Request | Response | HTTP | Description |
+|---|---|---|---|---|
+| GetUser | [GetUserRequest](#foo.users.GetUserRequest) | [User](#foo.users.User) | ` + "`GET /v1/users/{id}`" + ` | GetUser returns the user \| or nothing. |
+| Ping | google.protobuf.Empty | google.protobuf.Empty | | **Deprecated.** |
+
+### Messages
+
+<a name="foo.users.User"></a>
+
+#### User
+
+User is a &lt;registered&gt; user.
+
+| Field | Number | Type | Label | Description |
+|---|---|---|---|---|
+| id | 1 | int64 | | ID of the user.<br>It is unique. |
+| timeout | 6 | int64 | | Unit: ` + "`ms`" + `. |
+| status | 2 | [Status](#foo.users.Status) | | |
+| tags | 3 | string | repeated | |
+| groups | 4 | map&lt;string, [User](#foo.users.User)&gt; | | |
+| nick | 5 | string | optional | **Deprecated.** |
+
+<a name="foo.users.GetUserRequest"></a>
+
+#### GetUserRequest
+
+This message has no fields.
+
+### Enums
+
+<a name="foo.users.Status"></a>
+
+#### Status
+
+| Name | Number | Description |
+|---|---|---|
+| STATUS_ACTIVE | 0 | |
+| STATUS_BANNED | 1 | Banned users can not log in. |
+`
+
+func TestBuildMarkdown(t *testing.T) {
+	data := NewGenerator("", Markdown).build(testPackages())
+	require.Equal(t, expectedMarkdown, string(data))
+}
+
+func TestBuildHTML(t *testing.T) {
+	require := require.New(t)
+	data := string(NewGenerator("", HTML).build(testPackages()))
+
+	require.Contains(data, "<title>API reference</title>")
+	require.Contains(data, `<h4 id="foo.users.User">User</h4>`)
+	require.Contains(data, "<p>User is a &lt;registered&gt; user.</p>")
+	require.Contains(data, `<tr><td>groups</td><td>4</td><td>map&lt;string, <a href="#foo.users.User">User</a>&gt;</td><td></td><td></td></tr>`)
+	require.Contains(data, "<td><code>GET /v1/users/{id}</code></td>")
+	require.Contains(data, "<td><strong>Deprecated.</strong></td>")
+	require.Contains(data, "<td>Unit: <code>ms</code>.</td>")
+	require.True(len(data) > 0 && data[len(data)-1] == '\n')
+	require.Contains(data, "</body>\n</html>\n")
+}
+
+func TestGenerate(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-apidoc")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	g := NewGenerator(dir, HTML)
+	require.Equal(filepath.Join(dir, "api.html"), g.FileName())
+	require.NoError(g.Generate(testPackages()))
+
+	data, err := ioutil.ReadFile(g.FileName())
+	require.NoError(err)
+	require.Contains(string(data), "<!DOCTYPE html>")
+
+	require.Error(NewGenerator(filepath.Join(dir, "missing"), Markdown).Generate(testPackages()))
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("html")
+	require.NoError(t, err)
+	require.Equal(t, HTML, f)
+
+	_, err = ParseFormat("pdf")
+	require.Error(t, err)
+}
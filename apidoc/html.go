@@ -0,0 +1,85 @@
+package apidoc
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// htmlWriter writes the documentation as a standalone HTML page.
+type htmlWriter struct{}
+
+const htmlStyle = `body { font-family: sans-serif; max-width: 960px; margin: 0 auto; padding: 1em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+code { background: #f5f5f5; }`
+
+func (w *htmlWriter) begin(buf *bytes.Buffer, title string) {
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	buf.WriteString(fmt.Sprintf("<title>%s</title>\n", w.escape(title)))
+	buf.WriteString(fmt.Sprintf("<style>\n%s\n</style>\n", htmlStyle))
+	buf.WriteString("</head>\n<body>\n")
+	w.heading(buf, 1, "", title)
+}
+
+func (w *htmlWriter) end(buf *bytes.Buffer) {
+	buf.WriteString("</body>\n</html>\n")
+}
+
+func (w *htmlWriter) heading(buf *bytes.Buffer, level int, anchor, text string) {
+	var id string
+	if anchor != "" {
+		id = fmt.Sprintf(" id=\"%s\"", w.escape(anchor))
+	}
+	buf.WriteString(fmt.Sprintf("<h%d%s>%s</h%d>\n", level, id, w.escape(text), level))
+}
+
+func (w *htmlWriter) paragraph(buf *bytes.Buffer, lines []string) {
+	buf.WriteString(fmt.Sprintf("<p>%s</p>\n", strings.Join(lines, "\n")))
+}
+
+func (w *htmlWriter) list(buf *bytes.Buffer, links []link) {
+	buf.WriteString("<ul>\n")
+	for _, l := range links {
+		buf.WriteString(fmt.Sprintf("<li>%s</li>\n", w.link(l)))
+	}
+	buf.WriteString("</ul>\n")
+}
+
+func (w *htmlWriter) table(buf *bytes.Buffer, header []string, rows [][]cell) {
+	buf.WriteString("<table>\n<thead>\n<tr>")
+	for _, h := range header {
+		buf.WriteString(fmt.Sprintf("<th>%s</th>", w.escape(h)))
+	}
+	buf.WriteString("</tr>\n</thead>\n<tbody>\n")
+
+	for _, row := range rows {
+		buf.WriteString("<tr>")
+		for _, c := range row {
+			buf.WriteString(fmt.Sprintf("<td>%s</td>", c))
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</tbody>\n</table>\n")
+}
+
+func (w *htmlWriter) link(l link) string {
+	return fmt.Sprintf("<a href=\"#%s\">%s</a>", w.escape(l.anchor), w.escape(l.text))
+}
+
+func (w *htmlWriter) escape(text string) string {
+	return html.EscapeString(text)
+}
+
+func (w *htmlWriter) code(text string) string {
+	return fmt.Sprintf("<code>%s</code>", w.escape(text))
+}
+
+func (w *htmlWriter) strong(text string) string {
+	return fmt.Sprintf("<strong>%s</strong>", text)
+}
+
+func (w *htmlWriter) lines(lines []string) string {
+	return strings.Join(lines, "<br>")
+}
@@ -0,0 +1,85 @@
+package apidoc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// markdownWriter writes the documentation as GitHub flavored Markdown. The
+// anchors are HTML anchors before their heading, as the ones generated from
+// the headings are not the same in every renderer.
+type markdownWriter struct{}
+
+var markdownEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	"|", `\|`,
+)
+
+func (w *markdownWriter) begin(buf *bytes.Buffer, title string) {
+	w.heading(buf, 1, "", title)
+}
+
+func (w *markdownWriter) end(buf *bytes.Buffer) {}
+
+func (w *markdownWriter) heading(buf *bytes.Buffer, level int, anchor, text string) {
+	if anchor != "" {
+		buf.WriteString(fmt.Sprintf("<a name=\"%s\"></a>\n\n", anchor))
+	}
+	buf.WriteString(fmt.Sprintf("%s %s\n\n", strings.Repeat("#", level), w.escape(text)))
+}
+
+func (w *markdownWriter) paragraph(buf *bytes.Buffer, lines []string) {
+	buf.WriteString(strings.Join(lines, "\n"))
+	buf.WriteString("\n\n")
+}
+
+func (w *markdownWriter) list(buf *bytes.Buffer, links []link) {
+	for _, l := range links {
+		buf.WriteString(fmt.Sprintf("- %s\n", w.link(l)))
+	}
+	buf.WriteRune('\n')
+}
+
+func (w *markdownWriter) table(buf *bytes.Buffer, header []string, rows [][]cell) {
+	buf.WriteString(fmt.Sprintf("| %s |\n", strings.Join(header, " | ")))
+	for range header {
+		buf.WriteString("|---")
+	}
+	buf.WriteString("|\n")
+
+	for _, row := range rows {
+		buf.WriteRune('|')
+		for _, c := range row {
+			if c == "" {
+				buf.WriteString(" |")
+				continue
+			}
+			buf.WriteString(fmt.Sprintf(" %s |", c))
+		}
+		buf.WriteRune('\n')
+	}
+	buf.WriteRune('\n')
+}
+
+func (w *markdownWriter) link(l link) string {
+	return fmt.Sprintf("[%s](#%s)", w.escape(l.text), l.anchor)
+}
+
+func (w *markdownWriter) escape(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+func (w *markdownWriter) code(text string) string {
+	return fmt.Sprintf("`%s`", strings.Replace(text, "|", `\|`, -1))
+}
+
+func (w *markdownWriter) strong(text string) string {
+	return fmt.Sprintf("**%s**", text)
+}
+
+func (w *markdownWriter) lines(lines []string) string {
+	return strings.Join(lines, "<br>")
+}
@@ -0,0 +1,199 @@
+package arrayconv // import "gitlab.com/ThatTomPerson/proteus/arrayconv"
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// FileName is the name of the file generated in every package.
+const FileName = "proteus_arrays.go"
+
+// methods are the methods required by gogoproto.customtype, which are
+// generated for every array type.
+var methods = []string{"Marshal", "MarshalTo", "Unmarshal", "Size", "ProtoSize"}
+
+// Generator generates the methods required by gogoproto.customtype for the
+// named array types of a package, like `type Hash [32]byte`, as their fields
+// are generated as bytes fields converted with them. For every array type
+// Foo it generates:
+//
+//	func (a *Foo) Marshal() ([]byte, error)
+//	func (a *Foo) MarshalTo(data []byte) (int, error)
+//	func (a *Foo) Unmarshal(data []byte) error
+//	func (a *Foo) Size() int
+//	func (a *Foo) ProtoSize() int
+//
+// Arrays of bytes are encoded as they are, and arrays of other elements as
+// the packed encoding of their elements, so the field has the same encoding
+// as a packed repeated field. Unmarshal returns an error if the field does
+// not have as many elements as the array, unless it has none, which is the
+// zero array.
+//
+// Array types with any of those methods already declared are skipped with a
+// warning, as their methods are the ones used.
+// The file will be written to the package path and it will be named
+// "proteus_arrays.go".
+type Generator struct{}
+
+// NewGenerator creates a new Generator.
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// Generate writes the methods of the array types of the given package.
+// Nothing is written if there are none. It reports whether the file was
+// written.
+func (g *Generator) Generate(pkg *scanner.Package) (bool, error) {
+	if len(pkg.Arrays) == 0 {
+		return false, nil
+	}
+
+	declared, err := findMethods(filepath.Join(goSrc, pkg.Path))
+	if err != nil {
+		return false, err
+	}
+
+	var arrays []*scanner.Array
+	for _, a := range pkg.Arrays {
+		if name, ok := declaredMethod(declared, a.Name); ok {
+			report.WarnAt(report.CodeSkipped, a.Position, a.Name, "array type %s already has a %s method, no conversion methods are generated for it", a.Name, name)
+			continue
+		}
+		arrays = append(arrays, a)
+	}
+
+	if len(arrays) == 0 {
+		return false, nil
+	}
+
+	data, err := g.buildFile(pkg, arrays)
+	if err != nil {
+		return false, err
+	}
+
+	file := g.FileName(pkg.Path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return false, err
+	}
+
+	report.Info("Generated array conversions: %s", file)
+	return true, nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, FileName)
+}
+
+func (g *Generator) buildFile(pkg *scanner.Package, arrays []*scanner.Array) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(pkg.Path)))
+	buf.WriteString(fmt.Sprintf("package %s\n", pkg.Name))
+	buf.WriteString("\nimport \"gitlab.com/ThatTomPerson/proteus/customtypes\"\n")
+
+	for _, a := range arrays {
+		name := a.Name
+		if a.IsBytes() {
+			buf.WriteString(fmt.Sprintf("\n// Marshal returns the bytes of the %s.\n", name))
+			buf.WriteString(fmt.Sprintf("func (a *%s) Marshal() ([]byte, error) {\n", name))
+			buf.WriteString("\treturn append([]byte(nil), a[:]...), nil\n}\n")
+
+			buf.WriteString(fmt.Sprintf("\n// MarshalTo writes the bytes of the %s to the given buffer, which must\n// have room for them, and returns their size.\n", name))
+			buf.WriteString(fmt.Sprintf("func (a *%s) MarshalTo(data []byte) (int, error) {\n", name))
+			buf.WriteString("\treturn customtypes.MarshalBytesTo(data, a[:])\n}\n")
+
+			buf.WriteString(fmt.Sprintf("\n// Unmarshal sets the %s to the given bytes, which must be %d, unless\n// there are none.\n", name, a.Len))
+			buf.WriteString(fmt.Sprintf("func (a *%s) Unmarshal(data []byte) error {\n", name))
+			buf.WriteString("\treturn customtypes.UnmarshalBytes(a[:], data)\n}\n")
+
+			buf.WriteString(fmt.Sprintf("\n// Size returns the size of the bytes of the %s.\n", name))
+			buf.WriteString(fmt.Sprintf("func (a *%s) Size() int {\n", name))
+			buf.WriteString("\treturn len(a)\n}\n")
+		} else {
+			buf.WriteString(fmt.Sprintf("\n// Marshal returns the packed encoding of the elements of the %s.\n", name))
+			buf.WriteString(fmt.Sprintf("func (a *%s) Marshal() ([]byte, error) {\n", name))
+			buf.WriteString("\treturn customtypes.AppendPacked(nil, a[:]), nil\n}\n")
+
+			buf.WriteString(fmt.Sprintf("\n// MarshalTo writes the packed encoding of the elements of the %s to the\n// given buffer, which must have room for it, and returns its size.\n", name))
+			buf.WriteString(fmt.Sprintf("func (a *%s) MarshalTo(data []byte) (int, error) {\n", name))
+			buf.WriteString("\treturn customtypes.MarshalPackedTo(data, a[:])\n}\n")
+
+			buf.WriteString(fmt.Sprintf("\n// Unmarshal sets the %s to the given packed elements, which must be %d,\n// unless there are none.\n", name, a.Len))
+			buf.WriteString(fmt.Sprintf("func (a *%s) Unmarshal(data []byte) error {\n", name))
+			buf.WriteString("\treturn customtypes.UnmarshalPacked(a[:], data)\n}\n")
+
+			buf.WriteString(fmt.Sprintf("\n// Size returns the size of the packed encoding of the elements of the %s.\n", name))
+			buf.WriteString(fmt.Sprintf("func (a *%s) Size() int {\n", name))
+			buf.WriteString("\treturn customtypes.SizePacked(a[:])\n}\n")
+		}
+
+		buf.WriteString("\n// ProtoSize is like Size, for the messages generated with protosizer.\n")
+		buf.WriteString(fmt.Sprintf("func (a *%s) ProtoSize() int {\n", name))
+		buf.WriteString("\treturn a.Size()\n}\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// declaredMethod returns the name of the first method of the array type
+// with the given name that is already declared, if any.
+func declaredMethod(declared map[string]map[string]bool, array string) (string, bool) {
+	for _, name := range methods {
+		if declared[array][name] {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// findMethods returns the names of the methods of the types in the Go files
+// of the given folder, but the generated one, indexed by the name of the
+// type.
+func findMethods(dir string) (map[string]map[string]bool, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return fi.Name() != FileName && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv == nil {
+					continue
+				}
+
+				typ := fn.Recv.List[0].Type
+				if star, ok := typ.(*ast.StarExpr); ok {
+					typ = star.X
+				}
+
+				if ident, ok := typ.(*ast.Ident); ok {
+					if names[ident.Name] == nil {
+						names[ident.Name] = make(map[string]bool)
+					}
+					names[ident.Name][fn.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
@@ -0,0 +1,117 @@
+package arrayconv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedFile = `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo
+
+import "gitlab.com/ThatTomPerson/proteus/customtypes"
+
+// Marshal returns the bytes of the Hash.
+func (a *Hash) Marshal() ([]byte, error) {
+	return append([]byte(nil), a[:]...), nil
+}
+
+// MarshalTo writes the bytes of the Hash to the given buffer, which must
+// have room for them, and returns their size.
+func (a *Hash) MarshalTo(data []byte) (int, error) {
+	return customtypes.MarshalBytesTo(data, a[:])
+}
+
+// Unmarshal sets the Hash to the given bytes, which must be 32, unless
+// there are none.
+func (a *Hash) Unmarshal(data []byte) error {
+	return customtypes.UnmarshalBytes(a[:], data)
+}
+
+// Size returns the size of the bytes of the Hash.
+func (a *Hash) Size() int {
+	return len(a)
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (a *Hash) ProtoSize() int {
+	return a.Size()
+}
+
+// Marshal returns the packed encoding of the elements of the Point.
+func (a *Point) Marshal() ([]byte, error) {
+	return customtypes.AppendPacked(nil, a[:]), nil
+}
+
+// MarshalTo writes the packed encoding of the elements of the Point to the
+// given buffer, which must have room for it, and returns its size.
+func (a *Point) MarshalTo(data []byte) (int, error) {
+	return customtypes.MarshalPackedTo(data, a[:])
+}
+
+// Unmarshal sets the Point to the given packed elements, which must be 3,
+// unless there are none.
+func (a *Point) Unmarshal(data []byte) error {
+	return customtypes.UnmarshalPacked(a[:], data)
+}
+
+// Size returns the size of the packed encoding of the elements of the Point.
+func (a *Point) Size() int {
+	return customtypes.SizePacked(a[:])
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (a *Point) ProtoSize() int {
+	return a.Size()
+}
+`
+
+func TestBuildFile(t *testing.T) {
+	pkg := &scanner.Package{Name: "foo", Path: "gitlab.com/foo"}
+	arrays := []*scanner.Array{
+		{Name: "Hash", Elem: "uint8", Len: 32},
+		{Name: "Point", Elem: "float64", Len: 3},
+	}
+
+	data, err := NewGenerator().buildFile(pkg, arrays)
+	require.Nil(t, err)
+	require.Equal(t, expectedFile, string(data))
+}
+
+func TestDeclaredMethod(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-arrayconv")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"hash.go":  "package foo\n\ntype Hash [32]byte\n\nfunc (h *Hash) Unmarshal(data []byte) error { return nil }\n",
+		"point.go": "package foo\n\ntype Point [3]float64\n\nfunc Size(p Point) int { return 0 }\n",
+		FileName:   "package foo\n\nfunc (p *Point) Marshal() ([]byte, error) { return nil, nil }\n",
+	}
+	for name, content := range files {
+		require.Nil(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	declared, err := findMethods(dir)
+	require.Nil(err)
+
+	name, ok := declaredMethod(declared, "Hash")
+	require.True(ok)
+	require.Equal("Unmarshal", name)
+
+	_, ok = declaredMethod(declared, "Point")
+	require.False(ok, "funcs and the generated file are not taken into account")
+}
+
+func TestGenerateNoArrays(t *testing.T) {
+	ok, err := NewGenerator().Generate(&scanner.Package{Name: "foo", Path: "gitlab.com/foo"})
+	require.Nil(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,193 @@
+package bazel // import "gitlab.com/ThatTomPerson/proteus/bazel"
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+// DefaultLabels are the labels of the proto_library targets of the files
+// that are imported by default in the generated .proto files.
+var DefaultLabels = map[string]string{
+	"github.com/gogo/protobuf/gogoproto/gogo.proto": "@com_github_gogo_protobuf//gogoproto:gogo_proto",
+	"google/api/annotations.proto":                  "@go_googleapis//google/api:annotations_proto",
+	"google/protobuf/any.proto":                     "@com_google_protobuf//:any_proto",
+	"google/protobuf/duration.proto":                "@com_google_protobuf//:duration_proto",
+	"google/protobuf/empty.proto":                   "@com_google_protobuf//:empty_proto",
+	"google/protobuf/struct.proto":                  "@com_google_protobuf//:struct_proto",
+	"google/protobuf/timestamp.proto":               "@com_google_protobuf//:timestamp_proto",
+	"google/protobuf/wrappers.proto":                "@com_google_protobuf//:wrappers_proto",
+	"google/type/date.proto":                        "@go_googleapis//google/type:date_proto",
+	"google/type/decimal.proto":                     "@go_googleapis//google/type:decimal_proto",
+	"google/type/datetime.proto":                    "@go_googleapis//google/type:datetime_proto",
+	"google/type/timeofday.proto":                   "@go_googleapis//google/type:timeofday_proto",
+	"validate/validate.proto":                       "@com_envoyproxy_protoc_gen_validate//validate:validate_proto",
+}
+
+// Generator writes a BUILD.bazel file with the proto_library and
+// go_proto_library targets of a package next to its generated .proto file.
+//
+// The folder the .proto files are generated in is expected to be the root of
+// the Bazel workspace, so the file of a package with the path foo/bar is in
+// the Bazel package //foo/bar and its targets are //foo/bar:bar_proto and
+// //foo/bar:bar_go_proto. As the generated Go code uses the types declared in
+// the Go package, the go_proto_library target is meant to be embedded in the
+// go_library of the package. With other file layouts, the Bazel package is
+// the folder of the .proto file, so each Go package needs a folder of its
+// own.
+type Generator struct {
+	basePath string
+	labels   map[string]string
+	layout   protobuf.FileLayout
+}
+
+// NewGenerator creates a new Generator with the given base path and the
+// default labels.
+func NewGenerator(basePath string) *Generator {
+	labels := make(map[string]string, len(DefaultLabels))
+	for file, label := range DefaultLabels {
+		labels[file] = label
+	}
+	return &Generator{basePath: basePath, labels: labels}
+}
+
+// SetFileLayout sets the layout of the generated .proto files, so the
+// BUILD.bazel files are written next to them and the files imported from
+// other packages get their labels.
+func (g *Generator) SetFileLayout(layout protobuf.FileLayout) {
+	g.layout = layout
+}
+
+// SetLabel sets the label of the proto_library target for an imported file,
+// overriding the default one if any.
+func (g *Generator) SetLabel(file, label string) {
+	g.labels[file] = label
+}
+
+// Generate writes the BUILD.bazel file of the given package to disk.
+func (g *Generator) Generate(pkg *protobuf.Package) error {
+	fi, err := os.Stat(g.basePath)
+	if err != nil {
+		return err
+	}
+
+	file := g.FileName(pkg)
+	if err := output.MkdirAll(filepath.Dir(file), fi.Mode()); err != nil {
+		return err
+	}
+
+	if err := output.WriteFile(file, g.buildFile(pkg), fi.Mode()); err != nil {
+		return err
+	}
+
+	report.Info("Generated BUILD file: %s", file)
+	return nil
+}
+
+// FileName returns the path of the BUILD file generated for the given
+// package.
+func (g *Generator) FileName(pkg *protobuf.Package) string {
+	return filepath.Join(g.basePath, filepath.Dir(g.layout.File(pkg.Path, pkg.Name)), "BUILD.bazel")
+}
+
+func (g *Generator) buildFile(pkg *protobuf.Package) []byte {
+	var (
+		name      = targetName(pkg.Path)
+		protoDeps []string
+		goDeps    []string
+	)
+
+	for _, i := range pkg.Imports {
+		if l, ok := g.labels[i]; ok {
+			protoDeps = append(protoDeps, l)
+		} else if path, ok := g.layout.GoPath(i); ok {
+			dir := filepath.Dir(i)
+			protoDeps = append(protoDeps, label(dir, targetName(path), "_proto"))
+			goDeps = append(goDeps, label(dir, targetName(path), "_go_proto"))
+		} else {
+			report.Warn("no Bazel label for import %q of package %s, it will not be added as a dependency", i, pkg.Path)
+		}
+	}
+
+	compiler := "@io_bazel_rules_go//proto:gogo_proto"
+	if pkg.HasRPCs() {
+		compiler = "@io_bazel_rules_go//proto:gogo_grpc"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("# %s\n\n", protobuf.GeneratedBy(pkg.Path)))
+	buf.WriteString(`load("@rules_proto//proto:defs.bzl", "proto_library")` + "\n")
+	buf.WriteString(`load("@io_bazel_rules_go//proto:def.bzl", "go_proto_library")` + "\n\n")
+
+	buf.WriteString("proto_library(\n")
+	writeAttr(&buf, "name", name+"_proto")
+	writeListAttr(&buf, "srcs", []string{filepath.Base(g.layout.File(pkg.Path, pkg.Name))})
+	writeListAttr(&buf, "visibility", []string{"//visibility:public"})
+	writeListAttr(&buf, "deps", protoDeps)
+	buf.WriteString(")\n\n")
+
+	buf.WriteString("go_proto_library(\n")
+	writeAttr(&buf, "name", name+"_go_proto")
+	writeListAttr(&buf, "compilers", []string{compiler})
+	writeAttr(&buf, "importpath", pkg.Path)
+	writeAttr(&buf, "proto", ":"+name+"_proto")
+	writeListAttr(&buf, "visibility", []string{"//visibility:public"})
+	writeListAttr(&buf, "deps", goDeps)
+	buf.WriteString(")\n")
+
+	return buf.Bytes()
+}
+
+func writeAttr(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(fmt.Sprintf("    %s = %q,\n", name, value))
+}
+
+// writeListAttr writes a list attribute with its values sorted, as
+// buildifier expects. Empty lists are not written.
+func writeListAttr(buf *bytes.Buffer, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	values = append([]string(nil), values...)
+	sort.Strings(values)
+
+	if len(values) == 1 {
+		buf.WriteString(fmt.Sprintf("    %s = [%q],\n", name, values[0]))
+		return
+	}
+
+	buf.WriteString(fmt.Sprintf("    %s = [\n", name))
+	for _, v := range values {
+		buf.WriteString(fmt.Sprintf("        %q,\n", v))
+	}
+	buf.WriteString("    ],\n")
+}
+
+// label returns the label of the target with the given name and suffix of
+// the Bazel package at path.
+func label(path, name, suffix string) string {
+	return fmt.Sprintf("//%s:%s%s", path, name, suffix)
+}
+
+// targetName returns the base name of the targets of the package at the
+// given path, which is the last element of the path with all the characters
+// that are not letters, digits or underscores replaced by underscores.
+func targetName(path string) string {
+	name := filepath.Base(path)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,106 @@
+package bazel
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+)
+
+const expectedBuildFile = `# Code generated by proteus from gitlab.com/foo.v1. DO NOT EDIT.
+
+load("@rules_proto//proto:defs.bzl", "proto_library")
+load("@io_bazel_rules_go//proto:def.bzl", "go_proto_library")
+
+proto_library(
+    name = "foo_v1_proto",
+    srcs = ["generated.proto"],
+    visibility = ["//visibility:public"],
+    deps = [
+        "//gitlab.com/bar/baz:baz_proto",
+        "@com_github_gogo_protobuf//gogoproto:gogo_proto",
+        "@com_google_protobuf//:timestamp_proto",
+    ],
+)
+
+go_proto_library(
+    name = "foo_v1_go_proto",
+    compilers = ["@io_bazel_rules_go//proto:gogo_grpc"],
+    importpath = "gitlab.com/foo.v1",
+    proto = ":foo_v1_proto",
+    visibility = ["//visibility:public"],
+    deps = ["//gitlab.com/bar/baz:baz_go_proto"],
+)
+`
+
+func TestBuildFile(t *testing.T) {
+	pkg := &protobuf.Package{
+		Path: "gitlab.com/foo.v1",
+		Imports: []string{
+			"github.com/gogo/protobuf/gogoproto/gogo.proto",
+			"google/protobuf/timestamp.proto",
+			"gitlab.com/bar/baz/generated.proto",
+			"unknown.proto",
+		},
+		Services: []*protobuf.Service{
+			{Name: "FooService", RPCs: []*protobuf.RPC{{Name: "Foo"}}},
+		},
+	}
+
+	require.Equal(t, expectedBuildFile, string(NewGenerator("").buildFile(pkg)))
+}
+
+func TestBuildFileCustomLabel(t *testing.T) {
+	require := require.New(t)
+	pkg := &protobuf.Package{
+		Path:    "foo",
+		Imports: []string{"google/protobuf/timestamp.proto"},
+	}
+
+	g := NewGenerator("")
+	g.SetLabel("google/protobuf/timestamp.proto", "//third_party:timestamp_proto")
+	content := string(g.buildFile(pkg))
+
+	require.Contains(content, `deps = ["//third_party:timestamp_proto"],`)
+	require.Contains(content, `compilers = ["@io_bazel_rules_go//proto:gogo_proto"],`)
+	require.Equal("@com_google_protobuf//:timestamp_proto", DefaultLabels["google/protobuf/timestamp.proto"])
+}
+
+func TestGenerate(t *testing.T) {
+	require := require.New(t)
+	dir, err := ioutil.TempDir("", "proteus-bazel")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	pkg := &protobuf.Package{Path: "foo/bar"}
+	require.NoError(NewGenerator(dir).Generate(pkg))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "foo", "bar", "BUILD.bazel"))
+	require.NoError(err)
+	require.Contains(string(content), `name = "bar_proto",`)
+}
+
+func TestBuildFileLayout(t *testing.T) {
+	require := require.New(t)
+	pkg := &protobuf.Package{
+		Path:    "gitlab.com/foo",
+		Imports: []string{"protos/gitlab.com/bar/bar.proto"},
+	}
+
+	g := NewGenerator("")
+	g.SetFileLayout("protos/{path}/{name}.proto")
+	content := string(g.buildFile(pkg))
+
+	require.Contains(content, `srcs = ["foo.proto"],`)
+	require.Contains(content, `deps = ["//protos/gitlab.com/bar:bar_proto"],`)
+	require.Contains(content, `deps = ["//protos/gitlab.com/bar:bar_go_proto"],`)
+	require.Equal(filepath.Join("protos", "gitlab.com", "foo", "BUILD.bazel"), g.FileName(pkg))
+}
+
+func TestTargetName(t *testing.T) {
+	require.Equal(t, "proteus_v1", targetName("gopkg.in/src-d/proteus.v1"))
+	require.Equal(t, "foo_bar", targetName("foo-bar"))
+}
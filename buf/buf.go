@@ -0,0 +1,361 @@
+package buf // import "gitlab.com/ThatTomPerson/proteus/buf"
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+// DefaultDeps are the modules of the Buf Schema Registry with the files that
+// are imported by default in the generated .proto files. The well-known
+// types are left out, as buf already has them.
+var DefaultDeps = map[string]string{
+	"google/api/annotations.proto": "buf.build/googleapis/googleapis",
+	"google/type/date.proto":       "buf.build/googleapis/googleapis",
+	"google/type/datetime.proto":   "buf.build/googleapis/googleapis",
+	"google/type/decimal.proto":    "buf.build/googleapis/googleapis",
+	"google/type/timeofday.proto":  "buf.build/googleapis/googleapis",
+	"validate/validate.proto":      "buf.build/envoyproxy/protoc-gen-validate",
+}
+
+const (
+	// ConfigFile is the name of the configuration file of the buf module.
+	ConfigFile = "buf.yaml"
+	// GenConfigFile is the name of the file with the plugins run by buf
+	// generate.
+	GenConfigFile = "buf.gen.yaml"
+)
+
+const header = "# Code generated by proteus. DO NOT EDIT.\n"
+
+// Generator writes the buf.yaml and buf.gen.yaml files of the buf module
+// rooted at the folder the .proto files are generated in.
+//
+// The rules of the DEFAULT category of buf lint the generated files break
+// are disabled only for the folders of the files that break them, so the
+// rest are still checked. Imported files that are neither generated, well
+// known types nor in a module of the Buf Schema Registry, like gogo.proto,
+// are copied into the module from the GOPATH and ignored by buf lint and buf
+// breaking. buf generate is expected to be run in the folder of the module,
+// and writes the Go code to the folders of the packages in the GOPATH.
+type Generator struct {
+	basePath string
+	goSrc    string
+	deps     map[string]string
+	layout   protobuf.FileLayout
+	files    protobuf.MessageFiles
+	acronyms protobuf.Acronyms
+}
+
+// NewGenerator creates a new Generator with the given base path, the default
+// dependencies and the src folder of the default GOPATH.
+func NewGenerator(basePath string) *Generator {
+	deps := make(map[string]string, len(DefaultDeps))
+	for file, module := range DefaultDeps {
+		deps[file] = module
+	}
+	return &Generator{
+		basePath: basePath,
+		goSrc:    filepath.Join(build.Default.GOPATH, "src"),
+		deps:     deps,
+	}
+}
+
+// SetAcronyms sets the acronyms the enum values were named with, so their
+// prefixes are checked against the names of their enums with them.
+func (g *Generator) SetAcronyms(acronyms protobuf.Acronyms) {
+	g.acronyms = acronyms
+}
+
+// SetFileLayout sets the layout of the generated .proto files.
+func (g *Generator) SetFileLayout(layout protobuf.FileLayout) {
+	g.layout = layout
+}
+
+// SetMessageFiles sets the .proto files published elsewhere of Go packages,
+// which are given to the Go plugin with the Go packages they are in.
+func (g *Generator) SetMessageFiles(files protobuf.MessageFiles) {
+	g.files = files
+}
+
+// SetGoSrc sets the folder the Go code is written to by buf generate and the
+// imported files are copied from, which is the src folder of the GOPATH by
+// default.
+func (g *Generator) SetGoSrc(dir string) {
+	g.goSrc = dir
+}
+
+// SetDep sets the module of the Buf Schema Registry of an imported file,
+// overriding the default one if any.
+func (g *Generator) SetDep(file, module string) {
+	g.deps[file] = module
+}
+
+// Generate writes the buf.yaml and buf.gen.yaml files of the module with the
+// given packages to disk, along with the copies of the imported files, and
+// returns the files written.
+func (g *Generator) Generate(pkgs []*protobuf.Package) ([]string, error) {
+	fi, err := os.Stat(g.basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	deps, vendored := g.imports(pkgs)
+	var written []string
+	for _, f := range vendored {
+		dst := filepath.Join(g.basePath, f)
+		if err := copyFile(filepath.Join(g.goSrc, f), dst, fi.Mode()); err != nil {
+			return nil, fmt.Errorf("error copying imported file %q: %s", f, err)
+		}
+		written = append(written, dst)
+	}
+
+	files := map[string][]byte{
+		ConfigFile:    g.configFile(pkgs, deps, vendored),
+		GenConfigFile: g.genConfigFile(),
+	}
+	for _, name := range []string{ConfigFile, GenConfigFile} {
+		file := filepath.Join(g.basePath, name)
+		if err := output.WriteFile(file, files[name], fi.Mode()); err != nil {
+			return nil, err
+		}
+
+		report.Info("Generated buf file: %s", file)
+		written = append(written, file)
+	}
+	return written, nil
+}
+
+// imports returns the modules the given packages depend on and the files
+// they import that have to be copied into the module, both sorted. Imports
+// that can not be found anywhere are left out with a warning.
+func (g *Generator) imports(pkgs []*protobuf.Package) (deps, vendored []string) {
+	generated := make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		generated[g.layout.File(pkg.Path, pkg.Name)] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, i := range pkg.Imports {
+			if seen[i] || generated[i] || strings.HasPrefix(i, "google/protobuf/") {
+				continue
+			}
+			seen[i] = true
+
+			if m, ok := g.deps[i]; ok {
+				if !containsString(deps, m) {
+					deps = append(deps, m)
+				}
+			} else if _, err := os.Stat(filepath.Join(g.goSrc, i)); err == nil {
+				vendored = append(vendored, i)
+			} else {
+				report.Warn("no buf module for import %q of package %s, add it to the deps of %s", i, pkg.Path, ConfigFile)
+			}
+		}
+	}
+
+	sort.Strings(deps)
+	sort.Strings(vendored)
+	return deps, vendored
+}
+
+func (g *Generator) configFile(pkgs []*protobuf.Package, deps, vendored []string) []byte {
+	ignored := make(map[string][]string)
+	for _, pkg := range pkgs {
+		dir := filepath.Dir(g.layout.File(pkg.Path, pkg.Name))
+		for _, rule := range brokenRules(pkg, dir, pkgs, g.acronyms) {
+			if !containsString(ignored[rule], dir) {
+				ignored[rule] = append(ignored[rule], dir)
+				report.Info("package %s does not follow the buf lint rule %s, it is ignored for %s", pkg.Path, rule, dir)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("version: v1\n")
+	writeList(&buf, "", "deps", deps)
+
+	buf.WriteString("lint:\n")
+	writeList(&buf, "  ", "use", []string{"DEFAULT"})
+	writeList(&buf, "  ", "ignore", vendored)
+	if len(ignored) > 0 {
+		var rules = make([]string, 0, len(ignored))
+		for rule := range ignored {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+
+		buf.WriteString("  ignore_only:\n")
+		for _, rule := range rules {
+			sort.Strings(ignored[rule])
+			writeList(&buf, "    ", rule, ignored[rule])
+		}
+	}
+
+	buf.WriteString("breaking:\n")
+	writeList(&buf, "  ", "use", []string{"FILE"})
+	writeList(&buf, "  ", "ignore", vendored)
+	return buf.Bytes()
+}
+
+func (g *Generator) genConfigFile() []byte {
+	out, err := filepath.Rel(g.basePath, g.goSrc)
+	if err != nil {
+		out = g.goSrc
+	}
+
+	opts := []string{"plugins=grpc"}
+	for _, mappings := range []string{protobuf.RegisteredMappings().ToGoOutPath(), g.files.ToGoOutPath()} {
+		if mappings != "" {
+			opts = append(opts, strings.Split(mappings, ",")...)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("version: v1\n")
+	buf.WriteString("plugins:\n")
+	buf.WriteString("  - name: gofast\n")
+	buf.WriteString(fmt.Sprintf("    out: %s\n", filepath.ToSlash(out)))
+	writeList(&buf, "    ", "opt", opts)
+	return buf.Bytes()
+}
+
+var (
+	upperSnakeCaseExpr = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+	lowerSnakeCaseExpr = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+)
+
+// brokenRules returns the rules of the DEFAULT category of buf lint the
+// given package, whose file is in the given folder, does not follow. pkgs are
+// all the packages of the module, named with the given acronyms.
+func brokenRules(pkg *protobuf.Package, dir string, pkgs []*protobuf.Package, acronyms protobuf.Acronyms) []string {
+	var rules []string
+	if filepath.ToSlash(dir) != strings.Replace(pkg.Name, ".", "/", -1) {
+		rules = append(rules, "PACKAGE_DIRECTORY_MATCH")
+	}
+
+	if !pkg.IsVersioned() {
+		rules = append(rules, "PACKAGE_VERSION_SUFFIX")
+	}
+
+	var prefix, upperSnakeCase, zeroSuffix bool
+	for _, e := range pkg.Enums {
+		for _, v := range e.Values {
+			prefix = prefix || !strings.HasPrefix(v.Name, acronyms.EnumValuePrefix(e.Name))
+			upperSnakeCase = upperSnakeCase || !upperSnakeCaseExpr.MatchString(v.Name)
+			zeroSuffix = zeroSuffix || (v.Value == 0 && !strings.HasSuffix(v.Name, "_UNSPECIFIED"))
+		}
+	}
+	rules = appendIf(rules, prefix, "ENUM_VALUE_PREFIX")
+	rules = appendIf(rules, upperSnakeCase, "ENUM_VALUE_UPPER_SNAKE_CASE")
+	rules = appendIf(rules, zeroSuffix, "ENUM_ZERO_VALUE_SUFFIX")
+
+	var lowerSnakeCase bool
+	for _, m := range pkg.Messages {
+		for _, f := range m.Fields {
+			lowerSnakeCase = lowerSnakeCase || !lowerSnakeCaseExpr.MatchString(f.Name)
+		}
+	}
+	rules = appendIf(rules, lowerSnakeCase, "FIELD_LOWER_SNAKE_CASE")
+
+	uses := rpcTypeUses(pkgs)
+	var suffix, request, response, unique bool
+	for _, svc := range pkg.Services {
+		suffix = suffix || !strings.HasSuffix(svc.Name, "Service")
+		for _, rpc := range svc.RPCs {
+			request = request || !isStandardName(rpc.Input, svc.Name, rpc.Name, "Request")
+			response = response || !isStandardName(rpc.Output, svc.Name, rpc.Name, "Response")
+			unique = unique || uses[typeName(rpc.Input)] > 1 || uses[typeName(rpc.Output)] > 1
+		}
+	}
+	rules = appendIf(rules, suffix, "SERVICE_SUFFIX")
+	rules = appendIf(rules, request, "RPC_REQUEST_STANDARD_NAME")
+	rules = appendIf(rules, response, "RPC_RESPONSE_STANDARD_NAME")
+	rules = appendIf(rules, unique, "RPC_REQUEST_RESPONSE_UNIQUE")
+	return rules
+}
+
+// rpcTypeUses returns how many times every type is used as the request or
+// the response of the RPCs of the given packages.
+func rpcTypeUses(pkgs []*protobuf.Package) map[string]int {
+	uses := make(map[string]int)
+	for _, pkg := range pkgs {
+		for _, svc := range pkg.Services {
+			for _, rpc := range svc.RPCs {
+				uses[typeName(rpc.Input)]++
+				uses[typeName(rpc.Output)]++
+			}
+		}
+	}
+	delete(uses, "")
+	return uses
+}
+
+// isStandardName reports whether the given type is named like buf expects
+// the request or response of an RPC, which is the name of the RPC, with or
+// without the one of its service before it, and the given suffix.
+func isStandardName(typ protobuf.Type, svc, rpc, suffix string) bool {
+	n, ok := typ.(*protobuf.Named)
+	return ok && (n.Name == rpc+suffix || n.Name == svc+rpc+suffix)
+}
+
+func typeName(typ protobuf.Type) string {
+	if n, ok := typ.(*protobuf.Named); ok {
+		return n.Package + "." + n.Name
+	}
+	return ""
+}
+
+func appendIf(list []string, cond bool, s string) []string {
+	if cond {
+		return append(list, s)
+	}
+	return list
+}
+
+// writeList writes a YAML list with the given indentation. Empty lists are
+// not written.
+func writeList(buf *bytes.Buffer, indent, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	buf.WriteString(fmt.Sprintf("%s%s:\n", indent, name))
+	for _, v := range values {
+		buf.WriteString(fmt.Sprintf("%s  - %s\n", indent, v))
+	}
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	if err := output.MkdirAll(filepath.Dir(dst), mode); err != nil {
+		return err
+	}
+	return output.WriteFile(dst, data, mode)
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
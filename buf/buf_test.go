@@ -0,0 +1,144 @@
+package buf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+)
+
+const expectedConfigFile = `# Code generated by proteus. DO NOT EDIT.
+version: v1
+deps:
+  - buf.build/envoyproxy/protoc-gen-validate
+  - buf.build/googleapis/googleapis
+lint:
+  use:
+    - DEFAULT
+  ignore:
+    - github.com/gogo/protobuf/gogoproto/gogo.proto
+  ignore_only:
+    ENUM_VALUE_PREFIX:
+      - gitlab/com/bar
+    ENUM_ZERO_VALUE_SUFFIX:
+      - gitlab/com/bar
+    PACKAGE_VERSION_SUFFIX:
+      - gitlab/com/bar
+    RPC_REQUEST_RESPONSE_UNIQUE:
+      - gitlab/com/bar
+    RPC_RESPONSE_STANDARD_NAME:
+      - gitlab/com/bar
+breaking:
+  use:
+    - FILE
+  ignore:
+    - github.com/gogo/protobuf/gogoproto/gogo.proto
+`
+
+func testPackages() []*protobuf.Package {
+	return []*protobuf.Package{
+		{
+			Name: "gitlab.com.foo.v1",
+			Path: "gitlab.com/foo",
+			Imports: []string{
+				"github.com/gogo/protobuf/gogoproto/gogo.proto",
+				"google/api/annotations.proto",
+				"google/protobuf/timestamp.proto",
+				"gitlab/com/bar/bar.proto",
+			},
+			Enums: []*protobuf.Enum{
+				{Name: "Kind", Values: []*protobuf.EnumValue{{Name: "KIND_UNSPECIFIED"}, {Name: "KIND_FOO", Value: 1}}},
+			},
+			Messages: []*protobuf.Message{
+				{Name: "Foo", Fields: []*protobuf.Field{{Name: "foo_id", Pos: 1, Type: protobuf.NewBasic("string")}}},
+			},
+			Services: []*protobuf.Service{
+				{Name: "FooService", RPCs: []*protobuf.RPC{
+					{Name: "GetFoo", Input: protobuf.NewNamed("gitlab.com.foo.v1", "GetFooRequest"), Output: protobuf.NewNamed("gitlab.com.foo.v1", "FooServiceGetFooResponse")},
+				}},
+			},
+		},
+		{
+			Name:    "gitlab.com.bar",
+			Path:    "gitlab.com/bar",
+			Imports: []string{"github.com/gogo/protobuf/gogoproto/gogo.proto", "validate/validate.proto", "unknown.proto"},
+			Enums: []*protobuf.Enum{
+				{Name: "Color", Values: []*protobuf.EnumValue{{Name: "RED"}}},
+			},
+			Services: []*protobuf.Service{
+				{Name: "BarService", RPCs: []*protobuf.RPC{
+					{Name: "GetBar", Input: protobuf.NewNamed("gitlab.com.bar", "GetBarRequest"), Output: protobuf.NewNamed("gitlab.com.bar", "Bar")},
+					{Name: "ListBars", Input: protobuf.NewNamed("gitlab.com.bar", "ListBarsRequest"), Output: protobuf.NewNamed("gitlab.com.bar", "Bar")},
+				}},
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	require := require.New(t)
+	dir, err := ioutil.TempDir("", "proteus-buf")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	gogo := filepath.Join(src, "github.com", "gogo", "protobuf", "gogoproto", "gogo.proto")
+	require.NoError(os.MkdirAll(filepath.Dir(gogo), 0755))
+	require.NoError(ioutil.WriteFile(gogo, []byte(`syntax = "proto2";`), 0644))
+
+	module := filepath.Join(dir, "protos")
+	require.NoError(os.MkdirAll(module, 0755))
+
+	g := NewGenerator(module)
+	g.SetGoSrc(src)
+	g.SetFileLayout("{package}/{name}.proto")
+	written, err := g.Generate(testPackages())
+	require.NoError(err)
+	require.Equal([]string{
+		filepath.Join(module, "github.com", "gogo", "protobuf", "gogoproto", "gogo.proto"),
+		filepath.Join(module, ConfigFile),
+		filepath.Join(module, GenConfigFile),
+	}, written)
+
+	content, err := ioutil.ReadFile(filepath.Join(module, ConfigFile))
+	require.NoError(err)
+	require.Equal(expectedConfigFile, string(content))
+
+	content, err = ioutil.ReadFile(filepath.Join(module, GenConfigFile))
+	require.NoError(err)
+	require.Contains(string(content), "  - name: gofast\n    out: ../src\n    opt:\n      - plugins=grpc\n")
+
+	content, err = ioutil.ReadFile(written[0])
+	require.NoError(err)
+	require.Equal(`syntax = "proto2";`, string(content))
+}
+
+func TestBrokenRules(t *testing.T) {
+	pkg := &protobuf.Package{
+		Name: "foo",
+		Enums: []*protobuf.Enum{
+			{Name: "Kind", Values: []*protobuf.EnumValue{{Name: "KindFoo"}}},
+		},
+		Messages: []*protobuf.Message{
+			{Name: "Foo", Fields: []*protobuf.Field{{Name: "FooID", Pos: 1, Type: protobuf.NewBasic("string")}}},
+		},
+		Services: []*protobuf.Service{
+			{Name: "Foo", RPCs: []*protobuf.RPC{
+				{Name: "Get", Input: protobuf.NewNamed("foo", "FooGetRequest"), Output: protobuf.NewNamed("foo", "GetResponse")},
+			}},
+		},
+	}
+
+	require.Equal(t, []string{
+		"PACKAGE_DIRECTORY_MATCH",
+		"PACKAGE_VERSION_SUFFIX",
+		"ENUM_VALUE_PREFIX",
+		"ENUM_VALUE_UPPER_SNAKE_CASE",
+		"ENUM_ZERO_VALUE_SUFFIX",
+		"FIELD_LOWER_SNAKE_CASE",
+		"SERVICE_SUFFIX",
+	}, brokenRules(pkg, "bar", []*protobuf.Package{pkg}, protobuf.Acronyms{}))
+}
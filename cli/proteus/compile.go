@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	protocOuts    cli.StringSlice
+	protocPlugins cli.StringSlice
+)
+
+var (
+	protocOutExpr    = regexp.MustCompile(`^([a-z][a-z0-9_-]*)_(out|opt)=(.+)$`)
+	protocPluginExpr = regexp.MustCompile(`^([a-z][a-z0-9_-]*)=(.+)$`)
+)
+
+// builtinGenerators are the outputs protoc generates by itself, without a
+// plugin.
+var builtinGenerators = []string{"cpp", "csharp", "java", "js", "kotlin", "objc", "php", "pyi", "python", "ruby"}
+
+// protocArgs returns the protoc arguments of the given outputs, which are in
+// the form NAME_out=[PARAMS:]DIR or NAME_opt=PARAMS, and the names of the
+// plugins they need.
+func protocArgs(outs []string) (args, plugins []string, err error) {
+	seen := make(map[string]bool)
+	for _, o := range outs {
+		m := protocOutExpr.FindStringSubmatch(o)
+		if m == nil {
+			return nil, nil, fmt.Errorf("invalid protoc output %q, expected NAME_out=[PARAMS:]DIR or NAME_opt=PARAMS", o)
+		}
+
+		args = append(args, "--"+o)
+		if name := m[1]; m[2] == "out" && !seen[name] && !containsString(builtinGenerators, name) {
+			seen[name] = true
+			plugins = append(plugins, name)
+		}
+	}
+	return args, plugins, nil
+}
+
+// protocPluginPaths returns the paths of the given plugins, which are in the
+// form NAME=PATH, indexed by their name. Paths must be absolute executable
+// files.
+func protocPluginPaths(list []string) (map[string]string, error) {
+	paths := make(map[string]string)
+	for _, p := range list {
+		m := protocPluginExpr.FindStringSubmatch(p)
+		if m == nil {
+			return nil, fmt.Errorf("invalid protoc plugin %q, expected NAME=PATH", p)
+		}
+
+		if err := checkExecutable(m[2]); err != nil {
+			return nil, fmt.Errorf("invalid protoc plugin %q: %s", p, err)
+		}
+		paths[m[1]] = m[2]
+	}
+	return paths, nil
+}
+
+// compileProtos runs protoc with the outputs given with --protoc-out on the
+// .proto files of every package generated, one package at a time, so the
+// errors of protoc tell which package failed.
+func compileProtos() error {
+	protocPath, err := findProtoc()
+	if err != nil {
+		return err
+	}
+
+	outs, plugins, _ := protocArgs(protocOuts)
+	paths, _ := protocPluginPaths(protocPlugins)
+
+	var names = make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := outs
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-%s=%s", name, paths[name]))
+	}
+
+	if hermetic {
+		for _, name := range plugins {
+			if _, ok := paths[name]; !ok {
+				return fmt.Errorf("the path of protoc-gen-%s must be given with --protoc-plugin in hermetic mode", name)
+			}
+		}
+	}
+
+	for _, p := range generatedPackages() {
+		protos, err := packageProtoFiles(p)
+		if err != nil {
+			return err
+		}
+
+		if err := protocCompile(protocPath, args, protos); err != nil {
+			return fmt.Errorf("error compiling the .proto files of %s with protoc: %s", p, err)
+		}
+		report.Info("Compiled with protoc: %s", strings.Join(protos, ", "))
+	}
+	return nil
+}
+
+// protocCompile runs protoc with the given arguments on the given files. If
+// it fails, the error has what protoc wrote to its standard error.
+func protocCompile(protocPath string, args, protoFiles []string) error {
+	protoPath := fmt.Sprintf(
+		"--proto_path=%s:%s:%s:%s:.",
+		goSrc,
+		path,
+		filepath.Join(protobufSrc, "protobuf"),
+		filepath.Dir(protoFiles[0]),
+	)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(protocPath, append(append([]string{protoPath}, args...), protoFiles...)...)
+	cmd.Env = toolEnv()
+	cmd.Stderr = &stderr
+
+	report.Info("executing protoc: %s %s", protocPath, strings.Join(cmd.Args[1:], " "))
+	if err := cmd.Run(); err != nil {
+		return protocError(err, stderr.String())
+	}
+	return nil
+}
+
+// protocError returns the error of a failed protoc run with its output, if
+// any, adding a hint if a plugin could not be found.
+func protocError(err error, output string) error {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return err
+	}
+
+	if strings.Contains(output, "program not found or is not executable") {
+		output += "\ninstall the plugin on the PATH or give its path with --protoc-plugin NAME=PATH"
+	}
+	return fmt.Errorf("%s\n%s", err, output)
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+)
+
+// blankUnusedImports returns the given Go file with the named imports that
+// are not used turned into blank imports. protoc-gen-gofast imports the
+// packages of the .proto files of the message types of all the fields, even
+// of the fields with a gogoproto.customtype, like the ones of the customtypes
+// package, whose generated code only uses the custom type, so the file would
+// not compile otherwise. The file is returned as it is if all of them are
+// used.
+func blankUnusedImports(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+
+	var changed bool
+	for _, imp := range f.Imports {
+		if imp.Name == nil || imp.Name.Name == "_" || imp.Name.Name == "." || used[imp.Name.Name] {
+			continue
+		}
+		imp.Name.Name = "_"
+		changed = true
+	}
+
+	if !changed {
+		return src, nil
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blankUnusedImportsOf rewrites the given Go files with blankUnusedImports.
+func blankUnusedImportsOf(files []string) error {
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		fixed, err := blankUnusedImports(src)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(fixed, src) {
+			continue
+		}
+
+		if err := ioutil.WriteFile(file, fixed, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
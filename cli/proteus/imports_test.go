@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const unusedImportsSrc = `package foo
+
+import fmt "fmt"
+import google_type "google.golang.org/genproto/googleapis/type/date"
+import _ "github.com/gogo/protobuf/gogoproto"
+
+var _ = fmt.Errorf
+`
+
+func TestBlankUnusedImports(t *testing.T) {
+	require := require.New(t)
+
+	fixed, err := blankUnusedImports([]byte(unusedImportsSrc))
+	require.NoError(err)
+	require.Contains(string(fixed), "import fmt \"fmt\"\n")
+	require.Contains(string(fixed), "import _ \"google.golang.org/genproto/googleapis/type/date\"\n")
+	require.Contains(string(fixed), "import _ \"github.com/gogo/protobuf/gogoproto\"\n")
+
+	same, err := blankUnusedImports(fixed)
+	require.NoError(err)
+	require.Equal(fixed, same)
+
+	_, err = blankUnusedImports([]byte("package"))
+	require.Error(err)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/gogo/protobuf/protoc-gen-gogo/generator"
+	plugin "github.com/gogo/protobuf/protoc-gen-gogo/plugin"
+	"github.com/gogo/protobuf/vanity"
+	// The plugins of protoc-gen-gofast are registered with the command.
+	_ "github.com/gogo/protobuf/vanity/command"
+
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+var noProtoc bool
+
+// descriptorSet returns the descriptors of the given .proto files, which are
+// in the given folder, and of the files they import, built in-process, along
+// with the names of the files.
+func descriptorSet(protoDir string, protoFiles ...string) (*protobuf.DescriptorSet, []string, error) {
+	set := protobuf.NewDescriptorSet(goSrc, path, filepath.Join(protobufSrc, "protobuf"), protoDir, ".")
+
+	var names = make([]string, len(protoFiles))
+	for i, f := range protoFiles {
+		name, err := set.AddFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		names[i] = name
+	}
+	return set, names, nil
+}
+
+// gofastGenerate generates the Go files of the given .proto files of the Go
+// package at the given path in-process, like protoc-gen-gofast does, and
+// writes them to the folder of the package. It returns the files written.
+func gofastGenerate(p string, protoFiles []string) ([]string, error) {
+	set, names, err := descriptorSet(filepath.Dir(protoFiles[0]), protoFiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: names,
+		Parameter:      proto.String(gofastParams()),
+		ProtoFile:      set.Files(),
+	}
+
+	files := req.GetProtoFile()
+	vanity.ForEachFile(files, vanity.TurnOffGogoImport)
+	vanity.ForEachFile(files, vanity.TurnOnMarshalerAll)
+	vanity.ForEachFile(files, vanity.TurnOnSizerAll)
+	vanity.ForEachFile(files, vanity.TurnOnUnmarshalerAll)
+
+	resp := generate(req)
+	if resp.Error != nil {
+		return nil, errors.New(resp.GetError())
+	}
+
+	var written []string
+	for _, f := range resp.File {
+		dst := filepath.Join(goSrc, p, filepath.Base(f.GetName()))
+		if err := ioutil.WriteFile(dst, []byte(f.GetContent()), 0644); err != nil {
+			return nil, fmt.Errorf("error writing %q: %s", dst, err)
+		}
+
+		report.Info("Generated Go file in-process: %s", dst)
+		written = append(written, dst)
+	}
+	return written, nil
+}
+
+// generate runs the generator of protoc-gen-gofast with the given request, as
+// command.Generate does but for the generation of tests, which leaves it as
+// the only plugin of the generator, so the files generated later in the same
+// run would have no gRPC, marshaling nor sizing code. The named imports that
+// are not used are made blank, see blankUnusedImports.
+func generate(req *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorResponse {
+	g := generator.New()
+	g.Request = req
+	g.CommandLineParameters(g.Request.GetParameter())
+	g.WrapTypes()
+	g.SetPackageNames()
+	g.BuildTypeNameMap()
+	g.GenerateAllFiles()
+
+	for _, f := range g.Response.File {
+		formatted, err := format.Source([]byte(f.GetContent()))
+		if err != nil {
+			g.Response.Error = proto.String(fmt.Sprintf("go format error: %s", err))
+			break
+		}
+
+		formatted, err = blankUnusedImports(formatted)
+		if err != nil {
+			g.Response.Error = proto.String(fmt.Sprintf("go imports error: %s", err))
+			break
+		}
+		f.Content = proto.String(string(formatted))
+	}
+	return g.Response
+}
+
+// writeDescriptorSet writes the descriptor set of the given .proto files,
+// including all the files they import, built in-process, to the given file.
+func writeDescriptorSet(out string, protoFiles ...string) error {
+	set, _, err := descriptorSet(filepath.Dir(protoFiles[0]), protoFiles...)
+	if err != nil {
+		return err
+	}
+
+	data, err := proto.Marshal(&descriptor.FileDescriptorSet{File: set.Files()})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, data, 0644)
+}
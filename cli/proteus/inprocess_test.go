@@ -0,0 +1,1012 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/ThatTomPerson/proteus"
+)
+
+const gofastPkg = "gitlab.com/ThatTomPerson/proteus/fixtures/gofast"
+
+const setsFile = `package gofast
+
+type Tags map[string]struct{}
+
+//proteus:generate
+type User struct {
+	Name string
+}
+
+//proteus:generate
+func Tag(user *User, tags map[string]struct{}, more Tags) map[string]struct{} {
+	return tags
+}
+`
+
+// writeFixture writes the package at gofastPkg with the given file, and
+// returns the func removing it.
+func writeFixture(t *testing.T, file string) func() {
+	dir := filepath.Join(goSrc, gofastPkg)
+	require.Nil(t, os.MkdirAll(dir, 0777))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "foo.go"), []byte(file), 0644))
+	return func() { os.RemoveAll(dir) }
+}
+
+// generateAndBuild generates the .proto file, the Go files and the RPC server
+// of the package with the given file in-process and builds it, along with the
+// files using the generated code, if any.
+func generateAndBuild(t *testing.T, file string, options proteus.Options, uses ...string) {
+	defer writeFixture(t, file)()
+	generateFixture(t, options, uses...)
+	runGo(t, "build")
+}
+
+// generateAndTest is like generateAndBuild, but it also generates the
+// property-based tests of the package and runs its tests instead.
+func generateAndTest(t *testing.T, file string, options proteus.Options, uses ...string) {
+	defer writeFixture(t, file)()
+	generateFixture(t, options, uses...)
+	options.Packages = []string{gofastPkg}
+	require.Nil(t, proteus.GeneratePropertyTests(options))
+	require.FileExists(t, filepath.Join(goSrc, gofastPkg, "proteus_property_test.go"))
+	runGo(t, "test")
+}
+
+// generateAndBench is like generateAndBuild, but it also writes the given
+// benchmarks of the generated code and runs them, logging their results.
+func generateAndBench(t *testing.T, file string, options proteus.Options, bench string) {
+	defer writeFixture(t, file)()
+	generateFixture(t, options)
+	name := filepath.Join(goSrc, gofastPkg, "bench_test.go")
+	require.Nil(t, ioutil.WriteFile(name, []byte(bench), 0644))
+	t.Log(runGo(t, "test", "-run", "^$", "-bench", ".", "-benchmem", "-benchtime", "1000x"))
+}
+
+// generateFixture generates the .proto file, the Go files and the RPC server
+// of the package already written at gofastPkg in-process, and writes the
+// files using the generated code, if any.
+func generateFixture(t *testing.T, options proteus.Options, uses ...string) {
+	require := require.New(t)
+	protoDir, err := ioutil.TempDir("", "proteus")
+	require.Nil(err)
+	defer os.RemoveAll(protoDir)
+	writeGoogleTypeProtos(t, protoDir)
+
+	oldPath := path
+	path = protoDir
+	defer func() { path = oldPath }()
+
+	options.BasePath = protoDir
+	options.Packages = []string{gofastPkg}
+	require.Nil(proteus.GenerateProtos(options))
+
+	protos, err := packageProtoFiles(gofastPkg)
+	require.Nil(err)
+	_, err = gofastGenerate(gofastPkg, protos)
+	require.Nil(err)
+	require.Nil(proteus.GenerateRPCServerWithOptions(options))
+	require.FileExists(filepath.Join(goSrc, gofastPkg, "server.proteus.go"))
+
+	for i, use := range uses {
+		name := filepath.Join(goSrc, gofastPkg, fmt.Sprintf("use%d.go", i))
+		require.Nil(ioutil.WriteFile(name, []byte(use), 0644))
+	}
+}
+
+// runGo runs the given go command with the given flags in the package at
+// gofastPkg and returns its output.
+func runGo(t *testing.T, command string, flags ...string) string {
+	cmd := exec.Command("go", append(append([]string{command}, flags...), ".")...)
+	cmd.Dir = filepath.Join(goSrc, gofastPkg)
+	out, err := cmd.CombinedOutput()
+	require.Nil(t, err, "generated code does not %s:\n%s", command, out)
+	return string(out)
+}
+
+// googleTypeProtos are the messages of google/type used by the custom types,
+// which are usually on the proto path with the rest of googleapis.
+var googleTypeProtos = map[string]string{
+	"date.proto":      "message Date { int32 year = 1; int32 month = 2; int32 day = 3; }",
+	"timeofday.proto": "message TimeOfDay { int32 hours = 1; int32 minutes = 2; int32 seconds = 3; int32 nanos = 4; }",
+	"decimal.proto":   "message Decimal { string value = 1; }",
+	"datetime.proto":  "message DateTime { int32 year = 1; int32 month = 2; int32 day = 3; int32 hours = 4; int32 minutes = 5; int32 seconds = 6; int32 nanos = 7; }",
+}
+
+func writeGoogleTypeProtos(t *testing.T, protoDir string) {
+	require := require.New(t)
+	dir := filepath.Join(protoDir, "google", "type")
+	require.Nil(os.MkdirAll(dir, 0777))
+	for name, message := range googleTypeProtos {
+		pkg := strings.TrimSuffix(name, ".proto")
+		content := fmt.Sprintf(
+			"syntax = \"proto3\";\npackage google.type;\noption go_package = \"google.golang.org/genproto/googleapis/type/%s;%s\";\n%s\n",
+			pkg, pkg, message,
+		)
+		require.Nil(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+}
+
+func TestGofastGenerateSets(t *testing.T) {
+	generateAndBuild(t, setsFile, proteus.Options{})
+}
+
+func TestGofastGenerateTwice(t *testing.T) {
+	generateAndBuild(t, setsFile, proteus.Options{})
+	generateAndBuild(t, setsFile, proteus.Options{})
+}
+
+const boolSetsFile = `package gofast
+
+type Votes map[int64]bool
+
+//proteus:generate
+type User struct {
+	Name  string
+	Flags map[string]bool
+	Votes Votes
+}
+
+//proteus:generate
+func Enable(user *User, flags map[string]bool, votes Votes) map[string]bool {
+	return flags
+}
+`
+
+func TestGofastGenerateBoolSets(t *testing.T) {
+	generateAndBuild(t, boolSetsFile, proteus.Options{BoolSets: true})
+}
+
+const setFieldFile = `package gofast
+
+//proteus:generate
+type User struct {
+	Name string
+	Tags map[string]struct{}
+}
+`
+
+func TestGenerateSetField(t *testing.T) {
+	defer writeFixture(t, setFieldFile)()
+
+	protoDir, err := ioutil.TempDir("", "proteus")
+	require.Nil(t, err)
+	defer os.RemoveAll(protoDir)
+
+	err = proteus.GenerateProtos(proteus.Options{BasePath: protoDir, Packages: []string{gofastPkg}})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "User.Tags")
+}
+
+const anyFieldsFile = `package gofast
+
+import (
+	"github.com/gogo/protobuf/types"
+
+	"gitlab.com/ThatTomPerson/proteus/anyconv"
+)
+
+//proteus:generate
+type Shape interface {
+	Area() float64
+}
+
+//proteus:generate
+type Circle struct {
+	Radius float64
+}
+
+func (c *Circle) Area() float64 {
+	return 3 * c.Radius * c.Radius
+}
+
+//proteus:generate
+type Drawing struct {
+	Name   string
+	Shape  Shape         ` + "`proteus:\"any\"`" + `
+	Shapes []anyconv.Any ` + "`proteus:\"any\"`" + `
+	Main   anyconv.Any
+	Cover  *types.Any    ` + "`proteus:\"any\"`" + `
+	Layers []*types.Any
+}
+
+//proteus:generate
+func Draw(d *Drawing) *Drawing {
+	return d
+}
+`
+
+const anyFieldsUse = `package gofast
+
+import "gitlab.com/ThatTomPerson/proteus/anyconv"
+
+func roundTrip(shapes []Shape) ([]Shape, error) {
+	anys, err := anyconv.ToAnys(shapes)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := (&Drawing{Shapes: anys, Main: anys[0]}).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded Drawing
+	if err := decoded.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	var result []Shape
+	return result, anyconv.FromAnys(decoded.Shapes, &result)
+}
+`
+
+func TestGofastGenerateAnyFields(t *testing.T) {
+	generateAndBuild(t, anyFieldsFile, proteus.Options{}, anyFieldsUse)
+}
+
+const valueFieldsFile = `package gofast
+
+import (
+	"github.com/gogo/protobuf/types"
+
+	"gitlab.com/ThatTomPerson/proteus/anyconv"
+)
+
+//proteus:generate
+type Event struct {
+	Name    string
+	Payload *types.Value  ` + "`proteus:\"value\"`" + `
+	Raw     interface{}   ` + "`proteus:\"value\"`" + `
+	Data    anyconv.Value ` + "`proteus:\"value\"`" + `
+	Typed   anyconv.Any
+	Tags    []*types.Value
+	Labels  []anyconv.Value
+	Extra   []interface{}
+}
+
+//proteus:generate
+func Publish(e *Event) *Event {
+	return e
+}
+`
+
+const valueFieldsUse = `package gofast
+
+import "gitlab.com/ThatTomPerson/proteus/anyconv"
+
+func roundTrip(data map[string]interface{}) (interface{}, error) {
+	encoded, err := (&Event{Data: anyconv.Value{Data: data}}).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded Event
+	err = decoded.Unmarshal(encoded)
+	return decoded.Data.Data, err
+}
+`
+
+func TestGofastGenerateValueFields(t *testing.T) {
+	generateAndBuild(t, valueFieldsFile, proteus.Options{}, valueFieldsUse)
+}
+
+const funcOptionsFile = `package gofast
+
+type SearchOption func(*searchOptions)
+
+type searchOptions struct {
+	limit   int
+	verbose bool
+	order   string
+	tags    []string
+}
+
+func WithLimit(n int) SearchOption {
+	return func(o *searchOptions) { o.limit = n }
+}
+
+func WithVerbose(v bool) SearchOption {
+	return func(o *searchOptions) { o.verbose = v }
+}
+
+func WithOrder(order string) SearchOption {
+	return func(o *searchOptions) { o.order = order }
+}
+
+func WithTags(tags []string) SearchOption {
+	return func(o *searchOptions) { o.tags = tags }
+}
+
+//proteus:generate
+//proteus:options WithLimit WithVerbose WithOrder WithTags
+func Search(query string, opts ...SearchOption) []string {
+	return nil
+}
+`
+
+func TestGofastGenerateFuncOptions(t *testing.T) {
+	generateAndBuild(t, funcOptionsFile, proteus.Options{})
+}
+
+const methodServiceFile = `package gofast
+
+//proteus:generate
+type User struct {
+	Name string
+}
+
+type Store struct {
+	users map[string]*User
+}
+
+//proteus:generate
+func (s *Store) Get(name string) *User {
+	return s.users[name]
+}
+
+type storeServiceServer struct {
+	Store *Store
+}
+
+func NewStoreServiceServer(store *Store) *storeServiceServer {
+	return &storeServiceServer{Store: store}
+}
+`
+
+const serveFile = `package gofast
+
+import "google.golang.org/grpc"
+
+func Serve(store *Store) *grpc.Server {
+	return NewGRPCServer(DefaultGRPCServerConfig(), NewStoreServiceServer(store))
+}
+`
+
+func TestGofastGenerateMethodService(t *testing.T) {
+	generateAndBuild(t, methodServiceFile, proteus.Options{}, serveFile)
+}
+
+const civilFile = `package gofast
+
+import "gitlab.com/ThatTomPerson/proteus/customtypes"
+
+//proteus:generate
+type Meeting struct {
+	Name string
+	Day  customtypes.Date
+	Prev *customtypes.Date
+	At   customtypes.Time
+	When customtypes.DateTime
+	Days []customtypes.Date
+}
+
+//proteus:generate
+func Plan(m *Meeting) *Meeting {
+	return m
+}
+`
+
+const civilUse = `package gofast
+
+import (
+	"time"
+
+	"gitlab.com/ThatTomPerson/proteus/customtypes"
+)
+
+func roundTrip() (*Meeting, error) {
+	now := time.Now()
+	day := customtypes.DateOf(now)
+	m := &Meeting{
+		Day:  day,
+		Prev: &day,
+		At:   customtypes.TimeOf(now),
+		When: customtypes.DateTimeOf(now),
+		Days: []customtypes.Date{day},
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded Meeting
+	return &decoded, decoded.Unmarshal(data)
+}
+`
+
+func TestGofastGenerateCivilFields(t *testing.T) {
+	generateAndBuild(t, civilFile, proteus.Options{}, civilUse)
+}
+
+const uuidFile = `package gofast
+
+import "gitlab.com/ThatTomPerson/proteus/customtypes"
+
+//proteus:generate
+type Account struct {
+	ID      customtypes.UUID
+	Parent  *customtypes.UUID
+	Members []customtypes.UUID
+}
+
+//proteus:generate
+func Find(id customtypes.UUID) *Account {
+	return &Account{ID: id}
+}
+`
+
+const uuidUse = `package gofast
+
+import "gitlab.com/ThatTomPerson/proteus/customtypes"
+
+func roundTrip(id customtypes.UUID) (*Account, error) {
+	data, err := (&Account{ID: id, Parent: &id, Members: []customtypes.UUID{id}}).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded Account
+	return &decoded, decoded.Unmarshal(data)
+}
+`
+
+func TestGofastGenerateUUIDFields(t *testing.T) {
+	generateAndBuild(t, uuidFile, proteus.Options{}, uuidUse)
+}
+
+const nullFile = `package gofast
+
+import "gitlab.com/ThatTomPerson/proteus/customtypes"
+
+//proteus:generate
+type Row struct {
+	Name    customtypes.NullString
+	Count   customtypes.NullInt64
+	Small   customtypes.NullInt32
+	Tiny    customtypes.NullInt16
+	Flags   customtypes.NullByte
+	Ratio   customtypes.NullFloat64
+	Enabled customtypes.NullBool
+	Deleted customtypes.NullTime
+	Parent  *customtypes.NullString
+	Tags    []customtypes.NullString
+}
+
+//proteus:generate
+func Save(r *Row) *Row {
+	return r
+}
+`
+
+const nullUse = `package gofast
+
+import (
+	"database/sql"
+
+	"gitlab.com/ThatTomPerson/proteus/customtypes"
+)
+
+func roundTrip(name sql.NullString) (sql.NullString, error) {
+	data, err := (&Row{Name: customtypes.NullString(name)}).Marshal()
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	var decoded Row
+	err = decoded.Unmarshal(data)
+	return sql.NullString(decoded.Name), err
+}
+`
+
+func TestGofastGenerateNullFields(t *testing.T) {
+	generateAndBuild(t, nullFile, proteus.Options{}, nullUse)
+}
+
+const bigFile = `package gofast
+
+import "gitlab.com/ThatTomPerson/proteus/customtypes"
+
+//proteus:generate
+type Invoice struct {
+	Total   customtypes.BigInt
+	Rate    customtypes.BigFloat
+	Credit  *customtypes.BigInt
+	Amounts []customtypes.BigFloat
+}
+
+//proteus:generate
+func Pay(i *Invoice) *Invoice {
+	return i
+}
+`
+
+const bigUse = `package gofast
+
+import (
+	"math/big"
+
+	"gitlab.com/ThatTomPerson/proteus/customtypes"
+)
+
+func roundTrip(credit *big.Int) (*big.Int, error) {
+	data, err := (&Invoice{Credit: (*customtypes.BigInt)(credit)}).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded Invoice
+	err = decoded.Unmarshal(data)
+	return decoded.Credit.Int(), err
+}
+`
+
+func TestGofastGenerateBigFields(t *testing.T) {
+	generateAndBuild(t, bigFile, proteus.Options{}, bigUse)
+}
+
+const arraysFile = `package gofast
+
+type Hash [32]byte
+
+type Point [3]float64
+
+type Counts [4]int32
+
+//proteus:generate
+type Block struct {
+	Hash    Hash
+	Parent  *Hash
+	Origin  Point
+	Counts  Counts
+	Uncles  []Hash
+}
+
+//proteus:generate
+func Lookup(h Hash) *Block {
+	return &Block{Hash: h}
+}
+`
+
+const arraysUse = `package gofast
+
+func roundTrip(b *Block) (*Block, error) {
+	data, err := b.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded Block
+	return &decoded, decoded.Unmarshal(data)
+}
+`
+
+func TestGofastGenerateArrayFields(t *testing.T) {
+	generateAndBuild(t, arraysFile, proteus.Options{}, arraysUse)
+}
+
+const lazyFile = `package gofast
+
+import "gitlab.com/ThatTomPerson/proteus/lazy"
+
+type Report struct {
+	Lines []string
+	Total int64
+}
+
+type LazyReport lazy.Message[Report]
+
+//proteus:generate
+type Summary struct {
+	Total   int64
+	Report  *LazyReport
+	Last    LazyReport
+	History []LazyReport
+}
+
+//proteus:generate
+func Summarize(total int64) *Summary {
+	return &Summary{Total: total}
+}
+
+//proteus:generate
+func LastReport(s *Summary) *LazyReport {
+	return &s.Last
+}
+`
+
+const lazyUse = `package gofast
+
+func lines(s *Summary) ([]string, error) {
+	data, err := s.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded Summary
+	if err := decoded.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	report, err := decoded.Report.Get()
+	if err != nil || report == nil {
+		return nil, err
+	}
+
+	if err := decoded.Last.Set(report); err != nil {
+		return nil, err
+	}
+	return report.Lines, nil
+}
+`
+
+func TestGofastGenerateLazyFields(t *testing.T) {
+	generateAndBuild(t, lazyFile, proteus.Options{}, lazyUse)
+	generateAndTest(t, lazyFile, proteus.Options{})
+}
+
+const poolsFile = `package gofast
+
+//proteus:generate
+type User struct {
+	Name string
+}
+
+//proteus:generate
+func GetUser(name string) User {
+	return User{Name: name}
+}
+
+//proteus:generate
+func Rename(user *User, name string) (*User, bool) {
+	return &User{Name: name}, true
+}
+`
+
+const poolsUse = `package gofast
+
+func reuse() {
+	u := UserPool.Get()
+	u.Name = "foo"
+	UserPool.Put(u)
+	RenameResponsePool.Put(RenameResponsePool.Get())
+}
+`
+
+func TestGofastGeneratePools(t *testing.T) {
+	generateAndBuild(t, poolsFile, proteus.Options{Pools: true}, poolsUse)
+}
+
+const timesFile = `package gofast
+
+import (
+	"fmt"
+	"time"
+)
+
+//proteus:generate
+type MyTime struct {
+	Time time.Time
+	Name string
+}
+
+//proteus:generate
+type MyDuration struct {
+	Duration time.Duration
+	Name     string
+}
+
+//proteus:generate
+func GetAlphaTime() MyTime {
+	return MyTime{Time: time.Unix(0, 0), Name: "alpha"}
+}
+
+//proteus:generate
+func GetOmegaTime() (*MyTime, error) {
+	return &MyTime{Time: time.Unix(1355308200, 0), Name: "omega"}, nil
+}
+
+//proteus:generate
+func GetDurationForLength(meters int64) *MyDuration {
+	return &MyDuration{
+		Duration: time.Second * time.Duration(meters/299792458),
+		Name:     fmt.Sprintf("The light takes this duration to travel %dm", meters),
+	}
+}
+
+//proteus:generate
+func RandomNumber(mean, std float64) float64 {
+	return 4*std + mean
+}
+`
+
+// The benchmarks call the generated methods directly, without gRPC, so they
+// only measure the allocations of the adapters and the funcs they call. The
+// results are kept in sink so they escape like they do in gRPC.
+const timesBench = `package gofast
+
+import (
+	"context"
+	"testing"
+)
+
+var sink interface{}
+
+func BenchmarkGetAlphaTime(b *testing.B) {
+	s := NewGofastServiceServer()
+	in := new(GetAlphaTimeRequest)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		result, err := s.GetAlphaTime(context.Background(), in)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink = result
+	}
+}
+
+func BenchmarkGetOmegaTime(b *testing.B) {
+	s := NewGofastServiceServer()
+	in := new(GetOmegaTimeRequest)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		result, err := s.GetOmegaTime(context.Background(), in)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink = result
+	}
+}
+
+func BenchmarkGetDurationForLength(b *testing.B) {
+	s := NewGofastServiceServer()
+	in := &GetDurationForLengthRequest{Arg1: 299792458}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		result, err := s.GetDurationForLength(context.Background(), in)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink = result
+	}
+}
+
+func BenchmarkRandomNumber(b *testing.B) {
+	s := NewGofastServiceServer()
+	in := &RandomNumberRequest{Arg1: 1, Arg2: 2}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		result, err := s.RandomNumber(context.Background(), in)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink = result
+	}
+}
+`
+
+func TestGofastBenchmarkServer(t *testing.T) {
+	generateAndBench(t, timesFile, proteus.Options{}, timesBench)
+}
+
+const clientsUse = `package gofast
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func dial(target string) (GofastServiceClient, error) {
+	config := DefaultGRPCClientConfig()
+	config.Balancer = "pick_first"
+	conn, err := NewGRPCClientConn("dns:///"+target, config, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return NewGofastServiceClient(conn), nil
+}
+
+func dialPool(target string) (GofastServiceClient, func() error, error) {
+	pool, err := NewGRPCClientPool(target, 4, DefaultGRPCClientConfig(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewGofastServiceClientPool(pool), pool.Close, nil
+}
+`
+
+func TestGofastGenerateClients(t *testing.T) {
+	generateAndBuild(t, poolsFile, proteus.Options{Clients: true, Tracing: true, ErrorStatus: true}, clientsUse)
+}
+
+const streamsFile = `package gofast
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+//proteus:generate
+type Event struct {
+	ID   int64
+	Name string
+}
+
+//proteus:generate
+//proteus:stream-buffer 16 drop
+func Watch(ctx context.Context, n int64) <-chan *Event {
+	events := make(chan *Event)
+	go func() {
+		defer close(events)
+		for i := int64(0); i < n; i++ {
+			select {
+			case events <- &Event{ID: i}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+//proteus:generate
+//proteus:stream-buffer 4
+func ListEvents(name string) iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		yield(Event{Name: name})
+	}
+}
+
+//proteus:generate
+func ReadEvents(from int64) (iter.Seq2[*Event, error], error) {
+	if from < 0 {
+		return nil, errors.New("invalid offset")
+	}
+	return func(yield func(*Event, error) bool) {
+		yield(&Event{ID: from}, nil)
+	}, nil
+}
+
+//proteus:generate
+func GetEvent(id int64) *Event {
+	return &Event{ID: id}
+}
+`
+
+const streamsUse = `package gofast
+
+import (
+	"context"
+	"io"
+
+	"gitlab.com/ThatTomPerson/proteus/connpool"
+)
+
+func init() {
+	gofastServiceServerListEventsBuffer.Size = 8
+}
+
+func watch(ctx context.Context, pool *connpool.Pool) ([]int64, error) {
+	stream, err := NewGofastServiceClientPool(pool).Watch(ctx, &WatchRequest{Arg1: 10})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return ids, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ids = append(ids, event.ID)
+	}
+}
+`
+
+func TestGofastGenerateStreams(t *testing.T) {
+	generateAndBuild(t, streamsFile, proteus.Options{Clients: true}, streamsUse)
+}
+
+const hedgingFile = `package gofast
+
+//proteus:generate
+type User struct {
+	Name string
+}
+
+//proteus:generate
+//proteus:idempotent
+func GetUser(name string) User {
+	return User{Name: name}
+}
+
+//proteus:generate
+func CreateUser(name string) User {
+	return User{Name: name}
+}
+`
+
+const hedgingUse = `package gofast
+
+import (
+	"time"
+
+	"gitlab.com/ThatTomPerson/proteus/hedging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func dial(target string) (*grpc.ClientConn, error) {
+	config := DefaultGRPCClientConfig()
+	config.Hedging = hedging.Policy{
+		MaxAttempts:   3,
+		Delay:         50 * time.Millisecond,
+		NonFatalCodes: []codes.Code{codes.Unavailable},
+	}
+	return NewGRPCClientConn(target, config, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+`
+
+func TestGofastGenerateHedgedClients(t *testing.T) {
+	generateAndBuild(t, hedgingFile, proteus.Options{Clients: true}, hedgingUse)
+}
+
+const propertyFile = `package gofast
+
+//proteus:generate
+//proteus:enum-semantics open
+type Kind int
+
+const (
+	Unknown Kind = iota
+	Small
+	Big
+)
+
+//proteus:generate
+type Tags []string
+
+//proteus:generate
+type Hash [4]byte
+
+//proteus:generate
+type User struct {
+	Name    string
+	Age     int
+	Score   float64
+	Kind    Kind
+	Avatar  []byte
+	Tags    Tags
+	Hash    Hash
+	Friends []*User
+	Best    *User
+	Groups  map[string]Group
+	Counts  map[Kind]uint32
+}
+
+//proteus:generate
+type Group struct {
+	Name  string
+	Admin *User
+}
+
+//proteus:generate
+func Greet(u *User) *Group {
+	return &Group{Name: u.Name, Admin: u}
+}
+`
+
+func TestGofastGeneratePropertyTests(t *testing.T) {
+	generateAndTest(t, propertyFile, proteus.Options{})
+}
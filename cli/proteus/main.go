@@ -1,23 +1,85 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"go/build"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"gitlab.com/ThatTomPerson/proteus"
+	"gitlab.com/ThatTomPerson/proteus/apidoc"
+	"gitlab.com/ThatTomPerson/proteus/manifest"
+	"gitlab.com/ThatTomPerson/proteus/output"
 	"gitlab.com/ThatTomPerson/proteus/protobuf"
 	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/rpc"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+	"gitlab.com/ThatTomPerson/proteus/snapshot"
 
 	"gopkg.in/urfave/cli.v1"
 )
 
 var (
-	packages cli.StringSlice
-	path     string
-	verbose  bool
+	packages         cli.StringSlice
+	path             string
+	verbose          bool
+	diagnostics      string
+	strict           bool
+	dryRun           bool
+	scanCacheDir     string
+	runCache         *scanner.Cache
+	checkBreaking    bool
+	fieldPolicy      string
+	importPaths      cli.StringSlice
+	messageFiles     cli.StringSlice
+	packageNames     cli.StringSlice
+	fileOptions      cli.StringSlice
+	breakPolicy      cli.StringSlice
+	fileLayout       string
+	pkgTemplate      string
+	splitFiles       bool
+	mergePackage     string
+	descriptorSetOut string
+	only             cli.StringSlice
+	runScope         *proteus.Scope
+	genBazel         bool
+	genBuf           bool
+	genOpenAPI       bool
+	docsFormat       string
+	schemaHashes     bool
+	unitHelpers      bool
+	tracing          bool
+	errorStatus      bool
+	logging          bool
+	pools            bool
+	clients          bool
+	examples         bool
+	protobufAPI      string
+	unspecified      bool
+	enumConstValues  bool
+	optionalFields   bool
+	boolSets         bool
+	inlineTypes      bool
+	enumNaming       string
+	enumSemantics    string
+	fieldNaming      string
+	jsonCasing       string
+	acronyms         cli.StringSlice
+	profilePath      string
+	fieldProfile     protobuf.FieldProfile
+	rulesPath        string
+	runRules         protobuf.Rules
+	manifestPath     string
+	cleanOrphans     bool
+	pruneStale       bool
+	runManifest      *manifest.Manifest
+	tracePath        string
+	runTrace         *protobuf.Trace
 )
 
 func main() {
@@ -37,6 +99,12 @@ func main() {
 			Usage:       "Print all warnings and info messages.",
 			Destination: &verbose,
 		},
+		cli.StringFlag{
+			Name:        "diagnostics",
+			Usage:       "Write the warnings and errors in `FORMAT`, which can be text or json. With json, every one of them is written as a JSON record in a line of its own, with its severity, code, position and symbol, for CI bots and editors to show them inline.",
+			Value:       string(report.TextFormat),
+			Destination: &diagnostics,
+		},
 	}
 
 	folderFlag := cli.StringFlag{
@@ -45,45 +113,688 @@ func main() {
 		Destination: &path,
 	}
 
-	app.Flags = append(baseFlags, folderFlag)
+	strictFlag := cli.BoolFlag{
+		Name:        "strict",
+		Usage:       "Fail, listing all of them, if any type, field, func or RPC has to be skipped because it can not be generated, instead of only warning about them.",
+		Destination: &strict,
+	}
+
+	dryRunFlag := cli.BoolFlag{
+		Name:        "dry-run",
+		Usage:       "Do not write the generated files, but print the unified diff between them and the files on disk, failing if any of them is not up to date.",
+		Destination: &dryRun,
+	}
+
+	scanCacheFlag := cli.StringFlag{
+		Name:        "scan-cache",
+		Usage:       "Persist the scanned Go packages in the given `DIR`, keyed by a hash of their Go files and the options, so later runs do not scan the unchanged packages again.",
+		Destination: &scanCacheDir,
+	}
+
+	checkBreakingFlag := cli.BoolFlag{
+		Name:        "check-breaking",
+		Usage:       "Fail if the generated .proto files have breaking changes with the ones already in the folder.",
+		Destination: &checkBreaking,
+	}
+
+	breakingPolicyFlag := cli.StringSliceFlag{
+		Name:  "breaking-policy",
+		Usage: "Take the action `CATEGORY=ACTION` with the breaking changes of a category, which is wire, json or source, e.g. json=warn if only the binary encoding is used. Actions are error, warn and ignore, and changes fail by default. You can use this flag multiple times.",
+		Value: &breakPolicy,
+	}
+
+	fieldPolicyFlag := cli.StringFlag{
+		Name:        "field-policy",
+		Usage:       "Use `POLICY` for struct fields of channel or func types: skip, error or placeholder (skips them reserving their position).",
+		Value:       string(scanner.SkipField),
+		Destination: &fieldPolicy,
+	}
+
+	importPathFlag := cli.StringSliceFlag{
+		Name:  "import-path",
+		Usage: "Import `FROM=TO` files from the path TO instead of FROM in the generated .proto files. Directories, ending with a slash, are also allowed. You can use this flag multiple times.",
+		Value: &importPaths,
+	}
+
+	messageFileFlag := cli.StringSliceFlag{
+		Name:  "message-file",
+		Usage: "Reference the types of a Go package from a .proto file published elsewhere with `GOPKG=FILE[:PACKAGE]`, the file and its protobuf package, instead of its generated .proto file. Types implementing proto.Message in packages that are not scanned need it. You can use this flag multiple times.",
+		Value: &messageFiles,
+	}
+
+	packageNameFlag := cli.StringSliceFlag{
+		Name:  "package-name",
+		Usage: "Generate the Go package GOPKG with the protobuf package `GOPKG=PACKAGE` instead of the one given by the package template. A //proteus:package comment in the docs of the package overrides it. You can use this flag multiple times.",
+		Value: &packageNames,
+	}
+
+	fileOptionFlag := cli.StringSliceFlag{
+		Name:  "file-option",
+		Usage: "Write the option `[GOPKG:]NAME=VALUE` to the .proto file of the Go package GOPKG, or of all of them if it is not given, e.g. java_package=com.acme.{name}. Values can have the placeholders of --file-layout. You can use this flag multiple times.",
+		Value: &fileOptions,
+	}
+
+	fileLayoutFlag := cli.StringFlag{
+		Name:        "file-layout",
+		Usage:       "Write the .proto file of every package to `LAYOUT` in the folder, and import it from there, where {path} is the path of the Go package, {name} its last element, {org} and {repo} its second and third elements, {module} its first three elements and {package} the protobuf package with slashes, e.g. {module}/{name}/v1/{name}.proto.",
+		Value:       string(protobuf.DefaultFileLayout),
+		Destination: &fileLayout,
+	}
+
+	pkgTemplateFlag := cli.StringFlag{
+		Name:        "package-template",
+		Usage:       "Name the protobuf package of every package after `TEMPLATE`, with the placeholders of --file-layout but {package}, e.g. {org}.{name}.v1. By default, it is the path of the Go package with dots.",
+		Destination: &pkgTemplate,
+	}
+
+	splitFilesFlag := cli.BoolFlag{
+		Name:        "split-files",
+		Usage:       "Write the messages and enums of every Go source file to a .proto file of its own, e.g. user.proto for user.go, next to the file of the package, which keeps the services.",
+		Destination: &splitFiles,
+	}
+
+	descriptorSetFlag := cli.StringFlag{
+		Name:        "descriptor-set-out",
+		Usage:       "Also write the binary FileDescriptorSet of the generated .proto files of all the packages, including the files they import, to `FILE`, like protoc --descriptor_set_out --include_imports, for tools such as grpcurl or buf.",
+		Destination: &descriptorSetOut,
+	}
+
+	onlyFlag := cli.StringSliceFlag{
+		Name:  "only",
+		Usage: "Only generate the given package or type, e.g. github.com/acme/app/user.User, and the packages of the types and services that depend on it, leaving the outputs of the rest as they are. You can use this flag multiple times.",
+		Value: &only,
+	}
+
+	mergePackageFlag := cli.StringFlag{
+		Name:        "merge-package",
+		Usage:       "Also write a .proto file with the given protobuf package and the messages, enums and services of all the packages, prefixing the names used in more than one of them.",
+		Destination: &mergePackage,
+	}
+
+	bazelFlag := cli.BoolFlag{
+		Name:        "bazel",
+		Usage:       "Generate a BUILD.bazel file with proto_library and go_proto_library targets next to every .proto file.",
+		Destination: &genBazel,
+	}
+
+	bufFlag := cli.BoolFlag{
+		Name:        "buf",
+		Usage:       "Generate the buf.yaml and buf.gen.yaml files of a buf module in the folder, and follow the conventions of buf lint unless the flags are given: --package-template {path}.v1, --file-layout {package}/{name}.proto, --enum-naming prefix and --enum-unspecified.",
+		Destination: &genBuf,
+	}
+
+	openAPIFlag := cli.BoolFlag{
+		Name:        "openapi",
+		Usage:       "Generate an openapi.json file in the folder with the OpenAPI v3 document of the RPCs with an HTTP rule and the messages and enums of all the packages.",
+		Destination: &genOpenAPI,
+	}
+
+	docsFlag := cli.StringFlag{
+		Name:        "docs",
+		Usage:       "Write the reference documentation of the services, messages and enums of all the packages, with their docs, to the folder in `FORMAT`: markdown (API.md) or html (api.html).",
+		Destination: &docsFormat,
+	}
+
+	schemaHashesFlag := cli.BoolFlag{
+		Name:        "schema-hashes",
+		Usage:       "Generate a proteus_schema.go file in every package with a constant with the schema hash of every message, like UserSchemaHash, which changes whenever the fields of the message or of the messages and enums it has change, to invalidate the serialized messages stored with another schema.",
+		Destination: &schemaHashes,
+	}
+
+	unitHelpersFlag := cli.BoolFlag{
+		Name:        "unit-helpers",
+		Usage:       "Generate a proteus_units.go file in every package with methods converting the fields with a time unit, like proteus:\"unit=ms\", from and to time.Duration, like TimeoutDuration and SetTimeoutDuration, and the fields with a size unit, like KiB, from and to bytes.",
+		Destination: &unitHelpers,
+	}
+
+	tracingFlag := cli.BoolFlag{
+		Name:        "tracing",
+		Usage:       "Add the interceptors of the tracing package to the generated gRPC server, so it propagates the W3C trace context and the request IDs of the calls.",
+		Destination: &tracing,
+	}
+
+	errorStatusFlag := cli.BoolFlag{
+		Name:        "error-status",
+		Usage:       "Add the interceptors of the errstatus package to the generated gRPC server, so the errors of the calls are sent with the code and the ErrorInfo and BadRequest details of their domain errors.",
+		Destination: &errorStatus,
+	}
+
+	loggingFlag := cli.BoolFlag{
+		Name:        "logging",
+		Usage:       "Generate a NewGRPCLoggingInterceptor func returning an interceptor of the logging package that logs a sample of the calls, with the method rates and the logger given, with the fields tagged as proteus:\"sensitive\" redacted.",
+		Destination: &logging,
+	}
+
+	poolsFlag := cli.BoolFlag{
+		Name:        "pools",
+		Usage:       "Declare a pool of the pool package for every message of the packages with services, like UserPool, with Get and Put methods, which the generated gRPC server takes its responses from and puts them back in, reset, once their calls have ended.",
+		Destination: &pools,
+	}
+
+	clientsFlag := cli.BoolFlag{
+		Name:        "clients",
+		Usage:       "Generate a NewGRPCClientConn func in the packages with services, which dials the target with the load balancing policy, resolvers, keepalive parameters and maximum message sizes of a GRPCClientConfig, starting from DefaultGRPCClientConfig, and a NewGRPCClientPool func with a client of every service sending its calls to a pool of them.",
+		Destination: &clients,
+	}
+
+	examplesFlag := cli.BoolFlag{
+		Name:        "examples",
+		Usage:       "Generate a proteus_example_test.go file in every package with an example per service calling all its RPCs with the generated client, so go doc shows how to use them.",
+		Destination: &examples,
+	}
+
+	protobufAPIFlag := cli.StringFlag{
+		Name:        "protobuf-api",
+		Usage:       "Register the generated gRPC servers of the packages without //proteus:protobuf-api with the code generated for `API`: gogo (gogo/protobuf, with the Go types of the package) or apiv2 (protoc-gen-go and protoc-gen-go-grpc in the pb folder of the package, converting their messages to the gogo ones).",
+		Value:       string(rpc.GoGo),
+		Destination: &protobufAPI,
+	}
+
+	unspecifiedFlag := cli.BoolFlag{
+		Name:        "enum-unspecified",
+		Usage:       "Add a {ENUM}_UNSPECIFIED value with the number 0 to the enums that do not have a zero value, which proto3 requires.",
+		Destination: &unspecified,
+	}
+
+	enumConstValuesFlag := cli.BoolFlag{
+		Name:        "enum-const-values",
+		Usage:       "Number the enum values with the values of their consts instead of the order they are declared in. It changes the numbers of the enums whose consts do not start at 0 and increase by 1.",
+		Destination: &enumConstValues,
+	}
+
+	optionalFlag := cli.BoolFlag{
+		Name:        "optional",
+		Usage:       "Generate the pointers to basic types and enums as proto3 optional fields. They are not supported by protoc-gen-gofast, so the Go code of the files can not be generated by proteus.",
+		Destination: &optionalFields,
+	}
+
+	boolSetsFlag := cli.BoolFlag{
+		Name:        "bool-sets",
+		Usage:       "Generate maps of bool values in the params and results of funcs as sets, that is, repeated fields of their keys, like maps of empty struct values.",
+		Destination: &boolSets,
+	}
+
+	inlineTypesFlag := cli.BoolFlag{
+		Name:        "inline-types",
+		Usage:       "Generate the struct types written inline in the signatures of the funcs, named after the func and the param or result, and the instantiations of generic struct types, like PageUser for Page[User], as messages. Package-level vars initialized with funcs are also generated like funcs.",
+		Destination: &inlineTypes,
+	}
+
+	enumNamingFlag := cli.StringFlag{
+		Name:        "enum-naming",
+		Usage:       "Name enum values with `NAMING`: snake (COLOR_RED for ColorRed), prefix (prepends the enum name), strip (removes the prefix all values have) or verbatim (the Go name).",
+		Value:       string(protobuf.SnakeCaseNaming),
+		Destination: &enumNaming,
+	}
+
+	enumSemanticsFlag := cli.StringFlag{
+		Name:        "enum-semantics",
+		Usage:       "Give `SEMANTICS` to the enums without //proteus:enum-semantics: closed (fields only accept the enum values, validated with protoc-gen-validate) or open (unknown values are kept). Funcs converting the enums with semantics from and to their protobuf values are generated in proteus_enums.go.",
+		Destination: &enumSemantics,
+	}
+
+	fieldNamingFlag := cli.StringFlag{
+		Name:        "field-naming",
+		Usage:       "Name message fields with `NAMING`: snake (user_id for UserID), keep (the Go name) or json (the name in the json tag, snake if it has none).",
+		Value:       string(protobuf.SnakeCaseFieldNaming),
+		Destination: &fieldNaming,
+	}
+
+	jsonCasingFlag := cli.StringFlag{
+		Name:        "json-casing",
+		Usage:       "Name message fields in JSON with `CASING`: camel (userId for user_id, the protobuf default), snake (user_id) or go (the Go name). Names in json tags are always kept.",
+		Value:       string(protobuf.CamelCaseJSON),
+		Destination: &jsonCasing,
+	}
+
+	acronymFlag := cli.StringSliceFlag{
+		Name:  "acronym",
+		Usage: "Keep `ACRONYM` as a single word when converting names to snake case, e.g. GRPC makes GRPCServer grpc_server. Common acronyms such as ID, HTTP or URL are already known. You can use this flag multiple times.",
+		Value: &acronyms,
+	}
+
+	profileFlag := cli.StringFlag{
+		Name:        "field-profile",
+		Usage:       "Number the fields of new messages following the JSON profile in `FILE`, which holds how often each field is set, so the small and frequent ones take the numbers 1 to 15. Messages keep their numbers once generated.",
+		Destination: &profilePath,
+	}
+
+	rulesFlag := cli.StringFlag{
+		Name:        "rules",
+		Usage:       "Apply the transformation rules in the JSON `FILE`, which set options, rename, retype or skip the messages, enums and fields that match their CEL expressions.",
+		Destination: &rulesPath,
+	}
+
+	traceFlag := cli.StringFlag{
+		Name:        "trace",
+		Usage:       "Experimental: write to `FILE` a JSON trace of why every field got its type, name, number and options.",
+		Destination: &tracePath,
+	}
+
+	toolFlags := []cli.Flag{
+		cli.BoolFlag{
+			Name:        "no-protoc",
+			Usage:       "Generate the Go files and descriptor sets in-process from the generated .proto files, like protoc-gen-gofast, without protoc or any plugin. Only the standard options and the ones of gogoproto are supported, and comments are not kept.",
+			Destination: &noProtoc,
+		},
+		cli.BoolFlag{
+			Name:        "hermetic",
+			Usage:       "Never look up external binaries on the PATH. The paths of protoc and protoc-gen-gofast must be given.",
+			Destination: &hermetic,
+		},
+		cli.StringFlag{
+			Name:        "protoc",
+			Usage:       "Use the protoc binary at the absolute `PATH` instead of the one on the PATH.",
+			Destination: &protocBin,
+		},
+		cli.StringFlag{
+			Name:        "protoc-version",
+			Usage:       "Fail if the version of protoc is not `VERSION`, e.g. 3.5.1.",
+			Destination: &protocVersion,
+		},
+		cli.StringFlag{
+			Name:        "protoc-gen-gofast",
+			Usage:       "Use the protoc-gen-gofast plugin at the absolute `PATH` instead of the one on the PATH.",
+			Destination: &gofastBin,
+		},
+	}
+
+	compileFlags := []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "protoc-out",
+			Usage: "Compile the generated .proto files with protoc and the output `NAME_out=[PARAMS:]DIR`, e.g. go_out=paths=source_relative:., or give the parameters of an output with NAME_opt=PARAMS. You can use this flag multiple times.",
+			Value: &protocOuts,
+		},
+		cli.StringSliceFlag{
+			Name:  "protoc-plugin",
+			Usage: "Use the protoc plugin protoc-gen-NAME at the absolute path given with `NAME=PATH` instead of the one on the PATH. You can use this flag multiple times.",
+			Value: &protocPlugins,
+		},
+	}
+
+	manifestFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:        "manifest",
+			Usage:       "Write a JSON manifest of all the files produced, with their hashes and source packages, to `FILE`. Files of the previous manifest that are not produced anymore are reported.",
+			Destination: &manifestPath,
+		},
+		cli.BoolFlag{
+			Name:        "clean",
+			Usage:       "Remove the files of the previous manifest that are not produced anymore.",
+			Destination: &cleanOrphans,
+		},
+		cli.BoolFlag{
+			Name:        "prune",
+			Usage:       "Remove the files of the previous manifest whose source packages or types do not exist anymore, keeping track of the rest.",
+			Destination: &pruneStale,
+		},
+	}
+
+	app.Flags = append(baseFlags, folderFlag, strictFlag, scanCacheFlag, checkBreakingFlag, breakingPolicyFlag, fieldPolicyFlag, unspecifiedFlag, enumConstValuesFlag, boolSetsFlag, inlineTypesFlag, enumNamingFlag, enumSemanticsFlag, fieldNamingFlag, jsonCasingFlag, acronymFlag, profileFlag, rulesFlag, traceFlag, importPathFlag, messageFileFlag, fileLayoutFlag, pkgTemplateFlag, packageNameFlag, fileOptionFlag, splitFilesFlag, mergePackageFlag, bazelFlag, bufFlag, openAPIFlag, docsFlag, schemaHashesFlag, unitHelpersFlag, descriptorSetFlag, onlyFlag)
+	app.Flags = append(app.Flags, toolFlags...)
+	app.Flags = append(app.Flags, manifestFlags...)
 	app.Commands = []cli.Command{
 		{
 			Name:        "proto",
 			Description: "Generates .proto files from your Go source code.",
 			Usage:       "Generates .proto files from Go packages",
 			Action:      initCmd(genProtos),
-			Flags:       append(baseFlags, folderFlag),
+			Flags:       append(append(append(append(baseFlags, folderFlag, strictFlag, scanCacheFlag, dryRunFlag, checkBreakingFlag, breakingPolicyFlag, fieldPolicyFlag, unspecifiedFlag, enumConstValuesFlag, optionalFlag, boolSetsFlag, inlineTypesFlag, enumNamingFlag, enumSemanticsFlag, fieldNamingFlag, jsonCasingFlag, acronymFlag, profileFlag, rulesFlag, traceFlag, importPathFlag, messageFileFlag, fileLayoutFlag, pkgTemplateFlag, packageNameFlag, fileOptionFlag, splitFilesFlag, mergePackageFlag, bazelFlag, bufFlag, openAPIFlag, docsFlag, schemaHashesFlag, unitHelpersFlag, descriptorSetFlag, onlyFlag), manifestFlags...), toolFlags...), compileFlags...),
+		},
+		{
+			Name:        "verify",
+			Description: "Checks the .proto files that would be generated from your Go source code against the ones already generated and reports breaking changes.",
+			Usage:       "Reports breaking changes with the generated .proto files",
+			Action:      initCmd(verify),
+			Flags:       append(baseFlags, folderFlag, strictFlag, scanCacheFlag, breakingPolicyFlag, fieldPolicyFlag, unspecifiedFlag, enumConstValuesFlag, optionalFlag, boolSetsFlag, inlineTypesFlag, enumNamingFlag, enumSemanticsFlag, fieldNamingFlag, jsonCasingFlag, acronymFlag, profileFlag, rulesFlag, importPathFlag, messageFileFlag, fileLayoutFlag, pkgTemplateFlag, packageNameFlag, fileOptionFlag, splitFilesFlag),
+		},
+		{
+			Name:        "watch",
+			Description: "Generates .proto files from your Go source code and regenerates the ones affected by every change of the Go files until it is interrupted, scanning again only the changed packages and the packages importing them.",
+			Usage:       "Regenerates .proto files on every change of the Go packages",
+			Action:      initCmd(watch),
+			Flags:       append(baseFlags, folderFlag, strictFlag, scanCacheFlag, fieldPolicyFlag, unspecifiedFlag, enumConstValuesFlag, optionalFlag, boolSetsFlag, inlineTypesFlag, enumNamingFlag, enumSemanticsFlag, fieldNamingFlag, jsonCasingFlag, acronymFlag, profileFlag, rulesFlag, importPathFlag, messageFileFlag, fileLayoutFlag, pkgTemplateFlag, packageNameFlag, fileOptionFlag, splitFilesFlag, mergePackageFlag, bazelFlag, bufFlag, openAPIFlag, docsFlag, schemaHashesFlag, unitHelpersFlag),
 		},
 		{
 			Name:        "rpc",
 			Description: "Generates the gRPC implementation of the gRPC server interface defined by your Go source code.",
 			Usage:       "Generates gRPC server implementation",
 			Action:      initCmd(genRPCServer),
+			Flags:       append(append(baseFlags, strictFlag, scanCacheFlag, dryRunFlag, fieldPolicyFlag, unspecifiedFlag, enumConstValuesFlag, optionalFlag, boolSetsFlag, inlineTypesFlag, enumNamingFlag, enumSemanticsFlag, fieldNamingFlag, jsonCasingFlag, acronymFlag, profileFlag, rulesFlag, importPathFlag, messageFileFlag, fileLayoutFlag, pkgTemplateFlag, packageNameFlag, fileOptionFlag, mergePackageFlag, bufFlag, tracingFlag, errorStatusFlag, loggingFlag, poolsFlag, clientsFlag, examplesFlag, protobufAPIFlag, onlyFlag), manifestFlags...),
+		},
+		{
+			Name:        "snapshot",
+			Description: "Generates tests that decode the wire-format snapshots stored in previous releases with the current messages of your Go source code.",
+			Usage:       "Generates snapshot compatibility tests",
+			Action:      initCmd(genSnapshotTests),
+			Flags:       append(append(baseFlags, strictFlag, scanCacheFlag, dryRunFlag, boolSetsFlag, inlineTypesFlag, onlyFlag), manifestFlags...),
+		},
+		{
+			Name:        "proptest",
+			Description: "Generates property-based tests that draw random values of the messages and enums of your Go source code and check that they are the same after converting them to protobuf and back.",
+			Usage:       "Generates property-based round trip tests",
+			Action:      initCmd(genPropertyTests),
+			Flags:       append(append(baseFlags, strictFlag, scanCacheFlag, dryRunFlag, optionalFlag, boolSetsFlag, inlineTypesFlag, enumSemanticsFlag, onlyFlag), manifestFlags...),
+		},
+		{
+			Name:        "harness",
+			Description: "Writes a descriptor set of the .proto files already generated and an index of the stored snapshots next to them, so programs in other languages can check they decode the snapshots like the Go messages.",
+			Usage:       "Generates a cross-language conformance harness from the snapshots",
+			Action:      initCmd(genHarness),
+			Flags:       append(append(baseFlags, folderFlag, fileLayoutFlag, pkgTemplateFlag), toolFlags...),
+		},
+		{
+			Name:        "analyze",
+			Description: "Reports the interfaces whose implementations are told apart in type switches or type assertions of your Go source code, and the oneof or enum they could be generated as.",
+			Usage:       "Reports interfaces that could be generated as oneofs or enums",
+			Action:      initCmd(analyzeInterfaces),
 			Flags:       baseFlags,
 		},
 	}
 	app.Action = initCmd(genAll)
 
 	if err := app.Run(os.Args); err != nil {
-		fmt.Println(err)
+		if diagnostics == string(report.JSONFormat) {
+			report.Error("%s", err)
+		} else {
+			fmt.Println(err)
+		}
 		os.Exit(1)
 	}
 }
 
 type action func(c *cli.Context) error
 
+// setBufDefaults sets the package template, file layout and enum naming
+// that follow the conventions of buf lint, unless they are given.
+func setBufDefaults(c *cli.Context) {
+	isSet := func(name string) bool {
+		return c.IsSet(name) || c.GlobalIsSet(name)
+	}
+
+	if !isSet("package-template") {
+		pkgTemplate = "{path}.v1"
+	}
+
+	if !isSet("file-layout") {
+		fileLayout = "{package}/{name}.proto"
+	}
+
+	if !isSet("enum-naming") {
+		enumNaming = string(protobuf.PrefixNaming)
+	}
+
+	if !isSet("enum-unspecified") {
+		unspecified = true
+	}
+}
+
 func initCmd(next action) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
+		format, err := report.ParseFormat(diagnostics)
+		if err != nil {
+			return err
+		}
+		report.SetFormat(format)
+
 		if len(packages) == 0 {
 			return errors.New("no package provided, there is nothing to generate")
 		}
 
+		if genBuf {
+			setBufDefaults(c)
+		}
+
+		if fieldPolicy != "" {
+			if _, err := scanner.ParseFieldPolicy(fieldPolicy); err != nil {
+				return err
+			}
+		}
+
+		if _, err := protobuf.ParseImportPaths(importPaths); err != nil {
+			return err
+		}
+
+		if _, err := protobuf.ParseMessageFiles(messageFiles); err != nil {
+			return err
+		}
+
+		if fileLayout != "" {
+			if _, err := protobuf.ParseFileLayout(fileLayout); err != nil {
+				return err
+			}
+		}
+
+		if _, err := protobuf.ParsePackageNames(packageNames); err != nil {
+			return err
+		}
+
+		if _, err := protobuf.ParseFileOptions(fileOptions); err != nil {
+			return err
+		}
+
+		if _, err := protobuf.ParseBreakingChangePolicy(breakPolicy); err != nil {
+			return err
+		}
+
+		if mergePackage != "" {
+			if _, err := protobuf.ParsePackageName(mergePackage); err != nil {
+				return err
+			}
+		}
+
+		if pkgTemplate != "" {
+			if _, err := protobuf.ParsePackageTemplate(pkgTemplate); err != nil {
+				return err
+			}
+		}
+
+		if enumNaming != "" {
+			if _, err := protobuf.ParseEnumNaming(enumNaming); err != nil {
+				return err
+			}
+		}
+
+		if docsFormat != "" {
+			if _, err := apidoc.ParseFormat(docsFormat); err != nil {
+				return err
+			}
+		}
+
+		if enumSemantics != "" {
+			if _, err := protobuf.ParseEnumSemantics(enumSemantics); err != nil {
+				return err
+			}
+		}
+
+		if protobufAPI != "" {
+			if _, err := rpc.ParseAPI(protobufAPI); err != nil {
+				return err
+			}
+		}
+
+		if fieldNaming != "" {
+			if _, err := protobuf.ParseFieldNaming(fieldNaming); err != nil {
+				return err
+			}
+		}
+
+		if jsonCasing != "" {
+			if _, err := protobuf.ParseJSONCasing(jsonCasing); err != nil {
+				return err
+			}
+		}
+
+		if profilePath != "" {
+			profile, err := protobuf.LoadFieldProfile(profilePath)
+			if err != nil {
+				return err
+			}
+			fieldProfile = profile
+		}
+
+		if rulesPath != "" {
+			rules, err := protobuf.LoadRules(rulesPath)
+			if err != nil {
+				return err
+			}
+			runRules = rules
+		}
+
+		if _, _, err := protocArgs(protocOuts); err != nil {
+			return err
+		}
+
+		if _, err := protocPluginPaths(protocPlugins); err != nil {
+			return err
+		}
+
+		if noProtoc && len(protocOuts) > 0 {
+			return errors.New("--protoc-out can not be used with --no-protoc, as it runs protoc")
+		}
+
+		if (cleanOrphans || pruneStale) && manifestPath == "" {
+			return errors.New("--clean and --prune require a manifest file given with --manifest")
+		}
+
+		if (cleanOrphans || pruneStale) && len(only) > 0 {
+			return errors.New("--clean and --prune can not be used with --only, as the files of the rest of the packages are not produced")
+		}
+
+		if dryRun && (cleanOrphans || pruneStale) {
+			return errors.New("--clean and --prune can not be used with --dry-run, as they remove files")
+		}
+
+		if dryRun && (len(protocOuts) > 0 || descriptorSetOut != "") {
+			return errors.New("--protoc-out and --descriptor-set-out can not be used with --dry-run, as they need the .proto files on disk")
+		}
+
+		if len(only) > 0 {
+			runScope = new(proteus.Scope)
+		}
+
 		if !verbose {
 			report.Silent()
 		}
 
-		return next(c)
+		if manifestPath != "" {
+			runManifest = manifest.New()
+		}
+
+		if tracePath != "" {
+			runTrace = protobuf.NewTrace()
+		}
+
+		if scanCacheDir != "" {
+			cache, err := scanner.NewDiskCache(scanCacheDir)
+			if err != nil {
+				return err
+			}
+			runCache = cache
+		}
+
+		if dryRun {
+			output.DryRun()
+		}
+
+		if err := next(c); err != nil {
+			return err
+		}
+
+		if runTrace != nil {
+			if err := runTrace.WriteFile(tracePath); err != nil {
+				return fmt.Errorf("error writing trace %q: %s", tracePath, err)
+			}
+		}
+
+		if runManifest != nil {
+			if err := writeManifest(); err != nil {
+				return err
+			}
+		}
+
+		if dryRun {
+			return printChanges()
+		}
+		return nil
+	}
+}
+
+// printChanges prints the unified diff of every file of the dry run that is
+// not the one on disk, and returns an error if there is any.
+func printChanges() error {
+	changes, err := output.Changes()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		fmt.Print(output.Diff(relPath(c.File), c.Old, c.New))
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("%d generated files are not up to date", len(changes))
+	}
+	return nil
+}
+
+// relPath returns the path of the file relative to the working directory,
+// if it is in it.
+func relPath(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+
+	rel, err := filepath.Rel(wd, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return file
+	}
+	return rel
+}
+
+// writeManifest writes the manifest of the run, reporting or removing the
+// files of the previous manifest that are not produced anymore. If only some
+// packages are generated, all the files of the previous manifest are kept.
+func writeManifest() error {
+	prev, err := manifest.ReadFile(manifestPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading previous manifest %q: %s", manifestPath, err)
+	}
+
+	if prev != nil && len(only) > 0 {
+		runManifest.Keep(prev, nil)
+	} else if prev != nil && pruneStale {
+		stale := runManifest.Stale(prev, packages, packageExists)
+		for _, f := range stale {
+			if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing stale file %q: %s", f.Path, err)
+			}
+			report.Info("removed stale file %s of package %s", f.Path, f.Package)
+		}
+		runManifest.Keep(prev, stale)
+	} else if prev != nil {
+		for _, f := range runManifest.Orphans(prev) {
+			if !cleanOrphans {
+				report.Warn("file %s of package %s is not generated anymore", f.Path, f.Package)
+				continue
+			}
+
+			if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing orphaned file %q: %s", f.Path, err)
+			}
+			report.Info("removed orphaned file %s of package %s", f.Path, f.Package)
+		}
 	}
+
+	return runManifest.WriteFile(manifestPath)
+}
+
+// packageExists reports whether the Go package with the given import path
+// can still be found.
+func packageExists(pkg string) bool {
+	_, err := build.Import(pkg, "", build.FindOnly)
+	return err == nil
 }
 
 func genProtos(c *cli.Context) error {
@@ -95,14 +806,183 @@ func genProtos(c *cli.Context) error {
 		return err
 	}
 
-	return proteus.GenerateProtos(proteus.Options{
-		BasePath: path,
-		Packages: packages,
-	})
+	options := protoOptions()
+
+	if checkBreaking {
+		if err := proteus.CheckBreakingChanges(options); err != nil {
+			return err
+		}
+	}
+
+	if err := proteus.GenerateProtos(options); err != nil {
+		return err
+	}
+
+	if descriptorSetOut != "" {
+		if err := writeDescriptorSetOut(); err != nil {
+			return err
+		}
+	}
+
+	if len(protocOuts) > 0 {
+		return compileProtos()
+	}
+	return nil
+}
+
+func verify(c *cli.Context) error {
+	if path == "" {
+		return errors.New("destination path cannot be empty")
+	}
+
+	if err := checkFolder(path); err != nil {
+		return err
+	}
+
+	return proteus.CheckBreakingChanges(protoOptions())
+}
+
+// protoOptions returns the options for generating .proto files from the
+// flags, which are expected to be already validated.
+func protoOptions() proteus.Options {
+	paths, _ := protobuf.ParseImportPaths(importPaths)
+	files, _ := protobuf.ParseMessageFiles(messageFiles)
+	names, _ := protobuf.ParsePackageNames(packageNames)
+	fileOpts, _ := protobuf.ParseFileOptions(fileOptions)
+	policy, _ := protobuf.ParseBreakingChangePolicy(breakPolicy)
+	return proteus.Options{
+		BasePath:        path,
+		Packages:        packages,
+		FieldPolicy:     scanner.FieldPolicy(fieldPolicy),
+		ImportPaths:     paths,
+		MessageFiles:    files,
+		FileLayout:      protobuf.FileLayout(fileLayout),
+		PackageTemplate: protobuf.PackageTemplate(pkgTemplate),
+		PackageNames:    names,
+		FileOptions:     fileOpts,
+		SplitFiles:      splitFiles,
+		MergePackage:    mergePackage,
+		BreakingPolicy:  policy,
+		Bazel:           genBazel,
+		Buf:             genBuf,
+		OpenAPI:         genOpenAPI,
+		Docs:            apidoc.Format(docsFormat),
+		SchemaHashes:    schemaHashes,
+		UnitHelpers:     unitHelpers,
+		Unspecified:     unspecified,
+		EnumConstValues: enumConstValues,
+		OptionalFields:  optionalFields,
+		BoolSets:        boolSets,
+		InlineTypes:     inlineTypes,
+		EnumNaming:      protobuf.EnumNaming(enumNaming),
+		EnumSemantics:   protobuf.EnumSemantics(enumSemantics),
+		FieldNaming:     protobuf.FieldNaming(fieldNaming),
+		JSONCasing:      protobuf.JSONCasing(jsonCasing),
+		Acronyms:        acronyms,
+		Profile:         fieldProfile,
+		Rules:           runRules,
+		Only:            only,
+		Scope:           runScope,
+		Manifest:        runManifest,
+		Trace:           runTrace,
+		Strict:          strict,
+		Cache:           runCache,
+	}
 }
 
 func genRPCServer(c *cli.Context) error {
-	return proteus.GenerateRPCServer(packages)
+	return proteus.GenerateRPCServerWithOptions(rpcOptions())
+}
+
+// rpcOptions returns the options for generating the RPC servers from the
+// flags, which transform the packages as protoOptions does, so the servers
+// use the same names as the generated .proto files.
+func rpcOptions() proteus.Options {
+	options := protoOptions()
+	options.Tracing = tracing
+	options.ErrorStatus = errorStatus
+	options.Logging = logging
+	options.Pools = pools
+	options.Clients = clients
+	options.Examples = examples
+	options.ProtobufAPI = rpc.API(protobufAPI)
+	return options
+}
+
+func genSnapshotTests(c *cli.Context) error {
+	return proteus.GenerateSnapshotTests(proteus.Options{
+		Packages:    packages,
+		BoolSets:    boolSets,
+		InlineTypes: inlineTypes,
+		Only:        only,
+		Manifest:    runManifest,
+		Strict:      strict,
+		Cache:       runCache,
+	})
+}
+
+func genPropertyTests(c *cli.Context) error {
+	return proteus.GeneratePropertyTests(proteus.Options{
+		Packages:       packages,
+		OptionalFields: optionalFields,
+		BoolSets:       boolSets,
+		InlineTypes:    inlineTypes,
+		EnumSemantics:  protobuf.EnumSemantics(enumSemantics),
+		Only:           only,
+		Manifest:       runManifest,
+		Strict:         strict,
+		Cache:          runCache,
+	})
+}
+
+func genHarness(c *cli.Context) error {
+	if path == "" {
+		return errors.New("destination path cannot be empty")
+	}
+
+	var protocPath string
+	if !noProtoc {
+		var err error
+		if protocPath, err = findProtoc(); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range packages {
+		proto := protoFile(p)
+		pkg, err := protobuf.ParseFile(proto)
+		if err != nil {
+			return fmt.Errorf("error parsing %q, generate it first: %s", proto, err)
+		}
+
+		dir := filepath.Join(goSrc, p, snapshot.Dir)
+		if err := checkFolder(dir); err != nil {
+			report.Warn("package %s has no snapshots, skipping it", p)
+			continue
+		}
+
+		descriptors := filepath.Join(dir, snapshot.DescriptorSetFile)
+		if noProtoc {
+			err = writeDescriptorSet(descriptors, proto)
+		} else {
+			err = protocDescriptorSet(protocPath, descriptors, proto)
+		}
+
+		if err != nil {
+			return fmt.Errorf("error generating the descriptor set of %q: %s", proto, err)
+		}
+
+		h, err := snapshot.WriteHarness(dir, pkg.Name)
+		if err != nil {
+			return err
+		}
+		report.Info("Generated harness of %d snapshots: %s", len(h.Fixtures), filepath.Join(dir, snapshot.HarnessFile))
+	}
+	return nil
+}
+
+func analyzeInterfaces(c *cli.Context) error {
+	return proteus.AnalyzeInterfaces(proteus.Options{Packages: packages})
 }
 
 var (
@@ -111,66 +991,226 @@ var (
 )
 
 func genAll(c *cli.Context) error {
-	protocPath, err := exec.LookPath("protoc")
-	if err != nil {
-		return fmt.Errorf("protoc is not installed: %s", err)
-	}
+	var protocPath, gofastPath string
+	if !noProtoc {
+		var err error
+		if protocPath, err = findProtoc(); err != nil {
+			return err
+		}
+
+		if gofastPath, err = findGofast(); err != nil {
+			return err
+		}
 
-	if err := checkFolder(protobufSrc); err != nil {
-		return fmt.Errorf("github.com/gogo/protobuf is not installed")
+		if err := checkFolder(protobufSrc); err != nil {
+			return fmt.Errorf("github.com/gogo/protobuf is not installed")
+		}
 	}
 
 	if err := genProtos(c); err != nil {
 		return err
 	}
 
-	for _, p := range packages {
-		outPath := goSrc
-		proto := filepath.Join(path, p, "generated.proto")
-
-		if err := protocExec(protocPath, p, outPath, proto); err != nil {
-			return fmt.Errorf("error generating Go files from %q: %s", proto, err)
+	for _, p := range generatedPackages() {
+		protos, err := packageProtoFiles(p)
+		if err != nil {
+			return err
 		}
 
-		matches, err := filepath.Glob(filepath.Join(path, p, "*.pb.go"))
+		var files []string
+		if noProtoc {
+			files, err = gofastGenerate(p, protos)
+		} else {
+			files, err = protocGenerate(protocPath, gofastPath, p, protos)
+		}
 		if err != nil {
-			return fmt.Errorf("error moving Go files")
+			return fmt.Errorf("error generating Go files from %q: %s", protos[0], err)
 		}
 
-		moveToDir := filepath.Join(outPath, p)
-		for _, s := range matches {
-			mv(s, moveToDir)
+		if runManifest != nil {
+			for _, f := range files {
+				if err := runManifest.Add(f, p); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
 	return genRPCServer(c)
 }
 
-func protocExec(protocPath, pkg, outPath, protoFile string) error {
+// protocGenerate generates the Go files of the given .proto files of the Go
+// package at the given path with protoc and protoc-gen-gofast, and moves them
+// to the folder of the package. It returns the files moved.
+func protocGenerate(protocPath, gofastPath, p string, protos []string) ([]string, error) {
+	outPath := goSrc
+	protoDir := filepath.Dir(protos[0])
+	if err := protocExec(protocPath, gofastPath, protoDir, outPath, protos...); err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, proto := range protos {
+		pbFile := strings.TrimSuffix(filepath.Base(proto), ".proto") + ".pb.go"
+		m, err := filepath.Glob(filepath.Join(protoDir, pbFile))
+		if err != nil {
+			return nil, fmt.Errorf("error moving Go files")
+		}
+		matches = append(matches, m...)
+	}
+
+	var files []string
+	moveToDir := filepath.Join(outPath, p)
+	for _, s := range matches {
+		if err := mv(s, moveToDir); err != nil {
+			return nil, fmt.Errorf("error moving %q: %s", s, err)
+		}
+		files = append(files, filepath.Join(moveToDir, filepath.Base(s)))
+	}
+
+	// With the import path of the package in go_package, as with --buf,
+	// protoc-gen-gofast already writes the files to the package.
+	if len(matches) == 0 {
+		for _, proto := range protos {
+			pbFile := filepath.Join(moveToDir, strings.TrimSuffix(filepath.Base(proto), ".proto")+".pb.go")
+			if _, err := os.Stat(pbFile); err == nil {
+				files = append(files, pbFile)
+			}
+		}
+	}
+	return files, blankUnusedImportsOf(files)
+}
+
+// writeDescriptorSetOut writes the descriptor set of the .proto files of all
+// the packages to the file given with --descriptor-set-out, with protoc or
+// in-process with --no-protoc. The files of the packages out of the scope of
+// --only are the ones already generated.
+func writeDescriptorSetOut() error {
+	var protos []string
+	for _, p := range packages {
+		files, err := packageProtoFiles(p)
+		if err != nil {
+			return err
+		}
+		protos = append(protos, files...)
+	}
+
+	var err error
+	if noProtoc {
+		err = writeDescriptorSet(descriptorSetOut, protos...)
+	} else {
+		var protocPath string
+		if protocPath, err = findProtoc(); err == nil {
+			err = protocDescriptorSet(protocPath, descriptorSetOut, protos...)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("error writing the descriptor set %q: %s", descriptorSetOut, err)
+	}
+	report.Info("Generated descriptor set: %s", descriptorSetOut)
+
+	if runManifest != nil {
+		return runManifest.Add(descriptorSetOut, packages[0])
+	}
+	return nil
+}
+
+// generatedPackages returns the packages whose files are generated, which
+// are only the ones in the scope of --only, if given.
+func generatedPackages() []string {
+	if runScope == nil {
+		return packages
+	}
+	return runScope.Packages()
+}
+
+// packageProtoFiles returns the .proto files generated for the Go package at
+// the given path, starting with the one in its layout. If the files are split,
+// the rest are the ones next to it generated from the package.
+func packageProtoFiles(p string) ([]string, error) {
+	proto := protoFile(p)
+	files := []string{proto}
+	if !splitFiles {
+		return files, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(proto), "*.proto"))
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte("// " + protobuf.GeneratedBy(p) + "\n")
+	for _, m := range matches {
+		if m == proto {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(m)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.HasPrefix(data, header) {
+			files = append(files, m)
+		}
+	}
+	return files, nil
+}
+
+func protocExec(protocPath, gofastPath, protoDir, outPath string, protoFiles ...string) error {
 	protocArgs := fmt.Sprintf(
 		"--proto_path=%s:%s:%s:%s:.",
 		goSrc,
 		path,
 		filepath.Join(protobufSrc, "protobuf"),
-		filepath.Join(path, pkg),
+		protoDir,
 	)
 
 	report.Info("executing protoc: %s %s", protocPath, protocArgs)
 
-	cmd := exec.Command(
-		protocPath,
-		protocArgs,
-		genAllGoFastOutOption(outPath),
-		protoFile,
-	)
+	args := []string{protocArgs}
+	if gofastPath != "" {
+		args = append(args, "--plugin=protoc-gen-gofast="+gofastPath)
+	}
+	args = append(args, genAllGoFastOutOption(outPath))
+	args = append(args, protoFiles...)
+
+	cmd := exec.Command(protocPath, args...)
+	cmd.Env = toolEnv()
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	return cmd.Run()
 }
 
-func mv(from, to string) error {
-	cmd := exec.Command("mv", from, to)
+// protoFile returns the path of the .proto file generated for the Go package
+// at the given path. Only the package names given with flags are known, not
+// the ones in the docs of the packages.
+func protoFile(p string) string {
+	names, _ := protobuf.ParsePackageNames(packageNames)
+	tpl := protobuf.PackageTemplate(pkgTemplate)
+	pkg := names.Disambiguate(packages, tpl).Package(p, tpl)
+	return filepath.Join(path, protobuf.FileLayout(fileLayout).File(p, pkg))
+}
+
+// protocDescriptorSet writes the descriptor set of the given .proto files,
+// including all the files they import, to the given file.
+func protocDescriptorSet(protocPath, out string, protoFiles ...string) error {
+	args := []string{
+		fmt.Sprintf(
+			"--proto_path=%s:%s:%s:%s:.",
+			goSrc,
+			path,
+			filepath.Join(protobufSrc, "protobuf"),
+			filepath.Dir(protoFiles[0]),
+		),
+		"--include_imports",
+		"--descriptor_set_out=" + out,
+	}
+
+	cmd := exec.Command(protocPath, append(args, protoFiles...)...)
+	cmd.Env = toolEnv()
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -178,14 +1218,23 @@ func mv(from, to string) error {
 }
 
 func genAllGoFastOutOption(outPath string) string {
-	str := "--gofast_out=plugins=grpc"
-	importMappings := protobuf.DefaultMappings.ToGoOutPath()
+	return fmt.Sprintf("--gofast_out=%s:%s", gofastParams(), outPath)
+}
+
+// gofastParams returns the parameters of protoc-gen-gofast, which are the
+// gRPC plugin and the Go packages of the imported files.
+func gofastParams() string {
+	str := "plugins=grpc"
+	importMappings := protobuf.RegisteredMappings().ToGoOutPath()
 
 	if importMappings != "" {
 		str += fmt.Sprintf(",%s", importMappings)
 	}
 
-	str += fmt.Sprintf(":%s", outPath)
+	files, _ := protobuf.ParseMessageFiles(messageFiles)
+	if fileMappings := files.ToGoOutPath(); fileMappings != "" {
+		str += fmt.Sprintf(",%s", fileMappings)
+	}
 
 	return str
 }
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+)
+
+func TestRPCOptions(t *testing.T) {
+	require := require.New(t)
+
+	oldNames, oldTemplate, oldClients := packageNames, pkgTemplate, clients
+	defer func() { packageNames, pkgTemplate, clients = oldNames, oldTemplate, oldClients }()
+	packageNames = []string{"gitlab.com/foo/svc=people"}
+	pkgTemplate = "{name}.v1"
+	clients = true
+
+	options := rpcOptions()
+	require.Equal(protobuf.PackageNames{"gitlab.com/foo/svc": "people"}, options.PackageNames)
+	require.Equal(protobuf.PackageTemplate("{name}.v1"), options.PackageTemplate)
+	require.True(options.Clients)
+	require.Equal(protoOptions().PackageNames, options.PackageNames, "the servers are transformed as the .proto files")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	hermetic      bool
+	protocBin     string
+	protocVersion string
+	gofastBin     string
+)
+
+// findProtoc returns the path of the protoc binary to use. It is only looked
+// up on the PATH if no path is given and hermetic mode is disabled. If an
+// expected version is given, the version of the binary is verified.
+func findProtoc() (string, error) {
+	path := protocBin
+	if path == "" {
+		if hermetic {
+			return "", errors.New("the path of protoc must be given with --protoc in hermetic mode")
+		}
+
+		var err error
+		if path, err = exec.LookPath("protoc"); err != nil {
+			return "", fmt.Errorf("protoc is not installed: %s", err)
+		}
+	} else if err := checkExecutable(path); err != nil {
+		return "", err
+	}
+
+	if protocVersion != "" {
+		if err := checkProtocVersion(path, protocVersion); err != nil {
+			return "", err
+		}
+	}
+
+	return path, nil
+}
+
+// findGofast returns the path of the protoc-gen-gofast plugin to use, or an
+// empty string if protoc should look it up on the PATH, which is not allowed
+// in hermetic mode.
+func findGofast() (string, error) {
+	if gofastBin == "" {
+		if hermetic {
+			return "", errors.New("the path of protoc-gen-gofast must be given with --protoc-gen-gofast in hermetic mode")
+		}
+		return "", nil
+	}
+
+	return gofastBin, checkExecutable(gofastBin)
+}
+
+func checkExecutable(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path of binary %q must be absolute", path)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to find binary %q: %s", path, err)
+	}
+
+	if fi.IsDir() || fi.Mode()&0111 == 0 {
+		return fmt.Errorf("%q is not an executable file", path)
+	}
+
+	return nil
+}
+
+// checkProtocVersion checks that the output of `protoc --version`, which is
+// in the form "libprotoc 3.5.1", matches the expected version.
+func checkProtocVersion(path, expected string) error {
+	cmd := exec.Command(path, "--version")
+	cmd.Env = toolEnv()
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("unable to get the version of protoc: %s", err)
+	}
+
+	version := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(out)), "libprotoc"))
+	if version != expected {
+		return fmt.Errorf("expected protoc version %s, got %s", expected, version)
+	}
+
+	return nil
+}
+
+// toolEnv returns the environment external binaries are run with. In
+// hermetic mode, PATH is removed so they can not find other binaries by
+// themselves.
+func toolEnv() []string {
+	env := os.Environ()
+	if !hermetic {
+		return env
+	}
+
+	var result []string
+	for _, e := range env {
+		if !strings.HasPrefix(e, "PATH=") {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// mv moves the file at from to the directory to.
+func mv(from, to string) error {
+	dst := filepath.Join(to, filepath.Base(from))
+	if err := os.Rename(from, dst); err == nil {
+		return nil
+	}
+
+	// rename does not work across devices, so copy it instead
+	if err := copyFile(from, dst); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/ThatTomPerson/proteus"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// watchDelay is how long the changes are collected before regenerating, as
+// editors and tools usually write files in several steps.
+const watchDelay = 300 * time.Millisecond
+
+// watch generates the .proto files of the packages and then watches their
+// folders, regenerating the proto packages affected by the changes of their
+// Go files until it is interrupted. The scanned packages are cached, in the
+// scan cache if one is given, so only the changed packages and the ones
+// importing them are scanned again.
+func watch(c *cli.Context) error {
+	if path == "" {
+		return errors.New("destination path cannot be empty")
+	}
+
+	if err := checkFolder(path); err != nil {
+		return err
+	}
+
+	options := protoOptions()
+	if options.Cache == nil {
+		options.Cache = scanner.NewCache()
+	}
+	if err := proteus.GenerateProtos(options); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	var dirs = make(map[string]string, len(packages))
+	for _, p := range packages {
+		dir := filepath.Join(goSrc, p)
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("error watching %q: %s", dir, err)
+		}
+		dirs[dir] = p
+	}
+	report.Info("watching %d packages for changes", len(packages))
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	var (
+		changed = make(map[string]bool)
+		timer   <-chan time.Time
+	)
+	for {
+		select {
+		case e := <-watcher.Events:
+			p, ok := dirs[filepath.Dir(e.Name)]
+			if !ok || e.Op == fsnotify.Chmod || !isSourceFile(e.Name) {
+				continue
+			}
+
+			changed[p] = true
+			timer = time.After(watchDelay)
+		case err := <-watcher.Errors:
+			report.Error("error watching the packages: %s", err)
+		case <-timer:
+			regenerate(options, changed)
+			changed = make(map[string]bool)
+			timer = nil
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+// regenerate generates the proto packages affected by the changes of the
+// given packages, which are scanned again along with the packages importing
+// them. Errors are reported instead of returned, so the watch goes on.
+func regenerate(options proteus.Options, changed map[string]bool) {
+	var pkgs = make([]string, 0, len(changed))
+	for p := range changed {
+		pkgs = append(pkgs, p)
+	}
+	sort.Strings(pkgs)
+
+	options.Only = options.Cache.Invalidate(pkgs...)
+	options.Scope = new(proteus.Scope)
+	if err := proteus.GenerateProtos(options); err != nil {
+		report.Error("error regenerating %s: %s", strings.Join(pkgs, ", "), err)
+		return
+	}
+
+	report.Info("regenerated the proto packages of %s", strings.Join(options.Scope.Packages(), ", "))
+}
+
+// isSourceFile reports whether the file is one of the Go files scanned,
+// which are the ones that are not tests or generated by protoc or proteus.
+func isSourceFile(file string) bool {
+	name := filepath.Base(file)
+	return strings.HasSuffix(name, ".go") &&
+		!strings.HasSuffix(name, "_test.go") &&
+		!strings.HasSuffix(name, ".pb.go") &&
+		!strings.HasSuffix(name, ".proteus.go") &&
+		!strings.HasPrefix(name, "proteus_")
+}
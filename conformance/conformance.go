@@ -0,0 +1,243 @@
+// Package conformance runs the messages generated by proteus against the
+// conformance test runner of protobuf, which sends a testee program
+// thousands of payloads in the binary and JSON encodings, with edge cases
+// like NaN, unset fields, the largest varints or unknown fields, and checks
+// what it encodes back. Serve implements the testee side of its protocol for
+// the messages in a Registry.
+package conformance // import "gitlab.com/ThatTomPerson/proteus/conformance"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Format is the encoding of a payload.
+type Format int
+
+// The formats of the payloads, as numbered by the WireFormat enum of
+// conformance.proto.
+const (
+	UnspecifiedFormat Format = 0
+	ProtobufFormat    Format = 1
+	JSONFormat        Format = 2
+	JSPBFormat        Format = 3
+	TextFormat        Format = 4
+)
+
+// Registry has the constructors of the messages the testee can handle, by
+// full protobuf name, e.g. protobuf_test_messages.proto3.TestAllTypesProto3.
+type Registry map[string]func() proto.Message
+
+// Register adds the constructor of the messages with the given full name.
+func (r Registry) Register(name string, fn func() proto.Message) {
+	r[name] = fn
+}
+
+// Request is a test sent by the runner.
+type Request struct {
+	// MessageType is the full protobuf name of the message of the payload.
+	MessageType string
+	// Payload is the message encoded with the input format.
+	Payload []byte
+	// Input is the format of the payload.
+	Input Format
+	// Output is the format the message must be encoded back with.
+	Output Format
+}
+
+// Response is the result of a test.
+type Response struct {
+	// ParseError is the error decoding the payload, if it could not be.
+	ParseError string
+	// SerializeError is the error encoding the message back, if it could
+	// not be.
+	SerializeError string
+	// RuntimeError is an error of the testee itself.
+	RuntimeError string
+	// Skipped is the reason the test was skipped, if it was.
+	Skipped string
+	// Payload is the message encoded with the output format.
+	Payload []byte
+	// Output is the format of the payload.
+	Output Format
+}
+
+// Handle runs the given test with the messages of the registry. Tests of
+// other messages, or in the JSPB or text formats, are skipped.
+func (r Registry) Handle(req *Request) *Response {
+	newMessage, ok := r[req.MessageType]
+	if !ok {
+		return &Response{Skipped: fmt.Sprintf("message %s is not registered", req.MessageType)}
+	}
+
+	msg := newMessage()
+	switch req.Input {
+	case ProtobufFormat:
+		if err := proto.Unmarshal(req.Payload, msg); err != nil {
+			return &Response{ParseError: err.Error()}
+		}
+	case JSONFormat:
+		if err := jsonpb.Unmarshal(bytes.NewReader(req.Payload), msg); err != nil {
+			return &Response{ParseError: err.Error()}
+		}
+	default:
+		return &Response{Skipped: fmt.Sprintf("input format %d is not supported", req.Input)}
+	}
+
+	switch req.Output {
+	case ProtobufFormat:
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			return &Response{SerializeError: err.Error()}
+		}
+		return &Response{Payload: data, Output: ProtobufFormat}
+	case JSONFormat:
+		s, err := new(jsonpb.Marshaler).MarshalToString(msg)
+		if err != nil {
+			return &Response{SerializeError: err.Error()}
+		}
+		return &Response{Payload: []byte(s), Output: JSONFormat}
+	default:
+		return &Response{Skipped: fmt.Sprintf("output format %d is not supported", req.Output)}
+	}
+}
+
+// Serve reads the tests sent by the runner from the given reader, which is
+// the standard input of the testee, and writes their responses to the given
+// writer, its standard output, until the runner closes it. Every test and
+// response is written as its length, in four little-endian bytes, followed
+// by the ConformanceRequest or ConformanceResponse message.
+func Serve(r io.Reader, w io.Writer, registry Registry) error {
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("error reading the size of the request: %s", err)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("error reading the request: %s", err)
+		}
+
+		var res *Response
+		req, err := UnmarshalRequest(data)
+		if err != nil {
+			res = &Response{RuntimeError: err.Error()}
+		} else {
+			res = registry.Handle(req)
+		}
+
+		data = MarshalResponse(res)
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(data))); err != nil {
+			return fmt.Errorf("error writing the size of the response: %s", err)
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("error writing the response: %s", err)
+		}
+	}
+}
+
+// The numbers of the fields of ConformanceRequest.
+const (
+	requestProtobufPayload = 1
+	requestJSONPayload     = 2
+	requestOutputFormat    = 3
+	requestMessageType     = 4
+	requestJSPBPayload     = 7
+	requestTextPayload     = 8
+)
+
+// The numbers of the fields of ConformanceResponse.
+const (
+	responseParseError      = 1
+	responseRuntimeError    = 2
+	responseProtobufPayload = 3
+	responseJSONPayload     = 4
+	responseSkipped         = 5
+	responseSerializeError  = 6
+)
+
+// UnmarshalRequest decodes a ConformanceRequest. Its fields are decoded by
+// hand, so the testee does not depend on the code generated for
+// conformance.proto.
+func UnmarshalRequest(data []byte) (*Request, error) {
+	req := new(Request)
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid request: %s", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		switch {
+		case typ == protowire.BytesType && num != requestOutputFormat:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid field %d of the request: %s", num, protowire.ParseError(n))
+			}
+			data = data[n:]
+
+			switch num {
+			case requestProtobufPayload:
+				req.Payload, req.Input = v, ProtobufFormat
+			case requestJSONPayload:
+				req.Payload, req.Input = v, JSONFormat
+			case requestJSPBPayload:
+				req.Payload, req.Input = v, JSPBFormat
+			case requestTextPayload:
+				req.Payload, req.Input = v, TextFormat
+			case requestMessageType:
+				req.MessageType = string(v)
+			}
+		case typ == protowire.VarintType && num == requestOutputFormat:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid output format of the request: %s", protowire.ParseError(n))
+			}
+			data = data[n:]
+			req.Output = Format(v)
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid field %d of the request: %s", num, protowire.ParseError(n))
+			}
+			data = data[n:]
+		}
+	}
+	return req, nil
+}
+
+// MarshalResponse encodes the response as a ConformanceResponse, whose
+// result is a oneof, so only the first of the errors, the skipped reason and
+// the payload that is set is encoded.
+func MarshalResponse(res *Response) []byte {
+	field := func(num protowire.Number, v []byte) []byte {
+		b := protowire.AppendTag(nil, num, protowire.BytesType)
+		return protowire.AppendBytes(b, v)
+	}
+
+	switch {
+	case res.ParseError != "":
+		return field(responseParseError, []byte(res.ParseError))
+	case res.SerializeError != "":
+		return field(responseSerializeError, []byte(res.SerializeError))
+	case res.RuntimeError != "":
+		return field(responseRuntimeError, []byte(res.RuntimeError))
+	case res.Skipped != "":
+		return field(responseSkipped, []byte(res.Skipped))
+	case res.Output == JSONFormat:
+		return field(responseJSONPayload, res.Payload)
+	default:
+		return field(responseProtobufPayload, res.Payload)
+	}
+}
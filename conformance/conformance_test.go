@@ -0,0 +1,115 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func testRegistry() Registry {
+	r := make(Registry)
+	r.Register("google.protobuf.DoubleValue", func() proto.Message { return new(types.DoubleValue) })
+	r.Register("google.protobuf.UInt64Value", func() proto.Message { return new(types.UInt64Value) })
+	r.Register("google.protobuf.Int64Value", func() proto.Message { return new(types.Int64Value) })
+	return r
+}
+
+func mustMarshal(t *testing.T, msg proto.Message) []byte {
+	data, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	return data
+}
+
+func TestHandle(t *testing.T) {
+	r := testRegistry()
+	cases := []struct {
+		name     string
+		req      *Request
+		expected *Response
+	}{
+		{
+			"NaN to JSON",
+			&Request{MessageType: "google.protobuf.DoubleValue", Payload: mustMarshal(t, &types.DoubleValue{Value: math.NaN()}), Input: ProtobufFormat, Output: JSONFormat},
+			&Response{Payload: []byte(`"NaN"`), Output: JSONFormat},
+		},
+		{
+			"largest varint",
+			&Request{MessageType: "google.protobuf.UInt64Value", Payload: []byte(`"18446744073709551615"`), Input: JSONFormat, Output: ProtobufFormat},
+			&Response{Payload: mustMarshal(t, &types.UInt64Value{Value: math.MaxUint64}), Output: ProtobufFormat},
+		},
+		{
+			"unset value",
+			&Request{MessageType: "google.protobuf.Int64Value", Payload: []byte{}, Input: ProtobufFormat, Output: ProtobufFormat},
+			&Response{Payload: []byte{}, Output: ProtobufFormat},
+		},
+		{
+			"invalid payload",
+			&Request{MessageType: "google.protobuf.Int64Value", Payload: []byte{0x08}, Input: ProtobufFormat, Output: ProtobufFormat},
+			&Response{ParseError: "unexpected EOF"},
+		},
+		{
+			"unregistered message",
+			&Request{MessageType: "protobuf_test_messages.proto3.TestAllTypesProto3", Input: ProtobufFormat, Output: ProtobufFormat},
+			&Response{Skipped: "message protobuf_test_messages.proto3.TestAllTypesProto3 is not registered"},
+		},
+		{
+			"text format",
+			&Request{MessageType: "google.protobuf.Int64Value", Input: TextFormat, Output: ProtobufFormat},
+			&Response{Skipped: "input format 4 is not supported"},
+		},
+	}
+
+	for _, c := range cases {
+		res := r.Handle(c.req)
+		require.Equal(t, string(c.expected.Payload), string(res.Payload), c.name)
+
+		res.Payload, c.expected.Payload = nil, nil
+		require.Equal(t, c.expected, res, c.name)
+	}
+}
+
+func TestServe(t *testing.T) {
+	require := require.New(t)
+
+	req := protowire.AppendTag(nil, requestProtobufPayload, protowire.BytesType)
+	req = protowire.AppendBytes(req, mustMarshal(t, &types.Int64Value{Value: -1}))
+	req = protowire.AppendTag(req, requestOutputFormat, protowire.VarintType)
+	req = protowire.AppendVarint(req, uint64(JSONFormat))
+	req = protowire.AppendTag(req, requestMessageType, protowire.BytesType)
+	req = protowire.AppendString(req, "google.protobuf.Int64Value")
+	req = protowire.AppendTag(req, 5, protowire.VarintType)
+	req = protowire.AppendVarint(req, 1)
+
+	var in, out bytes.Buffer
+	for _, data := range [][]byte{req, {0xff}} {
+		require.NoError(binary.Write(&in, binary.LittleEndian, uint32(len(data))))
+		in.Write(data)
+	}
+	require.NoError(Serve(&in, &out, testRegistry()))
+
+	var responses [][]byte
+	for out.Len() > 0 {
+		var size uint32
+		require.NoError(binary.Read(&out, binary.LittleEndian, &size))
+		responses = append(responses, out.Next(int(size)))
+	}
+
+	require.Len(responses, 2)
+	require.Equal(MarshalResponse(&Response{Payload: []byte(`"-1"`), Output: JSONFormat}), responses[0])
+
+	num, typ, n := protowire.ConsumeTag(responses[1])
+	require.True(n > 0)
+	require.Equal(protowire.Number(responseRuntimeError), num, "invalid requests are runtime errors")
+	require.Equal(protowire.BytesType, typ)
+}
+
+func TestServeTruncated(t *testing.T) {
+	in := bytes.NewReader([]byte{10, 0, 0, 0, 1})
+	require.Error(t, Serve(in, new(bytes.Buffer), testRegistry()))
+}
@@ -0,0 +1,49 @@
+// Package proto3 has the fields of the TestAllTypesProto3 message of the
+// protobuf conformance suite that Go types can be generated as, with the
+// names and numbers they have in test_messages_proto3.proto, so the runner
+// can send its tests to the code proteus generates for them. The
+// repeated_bytes and map_string_bytes fields are left out, as slices of
+// []byte are scanned as a []byte and generated as a single bytes field.
+//
+//proteus:package protobuf_test_messages.proto3
+package proto3
+
+//proteus:generate
+type TestAllTypesProto3 struct {
+	OptionalInt32         int32          `proteus:"name=optional_int32,id=1"`
+	OptionalInt64         int64          `proteus:"name=optional_int64,id=2"`
+	OptionalUint32        uint32         `proteus:"name=optional_uint32,id=3"`
+	OptionalUint64        uint64         `proteus:"name=optional_uint64,id=4"`
+	OptionalFloat         float32        `proteus:"name=optional_float,id=11"`
+	OptionalDouble        float64        `proteus:"name=optional_double,id=12"`
+	OptionalBool          bool           `proteus:"name=optional_bool,id=13"`
+	OptionalString        string         `proteus:"name=optional_string,id=14"`
+	OptionalBytes         []byte         `proteus:"name=optional_bytes,id=15"`
+	OptionalNestedMessage *NestedMessage `proteus:"name=optional_nested_message,id=18"`
+
+	RepeatedInt32         []int32          `proteus:"name=repeated_int32,id=31"`
+	RepeatedInt64         []int64          `proteus:"name=repeated_int64,id=32"`
+	RepeatedUint32        []uint32         `proteus:"name=repeated_uint32,id=33"`
+	RepeatedUint64        []uint64         `proteus:"name=repeated_uint64,id=34"`
+	RepeatedFloat         []float32        `proteus:"name=repeated_float,id=41"`
+	RepeatedDouble        []float64        `proteus:"name=repeated_double,id=42"`
+	RepeatedBool          []bool           `proteus:"name=repeated_bool,id=43"`
+	RepeatedString        []string         `proteus:"name=repeated_string,id=44"`
+	RepeatedNestedMessage []*NestedMessage `proteus:"name=repeated_nested_message,id=48"`
+
+	MapInt32Int32          map[int32]int32           `proteus:"name=map_int32_int32,id=56"`
+	MapInt64Int64          map[int64]int64           `proteus:"name=map_int64_int64,id=57"`
+	MapUint32Uint32        map[uint32]uint32         `proteus:"name=map_uint32_uint32,id=58"`
+	MapUint64Uint64        map[uint64]uint64         `proteus:"name=map_uint64_uint64,id=59"`
+	MapInt32Float          map[int32]float32         `proteus:"name=map_int32_float,id=66"`
+	MapInt32Double         map[int32]float64         `proteus:"name=map_int32_double,id=67"`
+	MapBoolBool            map[bool]bool             `proteus:"name=map_bool_bool,id=68"`
+	MapStringString        map[string]string         `proteus:"name=map_string_string,id=69"`
+	MapStringNestedMessage map[string]*NestedMessage `proteus:"name=map_string_nested_message,id=71"`
+}
+
+//proteus:generate
+type NestedMessage struct {
+	A           int32               `proteus:"name=a,id=1"`
+	Corecursive *TestAllTypesProto3 `proteus:"name=corecursive,id=2"`
+}
@@ -0,0 +1,25 @@
+// Command testee serves the tests of the protobuf conformance runner with
+// the code generated by proteus for the proto3 package. Run make conformance
+// from the root of the repository to generate it and run the suite.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gogo/protobuf/proto"
+	"gitlab.com/ThatTomPerson/proteus/conformance"
+	"gitlab.com/ThatTomPerson/proteus/conformance/testdata/proto3"
+)
+
+func main() {
+	r := make(conformance.Registry)
+	r.Register("protobuf_test_messages.proto3.TestAllTypesProto3", func() proto.Message {
+		return new(proto3.TestAllTypesProto3)
+	})
+
+	if err := conformance.Serve(os.Stdin, os.Stdout, r); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
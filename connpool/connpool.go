@@ -0,0 +1,74 @@
+// Package connpool pools the connections of the generated gRPC clients, so
+// the calls to a service are spread over several HTTP/2 connections when a
+// single one becomes the bottleneck, e.g. because of the limit of concurrent
+// streams of the server or the throughput of one TCP connection.
+package connpool // import "gitlab.com/ThatTomPerson/proteus/connpool"
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+)
+
+// Pool is a fixed number of client connections that the calls are sent to
+// in turn. It is also a grpc.ClientConnInterface, so it can be given to the
+// clients that take one.
+type Pool struct {
+	conns []*grpc.ClientConn
+	next  atomic.Uint32
+}
+
+// Dial returns a pool of the given number of client connections created with
+// the given func. If one of them can not be created, the ones that were are
+// closed and its error is returned.
+func Dial(size int, dial func() (*grpc.ClientConn, error)) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("connpool: the size of the pool must be positive, got %d", size)
+	}
+
+	p := &Pool{conns: make([]*grpc.ClientConn, 0, size)}
+	for i := 0; i < size; i++ {
+		conn, err := dial()
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.conns = append(p.conns, conn)
+	}
+	return p, nil
+}
+
+// Len returns the number of connections of the pool.
+func (p *Pool) Len() int {
+	return len(p.conns)
+}
+
+// Next returns the connection the next call is sent to, which is the one
+// after the last connection returned, round-robin.
+func (p *Pool) Next() *grpc.ClientConn {
+	n := p.next.Add(1) - 1
+	return p.conns[n%uint32(len(p.conns))]
+}
+
+// Invoke sends a unary call to the next connection.
+func (p *Pool) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	return p.Next().Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream opens a stream on the next connection.
+func (p *Pool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return p.Next().NewStream(ctx, desc, method, opts...)
+}
+
+// Close closes all the connections of the pool, returning the first error.
+func (p *Pool) Close() error {
+	var err error
+	for _, conn := range p.conns {
+		if e := conn.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package connpool
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var _ grpc.ClientConnInterface = (*Pool)(nil)
+
+func dial() (*grpc.ClientConn, error) {
+	return grpc.NewClient("passthrough:///localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+func TestDial(t *testing.T) {
+	require := require.New(t)
+
+	p, err := Dial(3, dial)
+	require.NoError(err)
+	require.Equal(3, p.Len())
+
+	var conns []*grpc.ClientConn
+	for i := 0; i < 6; i++ {
+		conns = append(conns, p.Next())
+	}
+	require.Equal(conns[:3], conns[3:])
+	require.NotSame(conns[0], conns[1])
+	require.NotSame(conns[1], conns[2])
+
+	require.NoError(p.Close())
+	require.Error(p.Close(), "the connections are already closed")
+}
+
+func TestDialErrors(t *testing.T) {
+	require := require.New(t)
+
+	_, err := Dial(0, dial)
+	require.EqualError(err, "connpool: the size of the pool must be positive, got 0")
+
+	var dialed []*grpc.ClientConn
+	_, err = Dial(3, func() (*grpc.ClientConn, error) {
+		if len(dialed) == 2 {
+			return nil, errors.New("can not dial")
+		}
+
+		conn, err := dial()
+		dialed = append(dialed, conn)
+		return conn, err
+	})
+	require.EqualError(err, "can not dial")
+	for _, conn := range dialed {
+		require.Error(conn.Close(), "the connections dialed are closed")
+	}
+}
@@ -0,0 +1,116 @@
+package constants // import "gitlab.com/ThatTomPerson/proteus/constants"
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// Generator writes a constants.json file with the constants of a package
+// marked to be generated next to its generated .proto file. As protobuf has
+// no constants, the file lets other languages share the limits, defaults and
+// codes defined in Go.
+//
+// The file has the name of the protobuf package and a list with the name,
+// docs, kind and value of every constant. The kind is one of bool, string,
+// int or float and the value is written as a JSON value of that kind.
+// Integers are always exact, so those that do not fit in 64 bits should be
+// decoded as big numbers.
+type Generator struct {
+	basePath string
+}
+
+// NewGenerator creates a new Generator with the given base path.
+func NewGenerator(basePath string) *Generator {
+	return &Generator{basePath}
+}
+
+type file struct {
+	GeneratedBy string     `json:"generated_by"`
+	Package     string     `json:"package"`
+	Constants   []constant `json:"constants"`
+}
+
+type constant struct {
+	Name  string            `json:"name"`
+	Docs  []string          `json:"docs,omitempty"`
+	Kind  scanner.ConstKind `json:"kind"`
+	Value json.RawMessage   `json:"value"`
+}
+
+// Generate writes the constants file of the given package to disk, if it has
+// any constants.
+func (g *Generator) Generate(pkg *scanner.Package, proto *protobuf.Package) error {
+	if len(pkg.Consts) == 0 {
+		return nil
+	}
+
+	fi, err := os.Stat(g.basePath)
+	if err != nil {
+		return err
+	}
+
+	data, err := g.buildFile(pkg, proto)
+	if err != nil {
+		return err
+	}
+
+	file := g.FileName(proto)
+	if err := output.MkdirAll(filepath.Dir(file), fi.Mode()); err != nil {
+		return err
+	}
+
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return err
+	}
+
+	report.Info("Generated constants file: %s", file)
+	return nil
+}
+
+// FileName returns the path of the constants file generated for the given
+// package.
+func (g *Generator) FileName(proto *protobuf.Package) string {
+	return filepath.Join(g.basePath, proto.Path, "constants.json")
+}
+
+func (g *Generator) buildFile(pkg *scanner.Package, proto *protobuf.Package) ([]byte, error) {
+	f := file{
+		GeneratedBy: protobuf.GeneratedBy(proto.Path),
+		Package:     proto.Name,
+	}
+
+	for _, c := range pkg.Consts {
+		value, err := jsonValue(c)
+		if err != nil {
+			return nil, err
+		}
+
+		f.Constants = append(f.Constants, constant{
+			Name:  c.Name,
+			Docs:  c.Doc,
+			Kind:  c.Kind,
+			Value: value,
+		})
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// jsonValue returns the value of the constant as JSON. Numbers and booleans
+// are already written in their JSON form, only strings have to be encoded.
+func jsonValue(c *scanner.Const) (json.RawMessage, error) {
+	if c.Kind == scanner.StringConst {
+		return json.Marshal(c.Value)
+	}
+	return json.RawMessage(c.Value), nil
+}
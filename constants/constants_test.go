@@ -0,0 +1,90 @@
+package constants
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedFile = `{
+  "generated_by": "Code generated by proteus from foo/bar. DO NOT EDIT.",
+  "package": "foo.bar",
+  "constants": [
+    {
+      "name": "MaxPageSize",
+      "docs": [
+        "MaxPageSize is the maximum size of a page."
+      ],
+      "kind": "int",
+      "value": 100
+    },
+    {
+      "name": "DefaultRegion",
+      "kind": "string",
+      "value": "eu \"west\""
+    },
+    {
+      "name": "Ratio",
+      "kind": "float",
+      "value": 1.5
+    },
+    {
+      "name": "Enabled",
+      "kind": "bool",
+      "value": true
+    }
+  ]
+}
+`
+
+func TestGenerate(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-constants")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	pkg := &scanner.Package{
+		Consts: []*scanner.Const{
+			{
+				Docs:  scanner.Docs{Doc: []string{"MaxPageSize is the maximum size of a page."}},
+				Name:  "MaxPageSize",
+				Kind:  scanner.IntConst,
+				Value: "100",
+			},
+			{Name: "DefaultRegion", Kind: scanner.StringConst, Value: `eu "west"`},
+			{Name: "Ratio", Kind: scanner.FloatConst, Value: "1.5"},
+			{Name: "Enabled", Kind: scanner.BoolConst, Value: "true"},
+		},
+	}
+	proto := &protobuf.Package{Name: "foo.bar", Path: "foo/bar"}
+
+	g := NewGenerator(dir)
+	require.Nil(g.Generate(pkg, proto))
+	require.Equal(filepath.Join(dir, "foo", "bar", "constants.json"), g.FileName(proto))
+
+	data, err := ioutil.ReadFile(g.FileName(proto))
+	require.Nil(err)
+	require.Equal(expectedFile, string(data))
+}
+
+func TestGenerateWithoutConsts(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-constants")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	g := NewGenerator(dir)
+	proto := &protobuf.Package{Name: "foo.bar", Path: "foo/bar"}
+	require.Nil(g.Generate(&scanner.Package{}, proto))
+
+	_, err = os.Stat(g.FileName(proto))
+	require.True(os.IsNotExist(err))
+}
@@ -0,0 +1,199 @@
+package customtypes
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The array funcs implement the methods of gogoproto.customtype generated
+// for the named array types of the scanned packages, like
+// `type Hash [32]byte`. Arrays of bytes are encoded as a bytes field, and
+// arrays of other elements as a packed repeated field, which has the same
+// encoding as a bytes field with the packed values. The arrays must get as
+// many elements as they have, or none, which is the zero array.
+
+// Number is the type of the elements of the arrays encoded as a packed
+// repeated field.
+type Number interface {
+	bool | int | int8 | int16 | int32 | int64 | uint | uint16 | uint32 | uint64 | float32 | float64
+}
+
+// MarshalBytesTo writes the given array of bytes to data, which must have
+// room for it, and returns its size.
+func MarshalBytesTo(data, array []byte) (int, error) {
+	return marshalTo(data, array)
+}
+
+// UnmarshalBytes copies the given bytes to the array, which must have the
+// same length, unless they are empty.
+func UnmarshalBytes(array, data []byte) error {
+	if len(data) == 0 {
+		clear(array)
+		return nil
+	}
+
+	if len(data) != len(array) {
+		return errArrayLen(len(array), len(data))
+	}
+	copy(array, data)
+	return nil
+}
+
+// AppendPacked appends the packed encoding of the given elements.
+func AppendPacked[T Number](b []byte, array []T) []byte {
+	typ := wireType[T]()
+	for _, v := range array {
+		switch typ {
+		case protowire.Fixed32Type:
+			b = protowire.AppendFixed32(b, uint32(encode(v)))
+		case protowire.Fixed64Type:
+			b = protowire.AppendFixed64(b, encode(v))
+		default:
+			b = protowire.AppendVarint(b, encode(v))
+		}
+	}
+	return b
+}
+
+// MarshalPackedTo writes the packed encoding of the given elements to data,
+// which must have room for it, and returns its size.
+func MarshalPackedTo[T Number](data []byte, array []T) (int, error) {
+	if len(data) < SizePacked(array) {
+		return 0, io.ErrShortBuffer
+	}
+	return len(AppendPacked(data[:0], array)), nil
+}
+
+// SizePacked returns the size of the packed encoding of the given elements.
+func SizePacked[T Number](array []T) int {
+	switch wireType[T]() {
+	case protowire.Fixed32Type:
+		return len(array) * protowire.SizeFixed32()
+	case protowire.Fixed64Type:
+		return len(array) * protowire.SizeFixed64()
+	}
+
+	var n int
+	for _, v := range array {
+		n += protowire.SizeVarint(encode(v))
+	}
+	return n
+}
+
+// UnmarshalPacked decodes the packed elements of data into the array, which
+// must have as many elements, unless there are none.
+func UnmarshalPacked[T Number](array []T, data []byte) error {
+	clear(array)
+	typ := wireType[T]()
+	var n int
+	for ; len(data) > 0; n++ {
+		var v uint64
+		var m int
+		switch typ {
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, m = protowire.ConsumeFixed32(data)
+			v = uint64(v32)
+		case protowire.Fixed64Type:
+			v, m = protowire.ConsumeFixed64(data)
+		default:
+			v, m = protowire.ConsumeVarint(data)
+		}
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+
+		if n < len(array) {
+			array[n] = decode[T](v)
+		}
+		data = data[m:]
+	}
+
+	if n != 0 && n != len(array) {
+		return errArrayLen(len(array), n)
+	}
+	return nil
+}
+
+// wireType returns the wire type of the packed elements of type T.
+func wireType[T Number]() protowire.Type {
+	var zero T
+	switch any(zero).(type) {
+	case float32:
+		return protowire.Fixed32Type
+	case float64:
+		return protowire.Fixed64Type
+	}
+	return protowire.VarintType
+}
+
+// encode returns the bits of the encoding of the given element. The signed
+// integers are sign extended, as the int32 and int64 fields are.
+func encode[T Number](v T) uint64 {
+	switch v := any(v).(type) {
+	case bool:
+		return protowire.EncodeBool(v)
+	case int:
+		return uint64(v)
+	case int8:
+		return uint64(v)
+	case int16:
+		return uint64(v)
+	case int32:
+		return uint64(v)
+	case int64:
+		return uint64(v)
+	case uint:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case float32:
+		return uint64(math.Float32bits(v))
+	case float64:
+		return math.Float64bits(v)
+	}
+	return 0
+}
+
+// decode returns the element with the bits of the given encoding.
+func decode[T Number](u uint64) T {
+	var v any
+	switch any(*new(T)).(type) {
+	case bool:
+		v = protowire.DecodeBool(u)
+	case int:
+		v = int(u)
+	case int8:
+		v = int8(u)
+	case int16:
+		v = int16(u)
+	case int32:
+		v = int32(u)
+	case int64:
+		v = int64(u)
+	case uint:
+		v = uint(u)
+	case uint16:
+		v = uint16(u)
+	case uint32:
+		v = uint32(u)
+	case uint64:
+		v = u
+	case float32:
+		v = math.Float32frombits(uint32(u))
+	case float64:
+		v = math.Float64frombits(u)
+	}
+	return v.(T)
+}
+
+func errArrayLen(want, got int) error {
+	return fmt.Errorf("customtypes: the array has %d elements, but %d were decoded", want, got)
+}
@@ -0,0 +1,88 @@
+package customtypes
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytesArray(t *testing.T) {
+	require := require.New(t)
+
+	var array [4]byte
+	require.NoError(UnmarshalBytes(array[:], []byte{1, 2, 3, 4}))
+	require.Equal([4]byte{1, 2, 3, 4}, array)
+
+	buf := make([]byte, 4)
+	n, err := MarshalBytesTo(buf, array[:])
+	require.NoError(err)
+	require.Equal(4, n)
+	require.Equal([]byte{1, 2, 3, 4}, buf)
+
+	require.NoError(UnmarshalBytes(array[:], nil))
+	require.Equal([4]byte{}, array, "no bytes is the zero array")
+
+	require.Error(UnmarshalBytes(array[:], []byte{1, 2, 3}))
+	require.Error(UnmarshalBytes(array[:], []byte{1, 2, 3, 4, 5}))
+	_, err = MarshalBytesTo(make([]byte, 3), array[:])
+	require.Error(err)
+}
+
+func TestPackedArray(t *testing.T) {
+	require := require.New(t)
+
+	ints := [3]int32{1, -1, 300}
+	expected := []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0xac, 0x02}
+	require.Equal(expected, AppendPacked(nil, ints[:]))
+	require.Equal(len(expected), SizePacked(ints[:]))
+
+	buf := make([]byte, len(expected))
+	n, err := MarshalPackedTo(buf, ints[:])
+	require.NoError(err)
+	require.Equal(expected, buf[:n])
+	_, err = MarshalPackedTo(buf[:3], ints[:])
+	require.Error(err)
+
+	var decoded [3]int32
+	require.NoError(UnmarshalPacked(decoded[:], expected))
+	require.Equal(ints, decoded)
+
+	require.NoError(UnmarshalPacked(decoded[:], nil))
+	require.Equal([3]int32{}, decoded, "no elements is the zero array")
+	require.Error(UnmarshalPacked(decoded[:], expected[:1]), "too few elements")
+	require.Error(UnmarshalPacked(decoded[:], append(expected, 0x01)), "too many elements")
+	require.Error(UnmarshalPacked(decoded[:], expected[:2]), "truncated varint")
+}
+
+func TestPackedArrayTypes(t *testing.T) {
+	require := require.New(t)
+
+	floats := [2]float64{1, math.Inf(-1)}
+	require.Equal(16, SizePacked(floats[:]))
+	var decodedFloats [2]float64
+	require.NoError(UnmarshalPacked(decodedFloats[:], AppendPacked(nil, floats[:])))
+	require.Equal(floats, decodedFloats)
+
+	small := [2]float32{1.5, -2}
+	require.Equal([]byte{0, 0, 0xc0, 0x3f, 0, 0, 0, 0xc0}, AppendPacked(nil, small[:]))
+	var decodedSmall [2]float32
+	require.NoError(UnmarshalPacked(decodedSmall[:], AppendPacked(nil, small[:])))
+	require.Equal(small, decodedSmall)
+
+	bools := [3]bool{true, false, true}
+	require.Equal([]byte{1, 0, 1}, AppendPacked(nil, bools[:]))
+	var decodedBools [3]bool
+	require.NoError(UnmarshalPacked(decodedBools[:], []byte{1, 0, 1}))
+	require.Equal(bools, decodedBools)
+
+	int8s := [2]int8{-1, 127}
+	var decodedInt8s [2]int8
+	require.NoError(UnmarshalPacked(decodedInt8s[:], AppendPacked(nil, int8s[:])))
+	require.Equal(int8s, decodedInt8s)
+
+	uints := [2]uint64{math.MaxUint64, 0}
+	var decodedUints [2]uint64
+	require.NoError(UnmarshalPacked(decodedUints[:], AppendPacked(nil, uints[:])))
+	require.Equal(uints, decodedUints)
+}
@@ -0,0 +1,217 @@
+package customtypes
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// errInf is returned when an infinite float is marshaled, as a
+// google.type.Decimal can not hold it.
+var errInf = errors.New("customtypes: infinite floats can not be marshaled")
+
+// errDecimal is returned when a google.type.Decimal does not hold a number.
+var errDecimal = errors.New("customtypes: invalid decimal")
+
+// BigInt is a big.Int generated as a google.type.Decimal, so its digits are
+// sent as they are. Pointers to them are converted to and from each other
+// with a type conversion, like (*customtypes.BigInt)(x), and with Int.
+type BigInt big.Int
+
+// Int returns the big.Int of the integer, which shares its value.
+func (b *BigInt) Int() *big.Int {
+	return (*big.Int)(b)
+}
+
+func (b *BigInt) String() string {
+	return b.Int().String()
+}
+
+// Marshal returns the encoding of the integer as a google.type.Decimal.
+func (b *BigInt) Marshal() ([]byte, error) {
+	return appendDecimal(nil, b.String()), nil
+}
+
+// MarshalTo writes the encoding of the integer as a google.type.Decimal to
+// the given buffer, which must have room for it, and returns its size.
+func (b *BigInt) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendDecimal(data[:0], b.String()))
+}
+
+// Unmarshal decodes the integer from the encoding of a google.type.Decimal,
+// which must not have a fraction or an exponent.
+func (b *BigInt) Unmarshal(data []byte) error {
+	s, err := unmarshalDecimal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := b.Int().SetString(orZero(s), 10); !ok {
+		return errDecimal
+	}
+	return nil
+}
+
+// Size returns the size of the encoding of the integer.
+func (b *BigInt) Size() int {
+	return sizeDecimal(b.String())
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (b *BigInt) ProtoSize() int {
+	return b.Size()
+}
+
+// MarshalJSON encodes the integer as the JSON of a google.type.Decimal.
+func (b *BigInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(decimalJSON{Value: b.String()})
+}
+
+// UnmarshalJSON decodes the integer from the JSON of a google.type.Decimal.
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	var j decimalJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	if _, ok := b.Int().SetString(orZero(j.Value), 10); !ok {
+		return errDecimal
+	}
+	return nil
+}
+
+// BigFloat is a big.Float generated as a google.type.Decimal, with the
+// shortest decimal that has the same value at its precision, so a float
+// decoded with the same precision gets the same value. Pointers to
+// them are converted to and from each other with a type conversion, like
+// (*customtypes.BigFloat)(x), and with Float. Infinite floats can not be
+// marshaled.
+type BigFloat big.Float
+
+// Float returns the big.Float of the float, which shares its value.
+func (f *BigFloat) Float() *big.Float {
+	return (*big.Float)(f)
+}
+
+func (f *BigFloat) String() string {
+	return f.Float().Text('g', -1)
+}
+
+// Marshal returns the encoding of the float as a google.type.Decimal.
+func (f *BigFloat) Marshal() ([]byte, error) {
+	if f.Float().IsInf() {
+		return nil, errInf
+	}
+	return appendDecimal(nil, f.String()), nil
+}
+
+// MarshalTo writes the encoding of the float as a google.type.Decimal to
+// the given buffer, which must have room for it, and returns its size.
+func (f *BigFloat) MarshalTo(data []byte) (int, error) {
+	if f.Float().IsInf() {
+		return 0, errInf
+	}
+	return marshalTo(data, appendDecimal(data[:0], f.String()))
+}
+
+// Unmarshal decodes the float from the encoding of a google.type.Decimal.
+// If the float has no precision, it gets enough to hold all the digits of
+// the decimal.
+func (f *BigFloat) Unmarshal(data []byte) error {
+	s, err := unmarshalDecimal(data)
+	if err != nil {
+		return err
+	}
+	return f.parse(s)
+}
+
+func (f *BigFloat) parse(s string) error {
+	s = orZero(s)
+	if f.Float().Prec() == 0 {
+		f.Float().SetPrec(decimalPrec(s))
+	}
+
+	if _, _, err := f.Float().Parse(s, 10); err != nil || f.Float().IsInf() {
+		return errDecimal
+	}
+	return nil
+}
+
+// decimalPrec returns the precision in bits needed to hold the digits of the
+// given decimal, which is at least the one of a float64.
+func decimalPrec(s string) uint {
+	// Every digit needs log2(10) bits, which is less than 4.
+	prec := uint(len(s)) * 4
+	if prec < 53 {
+		return 53
+	}
+	return prec
+}
+
+// Size returns the size of the encoding of the float.
+func (f *BigFloat) Size() int {
+	return sizeDecimal(f.String())
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (f *BigFloat) ProtoSize() int {
+	return f.Size()
+}
+
+// MarshalJSON encodes the float as the JSON of a google.type.Decimal.
+func (f *BigFloat) MarshalJSON() ([]byte, error) {
+	if f.Float().IsInf() {
+		return nil, errInf
+	}
+	return json.Marshal(decimalJSON{Value: f.String()})
+}
+
+// UnmarshalJSON decodes the float from the JSON of a google.type.Decimal.
+func (f *BigFloat) UnmarshalJSON(data []byte) error {
+	var j decimalJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return f.parse(j.Value)
+}
+
+type decimalJSON struct {
+	Value string `json:"value"`
+}
+
+// appendDecimal appends the value field of a google.type.Decimal.
+func appendDecimal(b []byte, s string) []byte {
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// sizeDecimal returns the size of the encoding of appendDecimal.
+func sizeDecimal(s string) int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(len(s))
+}
+
+// unmarshalDecimal decodes the value field of a google.type.Decimal.
+func unmarshalDecimal(data []byte) (string, error) {
+	var s string
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if num != 1 || typ != protowire.BytesType {
+			return 0
+		}
+
+		var m int
+		s, m = protowire.ConsumeString(data)
+		return m
+	})
+	return s, err
+}
+
+// orZero returns the given decimal, or 0 if it is empty, which is the
+// default of a google.type.Decimal.
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
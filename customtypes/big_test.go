@@ -0,0 +1,73 @@
+package customtypes
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBigInt(t *testing.T) {
+	require := require.New(t)
+
+	x, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	b := (*BigInt)(x)
+	requireEncoding(t, append([]byte{0x0a, 31}, "-123456789012345678901234567890"...), b)
+	requireEncoding(t, []byte{0x0a, 0x01, '0'}, new(BigInt))
+
+	data, err := b.Marshal()
+	require.NoError(err)
+	var decoded BigInt
+	require.NoError(decoded.Unmarshal(data))
+	require.Equal(0, x.Cmp(decoded.Int()))
+
+	require.NoError(decoded.Unmarshal(nil))
+	require.Equal(0, decoded.Int().Sign(), "an empty decimal is zero")
+	require.Error(decoded.Unmarshal(appendDecimal(nil, "1.5")))
+
+	j, err := json.Marshal(b)
+	require.NoError(err)
+	require.Equal(`{"value":"-123456789012345678901234567890"}`, string(j))
+	require.NoError(json.Unmarshal(j, &decoded))
+	require.Equal(0, x.Cmp(decoded.Int()))
+}
+
+func TestBigFloat(t *testing.T) {
+	require := require.New(t)
+
+	x, _, err := big.ParseFloat("3.14159265358979323846264338327950288", 10, 200, big.ToNearestEven)
+	require.NoError(err)
+	f := (*BigFloat)(x)
+	requireEncoding(t, appendDecimal(nil, f.String()), f)
+	requireEncoding(t, appendDecimal(nil, "1.5"), (*BigFloat)(big.NewFloat(1.5)))
+
+	data, err := f.Marshal()
+	require.NoError(err)
+	var decoded BigFloat
+	decoded.Float().SetPrec(200)
+	require.NoError(decoded.Unmarshal(data))
+	require.Equal(0, x.Cmp(decoded.Float()), "a float with the same precision gets the same value")
+
+	decoded = BigFloat{}
+	require.NoError(decoded.Unmarshal(data))
+	require.Equal("3.14159265358979323846264338327950288", decoded.String(), "the precision is enough for all the digits")
+
+	decoded = BigFloat{}
+	require.NoError(decoded.Unmarshal(appendDecimal(nil, "-2.5e-3")))
+	require.Equal("-0.0025", decoded.Float().Text('f', -1))
+	require.Error(decoded.Unmarshal(appendDecimal(nil, "abc")))
+
+	inf := (*BigFloat)(new(big.Float).SetInf(false))
+	_, err = inf.Marshal()
+	require.Error(err)
+	_, err = inf.MarshalTo(make([]byte, 10))
+	require.Error(err)
+
+	j, err := json.Marshal((*BigFloat)(big.NewFloat(1.5)))
+	require.NoError(err)
+	require.Equal(`{"value":"1.5"}`, string(j))
+	decoded = BigFloat{}
+	require.NoError(json.Unmarshal(j, &decoded))
+	require.Equal("1.5", decoded.String())
+}
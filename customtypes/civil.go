@@ -0,0 +1,270 @@
+package customtypes
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Date is a date without a time or a time zone, with the same fields as
+// civil.Date. It is generated as a google.type.Date.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the date of the given time in its location.
+func DateOf(t time.Time) Date {
+	var d Date
+	d.Year, d.Month, d.Day = t.Date()
+	return d
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// In returns the time of the start of the date in the given location.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+func (d Date) ints() []int64 {
+	return []int64{int64(d.Year), int64(d.Month), int64(d.Day)}
+}
+
+func (d *Date) setInts(v []int64) {
+	d.Year, d.Month, d.Day = int(v[0]), time.Month(v[1]), int(v[2])
+}
+
+// Marshal returns the encoding of the date as a google.type.Date.
+func (d Date) Marshal() ([]byte, error) {
+	return appendInts(nil, d.ints()...), nil
+}
+
+// MarshalTo writes the encoding of the date as a google.type.Date to the
+// given buffer, which must have room for it, and returns its size.
+func (d Date) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendInts(data[:0], d.ints()...))
+}
+
+// Unmarshal decodes the date from the encoding of a google.type.Date.
+func (d *Date) Unmarshal(data []byte) error {
+	v, err := unmarshalInts(data, 3)
+	if err != nil {
+		return err
+	}
+
+	d.setInts(v)
+	return nil
+}
+
+// Size returns the size of the encoding of the date.
+func (d Date) Size() int {
+	return sizeInts(d.ints()...)
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (d Date) ProtoSize() int {
+	return d.Size()
+}
+
+// MarshalJSON encodes the date as the JSON of a google.type.Date.
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dateJSON{Year: d.Year, Month: int(d.Month), Day: d.Day})
+}
+
+// UnmarshalJSON decodes the date from the JSON of a google.type.Date.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var j dateJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	*d = Date{Year: j.Year, Month: time.Month(j.Month), Day: j.Day}
+	return nil
+}
+
+type dateJSON struct {
+	Year  int `json:"year,omitempty"`
+	Month int `json:"month,omitempty"`
+	Day   int `json:"day,omitempty"`
+}
+
+// Time is a time of the day without a date or a time zone, with the same
+// fields as civil.Time. It is generated as a google.type.TimeOfDay.
+type Time struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
+
+// TimeOf returns the time of the day of the given time in its location.
+func TimeOf(t time.Time) Time {
+	var tm Time
+	tm.Hour, tm.Minute, tm.Second = t.Clock()
+	tm.Nanosecond = t.Nanosecond()
+	return tm
+}
+
+func (t Time) String() string {
+	s := fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	if t.Nanosecond == 0 {
+		return s
+	}
+	return s + fmt.Sprintf(".%09d", t.Nanosecond)
+}
+
+func (t Time) ints() []int64 {
+	return []int64{int64(t.Hour), int64(t.Minute), int64(t.Second), int64(t.Nanosecond)}
+}
+
+func (t *Time) setInts(v []int64) {
+	t.Hour, t.Minute, t.Second, t.Nanosecond = int(v[0]), int(v[1]), int(v[2]), int(v[3])
+}
+
+// Marshal returns the encoding of the time as a google.type.TimeOfDay.
+func (t Time) Marshal() ([]byte, error) {
+	return appendInts(nil, t.ints()...), nil
+}
+
+// MarshalTo writes the encoding of the time as a google.type.TimeOfDay to
+// the given buffer, which must have room for it, and returns its size.
+func (t Time) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendInts(data[:0], t.ints()...))
+}
+
+// Unmarshal decodes the time from the encoding of a google.type.TimeOfDay.
+func (t *Time) Unmarshal(data []byte) error {
+	v, err := unmarshalInts(data, 4)
+	if err != nil {
+		return err
+	}
+
+	t.setInts(v)
+	return nil
+}
+
+// Size returns the size of the encoding of the time.
+func (t Time) Size() int {
+	return sizeInts(t.ints()...)
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (t Time) ProtoSize() int {
+	return t.Size()
+}
+
+// MarshalJSON encodes the time as the JSON of a google.type.TimeOfDay.
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timeJSON{Hours: t.Hour, Minutes: t.Minute, Seconds: t.Second, Nanos: t.Nanosecond})
+}
+
+// UnmarshalJSON decodes the time from the JSON of a google.type.TimeOfDay.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	var j timeJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	*t = Time{Hour: j.Hours, Minute: j.Minutes, Second: j.Seconds, Nanosecond: j.Nanos}
+	return nil
+}
+
+type timeJSON struct {
+	Hours   int `json:"hours,omitempty"`
+	Minutes int `json:"minutes,omitempty"`
+	Seconds int `json:"seconds,omitempty"`
+	Nanos   int `json:"nanos,omitempty"`
+}
+
+// DateTime is a date and a time of the day without a time zone, like
+// civil.DateTime, which is converted with the conversions of its fields. It
+// is generated as a google.type.DateTime without a time offset.
+type DateTime struct {
+	Date Date
+	Time Time
+}
+
+// DateTimeOf returns the date and time of the given time in its location.
+func DateTimeOf(t time.Time) DateTime {
+	return DateTime{Date: DateOf(t), Time: TimeOf(t)}
+}
+
+func (dt DateTime) String() string {
+	return dt.Date.String() + "T" + dt.Time.String()
+}
+
+// In returns the time of the date and time in the given location.
+func (dt DateTime) In(loc *time.Location) time.Time {
+	return time.Date(dt.Date.Year, dt.Date.Month, dt.Date.Day, dt.Time.Hour, dt.Time.Minute, dt.Time.Second, dt.Time.Nanosecond, loc)
+}
+
+func (dt DateTime) ints() []int64 {
+	return append(dt.Date.ints(), dt.Time.ints()...)
+}
+
+// Marshal returns the encoding of the date and time as a google.type.DateTime.
+func (dt DateTime) Marshal() ([]byte, error) {
+	return appendInts(nil, dt.ints()...), nil
+}
+
+// MarshalTo writes the encoding of the date and time as a
+// google.type.DateTime to the given buffer, which must have room for it, and
+// returns its size.
+func (dt DateTime) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendInts(data[:0], dt.ints()...))
+}
+
+// Unmarshal decodes the date and time from the encoding of a
+// google.type.DateTime. Its time offset, if any, is ignored.
+func (dt *DateTime) Unmarshal(data []byte) error {
+	v, err := unmarshalInts(data, 7)
+	if err != nil {
+		return err
+	}
+
+	dt.Date.setInts(v[:3])
+	dt.Time.setInts(v[3:])
+	return nil
+}
+
+// Size returns the size of the encoding of the date and time.
+func (dt DateTime) Size() int {
+	return sizeInts(dt.ints()...)
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (dt DateTime) ProtoSize() int {
+	return dt.Size()
+}
+
+// MarshalJSON encodes the date and time as the JSON of a google.type.DateTime.
+func (dt DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dateTimeJSON{
+		dateJSON{Year: dt.Date.Year, Month: int(dt.Date.Month), Day: dt.Date.Day},
+		timeJSON{Hours: dt.Time.Hour, Minutes: dt.Time.Minute, Seconds: dt.Time.Second, Nanos: dt.Time.Nanosecond},
+	})
+}
+
+// UnmarshalJSON decodes the date and time from the JSON of a
+// google.type.DateTime. Its time offset, if any, is ignored.
+func (dt *DateTime) UnmarshalJSON(data []byte) error {
+	var j dateTimeJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	*dt = DateTime{
+		Date: Date{Year: j.Year, Month: time.Month(j.Month), Day: j.Day},
+		Time: Time{Hour: j.Hours, Minute: j.Minutes, Second: j.Seconds, Nanosecond: j.Nanos},
+	}
+	return nil
+}
+
+type dateTimeJSON struct {
+	dateJSON
+	timeJSON
+}
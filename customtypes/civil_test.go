@@ -0,0 +1,109 @@
+package customtypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// marshaler is implemented by all the custom types.
+type marshaler interface {
+	Marshal() ([]byte, error)
+	MarshalTo([]byte) (int, error)
+	Size() int
+	ProtoSize() int
+}
+
+// requireEncoding checks that the given value is encoded as expected with
+// all the marshaling methods.
+func requireEncoding(t *testing.T, expected []byte, m marshaler) {
+	require := require.New(t)
+
+	b, err := m.Marshal()
+	require.NoError(err)
+	require.Equal(expected, b)
+	require.Equal(len(expected), m.Size())
+	require.Equal(len(expected), m.ProtoSize())
+
+	buf := make([]byte, m.Size()+1)
+	n, err := m.MarshalTo(buf[1:])
+	require.NoError(err)
+	require.Equal(len(expected), n)
+	require.True(bytes.Equal(expected, buf[1:1+n]))
+}
+
+func TestDate(t *testing.T) {
+	require := require.New(t)
+
+	d := Date{Year: 2024, Month: time.February, Day: 29}
+	requireEncoding(t, []byte{0x08, 0xe8, 0x0f, 0x10, 0x02, 0x18, 0x1d}, d)
+	requireEncoding(t, nil, Date{})
+
+	var decoded Date
+	b, _ := d.Marshal()
+	require.NoError(decoded.Unmarshal(b))
+	require.Equal(d, decoded)
+	require.Equal("2024-02-29", d.String())
+
+	require.Equal(d, DateOf(time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC)))
+	require.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), d.In(time.UTC))
+
+	j, err := json.Marshal(d)
+	require.NoError(err)
+	require.JSONEq(`{"year": 2024, "month": 2, "day": 29}`, string(j))
+	decoded = Date{}
+	require.NoError(json.Unmarshal(j, &decoded))
+	require.Equal(d, decoded)
+
+	require.Error(decoded.Unmarshal([]byte{0x08}))
+}
+
+func TestTime(t *testing.T) {
+	require := require.New(t)
+
+	tm := Time{Hour: 13, Minute: 5, Nanosecond: 1}
+	requireEncoding(t, []byte{0x08, 0x0d, 0x10, 0x05, 0x20, 0x01}, tm)
+
+	var decoded Time
+	b, _ := tm.Marshal()
+	require.NoError(decoded.Unmarshal(b))
+	require.Equal(tm, decoded)
+	require.Equal("13:05:00.000000001", tm.String())
+	require.Equal("13:05:00", Time{Hour: 13, Minute: 5}.String())
+	require.Equal(tm, TimeOf(time.Date(2024, 2, 29, 13, 5, 0, 1, time.UTC)))
+
+	j, err := json.Marshal(tm)
+	require.NoError(err)
+	require.JSONEq(`{"hours": 13, "minutes": 5, "nanos": 1}`, string(j))
+	decoded = Time{}
+	require.NoError(json.Unmarshal(j, &decoded))
+	require.Equal(tm, decoded)
+}
+
+func TestDateTime(t *testing.T) {
+	require := require.New(t)
+
+	at := time.Date(2024, 2, 29, 13, 5, 7, 0, time.UTC)
+	dt := DateTimeOf(at)
+	require.Equal(DateTime{Date: Date{Year: 2024, Month: time.February, Day: 29}, Time: Time{Hour: 13, Minute: 5, Second: 7}}, dt)
+	requireEncoding(t, []byte{0x08, 0xe8, 0x0f, 0x10, 0x02, 0x18, 0x1d, 0x20, 0x0d, 0x28, 0x05, 0x30, 0x07}, dt)
+	require.Equal(at, dt.In(time.UTC))
+	require.Equal("2024-02-29T13:05:07", dt.String())
+
+	var decoded DateTime
+	b, _ := dt.Marshal()
+	// A utc_offset is ignored.
+	b = append(b, 0x42, 0x02, 0x08, 0x3c)
+	require.NoError(decoded.Unmarshal(b))
+	require.Equal(dt, decoded)
+
+	j, err := json.Marshal(dt)
+	require.NoError(err)
+	require.JSONEq(`{"year": 2024, "month": 2, "day": 29, "hours": 13, "minutes": 5, "seconds": 7}`, string(j))
+	decoded = DateTime{}
+	require.NoError(json.Unmarshal(j, &decoded))
+	require.Equal(dt, decoded)
+}
@@ -0,0 +1,92 @@
+// Package customtypes has types that hold the values of well-known Go types
+// the generated code can not marshal, like the dates of
+// cloud.google.com/go/civil, the nullable types of database/sql, UUIDs and
+// arbitrary-precision numbers. They implement the methods required by the
+// gogoproto.customtype option, so the structs of the scanned packages can
+// have fields of these types, which are generated as fields of the
+// well-known protobuf types with the same values, like google.type.Date or
+// google.protobuf.StringValue.
+//
+// The types have the same fields or underlying type as the ones they hold,
+// so they are converted to and from them with a type conversion, like
+// sql.NullString(s) or customtypes.NullString(s).
+package customtypes // import "gitlab.com/ThatTomPerson/proteus/customtypes"
+
+import (
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// marshalTo writes the given encoding to dst, which must have room for it,
+// as the generated code allocates the size of the message beforehand.
+func marshalTo(dst, encoded []byte) (int, error) {
+	if len(dst) < len(encoded) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(dst, encoded), nil
+}
+
+// appendInts appends the given values as the varint fields numbered from 1
+// in order. Zero values are not written, as in proto3.
+func appendInts(b []byte, values ...int64) []byte {
+	for i, v := range values {
+		if v != 0 {
+			b = protowire.AppendTag(b, protowire.Number(i+1), protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v))
+		}
+	}
+	return b
+}
+
+// sizeInts returns the size of the encoding of appendInts.
+func sizeInts(values ...int64) int {
+	var n int
+	for i, v := range values {
+		if v != 0 {
+			n += protowire.SizeTag(protowire.Number(i+1)) + protowire.SizeVarint(uint64(v))
+		}
+	}
+	return n
+}
+
+// unmarshalInts decodes the varint fields numbered from 1 to n of the given
+// message, which are int32, as in the messages of google.type. Other fields
+// are skipped.
+func unmarshalInts(data []byte, n int) ([]int64, error) {
+	values := make([]int64, n)
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if int(num) > n || typ != protowire.VarintType {
+			return 0
+		}
+
+		v, m := protowire.ConsumeVarint(data)
+		values[num-1] = int64(int32(v))
+		return m
+	})
+	return values, err
+}
+
+// walkFields calls fn with the number, type and data of every field of the
+// given message, starting with the value of the field. fn returns the length
+// of the value it consumed, a negative protowire error code if it is invalid
+// or 0 to skip it.
+func walkFields(data []byte, fn func(protowire.Number, protowire.Type, []byte) int) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		n = fn(num, typ, data)
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package customtypes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestInts(t *testing.T) {
+	require := require.New(t)
+
+	b := appendInts(nil, 1, 0, -1)
+	require.Equal(sizeInts(1, 0, -1), len(b))
+	require.Equal([]byte{0x08, 0x01, 0x18, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, b)
+
+	v, err := unmarshalInts(b, 3)
+	require.NoError(err)
+	require.Equal([]int64{1, 0, -1}, v)
+
+	v, err = unmarshalInts(b, 1)
+	require.NoError(err)
+	require.Equal([]int64{1}, v, "other fields are skipped")
+
+	_, err = unmarshalInts(b[:len(b)-1], 3)
+	require.Error(err)
+}
+
+func TestWalkFieldsSkipsUnknownFields(t *testing.T) {
+	require := require.New(t)
+
+	b := protowire.AppendTag(nil, 9, protowire.BytesType)
+	b = protowire.AppendString(b, "foo")
+	b = appendInts(b, 0, 42)
+
+	v, err := unmarshalInts(b, 2)
+	require.NoError(err)
+	require.Equal([]int64{0, 42}, v)
+}
+
+func TestMarshalTo(t *testing.T) {
+	require := require.New(t)
+
+	n, err := marshalTo(make([]byte, 3), []byte{1, 2})
+	require.NoError(err)
+	require.Equal(2, n)
+
+	_, err = marshalTo(make([]byte, 1), []byte{1, 2})
+	require.Error(err)
+}
@@ -0,0 +1,613 @@
+package customtypes
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The nullable types have the same fields as the ones of database/sql, and
+// they are generated as the wrappers of google.protobuf with the same
+// values, or as a google.protobuf.Timestamp for NullTime. The value of a
+// valid one is always written, even if it is zero, and a null one is an
+// empty wrapper, so they keep being null or valid when they are sent to
+// other Go programs. Other clients see null values as wrappers with the
+// zero value instead, unless the fields are pointers, which are not sent
+// if they are nil. They also implement sql.Scanner and driver.Valuer, so
+// they can be used with database/sql directly.
+
+// NullString is a sql.NullString generated as a google.protobuf.StringValue.
+type NullString struct {
+	String string
+	Valid  bool
+}
+
+func (n NullString) appendValue(b []byte) []byte {
+	if !n.Valid {
+		return b
+	}
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendString(b, n.String)
+}
+
+// Marshal returns the encoding of the string as a google.protobuf.StringValue.
+func (n NullString) Marshal() ([]byte, error) {
+	return n.appendValue(nil), nil
+}
+
+// MarshalTo writes the encoding of the string as a
+// google.protobuf.StringValue to the given buffer, which must have room for
+// it, and returns its size.
+func (n NullString) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, n.appendValue(data[:0]))
+}
+
+// Unmarshal decodes the string from the encoding of a
+// google.protobuf.StringValue, which is null if it is empty.
+func (n *NullString) Unmarshal(data []byte) error {
+	*n = NullString{Valid: len(data) > 0}
+	return walkFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if num != 1 || typ != protowire.BytesType {
+			return 0
+		}
+
+		v, m := protowire.ConsumeString(data)
+		n.String = v
+		return m
+	})
+}
+
+// Size returns the size of the encoding of the string.
+func (n NullString) Size() int {
+	if !n.Valid {
+		return 0
+	}
+	return protowire.SizeTag(1) + protowire.SizeBytes(len(n.String))
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (n NullString) ProtoSize() int {
+	return n.Size()
+}
+
+// MarshalJSON encodes the string, or null if it is not valid.
+func (n NullString) MarshalJSON() ([]byte, error) {
+	return marshalNullJSON(n.Valid, n.String)
+}
+
+// UnmarshalJSON decodes the string, which is not valid if it is null.
+func (n *NullString) UnmarshalJSON(data []byte) (err error) {
+	*n = NullString{}
+	n.Valid, err = unmarshalNullJSON(data, &n.String)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullString) Scan(value interface{}) error {
+	return (*sql.NullString)(n).Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (n NullString) Value() (driver.Value, error) {
+	return sql.NullString(n).Value()
+}
+
+// NullInt64 is a sql.NullInt64 generated as a google.protobuf.Int64Value.
+type NullInt64 struct {
+	Int64 int64
+	Valid bool
+}
+
+// Marshal returns the encoding of the integer as a
+// google.protobuf.Int64Value.
+func (n NullInt64) Marshal() ([]byte, error) {
+	return appendVarintValue(nil, n.Valid, uint64(n.Int64)), nil
+}
+
+// MarshalTo writes the encoding of the integer as a
+// google.protobuf.Int64Value to the given buffer, which must have room for
+// it, and returns its size.
+func (n NullInt64) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendVarintValue(data[:0], n.Valid, uint64(n.Int64)))
+}
+
+// Unmarshal decodes the integer from the encoding of a
+// google.protobuf.Int64Value, which is null if it is empty.
+func (n *NullInt64) Unmarshal(data []byte) error {
+	v, err := unmarshalVarintValue(data)
+	*n = NullInt64{Int64: int64(v), Valid: len(data) > 0}
+	return err
+}
+
+// Size returns the size of the encoding of the integer.
+func (n NullInt64) Size() int {
+	return sizeVarintValue(n.Valid, uint64(n.Int64))
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (n NullInt64) ProtoSize() int {
+	return n.Size()
+}
+
+// MarshalJSON encodes the integer, or null if it is not valid.
+func (n NullInt64) MarshalJSON() ([]byte, error) {
+	return marshalNullJSON(n.Valid, n.Int64)
+}
+
+// UnmarshalJSON decodes the integer, which is not valid if it is null.
+func (n *NullInt64) UnmarshalJSON(data []byte) (err error) {
+	*n = NullInt64{}
+	n.Valid, err = unmarshalNullJSON(data, &n.Int64)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullInt64) Scan(value interface{}) error {
+	return (*sql.NullInt64)(n).Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (n NullInt64) Value() (driver.Value, error) {
+	return sql.NullInt64(n).Value()
+}
+
+// NullInt32 is a sql.NullInt32 generated as a google.protobuf.Int32Value.
+type NullInt32 struct {
+	Int32 int32
+	Valid bool
+}
+
+// Marshal returns the encoding of the integer as a
+// google.protobuf.Int32Value.
+func (n NullInt32) Marshal() ([]byte, error) {
+	return appendVarintValue(nil, n.Valid, uint64(n.Int32)), nil
+}
+
+// MarshalTo writes the encoding of the integer as a
+// google.protobuf.Int32Value to the given buffer, which must have room for
+// it, and returns its size.
+func (n NullInt32) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendVarintValue(data[:0], n.Valid, uint64(n.Int32)))
+}
+
+// Unmarshal decodes the integer from the encoding of a
+// google.protobuf.Int32Value, which is null if it is empty.
+func (n *NullInt32) Unmarshal(data []byte) error {
+	v, err := unmarshalVarintValue(data)
+	*n = NullInt32{Int32: int32(v), Valid: len(data) > 0}
+	return err
+}
+
+// Size returns the size of the encoding of the integer.
+func (n NullInt32) Size() int {
+	return sizeVarintValue(n.Valid, uint64(n.Int32))
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (n NullInt32) ProtoSize() int {
+	return n.Size()
+}
+
+// MarshalJSON encodes the integer, or null if it is not valid.
+func (n NullInt32) MarshalJSON() ([]byte, error) {
+	return marshalNullJSON(n.Valid, n.Int32)
+}
+
+// UnmarshalJSON decodes the integer, which is not valid if it is null.
+func (n *NullInt32) UnmarshalJSON(data []byte) (err error) {
+	*n = NullInt32{}
+	n.Valid, err = unmarshalNullJSON(data, &n.Int32)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullInt32) Scan(value interface{}) error {
+	return (*sql.NullInt32)(n).Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (n NullInt32) Value() (driver.Value, error) {
+	return sql.NullInt32(n).Value()
+}
+
+// NullInt16 is a sql.NullInt16 generated as a google.protobuf.Int32Value.
+// The values that do not fit in an int16 are truncated when they are
+// decoded.
+type NullInt16 struct {
+	Int16 int16
+	Valid bool
+}
+
+// Marshal returns the encoding of the integer as a
+// google.protobuf.Int32Value.
+func (n NullInt16) Marshal() ([]byte, error) {
+	return appendVarintValue(nil, n.Valid, uint64(n.Int16)), nil
+}
+
+// MarshalTo writes the encoding of the integer as a
+// google.protobuf.Int32Value to the given buffer, which must have room for
+// it, and returns its size.
+func (n NullInt16) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendVarintValue(data[:0], n.Valid, uint64(n.Int16)))
+}
+
+// Unmarshal decodes the integer from the encoding of a
+// google.protobuf.Int32Value, which is null if it is empty.
+func (n *NullInt16) Unmarshal(data []byte) error {
+	v, err := unmarshalVarintValue(data)
+	*n = NullInt16{Int16: int16(v), Valid: len(data) > 0}
+	return err
+}
+
+// Size returns the size of the encoding of the integer.
+func (n NullInt16) Size() int {
+	return sizeVarintValue(n.Valid, uint64(n.Int16))
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (n NullInt16) ProtoSize() int {
+	return n.Size()
+}
+
+// MarshalJSON encodes the integer, or null if it is not valid.
+func (n NullInt16) MarshalJSON() ([]byte, error) {
+	return marshalNullJSON(n.Valid, n.Int16)
+}
+
+// UnmarshalJSON decodes the integer, which is not valid if it is null.
+func (n *NullInt16) UnmarshalJSON(data []byte) (err error) {
+	*n = NullInt16{}
+	n.Valid, err = unmarshalNullJSON(data, &n.Int16)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullInt16) Scan(value interface{}) error {
+	return (*sql.NullInt16)(n).Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (n NullInt16) Value() (driver.Value, error) {
+	return sql.NullInt16(n).Value()
+}
+
+// NullByte is a sql.NullByte generated as a google.protobuf.UInt32Value. The
+// values that do not fit in a byte are truncated when they are decoded.
+type NullByte struct {
+	Byte  byte
+	Valid bool
+}
+
+// Marshal returns the encoding of the byte as a google.protobuf.UInt32Value.
+func (n NullByte) Marshal() ([]byte, error) {
+	return appendVarintValue(nil, n.Valid, uint64(n.Byte)), nil
+}
+
+// MarshalTo writes the encoding of the byte as a google.protobuf.UInt32Value
+// to the given buffer, which must have room for it, and returns its size.
+func (n NullByte) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendVarintValue(data[:0], n.Valid, uint64(n.Byte)))
+}
+
+// Unmarshal decodes the byte from the encoding of a
+// google.protobuf.UInt32Value, which is null if it is empty.
+func (n *NullByte) Unmarshal(data []byte) error {
+	v, err := unmarshalVarintValue(data)
+	*n = NullByte{Byte: byte(v), Valid: len(data) > 0}
+	return err
+}
+
+// Size returns the size of the encoding of the byte.
+func (n NullByte) Size() int {
+	return sizeVarintValue(n.Valid, uint64(n.Byte))
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (n NullByte) ProtoSize() int {
+	return n.Size()
+}
+
+// MarshalJSON encodes the byte as a number, or null if it is not valid.
+func (n NullByte) MarshalJSON() ([]byte, error) {
+	return marshalNullJSON(n.Valid, n.Byte)
+}
+
+// UnmarshalJSON decodes the byte from a number, which is not valid if it is
+// null.
+func (n *NullByte) UnmarshalJSON(data []byte) (err error) {
+	*n = NullByte{}
+	n.Valid, err = unmarshalNullJSON(data, &n.Byte)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullByte) Scan(value interface{}) error {
+	return (*sql.NullByte)(n).Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (n NullByte) Value() (driver.Value, error) {
+	return sql.NullByte(n).Value()
+}
+
+// NullFloat64 is a sql.NullFloat64 generated as a
+// google.protobuf.DoubleValue.
+type NullFloat64 struct {
+	Float64 float64
+	Valid   bool
+}
+
+func (n NullFloat64) appendValue(b []byte) []byte {
+	if !n.Valid {
+		return b
+	}
+	b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(n.Float64))
+}
+
+// Marshal returns the encoding of the float as a google.protobuf.DoubleValue.
+func (n NullFloat64) Marshal() ([]byte, error) {
+	return n.appendValue(nil), nil
+}
+
+// MarshalTo writes the encoding of the float as a
+// google.protobuf.DoubleValue to the given buffer, which must have room for
+// it, and returns its size.
+func (n NullFloat64) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, n.appendValue(data[:0]))
+}
+
+// Unmarshal decodes the float from the encoding of a
+// google.protobuf.DoubleValue, which is null if it is empty.
+func (n *NullFloat64) Unmarshal(data []byte) error {
+	*n = NullFloat64{Valid: len(data) > 0}
+	return walkFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if num != 1 || typ != protowire.Fixed64Type {
+			return 0
+		}
+
+		v, m := protowire.ConsumeFixed64(data)
+		n.Float64 = math.Float64frombits(v)
+		return m
+	})
+}
+
+// Size returns the size of the encoding of the float.
+func (n NullFloat64) Size() int {
+	if !n.Valid {
+		return 0
+	}
+	return protowire.SizeTag(1) + protowire.SizeFixed64()
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (n NullFloat64) ProtoSize() int {
+	return n.Size()
+}
+
+// MarshalJSON encodes the float, or null if it is not valid.
+func (n NullFloat64) MarshalJSON() ([]byte, error) {
+	return marshalNullJSON(n.Valid, n.Float64)
+}
+
+// UnmarshalJSON decodes the float, which is not valid if it is null.
+func (n *NullFloat64) UnmarshalJSON(data []byte) (err error) {
+	*n = NullFloat64{}
+	n.Valid, err = unmarshalNullJSON(data, &n.Float64)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullFloat64) Scan(value interface{}) error {
+	return (*sql.NullFloat64)(n).Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (n NullFloat64) Value() (driver.Value, error) {
+	return sql.NullFloat64(n).Value()
+}
+
+// NullBool is a sql.NullBool generated as a google.protobuf.BoolValue.
+type NullBool struct {
+	Bool  bool
+	Valid bool
+}
+
+// Marshal returns the encoding of the bool as a google.protobuf.BoolValue.
+func (n NullBool) Marshal() ([]byte, error) {
+	return appendVarintValue(nil, n.Valid, protowire.EncodeBool(n.Bool)), nil
+}
+
+// MarshalTo writes the encoding of the bool as a google.protobuf.BoolValue
+// to the given buffer, which must have room for it, and returns its size.
+func (n NullBool) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, appendVarintValue(data[:0], n.Valid, protowire.EncodeBool(n.Bool)))
+}
+
+// Unmarshal decodes the bool from the encoding of a
+// google.protobuf.BoolValue, which is null if it is empty.
+func (n *NullBool) Unmarshal(data []byte) error {
+	v, err := unmarshalVarintValue(data)
+	*n = NullBool{Bool: protowire.DecodeBool(v), Valid: len(data) > 0}
+	return err
+}
+
+// Size returns the size of the encoding of the bool.
+func (n NullBool) Size() int {
+	return sizeVarintValue(n.Valid, protowire.EncodeBool(n.Bool))
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (n NullBool) ProtoSize() int {
+	return n.Size()
+}
+
+// MarshalJSON encodes the bool, or null if it is not valid.
+func (n NullBool) MarshalJSON() ([]byte, error) {
+	return marshalNullJSON(n.Valid, n.Bool)
+}
+
+// UnmarshalJSON decodes the bool, which is not valid if it is null.
+func (n *NullBool) UnmarshalJSON(data []byte) (err error) {
+	*n = NullBool{}
+	n.Valid, err = unmarshalNullJSON(data, &n.Bool)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullBool) Scan(value interface{}) error {
+	return (*sql.NullBool)(n).Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (n NullBool) Value() (driver.Value, error) {
+	return sql.NullBool(n).Value()
+}
+
+// NullTime is a sql.NullTime generated as a google.protobuf.Timestamp. The
+// location of the time is not sent, so it is decoded in UTC.
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (n NullTime) appendValue(b []byte) []byte {
+	if !n.Valid {
+		return b
+	}
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(n.Time.Unix()))
+	if nanos := n.Time.Nanosecond(); nanos != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(nanos))
+	}
+	return b
+}
+
+// Marshal returns the encoding of the time as a google.protobuf.Timestamp.
+func (n NullTime) Marshal() ([]byte, error) {
+	return n.appendValue(nil), nil
+}
+
+// MarshalTo writes the encoding of the time as a google.protobuf.Timestamp
+// to the given buffer, which must have room for it, and returns its size.
+func (n NullTime) MarshalTo(data []byte) (int, error) {
+	return marshalTo(data, n.appendValue(data[:0]))
+}
+
+// Unmarshal decodes the time from the encoding of a
+// google.protobuf.Timestamp, which is null if it is empty.
+func (n *NullTime) Unmarshal(data []byte) error {
+	var seconds, nanos int64
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if num > 2 || typ != protowire.VarintType {
+			return 0
+		}
+
+		v, m := protowire.ConsumeVarint(data)
+		if num == 1 {
+			seconds = int64(v)
+		} else {
+			nanos = int64(int32(v))
+		}
+		return m
+	})
+	if err != nil {
+		return err
+	}
+
+	*n = NullTime{Valid: len(data) > 0}
+	if n.Valid {
+		n.Time = time.Unix(seconds, nanos).UTC()
+	}
+	return nil
+}
+
+// Size returns the size of the encoding of the time.
+func (n NullTime) Size() int {
+	return len(n.appendValue(nil))
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (n NullTime) ProtoSize() int {
+	return n.Size()
+}
+
+// MarshalJSON encodes the time in RFC 3339 format, or null if it is not
+// valid.
+func (n NullTime) MarshalJSON() ([]byte, error) {
+	return marshalNullJSON(n.Valid, n.Time)
+}
+
+// UnmarshalJSON decodes the time in RFC 3339 format, which is not valid if
+// it is null.
+func (n *NullTime) UnmarshalJSON(data []byte) (err error) {
+	*n = NullTime{}
+	n.Valid, err = unmarshalNullJSON(data, &n.Time)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullTime) Scan(value interface{}) error {
+	return (*sql.NullTime)(n).Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (n NullTime) Value() (driver.Value, error) {
+	return sql.NullTime(n).Value()
+}
+
+// appendVarintValue appends the varint value field of a wrapper if it is
+// valid, even if it is zero.
+func appendVarintValue(b []byte, valid bool, v uint64) []byte {
+	if !valid {
+		return b
+	}
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// sizeVarintValue returns the size of the encoding of appendVarintValue.
+func sizeVarintValue(valid bool, v uint64) int {
+	if !valid {
+		return 0
+	}
+	return protowire.SizeTag(1) + protowire.SizeVarint(v)
+}
+
+// unmarshalVarintValue decodes the varint value field of a wrapper, which
+// is zero if it is not written.
+func unmarshalVarintValue(data []byte) (uint64, error) {
+	var v uint64
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if num != 1 || typ != protowire.VarintType {
+			return 0
+		}
+
+		var m int
+		v, m = protowire.ConsumeVarint(data)
+		return m
+	})
+	return v, err
+}
+
+// marshalNullJSON encodes the given value, or null if it is not valid.
+func marshalNullJSON(valid bool, v interface{}) ([]byte, error) {
+	if !valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalNullJSON decodes the given value, and returns whether it is valid,
+// which it is not if it is null.
+func unmarshalNullJSON(data []byte, v interface{}) (bool, error) {
+	if string(data) == "null" {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
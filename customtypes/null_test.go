@@ -0,0 +1,133 @@
+package customtypes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// unmarshaler is implemented by the pointers to the custom types.
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+func TestNullEncoding(t *testing.T) {
+	at := time.Date(2024, 2, 29, 13, 5, 7, 1, time.UTC)
+	cases := []struct {
+		name     string
+		value    marshaler
+		decoded  unmarshaler
+		expected []byte
+	}{
+		{"string", NullString{String: "foo", Valid: true}, new(NullString), []byte{0x0a, 0x03, 'f', 'o', 'o'}},
+		{"empty string", NullString{Valid: true}, new(NullString), []byte{0x0a, 0x00}},
+		{"null string", NullString{}, new(NullString), nil},
+		{"int64", NullInt64{Int64: -1, Valid: true}, new(NullInt64), []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"zero int64", NullInt64{Valid: true}, new(NullInt64), []byte{0x08, 0x00}},
+		{"null int64", NullInt64{}, new(NullInt64), nil},
+		{"int32", NullInt32{Int32: 300, Valid: true}, new(NullInt32), []byte{0x08, 0xac, 0x02}},
+		{"int16", NullInt16{Int16: -2, Valid: true}, new(NullInt16), []byte{0x08, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"byte", NullByte{Byte: 255, Valid: true}, new(NullByte), []byte{0x08, 0xff, 0x01}},
+		{"float64", NullFloat64{Float64: 1, Valid: true}, new(NullFloat64), []byte{0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f}},
+		{"bool", NullBool{Bool: true, Valid: true}, new(NullBool), []byte{0x08, 0x01}},
+		{"false", NullBool{Valid: true}, new(NullBool), []byte{0x08, 0x00}},
+		{"time", NullTime{Time: at, Valid: true}, new(NullTime), []byte{0x08, 0x83, 0x82, 0x82, 0xaf, 0x06, 0x10, 0x01}},
+		{"epoch", NullTime{Time: time.Unix(0, 0).UTC(), Valid: true}, new(NullTime), []byte{0x08, 0x00}},
+		{"null time", NullTime{}, new(NullTime), nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requireEncoding(t, c.expected, c.value)
+
+			b, err := c.value.Marshal()
+			require.NoError(t, err)
+			require.NoError(t, c.decoded.Unmarshal(b))
+			require.Equal(t, c.value, deref(c.decoded))
+		})
+	}
+}
+
+// deref returns the value the given pointer to a nullable type points to.
+func deref(v unmarshaler) interface{} {
+	switch v := v.(type) {
+	case *NullString:
+		return *v
+	case *NullInt64:
+		return *v
+	case *NullInt32:
+		return *v
+	case *NullInt16:
+		return *v
+	case *NullByte:
+		return *v
+	case *NullFloat64:
+		return *v
+	case *NullBool:
+		return *v
+	case *NullTime:
+		return *v
+	}
+	return nil
+}
+
+func TestNullUnmarshalSkipsUnknownFields(t *testing.T) {
+	require := require.New(t)
+
+	var n NullInt64
+	require.NoError(n.Unmarshal([]byte{0x10, 0x01, 0x08, 0x02}))
+	require.Equal(NullInt64{Int64: 2, Valid: true}, n)
+
+	var f NullFloat64
+	require.NoError(f.Unmarshal([]byte{0x08, 0x01}))
+	require.Equal(NullFloat64{Valid: true}, f, "a value with another wire type is skipped")
+
+	var s NullString
+	require.Error(s.Unmarshal([]byte{0x0a, 0x03, 'f'}))
+}
+
+func TestNullJSON(t *testing.T) {
+	require := require.New(t)
+
+	b, err := json.Marshal([]interface{}{
+		NullString{String: "foo", Valid: true},
+		NullString{},
+		NullInt64{Int64: 3, Valid: true},
+		NullFloat64{Float64: 1.5, Valid: true},
+		NullBool{Valid: true},
+		NullTime{Time: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), Valid: true},
+	})
+	require.NoError(err)
+	require.Equal(`["foo",null,3,1.5,false,"2024-02-29T00:00:00Z"]`, string(b))
+
+	s := NullString{String: "foo", Valid: true}
+	require.NoError(json.Unmarshal([]byte("null"), &s))
+	require.Equal(NullString{}, s)
+	require.NoError(json.Unmarshal([]byte(`"bar"`), &s))
+	require.Equal(NullString{String: "bar", Valid: true}, s)
+}
+
+func TestNullSQL(t *testing.T) {
+	require := require.New(t)
+
+	var s NullString
+	require.NoError(s.Scan("foo"))
+	require.Equal(NullString{String: "foo", Valid: true}, s)
+	require.NoError(s.Scan(nil))
+	require.Equal(NullString{}, s)
+
+	v, err := NullInt64{Int64: 2, Valid: true}.Value()
+	require.NoError(err)
+	require.Equal(int64(2), v)
+
+	v, err = NullFloat64{}.Value()
+	require.NoError(err)
+	require.Nil(v)
+
+	require.Equal(sql.NullBool{Bool: true, Valid: true}, sql.NullBool(NullBool{Bool: true, Valid: true}))
+	require.Equal(math.Float64bits(1), math.Float64bits(NullFloat64(sql.NullFloat64{Float64: 1}).Float64))
+}
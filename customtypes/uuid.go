@@ -0,0 +1,107 @@
+package customtypes
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// UUID is a UUID with the same underlying type as the UUIDs of
+// github.com/google/uuid and github.com/gofrs/uuid. It is generated as a
+// bytes field with its canonical text form, like
+// "f47ac10b-58cc-4372-a567-0e02b2c3d479", so it has the same encoding as a
+// string field. The field can not be a string, as gogoproto.customtype only
+// converts bytes and message fields.
+type UUID [16]byte
+
+// uuidLen is the length of the canonical text form of a UUID.
+const uuidLen = 36
+
+// errUUID is returned when a UUID is not in its canonical text form.
+var errUUID = errors.New("customtypes: invalid UUID")
+
+// ParseUUID decodes the UUID in the given canonical text form.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	return u, u.UnmarshalText([]byte(s))
+}
+
+// String returns the canonical text form of the UUID.
+func (u UUID) String() string {
+	return string(u.appendText(make([]byte, 0, uuidLen)))
+}
+
+func (u UUID) appendText(b []byte) []byte {
+	for i := range u {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			b = append(b, '-')
+		}
+		b = hex.AppendEncode(b, u[i:i+1])
+	}
+	return b
+}
+
+// MarshalText returns the canonical text form of the UUID, which is also
+// used by encoding/json.
+func (u UUID) MarshalText() ([]byte, error) {
+	return u.appendText(make([]byte, 0, uuidLen)), nil
+}
+
+// UnmarshalText decodes the UUID from its canonical text form.
+func (u *UUID) UnmarshalText(text []byte) error {
+	if len(text) != uuidLen {
+		return errUUID
+	}
+
+	var digits [32]byte
+	n := 0
+	for i, c := range text {
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if c != '-' {
+				return errUUID
+			}
+			continue
+		}
+		digits[n] = c
+		n++
+	}
+
+	if _, err := hex.Decode(u[:], digits[:]); err != nil {
+		return errUUID
+	}
+	return nil
+}
+
+// Marshal returns the canonical text form of the UUID.
+func (u UUID) Marshal() ([]byte, error) {
+	return u.MarshalText()
+}
+
+// MarshalTo writes the canonical text form of the UUID to the given buffer,
+// which must have room for it, and returns its size.
+func (u UUID) MarshalTo(data []byte) (int, error) {
+	if len(data) < uuidLen {
+		return 0, io.ErrShortBuffer
+	}
+	return len(u.appendText(data[:0])), nil
+}
+
+// Unmarshal decodes the UUID from its canonical text form. An empty value,
+// which is the default of a string field, is the zero UUID.
+func (u *UUID) Unmarshal(data []byte) error {
+	if len(data) == 0 {
+		*u = UUID{}
+		return nil
+	}
+	return u.UnmarshalText(data)
+}
+
+// Size returns the size of the canonical text form of the UUID.
+func (u UUID) Size() int {
+	return uuidLen
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (u UUID) ProtoSize() int {
+	return u.Size()
+}
@@ -0,0 +1,49 @@
+package customtypes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const uuidText = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+
+func TestUUID(t *testing.T) {
+	require := require.New(t)
+
+	u, err := ParseUUID(uuidText)
+	require.NoError(err)
+	require.Equal(UUID{0xf4, 0x7a, 0xc1, 0x0b, 0x58, 0xcc, 0x43, 0x72, 0xa5, 0x67, 0x0e, 0x02, 0xb2, 0xc3, 0xd4, 0x79}, u)
+	require.Equal(uuidText, u.String())
+	requireEncoding(t, []byte(uuidText), u)
+	requireEncoding(t, []byte("00000000-0000-0000-0000-000000000000"), UUID{})
+
+	var decoded UUID
+	require.NoError(decoded.Unmarshal([]byte(uuidText)))
+	require.Equal(u, decoded)
+	require.NoError(decoded.Unmarshal(nil))
+	require.Equal(UUID{}, decoded)
+
+	j, err := json.Marshal(u)
+	require.NoError(err)
+	require.Equal(`"`+uuidText+`"`, string(j))
+	decoded = UUID{}
+	require.NoError(json.Unmarshal(j, &decoded))
+	require.Equal(u, decoded)
+
+	_, err = u.MarshalTo(make([]byte, uuidLen-1))
+	require.Error(err)
+}
+
+func TestParseUUIDInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"f47ac10b58cc4372a5670e02b2c3d479",
+		"f47ac10b-58cc-4372-a567_0e02b2c3d479",
+		"g47ac10b-58cc-4372-a567-0e02b2c3d479",
+	} {
+		_, err := ParseUUID(s)
+		require.Error(t, err, s)
+	}
+}
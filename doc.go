@@ -12,4 +12,8 @@
 // protobuf enumerations.
 // All the exported functions and methods will be turned into protobuf RPC
 // services.
+//
+// Other tools can embed proteus with GenerateModel, which returns the
+// protobuf packages that would be generated without writing them, and
+// RenderProtos, which writes them once they are inspected or modified.
 package proteus // import "gitlab.com/ThatTomPerson/proteus"
@@ -0,0 +1,222 @@
+package enumconv // import "gitlab.com/ThatTomPerson/proteus/enumconv"
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// FileName is the name of the file generated in every package.
+const FileName = "proteus_enums.go"
+
+// Generator generates the funcs that convert between the enums of a package
+// with open or closed semantics and their protobuf values, so the values
+// that are not one of the enum are handled explicitly instead of being
+// silently turned into the zero value, as converting them by hand does. For
+// every enum Foo it generates:
+//
+//	func IsKnownFoo(v Foo) bool
+//	func FooFromProto(v int32) (Foo, error)
+//	func FooToProto(v Foo) (int32, error)
+//
+// For closed enums, the conversions return an error for the unknown values.
+// For open enums, they keep them as they are and IsKnownFoo tells them
+// apart. The zero value is always known, as it is the one of the fields that
+// are not set.
+//
+// Only enums of integer types can hold unknown values, so others are skipped
+// with a warning, as are enums with any of those funcs already declared.
+// The file will be written to the package path and it will be named
+// "proteus_enums.go".
+type Generator struct {
+	semantics protobuf.EnumSemantics
+}
+
+// NewGenerator creates a new Generator.
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// SetEnumSemantics sets the semantics of the enums that do not have them in
+// their docs or their package's. If empty, no funcs are generated for those
+// enums.
+func (g *Generator) SetEnumSemantics(semantics protobuf.EnumSemantics) {
+	g.semantics = semantics
+}
+
+// Generate writes the conversion funcs of the enums of the given package
+// with open or closed semantics. Nothing is written if there are none. It
+// reports whether the file was written.
+func (g *Generator) Generate(pkg *scanner.Package) (bool, error) {
+	var candidates []*scanner.Enum
+	for _, e := range pkg.Enums {
+		if protobuf.EnumSemanticsOf(e, g.semantics) != "" {
+			candidates = append(candidates, e)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return false, nil
+	}
+
+	declared, err := findFuncs(filepath.Join(goSrc, pkg.Path))
+	if err != nil {
+		return false, err
+	}
+
+	var enums []*scanner.Enum
+	for _, e := range candidates {
+		if !e.Integer {
+			report.Warn("enum %s is not of an integer type, no conversion funcs are generated for it", e.Name)
+			continue
+		}
+
+		if name, ok := declaredFunc(declared, e.Name); ok {
+			report.Warn("enum %s already has a %s func, no conversion funcs are generated for it", e.Name, name)
+			continue
+		}
+		enums = append(enums, e)
+	}
+
+	if len(enums) == 0 {
+		return false, nil
+	}
+
+	data, err := g.buildFile(pkg, enums)
+	if err != nil {
+		return false, err
+	}
+
+	file := g.FileName(pkg.Path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return false, err
+	}
+
+	report.Info("Generated enum conversions: %s", file)
+	return true, nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, FileName)
+}
+
+func (g *Generator) buildFile(pkg *scanner.Package, enums []*scanner.Enum) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(pkg.Path)))
+	buf.WriteString(fmt.Sprintf("package %s\n", pkg.Name))
+
+	// fmt is only used to reject the unknown values of closed enums
+	for _, e := range enums {
+		if protobuf.EnumSemanticsOf(e, g.semantics) == protobuf.ClosedEnum {
+			buf.WriteString("\nimport \"fmt\"\n")
+			break
+		}
+	}
+
+	for _, e := range enums {
+		name := e.Name
+		closed := protobuf.EnumSemanticsOf(e, g.semantics) == protobuf.ClosedEnum
+
+		buf.WriteString(fmt.Sprintf("\n// IsKnown%s reports whether the given value is one of the values of %s.\n", name, name))
+		buf.WriteString(fmt.Sprintf("func IsKnown%s(v %s) bool {\n", name, name))
+		buf.WriteString(fmt.Sprintf("\tswitch v {\n\tcase %s:\n\t\treturn true\n\t}\n", strings.Join(knownValues(e), ", ")))
+		buf.WriteString("\treturn false\n}\n")
+
+		if closed {
+			buf.WriteString(fmt.Sprintf("\n// %sFromProto converts the given protobuf value to %s, returning an error\n// if it is not one of its values, as %s is closed.\n", name, name, name))
+			buf.WriteString(fmt.Sprintf("func %sFromProto(v int32) (%s, error) {\n", name, name))
+			buf.WriteString(fmt.Sprintf("\tif !IsKnown%s(%s(v)) {\n", name, name))
+			buf.WriteString(fmt.Sprintf("\t\treturn 0, fmt.Errorf(\"unknown value %%d of enum %s\", v)\n\t}\n", name))
+			buf.WriteString(fmt.Sprintf("\treturn %s(v), nil\n}\n", name))
+
+			buf.WriteString(fmt.Sprintf("\n// %sToProto converts the given %s to its protobuf value, returning an error\n// if it is not one of its values, as %s is closed.\n", name, name, name))
+			buf.WriteString(fmt.Sprintf("func %sToProto(v %s) (int32, error) {\n", name, name))
+			buf.WriteString(fmt.Sprintf("\tif !IsKnown%s(v) {\n", name))
+			buf.WriteString(fmt.Sprintf("\t\treturn 0, fmt.Errorf(\"unknown value %%d of enum %s\", v)\n\t}\n", name))
+			buf.WriteString("\treturn int32(v), nil\n}\n")
+			continue
+		}
+
+		buf.WriteString(fmt.Sprintf("\n// %sFromProto converts the given protobuf value to %s, keeping it as it is\n// if it is not one of its values, as %s is open.\n", name, name, name))
+		buf.WriteString(fmt.Sprintf("func %sFromProto(v int32) (%s, error) {\n", name, name))
+		buf.WriteString(fmt.Sprintf("\treturn %s(v), nil\n}\n", name))
+
+		buf.WriteString(fmt.Sprintf("\n// %sToProto converts the given %s to its protobuf value, keeping it as it is\n// if it is not one of its values, as %s is open.\n", name, name, name))
+		buf.WriteString(fmt.Sprintf("func %sToProto(v %s) (int32, error) {\n", name, name))
+		buf.WriteString("\treturn int32(v), nil\n}\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// knownValues returns the constants of the values of the given enum that
+// are generated, which are the ones that are not negative, along with the
+// zero value if no constant has it. Constants with the value of a previous
+// one are left out, as they can not be repeated in a switch.
+func knownValues(e *scanner.Enum) []string {
+	var (
+		names []string
+		seen  = make(map[int64]bool)
+	)
+	for _, v := range e.Values {
+		if v.Value < 0 || seen[v.Value] {
+			continue
+		}
+		seen[v.Value] = true
+		names = append(names, v.Name)
+	}
+
+	if !seen[0] {
+		names = append([]string{"0"}, names...)
+	}
+	return names
+}
+
+// declaredFunc returns the name of the first conversion func of the enum
+// with the given name that is already declared, if any.
+func declaredFunc(declared map[string]bool, enum string) (string, bool) {
+	for _, name := range []string{"IsKnown" + enum, enum + "FromProto", enum + "ToProto"} {
+		if declared[name] {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// findFuncs returns the names of the funcs in the Go files of the given
+// folder, but the generated one.
+func findFuncs(dir string) (map[string]bool, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return fi.Name() != FileName && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+					names[fn.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
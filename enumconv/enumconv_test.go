@@ -0,0 +1,143 @@
+package enumconv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedFile = `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo
+
+import "fmt"
+
+// IsKnownSize reports whether the given value is one of the values of Size.
+func IsKnownSize(v Size) bool {
+	switch v {
+	case 0, Small, Large:
+		return true
+	}
+	return false
+}
+
+// SizeFromProto converts the given protobuf value to Size, returning an error
+// if it is not one of its values, as Size is closed.
+func SizeFromProto(v int32) (Size, error) {
+	if !IsKnownSize(Size(v)) {
+		return 0, fmt.Errorf("unknown value %d of enum Size", v)
+	}
+	return Size(v), nil
+}
+
+// SizeToProto converts the given Size to its protobuf value, returning an error
+// if it is not one of its values, as Size is closed.
+func SizeToProto(v Size) (int32, error) {
+	if !IsKnownSize(v) {
+		return 0, fmt.Errorf("unknown value %d of enum Size", v)
+	}
+	return int32(v), nil
+}
+
+// IsKnownColor reports whether the given value is one of the values of Color.
+func IsKnownColor(v Color) bool {
+	switch v {
+	case Unknown, Red:
+		return true
+	}
+	return false
+}
+
+// ColorFromProto converts the given protobuf value to Color, keeping it as it is
+// if it is not one of its values, as Color is open.
+func ColorFromProto(v int32) (Color, error) {
+	return Color(v), nil
+}
+
+// ColorToProto converts the given Color to its protobuf value, keeping it as it is
+// if it is not one of its values, as Color is open.
+func ColorToProto(v Color) (int32, error) {
+	return int32(v), nil
+}
+`
+
+func TestBuildFile(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &scanner.Package{
+		Name: "foo",
+		Path: "gitlab.com/foo",
+	}
+	enums := []*scanner.Enum{
+		{Name: "Size", Semantics: "closed", Integer: true, Values: []*scanner.EnumValue{
+			{Name: "Small", Value: 1},
+			{Name: "Medium", Value: -1},
+			{Name: "Large", Value: 2},
+			{Name: "Big", Value: 2},
+		}},
+		{Name: "Color", Integer: true, Values: []*scanner.EnumValue{
+			{Name: "Unknown", Value: 0},
+			{Name: "Red", Value: 1},
+		}},
+	}
+
+	g := NewGenerator()
+	g.SetEnumSemantics(protobuf.OpenEnum)
+	data, err := g.buildFile(pkg, enums)
+	require.Nil(err)
+	require.Equal(expectedFile, string(data))
+}
+
+func TestBuildFileOpen(t *testing.T) {
+	pkg := &scanner.Package{Name: "foo", Path: "gitlab.com/foo"}
+	enums := []*scanner.Enum{{Name: "Color", Semantics: "open", Integer: true}}
+
+	data, err := NewGenerator().buildFile(pkg, enums)
+	require.Nil(t, err)
+	require.NotContains(t, string(data), "import", "fmt is only needed by closed enums")
+	require.Contains(t, string(data), "case 0:")
+}
+
+func TestDeclaredFunc(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-enumconv")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"size.go":  "package foo\n\ntype Size int\n\nfunc SizeFromProto(v int32) Size { return Size(v) }\n",
+		"color.go": "package foo\n\ntype Color int\n\nfunc (c Color) ColorToProto() int32 { return 0 }\n",
+		FileName:   "package foo\n\nfunc IsKnownColor(v Color) bool { return true }\n",
+	}
+	for name, content := range files {
+		require.Nil(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	declared, err := findFuncs(dir)
+	require.Nil(err)
+
+	name, ok := declaredFunc(declared, "Size")
+	require.True(ok)
+	require.Equal("SizeFromProto", name)
+
+	_, ok = declaredFunc(declared, "Color")
+	require.False(ok, "methods and the generated file are not taken into account")
+}
+
+func TestGenerateNoEnums(t *testing.T) {
+	pkg := &scanner.Package{
+		Name:  "foo",
+		Path:  "gitlab.com/foo",
+		Enums: []*scanner.Enum{{Name: "Color", Integer: true}},
+	}
+
+	ok, err := NewGenerator().Generate(pkg)
+	require.Nil(t, err)
+	require.False(t, ok, "enums without semantics have no funcs")
+}
@@ -0,0 +1,166 @@
+// Package errstatus maps the domain errors returned by the functions served
+// by the generated gRPC servers to a google.rpc.Status with the code and the
+// ErrorInfo and BadRequest details they are annotated with, and maps them
+// back in the clients, so the details survive the call.
+package errstatus // import "gitlab.com/ThatTomPerson/proteus/errstatus"
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Coder is implemented by the errors with the gRPC code they are sent with.
+type Coder interface {
+	GRPCCode() codes.Code
+}
+
+// ErrorInfoer is implemented by the errors with the reason, domain and
+// metadata sent in an ErrorInfo detail.
+type ErrorInfoer interface {
+	ErrorInfo() *errdetails.ErrorInfo
+}
+
+// FieldViolator is implemented by the errors caused by invalid fields of the
+// request, which are sent in a BadRequest detail.
+type FieldViolator interface {
+	FieldViolations() []*errdetails.BadRequest_FieldViolation
+}
+
+// grpcStatuser is implemented by the errors that already have a status, like
+// the ones returned by the status package.
+type grpcStatuser interface {
+	GRPCStatus() *status.Status
+}
+
+// Status returns the status of the given error. The errors with a status of
+// their own, anywhere in their chain, keep it. Otherwise, the status has the
+// code of the first Coder in the chain, or Unknown if there is none, the
+// message of the error and the details of the first ErrorInfoer and
+// FieldViolator in the chain. Context errors have their codes. It is nil for
+// a nil error.
+func Status(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+
+	var s grpcStatuser
+	if errors.As(err, &s) {
+		return s.GRPCStatus()
+	}
+
+	code := codes.Unknown
+	var c Coder
+	if errors.As(err, &c) {
+		code = c.GRPCCode()
+	} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		code = status.FromContextError(err).Code()
+	}
+
+	var info *errdetails.ErrorInfo
+	var ei ErrorInfoer
+	if errors.As(err, &ei) {
+		info = ei.ErrorInfo()
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	var fv FieldViolator
+	if errors.As(err, &fv) {
+		violations = fv.FieldViolations()
+	}
+
+	return newStatus(code, err.Error(), info, violations)
+}
+
+// newStatus returns a status with the given code, message and details. The
+// details that are empty are not added.
+func newStatus(code codes.Code, msg string, info *errdetails.ErrorInfo, violations []*errdetails.BadRequest_FieldViolation) *status.Status {
+	st := status.New(code, msg)
+	var details []protoadapt.MessageV1
+	if info != nil {
+		details = append(details, info)
+	}
+	if len(violations) > 0 {
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+
+	if len(details) == 0 {
+		return st
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// Error is the error of a status, with its details, as the clients get it
+// from FromError. It implements Coder, ErrorInfoer and FieldViolator, so the
+// errors of a server can be returned by another one with the same status.
+type Error struct {
+	// Code is the code of the status.
+	Code codes.Code
+	// Message is the message of the status.
+	Message string
+	// Info is the ErrorInfo detail of the status, if any.
+	Info *errdetails.ErrorInfo
+	// Violations are the field violations of the BadRequest details of the
+	// status, if any.
+	Violations []*errdetails.BadRequest_FieldViolation
+}
+
+// FromError returns the Error with the status of the given error, and
+// whether it has one, as the errors returned by gRPC clients do. The details
+// other than ErrorInfo and BadRequest are ignored.
+func FromError(err error) (*Error, bool) {
+	if e, ok := err.(*Error); ok {
+		return e, true
+	}
+
+	st, ok := status.FromError(err)
+	if !ok || st == nil {
+		return nil, false
+	}
+
+	e := &Error{Code: st.Code(), Message: st.Message()}
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if e.Info == nil {
+				e.Info = d
+			}
+		case *errdetails.BadRequest:
+			e.Violations = append(e.Violations, d.FieldViolations...)
+		}
+	}
+	return e, true
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// GRPCCode returns the code of the status.
+func (e *Error) GRPCCode() codes.Code {
+	return e.Code
+}
+
+// ErrorInfo returns the ErrorInfo detail of the status.
+func (e *Error) ErrorInfo() *errdetails.ErrorInfo {
+	return e.Info
+}
+
+// FieldViolations returns the field violations of the status.
+func (e *Error) FieldViolations() []*errdetails.BadRequest_FieldViolation {
+	return e.Violations
+}
+
+// GRPCStatus returns the status of the error, with its details.
+func (e *Error) GRPCStatus() *status.Status {
+	return newStatus(e.Code, e.Message, e.Info, e.Violations)
+}
@@ -0,0 +1,136 @@
+package errstatus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type notFoundError struct {
+	id string
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("user %s not found", e.id)
+}
+
+func (e *notFoundError) GRPCCode() codes.Code {
+	return codes.NotFound
+}
+
+func (e *notFoundError) ErrorInfo() *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{
+		Reason:   "USER_NOT_FOUND",
+		Domain:   "users.example.com",
+		Metadata: map[string]string{"id": e.id},
+	}
+}
+
+type invalidError struct {
+	fields []string
+}
+
+func (e *invalidError) Error() string {
+	return "invalid user"
+}
+
+func (e *invalidError) GRPCCode() codes.Code {
+	return codes.InvalidArgument
+}
+
+func (e *invalidError) FieldViolations() []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, f := range e.fields {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       f,
+			Description: "is required",
+		})
+	}
+	return violations
+}
+
+func TestStatus(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(Status(nil))
+	require.Nil(Status(nil).Err())
+
+	st := Status(errors.New("foo"))
+	require.Equal(codes.Unknown, st.Code())
+	require.Equal("foo", st.Message())
+	require.Empty(st.Details())
+
+	st = Status(fmt.Errorf("get: %w", &notFoundError{"42"}))
+	require.Equal(codes.NotFound, st.Code())
+	require.Equal("get: user 42 not found", st.Message())
+	require.Len(st.Details(), 1)
+	require.True(proto.Equal((&notFoundError{"42"}).ErrorInfo(), st.Details()[0].(*errdetails.ErrorInfo)))
+
+	st = Status(&invalidError{[]string{"name", "email"}})
+	require.Equal(codes.InvalidArgument, st.Code())
+	require.Len(st.Details(), 1)
+	require.Len(st.Details()[0].(*errdetails.BadRequest).FieldViolations, 2)
+
+	st = Status(&invalidError{})
+	require.Equal(codes.InvalidArgument, st.Code())
+	require.Empty(st.Details())
+
+	st = Status(fmt.Errorf("wrapped: %w", status.Error(codes.PermissionDenied, "denied")))
+	require.Equal(codes.PermissionDenied, st.Code())
+	require.Equal("denied", st.Message())
+
+	require.Equal(codes.Canceled, Status(context.Canceled).Code())
+	require.Equal(codes.DeadlineExceeded, Status(fmt.Errorf("call: %w", context.DeadlineExceeded)).Code())
+}
+
+func TestFromError(t *testing.T) {
+	require := require.New(t)
+
+	_, ok := FromError(errors.New("foo"))
+	require.False(ok)
+
+	e, ok := FromError(Status(&notFoundError{"42"}).Err())
+	require.True(ok)
+	require.Equal(codes.NotFound, e.Code)
+	require.Equal("user 42 not found", e.Message)
+	require.Equal("user 42 not found", e.Error())
+	require.Equal("USER_NOT_FOUND", e.Info.GetReason())
+	require.Equal("42", e.Info.GetMetadata()["id"])
+	require.Empty(e.Violations)
+
+	e, ok = FromError(Status(&invalidError{[]string{"name"}}).Err())
+	require.True(ok)
+	require.Equal(codes.InvalidArgument, e.Code)
+	require.Nil(e.Info)
+	require.Len(e.Violations, 1)
+	require.Equal("name", e.Violations[0].GetField())
+	require.Equal("is required", e.Violations[0].GetDescription())
+
+	same, ok := FromError(e)
+	require.True(ok)
+	require.Equal(e, same)
+}
+
+func TestErrorStatus(t *testing.T) {
+	require := require.New(t)
+
+	e, ok := FromError(Status(&notFoundError{"42"}).Err())
+	require.True(ok)
+
+	st := Status(fmt.Errorf("proxy: %w", e))
+	require.Equal(codes.NotFound, st.Code())
+	require.Equal("user 42 not found", st.Message())
+	require.Len(st.Details(), 1)
+
+	again, ok := FromError(st.Err())
+	require.True(ok)
+	require.Equal(e.Code, again.Code)
+	require.True(proto.Equal(e.Info, again.Info))
+}
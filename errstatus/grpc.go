@@ -0,0 +1,71 @@
+package errstatus
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor returns an interceptor that sends the errors of the
+// calls with their Status.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, Status(err).Err()
+	}
+}
+
+// StreamServerInterceptor is like UnaryServerInterceptor for streams.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return Status(handler(srv, ss)).Err()
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor that returns the errors with
+// a status of the calls as an *Error, so its code and details can be found
+// with errors.As.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return clientError(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// StreamClientInterceptor is like UnaryClientInterceptor for streams, whose
+// messages are received with the errors as an *Error as well. The io.EOF at
+// the end of the stream is kept.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, clientError(err)
+		}
+		return &clientStream{cs}, nil
+	}
+}
+
+// clientStream is a client stream returning its errors as an *Error.
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	return clientError(s.ClientStream.SendMsg(m))
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	return clientError(s.ClientStream.RecvMsg(m))
+}
+
+// clientError returns the given error as an *Error if it has a status, or
+// as it is otherwise.
+func clientError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := FromError(err); ok {
+		return e
+	}
+	return err
+}
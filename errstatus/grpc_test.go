@@ -0,0 +1,94 @@
+package errstatus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	require := require.New(t)
+	intercept := UnaryServerInterceptor()
+
+	resp, err := intercept(context.Background(), "foo", &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})
+	require.NoError(err)
+	require.Equal("foo", resp)
+
+	_, err = intercept(context.Background(), "foo", &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, &notFoundError{"42"}
+	})
+	st, ok := status.FromError(err)
+	require.True(ok)
+	require.Equal(codes.NotFound, st.Code())
+	require.Len(st.Details(), 1)
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	require := require.New(t)
+	intercept := StreamServerInterceptor()
+
+	err := intercept(nil, nil, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+		return nil
+	})
+	require.NoError(err)
+
+	err = intercept(nil, nil, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+		return &invalidError{[]string{"name"}}
+	})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+type fakeClientStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (s *fakeClientStream) SendMsg(m interface{}) error {
+	return s.err
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	return s.err
+}
+
+func TestClientInterceptors(t *testing.T) {
+	require := require.New(t)
+	sent := Status(&notFoundError{"42"}).Err()
+
+	err := UnaryClientInterceptor()(context.Background(), "/foo.Foo/Bar", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return sent
+	})
+	var e *Error
+	require.True(errors.As(err, &e))
+	require.Equal(codes.NotFound, e.Code)
+	require.Equal("USER_NOT_FOUND", e.Info.GetReason())
+
+	err = UnaryClientInterceptor()(context.Background(), "/foo.Foo/Bar", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	})
+	require.NoError(err)
+
+	stream := &fakeClientStream{err: sent}
+	cs, err := StreamClientInterceptor()(context.Background(), &grpc.StreamDesc{}, nil, "/foo.Foo/Bar", func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return stream, nil
+	})
+	require.NoError(err)
+	require.True(errors.As(cs.RecvMsg(nil), &e))
+	require.True(errors.As(cs.SendMsg(nil), &e))
+
+	stream.err = io.EOF
+	require.Equal(io.EOF, cs.RecvMsg(nil))
+
+	_, err = StreamClientInterceptor()(context.Background(), &grpc.StreamDesc{}, nil, "/foo.Foo/Bar", func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return nil, sent
+	})
+	require.True(errors.As(err, &e))
+}
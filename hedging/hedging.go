@@ -0,0 +1,164 @@
+// Package hedging hedges the calls of the gRPC clients to idempotent
+// methods, sending another attempt of a call when no response arrives after
+// a delay and returning the first response, so a slow server does not slow
+// down the calls. grpc-go does not implement the hedging policy of the
+// service config, so it is done by a client interceptor instead.
+package hedging // import "gitlab.com/ThatTomPerson/proteus/hedging"
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Policy is the hedging policy of the calls.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts of a call, including the
+	// first one. The calls are not hedged if it is less than 2.
+	MaxAttempts int
+	// Delay is the time waited for a response to the last attempt before
+	// sending another one.
+	Delay time.Duration
+	// NonFatalCodes are the codes of the errors after which another attempt
+	// is sent right away, instead of returning the error, e.g. Unavailable.
+	NonFatalCodes []codes.Code
+}
+
+// UnaryClientInterceptor returns an interceptor that hedges the calls to the
+// methods with the given full names, e.g. /foo.UserService/GetUser, with the
+// given policy. The attempts of a call are sent concurrently and the first
+// one to succeed or to fail with a fatal error is its result, the rest are
+// canceled. If all of them fail with a non-fatal error, the last one is
+// returned. The calls to other methods are not hedged.
+func UnaryClientInterceptor(policy Policy, methods ...string) grpc.UnaryClientInterceptor {
+	hedged := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		hedged[m] = true
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if policy.MaxAttempts < 2 || !hedged[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		return policy.invoke(ctx, method, req, reply, cc, invoker, opts)
+	}
+}
+
+type result struct {
+	attempt *attempt
+	err     error
+}
+
+func (p Policy) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts []grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the channel has room for all the attempts, so the ones still running
+	// when the call returns do not block
+	results := make(chan result, p.MaxAttempts)
+	var sent, running int
+	send := func() {
+		a := newAttempt(reply, opts)
+		sent++
+		running++
+		go func() {
+			results <- result{a, invoker(ctx, method, req, a.reply, cc, a.opts...)}
+		}()
+	}
+
+	send()
+	timer := time.NewTimer(p.Delay)
+	defer timer.Stop()
+
+	var err error
+	for running > 0 {
+		select {
+		case <-timer.C:
+			if sent < p.MaxAttempts {
+				send()
+				timer.Reset(p.Delay)
+			}
+		case r := <-results:
+			running--
+			if r.err == nil {
+				r.attempt.copyTo(reply, opts)
+				return nil
+			}
+
+			err = r.err
+			if !p.isNonFatal(status.Code(err)) {
+				return err
+			}
+
+			if sent < p.MaxAttempts {
+				send()
+				timer.Reset(p.Delay)
+			}
+		}
+	}
+	return err
+}
+
+func (p Policy) isNonFatal(code codes.Code) bool {
+	for _, c := range p.NonFatalCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// attempt is an attempt of a call, with a reply of its own and with the
+// call options receiving its header, trailer and peer replaced by ones of
+// its own, so the attempts do not write them concurrently.
+type attempt struct {
+	reply   interface{}
+	opts    []grpc.CallOption
+	header  metadata.MD
+	trailer metadata.MD
+	peer    peer.Peer
+}
+
+func newAttempt(reply interface{}, opts []grpc.CallOption) *attempt {
+	a := &attempt{
+		reply: reflect.New(reflect.TypeOf(reply).Elem()).Interface(),
+		opts:  make([]grpc.CallOption, len(opts)),
+	}
+
+	for i, o := range opts {
+		switch o.(type) {
+		case grpc.HeaderCallOption:
+			a.opts[i] = grpc.Header(&a.header)
+		case grpc.TrailerCallOption:
+			a.opts[i] = grpc.Trailer(&a.trailer)
+		case grpc.PeerCallOption:
+			a.opts[i] = grpc.Peer(&a.peer)
+		default:
+			a.opts[i] = o
+		}
+	}
+	return a
+}
+
+// copyTo copies the reply, header, trailer and peer of the attempt to the
+// ones of the call.
+func (a *attempt) copyTo(reply interface{}, opts []grpc.CallOption) {
+	reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(a.reply).Elem())
+	for _, o := range opts {
+		switch o := o.(type) {
+		case grpc.HeaderCallOption:
+			*o.HeaderAddr = a.header
+		case grpc.TrailerCallOption:
+			*o.TrailerAddr = a.trailer
+		case grpc.PeerCallOption:
+			*o.PeerAddr = a.peer
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package hedging
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const method = "/foo.UserService/GetUser"
+
+type user struct {
+	Name string
+}
+
+// fakeInvoker answers the attempts with the given funcs, in order, counting
+// the attempts sent.
+type fakeInvoker struct {
+	attempts int32
+	answers  []func(ctx context.Context, reply *user, opts []grpc.CallOption) error
+}
+
+func (i *fakeInvoker) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	n := atomic.AddInt32(&i.attempts, 1)
+	return i.answers[n-1](ctx, reply.(*user), opts)
+}
+
+func answer(name string, after time.Duration) func(context.Context, *user, []grpc.CallOption) error {
+	return func(ctx context.Context, reply *user, opts []grpc.CallOption) error {
+		select {
+		case <-time.After(after):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+
+		reply.Name = name
+		for _, o := range opts {
+			if h, ok := o.(grpc.HeaderCallOption); ok {
+				*h.HeaderAddr = metadata.Pairs("name", name)
+			}
+		}
+		return nil
+	}
+}
+
+func fail(code codes.Code) func(context.Context, *user, []grpc.CallOption) error {
+	return func(context.Context, *user, []grpc.CallOption) error {
+		return status.Error(code, "failed")
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	require := require.New(t)
+	intercept := UnaryClientInterceptor(Policy{MaxAttempts: 3, Delay: 10 * time.Millisecond}, method)
+
+	invoker := &fakeInvoker{answers: []func(context.Context, *user, []grpc.CallOption) error{
+		answer("slow", time.Second),
+		answer("fast", 0),
+	}}
+
+	var (
+		reply  user
+		header metadata.MD
+	)
+	err := intercept(context.Background(), method, nil, &reply, nil, invoker.invoke, grpc.Header(&header))
+	require.NoError(err)
+	require.Equal(user{Name: "fast"}, reply)
+	require.Equal(metadata.Pairs("name", "fast"), header)
+	require.Equal(int32(2), invoker.attempts)
+}
+
+func TestUnaryClientInterceptorNotHedged(t *testing.T) {
+	require := require.New(t)
+
+	cases := []struct {
+		policy Policy
+		method string
+	}{
+		{Policy{MaxAttempts: 3}, "/foo.UserService/CreateUser"},
+		{Policy{MaxAttempts: 1}, method},
+	}
+
+	for _, c := range cases {
+		invoker := &fakeInvoker{answers: []func(context.Context, *user, []grpc.CallOption) error{
+			fail(codes.Unavailable),
+		}}
+
+		var reply user
+		err := UnaryClientInterceptor(c.policy, method)(context.Background(), c.method, nil, &reply, nil, invoker.invoke)
+		require.Equal(codes.Unavailable, status.Code(err))
+		require.Equal(int32(1), invoker.attempts)
+	}
+}
+
+func TestUnaryClientInterceptorErrors(t *testing.T) {
+	require := require.New(t)
+	policy := Policy{
+		MaxAttempts:   3,
+		Delay:         time.Hour,
+		NonFatalCodes: []codes.Code{codes.Unavailable},
+	}
+	intercept := UnaryClientInterceptor(policy, method)
+
+	invoker := &fakeInvoker{answers: []func(context.Context, *user, []grpc.CallOption) error{
+		fail(codes.Unavailable),
+		fail(codes.NotFound),
+	}}
+
+	var reply user
+	err := intercept(context.Background(), method, nil, &reply, nil, invoker.invoke)
+	require.Equal(codes.NotFound, status.Code(err))
+	require.Equal(int32(2), invoker.attempts)
+
+	invoker = &fakeInvoker{answers: []func(context.Context, *user, []grpc.CallOption) error{
+		fail(codes.Unavailable),
+		fail(codes.Unavailable),
+		fail(codes.Unavailable),
+	}}
+
+	err = intercept(context.Background(), method, nil, &reply, nil, invoker.invoke)
+	require.Equal(codes.Unavailable, status.Code(err))
+	require.Equal(int32(3), invoker.attempts)
+	require.Equal(user{}, reply)
+}
@@ -0,0 +1,170 @@
+package jsonomit // import "gitlab.com/ThatTomPerson/proteus/jsonomit"
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// FileName is the name of the file generated in every package.
+const FileName = "proteus_json.go"
+
+// Generator generates a MarshalJSON method for every struct of a package with
+// fields marked with `proteus:"json=omit"`, which encodes the struct as
+// encoding/json does but leaves those fields out, e.g. internal-only fields
+// that must still travel over gRPC. Other languages can leave them out too
+// reading the (proteus.json_omit) option of the fields in the .proto file.
+//
+// As the fields are dropped from the encoded object, the keys of the output
+// are sorted. Structs that already have a MarshalJSON method are skipped with
+// a warning, so they can be customised.
+//
+// The file will be written to the package path and it will be named
+// "proteus_json.go".
+type Generator struct{}
+
+// NewGenerator creates a new Generator.
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// Generate writes the MarshalJSON methods of the structs of the given package
+// with fields left out of JSON. Nothing is written if there are none. It
+// reports whether the file was written.
+func (g *Generator) Generate(pkg *scanner.Package) (bool, error) {
+	marshalers, err := findMarshalers(filepath.Join(goSrc, pkg.Path))
+	if err != nil {
+		return false, err
+	}
+
+	var structs []*scanner.Struct
+	for _, s := range omittingStructs(pkg) {
+		if marshalers[s.Name] {
+			report.Warn("struct %q already has a MarshalJSON method, its fields marked with json=omit are not left out of JSON", s.Name)
+			continue
+		}
+		structs = append(structs, s)
+	}
+
+	if len(structs) == 0 {
+		return false, nil
+	}
+
+	data, err := g.buildFile(pkg, structs)
+	if err != nil {
+		return false, err
+	}
+
+	file := g.FileName(pkg.Path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return false, err
+	}
+
+	report.Info("Generated JSON marshalers: %s", file)
+	return true, nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, FileName)
+}
+
+// omittingStructs returns the generated structs of the package with any
+// field left out of JSON.
+func omittingStructs(pkg *scanner.Package) []*scanner.Struct {
+	var result []*scanner.Struct
+	for _, s := range pkg.Structs {
+		if !s.Generate {
+			continue
+		}
+
+		for _, f := range s.Fields {
+			if f.JSONOmit && !f.Reserved {
+				result = append(result, s)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (g *Generator) buildFile(pkg *scanner.Package, structs []*scanner.Struct) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(pkg.Path)))
+	buf.WriteString(fmt.Sprintf("package %s\n\n", pkg.Name))
+	buf.WriteString("import \"encoding/json\"\n")
+
+	for _, s := range structs {
+		plain := "proteusJSON" + s.Name
+		buf.WriteString(fmt.Sprintf("\n// MarshalJSON encodes %s as JSON without the fields marked with json=omit.\n", s.Name))
+		buf.WriteString(fmt.Sprintf("func (m %s) MarshalJSON() ([]byte, error) {\n", s.Name))
+		buf.WriteString(fmt.Sprintf("\ttype %s %s\n", plain, s.Name))
+		buf.WriteString(fmt.Sprintf("\tdata, err := json.Marshal(%s(m))\n", plain))
+		buf.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n\n")
+		buf.WriteString("\tvar fields map[string]json.RawMessage\n")
+		buf.WriteString("\tif err := json.Unmarshal(data, &fields); err != nil {\n\t\treturn nil, err\n\t}\n\n")
+		for _, f := range s.Fields {
+			if f.JSONOmit && !f.Reserved {
+				buf.WriteString(fmt.Sprintf("\tdelete(fields, %q)\n", jsonKey(f)))
+			}
+		}
+		buf.WriteString("\treturn json.Marshal(fields)\n}\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// jsonKey returns the key of the field in the encoding/json output.
+func jsonKey(f *scanner.Field) string {
+	if f.JSONName != "" {
+		return f.JSONName
+	}
+	return f.Name
+}
+
+// findMarshalers returns the names of the types with a MarshalJSON method in
+// the Go files of the given folder, but the generated one.
+func findMarshalers(dir string) (map[string]bool, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return fi.Name() != FileName && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv == nil || fn.Name.Name != "MarshalJSON" {
+					continue
+				}
+
+				typ := fn.Recv.List[0].Type
+				if star, ok := typ.(*ast.StarExpr); ok {
+					typ = star.X
+				}
+
+				if ident, ok := typ.(*ast.Ident); ok {
+					names[ident.Name] = true
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
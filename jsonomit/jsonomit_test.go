@@ -0,0 +1,87 @@
+package jsonomit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedFile = `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo
+
+import "encoding/json"
+
+// MarshalJSON encodes User as JSON without the fields marked with json=omit.
+func (m User) MarshalJSON() ([]byte, error) {
+	type proteusJSONUser User
+	data, err := json.Marshal(proteusJSONUser(m))
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	delete(fields, "password")
+	delete(fields, "Token")
+	return json.Marshal(fields)
+}
+`
+
+func TestBuildFile(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &scanner.Package{
+		Name: "foo",
+		Path: "gitlab.com/foo",
+		Structs: []*scanner.Struct{
+			{Name: "User", Generate: true, Fields: []*scanner.Field{
+				{Name: "Name", JSONName: "name"},
+				{Name: "Password", JSONName: "password", JSONOmit: true},
+				{Name: "Token", JSONOmit: true},
+			}},
+			{Name: "Group", Generate: true, Fields: []*scanner.Field{
+				{Name: "Name"},
+			}},
+			{Name: "Internal", Fields: []*scanner.Field{
+				{Name: "Key", JSONOmit: true},
+			}},
+		},
+	}
+
+	structs := omittingStructs(pkg)
+	require.Len(structs, 1)
+	require.Equal("User", structs[0].Name)
+
+	data, err := NewGenerator().buildFile(pkg, structs)
+	require.Nil(err)
+	require.Equal(expectedFile, string(data))
+}
+
+func TestFindMarshalers(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-jsonomit")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"user.go":  "package foo\n\ntype User struct{}\n\nfunc (u *User) MarshalJSON() ([]byte, error) { return nil, nil }\n",
+		"group.go": "package foo\n\ntype Group struct{}\n\nfunc (g Group) String() string { return \"\" }\n",
+		FileName:   "package foo\n\nfunc (g Group) MarshalJSON() ([]byte, error) { return nil, nil }\n",
+	}
+	for name, content := range files {
+		require.Nil(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	marshalers, err := findMarshalers(dir)
+	require.Nil(err)
+	require.Equal(map[string]bool{"User": true}, marshalers)
+}
@@ -0,0 +1,120 @@
+// Package lazy holds the nested messages that are decoded only when they are
+// accessed, so the messages whose consumers rarely touch a big sub-message do
+// not pay for decoding it. The sub-message is kept encoded when its parent is
+// unmarshaled and its bytes are written back as they are when the parent is
+// marshaled again.
+//
+// A sub-message is decoded lazily by declaring a type of Message for it in
+// the scanned package and using it as the type of the field:
+//
+//	type LazyReport lazy.Message[Report]
+//
+//	type Order struct {
+//		ID     int64
+//		Report *LazyReport
+//	}
+//
+// proteus generates the field with the type of the message, Report, with the
+// lazy option, and the methods of the gogoproto.customtype option for
+// LazyReport, plus Get and Set, which decode and encode the message.
+package lazy // import "gitlab.com/ThatTomPerson/proteus/lazy"
+
+import (
+	"fmt"
+	"io"
+)
+
+// Message is the encoding of a message of type T, which is decoded by Get.
+// The zero Message is an empty message.
+type Message[T any] struct {
+	data []byte
+	// the array makes the Messages of different types different, so they
+	// can not be converted to each other, and has no size
+	_ [0]*T
+}
+
+type marshaler interface {
+	Marshal() ([]byte, error)
+}
+
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+// Get decodes the message. A new one is decoded in every call, so it is
+// safe to call it concurrently, and the message returned can be modified
+// without modifying the Message, until it is stored again with Set. It
+// returns nil for a nil Message.
+func (m *Message[T]) Get() (*T, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	msg := new(T)
+	u, ok := interface{}(msg).(unmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("lazy: %T is not a message with an Unmarshal method", msg)
+	}
+
+	if err := u.Unmarshal(m.data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Set encodes the given message and stores its encoding. A nil message is
+// stored as an empty one.
+func (m *Message[T]) Set(msg *T) error {
+	if msg == nil {
+		m.data = nil
+		return nil
+	}
+
+	v, ok := interface{}(msg).(marshaler)
+	if !ok {
+		return fmt.Errorf("lazy: %T is not a message with a Marshal method", msg)
+	}
+
+	data, err := v.Marshal()
+	if err != nil {
+		return err
+	}
+	m.data = data
+	return nil
+}
+
+// Bytes returns the encoding of the message, which must not be modified.
+func (m *Message[T]) Bytes() []byte {
+	return m.data
+}
+
+// Marshal returns a copy of the encoding of the message.
+func (m *Message[T]) Marshal() ([]byte, error) {
+	return append([]byte(nil), m.data...), nil
+}
+
+// MarshalTo writes the encoding of the message to the given buffer, which
+// must have room for it, and returns its size.
+func (m *Message[T]) MarshalTo(data []byte) (int, error) {
+	if len(data) < len(m.data) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(data, m.data), nil
+}
+
+// Unmarshal stores a copy of the given encoding of the message, without
+// decoding it, as the buffer it is in may be reused by the caller.
+func (m *Message[T]) Unmarshal(data []byte) error {
+	m.data = append([]byte(nil), data...)
+	return nil
+}
+
+// Size returns the size of the encoding of the message.
+func (m *Message[T]) Size() int {
+	return len(m.data)
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (m *Message[T]) ProtoSize() int {
+	return m.Size()
+}
@@ -0,0 +1,106 @@
+package lazy
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// report is encoded as the bytes of its text and counts how many times it is
+// decoded.
+type report struct {
+	Text string
+}
+
+var decoded int
+
+func (r *report) Marshal() ([]byte, error) {
+	return []byte(r.Text), nil
+}
+
+func (r *report) Unmarshal(data []byte) error {
+	decoded++
+	if string(data) == "invalid" {
+		return errors.New("invalid report")
+	}
+	r.Text = string(data)
+	return nil
+}
+
+type lazyReport Message[report]
+
+func TestMessage(t *testing.T) {
+	require := require.New(t)
+	decoded = 0
+
+	var m Message[report]
+	require.NoError(m.Set(&report{Text: "big"}))
+	require.Equal([]byte("big"), m.Bytes())
+	require.Equal(3, m.Size())
+	require.Equal(3, m.ProtoSize())
+
+	data, err := m.Marshal()
+	require.NoError(err)
+	require.Equal([]byte("big"), data)
+
+	var other Message[report]
+	require.NoError(other.Unmarshal(data))
+	data[0] = 'p'
+	require.Equal([]byte("big"), other.Bytes(), "the encoding is copied")
+	require.Equal(0, decoded, "the message is not decoded until it is accessed")
+
+	r, err := other.Get()
+	require.NoError(err)
+	require.Equal(&report{Text: "big"}, r)
+	require.Equal(1, decoded)
+
+	r.Text = "changed"
+	r, err = other.Get()
+	require.NoError(err)
+	require.Equal(&report{Text: "big"}, r, "the message returned is a copy")
+
+	buf := make([]byte, 2)
+	_, err = other.MarshalTo(buf)
+	require.Equal(io.ErrShortBuffer, err)
+	buf = make([]byte, 5)
+	n, err := other.MarshalTo(buf)
+	require.NoError(err)
+	require.Equal([]byte("big"), buf[:n])
+
+	require.NoError(other.Set(nil))
+	require.Equal(0, other.Size())
+	r, err = other.Get()
+	require.NoError(err)
+	require.Equal(&report{}, r)
+}
+
+func TestMessageDefinedType(t *testing.T) {
+	require := require.New(t)
+
+	var l lazyReport
+	m := (*Message[report])(&l)
+	require.NoError(m.Set(&report{Text: "big"}))
+	require.Equal([]byte("big"), l.data)
+
+	var nilReport *lazyReport
+	r, err := (*Message[report])(nilReport).Get()
+	require.NoError(err)
+	require.Nil(r)
+}
+
+func TestMessageErrors(t *testing.T) {
+	require := require.New(t)
+
+	var m Message[report]
+	require.NoError(m.Unmarshal([]byte("invalid")))
+	_, err := m.Get()
+	require.EqualError(err, "invalid report")
+
+	var notMessage Message[struct{}]
+	_, err = notMessage.Get()
+	require.EqualError(err, "lazy: *struct {} is not a message with an Unmarshal method")
+	err = notMessage.Set(&struct{}{})
+	require.EqualError(err, "lazy: *struct {} is not a message with a Marshal method")
+}
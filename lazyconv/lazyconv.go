@@ -0,0 +1,189 @@
+package lazyconv // import "gitlab.com/ThatTomPerson/proteus/lazyconv"
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// FileName is the name of the file generated in every package.
+const FileName = "proteus_lazy.go"
+
+// methods are the methods generated for every lazy type, which are the ones
+// required by gogoproto.customtype plus the ones to access the message.
+var methods = []string{"Get", "Set", "Marshal", "MarshalTo", "Unmarshal", "Size", "ProtoSize"}
+
+// Generator generates the methods of the lazy types of a package, like
+// `type LazyReport lazy.Message[Report]`, as their fields are generated as
+// fields of their message converted with the methods required by
+// gogoproto.customtype, which keep the message encoded. The methods of a
+// defined type are not the ones of its underlying type, so for every lazy
+// type Foo of a message Bar it generates the ones of lazy.Message:
+//
+//	func (m *Foo) Get() (*Bar, error)
+//	func (m *Foo) Set(msg *Bar) error
+//	func (m *Foo) Marshal() ([]byte, error)
+//	func (m *Foo) MarshalTo(data []byte) (int, error)
+//	func (m *Foo) Unmarshal(data []byte) error
+//	func (m *Foo) Size() int
+//	func (m *Foo) ProtoSize() int
+//
+// Lazy types with any of those methods already declared are skipped with a
+// warning, as their methods are the ones used.
+// The file will be written to the package path and it will be named
+// "proteus_lazy.go".
+type Generator struct{}
+
+// NewGenerator creates a new Generator.
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// Generate writes the methods of the lazy types of the given package.
+// Nothing is written if there are none. It reports whether the file was
+// written.
+func (g *Generator) Generate(pkg *scanner.Package) (bool, error) {
+	if len(pkg.Lazies) == 0 {
+		return false, nil
+	}
+
+	declared, err := findMethods(filepath.Join(goSrc, pkg.Path))
+	if err != nil {
+		return false, err
+	}
+
+	var lazies []*scanner.Lazy
+	for _, l := range pkg.Lazies {
+		if name, ok := declaredMethod(declared, l.Name); ok {
+			report.WarnAt(report.CodeSkipped, l.Position, l.Name, "lazy type %s already has a %s method, no conversion methods are generated for it", l.Name, name)
+			continue
+		}
+		lazies = append(lazies, l)
+	}
+
+	if len(lazies) == 0 {
+		return false, nil
+	}
+
+	data, err := g.buildFile(pkg, lazies)
+	if err != nil {
+		return false, err
+	}
+
+	file := g.FileName(pkg.Path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return false, err
+	}
+
+	report.Info("Generated lazy conversions: %s", file)
+	return true, nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, FileName)
+}
+
+func (g *Generator) buildFile(pkg *scanner.Package, lazies []*scanner.Lazy) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(pkg.Path)))
+	buf.WriteString(fmt.Sprintf("package %s\n", pkg.Name))
+	buf.WriteString("\nimport \"gitlab.com/ThatTomPerson/proteus/lazy\"\n")
+
+	for _, l := range lazies {
+		name, msg := l.Name, l.Message.Name
+		conv := fmt.Sprintf("(*lazy.Message[%s])(m)", msg)
+
+		buf.WriteString(fmt.Sprintf("\n// Get decodes the %s of the %s, which is nil for a nil one.\n", msg, name))
+		buf.WriteString(fmt.Sprintf("func (m *%s) Get() (*%s, error) {\n", name, msg))
+		buf.WriteString(fmt.Sprintf("\treturn %s.Get()\n}\n", conv))
+
+		buf.WriteString(fmt.Sprintf("\n// Set encodes the given %s and stores it in the %s.\n", msg, name))
+		buf.WriteString(fmt.Sprintf("func (m *%s) Set(msg *%s) error {\n", name, msg))
+		buf.WriteString(fmt.Sprintf("\treturn %s.Set(msg)\n}\n", conv))
+
+		buf.WriteString(fmt.Sprintf("\n// Marshal returns the encoding of the %s, without decoding it.\n", msg))
+		buf.WriteString(fmt.Sprintf("func (m *%s) Marshal() ([]byte, error) {\n", name))
+		buf.WriteString(fmt.Sprintf("\treturn %s.Marshal()\n}\n", conv))
+
+		buf.WriteString(fmt.Sprintf("\n// MarshalTo writes the encoding of the %s to the given buffer, which must\n// have room for it, and returns its size.\n", msg))
+		buf.WriteString(fmt.Sprintf("func (m *%s) MarshalTo(data []byte) (int, error) {\n", name))
+		buf.WriteString(fmt.Sprintf("\treturn %s.MarshalTo(data)\n}\n", conv))
+
+		buf.WriteString(fmt.Sprintf("\n// Unmarshal stores the given encoding of the %s, which is decoded by Get.\n", msg))
+		buf.WriteString(fmt.Sprintf("func (m *%s) Unmarshal(data []byte) error {\n", name))
+		buf.WriteString(fmt.Sprintf("\treturn %s.Unmarshal(data)\n}\n", conv))
+
+		buf.WriteString(fmt.Sprintf("\n// Size returns the size of the encoding of the %s.\n", msg))
+		buf.WriteString(fmt.Sprintf("func (m *%s) Size() int {\n", name))
+		buf.WriteString(fmt.Sprintf("\treturn %s.Size()\n}\n", conv))
+
+		buf.WriteString("\n// ProtoSize is like Size, for the messages generated with protosizer.\n")
+		buf.WriteString(fmt.Sprintf("func (m *%s) ProtoSize() int {\n", name))
+		buf.WriteString("\treturn m.Size()\n}\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// declaredMethod returns the name of the first method of the lazy type with
+// the given name that is already declared, if any.
+func declaredMethod(declared map[string]map[string]bool, lazy string) (string, bool) {
+	for _, name := range methods {
+		if declared[lazy][name] {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// findMethods returns the names of the methods of the types in the Go files
+// of the given folder, but the generated one, indexed by the name of the
+// type.
+func findMethods(dir string) (map[string]map[string]bool, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return fi.Name() != FileName && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv == nil {
+					continue
+				}
+
+				typ := fn.Recv.List[0].Type
+				if star, ok := typ.(*ast.StarExpr); ok {
+					typ = star.X
+				}
+
+				if ident, ok := typ.(*ast.Ident); ok {
+					if names[ident.Name] == nil {
+						names[ident.Name] = make(map[string]bool)
+					}
+					names[ident.Name][fn.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
@@ -0,0 +1,98 @@
+package lazyconv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedFile = `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo
+
+import "gitlab.com/ThatTomPerson/proteus/lazy"
+
+// Get decodes the Report of the LazyReport, which is nil for a nil one.
+func (m *LazyReport) Get() (*Report, error) {
+	return (*lazy.Message[Report])(m).Get()
+}
+
+// Set encodes the given Report and stores it in the LazyReport.
+func (m *LazyReport) Set(msg *Report) error {
+	return (*lazy.Message[Report])(m).Set(msg)
+}
+
+// Marshal returns the encoding of the Report, without decoding it.
+func (m *LazyReport) Marshal() ([]byte, error) {
+	return (*lazy.Message[Report])(m).Marshal()
+}
+
+// MarshalTo writes the encoding of the Report to the given buffer, which must
+// have room for it, and returns its size.
+func (m *LazyReport) MarshalTo(data []byte) (int, error) {
+	return (*lazy.Message[Report])(m).MarshalTo(data)
+}
+
+// Unmarshal stores the given encoding of the Report, which is decoded by Get.
+func (m *LazyReport) Unmarshal(data []byte) error {
+	return (*lazy.Message[Report])(m).Unmarshal(data)
+}
+
+// Size returns the size of the encoding of the Report.
+func (m *LazyReport) Size() int {
+	return (*lazy.Message[Report])(m).Size()
+}
+
+// ProtoSize is like Size, for the messages generated with protosizer.
+func (m *LazyReport) ProtoSize() int {
+	return m.Size()
+}
+`
+
+func TestBuildFile(t *testing.T) {
+	pkg := &scanner.Package{Name: "foo", Path: "gitlab.com/foo"}
+	lazies := []*scanner.Lazy{
+		{Name: "LazyReport", Message: scanner.NewNamed("gitlab.com/foo", "Report").(*scanner.Named)},
+	}
+
+	data, err := NewGenerator().buildFile(pkg, lazies)
+	require.Nil(t, err)
+	require.Equal(t, expectedFile, string(data))
+}
+
+func TestDeclaredMethod(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-lazyconv")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"report.go": "package foo\n\ntype LazyReport lazy.Message[Report]\n\nfunc (r *LazyReport) Get() (*Report, error) { return nil, nil }\n",
+		"user.go":   "package foo\n\ntype LazyUser lazy.Message[User]\n\nfunc Size(u LazyUser) int { return 0 }\n",
+		FileName:    "package foo\n\nfunc (m *LazyUser) Marshal() ([]byte, error) { return nil, nil }\n",
+	}
+	for name, content := range files {
+		require.Nil(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	declared, err := findMethods(dir)
+	require.Nil(err)
+
+	name, ok := declaredMethod(declared, "LazyReport")
+	require.True(ok)
+	require.Equal("Get", name)
+
+	_, ok = declaredMethod(declared, "LazyUser")
+	require.False(ok, "funcs and the generated file are not taken into account")
+}
+
+func TestGenerateNoLazies(t *testing.T) {
+	ok, err := NewGenerator().Generate(&scanner.Package{Name: "foo", Path: "gitlab.com/foo"})
+	require.Nil(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/ThatTomPerson/proteus/tracing"
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor returns an interceptor that logs the calls chosen
+// by the given sampler, with their requests and responses rendered with the
+// paths of the given redactions, by full method name, redacted. Chained
+// after the interceptors of the tracing package, the entries have the
+// request ID of the calls.
+func UnaryServerInterceptor(sampler Sampler, redactions map[string]Redaction) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !sampler.Sampled(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		e := Entry{
+			Method:    info.FullMethod,
+			RequestID: tracing.FromContext(ctx).RequestID,
+			Duration:  time.Since(start),
+		}
+
+		r := redactions[info.FullMethod]
+		e.Request, _ = Render(req, r.Request)
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e.Response, _ = Render(resp, r.Response)
+		}
+
+		sampler.log(ctx, e)
+		return resp, err
+	}
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/tracing"
+	"google.golang.org/grpc"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	require := require.New(t)
+
+	var entries []Entry
+	sampler := Sampler{
+		Methods: map[string]float64{"/foo.FooService/Login": 1},
+		Log: func(ctx context.Context, e Entry) {
+			entries = append(entries, e)
+		},
+	}
+	intercept := UnaryServerInterceptor(sampler, map[string]Redaction{
+		"/foo.FooService/Login": {Request: []string{"password"}, Response: []string{"friends.password"}},
+	})
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		u := req.(*user)
+		if u.Name == "" {
+			return nil, errors.New("no name")
+		}
+		return &user{Name: u.Name, Friends: []*user{{Password: "1234"}}}, nil
+	}
+
+	login := &grpc.UnaryServerInfo{FullMethod: "/foo.FooService/Login"}
+	ctx := tracing.NewContext(context.Background(), tracing.IDs{RequestID: "req-1"})
+	resp, err := intercept(ctx, &user{Name: "jane", Password: "hunter2"}, login, handler)
+	require.NoError(err)
+	require.Equal("jane", resp.(*user).Name)
+
+	_, err = intercept(ctx, &user{}, login, handler)
+	require.Error(err)
+
+	_, err = intercept(ctx, &user{Name: "jane"}, &grpc.UnaryServerInfo{FullMethod: "/foo.FooService/Get"}, handler)
+	require.NoError(err)
+
+	require.Len(entries, 2, "only the sampled calls are logged")
+	require.Equal("/foo.FooService/Login", entries[0].Method)
+	require.Equal("req-1", entries[0].RequestID)
+	require.JSONEq(`{"name":"jane","password":"[REDACTED]","tokens":null}`, string(entries[0].Request))
+	require.JSONEq(`{"name":"jane","password":"","tokens":null,"friends":[{"name":"","password":"[REDACTED]","tokens":null}]}`, string(entries[0].Response))
+	require.Equal("no name", entries[1].Error)
+	require.Nil(entries[1].Response)
+}
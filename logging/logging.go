@@ -0,0 +1,171 @@
+// Package logging logs a sample of the requests and responses of the calls
+// served by the generated gRPC servers, rendered in the protobuf JSON
+// encoding, with the values of their sensitive fields redacted, so the
+// traffic of production services can be debugged without leaking passwords
+// or personal details to the logs.
+package logging // import "gitlab.com/ThatTomPerson/proteus/logging"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	protov2 "google.golang.org/protobuf/proto"
+)
+
+// Redacted is the value the sensitive fields get in the logs.
+const Redacted = "[REDACTED]"
+
+// Sampler chooses the calls that are logged and logs them.
+type Sampler struct {
+	// Rate is the fraction of the calls that are logged, from 0, the
+	// default, which logs none, to 1, which logs all of them.
+	Rate float64
+	// Methods are the rates of the methods that have their own, by full
+	// method name, e.g. /users.UsersService/GetUser.
+	Methods map[string]float64
+	// Log logs the entry of a sampled call. If nil, the entries are logged
+	// in JSON with the log package.
+	Log func(ctx context.Context, e Entry)
+}
+
+// Sampled reports whether a call to the method with the given full name is
+// logged.
+func (s Sampler) Sampled(method string) bool {
+	rate, ok := s.Methods[method]
+	if !ok {
+		rate = s.Rate
+	}
+
+	switch {
+	case rate <= 0:
+		return false
+	case rate >= 1:
+		return true
+	default:
+		return rand.Float64() < rate
+	}
+}
+
+func (s Sampler) log(ctx context.Context, e Entry) {
+	if s.Log != nil {
+		s.Log(ctx, e)
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("logging: unable to encode the entry of %s: %s", e.Method, err)
+		return
+	}
+	log.Printf("%s", data)
+}
+
+// Entry is the log entry of a call.
+type Entry struct {
+	// Method is the full name of the method called.
+	Method string `json:"method"`
+	// RequestID is the request ID of the call given by the interceptors of
+	// the tracing package, if any.
+	RequestID string `json:"request_id,omitempty"`
+	// Request is the rendered request, or nil if it could not be rendered.
+	Request json.RawMessage `json:"request,omitempty"`
+	// Response is the rendered response, or nil if the call failed or it
+	// could not be rendered.
+	Response json.RawMessage `json:"response,omitempty"`
+	// Error is the error of the call, if it failed.
+	Error string `json:"error,omitempty"`
+	// Duration is the time it took to serve the call.
+	Duration time.Duration `json:"duration"`
+}
+
+// Redaction has the paths of the sensitive fields of the request and the
+// response of a method that are redacted. A path has the JSON names of the
+// fields from the message to the sensitive one, separated by dots, e.g.
+// user.password, with a * for the values of maps, e.g. users.*.password.
+// The paths go through the elements of repeated fields, and sensitive
+// repeated fields are redacted as a whole.
+type Redaction struct {
+	Request  []string
+	Response []string
+}
+
+// Render renders the given message in the protobuf JSON encoding, with the
+// fields in the given paths redacted. APIv2 messages are rendered with
+// protojson, gogo ones with jsonpb and the rest of the values with
+// encoding/json.
+func Render(msg interface{}, paths []string) (json.RawMessage, error) {
+	data, err := marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) > 0 {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+
+		for _, p := range paths {
+			v = redact(v, strings.Split(p, "."))
+		}
+
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshal(msg interface{}) ([]byte, error) {
+	switch m := msg.(type) {
+	case protov2.Message:
+		return protojson.Marshal(m)
+	case proto.Message:
+		s, err := new(jsonpb.Marshaler).MarshalToString(m)
+		return []byte(s), err
+	default:
+		return json.Marshal(m)
+	}
+}
+
+// redact returns the given decoded JSON value with the value in the given
+// path replaced by Redacted.
+func redact(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		if v == nil {
+			return nil
+		}
+		return Redacted
+	}
+
+	switch val := v.(type) {
+	case []interface{}:
+		for i, elem := range val {
+			val[i] = redact(elem, path)
+		}
+	case map[string]interface{}:
+		if path[0] == "*" {
+			for k, elem := range val {
+				val[k] = redact(elem, path[1:])
+			}
+		} else if elem, ok := val[path[0]]; ok {
+			val[path[0]] = redact(elem, path[1:])
+		}
+	}
+	return v
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestSampled(t *testing.T) {
+	require := require.New(t)
+
+	s := Sampler{Methods: map[string]float64{"/foo.FooService/GetFoo": 1, "/foo.FooService/Ping": 0}}
+	require.True(s.Sampled("/foo.FooService/GetFoo"))
+	require.False(s.Sampled("/foo.FooService/ListFoos"), "the default rate logs nothing")
+
+	s.Rate = 1
+	require.True(s.Sampled("/foo.FooService/ListFoos"))
+	require.False(s.Sampled("/foo.FooService/Ping"), "the rate of the method is used")
+}
+
+type user struct {
+	Name     string            `json:"name"`
+	Password string            `json:"password"`
+	Tokens   []string          `json:"tokens"`
+	Friends  []*user           `json:"friends,omitempty"`
+	Groups   map[string]*group `json:"groups,omitempty"`
+}
+
+type group struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+	Size   int64  `json:"size"`
+}
+
+func TestRender(t *testing.T) {
+	require := require.New(t)
+
+	u := &user{
+		Name:     "jane",
+		Password: "hunter2",
+		Tokens:   []string{"a", "b"},
+		Friends:  []*user{{Name: "john", Password: "1234"}},
+		Groups:   map[string]*group{"admins": {Name: "admins", Secret: "s3cr3t", Size: 9007199254740993}},
+	}
+	data, err := Render(u, []string{"password", "tokens", "friends.password", "groups.*.secret", "missing.field"})
+	require.NoError(err)
+	require.NotContains(string(data), "hunter2")
+	require.JSONEq(`{
+		"name": "jane",
+		"password": "[REDACTED]",
+		"tokens": "[REDACTED]",
+		"friends": [{"name": "john", "password": "[REDACTED]", "tokens": null}],
+		"groups": {"admins": {"name": "admins", "secret": "[REDACTED]", "size": 9007199254740993}}
+	}`, string(data))
+	require.Contains(string(data), "9007199254740993", "numbers are kept as they are")
+
+	data, err = Render(&user{Name: "jane"}, nil)
+	require.NoError(err)
+	require.Equal(`{"name":"jane","password":"","tokens":null}`, string(data))
+}
+
+func TestRenderProtobuf(t *testing.T) {
+	require := require.New(t)
+
+	data, err := Render(&types.Struct{Fields: map[string]*types.Value{
+		"password": {Kind: &types.Value_StringValue{StringValue: "hunter2"}},
+	}}, []string{"password"})
+	require.NoError(err)
+	require.Equal(`{"password":"[REDACTED]"}`, string(data), "gogo messages are rendered with jsonpb")
+
+	data, err = Render(wrapperspb.Int64(42), nil)
+	require.NoError(err)
+	require.Equal(`"42"`, string(data), "APIv2 messages are rendered with protojson")
+}
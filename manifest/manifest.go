@@ -0,0 +1,154 @@
+package manifest // import "gitlab.com/ThatTomPerson/proteus/manifest"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+)
+
+// File is a single file produced in a run.
+type File struct {
+	// Path of the file.
+	Path string `json:"path"`
+	// SHA256 is the hex encoded SHA-256 hash of the contents of the file.
+	SHA256 string `json:"sha256"`
+	// Package is the Go package the file was generated from.
+	Package string `json:"package"`
+}
+
+// Manifest is the list of all the files produced in a run. It is meant to be
+// written as JSON so build systems can track the outputs of proteus and the
+// outputs of previous runs that are not produced anymore.
+type Manifest struct {
+	Files []*File `json:"files"`
+}
+
+// New creates a new empty manifest.
+func New() *Manifest {
+	return &Manifest{Files: []*File{}}
+}
+
+// ReadFile reads the manifest in the JSON file at the given path.
+func ReadFile(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := New()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add adds the file at the given path, generated from the given Go package,
+// to the manifest, hashing its current contents. If the file was already in
+// the manifest, it is replaced.
+func (m *Manifest) Add(path, pkg string) error {
+	data, err := output.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	f := &File{Path: path, SHA256: hex.EncodeToString(sum[:]), Package: pkg}
+	for i, file := range m.Files {
+		if file.Path == path {
+			m.Files[i] = f
+			return nil
+		}
+	}
+
+	m.Files = append(m.Files, f)
+	return nil
+}
+
+// Has reports whether the file at the given path is in the manifest.
+func (m *Manifest) Has(path string) bool {
+	for _, f := range m.Files {
+		if f.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Orphans returns the files of the previous manifest that are not in this
+// one, that is, the files that are not produced anymore, e.g. because their
+// package was removed.
+func (m *Manifest) Orphans(prev *Manifest) []*File {
+	var orphans []*File
+	for _, f := range prev.Files {
+		if !m.Has(f.Path) {
+			orphans = append(orphans, f)
+		}
+	}
+	return orphans
+}
+
+// Stale returns the files of the previous manifest whose source does not
+// exist anymore. Those are the files of packages that do not exist, according
+// to the given func, and the files that were not produced again for the
+// given packages, which are the ones generated in this run. As not all the
+// kinds of files are produced in every run, only the files with the same name
+// as one produced in this run are considered for the latter.
+func (m *Manifest) Stale(prev *Manifest, generated []string, exists func(pkg string) bool) []*File {
+	var pkgs = make(map[string]bool, len(generated))
+	for _, p := range generated {
+		pkgs[p] = true
+	}
+
+	var names = make(map[string]bool)
+	for _, f := range m.Files {
+		names[filepath.Base(f.Path)] = true
+	}
+
+	var stale []*File
+	for _, f := range prev.Files {
+		if m.Has(f.Path) {
+			continue
+		}
+
+		if (pkgs[f.Package] && names[filepath.Base(f.Path)]) || !exists(f.Package) {
+			stale = append(stale, f)
+		}
+	}
+	return stale
+}
+
+// Keep adds the files of the previous manifest that are not in this one,
+// except the given ones, so they are still tracked even though they were
+// not produced in this run.
+func (m *Manifest) Keep(prev *Manifest, except []*File) {
+	var skip = make(map[string]bool, len(except))
+	for _, f := range except {
+		skip[f.Path] = true
+	}
+
+	for _, f := range prev.Files {
+		if !skip[f.Path] && !m.Has(f.Path) {
+			m.Files = append(m.Files, f)
+		}
+	}
+}
+
+// WriteFile writes the manifest as JSON to the file at the given path. Files
+// are sorted by path so the output does not depend on the generation order.
+func (m *Manifest) WriteFile(path string) error {
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return output.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,89 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-manifest")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	foo := filepath.Join(dir, "foo.proto")
+	bar := filepath.Join(dir, "bar.proto")
+	require.NoError(ioutil.WriteFile(foo, []byte("foo"), 0644))
+	require.NoError(ioutil.WriteFile(bar, []byte("bar"), 0644))
+
+	m := New()
+	require.NoError(m.Add(foo, "foo"))
+	require.NoError(m.Add(bar, "bar"))
+	require.NoError(m.Add(foo, "foo"))
+	require.Error(m.Add(filepath.Join(dir, "missing.proto"), "missing"))
+	require.Len(m.Files, 2)
+	require.Equal(
+		"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		m.Files[0].SHA256,
+	)
+
+	file := filepath.Join(dir, "manifest.json")
+	require.NoError(m.WriteFile(file))
+
+	read, err := ReadFile(file)
+	require.NoError(err)
+	require.Equal([]*File{
+		{Path: bar, SHA256: m.Files[0].SHA256, Package: "bar"},
+		{Path: foo, SHA256: m.Files[1].SHA256, Package: "foo"},
+	}, read.Files)
+	require.Equal("bar", read.Files[0].Package)
+}
+
+func TestManifestOrphans(t *testing.T) {
+	prev := &Manifest{Files: []*File{
+		{Path: "foo/generated.proto", Package: "foo"},
+		{Path: "bar/generated.proto", Package: "bar"},
+	}}
+	curr := &Manifest{Files: []*File{
+		{Path: "foo/generated.proto", Package: "foo"},
+		{Path: "baz/generated.proto", Package: "baz"},
+	}}
+
+	require.Equal(t, []*File{prev.Files[1]}, curr.Orphans(prev))
+	require.Empty(t, prev.Orphans(prev))
+}
+
+func TestReadFileError(t *testing.T) {
+	_, err := ReadFile("/does/not/exist.json")
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestManifestStale(t *testing.T) {
+	require := require.New(t)
+
+	prev := &Manifest{Files: []*File{
+		{Path: "foo/generated.proto", Package: "foo"},
+		{Path: "foo/server.proteus.go", Package: "foo"},
+		{Path: "qux/generated.proto", Package: "qux"},
+		{Path: "bar/generated.proto", Package: "bar"},
+		{Path: "baz/generated.proto", Package: "baz"},
+	}}
+	curr := &Manifest{Files: []*File{
+		{Path: "foo/generated.proto", Package: "foo"},
+	}}
+
+	exists := func(pkg string) bool {
+		return pkg != "bar"
+	}
+
+	stale := curr.Stale(prev, []string{"foo", "qux"}, exists)
+	require.Equal([]*File{prev.Files[2], prev.Files[3]}, stale)
+
+	curr.Keep(prev, stale)
+	require.Equal([]*File{prev.Files[0], prev.Files[1], prev.Files[4]}, curr.Files)
+}
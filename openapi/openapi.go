@@ -0,0 +1,534 @@
+package openapi // import "gitlab.com/ThatTomPerson/proteus/openapi"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+const (
+	// FileName is the name of the OpenAPI document written in the base path.
+	FileName = "openapi.json"
+	// Version is the version of the OpenAPI specification of the document.
+	Version = "3.0.3"
+	// DefaultAPIVersion is the version of the API given in the document if
+	// none is set.
+	DefaultAPIVersion = "1.0.0"
+)
+
+// Generator writes an OpenAPI v3 document describing the HTTP API of the
+// generated packages, the way grpc-gateway or any other proxy following the
+// google.api.http rules serves it.
+//
+// Every RPC with an HTTP rule is an operation of the path of its rule, whose
+// request and response bodies are the JSON mapping of proto3 of its messages.
+// The fields of the request bound by the path are path parameters, and the
+// ones that are not sent in the body are query parameters, if they are of a
+// basic type or an enum. Every message and enum of the packages is a schema
+// of the document, named after its full protobuf name. The well-known types
+// are given the schema of their JSON mapping.
+type Generator struct {
+	basePath string
+	title    string
+	version  string
+}
+
+// NewGenerator creates a new Generator with the given base path.
+func NewGenerator(basePath string) *Generator {
+	return &Generator{basePath: basePath, version: DefaultAPIVersion}
+}
+
+// SetInfo sets the title and the version of the API in the document. If the
+// title is empty, it is the protobuf packages generated. If the version is
+// empty, it is DefaultAPIVersion.
+func (g *Generator) SetInfo(title, version string) {
+	g.title = title
+	g.version = version
+	if version == "" {
+		g.version = DefaultAPIVersion
+	}
+}
+
+// FileName returns the path of the document written by the generator.
+func (g *Generator) FileName() string {
+	return filepath.Join(g.basePath, FileName)
+}
+
+// Generate writes the OpenAPI document of the given packages to disk.
+func (g *Generator) Generate(pkgs []*protobuf.Package) error {
+	fi, err := os.Stat(g.basePath)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(g.document(pkgs)); err != nil {
+		return err
+	}
+
+	if err := output.WriteFile(g.FileName(), buf.Bytes(), fi.Mode()); err != nil {
+		return err
+	}
+
+	report.Info("Generated OpenAPI document: %s", g.FileName())
+	return nil
+}
+
+type document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       info                             `json:"info"`
+	Tags       []tag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type components struct {
+	Schemas map[string]*schema `json:"schemas,omitempty"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags"`
+	Parameters  []*parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string     `json:"$ref,omitempty"`
+	Type                 string     `json:"type,omitempty"`
+	Format               string     `json:"format,omitempty"`
+	Description          string     `json:"description,omitempty"`
+	Items                *schema    `json:"items,omitempty"`
+	Properties           properties `json:"properties,omitempty"`
+	AdditionalProperties *schema    `json:"additionalProperties,omitempty"`
+	Enum                 []string   `json:"enum,omitempty"`
+	Deprecated           bool       `json:"deprecated,omitempty"`
+	Unit                 string     `json:"x-unit,omitempty"`
+}
+
+// properties are the properties of an object schema, which are written in
+// the order of the fields of their message.
+type properties []property
+
+type property struct {
+	name   string
+	schema *schema
+}
+
+func (p properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteRune('{')
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+
+		name, err := json.Marshal(prop.name)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(prop.schema)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteRune(':')
+		buf.Write(value)
+	}
+	buf.WriteRune('}')
+	return buf.Bytes(), nil
+}
+
+const jsonContent = "application/json"
+
+// document returns the OpenAPI document of the given packages.
+func (g *Generator) document(pkgs []*protobuf.Package) *document {
+	doc := &document{
+		OpenAPI:    Version,
+		Info:       info{Title: g.title, Version: g.version},
+		Paths:      make(map[string]map[string]*operation),
+		Components: components{Schemas: make(map[string]*schema)},
+	}
+
+	if doc.Info.Title == "" {
+		var names = make([]string, len(pkgs))
+		for i, pkg := range pkgs {
+			names[i] = pkg.Name
+		}
+		doc.Info.Title = strings.Join(names, ", ")
+	}
+
+	defs := newDefinitions(pkgs)
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Enums {
+			doc.Components.Schemas[fullName(pkg.Name, e.Name)] = enumSchema(e)
+		}
+
+		for _, msg := range pkg.Messages {
+			doc.Components.Schemas[fullName(pkg.Name, msg.Name)] = defs.messageSchema(pkg, msg)
+		}
+
+		for _, svc := range pkg.Services {
+			var hasHTTP bool
+			for _, rpc := range svc.RPCs {
+				if rpc.HTTP == nil {
+					continue
+				}
+
+				hasHTTP = true
+				path := pathTemplate(rpc.HTTP.Path)
+				if doc.Paths[path] == nil {
+					doc.Paths[path] = make(map[string]*operation)
+				}
+
+				if _, ok := doc.Paths[path][rpc.HTTP.Method]; ok {
+					report.Warn("RPC %s.%s has the same HTTP rule as another one, it is left out of the OpenAPI document", svc.Name, rpc.Name)
+					continue
+				}
+				doc.Paths[path][rpc.HTTP.Method] = defs.operation(pkg, svc, rpc)
+			}
+
+			if hasHTTP {
+				doc.Tags = append(doc.Tags, tag{Name: svc.Name, Description: strings.Join(svc.Docs, "\n")})
+			}
+		}
+	}
+
+	return doc
+}
+
+// definitions are the messages and enums of the packages in the document,
+// indexed by their full protobuf name.
+type definitions struct {
+	messages map[string]*protobuf.Message
+	enums    map[string]bool
+}
+
+func newDefinitions(pkgs []*protobuf.Package) *definitions {
+	defs := &definitions{
+		messages: make(map[string]*protobuf.Message),
+		enums:    make(map[string]bool),
+	}
+	for _, pkg := range pkgs {
+		for _, msg := range pkg.Messages {
+			defs.messages[fullName(pkg.Name, msg.Name)] = msg
+		}
+
+		for _, e := range pkg.Enums {
+			defs.enums[fullName(pkg.Name, e.Name)] = true
+		}
+	}
+	return defs
+}
+
+// operation returns the operation of the given RPC, which must have an HTTP
+// rule.
+func (d *definitions) operation(pkg *protobuf.Package, svc *protobuf.Service, rpc *protobuf.RPC) *operation {
+	op := &operation{
+		OperationID: fmt.Sprintf("%s_%s", svc.Name, rpc.Name),
+		Tags:        []string{svc.Name},
+		Responses: map[string]*response{
+			"200": {
+				Description: "A successful response.",
+				Content:     map[string]*mediaType{jsonContent: {Schema: d.typeSchema(pkg, rpc.Output)}},
+			},
+		},
+		Deprecated: isDeprecated(rpc.Options),
+	}
+
+	if len(rpc.Docs) > 0 {
+		op.Summary = rpc.Docs[0]
+		op.Description = strings.Join(rpc.Docs, "\n")
+	}
+
+	input := d.message(pkg, rpc.Input)
+	bound := make(map[string]bool)
+	for _, name := range pathParams(rpc.HTTP.Path) {
+		bound[name] = true
+		param := &parameter{Name: name, In: "path", Schema: &schema{Type: "string"}}
+		if f := findField(input, name); f != nil {
+			param = d.parameter(pkg, f, name, "path")
+		}
+		param.Required = true
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	switch rpc.HTTP.Body {
+	case "":
+	case "*":
+		op.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]*mediaType{jsonContent: {Schema: d.typeSchema(pkg, rpc.Input)}},
+		}
+		return op
+	default:
+		bound[rpc.HTTP.Body] = true
+		body := &schema{Type: "object"}
+		if f := findField(input, rpc.HTTP.Body); f != nil {
+			body = d.fieldSchema(pkg, f)
+		}
+		op.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]*mediaType{jsonContent: {Schema: body}},
+		}
+	}
+
+	if input == nil {
+		return op
+	}
+
+	for _, f := range input.Fields {
+		if bound[f.Name] || !d.isQueryParam(pkg, f) {
+			continue
+		}
+
+		op.Parameters = append(op.Parameters, d.parameter(pkg, f, f.JSONName(), "query"))
+	}
+	return op
+}
+
+// parameter returns the parameter with the given name and location of the
+// given field of a request of the package.
+func (d *definitions) parameter(pkg *protobuf.Package, f *protobuf.Field, name, in string) *parameter {
+	s := d.fieldSchema(pkg, f)
+	s.Description = ""
+	return &parameter{
+		Name:        name,
+		In:          in,
+		Description: strings.Join(f.Docs, "\n"),
+		Schema:      s,
+	}
+}
+
+// isQueryParam reports whether the given field can be sent as a query
+// parameter, which are only the fields of basic types and enums.
+func (d *definitions) isQueryParam(pkg *protobuf.Package, f *protobuf.Field) bool {
+	switch t := underlying(f.Type).(type) {
+	case *protobuf.Basic:
+		return true
+	case *protobuf.Named:
+		return d.enums[d.namedName(pkg, t)]
+	}
+	return false
+}
+
+// message returns the message of the packages with the given type, if any.
+func (d *definitions) message(pkg *protobuf.Package, typ protobuf.Type) *protobuf.Message {
+	if n, ok := underlying(typ).(*protobuf.Named); ok {
+		return d.messages[d.namedName(pkg, n)]
+	}
+	return nil
+}
+
+// messageSchema returns the schema of the given message of the package.
+func (d *definitions) messageSchema(pkg *protobuf.Package, msg *protobuf.Message) *schema {
+	s := &schema{
+		Type:        "object",
+		Description: strings.Join(msg.Docs, "\n"),
+		Deprecated:  isDeprecated(msg.Options),
+	}
+	for _, f := range msg.Fields {
+		s.Properties = append(s.Properties, property{f.JSONName(), d.fieldSchema(pkg, f)})
+	}
+	return s
+}
+
+// fieldSchema returns the schema of the value of the given field of a
+// message of the package.
+func (d *definitions) fieldSchema(pkg *protobuf.Package, f *protobuf.Field) *schema {
+	s := d.typeSchema(pkg, f.Type)
+	if f.Repeated {
+		s = &schema{Type: "array", Items: s}
+	}
+
+	if s.Ref == "" {
+		s.Description = strings.Join(f.Docs, "\n")
+		s.Deprecated = isDeprecated(f.Options)
+		s.Unit = f.Unit()
+	}
+	return s
+}
+
+// typeSchema returns the schema of the values of the given type used in the
+// package. Messages and enums of the packages are references to their
+// schema, and the rest of the named types are objects.
+func (d *definitions) typeSchema(pkg *protobuf.Package, typ protobuf.Type) *schema {
+	switch t := underlying(typ).(type) {
+	case *protobuf.Basic:
+		return basicSchema(t.Name)
+	case *protobuf.Map:
+		return &schema{Type: "object", AdditionalProperties: d.typeSchema(pkg, t.Value)}
+	case *protobuf.Named:
+		name := d.namedName(pkg, t)
+		if d.messages[name] != nil || d.enums[name] {
+			return &schema{Ref: "#/components/schemas/" + name}
+		}
+
+		if fn, ok := wellKnownTypes[name]; ok {
+			return fn()
+		}
+	}
+	return &schema{Type: "object"}
+}
+
+// namedName returns the full protobuf name of the given named type used in
+// the package.
+func (d *definitions) namedName(pkg *protobuf.Package, n *protobuf.Named) string {
+	if n.Package == "" {
+		return fullName(pkg.Name, n.Name)
+	}
+	return fullName(n.Package, n.Name)
+}
+
+func enumSchema(e *protobuf.Enum) *schema {
+	s := &schema{
+		Type:        "string",
+		Description: strings.Join(e.Docs, "\n"),
+		Deprecated:  isDeprecated(e.Options),
+	}
+	for _, v := range e.Values {
+		s.Enum = append(s.Enum, v.Name)
+	}
+	return s
+}
+
+// basicSchema returns the schema of the JSON mapping of the given protobuf
+// basic type. 64-bit integers are strings, as JSON numbers can not hold them.
+func basicSchema(name string) *schema {
+	switch name {
+	case "double", "float":
+		return &schema{Type: "number", Format: name}
+	case "int32", "sint32", "sfixed32":
+		return &schema{Type: "integer", Format: "int32"}
+	case "uint32", "fixed32":
+		return &schema{Type: "integer", Format: "int64"}
+	case "int64", "sint64", "sfixed64":
+		return &schema{Type: "string", Format: "int64"}
+	case "uint64", "fixed64":
+		return &schema{Type: "string", Format: "uint64"}
+	case "bool":
+		return &schema{Type: "boolean"}
+	case "bytes":
+		return &schema{Type: "string", Format: "byte"}
+	}
+	return &schema{Type: "string"}
+}
+
+// wellKnownTypes are the schemas of the JSON mapping of the well-known types,
+// indexed by their full protobuf name.
+var wellKnownTypes = map[string]func() *schema{
+	"google.protobuf.Timestamp":   func() *schema { return &schema{Type: "string", Format: "date-time"} },
+	"google.protobuf.Duration":    func() *schema { return &schema{Type: "string"} },
+	"google.protobuf.FieldMask":   func() *schema { return &schema{Type: "string"} },
+	"google.protobuf.Empty":       func() *schema { return &schema{Type: "object"} },
+	"google.protobuf.Any":         func() *schema { return &schema{Type: "object"} },
+	"google.protobuf.Struct":      func() *schema { return &schema{Type: "object"} },
+	"google.protobuf.Value":       func() *schema { return &schema{} },
+	"google.protobuf.ListValue":   func() *schema { return &schema{Type: "array", Items: &schema{}} },
+	"google.protobuf.DoubleValue": func() *schema { return basicSchema("double") },
+	"google.protobuf.FloatValue":  func() *schema { return basicSchema("float") },
+	"google.protobuf.Int64Value":  func() *schema { return basicSchema("int64") },
+	"google.protobuf.UInt64Value": func() *schema { return basicSchema("uint64") },
+	"google.protobuf.Int32Value":  func() *schema { return basicSchema("int32") },
+	"google.protobuf.UInt32Value": func() *schema { return basicSchema("uint32") },
+	"google.protobuf.BoolValue":   func() *schema { return basicSchema("bool") },
+	"google.protobuf.StringValue": func() *schema { return basicSchema("string") },
+	"google.protobuf.BytesValue":  func() *schema { return basicSchema("bytes") },
+}
+
+func underlying(typ protobuf.Type) protobuf.Type {
+	if a, ok := typ.(*protobuf.Alias); ok {
+		return underlying(a.Underlying)
+	}
+	return typ
+}
+
+func findField(msg *protobuf.Message, name string) *protobuf.Field {
+	if msg == nil {
+		return nil
+	}
+
+	for _, f := range msg.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func isDeprecated(opts protobuf.Options) bool {
+	v, ok := opts["deprecated"]
+	return ok && v.String() == "true"
+}
+
+func fullName(pkg, name string) string {
+	return pkg + "." + name
+}
+
+// pathVar matches the variables of a path template, like {id} or
+// {name=shelves/*}.
+var pathVar = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// pathTemplate returns the OpenAPI path of the given path template, whose
+// variables only have their name.
+func pathTemplate(path string) string {
+	return pathVar.ReplaceAllString(path, "{$1}")
+}
+
+// pathParams returns the names of the variables of the given path template.
+func pathParams(path string) []string {
+	var names []string
+	for _, m := range pathVar.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
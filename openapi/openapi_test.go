@@ -0,0 +1,337 @@
+package openapi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Users",
+    "version": "v1"
+  },
+  "tags": [
+    {
+      "name": "UserService",
+      "description": "UserService manages the users."
+    }
+  ],
+  "paths": {
+    "/v1/users": {
+      "get": {
+        "operationId": "UserService_ListUsers",
+        "tags": [
+          "UserService"
+        ],
+        "parameters": [
+          {
+            "name": "pageSize",
+            "in": "query",
+            "schema": {
+              "type": "integer",
+              "format": "int32"
+            }
+          },
+          {
+            "name": "status",
+            "in": "query",
+            "schema": {
+              "$ref": "#/components/schemas/acme.users.v1.Status"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "A successful response.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/acme.users.v1.ListUsersResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "post": {
+        "operationId": "UserService_CreateUser",
+        "summary": "CreateUser creates a user.",
+        "description": "CreateUser creates a user.\nIts id is ignored.",
+        "tags": [
+          "UserService"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/acme.users.v1.User"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "A successful response.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/acme.users.v1.User"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/v1/users/{id}": {
+      "patch": {
+        "operationId": "UserService_UpdateUser",
+        "tags": [
+          "UserService"
+        ],
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "description": "ID of the user.",
+            "required": true,
+            "schema": {
+              "type": "string",
+              "format": "int64"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/acme.users.v1.User"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "A successful response.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        },
+        "deprecated": true
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "acme.users.v1.ListUsersRequest": {
+        "type": "object",
+        "properties": {
+          "pageSize": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "status": {
+            "$ref": "#/components/schemas/acme.users.v1.Status"
+          },
+          "filter": {
+            "$ref": "#/components/schemas/acme.users.v1.User"
+          }
+        }
+      },
+      "acme.users.v1.ListUsersResponse": {
+        "type": "object",
+        "properties": {
+          "users": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/acme.users.v1.User"
+            }
+          }
+        }
+      },
+      "acme.users.v1.Status": {
+        "type": "string",
+        "enum": [
+          "STATUS_UNSPECIFIED",
+          "STATUS_ACTIVE"
+        ]
+      },
+      "acme.users.v1.UpdateUserRequest": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string",
+            "format": "int64",
+            "description": "ID of the user."
+          },
+          "user": {
+            "$ref": "#/components/schemas/acme.users.v1.User"
+          }
+        }
+      },
+      "acme.users.v1.User": {
+        "type": "object",
+        "description": "User is a user.",
+        "properties": {
+          "id": {
+            "type": "string",
+            "format": "int64"
+          },
+          "display_name": {
+            "type": "string"
+          },
+          "createdAt": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "labels": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "score": {
+            "type": "number",
+            "format": "double",
+            "deprecated": true
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+func testPackage() *protobuf.Package {
+	user := protobuf.NewNamed("", "User")
+	return &protobuf.Package{
+		Name: "acme.users.v1",
+		Path: "github.com/acme/users",
+		Enums: []*protobuf.Enum{
+			{Name: "Status", Values: []*protobuf.EnumValue{{Name: "STATUS_UNSPECIFIED"}, {Name: "STATUS_ACTIVE", Value: 1}}},
+		},
+		Messages: []*protobuf.Message{
+			{
+				Docs: []string{"User is a user."},
+				Name: "User",
+				Fields: []*protobuf.Field{
+					{Name: "id", Pos: 1, Type: protobuf.NewBasic("int64")},
+					{Name: "display_name", Pos: 2, Type: protobuf.NewBasic("string"), Options: protobuf.Options{"json_name": protobuf.NewStringValue("display_name")}},
+					{Name: "created_at", Pos: 3, Type: protobuf.NewNamed("google.protobuf", "Timestamp")},
+					{Name: "labels", Pos: 4, Type: protobuf.NewMap(protobuf.NewBasic("string"), protobuf.NewBasic("string"))},
+					{Name: "score", Pos: 5, Type: protobuf.NewBasic("double"), Options: protobuf.Options{"deprecated": protobuf.NewLiteralValue("true")}},
+				},
+			},
+			{
+				Name: "ListUsersRequest",
+				Fields: []*protobuf.Field{
+					{Name: "page_size", Pos: 1, Type: protobuf.NewBasic("int32")},
+					{Name: "status", Pos: 2, Type: protobuf.NewNamed("acme.users.v1", "Status")},
+					{Name: "filter", Pos: 3, Type: user},
+				},
+			},
+			{
+				Name: "ListUsersResponse",
+				Fields: []*protobuf.Field{
+					{Name: "users", Pos: 1, Repeated: true, Type: user},
+				},
+			},
+			{
+				Name: "UpdateUserRequest",
+				Fields: []*protobuf.Field{
+					{Docs: []string{"ID of the user."}, Name: "id", Pos: 1, Type: protobuf.NewBasic("int64")},
+					{Name: "user", Pos: 2, Type: user},
+				},
+			},
+		},
+		Services: []*protobuf.Service{
+			{
+				Docs: []string{"UserService manages the users."},
+				Name: "UserService",
+				RPCs: []*protobuf.RPC{
+					{
+						Docs:   []string{"CreateUser creates a user.", "Its id is ignored."},
+						Name:   "CreateUser",
+						Input:  user,
+						Output: user,
+						HTTP:   &scanner.HTTPRule{Method: "post", Path: "/v1/users", Body: "*"},
+					},
+					{
+						Name:   "ListUsers",
+						Input:  protobuf.NewGeneratedNamed("acme.users.v1", "ListUsersRequest"),
+						Output: protobuf.NewGeneratedNamed("acme.users.v1", "ListUsersResponse"),
+						HTTP:   &scanner.HTTPRule{Method: "get", Path: "/v1/users"},
+					},
+					{
+						Name:    "UpdateUser",
+						Input:   protobuf.NewGeneratedNamed("acme.users.v1", "UpdateUserRequest"),
+						Output:  protobuf.NewNamed("google.protobuf", "Empty"),
+						Options: protobuf.Options{"deprecated": protobuf.NewLiteralValue("true")},
+						HTTP:    &scanner.HTTPRule{Method: "patch", Path: "/v1/users/{id=*}", Body: "user"},
+					},
+					{
+						Name:   "DeleteAll",
+						Input:  protobuf.NewNamed("google.protobuf", "Empty"),
+						Output: protobuf.NewNamed("google.protobuf", "Empty"),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	require := require.New(t)
+	dir, err := ioutil.TempDir("", "proteus-openapi")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	g := NewGenerator(dir)
+	g.SetInfo("Users", "v1")
+	require.NoError(g.Generate([]*protobuf.Package{testPackage()}))
+	require.Equal(filepath.Join(dir, FileName), g.FileName())
+
+	content, err := ioutil.ReadFile(g.FileName())
+	require.NoError(err)
+	require.Equal(expectedDocument, string(content))
+}
+
+func TestDocumentInfo(t *testing.T) {
+	g := NewGenerator("")
+	doc := g.document([]*protobuf.Package{{Name: "foo"}, {Name: "bar"}})
+	require.Equal(t, info{Title: "foo, bar", Version: DefaultAPIVersion}, doc.Info)
+	require.Empty(t, doc.Paths)
+	require.Empty(t, doc.Tags)
+}
+
+func TestFieldSchemaUnit(t *testing.T) {
+	pkg := &protobuf.Package{Name: "foo"}
+	d := newDefinitions([]*protobuf.Package{pkg})
+	unit := protobuf.Options{"(proteus.unit)": protobuf.NewStringValue("ms")}
+
+	s := d.fieldSchema(pkg, &protobuf.Field{Name: "timeout", Type: protobuf.NewBasic("int64"), Options: unit})
+	require.Equal(t, &schema{Type: "string", Format: "int64", Unit: "ms"}, s)
+
+	p := d.parameter(pkg, &protobuf.Field{Name: "delays", Repeated: true, Type: protobuf.NewBasic("int32"), Options: unit}, "delays", "query")
+	require.Equal(t, "ms", p.Schema.Unit)
+}
+
+func TestPathTemplate(t *testing.T) {
+	require.Equal(t, "/v1/{name}/books/{id}", pathTemplate("/v1/{name=shelves/*}/books/{id}"))
+	require.Equal(t, []string{"name", "id"}, pathParams("/v1/{name=shelves/*}/books/{id}"))
+	require.Nil(t, pathParams("/v1/books"))
+}
@@ -0,0 +1,6 @@
+// Package options holds options.proto, with the custom protobuf options set
+// by proteus in the generated .proto files. Generated files import it from
+// gitlab.com/ThatTomPerson/proteus/options/options.proto when they use any of
+// them, so the base path of the Go packages must be one of the paths protoc
+// looks for imports in.
+package options // import "gitlab.com/ThatTomPerson/proteus/options"
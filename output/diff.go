@@ -0,0 +1,208 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines written around the changes.
+const context = 3
+
+// maxLCS is the largest product of the lengths of the changed parts of the
+// files for which the smallest diff is found. Larger parts are written as
+// all their old lines removed and all their new lines added.
+const maxLCS = 4 << 20
+
+// Diff returns the unified diff between the old and the new contents of the
+// file with the given name, as written by diff -u. Old contents that are nil
+// are diffed as /dev/null, as they are of a file that does not exist yet. It
+// is empty if the contents are the same.
+func Diff(file string, old, new []byte) string {
+	if bytes.Equal(old, new) && old != nil {
+		return ""
+	}
+
+	ops := diffLines(splitLines(old), splitLines(new))
+
+	var buf bytes.Buffer
+	from := file
+	if old == nil {
+		from = "/dev/null"
+	}
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", from, file)
+	for _, h := range hunks(ops) {
+		writeHunk(&buf, ops[h[0]:h[1]])
+	}
+	return buf.String()
+}
+
+// splitLines splits the data in lines, keeping their line breaks. Only the
+// last line may not have one.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// op is a line of the diff, which is kept, removed or added.
+type op struct {
+	kind byte
+	line string
+	// old and new are the numbers of the old and new lines before it.
+	old, new int
+}
+
+// diffLines returns the lines kept, removed and added to turn the old lines
+// into the new ones.
+func diffLines(old, new []string) []op {
+	var prefix int
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+
+	var suffix int
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+
+	var ops []op
+	add := func(kind byte, line string) {
+		var o, n int
+		if len(ops) > 0 {
+			last := ops[len(ops)-1]
+			o, n = last.old, last.new
+			if last.kind != '+' {
+				o++
+			}
+			if last.kind != '-' {
+				n++
+			}
+		}
+		ops = append(ops, op{kind: kind, line: line, old: o, new: n})
+	}
+
+	for _, l := range old[:prefix] {
+		add(' ', l)
+	}
+
+	a, b := old[prefix:len(old)-suffix], new[prefix:len(new)-suffix]
+	if len(a)*len(b) > maxLCS {
+		for _, l := range a {
+			add('-', l)
+		}
+		for _, l := range b {
+			add('+', l)
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of
+		// a[i:] and b[j:].
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				add(' ', a[i])
+				i++
+				j++
+			case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+				add('-', a[i])
+				i++
+			default:
+				add('+', b[j])
+				j++
+			}
+		}
+	}
+
+	for _, l := range old[len(old)-suffix:] {
+		add(' ', l)
+	}
+	return ops
+}
+
+// hunks returns the start and end of the ops of every hunk, which are the
+// changes closer than twice the context lines along with the lines around
+// them.
+func hunks(ops []op) [][2]int {
+	var result [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for j := i; j < len(ops) && j <= end+2*context; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+
+		i = end + context
+		if i >= len(ops) {
+			i = len(ops) - 1
+		}
+		result = append(result, [2]int{start, i + 1})
+	}
+	return result
+}
+
+func writeHunk(buf *bytes.Buffer, ops []op) {
+	var old, new int
+	for _, o := range ops {
+		if o.kind != '+' {
+			old++
+		}
+		if o.kind != '-' {
+			new++
+		}
+	}
+
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(ops[0].old, old), hunkRange(ops[0].new, new))
+	for _, o := range ops {
+		buf.WriteByte(o.kind)
+		buf.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange returns the range of a hunk with the given number of lines
+// after the given number of lines of the file.
+func hunkRange(before, lines int) string {
+	switch lines {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, lines)
+	}
+}
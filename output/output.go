@@ -0,0 +1,120 @@
+// Package output writes the files generated by proteus. In dry-run mode, the
+// files are kept in memory instead, so they can be compared with the ones on
+// disk to tell whether the generated files are up to date.
+package output // import "gitlab.com/ThatTomPerson/proteus/output"
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+var (
+	mu      sync.Mutex
+	dryRun  bool
+	written []string
+	files   map[string][]byte
+)
+
+// DryRun makes the files be kept in memory instead of written to disk,
+// forgetting the ones kept so far.
+func DryRun() {
+	mu.Lock()
+	defer mu.Unlock()
+	dryRun = true
+	written = nil
+	files = make(map[string][]byte)
+}
+
+// EndDryRun makes the files be written to disk again, forgetting the ones
+// kept in memory.
+func EndDryRun() {
+	mu.Lock()
+	defer mu.Unlock()
+	dryRun = false
+	written = nil
+	files = nil
+}
+
+// IsDryRun reports whether the files are kept in memory.
+func IsDryRun() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return dryRun
+}
+
+// WriteFile writes the data to the file with the given name, like
+// ioutil.WriteFile, or keeps it in memory in dry-run mode.
+func WriteFile(file string, data []byte, perm os.FileMode) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !dryRun {
+		return ioutil.WriteFile(file, data, perm)
+	}
+
+	if _, ok := files[file]; !ok {
+		written = append(written, file)
+	}
+	files[file] = append([]byte(nil), data...)
+	return nil
+}
+
+// ReadFile reads the file with the given name, like ioutil.ReadFile. In
+// dry-run mode, the data kept in memory for it is returned, if any.
+func ReadFile(file string) ([]byte, error) {
+	mu.Lock()
+	data, ok := files[file]
+	mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	return ioutil.ReadFile(file)
+}
+
+// MkdirAll creates the directory with the given path and its parents, like
+// os.MkdirAll. It does nothing in dry-run mode.
+func MkdirAll(path string, perm os.FileMode) error {
+	if IsDryRun() {
+		return nil
+	}
+	return os.MkdirAll(path, perm)
+}
+
+// Change is a file whose contents kept in dry-run mode are not the ones on
+// disk.
+type Change struct {
+	// File is the name of the file.
+	File string
+	// Old are the contents on disk, which are nil if the file does not
+	// exist.
+	Old []byte
+	// New are the contents kept in memory.
+	New []byte
+}
+
+// Diff returns the unified diff from the contents on disk to the ones kept
+// in memory.
+func (c Change) Diff() string {
+	return Diff(c.File, c.Old, c.New)
+}
+
+// Changes returns the files kept in dry-run mode whose contents are not the
+// ones on disk, in the order they were first written.
+func Changes() ([]Change, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var changes []Change
+	for _, file := range written {
+		old, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if err != nil || !bytes.Equal(old, files[file]) {
+			changes = append(changes, Change{File: file, Old: old, New: files[file]})
+		}
+	}
+	return changes, nil
+}
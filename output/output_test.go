@@ -0,0 +1,97 @@
+package output
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-output")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	same := filepath.Join(dir, "same.proto")
+	changed := filepath.Join(dir, "changed.proto")
+	created := filepath.Join(dir, "sub", "created.proto")
+	require.NoError(WriteFile(same, []byte("same\n"), 0644))
+	require.NoError(WriteFile(changed, []byte("old\n"), 0644))
+
+	DryRun()
+	defer EndDryRun()
+
+	require.NoError(MkdirAll(filepath.Dir(created), 0755))
+	require.NoError(WriteFile(created, []byte("created\n"), 0644))
+	require.NoError(WriteFile(changed, []byte("new\n"), 0644))
+	require.NoError(WriteFile(same, []byte("same\n"), 0644))
+
+	data, err := ReadFile(changed)
+	require.NoError(err)
+	require.Equal("new\n", string(data))
+
+	_, err = os.Stat(filepath.Dir(created))
+	require.True(os.IsNotExist(err))
+
+	data, err = ioutil.ReadFile(changed)
+	require.NoError(err)
+	require.Equal("old\n", string(data))
+
+	changes, err := Changes()
+	require.NoError(err)
+	require.Equal([]Change{
+		{File: created, New: []byte("created\n")},
+		{File: changed, Old: []byte("old\n"), New: []byte("new\n")},
+	}, changes)
+}
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{
+			"changed line",
+			"a\nb\nc\nd\ne\nf\ng\nh\n",
+			"a\nb\nc\nd\nE\nf\ng\nh\n",
+			"--- foo.proto\n+++ foo.proto\n@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n",
+		},
+		{
+			"added and removed lines",
+			"a\nb\nc\n",
+			"b\nc\nd\n",
+			"--- foo.proto\n+++ foo.proto\n@@ -1,3 +1,3 @@\n-a\n b\n c\n+d\n",
+		},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"--- foo.proto\n+++ foo.proto\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+		{
+			"no newline at end of file",
+			"a\n",
+			"a",
+			"--- foo.proto\n+++ foo.proto\n@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expected, Diff("foo.proto", []byte(c.old), []byte(c.new)))
+		})
+	}
+}
+
+func TestDiffNewFile(t *testing.T) {
+	require.Equal(t,
+		"--- /dev/null\n+++ foo.proto\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		Diff("foo.proto", nil, []byte("a\nb\n")),
+	)
+}
@@ -0,0 +1,141 @@
+// Package pool reuses the messages of the responses of the generated gRPC
+// servers, so they are not allocated for every call under high load.
+//
+// The generated code declares a Pool for every message of a package, like
+// UserPool for User, whose Get and Put methods can be used by anyone, and
+// the server adapters take their responses from the pools with GetFor. The
+// messages taken with GetFor are reset and put back in their pool by the
+// stats handler returned by StatsHandler, which NewGRPCServer adds, once the
+// call has ended and its response has been sent.
+package pool // import "gitlab.com/ThatTomPerson/proteus/pool"
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/stats"
+)
+
+// Pool is a pool of messages of type T, which are reset when they are put
+// back. Its zero value is an empty pool ready to use.
+type Pool[T any, P interface {
+	*T
+	Reset()
+}] struct {
+	p sync.Pool
+}
+
+// Get returns a message of the pool, or a new one if it is empty. The
+// message is zero, as the messages are reset when they are put back.
+func (p *Pool[T, P]) Get() P {
+	if m, ok := p.p.Get().(P); ok {
+		return m
+	}
+	return P(new(T))
+}
+
+// Put resets the given message and puts it back in the pool. The message
+// must not be used after calling Put. Nil messages are ignored.
+func (p *Pool[T, P]) Put(m P) {
+	if m == nil {
+		return
+	}
+
+	m.Reset()
+	p.p.Put(m)
+}
+
+// GetFor returns a message of the pool like Get, which is put back once the
+// call of the given context has ended, if the server has the stats handler
+// returned by StatsHandler. Otherwise, it is never put back. The message
+// must not be used after the call has ended, so it is meant for the
+// response of the call.
+func (p *Pool[T, P]) GetFor(ctx context.Context) P {
+	m := p.Get()
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		c.add(p, m)
+	}
+	return m
+}
+
+// put puts back the given message, which is a P.
+func (p *Pool[T, P]) put(m interface{}) {
+	p.Put(m.(P))
+}
+
+// putter is implemented by the pools, to put back messages of their type.
+type putter interface {
+	put(m interface{})
+}
+
+// entry is a message taken from a pool during a call.
+type entry struct {
+	pool putter
+	msg  interface{}
+}
+
+type callKey struct{}
+
+// call has the messages taken from their pools during a call, to put them
+// back once it has ended. The first one is kept apart, as most calls only
+// take their response.
+type call struct {
+	mu    sync.Mutex
+	first entry
+	rest  []entry
+}
+
+func (c *call) add(p putter, m interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := entry{pool: p, msg: m}
+	if c.first.pool == nil {
+		c.first = e
+	} else {
+		c.rest = append(c.rest, e)
+	}
+}
+
+// release puts back all the messages taken during the call.
+func (c *call) release() {
+	c.mu.Lock()
+	first, rest := c.first, c.rest
+	c.first, c.rest = entry{}, nil
+	c.mu.Unlock()
+
+	if first.pool != nil {
+		first.pool.put(first.msg)
+	}
+	for _, e := range rest {
+		e.pool.put(e.msg)
+	}
+}
+
+// StatsHandler returns the gRPC stats handler that puts back the messages
+// taken with GetFor during the calls of a server once they have ended.
+func StatsHandler() stats.Handler {
+	return statsHandler{}
+}
+
+type statsHandler struct{}
+
+func (statsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, callKey{}, new(call))
+}
+
+func (statsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.End); !ok || s.IsClient() {
+		return
+	}
+
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		c.release()
+	}
+}
+
+func (statsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (statsHandler) HandleConn(context.Context, stats.ConnStats) {}
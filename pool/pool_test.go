@@ -0,0 +1,133 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// resets gets the values of the messages when they are reset.
+var resets = make(chan string, 10)
+
+type message struct {
+	Value string
+}
+
+func (m *message) Reset() {
+	resets <- m.Value
+	*m = message{}
+}
+
+var messagePool Pool[message, *message]
+
+func TestPool(t *testing.T) {
+	require := require.New(t)
+
+	m := messagePool.Get()
+	require.NotNil(m)
+	m.Value = "foo"
+	messagePool.Put(m)
+	require.Equal("foo", <-resets)
+
+	messagePool.Put(nil)
+	for i := 0; i < 10; i++ {
+		require.Equal(&message{}, messagePool.Get(), "messages are zero")
+	}
+}
+
+func TestGetForWithoutHandler(t *testing.T) {
+	m := messagePool.GetFor(context.Background())
+	m.Value = "foo"
+	(statsHandler{}).HandleRPC(context.Background(), nil)
+	require.Empty(t, resets, "messages are not put back without the handler")
+}
+
+func TestCallRelease(t *testing.T) {
+	require := require.New(t)
+
+	ctx := (statsHandler{}).TagRPC(context.Background(), nil)
+	for _, v := range []string{"foo", "bar", "baz"} {
+		messagePool.GetFor(ctx).Value = v
+	}
+	require.Empty(resets)
+
+	ctx.Value(callKey{}).(*call).release()
+	require.Equal("foo", <-resets)
+	require.Equal("bar", <-resets)
+	require.Equal("baz", <-resets)
+
+	ctx.Value(callKey{}).(*call).release()
+	require.Empty(resets, "messages are put back once")
+}
+
+// codec encodes the value of a message as is.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(v.(*message).Value), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	v.(*message).Value = string(data)
+	return nil
+}
+
+func (codec) Name() string {
+	return "message"
+}
+
+var echoService = grpc.ServiceDesc{
+	ServiceName: "pool.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			var in message
+			if err := dec(&in); err != nil {
+				return nil, err
+			}
+
+			result := messagePool.GetFor(ctx)
+			result.Value = in.Value
+			return result, nil
+		},
+	}},
+}
+
+func TestStatsHandler(t *testing.T) {
+	require := require.New(t)
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.StatsHandler(StatsHandler()), grpc.ForceServerCodec(codec{}))
+	s.RegisterService(&echoService, struct{}{})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})),
+	)
+	require.NoError(err)
+	defer conn.Close()
+
+	var out message
+	require.NoError(conn.Invoke(context.Background(), "/pool.Echo/Echo", &message{Value: "hola"}, &out))
+	require.Equal("hola", out.Value, "the response is sent before it is put back")
+
+	select {
+	case v := <-resets:
+		require.Equal("hola", v)
+	case <-time.After(5 * time.Second):
+		require.Fail("the response was not put back")
+	}
+}
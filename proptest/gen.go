@@ -0,0 +1,620 @@
+package proptest
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// FileName is the name of the file generated in every package.
+const FileName = "proteus_property_test.go"
+
+// Generator generates the property-based tests of a package, written with
+// pgregory.net/rapid. For every message generated from a struct Foo of the
+// package, it generates a rapid generator of Foo values drawing its fields
+// following their validate rules, and a TestProteusFooRoundTrip test
+// checking that every value drawn is decoded from its encoding as it is. For
+// every enum Bar with the conversion funcs generated by enumconv, it
+// generates a TestProteusBarConversions test checking that BarFromProto and
+// BarToProto are inverse functions.
+//
+// The fields are drawn with the sizes and ranges of the validate rules, but
+// the fields with rules that can not be drawn, like email, and the fields of
+// types that are not basic types, enums, arrays or structs of the package,
+// like time.Time, keep their zero value. Nested messages are only drawn up to
+// a depth of 3, so recursive messages are finite.
+//
+// The file will be written to the package path and it will be named
+// "proteus_property_test.go". As it uses the generated protobuf code of the
+// package, it is only meant to be generated after it, and after the enum
+// conversion funcs.
+type Generator struct {
+	semantics protobuf.EnumSemantics
+}
+
+// NewGenerator creates a new Generator.
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// SetEnumSemantics sets the semantics of the enums that do not have them in
+// their docs or their package's, which tell whether their conversions reject
+// the unknown values.
+func (g *Generator) SetEnumSemantics(semantics protobuf.EnumSemantics) {
+	g.semantics = semantics
+}
+
+// Generate writes the property-based tests of the given package, whose
+// messages are the ones of the given proto package. Nothing is written if
+// it has no messages generated from its structs nor enums with conversion
+// funcs. It reports whether the file was written.
+func (g *Generator) Generate(pkg *scanner.Package, proto *protobuf.Package) (bool, error) {
+	declared, err := findFuncs(filepath.Join(goSrc, pkg.Path))
+	if err != nil {
+		return false, err
+	}
+
+	f := newFile(pkg, proto, declared, g.semantics)
+	if len(f.messages) == 0 && len(f.enums) == 0 {
+		return false, nil
+	}
+
+	data, err := f.build()
+	if err != nil {
+		return false, err
+	}
+
+	file := g.FileName(pkg.Path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return false, err
+	}
+
+	report.Info("Generated property tests: %s", file)
+	return true, nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, FileName)
+}
+
+// message is a message generated from a struct of the package.
+type message struct {
+	*scanner.Struct
+	proto *protobuf.Message
+}
+
+// enum is an enum of the package with conversion funcs.
+type enum struct {
+	*scanner.Enum
+	closed bool
+}
+
+// file is the file with the tests of a package.
+type file struct {
+	pkg      *scanner.Package
+	messages []message
+	enums    []enum
+	// structs are the names of the structs with a generator.
+	structs map[string]bool
+	// math reports whether the math package is used.
+	math bool
+}
+
+func newFile(pkg *scanner.Package, proto *protobuf.Package, declared map[string]bool, semantics protobuf.EnumSemantics) *file {
+	f := &file{pkg: pkg, structs: make(map[string]bool)}
+
+	structs := make(map[string]*scanner.Struct)
+	for _, s := range pkg.Structs {
+		if s.Generate && !s.Inline {
+			structs[s.Name] = s
+		}
+	}
+
+	for _, m := range proto.Messages {
+		if s, ok := structs[m.Name]; ok {
+			f.messages = append(f.messages, message{s, m})
+			f.structs[s.Name] = true
+		}
+	}
+
+	for _, e := range pkg.Enums {
+		if e.Integer && declared["IsKnown"+e.Name] && declared[e.Name+"FromProto"] && declared[e.Name+"ToProto"] {
+			closed := protobuf.EnumSemanticsOf(e, semantics) == protobuf.ClosedEnum
+			f.enums = append(f.enums, enum{e, closed})
+		}
+	}
+	return f
+}
+
+func (f *file) build() ([]byte, error) {
+	var body bytes.Buffer
+	if len(f.messages) > 0 {
+		body.WriteString("\n// proteusMaxDepth is the depth of the nested messages drawn, so recursive\n// messages are finite.\n")
+		body.WriteString("const proteusMaxDepth = 3\n")
+	}
+
+	for _, m := range f.messages {
+		f.writeMessage(&body, m)
+	}
+
+	for _, e := range f.enums {
+		f.writeEnum(&body, e)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(f.pkg.Path)))
+	buf.WriteString(fmt.Sprintf("package %s\n\n", f.pkg.Name))
+	buf.WriteString("import (\n")
+	if f.math {
+		buf.WriteString("\t\"math\"\n")
+	}
+	buf.WriteString("\t\"testing\"\n\n\t\"pgregory.net/rapid\"\n\n\t\"gitlab.com/ThatTomPerson/proteus/proptest\"\n)\n")
+	buf.Write(body.Bytes())
+
+	return format.Source(buf.Bytes())
+}
+
+func (f *file) writeMessage(buf *bytes.Buffer, m message) {
+	name := m.Name
+	buf.WriteString(fmt.Sprintf("\nfunc proteus%sGenerator(depth int) *rapid.Generator[%s] {\n", name, name))
+	buf.WriteString(fmt.Sprintf("\treturn rapid.Custom(func(t *rapid.T) %s {\n", name))
+	buf.WriteString(fmt.Sprintf("\t\tvar v %s\n", name))
+	for _, pf := range m.proto.Fields {
+		sf := structField(m.Struct, pf.GoName())
+		if sf == nil {
+			continue
+		}
+
+		r, ok := rulesOf(pf)
+		if !ok {
+			continue
+		}
+
+		expr, nested, ok := f.gen(sf.Type, r, pf.Optional)
+		if !ok {
+			continue
+		}
+
+		draw := fmt.Sprintf("v.%s = %s.Draw(t, %q)\n", sf.Name, expr, sf.Name)
+		if nested {
+			buf.WriteString("\t\tif depth < proteusMaxDepth {\n\t\t\t" + draw + "\t\t}\n")
+		} else {
+			buf.WriteString("\t\t" + draw)
+		}
+	}
+	buf.WriteString("\t\treturn v\n\t})\n}\n")
+
+	buf.WriteString(fmt.Sprintf("\nfunc TestProteus%sRoundTrip(t *testing.T) {\n", name))
+	buf.WriteString("\trapid.Check(t, func(t *rapid.T) {\n")
+	buf.WriteString(fmt.Sprintf("\t\tmsg := proteus%sGenerator(0).Draw(t, \"msg\")\n", name))
+	buf.WriteString(fmt.Sprintf("\t\tproptest.RoundTrip(t, &msg, new(%s))\n", name))
+	buf.WriteString("\t})\n}\n")
+}
+
+func (f *file) writeEnum(buf *bytes.Buffer, e enum) {
+	name := e.Name
+	var values []string
+	for _, v := range e.Values {
+		if v.Value >= math.MinInt32 && v.Value <= math.MaxInt32 {
+			values = append(values, fmt.Sprintf("int32(%s)", v.Name))
+		}
+	}
+
+	// the values of the enum are drawn along with others that fit in any
+	// integer type, which may not be known
+	gen := "rapid.Int32Range(0, 127)"
+	if len(values) > 0 {
+		gen = fmt.Sprintf("rapid.OneOf(rapid.SampledFrom([]int32{%s}), %s)", strings.Join(values, ", "), gen)
+	}
+
+	buf.WriteString(fmt.Sprintf("\nfunc TestProteus%sConversions(t *testing.T) {\n", name))
+	buf.WriteString("\trapid.Check(t, func(t *rapid.T) {\n")
+	buf.WriteString(fmt.Sprintf("\t\tv := %s.Draw(t, \"v\")\n", gen))
+	buf.WriteString(fmt.Sprintf("\t\tproptest.CheckEnum(t, v, %t, IsKnown%s, %sFromProto, %sToProto)\n", e.closed, name, name, name))
+	buf.WriteString("\t})\n}\n")
+}
+
+// gen returns the expression of the rapid generator of the values of the
+// given type following the given rules, whether it draws nested messages and
+// whether the values of the type can be drawn. Pointers to values that are
+// not messages are only drawn for optional fields.
+func (f *file) gen(typ scanner.Type, r rules, optional bool) (string, bool, bool) {
+	switch ty := typ.(type) {
+	case *scanner.Basic:
+		if ty.Repeated {
+			if ty.Nullable {
+				return "", false, false
+			}
+
+			if ty.Name == "byte" {
+				if n, ok := r["len"]; ok {
+					return fmt.Sprintf("rapid.SliceOfN(rapid.Byte(), %s, %s)", n, n), false, true
+				}
+				return sliceOf("rapid.Byte()", r, "min_len", "max_len"), false, true
+			}
+
+			elem, ok := f.basic(ty.Name, nil)
+			return sliceOf(elem, r, "min_items", "max_items"), false, ok
+		}
+
+		expr, ok := f.basic(ty.Name, r)
+		if ty.Nullable {
+			if !optional {
+				return "", false, false
+			}
+			expr = fmt.Sprintf("rapid.Ptr(%s, true)", expr)
+		}
+		return expr, false, ok
+	case *scanner.Named:
+		elem, nested, ok := f.named(ty)
+		if !ok {
+			return "", false, false
+		}
+
+		if ty.Repeated {
+			if ty.Nullable {
+				elem = fmt.Sprintf("rapid.Ptr(%s, false)", elem)
+			}
+			return sliceOf(elem, r, "min_items", "max_items"), nested, true
+		}
+
+		if ty.Nullable {
+			if !nested && !optional {
+				return "", false, false
+			}
+			_, required := r["required"]
+			elem = fmt.Sprintf("rapid.Ptr(%s, %t)", elem, !required)
+		}
+		return elem, nested, true
+	case *scanner.Alias:
+		return f.alias(ty, r, optional)
+	case *scanner.Map:
+		key, _, ok := f.gen(ty.Key, nil, false)
+		if !ok {
+			return "", false, false
+		}
+
+		// nil values are decoded as empty messages
+		value, nested, ok := f.gen(ty.Value, rules{"required": ""}, false)
+		if !ok {
+			return "", false, false
+		}
+
+		if _, ok := r["min_pairs"]; !ok {
+			if _, ok := r["max_pairs"]; !ok {
+				return fmt.Sprintf("rapid.MapOf(%s, %s)", key, value), nested, true
+			}
+		}
+		return fmt.Sprintf("rapid.MapOfN(%s, %s, %s, %s)", key, value, r.get("min_pairs", "-1"), r.get("max_pairs", "-1")), nested, true
+	}
+
+	return "", false, false
+}
+
+// named returns the expression of the rapid generator of the values of the
+// given named type, which is a struct with a generator, an enum or an array
+// of the package, and whether it draws nested messages.
+func (f *file) named(ty *scanner.Named) (string, bool, bool) {
+	if ty.Path != f.pkg.Path || ty.Inline {
+		return "", false, false
+	}
+
+	if f.structs[ty.Name] {
+		return fmt.Sprintf("proteus%sGenerator(depth+1)", ty.Name), true, true
+	}
+
+	for _, e := range f.pkg.Enums {
+		if e.Name != ty.Name {
+			continue
+		}
+
+		var values []string
+		for _, v := range e.Values {
+			if v.Value >= math.MinInt32 && v.Value <= math.MaxInt32 {
+				values = append(values, v.Name)
+			}
+		}
+
+		if !e.Integer || len(values) == 0 {
+			return "", false, false
+		}
+		return fmt.Sprintf("rapid.SampledFrom([]%s{%s})", ty.Name, strings.Join(values, ", ")), false, true
+	}
+
+	for _, a := range f.pkg.Arrays {
+		if a.Name == ty.Name {
+			return fmt.Sprintf("rapid.Make[%s]()", ty.Name), false, true
+		}
+	}
+	return "", false, false
+}
+
+// alias returns the expression of the rapid generator of the values of the
+// given type declared in the package, which are converted from the values
+// of its underlying type.
+func (f *file) alias(ty *scanner.Alias, r rules, optional bool) (string, bool, bool) {
+	named, ok := ty.Type.(*scanner.Named)
+	if !ok || named.Path != f.pkg.Path {
+		return "", false, false
+	}
+
+	// the rules of a slice of the type apply to the slice, not to its
+	// elements
+	elemRules := r
+	if named.Repeated {
+		elemRules = nil
+	}
+
+	under, nested, ok := f.gen(ty.Underlying, elemRules, false)
+	if !ok {
+		return "", false, false
+	}
+
+	goType, ok := goTypeOf(ty.Underlying)
+	if !ok {
+		return "", false, false
+	}
+
+	expr := fmt.Sprintf("rapid.Map(%s, func(v %s) %s { return %s(v) })", under, goType, named.Name, named.Name)
+	switch {
+	case named.Repeated:
+		if named.Nullable {
+			return "", false, false
+		}
+		expr = sliceOf(expr, r, "min_items", "max_items")
+	case named.Nullable:
+		if !optional {
+			return "", false, false
+		}
+		expr = fmt.Sprintf("rapid.Ptr(%s, true)", expr)
+	}
+	return expr, nested, true
+}
+
+// numbers are the rapid generators of the numeric basic types, with their
+// bounds.
+var numbers = map[string]struct {
+	gen      string
+	min, max string
+}{
+	"int":     {"Int", "math.MinInt", "math.MaxInt"},
+	"int8":    {"Int8", "math.MinInt8", "math.MaxInt8"},
+	"int16":   {"Int16", "math.MinInt16", "math.MaxInt16"},
+	"int32":   {"Int32", "math.MinInt32", "math.MaxInt32"},
+	"rune":    {"Int32", "math.MinInt32", "math.MaxInt32"},
+	"int64":   {"Int64", "math.MinInt64", "math.MaxInt64"},
+	"uint":    {"Uint", "0", "math.MaxUint"},
+	"uint8":   {"Uint8", "0", "math.MaxUint8"},
+	"byte":    {"Byte", "0", "math.MaxUint8"},
+	"uint16":  {"Uint16", "0", "math.MaxUint16"},
+	"uint32":  {"Uint32", "0", "math.MaxUint32"},
+	"uint64":  {"Uint64", "0", "math.MaxUint64"},
+	"uintptr": {"Uintptr", "0", "^uintptr(0)"},
+	"float32": {"Float32", "-math.MaxFloat32", "math.MaxFloat32"},
+	"float64": {"Float64", "-math.MaxFloat64", "math.MaxFloat64"},
+}
+
+// basic returns the expression of the rapid generator of the values of the
+// basic type with the given name following the given rules.
+func (f *file) basic(name string, r rules) (string, bool) {
+	if v, ok := r["const"]; ok {
+		if name == "string" {
+			return fmt.Sprintf("rapid.Just(%s)", v), true
+		}
+		return fmt.Sprintf("rapid.Just(%s(%s))", name, v), true
+	}
+
+	switch name {
+	case "bool":
+		return "rapid.Bool()", true
+	case "string":
+		if n, ok := r["len"]; ok {
+			return fmt.Sprintf("rapid.StringN(%s, %s, -1)", n, n), true
+		}
+
+		if _, ok := r["min_len"]; !ok {
+			if _, ok := r["max_len"]; !ok {
+				return "rapid.String()", true
+			}
+		}
+		return fmt.Sprintf("rapid.StringN(%s, %s, -1)", r.get("min_len", "-1"), r.get("max_len", "-1")), true
+	}
+
+	n, ok := numbers[name]
+	if !ok {
+		return "", false
+	}
+
+	min, hasMin := r["gte"]
+	max, hasMax := r["lte"]
+	float := strings.HasPrefix(name, "float")
+	if !float {
+		// the exclusive bounds of integers are the next inclusive ones
+		if v, ok := r["gt"]; ok {
+			min, hasMin = nextInt(v, 1), true
+		}
+		if v, ok := r["lt"]; ok {
+			max, hasMax = nextInt(v, -1), true
+		}
+	}
+
+	expr := fmt.Sprintf("rapid.%s()", n.gen)
+	if hasMin || hasMax {
+		if !hasMin {
+			min = n.min
+		}
+		if !hasMax {
+			max = n.max
+		}
+
+		f.math = f.math || strings.Contains(min+max, "math.")
+		expr = fmt.Sprintf("rapid.%sRange(%s, %s)", n.gen, min, max)
+	}
+
+	if float {
+		var conds []string
+		if v, ok := r["gt"]; ok {
+			conds = append(conds, "v > "+v)
+		}
+		if v, ok := r["lt"]; ok {
+			conds = append(conds, "v < "+v)
+		}
+
+		if len(conds) > 0 {
+			expr = fmt.Sprintf("%s.Filter(func(v %s) bool { return %s })", expr, name, strings.Join(conds, " && "))
+		}
+	}
+	return expr, true
+}
+
+// nextInt returns the integer next to the given one in the given direction.
+func nextInt(v string, dir int64) string {
+	if strings.HasPrefix(v, "-") {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return strconv.FormatInt(n+dir, 10)
+	}
+
+	n, _ := strconv.ParseUint(v, 10, 64)
+	if dir < 0 {
+		if n == 0 {
+			return "-1"
+		}
+		return strconv.FormatUint(n-1, 10)
+	}
+	return strconv.FormatUint(n+1, 10)
+}
+
+// sliceOf returns the expression of the rapid generator of slices of the
+// given elements with the sizes of the given rules.
+func sliceOf(elem string, r rules, min, max string) string {
+	if _, ok := r[min]; !ok {
+		if _, ok := r[max]; !ok {
+			return fmt.Sprintf("rapid.SliceOf(%s)", elem)
+		}
+	}
+	return fmt.Sprintf("rapid.SliceOfN(%s, %s, %s)", elem, r.get(min, "-1"), r.get(max, "-1"))
+}
+
+// goTypeOf returns the Go type of the given underlying type of a type
+// declaration, which can be a basic type, a slice of them or a map of them.
+func goTypeOf(typ scanner.Type) (string, bool) {
+	switch ty := typ.(type) {
+	case *scanner.Basic:
+		if ty.Repeated {
+			return "[]" + ty.Name, true
+		}
+		return ty.Name, true
+	case *scanner.Map:
+		key, ok := goTypeOf(ty.Key)
+		if !ok {
+			return "", false
+		}
+
+		value, ok := goTypeOf(ty.Value)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("map[%s]%s", key, value), true
+	}
+	return "", false
+}
+
+// rules are the protoc-gen-validate rules of a field, indexed by their name
+// without the kind, along with their values as Go literals.
+type rules map[string]string
+
+// drawnRules are the rules the generators can follow.
+var drawnRules = map[string]bool{
+	"const":        true,
+	"defined_only": true,
+	"gt":           true,
+	"gte":          true,
+	"len":          true,
+	"lt":           true,
+	"lte":          true,
+	"max_items":    true,
+	"max_len":      true,
+	"max_pairs":    true,
+	"min_items":    true,
+	"min_len":      true,
+	"min_pairs":    true,
+	"required":     true,
+}
+
+// rulesOf returns the rules of the given field, and whether all of them can
+// be followed.
+func rulesOf(f *protobuf.Field) (rules, bool) {
+	const prefix = "(validate.rules)."
+
+	r := make(rules)
+	for name, v := range f.Options {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		name = name[strings.LastIndex(name, ".")+1:]
+		if !drawnRules[name] {
+			return nil, false
+		}
+		r[name] = v.String()
+	}
+	return r, true
+}
+
+func (r rules) get(name, def string) string {
+	if v, ok := r[name]; ok {
+		return v
+	}
+	return def
+}
+
+// structField returns the field of the given struct with the given name, if
+// any.
+func structField(s *scanner.Struct, name string) *scanner.Field {
+	for _, f := range s.Fields {
+		if f.Name == name && !f.Reserved && f.Type != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// findFuncs returns the names of the funcs in the Go files of the given
+// folder, but the tests.
+func findFuncs(dir string) (map[string]bool, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+					names[fn.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
@@ -0,0 +1,182 @@
+package proptest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedFile = `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo
+
+import (
+	"math"
+	"testing"
+
+	"pgregory.net/rapid"
+
+	"gitlab.com/ThatTomPerson/proteus/proptest"
+)
+
+// proteusMaxDepth is the depth of the nested messages drawn, so recursive
+// messages are finite.
+const proteusMaxDepth = 3
+
+func proteusUserGenerator(depth int) *rapid.Generator[User] {
+	return rapid.Custom(func(t *rapid.T) User {
+		var v User
+		v.Name = rapid.StringN(1, 10, -1).Draw(t, "Name")
+		v.Age = rapid.IntRange(19, math.MaxInt).Draw(t, "Age")
+		v.Score = rapid.Float64Range(0, 1).Filter(func(v float64) bool { return v < 1 }).Draw(t, "Score")
+		v.Nick = rapid.Ptr(rapid.String(), true).Draw(t, "Nick")
+		v.Avatar = rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "Avatar")
+		v.Tags = rapid.SliceOfN(rapid.Map(rapid.String(), func(v string) Tag { return Tag(v) }), -1, 3).Draw(t, "Tags")
+		v.Status = rapid.SampledFrom([]Status{Active, Banned}).Draw(t, "Status")
+		if depth < proteusMaxDepth {
+			v.Friend = rapid.Ptr(proteusUserGenerator(depth+1), false).Draw(t, "Friend")
+		}
+		return v
+	})
+}
+
+func TestProteusUserRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		msg := proteusUserGenerator(0).Draw(t, "msg")
+		proptest.RoundTrip(t, &msg, new(User))
+	})
+}
+
+func TestProteusStatusConversions(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		v := rapid.OneOf(rapid.SampledFrom([]int32{int32(Active), int32(Banned)}), rapid.Int32Range(0, 127)).Draw(t, "v")
+		proptest.CheckEnum(t, v, true, IsKnownStatus, StatusFromProto, StatusToProto)
+	})
+}
+`
+
+func TestBuildFile(t *testing.T) {
+	require := require.New(t)
+
+	named := func(name string) *scanner.Named {
+		return scanner.NewNamed("gitlab.com/foo", name).(*scanner.Named)
+	}
+
+	nick := scanner.NewBasic("string")
+	nick.SetNullable(true)
+	avatar := scanner.NewBasic("byte")
+	avatar.SetRepeated(true)
+	tag := named("Tag")
+	tag.SetRepeated(true)
+	friend := named("User")
+	friend.SetNullable(true)
+
+	user := &scanner.Struct{
+		Generate: true,
+		Name:     "User",
+		Fields: []*scanner.Field{
+			{Name: "Name", Type: scanner.NewBasic("string")},
+			{Name: "Email", Type: scanner.NewBasic("string")},
+			{Name: "Age", Type: scanner.NewBasic("int")},
+			{Name: "Score", Type: scanner.NewBasic("float64")},
+			{Name: "Nick", Type: nick},
+			{Name: "Born", Type: scanner.NewNamed("time", "Time")},
+			{Name: "Avatar", Type: avatar},
+			{Name: "Tags", Type: scanner.NewAlias(tag, scanner.NewBasic("string"))},
+			{Name: "Status", Type: named("Status")},
+			{Name: "Friend", Type: friend},
+			{Name: "Secret", Reserved: true},
+		},
+	}
+
+	pkg := &scanner.Package{
+		Name:    "foo",
+		Path:    "gitlab.com/foo",
+		Structs: []*scanner.Struct{user},
+		Enums: []*scanner.Enum{
+			{
+				Name:      "Status",
+				Semantics: "closed",
+				Integer:   true,
+				Values:    []*scanner.EnumValue{{Name: "Active", Value: 1}, {Name: "Banned", Value: 2}},
+			},
+			{
+				Name:    "Role",
+				Integer: true,
+				Values:  []*scanner.EnumValue{{Name: "Admin", Value: 0}},
+			},
+		},
+	}
+
+	field := func(name string, opts protobuf.Options) *protobuf.Field {
+		return &protobuf.Field{Name: name, Options: opts}
+	}
+	lit := protobuf.NewLiteralValue
+
+	nickField := field("nick", nil)
+	nickField.Optional = true
+	proto := &protobuf.Package{
+		Messages: []*protobuf.Message{
+			{
+				Name: "User",
+				Fields: []*protobuf.Field{
+					field("name", protobuf.Options{
+						"(validate.rules).string.min_len": lit("1"),
+						"(validate.rules).string.max_len": lit("10"),
+					}),
+					field("email", protobuf.Options{"(validate.rules).string.email": lit("true")}),
+					field("age", protobuf.Options{"(validate.rules).int64.gt": lit("18")}),
+					field("score", protobuf.Options{
+						"(validate.rules).double.gte": lit("0"),
+						"(validate.rules).double.lte": lit("1"),
+						"(validate.rules).double.lt":  lit("1"),
+					}),
+					nickField,
+					field("born", nil),
+					field("avatar", protobuf.Options{"(validate.rules).bytes.len": lit("4")}),
+					field("tags", protobuf.Options{"(validate.rules).repeated.max_items": lit("3")}),
+					field("status", protobuf.Options{"(validate.rules).enum.defined_only": lit("true")}),
+					field("friend", protobuf.Options{"(validate.rules).message.required": lit("true")}),
+				},
+			},
+			{Name: "User_GetFriendRequest"},
+		},
+	}
+
+	declared := map[string]bool{
+		"IsKnownStatus": true, "StatusFromProto": true, "StatusToProto": true,
+		"IsKnownRole": true, "RoleFromProto": true,
+	}
+
+	data, err := newFile(pkg, proto, declared, "").build()
+	require.Nil(err)
+	require.Equal(expectedFile, string(data))
+}
+
+func TestBuildFileWithoutMath(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &scanner.Package{
+		Name: "foo",
+		Path: "gitlab.com/foo",
+		Structs: []*scanner.Struct{
+			{Generate: true, Name: "Point", Fields: []*scanner.Field{{Name: "X", Type: scanner.NewBasic("int32")}}},
+			{Name: "Hidden"},
+		},
+	}
+	proto := &protobuf.Package{
+		Messages: []*protobuf.Message{
+			{Name: "Point", Fields: []*protobuf.Field{{Name: "x"}}},
+			{Name: "Hidden"},
+		},
+	}
+
+	data, err := newFile(pkg, proto, nil, "").build()
+	require.Nil(err)
+	require.NotContains(string(data), `"math"`)
+	require.Contains(string(data), `v.X = rapid.Int32().Draw(t, "X")`)
+	require.NotContains(string(data), "Hidden")
+}
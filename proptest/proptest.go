@@ -0,0 +1,151 @@
+// Package proptest has the checks of the property-based tests generated by
+// proteus, which draw random values of the messages and enums of a package
+// and check that converting them to protobuf and back gives the same values.
+package proptest // import "gitlab.com/ThatTomPerson/proteus/proptest"
+
+import (
+	"reflect"
+)
+
+// Message is a message with the Go code generated by gogo, which encodes and
+// decodes its wire format.
+type Message interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// TB is the part of testing.TB and rapid.T used by the checks.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
+
+// Integer is the constraint of the types of the enums with conversion funcs.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// RoundTrip checks that decoding the encoding of msg into decoded, which must
+// be a new message of the same type, gives msg back.
+func RoundTrip(t TB, msg, decoded Message) {
+	t.Helper()
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("can not encode %#v: %s", msg, err)
+	}
+
+	if err := decoded.Unmarshal(data); err != nil {
+		t.Fatalf("can not decode the encoding of %#v: %s", msg, err)
+	}
+
+	if !Equal(msg, decoded) {
+		t.Fatalf("the encoding of %#v is decoded as %#v", msg, decoded)
+	}
+}
+
+// CheckEnum checks that the conversion funcs of an enum are inverse
+// functions for the given protobuf value. Open enums must convert all the
+// values, while closed enums must return an error exactly for the values
+// that are not known. The values converted from protobuf must be converted
+// back to the same value.
+func CheckEnum[E Integer](
+	t TB,
+	v int32,
+	closed bool,
+	isKnown func(E) bool,
+	from func(int32) (E, error),
+	to func(E) (int32, error),
+) {
+	t.Helper()
+	e, err := from(v)
+	known := isKnown(E(v))
+	switch {
+	case err != nil && (!closed || known):
+		t.Fatalf("can not convert the value %d from protobuf: %s", v, err)
+	case err == nil && closed && !known:
+		t.Fatalf("the unknown value %d of a closed enum is converted from protobuf", v)
+	case err != nil:
+		return
+	}
+
+	p, err := to(e)
+	if err != nil {
+		t.Fatalf("can not convert the value %d back to protobuf: %s", v, err)
+	}
+
+	if p != v {
+		t.Fatalf("the value %d is converted back to protobuf as %d", v, p)
+	}
+}
+
+// Equal reports whether the given values are deeply equal, like
+// reflect.DeepEqual, but taking nil and empty slices and maps as equal, as
+// their encodings are the same.
+func Equal(a, b interface{}) bool {
+	return equal(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func equal(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a.Kind() {
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+
+		iter := a.MapRange()
+		for iter.Next() {
+			v := b.MapIndex(iter.Key())
+			if !v.IsValid() || !equal(iter.Value(), v) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equal(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !equal(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex()
+	case reflect.String:
+		return a.String() == b.String()
+	}
+
+	// funcs, chans and unsafe pointers are not encoded
+	return true
+}
@@ -0,0 +1,131 @@
+package proptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+// fakeTB records the failure of a check.
+type fakeTB struct {
+	failure string
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Fatalf(format string, args ...interface{}) {
+	if t.failure == "" {
+		t.failure = fmt.Sprintf(format, args...)
+	}
+}
+
+// jsonMessage is encoded as JSON, which loses the fields with a lossy tag.
+type jsonMessage struct {
+	Name  string
+	Tags  []string
+	Lossy int `json:"-"`
+}
+
+func (m *jsonMessage) Marshal() ([]byte, error) { return json.Marshal(m) }
+
+func (m *jsonMessage) Unmarshal(data []byte) error { return json.Unmarshal(data, m) }
+
+func TestRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	var tb fakeTB
+	RoundTrip(&tb, &jsonMessage{Name: "foo", Tags: []string{}}, new(jsonMessage))
+	require.Empty(tb.failure)
+
+	RoundTrip(&tb, &jsonMessage{Name: "foo", Lossy: 1}, new(jsonMessage))
+	require.Contains(tb.failure, "is decoded as")
+}
+
+func TestEqual(t *testing.T) {
+	type inner struct {
+		Values map[string][]byte
+	}
+
+	type outer struct {
+		Name  string
+		Inner *inner
+		List  []inner
+		Array [2]float64
+		fn    func()
+	}
+
+	cases := []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{outer{Name: "a"}, outer{Name: "a"}, true},
+		{outer{Name: "a"}, outer{Name: "b"}, false},
+		{outer{List: []inner{}}, outer{}, true},
+		{outer{Inner: &inner{Values: map[string][]byte{"a": {}}}}, outer{Inner: &inner{Values: map[string][]byte{"a": nil}}}, true},
+		{outer{Inner: &inner{Values: map[string][]byte{"a": nil}}}, outer{Inner: &inner{Values: map[string][]byte{"b": nil}}}, false},
+		{outer{Inner: &inner{}}, outer{}, false},
+		{outer{Array: [2]float64{1, 2}}, outer{Array: [2]float64{1, 3}}, false},
+		{outer{fn: func() {}}, outer{}, true},
+		{int32(1), int64(1), false},
+		{nil, nil, true},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.equal, Equal(c.a, c.b), "%#v == %#v", c.a, c.b)
+	}
+}
+
+type color uint8
+
+const (
+	red color = iota
+	green
+)
+
+func isKnownColor(v color) bool {
+	return v == red || v == green
+}
+
+func closedFromProto(v int32) (color, error) {
+	if !isKnownColor(color(v)) {
+		return 0, fmt.Errorf("unknown value %d", v)
+	}
+	return color(v), nil
+}
+
+func openFromProto(v int32) (color, error) {
+	return color(v), nil
+}
+
+func colorToProto(v color) (int32, error) {
+	return int32(v), nil
+}
+
+func TestCheckEnum(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		v := rapid.Int32Range(0, 127).Draw(t, "v")
+		CheckEnum(t, v, true, isKnownColor, closedFromProto, colorToProto)
+		CheckEnum(t, v, false, isKnownColor, openFromProto, colorToProto)
+	})
+}
+
+func TestCheckEnumFailures(t *testing.T) {
+	require := require.New(t)
+
+	var tb fakeTB
+	CheckEnum(&tb, 5, false, isKnownColor, closedFromProto, colorToProto)
+	require.Contains(tb.failure, "can not convert the value 5 from protobuf")
+
+	tb = fakeTB{}
+	CheckEnum(&tb, 5, true, isKnownColor, openFromProto, colorToProto)
+	require.Contains(tb.failure, "the unknown value 5")
+
+	tb = fakeTB{}
+	CheckEnum(&tb, 1, true, isKnownColor, closedFromProto, func(v color) (int32, error) {
+		return 0, nil
+	})
+	require.Equal("the value 1 is converted back to protobuf as 0", tb.failure)
+}
@@ -1,26 +1,251 @@
 package proteus
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/apidoc"
+	"gitlab.com/ThatTomPerson/proteus/arrayconv"
+	"gitlab.com/ThatTomPerson/proteus/bazel"
+	"gitlab.com/ThatTomPerson/proteus/buf"
+	"gitlab.com/ThatTomPerson/proteus/constants"
+	"gitlab.com/ThatTomPerson/proteus/enumconv"
+	"gitlab.com/ThatTomPerson/proteus/jsonomit"
+	"gitlab.com/ThatTomPerson/proteus/lazyconv"
+	"gitlab.com/ThatTomPerson/proteus/manifest"
+	"gitlab.com/ThatTomPerson/proteus/openapi"
+	"gitlab.com/ThatTomPerson/proteus/proptest"
 	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
 	"gitlab.com/ThatTomPerson/proteus/resolver"
 	"gitlab.com/ThatTomPerson/proteus/rpc"
 	"gitlab.com/ThatTomPerson/proteus/scanner"
+	"gitlab.com/ThatTomPerson/proteus/schemahash"
+	"gitlab.com/ThatTomPerson/proteus/snapshot"
+	"gitlab.com/ThatTomPerson/proteus/units"
+	"gitlab.com/ThatTomPerson/proteus/usage"
 )
 
 // Options are all the available options to configure proto generation.
 type Options struct {
 	BasePath string
 	Packages []string
+	// FieldPolicy is the policy for struct fields of channel or func types.
+	// If empty, they are skipped.
+	FieldPolicy scanner.FieldPolicy
+	// Unspecified enables adding a value with the number 0 to the enums that
+	// do not have one.
+	Unspecified bool
+	// EnumConstValues numbers the values of the enums with the values of
+	// their consts instead of the order they are declared in.
+	EnumConstValues bool
+	// OptionalFields makes the pointers to basic types and enums be
+	// generated as proto3 optional fields. protoc-gen-gofast does not
+	// support them, so the Go code of the files can not be generated with it.
+	OptionalFields bool
+	// BoolSets makes maps of bool values in the params and results of funcs
+	// be generated as repeated fields of their keys, like sets of empty
+	// struct values, in the packages that do not set it in their docs.
+	BoolSets bool
+	// InlineTypes makes the struct types written inline in the signatures
+	// of the funcs generated, and the instantiations of generic struct types
+	// they use, be generated as messages of the package, and the
+	// package-level vars initialized with funcs be generated like funcs.
+	InlineTypes bool
+	// EnumNaming is the naming strategy of the values of the enums that do
+	// not have one in their docs or their package's.
+	EnumNaming protobuf.EnumNaming
+	// EnumSemantics is the semantics of the enums that do not have them in
+	// their docs or their package's. Fields of closed enums only accept
+	// their values, and funcs converting the enums with open or closed
+	// semantics from and to their protobuf values are generated in their
+	// packages. If empty, enums without semantics have none of those.
+	EnumSemantics protobuf.EnumSemantics
+	// FieldNaming is the naming strategy of the fields of the messages of
+	// the packages that do not have one in their docs.
+	FieldNaming protobuf.FieldNaming
+	// JSONCasing is the casing of the names in JSON of the fields of the
+	// structs that do not have one in their docs or their package's.
+	JSONCasing protobuf.JSONCasing
+	// Acronyms are the acronyms kept as a single word, along with the
+	// default ones, when names are converted to snake case.
+	Acronyms []string
+	// Profile holds how often the fields of the messages are set, to number
+	// the small and frequent ones first the first time they are generated.
+	Profile protobuf.FieldProfile
+	// Rules are the transformation rules that set options, rename, retype
+	// or skip the messages, enums and fields matching their expressions.
+	Rules protobuf.Rules
+	// MessageHooks, FieldHooks and RPCHooks are called with the messages,
+	// fields and RPCs of every package once it is transformed, after the
+	// rules, so tools embedding proteus can modify or drop them.
+	MessageHooks []protobuf.MessageHook
+	FieldHooks   []protobuf.FieldHook
+	RPCHooks     []protobuf.RPCHook
+	// ImportPaths overrides the paths other files are imported from in the
+	// generated files.
+	ImportPaths protobuf.ImportPaths
+	// MessageFiles are the .proto files published elsewhere of Go packages,
+	// which are imported instead of the generated ones to reference their
+	// types, including the ones that already implement proto.Message.
+	MessageFiles protobuf.MessageFiles
+	// FileLayout is the path of the .proto file of every package in the base
+	// path. If empty, it is a generated.proto in the folder of the package.
+	FileLayout protobuf.FileLayout
+	// PackageTemplate is the protobuf package of every package. If empty, it
+	// is the path of the package with dots instead of slashes.
+	PackageTemplate protobuf.PackageTemplate
+	// PackageNames are the protobuf packages of some packages, indexed by
+	// their path, which override the package template. Packages can also set
+	// theirs with a `//proteus:package` comment in their docs, which
+	// overrides these.
+	PackageNames protobuf.PackageNames
+	// FileOptions are the options written to the files of the packages, such
+	// as java_package, indexed by the path of the package, or an empty path
+	// for all of them.
+	FileOptions protobuf.FileOptions
+	// SplitFiles writes the messages and enums of every Go source file of a
+	// package to a .proto file of its own, next to the file of the package.
+	// It can not be used along with Bazel.
+	SplitFiles bool
+	// MergePackage, if not empty, is the protobuf package of a .proto file
+	// with the messages, enums and services of all the packages, written
+	// along with the file of every package.
+	MergePackage string
+	// BreakingPolicy is what is done with the breaking changes of each
+	// category found by CheckBreakingChanges. Changes of the categories not
+	// in it fail the check.
+	BreakingPolicy protobuf.BreakingChangePolicy
+	// Bazel enables the generation of a BUILD.bazel file next to every
+	// generated .proto file.
+	Bazel bool
+	// Buf enables the generation of the buf.yaml and buf.gen.yaml files of a
+	// buf module in the base path, and makes the go_package of the files the
+	// import path of their Go package, so buf generate writes the Go code to
+	// it.
+	Buf bool
+	// OpenAPI enables the generation of an OpenAPI v3 document in the base
+	// path with the RPCs with an HTTP rule and the messages and enums of all
+	// the packages.
+	OpenAPI bool
+	// Docs is the format of the reference documentation of the messages,
+	// enums and services of all the packages written in the base path. If
+	// empty, no documentation is written.
+	Docs apidoc.Format
+	// SchemaHashes enables the generation of a constant with the schema hash
+	// of every message generated from a struct in its package, which changes
+	// whenever the encoding of the message may change.
+	SchemaHashes bool
+	// UnitHelpers enables the generation of methods converting the fields
+	// with a time or size unit from and to time.Duration or bytes.
+	UnitHelpers bool
+	// Tracing makes the generated gRPC server propagate the trace context
+	// and the request IDs of the calls with the interceptors of the tracing
+	// package.
+	Tracing bool
+	// ErrorStatus makes the generated gRPC server send the errors of the calls
+	// with the code and the ErrorInfo and BadRequest details of their domain
+	// errors with the interceptors of the errstatus package.
+	ErrorStatus bool
+	// Logging enables the generation of a NewGRPCLoggingInterceptor func in
+	// the gRPC server of the packages, returning an interceptor that logs a
+	// sample of the calls with their sensitive fields redacted.
+	Logging bool
+	// Pools enables the declaration of a pool of the pool package for every
+	// message of the packages with services, which the generated gRPC
+	// servers take their responses from and put them back in once their
+	// calls have ended.
+	Pools bool
+	// Clients enables the generation of a NewGRPCClientConn func in the
+	// packages with services, along with a GRPCClientConfig with the load
+	// balancing policy, the resolvers, the keepalive parameters and the
+	// maximum message sizes of the connections it returns, a
+	// NewGRPCClientPool func returning a pool of them and, with gogo, a
+	// client of every service sending its calls to the connections of a
+	// pool in turn.
+	Clients bool
+	// Examples enables the generation of an example test per service in the
+	// packages served with gogo, calling its RPCs with the generated client,
+	// so go doc shows how to use them.
+	Examples bool
+	// ProtobufAPI is the API of the code generated by protoc the gRPC
+	// servers of the packages are registered with, unless they have one in
+	// their docs. If empty, it is rpc.GoGo.
+	ProtobufAPI rpc.API
+	// Only are the packages, or the types, like github.com/acme/app/user.User,
+	// that are generated, along with the packages with types or services that
+	// depend on them. The outputs of the rest of the packages are left as
+	// they are. If empty, all the packages are generated.
+	Only []string
+	// Scope, if not nil, gets the packages generated with Only set in it, so
+	// other steps can be limited to them.
+	Scope *Scope
+	// Manifest, if not nil, gets all the files produced added to it.
+	Manifest *manifest.Manifest
+	// Trace, if not nil, gets the decisions taken for the fields of the
+	// structs recorded in it.
+	Trace *protobuf.Trace
+	// Strict makes the generation fail, before anything is generated, if
+	// any type, field, func or RPC had to be skipped, with a report.Problems
+	// error listing all of them. Otherwise they are only reported as
+	// warnings, and report.Skipped returns them after the generation.
+	Strict bool
+	// Cache, if not nil, keeps the scanned packages between generations, so
+	// only the packages invalidated in it are scanned again. A cache created
+	// with scanner.NewCache must only be used with the same field policy,
+	// bool sets and inline types, while one created with
+	// scanner.NewDiskCache keys the packages by them, by their Go files and
+	// by the packages they import that are scanned too, persisting them for
+	// later runs.
+	Cache *scanner.Cache
+}
+
+// protoFiles returns the files the given package is written to, which are
+// more than one if the files are split.
+func (o Options) protoFiles(pkg *protobuf.Package) []*protobuf.Package {
+	if !o.SplitFiles {
+		return []*protobuf.Package{pkg}
+	}
+	return protobuf.SplitByFile(pkg)
+}
+
+// addToManifest adds the file generated from the given package to the
+// manifest of the options, if any.
+func (o Options) addToManifest(file, pkg string) error {
+	if o.Manifest == nil {
+		return nil
+	}
+	return o.Manifest.Add(file, pkg)
 }
 
 type generator func(*scanner.Package, *protobuf.Package) error
 
-func transformToProtobuf(packages []string, generate generator) error {
-	scanner, err := scanner.New(packages...)
+// transformToProtobuf scans and transforms all the packages of the options
+// and calls generate with the ones in the scope of Only, if it is given.
+func transformToProtobuf(options Options, generate generator) error {
+	acronyms, err := protobuf.NewAcronyms(options.Acronyms...)
+	if err != nil {
+		return err
+	}
+
+	report.ResetSkipped()
+	scanner, err := scanner.New(options.Packages...)
 	if err != nil {
 		return err
 	}
 
+	if options.FieldPolicy != "" {
+		scanner.SetFieldPolicy(options.FieldPolicy)
+	}
+
+	scanner.SetBoolSets(options.BoolSets)
+	scanner.SetInlineTypes(options.InlineTypes)
+	if options.Cache != nil {
+		scanner.SetCache(options.Cache)
+	}
+
 	pkgs, err := scanner.Scan()
 	if err != nil {
 		return err
@@ -32,9 +257,65 @@ func transformToProtobuf(packages []string, generate generator) error {
 	t := protobuf.NewTransformer()
 	t.SetStructSet(createStructTypeSet(pkgs))
 	t.SetEnumSet(createEnumTypeSet(pkgs))
-	for _, p := range pkgs {
-		pkg := t.Transform(p)
-		if err := generate(p, pkg); err != nil {
+	t.SetArraySet(createArrayTypeSet(pkgs))
+	t.SetLazyMessages(createLazyMessages(pkgs))
+	t.SetClosedEnums(protobuf.ClosedEnums(pkgs, options.EnumSemantics))
+	t.SetImportPaths(options.ImportPaths)
+	t.SetMessageFiles(options.MessageFiles)
+	t.SetFileLayout(options.FileLayout)
+	t.SetPackageTemplate(options.PackageTemplate)
+	t.SetPackageNames(createPackageNames(options.PackageNames, options.PackageTemplate, pkgs))
+	t.SetFileOptions(options.FileOptions)
+	t.SetGoImportPaths(options.Buf)
+	t.SetUnspecifiedEnumValues(options.Unspecified)
+	t.SetEnumConstValues(options.EnumConstValues)
+	t.SetOptionalFields(options.OptionalFields)
+	t.SetEnumNaming(options.EnumNaming)
+	t.SetFieldNaming(options.FieldNaming)
+	t.SetJSONCasing(options.JSONCasing)
+	t.SetAcronyms(acronyms)
+	t.SetFieldProfile(options.Profile)
+	t.SetRules(options.Rules)
+	t.SetTrace(options.Trace)
+	for _, h := range options.MessageHooks {
+		t.RegisterMessageHook(h)
+	}
+
+	for _, h := range options.FieldHooks {
+		t.RegisterFieldHook(h)
+	}
+
+	for _, h := range options.RPCHooks {
+		t.RegisterRPCHook(h)
+	}
+	var protos = make([]*protobuf.Package, len(pkgs))
+	for i, p := range pkgs {
+		protos[i] = t.Transform(p)
+	}
+
+	if options.Strict {
+		if err := report.Skipped().Err(); err != nil {
+			return err
+		}
+	}
+
+	var scope *Scope
+	if len(options.Only) > 0 {
+		if scope, err = newScope(options.Only, pkgs, protos); err != nil {
+			return err
+		}
+
+		if options.Scope != nil {
+			*options.Scope = *scope
+		}
+	}
+
+	for i, p := range pkgs {
+		if scope != nil && !scope.Has(p.Path) {
+			continue
+		}
+
+		if err := generate(p, protos[i]); err != nil {
 			return err
 		}
 	}
@@ -52,6 +333,44 @@ func createStructTypeSet(pkgs []*scanner.Package) protobuf.TypeSet {
 	return ts
 }
 
+// createPackageNames returns the given package names along with the ones
+// given in the docs of the packages, which override them. Invalid names in
+// the docs are ignored with a warning. Packages whose protobuf package would
+// be the same as the one of another are given a distinct one, also with a
+// warning.
+func createPackageNames(names protobuf.PackageNames, tpl protobuf.PackageTemplate, pkgs []*scanner.Package) protobuf.PackageNames {
+	result := make(protobuf.PackageNames, len(names))
+	for path, name := range names {
+		result[path] = name
+	}
+
+	for _, p := range pkgs {
+		if p.ProtoPackage == "" {
+			continue
+		}
+
+		name, err := protobuf.ParsePackageName(p.ProtoPackage)
+		if err != nil {
+			report.Warn("package %q has an invalid package comment, ignoring it: %s", p.Path, err)
+			continue
+		}
+		result[p.Path] = name
+	}
+
+	var paths = make([]string, len(pkgs))
+	for i, p := range pkgs {
+		paths[i] = p.Path
+	}
+
+	disambiguated := result.Disambiguate(paths, tpl)
+	for _, p := range paths {
+		if _, ok := result[p]; !ok && disambiguated[p] != "" {
+			report.Warn("package %s has the same protobuf package %s as other packages, generating it as %s; give it a name with --package-name to choose another", p, result.Package(p, tpl), disambiguated[p])
+		}
+	}
+	return disambiguated
+}
+
 func createEnumTypeSet(pkgs []*scanner.Package) protobuf.TypeSet {
 	ts := protobuf.NewTypeSet()
 	for _, p := range pkgs {
@@ -62,19 +381,497 @@ func createEnumTypeSet(pkgs []*scanner.Package) protobuf.TypeSet {
 	return ts
 }
 
+func createArrayTypeSet(pkgs []*scanner.Package) protobuf.TypeSet {
+	ts := protobuf.NewTypeSet()
+	for _, p := range pkgs {
+		for _, a := range p.Arrays {
+			ts.Add(p.Path, a.Name)
+		}
+	}
+	return ts
+}
+
+func createLazyMessages(pkgs []*scanner.Package) map[string]*scanner.Named {
+	messages := make(map[string]*scanner.Named)
+	for _, p := range pkgs {
+		for _, l := range p.Lazies {
+			messages[fmt.Sprintf("%s.%s", p.Path, l.Name)] = l.Message
+		}
+	}
+	return messages
+}
+
 // GenerateProtos generates proto files for the given options.
 func GenerateProtos(options Options) error {
-	g := protobuf.NewGenerator(options.BasePath)
-	return transformToProtobuf(options.Packages, func(_ *scanner.Package, pkg *protobuf.Package) error {
-		return g.Generate(pkg)
+	if err := checkProtoOptions(options); err != nil {
+		return err
+	}
+
+	g := newProtoGenerator(options)
+	bg := bazel.NewGenerator(options.BasePath)
+	bg.SetFileLayout(options.FileLayout)
+	cg := constants.NewGenerator(options.BasePath)
+	jg := jsonomit.NewGenerator()
+	eg := enumconv.NewGenerator()
+	eg.SetEnumSemantics(options.EnumSemantics)
+	ug := units.NewGenerator()
+	ag := arrayconv.NewGenerator()
+	lg := lazyconv.NewGenerator()
+	var (
+		scanned []*scanner.Package
+		protos  []*protobuf.Package
+	)
+	err := transformToProtobuf(options, func(p *scanner.Package, pkg *protobuf.Package) error {
+		scanned = append(scanned, p)
+		protos = append(protos, pkg)
+		if err := mergeProtoFiles(options, g, pkg); err != nil {
+			return err
+		}
+
+		if err := writeProtoFiles(options, g, pkg); err != nil {
+			return err
+		}
+
+		if len(p.Consts) > 0 {
+			if err := cg.Generate(p, pkg); err != nil {
+				return err
+			}
+
+			if err := options.addToManifest(cg.FileName(pkg), p.Path); err != nil {
+				return err
+			}
+		}
+
+		written, err := jg.Generate(p)
+		if err != nil {
+			return err
+		}
+
+		if written {
+			if err := options.addToManifest(jg.FileName(p.Path), p.Path); err != nil {
+				return err
+			}
+		}
+
+		written, err = eg.Generate(p)
+		if err != nil {
+			return err
+		}
+
+		if written {
+			if err := options.addToManifest(eg.FileName(p.Path), p.Path); err != nil {
+				return err
+			}
+		}
+
+		written, err = ag.Generate(p)
+		if err != nil {
+			return err
+		}
+
+		if written {
+			if err := options.addToManifest(ag.FileName(p.Path), p.Path); err != nil {
+				return err
+			}
+		}
+
+		written, err = lg.Generate(p)
+		if err != nil {
+			return err
+		}
+
+		if written {
+			if err := options.addToManifest(lg.FileName(p.Path), p.Path); err != nil {
+				return err
+			}
+		}
+
+		if options.UnitHelpers {
+			written, err = ug.Generate(p)
+			if err != nil {
+				return err
+			}
+
+			if written {
+				if err := options.addToManifest(ug.FileName(p.Path), p.Path); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !options.Bazel {
+			return nil
+		}
+
+		if err := bg.Generate(pkg); err != nil {
+			return err
+		}
+		return options.addToManifest(bg.FileName(pkg), p.Path)
+	})
+	if err != nil {
+		return err
+	}
+
+	if options.SchemaHashes {
+		// the hashes are written once all the packages are merged with
+		// their previous files, as they have the numbers of the fields
+		sg := schemahash.NewGenerator(protos)
+		for i, p := range scanned {
+			written, err := sg.Generate(p, protos[i])
+			if err != nil {
+				return err
+			}
+
+			if written {
+				if err := options.addToManifest(sg.FileName(p.Path), p.Path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return writeModuleFiles(options, g, protos)
+}
+
+// GenerateModel scans and transforms the packages of the given options, or
+// the ones in the scope of Only if it is given, and returns the protobuf
+// packages they are generated as, merged with their previous .proto files in
+// the base path, if any, as GenerateProtos does. Nothing is written, so
+// other tools can inspect or modify the packages and then write them with
+// RenderProtos.
+func GenerateModel(options Options) ([]*protobuf.Package, error) {
+	g := newProtoGenerator(options)
+	var protos []*protobuf.Package
+	err := transformToProtobuf(options, func(p *scanner.Package, pkg *protobuf.Package) error {
+		protos = append(protos, pkg)
+		return mergeProtoFiles(options, g, pkg)
 	})
+	if err != nil {
+		return nil, err
+	}
+	return protos, nil
+}
+
+// RenderProtos writes the given protobuf packages, usually returned by
+// GenerateModel, to .proto files in the base path of the given options,
+// along with the Bazel, merged, buf, OpenAPI and documentation files enabled
+// in them. The Go code generated in the Go packages, like the constants or
+// the JSON marshalers, is only written by GenerateProtos.
+func RenderProtos(options Options, pkgs []*protobuf.Package) error {
+	if err := checkProtoOptions(options); err != nil {
+		return err
+	}
+
+	g := newProtoGenerator(options)
+	bg := bazel.NewGenerator(options.BasePath)
+	bg.SetFileLayout(options.FileLayout)
+	for _, pkg := range pkgs {
+		if err := writeProtoFiles(options, g, pkg); err != nil {
+			return err
+		}
+
+		if !options.Bazel {
+			continue
+		}
+
+		if err := bg.Generate(pkg); err != nil {
+			return err
+		}
+
+		if err := options.addToManifest(bg.FileName(pkg), pkg.Path); err != nil {
+			return err
+		}
+	}
+
+	return writeModuleFiles(options, g, pkgs)
+}
+
+// checkProtoOptions returns an error if the given options can not be used to
+// write .proto files.
+func checkProtoOptions(options Options) error {
+	if options.SplitFiles && options.Bazel {
+		return errors.New("split files can not be generated along with Bazel files")
+	}
+	return nil
+}
+
+func newProtoGenerator(options Options) *protobuf.Generator {
+	g := protobuf.NewGenerator(options.BasePath)
+	g.SetFileLayout(options.FileLayout)
+	return g
+}
+
+// mergeProtoFiles merges the given package with the .proto files it was
+// written to before, if any.
+func mergeProtoFiles(options Options, g *protobuf.Generator, pkg *protobuf.Package) error {
+	for _, f := range options.protoFiles(pkg) {
+		if err := mergePrevious(g.FileName(f), pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeProtoFiles writes the .proto files of the given package.
+func writeProtoFiles(options Options, g *protobuf.Generator, pkg *protobuf.Package) error {
+	for _, f := range options.protoFiles(pkg) {
+		if err := g.Generate(f); err != nil {
+			return err
+		}
+
+		if err := options.addToManifest(g.FileName(f), pkg.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeModuleFiles writes the files of all the given packages enabled in
+// the options, which are left as they are when only some packages are
+// generated.
+func writeModuleFiles(options Options, g *protobuf.Generator, protos []*protobuf.Package) error {
+	if options.MergePackage != "" && len(options.Only) > 0 {
+		report.Warn("the merged file is left as it is when only some packages are generated, generate all of them to update it")
+	} else if options.MergePackage != "" && len(protos) > 0 {
+		merged := protobuf.MergePackages(options.MergePackage, protos)
+		if err := mergePrevious(g.FileName(merged), merged); err != nil {
+			return err
+		}
+
+		if err := g.Generate(merged); err != nil {
+			return err
+		}
+
+		if err := options.addToManifest(g.FileName(merged), protos[0].Path); err != nil {
+			return err
+		}
+	}
+
+	if options.Buf && len(options.Only) > 0 {
+		report.Warn("the buf files are left as they are when only some packages are generated, generate all of them to update them")
+	} else if options.Buf && len(protos) > 0 {
+		acronyms, err := protobuf.NewAcronyms(options.Acronyms...)
+		if err != nil {
+			return err
+		}
+
+		bfg := buf.NewGenerator(options.BasePath)
+		bfg.SetFileLayout(options.FileLayout)
+		bfg.SetMessageFiles(options.MessageFiles)
+		bfg.SetAcronyms(acronyms)
+		files, err := bfg.Generate(protos)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			if err := options.addToManifest(f, protos[0].Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if options.OpenAPI && len(options.Only) > 0 {
+		report.Warn("the OpenAPI document is left as it is when only some packages are generated, generate all of them to update it")
+	} else if options.OpenAPI && len(protos) > 0 {
+		og := openapi.NewGenerator(options.BasePath)
+		if err := og.Generate(protos); err != nil {
+			return err
+		}
+
+		if err := options.addToManifest(og.FileName(), protos[0].Path); err != nil {
+			return err
+		}
+	}
+
+	if options.Docs != "" && len(options.Only) > 0 {
+		report.Warn("the API documentation is left as it is when only some packages are generated, generate all of them to update it")
+	} else if options.Docs != "" && len(protos) > 0 {
+		dg := apidoc.NewGenerator(options.BasePath, options.Docs)
+		if err := dg.Generate(protos); err != nil {
+			return err
+		}
+
+		if err := options.addToManifest(dg.FileName(), protos[0].Path); err != nil {
+			return err
+		}
+	}
+
+	for _, group := range protobuf.FindDuplicateMessages(protos) {
+		report.Info("messages %s have the same fields, consider sharing a single Go type between their packages", strings.Join(group, ", "))
+	}
+	return nil
 }
 
 // GenerateRPCServer generates the gRPC server implementation of the given
 // packages.
 func GenerateRPCServer(packages []string) error {
+	return GenerateRPCServerWithOptions(Options{Packages: packages})
+}
+
+// GenerateRPCServerWithOptions generates the gRPC server implementation of
+// the packages in the given options, along with their usage examples if
+// enabled. Only the packages, the field policy, the bool sets, the inline
+// types, the tracing, the error status, the logging, the pools, the
+// clients, the examples, the protobuf API and the manifest of the options
+// are used.
+func GenerateRPCServerWithOptions(options Options) error {
 	g := rpc.NewGenerator()
-	return transformToProtobuf(packages, func(p *scanner.Package, pkg *protobuf.Package) error {
-		return g.Generate(pkg, p.Path)
+	g.SetTracing(options.Tracing)
+	g.SetErrorStatus(options.ErrorStatus)
+	g.SetLogging(options.Logging)
+	g.SetPools(options.Pools)
+	g.SetClients(options.Clients)
+	ug := usage.NewGenerator()
+	return transformToProtobuf(options, func(p *scanner.Package, pkg *protobuf.Package) error {
+		api := rpc.APIOf(p, options.ProtobufAPI)
+		g.SetAPI(api)
+		if err := g.Generate(pkg, p.Path); err != nil {
+			return err
+		}
+
+		if !pkg.HasRPCs() {
+			return nil
+		}
+
+		if err := options.addToManifest(g.FileName(p.Path), p.Path); err != nil {
+			return err
+		}
+
+		// The clients of the packages served with APIv2 are in their APIv2
+		// code, with messages of their own.
+		if !options.Examples || api.API == rpc.APIv2 {
+			return nil
+		}
+
+		if err := ug.Generate(pkg, p.Name, p.Path); err != nil {
+			return err
+		}
+		return options.addToManifest(ug.FileName(p.Path), p.Path)
+	})
+}
+
+// GenerateSnapshotTests generates the tests that check the stored snapshots
+// of previous releases against the current messages of the packages in the
+// given options. Only the packages, the field policy, the bool sets, the
+// inline types and the manifest of the options are used.
+func GenerateSnapshotTests(options Options) error {
+	g := snapshot.NewGenerator()
+	return transformToProtobuf(options, func(p *scanner.Package, pkg *protobuf.Package) error {
+		if err := g.Generate(pkg, p.Name, p.Path); err != nil {
+			return err
+		}
+
+		if len(pkg.Messages) == 0 {
+			return nil
+		}
+		return options.addToManifest(g.FileName(p.Path), p.Path)
+	})
+}
+
+// GeneratePropertyTests generates the property-based tests that check that
+// the messages of the packages in the given options survive a round trip
+// through their encoding, and that the conversion funcs of their enums are
+// inverse functions. Only the packages, the field policy, the bool sets, the
+// inline types, the optional fields, the enum semantics and the manifest of
+// the options are used.
+func GeneratePropertyTests(options Options) error {
+	g := proptest.NewGenerator()
+	g.SetEnumSemantics(options.EnumSemantics)
+	return transformToProtobuf(options, func(p *scanner.Package, pkg *protobuf.Package) error {
+		written, err := g.Generate(p, pkg)
+		if err != nil || !written {
+			return err
+		}
+		return options.addToManifest(g.FileName(p.Path), p.Path)
 	})
 }
+
+// AnalyzeInterfaces reports the interfaces of the packages in the given
+// options whose implementations are told apart in type switches or type
+// assertions, with the oneof or enum they could be generated as. Only the
+// packages of the options are used.
+func AnalyzeInterfaces(options Options) error {
+	scanner, err := scanner.New(options.Packages...)
+	if err != nil {
+		return err
+	}
+
+	ifaces, err := scanner.FindClosedInterfaces()
+	if err != nil {
+		return err
+	}
+
+	for _, i := range ifaces {
+		report.Info("%s", i)
+	}
+	return nil
+}
+
+// mergePrevious makes the messages of the package numbered following a field
+// profile keep the numbers they had in the previously generated file, and
+// reserves the numbers and names of the fields and enum values that were in
+// it and were deleted since then. It does nothing if there is no previous
+// file. If the package is split, it is called with each of its files.
+func mergePrevious(file string, pkg *protobuf.Package) error {
+	prev, err := protobuf.ParseFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error parsing %q: %s", file, err)
+	}
+
+	protobuf.KeepProfiledNumbers(prev, pkg)
+	protobuf.ReserveDeleted(prev, pkg)
+	return nil
+}
+
+// CheckBreakingChanges compares the .proto files previously generated at the
+// base path with the ones that would be generated now for the given options.
+// If any change is not compatible, an error listing all of them and how many
+// there are of each category is returned, unless the breaking policy of the
+// options warns about or ignores their category. Packages without a
+// previously generated file are skipped.
+func CheckBreakingChanges(options Options) error {
+	var (
+		g      = protobuf.NewGenerator(options.BasePath)
+		failed protobuf.BreakingChanges
+		lines  []string
+	)
+	g.SetFileLayout(options.FileLayout)
+
+	err := transformToProtobuf(options, func(_ *scanner.Package, pkg *protobuf.Package) error {
+		for _, f := range options.protoFiles(pkg) {
+			file := g.FileName(f)
+			prev, err := protobuf.ParseFile(file)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("error parsing %q: %s", file, err)
+			}
+
+			// types may move between the files of a package, so they are
+			// compared with the whole package
+			protobuf.KeepProfiledNumbers(prev, pkg)
+			for _, c := range protobuf.FindBreakingChanges(prev, pkg) {
+				switch options.BreakingPolicy.Action(c.Category) {
+				case protobuf.FailOnBreakingChange:
+					failed = append(failed, c)
+					lines = append(lines, fmt.Sprintf("%s: %s-breaking: %s", file, c.Category, c))
+				case protobuf.WarnOnBreakingChange:
+					report.Warn("%s: %s-breaking: %s", file, c.Category, c)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(lines) > 0 {
+		return fmt.Errorf("breaking changes found (%s):\n%s", failed.Score(), strings.Join(lines, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package protobuf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BreakingChangeKind identifies the kind of an incompatible change between
+// two versions of the same package.
+type BreakingChangeKind string
+
+const (
+	// MessageDeleted is reported when a message no longer exists.
+	MessageDeleted BreakingChangeKind = "message-deleted"
+	// EnumDeleted is reported when an enum no longer exists.
+	EnumDeleted BreakingChangeKind = "enum-deleted"
+	// FieldTypeChanged is reported when a field number has a different type
+	// than before.
+	FieldTypeChanged BreakingChangeKind = "field-type-changed"
+	// FieldNameReused is reported when a field name is used with a different
+	// number than before.
+	FieldNameReused BreakingChangeKind = "field-name-reused"
+	// ReservedFieldReused is reported when a field uses a number that was
+	// reserved.
+	ReservedFieldReused BreakingChangeKind = "reserved-field-reused"
+	// ReservedFieldNameReused is reported when a field uses a name that was
+	// reserved.
+	ReservedFieldNameReused BreakingChangeKind = "reserved-field-name-reused"
+	// EnumValueChanged is reported when an enum value name has a different
+	// number than before.
+	EnumValueChanged BreakingChangeKind = "enum-value-changed"
+	// ReservedEnumValueReused is reported when an enum value uses a number
+	// or a name that was reserved.
+	ReservedEnumValueReused BreakingChangeKind = "reserved-enum-value-reused"
+	// FieldRenamed is reported when a field number has a different name than
+	// before and its previous name is not used anymore.
+	FieldRenamed BreakingChangeKind = "field-renamed"
+	// EnumValueRenamed is reported when an enum value number has a different
+	// name than before and its previous name is not used anymore.
+	EnumValueRenamed BreakingChangeKind = "enum-value-renamed"
+)
+
+// BreakingChangeCategory tells what a breaking change breaks.
+type BreakingChangeCategory string
+
+const (
+	// WireBreaking changes make the previous and the current versions unable
+	// to decode the binary encoding of each other.
+	WireBreaking BreakingChangeCategory = "wire"
+	// JSONBreaking changes keep the binary encoding compatible, but not the
+	// JSON one, which uses the names of fields and enum values instead of
+	// their numbers.
+	JSONBreaking BreakingChangeCategory = "json"
+	// SourceBreaking changes keep both encodings compatible, but break the
+	// code generated from the previous version, e.g. a message that was
+	// deleted or a field whose name changed but not its name in JSON.
+	SourceBreaking BreakingChangeCategory = "source"
+)
+
+// BreakingChangeCategories are all the categories of breaking changes, from
+// the most to the least severe.
+var BreakingChangeCategories = []BreakingChangeCategory{WireBreaking, JSONBreaking, SourceBreaking}
+
+// BreakingChangeAction is what is done with the breaking changes found.
+type BreakingChangeAction string
+
+const (
+	// FailOnBreakingChange makes the check fail with the changes.
+	FailOnBreakingChange BreakingChangeAction = "error"
+	// WarnOnBreakingChange reports the changes as warnings.
+	WarnOnBreakingChange BreakingChangeAction = "warn"
+	// IgnoreBreakingChange does not report the changes.
+	IgnoreBreakingChange BreakingChangeAction = "ignore"
+)
+
+// BreakingChangePolicy is the action taken with the breaking changes of each
+// category. The changes of the categories not in the policy fail the check.
+type BreakingChangePolicy map[BreakingChangeCategory]BreakingChangeAction
+
+// ParseBreakingChangePolicy creates a BreakingChangePolicy from a list of
+// "CATEGORY=ACTION" pairs, e.g. json=warn for releases that only use the
+// binary encoding.
+func ParseBreakingChangePolicy(pairs []string) (BreakingChangePolicy, error) {
+	policy := make(BreakingChangePolicy)
+	for _, p := range pairs {
+		idx := strings.Index(p, "=")
+		if idx <= 0 || idx == len(p)-1 {
+			return nil, fmt.Errorf("invalid breaking change policy %q, expected CATEGORY=ACTION", p)
+		}
+
+		category, action := BreakingChangeCategory(p[:idx]), BreakingChangeAction(p[idx+1:])
+		switch category {
+		case WireBreaking, JSONBreaking, SourceBreaking:
+		default:
+			return nil, fmt.Errorf("invalid breaking change policy %q, the category must be wire, json or source", p)
+		}
+
+		switch action {
+		case FailOnBreakingChange, WarnOnBreakingChange, IgnoreBreakingChange:
+		default:
+			return nil, fmt.Errorf("invalid breaking change policy %q, the action must be error, warn or ignore", p)
+		}
+		policy[category] = action
+	}
+	return policy, nil
+}
+
+// Action returns the action taken with the changes of the given category.
+func (p BreakingChangePolicy) Action(category BreakingChangeCategory) BreakingChangeAction {
+	if a, ok := p[category]; ok {
+		return a
+	}
+	return FailOnBreakingChange
+}
+
+// BreakingChange is a single incompatible change found comparing two
+// versions of a package.
+type BreakingChange struct {
+	Kind BreakingChangeKind
+	// Category is the most severe thing the change breaks.
+	Category BreakingChangeCategory
+	// Element is the qualified name of the changed element, e.g. "Foo.bar".
+	Element string
+	Message string
+}
+
+func (c *BreakingChange) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.Kind, c.Element, c.Message)
+}
+
+// BreakingChanges is a list of breaking changes.
+type BreakingChanges []*BreakingChange
+
+func (cs BreakingChanges) String() string {
+	var lines = make([]string, len(cs))
+	for i, c := range cs {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Count returns the number of changes with the given category.
+func (cs BreakingChanges) Count(category BreakingChangeCategory) int {
+	var n int
+	for _, c := range cs {
+		if c.Category == category {
+			n++
+		}
+	}
+	return n
+}
+
+// Score returns the number of changes of each category, e.g. "wire: 1, json:
+// 0, source: 2".
+func (cs BreakingChanges) Score() string {
+	var parts = make([]string, len(BreakingChangeCategories))
+	for i, c := range BreakingChangeCategories {
+		parts[i] = fmt.Sprintf("%s: %d", c, cs.Count(c))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (cs *BreakingChanges) add(kind BreakingChangeKind, category BreakingChangeCategory, elem, format string, args ...interface{}) {
+	*cs = append(*cs, &BreakingChange{
+		Kind:     kind,
+		Category: category,
+		Element:  elem,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// FindBreakingChanges compares the previous version of a package with the
+// current one and returns all the changes that are not compatible, on the
+// wire, in JSON or in the generated code.
+func FindBreakingChanges(prev, curr *Package) BreakingChanges {
+	var changes BreakingChanges
+
+	for _, old := range prev.Messages {
+		msg := curr.findMessage(old.Name)
+		if msg == nil {
+			changes.add(MessageDeleted, SourceBreaking, old.Name, "message was deleted")
+			continue
+		}
+
+		compareMessages(&changes, old, msg)
+	}
+
+	for _, old := range prev.Enums {
+		enum := curr.findEnum(old.Name)
+		if enum == nil {
+			changes.add(EnumDeleted, SourceBreaking, old.Name, "enum was deleted")
+			continue
+		}
+
+		compareEnums(&changes, old, enum)
+	}
+
+	return changes
+}
+
+func compareMessages(changes *BreakingChanges, old, msg *Message) {
+	for _, of := range old.Fields {
+		elem := fmt.Sprintf("%s.%s", msg.Name, of.Name)
+		if f := msg.fieldByPos(of.Pos); f != nil {
+			if oldType, newType := fieldTypeString(of), fieldTypeString(f); oldType != newType {
+				changes.add(FieldTypeChanged, WireBreaking, elem, "field number %d changed type from %s to %s", of.Pos, oldType, newType)
+			}
+		}
+
+		if f := msg.fieldByName(of.Name); f == nil {
+			if f = msg.fieldByPos(of.Pos); f != nil {
+				compareFieldNames(changes, msg, of, f)
+			}
+		} else if f.Pos != of.Pos {
+			changes.add(FieldNameReused, WireBreaking, elem, "field name was used with number %d and is now used with number %d", of.Pos, f.Pos)
+		}
+	}
+
+	for _, r := range old.Reserved {
+		if f := msg.fieldByPos(int(r)); f != nil {
+			changes.add(ReservedFieldReused, WireBreaking, fmt.Sprintf("%s.%s", msg.Name, f.Name), "field uses the reserved number %d", r)
+		}
+	}
+
+	for _, n := range old.ReservedNames {
+		if f := msg.fieldByName(n); f != nil {
+			changes.add(ReservedFieldNameReused, JSONBreaking, fmt.Sprintf("%s.%s", msg.Name, f.Name), "field uses the reserved name %q", n)
+		}
+	}
+}
+
+func compareEnums(changes *BreakingChanges, old, enum *Enum) {
+	for _, ov := range old.Values {
+		if v := enum.valueByName(ov.Name); v != nil && v.Value != ov.Value {
+			changes.add(EnumValueChanged, WireBreaking, fmt.Sprintf("%s.%s", enum.Name, v.Name), "enum value changed from %d to %d", ov.Value, v.Value)
+		} else if v == nil {
+			if v = enum.valueByNumber(ov.Value); v != nil {
+				changes.add(EnumValueRenamed, JSONBreaking, fmt.Sprintf("%s.%s", enum.Name, v.Name), "enum value %d was named %s", v.Value, ov.Name)
+			}
+		}
+	}
+
+	for _, v := range enum.Values {
+		elem := fmt.Sprintf("%s.%s", enum.Name, v.Name)
+		if containsUint(old.Reserved, v.Value) {
+			changes.add(ReservedEnumValueReused, WireBreaking, elem, "enum value uses the reserved number %d", v.Value)
+		}
+
+		if containsString(old.ReservedNames, v.Name) {
+			changes.add(ReservedEnumValueReused, JSONBreaking, elem, "enum value uses the reserved name %q", v.Name)
+		}
+	}
+}
+
+// compareFieldNames reports the field with the same number as the given
+// previous field, whose name is not used anymore. It only breaks JSON if its
+// name in JSON is not the same either.
+func compareFieldNames(changes *BreakingChanges, msg *Message, old, f *Field) {
+	elem := fmt.Sprintf("%s.%s", msg.Name, f.Name)
+	if oldName, newName := old.JSONName(), f.JSONName(); oldName != newName {
+		changes.add(FieldRenamed, JSONBreaking, elem, "field number %d was named %s, and %s in JSON instead of %s", f.Pos, old.Name, oldName, newName)
+	} else {
+		changes.add(FieldRenamed, SourceBreaking, elem, "field number %d was named %s, with the same name in JSON", f.Pos, old.Name)
+	}
+}
+
+// ReserveDeleted reserves in the current version of a package the numbers and
+// names of the fields and enum values of the previous version that do not
+// exist anymore, so they can not be reused by mistake later. The ones that
+// were already reserved are kept. Numbers and names that are used in the
+// current version are never reserved, as that is a breaking change that is
+// reported by FindBreakingChanges instead.
+func ReserveDeleted(prev, curr *Package) {
+	for _, old := range prev.Messages {
+		if msg := curr.findMessage(old.Name); msg != nil {
+			reserveDeletedFields(old, msg)
+		}
+	}
+
+	for _, old := range prev.Enums {
+		if enum := curr.findEnum(old.Name); enum != nil {
+			reserveDeletedValues(old, enum)
+		}
+	}
+}
+
+func reserveDeletedFields(old, msg *Message) {
+	var (
+		nums  = append([]uint(nil), old.Reserved...)
+		names = append([]string(nil), old.ReservedNames...)
+	)
+
+	for _, f := range old.Fields {
+		nums = append(nums, uint(f.Pos))
+		names = append(names, f.Name)
+	}
+
+	for _, n := range nums {
+		if msg.fieldByPos(int(n)) == nil {
+			msg.Reserve(n)
+		}
+	}
+
+	for _, n := range names {
+		if msg.fieldByName(n) == nil {
+			msg.ReserveName(n)
+		}
+	}
+}
+
+func reserveDeletedValues(old, enum *Enum) {
+	var (
+		nums  = append([]uint(nil), old.Reserved...)
+		names = append([]string(nil), old.ReservedNames...)
+	)
+
+	for _, v := range old.Values {
+		nums = append(nums, v.Value)
+		names = append(names, v.Name)
+	}
+
+	for _, n := range nums {
+		if enum.valueByNumber(n) == nil {
+			enum.Reserve(n)
+		}
+	}
+
+	for _, n := range names {
+		if enum.valueByName(n) == nil {
+			enum.ReserveName(n)
+		}
+	}
+}
+
+func fieldTypeString(f *Field) string {
+	if f.Repeated {
+		return "repeated " + f.Type.String()
+	}
+	return f.Type.String()
+}
+
+func (p *Package) findMessage(name string) *Message {
+	for _, m := range p.Messages {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func (p *Package) findEnum(name string) *Enum {
+	for _, e := range p.Enums {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+func (m *Message) fieldByPos(pos int) *Field {
+	for _, f := range m.Fields {
+		if f.Pos == pos {
+			return f
+		}
+	}
+	return nil
+}
+
+func (m *Message) fieldByName(name string) *Field {
+	for _, f := range m.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (e *Enum) valueByNumber(n uint) *EnumValue {
+	for _, v := range e.Values {
+		if v.Value == n {
+			return v
+		}
+	}
+	return nil
+}
+
+func (e *Enum) valueByName(name string) *EnumValue {
+	for _, v := range e.Values {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
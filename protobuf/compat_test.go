@@ -0,0 +1,282 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindBreakingChanges(t *testing.T) {
+	prev := &Package{
+		Messages: []*Message{
+			{
+				Name:          "Foo",
+				Reserved:      []uint{3},
+				ReservedNames: []string{"c"},
+				Fields: []*Field{
+					{Name: "a", Pos: 1, Type: NewBasic("string")},
+					{Name: "b", Pos: 2, Type: NewBasic("int64")},
+				},
+			},
+			{Name: "Bar"},
+		},
+		Enums: []*Enum{
+			{
+				Name:          "Status",
+				Reserved:      []uint{2},
+				ReservedNames: []string{"OPEN"},
+				Values: []*EnumValue{
+					{Name: "ACTIVE", Value: 0},
+					{Name: "CLOSED", Value: 1},
+				},
+			},
+			{Name: "Deleted"},
+		},
+	}
+
+	curr := &Package{
+		Messages: []*Message{
+			{
+				Name: "Foo",
+				Fields: []*Field{
+					{Name: "a", Pos: 1, Type: NewBasic("string"), Repeated: true},
+					{Name: "c", Pos: 2, Type: NewBasic("int64")},
+					{Name: "b", Pos: 3, Type: NewBasic("int64")},
+				},
+			},
+		},
+		Enums: []*Enum{
+			{
+				Name: "Status",
+				Values: []*EnumValue{
+					{Name: "CLOSED", Value: 0},
+					{Name: "ACTIVE", Value: 1},
+					{Name: "OPEN", Value: 2},
+				},
+			},
+		},
+	}
+
+	changes := FindBreakingChanges(prev, curr)
+
+	var kinds = make([]BreakingChangeKind, len(changes))
+	var elems = make([]string, len(changes))
+	var categories = make([]BreakingChangeCategory, len(changes))
+	for i, c := range changes {
+		kinds[i] = c.Kind
+		elems[i] = c.Element
+		categories[i] = c.Category
+	}
+
+	require.Equal(t, []BreakingChangeKind{
+		FieldTypeChanged,
+		FieldNameReused,
+		ReservedFieldReused,
+		ReservedFieldNameReused,
+		MessageDeleted,
+		EnumValueChanged,
+		EnumValueChanged,
+		ReservedEnumValueReused,
+		ReservedEnumValueReused,
+		EnumDeleted,
+	}, kinds)
+	require.Equal(t, []string{
+		"Foo.a",
+		"Foo.b",
+		"Foo.b",
+		"Foo.c",
+		"Bar",
+		"Status.ACTIVE",
+		"Status.CLOSED",
+		"Status.OPEN",
+		"Status.OPEN",
+		"Deleted",
+	}, elems)
+	require.Equal(t, []BreakingChangeCategory{
+		WireBreaking,
+		WireBreaking,
+		WireBreaking,
+		JSONBreaking,
+		SourceBreaking,
+		WireBreaking,
+		WireBreaking,
+		WireBreaking,
+		JSONBreaking,
+		SourceBreaking,
+	}, categories)
+	require.Equal(t, "wire: 6, json: 2, source: 2", changes.Score())
+	require.Equal(t, "field-type-changed: Foo.a: field number 1 changed type from string to repeated string", changes[0].String())
+}
+
+func TestFindBreakingChangesRenamed(t *testing.T) {
+	prev := &Package{
+		Messages: []*Message{
+			{
+				Name: "Foo",
+				Fields: []*Field{
+					{Name: "a", Pos: 1, Type: NewBasic("string")},
+					{Name: "b", Pos: 2, Type: NewBasic("string")},
+				},
+			},
+		},
+		Enums: []*Enum{
+			{
+				Name: "Status",
+				Values: []*EnumValue{
+					{Name: "ACTIVE", Value: 0},
+				},
+			},
+		},
+	}
+
+	curr := &Package{
+		Messages: []*Message{
+			{
+				Name: "Foo",
+				Fields: []*Field{
+					{Name: "c", Pos: 1, Type: NewBasic("string")},
+					{
+						Name:    "d",
+						Pos:     2,
+						Type:    NewBasic("string"),
+						Options: Options{"json_name": NewStringValue("b")},
+					},
+				},
+			},
+		},
+		Enums: []*Enum{
+			{
+				Name: "Status",
+				Values: []*EnumValue{
+					{Name: "ENABLED", Value: 0},
+				},
+			},
+		},
+	}
+
+	changes := FindBreakingChanges(prev, curr)
+	require.Len(t, changes, 3)
+	require.Equal(t, &BreakingChange{
+		Kind:     FieldRenamed,
+		Category: JSONBreaking,
+		Element:  "Foo.c",
+		Message:  "field number 1 was named a, and a in JSON instead of c",
+	}, changes[0])
+	require.Equal(t, &BreakingChange{
+		Kind:     FieldRenamed,
+		Category: SourceBreaking,
+		Element:  "Foo.d",
+		Message:  "field number 2 was named b, with the same name in JSON",
+	}, changes[1])
+	require.Equal(t, &BreakingChange{
+		Kind:     EnumValueRenamed,
+		Category: JSONBreaking,
+		Element:  "Status.ENABLED",
+		Message:  "enum value 0 was named ACTIVE",
+	}, changes[2])
+}
+
+func TestParseBreakingChangePolicy(t *testing.T) {
+	require := require.New(t)
+
+	policy, err := ParseBreakingChangePolicy([]string{"json=warn", "source=ignore"})
+	require.NoError(err)
+	require.Equal(FailOnBreakingChange, policy.Action(WireBreaking))
+	require.Equal(WarnOnBreakingChange, policy.Action(JSONBreaking))
+	require.Equal(IgnoreBreakingChange, policy.Action(SourceBreaking))
+
+	for _, p := range []string{"json", "=warn", "json=", "binary=warn", "json=skip"} {
+		_, err := ParseBreakingChangePolicy([]string{p})
+		require.Error(err, p)
+	}
+}
+
+func TestFindBreakingChangesCompatible(t *testing.T) {
+	prev := &Package{
+		Messages: []*Message{
+			{
+				Name: "Foo",
+				Fields: []*Field{
+					{Name: "a", Pos: 1, Type: NewBasic("string")},
+				},
+			},
+		},
+	}
+
+	curr := &Package{
+		Messages: []*Message{
+			{
+				Name: "Foo",
+				Fields: []*Field{
+					{Name: "a", Pos: 1, Type: NewBasic("string")},
+					{Name: "b", Pos: 2, Type: NewNamed("foo", "Bar")},
+				},
+			},
+			{Name: "Bar"},
+		},
+	}
+
+	require.Empty(t, FindBreakingChanges(prev, curr))
+}
+
+func TestReserveDeleted(t *testing.T) {
+	require := require.New(t)
+
+	prev := &Package{
+		Messages: []*Message{
+			{
+				Name:          "Foo",
+				Reserved:      []uint{4},
+				ReservedNames: []string{"d"},
+				Fields: []*Field{
+					{Name: "a", Pos: 1, Type: NewBasic("string")},
+					{Name: "b", Pos: 2, Type: NewBasic("int64")},
+					{Name: "c", Pos: 3, Type: NewBasic("int64")},
+				},
+			},
+		},
+		Enums: []*Enum{
+			{
+				Name: "Status",
+				Values: []*EnumValue{
+					{Name: "ACTIVE", Value: 0},
+					{Name: "PENDING", Value: 1},
+					{Name: "CLOSED", Value: 2},
+				},
+			},
+		},
+	}
+
+	curr := &Package{
+		Messages: []*Message{
+			{
+				Name: "Foo",
+				Fields: []*Field{
+					{Name: "a", Pos: 1, Type: NewBasic("string")},
+					{Name: "c", Pos: 2, Type: NewBasic("int64")},
+				},
+			},
+		},
+		Enums: []*Enum{
+			{
+				Name: "Status",
+				Values: []*EnumValue{
+					{Name: "ACTIVE", Value: 0},
+					{Name: "CLOSED", Value: 1},
+				},
+			},
+		},
+	}
+
+	ReserveDeleted(prev, curr)
+
+	msg := curr.Messages[0]
+	require.Equal([]uint{4, 3}, msg.Reserved)
+	require.Equal([]string{"d", "b"}, msg.ReservedNames)
+
+	enum := curr.Enums[0]
+	require.Equal([]uint{2}, enum.Reserved)
+	require.Equal([]string{"PENDING"}, enum.ReservedNames)
+
+	require.Equal([]uint{4}, prev.Messages[0].Reserved)
+}
@@ -0,0 +1,701 @@
+package protobuf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+
+	// The descriptors of gogo.proto and the well-known types are registered
+	// by their Go packages.
+	_ "github.com/gogo/protobuf/gogoproto"
+	_ "github.com/gogo/protobuf/types"
+)
+
+// registeredFiles are the files that can be imported without being on the
+// import paths of a DescriptorSet, indexed by the path they are imported
+// with, with the name their descriptors are registered with.
+var registeredFiles = map[string]string{
+	"github.com/gogo/protobuf/gogoproto/gogo.proto": "gogo.proto",
+	"google/protobuf/descriptor.proto":              "descriptor.proto",
+	"google/protobuf/any.proto":                     "any.proto",
+	"google/protobuf/duration.proto":                "duration.proto",
+	"google/protobuf/empty.proto":                   "empty.proto",
+	"google/protobuf/field_mask.proto":              "field_mask.proto",
+	"google/protobuf/struct.proto":                  "struct.proto",
+	"google/protobuf/timestamp.proto":               "timestamp.proto",
+	"google/protobuf/wrappers.proto":                "wrappers.proto",
+}
+
+// Paths of the elements of the descriptors of files, messages, enums and
+// services in their source code info.
+const (
+	fileMessagePath   = 4
+	fileEnumPath      = 5
+	fileServicePath   = 6
+	messageFieldPath  = 2
+	enumValuePath     = 2
+	serviceMethodPath = 2
+)
+
+const unsupportedOptions = "only the standard options and the ones of gogoproto are supported without protoc"
+
+// DescriptorSet builds the descriptors of the .proto files generated by
+// proteus from their packages, and of all the files they import, so Go code
+// can be generated from them in-process instead of with protoc. Only the
+// subset of the language written by Generator is supported, which is also
+// what imported files can have, unless they are gogo.proto or one of the
+// well-known types.
+type DescriptorSet struct {
+	paths []string
+	files map[string]*descriptor.FileDescriptorProto
+	// order are the names of the files, each one after the ones it imports.
+	order []string
+	// loading are the files whose imports are being added.
+	loading map[string]bool
+	// types are the kinds of all the messages and enums of the files,
+	// indexed by their full name, e.g. .google.protobuf.Timestamp.
+	types map[string]descriptor.FieldDescriptorProto_Type
+}
+
+// NewDescriptorSet creates a DescriptorSet that looks up the files imported
+// in the given folders, in order, like protoc does with its proto paths.
+func NewDescriptorSet(paths ...string) *DescriptorSet {
+	return &DescriptorSet{
+		paths:   paths,
+		files:   make(map[string]*descriptor.FileDescriptorProto),
+		loading: make(map[string]bool),
+		types:   make(map[string]descriptor.FieldDescriptorProto_Type),
+	}
+}
+
+// Files returns the descriptors of all the files in the set, each one after
+// the ones it imports.
+func (s *DescriptorSet) Files() []*descriptor.FileDescriptorProto {
+	var files = make([]*descriptor.FileDescriptorProto, len(s.order))
+	for i, name := range s.order {
+		files[i] = s.files[name]
+	}
+	return files
+}
+
+// Name returns the name of the .proto file at the given path, which is its
+// path relative to the first folder of the set that has it.
+func (s *DescriptorSet) Name(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range s.paths {
+		dir, err := filepath.Abs(p)
+		if err != nil {
+			return "", err
+		}
+
+		if rel, err := filepath.Rel(dir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel), nil
+		}
+	}
+	return "", fmt.Errorf("%s is not in any of the import paths: %s", file, strings.Join(s.paths, ", "))
+}
+
+// AddFile parses the .proto file at the given path and adds its descriptor to
+// the set, if it was not already, and returns its name.
+func (s *DescriptorSet) AddFile(file string) (string, error) {
+	name, err := s.Name(file)
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := s.files[name]; ok {
+		return name, nil
+	}
+
+	pkg, err := ParseFile(file)
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s: %s", file, err)
+	}
+
+	_, err = s.Add(name, pkg)
+	return name, err
+}
+
+// Add adds to the set the descriptor of the file with the given name, which
+// is the path it is imported with, built from the given package, after the
+// ones of the files it imports.
+func (s *DescriptorSet) Add(name string, pkg *Package) (*descriptor.FileDescriptorProto, error) {
+	s.loading[name] = true
+	defer delete(s.loading, name)
+
+	for _, imp := range pkg.Imports {
+		if err := s.load(imp); err != nil {
+			return nil, err
+		}
+	}
+
+	prefix := "." + pkg.Name + "."
+	for _, m := range pkg.Messages {
+		s.types[prefix+m.Name] = descriptor.FieldDescriptorProto_TYPE_MESSAGE
+	}
+
+	for _, e := range pkg.Enums {
+		s.types[prefix+e.Name] = descriptor.FieldDescriptorProto_TYPE_ENUM
+	}
+
+	b := &descriptorBuilder{pkg: pkg, types: s.types, info: new(descriptor.SourceCodeInfo)}
+	fd, err := b.file(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	s.files[name] = fd
+	s.order = append(s.order, name)
+	return fd, nil
+}
+
+// load adds the imported file with the given name to the set, if it was not
+// already, from the registered descriptors or the import paths.
+func (s *DescriptorSet) load(name string) error {
+	if _, ok := s.files[name]; ok {
+		return nil
+	}
+
+	if s.loading[name] {
+		return fmt.Errorf("import cycle in %s", name)
+	}
+
+	if registered, ok := registeredFiles[name]; ok {
+		return s.addRegistered(name, registered)
+	}
+
+	for _, p := range s.paths {
+		file := filepath.Join(p, filepath.FromSlash(name))
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+
+		pkg, err := ParseFile(file)
+		if err != nil {
+			return fmt.Errorf("error parsing imported file %s, only the files generated by proteus can be imported without protoc: %s", file, err)
+		}
+
+		_, err = s.Add(name, pkg)
+		return err
+	}
+	return fmt.Errorf("imported file %s not found in any of the import paths: %s", name, strings.Join(s.paths, ", "))
+}
+
+// addRegistered adds the file with the given name, whose descriptor is
+// registered with another one, after the ones it imports.
+func (s *DescriptorSet) addRegistered(name, registered string) error {
+	gz := proto.FileDescriptor(registered)
+	if gz == nil {
+		return fmt.Errorf("descriptor of %s is not registered", name)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return fmt.Errorf("invalid descriptor of %s: %s", name, err)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("invalid descriptor of %s: %s", name, err)
+	}
+
+	fd := new(descriptor.FileDescriptorProto)
+	if err := proto.Unmarshal(data, fd); err != nil {
+		return fmt.Errorf("invalid descriptor of %s: %s", name, err)
+	}
+
+	for _, dep := range fd.Dependency {
+		if err := s.load(dep); err != nil {
+			return err
+		}
+	}
+
+	fd.Name = proto.String(name)
+	s.addTypes("."+fd.GetPackage()+".", fd.MessageType, fd.EnumType)
+	s.files[name] = fd
+	s.order = append(s.order, name)
+	return nil
+}
+
+func (s *DescriptorSet) addTypes(prefix string, msgs []*descriptor.DescriptorProto, enums []*descriptor.EnumDescriptorProto) {
+	for _, m := range msgs {
+		s.types[prefix+m.GetName()] = descriptor.FieldDescriptorProto_TYPE_MESSAGE
+		s.addTypes(prefix+m.GetName()+".", m.NestedType, m.EnumType)
+	}
+
+	for _, e := range enums {
+		s.types[prefix+e.GetName()] = descriptor.FieldDescriptorProto_TYPE_ENUM
+	}
+}
+
+// descriptorBuilder builds the descriptor of the file of a package.
+type descriptorBuilder struct {
+	pkg   *Package
+	types map[string]descriptor.FieldDescriptorProto_Type
+	info  *descriptor.SourceCodeInfo
+}
+
+func (b *descriptorBuilder) file(name string) (*descriptor.FileDescriptorProto, error) {
+	fd := &descriptor.FileDescriptorProto{
+		Name:       proto.String(name),
+		Package:    proto.String(b.pkg.Name),
+		Dependency: b.pkg.Imports,
+		Syntax:     proto.String("proto3"),
+	}
+
+	if len(b.pkg.Options) > 0 {
+		fd.Options = new(descriptor.FileOptions)
+		if err := setOptions(fd.Options, b.pkg.Options); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, m := range b.pkg.Messages {
+		msg, err := b.message(m, []int32{fileMessagePath, int32(i)})
+		if err != nil {
+			return nil, fmt.Errorf("message %s: %s", m.Name, err)
+		}
+		fd.MessageType = append(fd.MessageType, msg)
+	}
+
+	for i, e := range b.pkg.Enums {
+		enum, err := b.enum(e, []int32{fileEnumPath, int32(i)})
+		if err != nil {
+			return nil, fmt.Errorf("enum %s: %s", e.Name, err)
+		}
+		fd.EnumType = append(fd.EnumType, enum)
+	}
+
+	for _, s := range b.pkg.Services {
+		if len(s.RPCs) == 0 {
+			continue
+		}
+
+		svc, err := b.service(s, []int32{fileServicePath, int32(len(fd.Service))})
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %s", s.Name, err)
+		}
+		fd.Service = append(fd.Service, svc)
+	}
+
+	if len(b.info.Location) > 0 {
+		fd.SourceCodeInfo = b.info
+	}
+	return fd, nil
+}
+
+func (b *descriptorBuilder) message(m *Message, path []int32) (*descriptor.DescriptorProto, error) {
+	b.comment(path, m.Docs)
+	msg := &descriptor.DescriptorProto{
+		Name:         proto.String(m.Name),
+		ReservedName: m.ReservedNames,
+	}
+
+	for _, n := range m.Reserved {
+		msg.ReservedRange = append(msg.ReservedRange, &descriptor.DescriptorProto_ReservedRange{
+			Start: proto.Int32(int32(n)),
+			End:   proto.Int32(int32(n) + 1),
+		})
+	}
+
+	if len(m.Options) > 0 {
+		msg.Options = new(descriptor.MessageOptions)
+		if err := setOptions(msg.Options, m.Options); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, f := range m.Fields {
+		b.comment(append(append([]int32{}, path...), messageFieldPath, int32(i)), f.Docs)
+		field, err := b.field(msg, f)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %s", f.Name, err)
+		}
+		msg.Field = append(msg.Field, field)
+	}
+	return msg, nil
+}
+
+// proto3Optional is the proto3_optional field of a field descriptor, number
+// 17, encoded with the value true, as the descriptors of gogo predate it.
+var proto3Optional = []byte{0x88, 0x01, 0x01}
+
+// field returns the descriptor of the given field of the message. Maps are
+// repeated fields of an entry message nested in it, as protoc does.
+func (b *descriptorBuilder) field(msg *descriptor.DescriptorProto, f *Field) (*descriptor.FieldDescriptorProto, error) {
+	field := &descriptor.FieldDescriptorProto{
+		Name:     proto.String(f.Name),
+		Number:   proto.Int32(int32(f.Pos)),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String(jsonName(f.Name)),
+	}
+
+	// Like protoc does, every proto3 optional field is the only field of a
+	// synthetic oneof, which follows the rest of the oneofs of the message.
+	if f.Optional {
+		field.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+		field.XXX_unrecognized = proto3Optional
+		msg.OneofDecl = append(msg.OneofDecl, &descriptor.OneofDescriptorProto{
+			Name: proto.String("_" + f.Name),
+		})
+	}
+
+	if f.Repeated {
+		field.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+
+	typ := f.Type
+	if a, ok := typ.(*Alias); ok {
+		typ = a.Underlying
+	}
+
+	if m, ok := typ.(*Map); ok {
+		entry, err := b.mapEntry(f.Name, m)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.NestedType = append(msg.NestedType, entry)
+		field.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		field.Type = descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		field.TypeName = proto.String("." + b.pkg.Name + "." + msg.GetName() + "." + entry.GetName())
+	} else if err := b.setType(field, typ); err != nil {
+		return nil, err
+	}
+
+	var opts = make(Options)
+	for name, v := range f.Options {
+		if s, ok := v.(StringValue); ok && name == "json_name" {
+			field.JsonName = proto.String(s.val)
+			continue
+		}
+		opts[name] = v
+	}
+
+	if len(opts) > 0 {
+		field.Options = new(descriptor.FieldOptions)
+		if err := setOptions(field.Options, opts); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (b *descriptorBuilder) mapEntry(name string, m *Map) (*descriptor.DescriptorProto, error) {
+	entryName := jsonName(name)
+	if entryName != "" {
+		entryName = strings.ToUpper(entryName[:1]) + entryName[1:]
+	}
+
+	entry := &descriptor.DescriptorProto{
+		Name:    proto.String(entryName + "Entry"),
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+
+	for i, t := range []Type{m.Key, m.Value} {
+		name := []string{"key", "value"}[i]
+		field := &descriptor.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(int32(i + 1)),
+			Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			JsonName: proto.String(name),
+		}
+
+		if err := b.setType(field, t); err != nil {
+			return nil, err
+		}
+		entry.Field = append(entry.Field, field)
+	}
+	return entry, nil
+}
+
+// setType sets the given type to the field, which is the name of a scalar or
+// one of the messages or enums of the files of the set.
+func (b *descriptorBuilder) setType(field *descriptor.FieldDescriptorProto, typ Type) error {
+	switch t := typ.(type) {
+	case *Alias:
+		return b.setType(field, t.Underlying)
+	case *Basic:
+		v, ok := descriptor.FieldDescriptorProto_Type_value["TYPE_"+strings.ToUpper(t.Name)]
+		if !ok {
+			return fmt.Errorf("unknown type %s", t.Name)
+		}
+		field.Type = descriptor.FieldDescriptorProto_Type(v).Enum()
+		return nil
+	case *Named:
+		name, kind, err := b.typeName(t)
+		if err != nil {
+			return err
+		}
+		field.Type = kind.Enum()
+		field.TypeName = proto.String(name)
+		return nil
+	}
+	return fmt.Errorf("type %s can not be used in a map", typ)
+}
+
+// typeName returns the full name of the given named type and whether it is
+// a message or an enum.
+func (b *descriptorBuilder) typeName(t *Named) (string, descriptor.FieldDescriptorProto_Type, error) {
+	pkg := t.Package
+	if pkg == "" {
+		pkg = b.pkg.Name
+	}
+
+	name := "." + pkg + "." + t.Name
+	kind, ok := b.types[name]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown type %s, it is not in the file or the ones it imports", t)
+	}
+	return name, kind, nil
+}
+
+func (b *descriptorBuilder) enum(e *Enum, path []int32) (*descriptor.EnumDescriptorProto, error) {
+	b.comment(path, e.Docs)
+	enum := &descriptor.EnumDescriptorProto{
+		Name:         proto.String(e.Name),
+		ReservedName: e.ReservedNames,
+	}
+
+	for _, n := range e.Reserved {
+		enum.ReservedRange = append(enum.ReservedRange, &descriptor.EnumDescriptorProto_EnumReservedRange{
+			Start: proto.Int32(int32(n)),
+			End:   proto.Int32(int32(n)),
+		})
+	}
+
+	if len(e.Options) > 0 {
+		enum.Options = new(descriptor.EnumOptions)
+		if err := setOptions(enum.Options, e.Options); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, v := range e.Values {
+		b.comment(append(append([]int32{}, path...), enumValuePath, int32(i)), v.Docs)
+		value := &descriptor.EnumValueDescriptorProto{
+			Name:   proto.String(v.Name),
+			Number: proto.Int32(int32(v.Value)),
+		}
+
+		if len(v.Options) > 0 {
+			value.Options = new(descriptor.EnumValueOptions)
+			if err := setOptions(value.Options, v.Options); err != nil {
+				return nil, fmt.Errorf("value %s: %s", v.Name, err)
+			}
+		}
+		enum.Value = append(enum.Value, value)
+	}
+	return enum, nil
+}
+
+func (b *descriptorBuilder) service(s *Service, path []int32) (*descriptor.ServiceDescriptorProto, error) {
+	b.comment(path, s.Docs)
+	svc := &descriptor.ServiceDescriptorProto{Name: proto.String(s.Name)}
+
+	for i, rpc := range s.RPCs {
+		b.comment(append(append([]int32{}, path...), serviceMethodPath, int32(i)), rpc.Docs)
+		method := &descriptor.MethodDescriptorProto{Name: proto.String(rpc.Name)}
+
+		input, err := b.rpcType(rpc.Input)
+		if err != nil {
+			return nil, fmt.Errorf("rpc %s: %s", rpc.Name, err)
+		}
+
+		output, err := b.rpcType(rpc.Output)
+		if err != nil {
+			return nil, fmt.Errorf("rpc %s: %s", rpc.Name, err)
+		}
+		method.InputType, method.OutputType = proto.String(input), proto.String(output)
+		if rpc.IsStreaming() {
+			method.ServerStreaming = proto.Bool(true)
+		}
+
+		if len(rpc.Options) > 0 {
+			method.Options = new(descriptor.MethodOptions)
+			if err := setOptions(method.Options, rpc.Options); err != nil {
+				return nil, fmt.Errorf("rpc %s: %s", rpc.Name, err)
+			}
+		}
+		svc.Method = append(svc.Method, method)
+	}
+	return svc, nil
+}
+
+// rpcType returns the full name of the message of the input or output of an
+// RPC.
+func (b *descriptorBuilder) rpcType(typ Type) (string, error) {
+	t, ok := typ.(*Named)
+	if !ok {
+		return "", fmt.Errorf("type %s is not a message", typ)
+	}
+
+	name, kind, err := b.typeName(t)
+	if err != nil {
+		return "", err
+	}
+
+	if kind != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return "", fmt.Errorf("type %s is not a message", typ)
+	}
+	return name, nil
+}
+
+// comment adds the given docs as the leading comments of the element at the
+// given path, so they are written to the generated code.
+func (b *descriptorBuilder) comment(path []int32, docs []string) {
+	if len(docs) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, d := range docs {
+		buf.WriteString(" " + d + "\n")
+	}
+
+	b.info.Location = append(b.info.Location, &descriptor.SourceCodeInfo_Location{
+		Path:            path,
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(buf.String()),
+	})
+}
+
+// setOptions sets the given options in the options message of a descriptor.
+// Standard options are set to the field of the message with their name and
+// the ones of gogoproto as extensions of the message.
+func setOptions(msg proto.Message, opts Options) error {
+	for _, opt := range opts.Sorted() {
+		var err error
+		if strings.HasPrefix(opt.Name, "(") && strings.HasSuffix(opt.Name, ")") {
+			err = setExtension(msg, strings.Trim(opt.Name, "()"), opt.Value)
+		} else {
+			err = setStandardOption(msg, opt.Name, opt.Value)
+		}
+
+		if err != nil {
+			return fmt.Errorf("option %s: %s", opt.Name, err)
+		}
+	}
+	return nil
+}
+
+func setStandardOption(msg proto.Message, name string, value OptionValue) error {
+	v := reflect.ValueOf(msg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.Split(v.Type().Field(i).Tag.Get("protobuf"), ",")
+		if !containsString(tag, "name="+name) {
+			continue
+		}
+
+		field := v.Field(i)
+		if field.Kind() != reflect.Ptr {
+			return fmt.Errorf("it is not supported without protoc")
+		}
+
+		val, err := optionValue(field.Type().Elem(), tag, value)
+		if err != nil {
+			return err
+		}
+
+		field.Set(val)
+		return nil
+	}
+	return fmt.Errorf("it is not an option of %s, %s", proto.MessageName(msg), unsupportedOptions)
+}
+
+func setExtension(msg proto.Message, name string, value OptionValue) error {
+	for _, ext := range proto.RegisteredExtensions(msg) {
+		if ext.Name != name {
+			continue
+		}
+
+		typ := reflect.TypeOf(ext.ExtensionType)
+		if typ.Kind() != reflect.Ptr {
+			return fmt.Errorf("it is not supported without protoc")
+		}
+
+		val, err := optionValue(typ.Elem(), strings.Split(ext.Tag, ","), value)
+		if err != nil {
+			return err
+		}
+		return proto.SetExtension(msg, ext, val.Interface())
+	}
+	return fmt.Errorf("it is not an option of %s, %s", proto.MessageName(msg), unsupportedOptions)
+}
+
+// optionValue returns a pointer to the given value of an option of the given
+// type, whose protobuf tag is the given one, which tells the name of the type
+// of enums.
+func optionValue(typ reflect.Type, tag []string, value OptionValue) (reflect.Value, error) {
+	ptr := reflect.New(typ)
+	v := ptr.Elem()
+
+	if typ.Kind() == reflect.String {
+		s, ok := value.(StringValue)
+		if !ok {
+			return ptr, fmt.Errorf("expected a string value, got %s", value)
+		}
+		v.SetString(s.val)
+		return ptr, nil
+	}
+
+	lit, ok := value.(LiteralValue)
+	if !ok {
+		return ptr, fmt.Errorf("expected a literal value, got %s", value)
+	}
+
+	var err error
+	switch typ.Kind() {
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(lit.val); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int32, reflect.Int64:
+		for _, t := range tag {
+			if strings.HasPrefix(t, "enum=") {
+				n, ok := proto.EnumValueMap(strings.TrimPrefix(t, "enum="))[lit.val]
+				if !ok {
+					return ptr, fmt.Errorf("unknown value %s", lit.val)
+				}
+				v.SetInt(int64(n))
+				return ptr, nil
+			}
+		}
+
+		var n int64
+		if n, err = strconv.ParseInt(lit.val, 10, typ.Bits()); err == nil {
+			v.SetInt(n)
+		}
+	case reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(lit.val, 10, typ.Bits()); err == nil {
+			v.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(lit.val, typ.Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	default:
+		return ptr, fmt.Errorf("values of type %s are not supported without protoc", typ)
+	}
+
+	if err != nil {
+		return ptr, fmt.Errorf("invalid value %s: %s", lit.val, err)
+	}
+	return ptr, nil
+}
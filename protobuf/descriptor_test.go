@@ -0,0 +1,290 @@
+package protobuf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogo/protobuf/gogoproto"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestDescriptorSetAdd(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &Package{
+		Name:    "foo.bar",
+		Path:    "gitlab.com/foo/bar",
+		Imports: []string{"github.com/gogo/protobuf/gogoproto/gogo.proto", "google/protobuf/timestamp.proto"},
+		Options: Options{
+			"go_package":                      NewStringValue("bar"),
+			"optimize_for":                    NewLiteralValue("SPEED"),
+			"(gogoproto.sizer_all)":           NewLiteralValue("true"),
+			"(gogoproto.goproto_getters_all)": NewLiteralValue("false"),
+		},
+		Messages: []*Message{
+			{
+				Docs:          []string{"User is an user."},
+				Name:          "User",
+				Reserved:      []uint{2},
+				ReservedNames: []string{"password"},
+				Fields: []*Field{
+					{Name: "user_id", Pos: 1, Type: NewBasic("string"), Options: Options{"(gogoproto.customname)": NewStringValue("UserID")}},
+					{Name: "created_at", Pos: 3, Type: NewNamed("google.protobuf", "Timestamp"), Options: Options{"(gogoproto.stdtime)": NewLiteralValue("true")}},
+					{Name: "status", Pos: 4, Type: NewNamed("foo.bar", "Status"), Options: Options{"json_name": NewStringValue("state")}},
+					{Name: "tags", Pos: 5, Repeated: true, Type: NewBasic("string"), Options: Options{"deprecated": NewLiteralValue("true")}},
+					{Name: "extra_info", Pos: 6, Type: NewMap(NewBasic("string"), NewNamed("foo.bar", "User"))},
+				},
+			},
+		},
+		Enums: []*Enum{
+			{
+				Name:     "Status",
+				Reserved: []uint{3},
+				Options:  Options{"allow_alias": NewLiteralValue("true")},
+				Values: []*EnumValue{
+					{Name: "ACTIVE", Value: 0, Docs: []string{"ACTIVE users can log in."}},
+					{Name: "INACTIVE", Value: 1},
+				},
+			},
+		},
+		Services: []*Service{
+			{Name: "Empty"},
+			{
+				Name: "UserService",
+				RPCs: []*RPC{
+					{Name: "GetUser", Input: NewNamed("foo.bar", "User"), Output: NewNamed("foo.bar", "User"), Options: Options{
+						"deprecated":        NewLiteralValue("true"),
+						"idempotency_level": NewLiteralValue("IDEMPOTENT"),
+					}},
+					{Name: "WatchUsers", Input: NewNamed("foo.bar", "User"), Output: NewNamed("foo.bar", "User"), Stream: scanner.ChanStream},
+				},
+			},
+		},
+	}
+
+	set := NewDescriptorSet()
+	fd, err := set.Add("gitlab.com/foo/bar/generated.proto", pkg)
+	require.Nil(err)
+
+	var names []string
+	for _, f := range set.Files() {
+		names = append(names, f.GetName())
+	}
+	require.Equal([]string{
+		"google/protobuf/descriptor.proto",
+		"github.com/gogo/protobuf/gogoproto/gogo.proto",
+		"google/protobuf/timestamp.proto",
+		"gitlab.com/foo/bar/generated.proto",
+	}, names)
+
+	require.Equal("foo.bar", fd.GetPackage())
+	require.Equal("proto3", fd.GetSyntax())
+	require.Equal("bar", fd.GetOptions().GetGoPackage())
+	require.Equal(descriptor.FileOptions_SPEED, fd.GetOptions().GetOptimizeFor())
+	require.True(proto.GetBoolExtension(fd.Options, gogoproto.E_SizerAll, false))
+	require.False(proto.GetBoolExtension(fd.Options, gogoproto.E_GoprotoGettersAll, true))
+
+	msg := fd.MessageType[0]
+	require.Equal("User", msg.GetName())
+	require.Equal([]string{"password"}, msg.ReservedName)
+	require.Len(msg.ReservedRange, 1)
+	require.Equal(int32(2), msg.ReservedRange[0].GetStart())
+	require.Equal(int32(3), msg.ReservedRange[0].GetEnd())
+
+	require.Len(msg.Field, 5)
+	require.Equal("userId", msg.Field[0].GetJsonName())
+	require.Equal(descriptor.FieldDescriptorProto_TYPE_STRING, msg.Field[0].GetType())
+	require.Equal("UserID", gogoproto.GetCustomName(msg.Field[0]))
+
+	require.Equal(descriptor.FieldDescriptorProto_TYPE_MESSAGE, msg.Field[1].GetType())
+	require.Equal(".google.protobuf.Timestamp", msg.Field[1].GetTypeName())
+	require.True(gogoproto.IsStdTime(msg.Field[1]))
+
+	require.Equal(descriptor.FieldDescriptorProto_TYPE_ENUM, msg.Field[2].GetType())
+	require.Equal(".foo.bar.Status", msg.Field[2].GetTypeName())
+	require.Equal("state", msg.Field[2].GetJsonName())
+	require.Nil(msg.Field[2].Options)
+
+	require.Equal(descriptor.FieldDescriptorProto_LABEL_REPEATED, msg.Field[3].GetLabel())
+	require.True(msg.Field[3].GetOptions().GetDeprecated())
+
+	require.Equal(descriptor.FieldDescriptorProto_LABEL_REPEATED, msg.Field[4].GetLabel())
+	require.Equal(".foo.bar.User.ExtraInfoEntry", msg.Field[4].GetTypeName())
+	require.Len(msg.NestedType, 1)
+	entry := msg.NestedType[0]
+	require.Equal("ExtraInfoEntry", entry.GetName())
+	require.True(entry.GetOptions().GetMapEntry())
+	require.Equal("key", entry.Field[0].GetName())
+	require.Equal(descriptor.FieldDescriptorProto_TYPE_STRING, entry.Field[0].GetType())
+	require.Equal(".foo.bar.User", entry.Field[1].GetTypeName())
+
+	enum := fd.EnumType[0]
+	require.Equal("Status", enum.GetName())
+	require.True(enum.GetOptions().GetAllowAlias())
+	require.Equal(int32(3), enum.ReservedRange[0].GetStart())
+	require.Equal(int32(3), enum.ReservedRange[0].GetEnd())
+	require.Len(enum.Value, 2)
+	require.Equal("INACTIVE", enum.Value[1].GetName())
+	require.Equal(int32(1), enum.Value[1].GetNumber())
+
+	require.Len(fd.Service, 1)
+	method := fd.Service[0].Method[0]
+	require.Equal("GetUser", method.GetName())
+	require.Equal(".foo.bar.User", method.GetInputType())
+	require.Equal(".foo.bar.User", method.GetOutputType())
+	require.True(method.GetOptions().GetDeprecated())
+	require.Equal(descriptor.MethodOptions_IDEMPOTENT, method.GetOptions().GetIdempotencyLevel())
+	require.False(method.GetServerStreaming())
+	require.True(fd.Service[0].Method[1].GetServerStreaming())
+
+	locs := fd.GetSourceCodeInfo().GetLocation()
+	require.Len(locs, 2)
+	require.Equal([]int32{4, 0}, locs[0].Path)
+	require.Equal(" User is an user.\n", locs[0].GetLeadingComments())
+	require.Equal([]int32{5, 0, 2, 0}, locs[1].Path)
+}
+
+func TestDescriptorSetAddOptional(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &Package{
+		Name: "foo",
+		Messages: []*Message{{Name: "Foo", Fields: []*Field{
+			{Name: "bar", Pos: 1, Optional: true, Type: NewBasic("string")},
+			{Name: "baz", Pos: 2, Type: NewBasic("string")},
+			{Name: "qux", Pos: 3, Optional: true, Type: NewBasic("int64")},
+		}}},
+	}
+
+	fd, err := NewDescriptorSet().Add("foo.proto", pkg)
+	require.Nil(err)
+
+	msg := fd.MessageType[0]
+	require.Len(msg.OneofDecl, 2)
+	require.Equal("_bar", msg.OneofDecl[0].GetName())
+	require.Equal("_qux", msg.OneofDecl[1].GetName())
+	require.Equal(int32(0), msg.Field[0].GetOneofIndex())
+	require.Nil(msg.Field[1].OneofIndex)
+	require.Equal(int32(1), msg.Field[2].GetOneofIndex())
+
+	data, err := proto.Marshal(fd)
+	require.Nil(err)
+
+	var file descriptorpb.FileDescriptorProto
+	require.Nil(protov2.Unmarshal(data, &file))
+	fields := file.GetMessageType()[0].GetField()
+	require.True(fields[0].GetProto3Optional())
+	require.False(fields[1].GetProto3Optional())
+	require.True(fields[2].GetProto3Optional())
+
+	_, err = protodesc.NewFile(&file, nil)
+	require.Nil(err, "the descriptor is valid for the protobuf runtime")
+}
+
+func TestDescriptorSetAddErrors(t *testing.T) {
+	cases := map[string]*Package{
+		"unknown type": {
+			Name:     "foo",
+			Messages: []*Message{{Name: "Foo", Fields: []*Field{{Name: "bar", Pos: 1, Type: NewNamed("foo", "Bar")}}}},
+		},
+		"unknown option": {
+			Name:    "foo",
+			Options: Options{"foo": NewLiteralValue("true")},
+		},
+		"custom option": {
+			Name:     "foo",
+			Messages: []*Message{{Name: "Foo", Fields: []*Field{{Name: "bar", Pos: 1, Type: NewBasic("string"), Options: Options{"(validate.rules).string.min_len": NewLiteralValue("1")}}}}},
+		},
+		"invalid option value": {
+			Name:    "foo",
+			Options: Options{"java_multiple_files": NewStringValue("true")},
+		},
+		"unknown enum option value": {
+			Name:    "foo",
+			Options: Options{"optimize_for": NewLiteralValue("FAST")},
+		},
+		"enum as rpc input": {
+			Name:     "foo",
+			Enums:    []*Enum{{Name: "Bar", Values: []*EnumValue{{Name: "BAR", Value: 0}}}},
+			Services: []*Service{{Name: "Foo", RPCs: []*RPC{{Name: "Do", Input: NewNamed("foo", "Bar"), Output: NewNamed("foo", "Bar")}}}},
+		},
+		"import not found": {
+			Name:    "foo",
+			Imports: []string{"validate/validate.proto"},
+		},
+	}
+
+	for name, pkg := range cases {
+		_, err := NewDescriptorSet().Add("foo.proto", pkg)
+		require.NotNil(t, err, name)
+	}
+}
+
+func TestDescriptorSetAddFile(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-descriptors")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"foo/generated.proto": `syntax = "proto3";
+package foo;
+
+import "bar/generated.proto";
+import "google/protobuf/duration.proto";
+
+message Foo {
+	bar.Bar bar = 1;
+	google.protobuf.Duration timeout = 2;
+}
+`,
+		"bar/generated.proto": `syntax = "proto3";
+package bar;
+
+enum Bar {
+	BAR = 0;
+}
+`,
+	}
+
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		require.Nil(os.MkdirAll(filepath.Dir(file), 0755))
+		require.Nil(ioutil.WriteFile(file, []byte(content), 0644))
+	}
+
+	set := NewDescriptorSet(filepath.Join(dir, "missing"), dir)
+	name, err := set.AddFile(filepath.Join(dir, "foo", "generated.proto"))
+	require.Nil(err)
+	require.Equal("foo/generated.proto", name)
+
+	all := set.Files()
+	require.Len(all, 3)
+	require.Equal("bar/generated.proto", all[0].GetName())
+	require.Equal("google/protobuf/duration.proto", all[1].GetName())
+
+	fd := all[2]
+	require.Equal(".bar.Bar", fd.MessageType[0].Field[0].GetTypeName())
+	require.Equal(descriptor.FieldDescriptorProto_TYPE_ENUM, fd.MessageType[0].Field[0].GetType())
+	require.Equal(".google.protobuf.Duration", fd.MessageType[0].Field[1].GetTypeName())
+
+	require.Nil(ioutil.WriteFile(filepath.Join(dir, "cycle.proto"), []byte(`syntax = "proto3";
+package cycle;
+
+import "cycle.proto";
+`), 0644))
+	_, err = set.AddFile(filepath.Join(dir, "cycle.proto"))
+	require.NotNil(err)
+
+	_, err = set.AddFile(filepath.Join(os.TempDir(), "outside.proto"))
+	require.NotNil(err)
+}
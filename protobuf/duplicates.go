@@ -0,0 +1,75 @@
+package protobuf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FindDuplicateMessages returns the groups of messages of different packages
+// that have the same fields, with the full names of the messages, like
+// "foo.bar.User". They are usually structs copied from one package to
+// another, which could be replaced by a single struct shared by all of them.
+// Fields are compared by name, type and whether they are repeated, in any
+// order, and messages without fields or generated for RPCs are never
+// duplicates.
+func FindDuplicateMessages(pkgs []*Package) [][]string {
+	var (
+		groups = make(map[string][]string)
+		paths  = make(map[string]map[string]bool)
+		keys   []string
+	)
+
+	for _, pkg := range pkgs {
+		generated := pkg.generatedMessages()
+		for _, msg := range pkg.Messages {
+			if len(msg.Fields) == 0 || generated[msg.Name] {
+				continue
+			}
+
+			key := messageShape(msg)
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+				paths[key] = make(map[string]bool)
+			}
+
+			groups[key] = append(groups[key], pkg.Name+"."+msg.Name)
+			paths[key][pkg.Path] = true
+		}
+	}
+
+	var duplicates [][]string
+	for _, key := range keys {
+		if len(paths[key]) > 1 {
+			duplicates = append(duplicates, groups[key])
+		}
+	}
+	return duplicates
+}
+
+// generatedMessages returns the names of the messages generated for the
+// requests and responses of the RPCs of the package.
+func (p *Package) generatedMessages() map[string]bool {
+	var names = make(map[string]bool)
+	for _, s := range p.Services {
+		for _, rpc := range s.RPCs {
+			for _, typ := range []Type{rpc.Input, rpc.Output} {
+				if n, ok := typ.(*Named); ok && n.Generated {
+					names[n.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// messageShape returns a representation of the fields of the message that
+// is the same for all the messages with the same fields.
+func messageShape(msg *Message) string {
+	var fields = make([]string, len(msg.Fields))
+	for i, f := range msg.Fields {
+		fields[i] = fmt.Sprintf("%s %s %t", f.Name, f.Type, f.Repeated)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, "; ")
+}
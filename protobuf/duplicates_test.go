@@ -0,0 +1,70 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDuplicateMessages(t *testing.T) {
+	require := require.New(t)
+
+	user := func(name string) *Message {
+		return &Message{
+			Name: name,
+			Fields: []*Field{
+				{Name: "id", Pos: 1, Type: NewBasic("int64")},
+				{Name: "tags", Pos: 2, Type: NewBasic("string"), Repeated: true},
+			},
+		}
+	}
+
+	reordered := &Message{
+		Name: "Member",
+		Fields: []*Field{
+			{Name: "tags", Pos: 1, Type: NewBasic("string"), Repeated: true},
+			{Name: "id", Pos: 2, Type: NewBasic("int64")},
+		},
+	}
+
+	different := &Message{
+		Name: "User",
+		Fields: []*Field{
+			{Name: "id", Pos: 1, Type: NewBasic("int64")},
+			{Name: "tags", Pos: 2, Type: NewBasic("string")},
+		},
+	}
+
+	pkgs := []*Package{
+		{
+			Name:     "foo",
+			Path:     "foo",
+			Messages: []*Message{user("User"), user("Account"), {Name: "Empty"}},
+		},
+		{
+			Name:     "bar",
+			Path:     "bar",
+			Messages: []*Message{reordered, {Name: "Empty"}},
+		},
+		{
+			Name:     "baz",
+			Path:     "baz",
+			Messages: []*Message{different, user("Baz_DoRequest")},
+			Services: []*Service{
+				{
+					Name: "BazService",
+					RPCs: []*RPC{
+						{
+							Name:   "Baz_Do",
+							Input:  NewGeneratedNamed("baz", "Baz_DoRequest"),
+							Output: NewNamed("baz", "User"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	require.Equal([][]string{{"foo.User", "foo.Account", "bar.Member"}}, FindDuplicateMessages(pkgs))
+	require.Nil(FindDuplicateMessages(pkgs[:1]), "messages of the same package are not duplicates")
+}
@@ -0,0 +1,85 @@
+package protobuf
+
+import "sort"
+
+// Feature is a protobuf feature used by the messages or services of a
+// package, which the generators may need imports or helper code for.
+type Feature string
+
+const (
+	// AnyFeature is used by the packages with google.protobuf.Any fields.
+	AnyFeature Feature = "any"
+	// WellKnownTypesFeature is used by the packages with fields, requests or
+	// responses of the well-known types, like google.protobuf.Timestamp.
+	WellKnownTypesFeature Feature = "wkt"
+	// ValidationFeature is used by the packages with fields with
+	// protoc-gen-validate rules.
+	ValidationFeature Feature = "validation"
+)
+
+// wellKnownPackage is the protobuf package of the well-known types.
+const wellKnownPackage = "google.protobuf"
+
+// Features is the set of features used by a package.
+type Features map[Feature]bool
+
+// Has reports whether the given feature is in the set.
+func (f Features) Has(feature Feature) bool {
+	return f[feature]
+}
+
+// List returns the features in the set, sorted.
+func (f Features) List() []Feature {
+	var list []Feature
+	for feat, ok := range f {
+		if ok {
+			list = append(list, feat)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i] < list[j]
+	})
+	return list
+}
+
+// DetectFeatures sets the features of the package to the ones used by its
+// messages and services. Transform, MergePackages, SplitByFile and Parse
+// already call it, so it only needs to be called again after the package is
+// modified.
+func (p *Package) DetectFeatures() {
+	v := &featureDetector{features: make(Features)}
+	Walk(p, v)
+	p.Features = v.features
+}
+
+// featureDetector collects the features used by the walked elements.
+type featureDetector struct {
+	BaseVisitor
+	features Features
+}
+
+func (d *featureDetector) VisitField(msg *Message, f *Field) bool {
+	for name := range f.Options {
+		if optionPackage(name) == validatePackage {
+			d.features[ValidationFeature] = true
+		}
+	}
+	return true
+}
+
+func (d *featureDetector) VisitNamed(n *Named) {
+	if n.Package != wellKnownPackage {
+		return
+	}
+
+	d.features[WellKnownTypesFeature] = true
+	if n.Name == "Any" {
+		d.features[AnyFeature] = true
+	}
+}
+
+func (d *featureDetector) VisitAlias(a *Alias) bool {
+	// Only the underlying type of an alias is written to the file.
+	WalkType(a.Underlying, d)
+	return false
+}
@@ -0,0 +1,42 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFeatures(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &Package{
+		Name: "foo",
+		Messages: []*Message{
+			{
+				Name: "User",
+				Fields: []*Field{
+					{Name: "id", Type: NewBasic("string")},
+					{Name: "created", Type: NewAlias(NewNamed("google.protobuf", "Any"), NewBasic("int64"))},
+				},
+			},
+		},
+		Services: []*Service{
+			{Name: "FooService", RPCs: []*RPC{{Name: "Ping", Input: NewNamed("google.protobuf", "Empty"), Output: NewNamed("", "User")}}},
+		},
+	}
+	pkg.DetectFeatures()
+	require.Equal([]Feature{WellKnownTypesFeature}, pkg.Features.List(), "only the underlying types of aliases are used")
+
+	pkg.Messages[0].Fields = append(pkg.Messages[0].Fields,
+		&Field{Name: "details", Type: NewMap(NewBasic("string"), NewNamed("google.protobuf", "Any"))},
+		&Field{Name: "age", Type: NewBasic("int32"), Options: Options{"(validate.rules).int32.gt": NewLiteralValue("0")}},
+	)
+	pkg.DetectFeatures()
+	require.Equal([]Feature{AnyFeature, ValidationFeature, WellKnownTypesFeature}, pkg.Features.List())
+	require.True(pkg.Features.Has(AnyFeature))
+
+	pkg.Services = nil
+	pkg.Messages = pkg.Messages[:0]
+	pkg.DetectFeatures()
+	require.Empty(pkg.Features.List())
+}
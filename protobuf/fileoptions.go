@@ -0,0 +1,114 @@
+package protobuf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileOptions are the options written to the .proto files of Go packages,
+// such as java_package or csharp_namespace, indexed by the path of the Go
+// package. The options with an empty path are written to all the files, but
+// the ones of a package override them. String values can have the same
+// placeholders as a FileLayout, which are replaced with the values of each
+// package, e.g. com.acme.{name} is com.acme.users for github.com/acme/users,
+// but {package} is the protobuf package as is.
+type FileOptions map[string]Options
+
+// stringFileOptions are the standard file options whose values are strings,
+// which do not need to be quoted.
+var stringFileOptions = []string{
+	"go_package",
+	"java_package",
+	"java_outer_classname",
+	"csharp_namespace",
+	"objc_class_prefix",
+	"php_class_prefix",
+	"php_namespace",
+	"php_metadata_namespace",
+	"ruby_package",
+	"swift_prefix",
+}
+
+var (
+	fileOptionNameExpr = regexp.MustCompile(`^([a-z_][a-z0-9_]*|\(gogoproto\.[a-z_][a-z0-9_]*\))$`)
+	literalValueExpr   = regexp.MustCompile(`^(true|false|[A-Z][A-Z0-9_]*)$`)
+)
+
+// ParseFileOptions creates FileOptions from a list of "[GOPKG:]NAME=VALUE"
+// options, which are given to all the packages if there is no Go package.
+// Values of the standard options whose type is string are always strings.
+// Other values are strings if they are quoted and literals if they are true,
+// false, numbers or enum values in upper case, like SPEED.
+func ParseFileOptions(list []string) (FileOptions, error) {
+	opts := make(FileOptions)
+	for _, o := range list {
+		idx := strings.Index(o, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid file option %q, expected [GOPKG:]NAME=VALUE", o)
+		}
+
+		var path, name = "", o[:idx]
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			path, name = name[:i], name[i+1:]
+		}
+
+		if !fileOptionNameExpr.MatchString(name) {
+			return nil, fmt.Errorf("invalid file option %q, the name must be a standard option or a gogoproto one", o)
+		}
+
+		value := fileOptionValue(name, o[idx+1:])
+		if value == nil {
+			return nil, fmt.Errorf("invalid file option %q, the value can not be empty", o)
+		}
+
+		if opts[path] == nil {
+			opts[path] = make(Options)
+		}
+		opts[path][name] = value
+	}
+	return opts, nil
+}
+
+// fileOptionValue returns the value of the option with the given name, or nil
+// if it is empty.
+func fileOptionValue(name, value string) OptionValue {
+	if value == "" {
+		return nil
+	}
+
+	if containsString(stringFileOptions, name) {
+		return NewStringValue(value)
+	}
+
+	if strings.HasPrefix(value, `"`) {
+		if s, err := strconv.Unquote(value); err == nil {
+			return NewStringValue(s)
+		}
+	}
+
+	if _, err := strconv.ParseFloat(value, 64); err == nil || literalValueExpr.MatchString(value) {
+		return NewLiteralValue(value)
+	}
+	return NewStringValue(value)
+}
+
+// Package returns the options of the file of the Go package at the given
+// path, whose protobuf package is the given one, with the placeholders of
+// their values replaced.
+func (o FileOptions) Package(path, pkg string) Options {
+	values := pathPlaceholders(path)
+	values["{package}"] = pkg
+
+	result := make(Options)
+	for _, p := range []string{"", path} {
+		for name, v := range o[p] {
+			if s, ok := v.(StringValue); ok {
+				v = NewStringValue(replacePlaceholders(s.val, values))
+			}
+			result[name] = v
+		}
+	}
+	return result
+}
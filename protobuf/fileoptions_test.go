@@ -0,0 +1,84 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestParseFileOptions(t *testing.T) {
+	require := require.New(t)
+
+	opts, err := ParseFileOptions([]string{
+		"java_package=com.acme.{name}",
+		"java_multiple_files=true",
+		"optimize_for=SPEED",
+		"github.com/acme/users:csharp_namespace=Acme.Users",
+		"github.com/acme/users:java_multiple_files=false",
+		"github.com/acme/users:(gogoproto.goproto_getters_all)=false",
+		"github.com/acme/users:go_package=github.com/acme/users;users",
+	})
+	require.NoError(err)
+	require.Equal(FileOptions{
+		"": {
+			"java_package":        NewStringValue("com.acme.{name}"),
+			"java_multiple_files": NewLiteralValue("true"),
+			"optimize_for":        NewLiteralValue("SPEED"),
+		},
+		"github.com/acme/users": {
+			"csharp_namespace":                NewStringValue("Acme.Users"),
+			"java_multiple_files":             NewLiteralValue("false"),
+			"(gogoproto.goproto_getters_all)": NewLiteralValue("false"),
+			"go_package":                      NewStringValue("github.com/acme/users;users"),
+		},
+	}, opts)
+
+	require.Equal(NewStringValue("true"), fileOptionValue("deprecated", `"true"`))
+	require.Equal(NewLiteralValue("1.5"), fileOptionValue("(gogoproto.foo)", "1.5"))
+	require.Equal(NewStringValue("foo"), fileOptionValue("(gogoproto.foo)", "foo"))
+
+	for _, o := range []string{"java_package", "=foo", "java_package=", "Java-Package=foo", "(foo.bar)=true"} {
+		_, err := ParseFileOptions([]string{o})
+		require.Error(err, o)
+	}
+}
+
+func TestFileOptionsPackage(t *testing.T) {
+	require := require.New(t)
+
+	opts, err := ParseFileOptions([]string{
+		"java_package=com.{org}.{name}",
+		"objc_class_prefix={package}",
+		"java_multiple_files=true",
+		"github.com/acme/users:java_multiple_files=false",
+	})
+	require.NoError(err)
+
+	require.Equal(Options{
+		"java_package":        NewStringValue("com.acme.users"),
+		"objc_class_prefix":   NewStringValue("acme.users.v1"),
+		"java_multiple_files": NewLiteralValue("false"),
+	}, opts.Package("github.com/acme/users", "acme.users.v1"))
+
+	require.Equal(NewLiteralValue("true"), opts.Package("github.com/acme/groups", "acme.groups")["java_multiple_files"])
+	require.Equal(Options{}, FileOptions(nil).Package("github.com/acme/users", "acme.users"))
+}
+
+func TestTransformFileOptions(t *testing.T) {
+	require := require.New(t)
+
+	opts, err := ParseFileOptions([]string{
+		"java_package=com.acme.{name}",
+		"gitlab.com/foo/bar:go_package=gitlab.com/foo/bar;bar",
+	})
+	require.NoError(err)
+
+	tr := NewTransformer()
+	tr.SetFileOptions(opts)
+	pkg := tr.Transform(&scanner.Package{Path: "gitlab.com/foo/bar", Name: "bar"})
+
+	require.Equal(NewStringValue("com.acme.bar"), pkg.Options["java_package"])
+	require.Equal(NewStringValue("gitlab.com/foo/bar;bar"), pkg.Options["go_package"])
+	require.Equal(NewLiteralValue("true"), pkg.Options["(gogoproto.protosizer_all)"])
+}
@@ -3,10 +3,10 @@ package protobuf
 import (
 	"bytes"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 
+	"gitlab.com/ThatTomPerson/proteus/output"
 	"gitlab.com/ThatTomPerson/proteus/report"
 )
 
@@ -14,17 +14,30 @@ import (
 // to disk in a file at the given path.
 type Generator struct {
 	basePath string
+	layout   FileLayout
 }
 
 // NewGenerator creates a new Generator with the given base path.
 func NewGenerator(basePath string) *Generator {
-	return &Generator{basePath}
+	return &Generator{basePath: basePath}
+}
+
+// SetFileLayout sets the layout of the generated files in the base path. By
+// default, they are written in a generated.proto in the folder of their Go
+// package.
+func (g *Generator) SetFileLayout(layout FileLayout) {
+	g.layout = layout
 }
 
 // Generate generates the proto3 .proto file of the given package and
-// writes it to disk.
+// writes it to disk. The package is sorted first, so the file is the same on
+// every run.
 func (g *Generator) Generate(pkg *Package) error {
+	pkg.PruneImports()
+	pkg.Sort()
+
 	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", GeneratedBy(pkg.Path)))
 	buf.WriteString(`syntax = "proto3";` + "\n")
 
 	writePackageData(&buf, pkg)
@@ -43,26 +56,45 @@ func (g *Generator) Generate(pkg *Package) error {
 		buf.WriteRune('\n')
 	}
 
-	if len(pkg.RPCs) > 0 {
-		writeService(&buf, pkg)
+	for _, svc := range pkg.Services {
+		if len(svc.RPCs) > 0 {
+			writeService(&buf, svc)
+		}
 	}
 
-	return g.writeFile(pkg.Path, buf.Bytes())
+	return g.writeFile(pkg, buf.Bytes())
+}
+
+// GeneratedBy returns the text of the comment that marks all the files
+// generated from the Go package at the given path, in the form expected by
+// the Go tools to recognize generated code. Every generator writes it at the
+// top of its files.
+func GeneratedBy(path string) string {
+	return fmt.Sprintf("Code generated by proteus from %s. DO NOT EDIT.", path)
 }
 
-func (g *Generator) writeFile(path string, data []byte) error {
-	path = filepath.Join(g.basePath, path)
+// FileName returns the path of the .proto file generated for the given
+// package.
+func (g *Generator) FileName(pkg *Package) string {
+	file := g.layout.File(pkg.Path, pkg.Name)
+	if pkg.file != "" {
+		file = filepath.Join(filepath.Dir(file), pkg.file)
+	}
+	return filepath.Join(g.basePath, file)
+}
+
+func (g *Generator) writeFile(pkg *Package, data []byte) error {
 	fi, err := os.Stat(g.basePath)
 	if err != nil {
 		return err
 	}
 
-	if err := os.MkdirAll(path, fi.Mode()); err != nil {
+	file := g.FileName(pkg)
+	if err := output.MkdirAll(filepath.Dir(file), fi.Mode()); err != nil {
 		return err
 	}
 
-	file := filepath.Join(path, "generated.proto")
-	if err := ioutil.WriteFile(file, data, fi.Mode()); err != nil {
+	if err := output.WriteFile(file, data, fi.Mode()); err != nil {
 		return err
 	}
 
@@ -89,24 +121,15 @@ func writeMessage(buf *bytes.Buffer, msg *Message) {
 	buf.WriteString(fmt.Sprintf("message %s {\n", msg.Name))
 	writeOptions(buf, msg.Options, true)
 
-	if len(msg.Reserved) > 0 {
-		buf.WriteString("\treserved ")
-
-		for i, p := range msg.Reserved {
-			if i > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(fmt.Sprint(p))
-		}
-
-		buf.WriteString(";\n")
-	}
+	writeReserved(buf, msg.Reserved, msg.ReservedNames)
 
 	for _, f := range msg.Fields {
 		writeDocs(buf, f.Docs, true)
 		buf.WriteRune('\t')
 		if f.Repeated {
 			buf.WriteString("repeated ")
+		} else if f.Optional {
+			buf.WriteString("optional ")
 		}
 
 		buf.WriteString(f.Type.String())
@@ -126,6 +149,7 @@ func writeEnum(buf *bytes.Buffer, enum *Enum) {
 	writeDocs(buf, enum.Docs, false)
 	buf.WriteString(fmt.Sprintf("enum %s {\n", enum.Name))
 	writeOptions(buf, enum.Options, true)
+	writeReserved(buf, enum.Reserved, enum.ReservedNames)
 
 	for _, v := range enum.Values {
 		writeDocs(buf, v.Docs, true)
@@ -140,6 +164,36 @@ func writeEnum(buf *bytes.Buffer, enum *Enum) {
 	buf.WriteString("}\n")
 }
 
+// writeReserved writes the reserved numbers and names of a message or enum.
+// As they can not be mixed, names are written in their own statement.
+func writeReserved(buf *bytes.Buffer, nums []uint, names []string) {
+	if len(nums) > 0 {
+		buf.WriteString("\treserved ")
+
+		for i, n := range nums {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(fmt.Sprint(n))
+		}
+
+		buf.WriteString(";\n")
+	}
+
+	if len(names) > 0 {
+		buf.WriteString("\treserved ")
+
+		for i, n := range names {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(fmt.Sprintf("%q", n))
+		}
+
+		buf.WriteString(";\n")
+	}
+}
+
 func writeOptions(buf *bytes.Buffer, options Options, indent bool) {
 	for _, opt := range options.Sorted() {
 		if indent {
@@ -171,16 +225,33 @@ func writeDocs(buf *bytes.Buffer, docs []string, indent bool) {
 	}
 }
 
-func writeService(buf *bytes.Buffer, pkg *Package) {
-	buf.WriteString(fmt.Sprintf("service %s {\n", pkg.ServiceName()))
-	for _, rpc := range pkg.RPCs {
+func writeService(buf *bytes.Buffer, svc *Service) {
+	writeDocs(buf, svc.Docs, false)
+	buf.WriteString(fmt.Sprintf("service %s {\n", svc.Name))
+	for _, rpc := range svc.RPCs {
 		writeDocs(buf, rpc.Docs, true)
+		var stream string
+		if rpc.IsStreaming() {
+			stream = "stream "
+		}
+
 		buf.WriteString(fmt.Sprintf(
-			"\trpc %s (%s) returns (%s);\n",
+			"\trpc %s (%s) returns (%s%s)",
 			rpc.Name,
 			rpc.Input,
+			stream,
 			rpc.Output,
 		))
+
+		if len(rpc.Options) > 0 {
+			buf.WriteString(" {\n")
+			for _, opt := range rpc.Options.Sorted() {
+				buf.WriteString(fmt.Sprintf("\t\toption %s = %s;\n", opt.Name, opt.Value))
+			}
+			buf.WriteString("\t}\n")
+		} else {
+			buf.WriteString(";\n")
+		}
 	}
 	buf.WriteString("}\n\n")
 }
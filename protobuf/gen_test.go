@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
 )
 
 func TestGenerator(t *testing.T) {
@@ -60,6 +62,8 @@ func (s *GenSuite) TestWriteOptions() {
 const expectedEnum = `// Possible pony races
 enum PonyRace {
 	option is_cute = true;
+	reserved 2;
+	reserved "BLUE_THUNDER";
 	// Pink cutie
 	PINK_CUTIE = 0;
 	RED_FURY = 1 [bar = "baz", foo = true];
@@ -72,6 +76,8 @@ var mockEnum = &Enum{
 	Options: Options{
 		"is_cute": NewLiteralValue("true"),
 	},
+	Reserved:      []uint{2},
+	ReservedNames: []string{"BLUE_THUNDER"},
 	Values: []*EnumValue{
 		{
 			Docs:  []string{"Pink cutie"},
@@ -99,6 +105,7 @@ const expectedMsg = `// Pony is so fancy
 message Pony {
 	option is_cute = true;
 	reserved 5, 6;
+	reserved "weight", "height";
 	// Name of the pony
 	string name = 1 [bar = "baz", foo = true];
 	// Time the pony was born
@@ -107,6 +114,8 @@ message Pony {
 	foo.bar.PonyRace race = 3;
 	// All the fancy nicknames the pony has
 	repeated string nick_names = 4;
+	// Age of the pony, if known
+	optional int32 age = 7;
 }
 `
 
@@ -119,7 +128,8 @@ var mockMsg = &Message{
 	Options: Options{
 		"is_cute": NewLiteralValue("true"),
 	},
-	Reserved: []uint{5, 6},
+	Reserved:      []uint{5, 6},
+	ReservedNames: []string{"weight", "height"},
 	Fields: []*Field{
 		{
 			Docs: []string{
@@ -152,6 +162,13 @@ var mockMsg = &Message{
 			Type:     NewBasic("string"),
 			Pos:      4,
 		},
+		{
+			Docs:     []string{"Age of the pony, if known"},
+			Name:     "age",
+			Optional: true,
+			Type:     NewBasic("int32"),
+			Pos:      7,
+		},
 	},
 }
 
@@ -185,14 +202,84 @@ const expectedService = `service BarService {
 `
 
 func (s *GenSuite) TestWriteService() {
-	writeService(s.buf, &Package{
-		Name: "foo.bar",
+	writeService(s.buf, &Service{
+		Name: "BarService",
 		RPCs: mockRpcs,
 	})
 	s.Equal(expectedService, s.buf.String())
 }
 
-var expectedProto = fmt.Sprintf(`syntax = "proto3";
+const expectedServiceWithOptions = `service UserService {
+	rpc GetUser (foo.bar.GetUserRequest) returns (foo.bar.User) {
+		option (google.api.http) = { get: "/v1/users/{id}" };
+		option (google.api.method_signature) = "id";
+		option (google.api.method_signature) = "id,name";
+	}
+}
+
+`
+
+const expectedStreamingService = `service EventService {
+	rpc Watch (foo.bar.WatchRequest) returns (stream foo.bar.Event);
+}
+
+`
+
+func (s *GenSuite) TestWriteStreamingService() {
+	writeService(s.buf, &Service{
+		Name: "EventService",
+		RPCs: []*RPC{
+			{
+				Name:   "Watch",
+				Input:  NewNamed("foo.bar", "WatchRequest"),
+				Output: NewNamed("foo.bar", "Event"),
+				Stream: scanner.SeqStream,
+			},
+		},
+	})
+	s.Equal(expectedStreamingService, s.buf.String())
+
+	pkg, err := Parse(strings.NewReader(s.buf.String()))
+	s.Nil(err)
+	s.True(pkg.Services[0].RPCs[0].IsStreaming(), "the streams of parsed RPCs are kept")
+	s.Equal("foo.bar.Event", pkg.Services[0].RPCs[0].Output.String())
+}
+
+func (s *GenSuite) TestWriteServiceWithOptions() {
+	writeService(s.buf, &Service{
+		Name: "UserService",
+		RPCs: []*RPC{
+			{
+				Name:   "GetUser",
+				Input:  NewNamed("foo.bar", "GetUserRequest"),
+				Output: NewNamed("foo.bar", "User"),
+				Options: Options{
+					"(google.api.http)": NewAggregateValue().With("get", NewStringValue("/v1/users/{id}")),
+					"(google.api.method_signature)": NewRepeatedValue(
+						NewStringValue("id"),
+						NewStringValue("id,name"),
+					),
+				},
+			},
+		},
+	})
+	s.Equal(expectedServiceWithOptions, s.buf.String())
+}
+
+// expectedSortedService is expectedService with its RPCs sorted by name, as
+// they are in generated files.
+const expectedSortedService = `service BarService {
+	// DoBar does a lot of Bar
+	rpc DoBar (foo.bar.DoBarRequest) returns (foo.bar.DoBarResponse);
+	// DoFoo does a lot of Foo
+	rpc DoFoo (foo.bar.DoFooRequest) returns (foo.bar.DoFooResponse);
+}
+
+`
+
+var expectedProto = fmt.Sprintf(`// Code generated by proteus from foo/bar. DO NOT EDIT.
+
+syntax = "proto3";
 package foo.bar;
 
 import "google/protobuf/timestamp.proto";
@@ -201,20 +288,21 @@ option foo = true;
 
 %s
 %s
-%s`, expectedMsg, expectedEnum, expectedService)
+%s`, expectedMsg, expectedEnum, expectedSortedService)
 
 func (s *GenSuite) TestGenerate() {
 	err := s.g.Generate(&Package{
 		Name:     "foo.bar",
+		Path:     "foo/bar",
 		Imports:  []string{"google/protobuf/timestamp.proto"},
 		Messages: []*Message{mockMsg},
 		Enums:    []*Enum{mockEnum},
 		Options:  Options{"foo": NewLiteralValue("true")},
-		RPCs:     mockRpcs,
+		Services: []*Service{{Name: "BarService", RPCs: mockRpcs}},
 	})
 	s.Nil(err)
 
-	bytes, err := ioutil.ReadFile(filepath.Join(s.path, "generated.proto"))
+	bytes, err := ioutil.ReadFile(filepath.Join(s.path, "foo", "bar", "generated.proto"))
 	s.Nil(err)
 
 	s.Equal(expectedProto, string(bytes))
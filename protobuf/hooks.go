@@ -0,0 +1,140 @@
+package protobuf
+
+// MessageHook is called with every message of a package once it is
+// transformed, before it is rendered. It can modify the message and it
+// returns whether the message is kept.
+type MessageHook func(pkg *Package, msg *Message) bool
+
+// FieldHook is called with every field of the messages kept by the message
+// hooks of a package, before it is rendered. It can modify the field and it
+// returns whether the field is kept. The numbers of dropped fields are
+// reserved, so they are not reused.
+type FieldHook func(pkg *Package, msg *Message, field *Field) bool
+
+// RPCHook is called with every RPC of the services of a package once it is
+// transformed, before it is rendered. It can modify the RPC and it returns
+// whether the RPC is kept. The request and response messages generated for
+// dropped RPCs are dropped along with them.
+type RPCHook func(pkg *Package, svc *Service, rpc *RPC) bool
+
+// hooks are the hooks registered in a transformer, which run in the order in
+// which they were registered.
+type hooks struct {
+	messages []MessageHook
+	fields   []FieldHook
+	rpcs     []RPCHook
+}
+
+// RegisterMessageHook registers a hook that is called with every message of
+// the transformed packages, after the transformation rules are applied, so
+// projects can add options to the messages, rename them or drop them.
+// Messages that are still used by other messages or RPCs should not be
+// dropped, as the package would not compile.
+func (t *Transformer) RegisterMessageHook(hook MessageHook) {
+	t.hooks.messages = append(t.hooks.messages, hook)
+}
+
+// RegisterFieldHook registers a hook that is called with every field of the
+// messages of the transformed packages, after the message hooks. The
+// generated servers expect the fields of the request and response messages
+// generated for the RPCs to be there.
+func (t *Transformer) RegisterFieldHook(hook FieldHook) {
+	t.hooks.fields = append(t.hooks.fields, hook)
+}
+
+// RegisterRPCHook registers a hook that is called with every RPC of the
+// services of the transformed packages, after the field hooks. Services
+// left without RPCs are not generated.
+func (t *Transformer) RegisterRPCHook(hook RPCHook) {
+	t.hooks.rpcs = append(t.hooks.rpcs, hook)
+}
+
+// run runs the hooks over the messages, fields and RPCs of the package.
+func (h *hooks) run(pkg *Package) {
+	if len(h.messages) == 0 && len(h.fields) == 0 && len(h.rpcs) == 0 {
+		return
+	}
+
+	var msgs []*Message
+	for _, msg := range pkg.Messages {
+		if h.keepMessage(pkg, msg) {
+			msgs = append(msgs, msg)
+		}
+	}
+	pkg.Messages = msgs
+
+	for _, msg := range pkg.Messages {
+		var fields []*Field
+		for _, f := range msg.Fields {
+			if h.keepField(pkg, msg, f) {
+				fields = append(fields, f)
+			} else {
+				msg.Reserve(uint(f.Pos))
+			}
+		}
+		msg.Fields = fields
+	}
+
+	var (
+		services []*Service
+		dropped  = make(map[string]bool)
+	)
+	for _, svc := range pkg.Services {
+		var rpcs []*RPC
+		for _, rpc := range svc.RPCs {
+			if h.keepRPC(pkg, svc, rpc) {
+				rpcs = append(rpcs, rpc)
+				continue
+			}
+
+			for _, typ := range []Type{rpc.Input, rpc.Output} {
+				if n, ok := typ.(*Named); ok && n.Generated {
+					dropped[n.Name] = true
+				}
+			}
+		}
+
+		if len(rpcs) > 0 || len(svc.RPCs) == 0 {
+			services = append(services, svc)
+		}
+		svc.RPCs = rpcs
+	}
+	pkg.Services = services
+
+	if len(dropped) > 0 {
+		var msgs []*Message
+		for _, msg := range pkg.Messages {
+			if !dropped[msg.Name] {
+				msgs = append(msgs, msg)
+			}
+		}
+		pkg.Messages = msgs
+	}
+}
+
+func (h *hooks) keepMessage(pkg *Package, msg *Message) bool {
+	for _, hook := range h.messages {
+		if !hook(pkg, msg) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *hooks) keepField(pkg *Package, msg *Message, f *Field) bool {
+	for _, hook := range h.fields {
+		if !hook(pkg, msg, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *hooks) keepRPC(pkg *Package, svc *Service, rpc *RPC) bool {
+	for _, hook := range h.rpcs {
+		if !hook(pkg, svc, rpc) {
+			return false
+		}
+	}
+	return true
+}
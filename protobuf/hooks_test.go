@@ -0,0 +1,116 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestTransformHooks(t *testing.T) {
+	require := require.New(t)
+
+	user := scanner.NewNamed("gitlab.com/foo/bar", "User")
+	user.SetNullable(true)
+	p := &scanner.Package{
+		Path: "gitlab.com/foo/bar",
+		Name: "bar",
+		Structs: []*scanner.Struct{
+			{
+				Name: "User",
+				Fields: []*scanner.Field{
+					{Name: "ID", Type: scanner.NewBasic("string")},
+					{Name: "Secret", Type: scanner.NewBasic("string")},
+					{Name: "Age", Type: scanner.NewBasic("int")},
+				},
+			},
+			{Name: "Internal"},
+		},
+		Funcs: []*scanner.Func{
+			{
+				Name:   "GetUser",
+				Input:  []scanner.Type{scanner.NewBasic("string")},
+				Output: []scanner.Type{user},
+			},
+			{
+				Name:   "DeleteUser",
+				Input:  []scanner.Type{scanner.NewBasic("string")},
+				Output: []scanner.Type{scanner.NewNamed("", "error")},
+			},
+		},
+	}
+
+	var calls []string
+	tr := NewTransformer()
+	tr.SetStructSet(NewTypeSet())
+	tr.structSet.Add(p.Path, "User")
+	tr.RegisterMessageHook(func(pkg *Package, msg *Message) bool {
+		calls = append(calls, "message "+msg.Name)
+		return msg.Name != "Internal"
+	})
+	tr.RegisterMessageHook(func(pkg *Package, msg *Message) bool {
+		if msg.Name == "User" {
+			msg.Options["deprecated"] = NewLiteralValue("true")
+		}
+		return true
+	})
+	tr.RegisterFieldHook(func(pkg *Package, msg *Message, f *Field) bool {
+		if f.Name == "age" {
+			f.Name = "years"
+		}
+		return f.Name != "secret"
+	})
+	tr.RegisterRPCHook(func(pkg *Package, svc *Service, rpc *RPC) bool {
+		return rpc.Name != "DeleteUser"
+	})
+	pkg := tr.Transform(p)
+
+	require.Equal([]string{
+		"message User",
+		"message Internal",
+		"message GetUserRequest",
+		"message DeleteUserRequest",
+		"message DeleteUserResponse",
+	}, calls, "dropped messages do not go to the next hooks")
+
+	var names []string
+	for _, msg := range pkg.Messages {
+		names = append(names, msg.Name)
+	}
+	require.Equal([]string{"User", "GetUserRequest"}, names, "the messages of dropped RPCs are dropped")
+
+	msg := pkg.Messages[0]
+	require.Equal(NewLiteralValue("true"), msg.Options["deprecated"])
+	require.Len(msg.Fields, 2)
+	require.Equal("id", msg.Fields[0].Name)
+	require.Equal("years", msg.Fields[1].Name)
+	require.Equal(3, msg.Fields[1].Pos)
+	require.Equal([]uint{2}, msg.Reserved, "the numbers of dropped fields are reserved")
+
+	require.Len(pkg.Services, 1)
+	require.Len(pkg.Services[0].RPCs, 1)
+	require.Equal("GetUser", pkg.Services[0].RPCs[0].Name)
+}
+
+func TestTransformHooksPruneImports(t *testing.T) {
+	p := &scanner.Package{
+		Path: "gitlab.com/foo/bar",
+		Name: "bar",
+		Structs: []*scanner.Struct{
+			{
+				Name: "User",
+				Fields: []*scanner.Field{
+					{Name: "ID", Type: scanner.NewBasic("string")},
+					{Name: "CreatedAt", Type: scanner.NewNamed("time", "Time")},
+				},
+			},
+		},
+	}
+
+	tr := NewTransformer()
+	tr.RegisterFieldHook(func(pkg *Package, msg *Message, f *Field) bool {
+		return f.Name != "created_at"
+	})
+	pkg := tr.Transform(p)
+	require.NotContains(t, pkg.Imports, "google/protobuf/timestamp.proto", "the imports of the dropped fields are pruned")
+}
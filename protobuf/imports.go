@@ -0,0 +1,223 @@
+package protobuf
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// caseInsensitiveImports reports whether the imports whose paths only differ
+// in case are the same file, as they are in Windows.
+var caseInsensitiveImports = runtime.GOOS == "windows"
+
+// normalizeImport returns the given import path cleaned and with forward
+// slashes, which protoc expects in every OS.
+func normalizeImport(file string) string {
+	if file == "" {
+		return file
+	}
+	return path.Clean(strings.Replace(file, `\`, "/", -1))
+}
+
+// importKey returns the key the given import is deduplicated by, that is, its
+// normalized path, lower-cased if the imports are case-insensitive.
+func importKey(file string) string {
+	file = normalizeImport(file)
+	if caseInsensitiveImports {
+		return strings.ToLower(file)
+	}
+	return file
+}
+
+// ImportPaths overrides the paths the generated files import other files
+// from. Keys are either the path of a file or, if they end with a slash, the
+// path of a directory, and values are the path to import them from instead.
+// For example, "google/protobuf/" => "external/protobuf/google/protobuf/"
+// would import all the well-known types from the given directory.
+type ImportPaths map[string]string
+
+// ParseImportPaths creates ImportPaths from a list of "FROM=TO" pairs.
+func ParseImportPaths(pairs []string) (ImportPaths, error) {
+	paths := make(ImportPaths)
+	for _, p := range pairs {
+		idx := strings.Index(p, "=")
+		if idx <= 0 || idx == len(p)-1 {
+			return nil, fmt.Errorf("invalid import path %q, expected FROM=TO", p)
+		}
+
+		from, to := p[:idx], p[idx+1:]
+		if strings.HasSuffix(from, "/") != strings.HasSuffix(to, "/") {
+			return nil, fmt.Errorf("invalid import path %q, both paths must be directories or files", p)
+		}
+		paths[from] = to
+	}
+	return paths, nil
+}
+
+// Resolve returns the path the given file should be imported from. Files are
+// matched exactly first and then by the longest of the directories they are
+// in. If there is no match, the file is returned as is.
+func (p ImportPaths) Resolve(file string) string {
+	if to, ok := p[file]; ok {
+		return to
+	}
+
+	var match string
+	for from := range p {
+		if strings.HasSuffix(from, "/") &&
+			strings.HasPrefix(file, from) &&
+			len(from) > len(match) {
+			match = from
+		}
+	}
+
+	if match == "" {
+		return file
+	}
+	return p[match] + strings.TrimPrefix(file, match)
+}
+
+// rewriteImports changes the path of all the imports of the package to the
+// ones given by the import paths.
+func (p *Package) rewriteImports(paths ImportPaths) {
+	if len(paths) == 0 {
+		return
+	}
+
+	for i, file := range p.Imports {
+		p.Imports[i] = normalizeImport(paths.Resolve(file))
+	}
+
+	for typ, file := range p.typeImports {
+		p.typeImports[typ] = normalizeImport(paths.Resolve(file))
+	}
+
+	for pkg, file := range p.pkgImports {
+		p.pkgImports[pkg] = normalizeImport(paths.Resolve(file))
+	}
+}
+
+// PruneImports removes the imports that are not used by any of the types or
+// options of the package, like the ones of the types of the fields or
+// messages dropped after they were imported. Only the imports added with
+// Import and ImportFromPath are considered, the rest of them are always
+// kept. The imports are also normalized to forward slashes and deduplicated,
+// ignoring their case in Windows, keeping the first one.
+func (p *Package) PruneImports() {
+	var (
+		known = make(map[string]bool)
+		used  = make(map[string]bool)
+		seen  = make(map[string]bool)
+	)
+
+	for _, f := range p.typeImports {
+		known[importKey(f)] = true
+	}
+
+	for _, f := range p.pkgImports {
+		known[importKey(f)] = true
+	}
+
+	p.walkTypes(func(n *Named) {
+		if f, ok := p.typeImports[n.String()]; ok {
+			used[importKey(f)] = true
+		} else if f, ok := p.pkgImports[n.Package]; ok {
+			used[importKey(f)] = true
+		}
+	})
+
+	p.walkOptions(func(opts Options) {
+		for name := range opts {
+			if f, ok := p.pkgImports[optionPackage(name)]; ok {
+				used[importKey(f)] = true
+			}
+		}
+	})
+
+	var imports []string
+	for _, i := range p.Imports {
+		key := importKey(i)
+		if seen[key] || (known[key] && !used[key]) {
+			continue
+		}
+
+		seen[key] = true
+		imports = append(imports, normalizeImport(i))
+	}
+	p.Imports = imports
+}
+
+// walkTypes calls fn with all the named types that are referenced in the
+// package.
+func (p *Package) walkTypes(fn func(*Named)) {
+	for _, m := range p.Messages {
+		for _, f := range m.Fields {
+			walkType(f.Type, fn)
+		}
+	}
+
+	for _, s := range p.Services {
+		for _, rpc := range s.RPCs {
+			walkType(rpc.Input, fn)
+			walkType(rpc.Output, fn)
+		}
+	}
+}
+
+func walkType(typ Type, fn func(*Named)) {
+	switch t := typ.(type) {
+	case *Named:
+		fn(t)
+	case *Map:
+		walkType(t.Key, fn)
+		walkType(t.Value, fn)
+	case *Alias:
+		// Only the underlying type of an alias is written to the file.
+		walkType(t.Underlying, fn)
+	}
+}
+
+// walkOptions calls fn with all the sets of options in the package.
+func (p *Package) walkOptions(fn func(Options)) {
+	fn(p.Options)
+
+	for _, m := range p.Messages {
+		fn(m.Options)
+		for _, f := range m.Fields {
+			fn(f.Options)
+		}
+	}
+
+	for _, e := range p.Enums {
+		fn(e.Options)
+		for _, v := range e.Values {
+			fn(v.Options)
+		}
+	}
+
+	for _, s := range p.Services {
+		for _, rpc := range s.RPCs {
+			fn(rpc.Options)
+		}
+	}
+}
+
+// optionPackage returns the package of a custom option, that is,
+// "gogoproto" for "(gogoproto.nullable)". If the option is not a custom
+// option, an empty string is returned.
+func optionPackage(name string) string {
+	if !strings.HasPrefix(name, "(") {
+		return ""
+	}
+
+	name = strings.TrimPrefix(name, "(")
+	if idx := strings.Index(name, ")"); idx >= 0 {
+		name = name[:idx]
+	}
+
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		return name[:idx]
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneImports(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &Package{Path: "foo"}
+	pkg.importPackage("github.com/gogo/protobuf/gogoproto/gogo.proto", "gogoproto")
+	pkg.Import(&ProtoType{
+		Name:    "Timestamp",
+		Package: "google.protobuf",
+		Import:  "google/protobuf/timestamp.proto",
+	})
+	pkg.Import(&ProtoType{
+		Name:    "Duration",
+		Package: "google.protobuf",
+		Import:  "google/protobuf/duration.proto",
+	})
+	pkg.ImportFromPath("bar")
+	pkg.ImportFromPath("baz")
+	pkg.Imports = append(pkg.Imports, "custom.proto")
+
+	pkg.Messages = []*Message{
+		{
+			Name: "Foo",
+			Fields: []*Field{
+				{Name: "a", Type: NewNamed("google.protobuf", "Timestamp")},
+				{Name: "b", Type: NewAlias(NewNamed("bar", "Alias"), NewBasic("string"))},
+				{Name: "c", Type: NewMap(NewBasic("string"), NewNamed("baz", "Qux"))},
+			},
+		},
+	}
+
+	pkg.PruneImports()
+	require.Equal([]string{
+		"google/protobuf/timestamp.proto",
+		"baz/generated.proto",
+		"custom.proto",
+	}, pkg.Imports)
+}
+
+func TestPruneImportsOptions(t *testing.T) {
+	pkg := &Package{Path: "foo"}
+	pkg.importPackage("github.com/gogo/protobuf/gogoproto/gogo.proto", "gogoproto")
+	pkg.Messages = []*Message{
+		{
+			Name: "Foo",
+			Fields: []*Field{
+				{
+					Name:    "a",
+					Type:    NewBasic("string"),
+					Options: Options{"(gogoproto.nullable)": NewLiteralValue("false")},
+				},
+			},
+		},
+	}
+
+	pkg.PruneImports()
+	require.Equal(t, []string{"github.com/gogo/protobuf/gogoproto/gogo.proto"}, pkg.Imports)
+}
+
+func TestPruneImportsNormalizes(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &Package{Path: "foo"}
+	pkg.importPackage(`bar\generated.proto`, "bar")
+	pkg.ImportFromPath("bar")
+	pkg.Imports = append(pkg.Imports, "custom/./a.proto", `custom\a.proto`)
+	require.Equal([]string{"bar/generated.proto", "custom/./a.proto", `custom\a.proto`}, pkg.Imports)
+
+	pkg.Messages = []*Message{
+		{Name: "Foo", Fields: []*Field{{Name: "a", Type: NewNamed("bar", "Bar")}}},
+	}
+	pkg.PruneImports()
+	require.Equal([]string{"bar/generated.proto", "custom/a.proto"}, pkg.Imports)
+}
+
+func TestPruneImportsCaseInsensitive(t *testing.T) {
+	require := require.New(t)
+
+	defer func(v bool) { caseInsensitiveImports = v }(caseInsensitiveImports)
+	caseInsensitiveImports = true
+
+	pkg := &Package{Path: "foo"}
+	pkg.importPackage("Bar/generated.proto", "bar")
+	pkg.importPackage("bar/generated.proto", "bar")
+	pkg.importPackage("baz/generated.proto", "baz")
+	pkg.Imports = append(pkg.Imports, "BAZ/generated.proto")
+	pkg.Messages = []*Message{
+		{Name: "Foo", Fields: []*Field{{Name: "a", Type: NewNamed("bar", "Bar")}}},
+	}
+
+	pkg.PruneImports()
+	require.Equal([]string{"Bar/generated.proto"}, pkg.Imports)
+}
+
+func TestOptionPackage(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"deprecated", ""},
+		{"(gogoproto.nullable)", "gogoproto"},
+		{"(foo.bar.baz).qux", "foo.bar"},
+		{"(baz)", ""},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expected, optionPackage(c.name), c.name)
+	}
+}
+
+func TestParseImportPaths(t *testing.T) {
+	require := require.New(t)
+
+	paths, err := ParseImportPaths([]string{
+		"google/protobuf/=external/protobuf/google/protobuf/",
+		"github.com/gogo/protobuf/gogoproto/gogo.proto=gogoproto/gogo.proto",
+	})
+	require.NoError(err)
+	require.Equal(ImportPaths{
+		"google/protobuf/": "external/protobuf/google/protobuf/",
+		"github.com/gogo/protobuf/gogoproto/gogo.proto": "gogoproto/gogo.proto",
+	}, paths)
+
+	for _, p := range []string{"foo", "=foo", "foo=", "foo/=bar.proto"} {
+		_, err := ParseImportPaths([]string{p})
+		require.Error(err, p)
+	}
+}
+
+func TestImportPathsResolve(t *testing.T) {
+	paths := ImportPaths{
+		"google/protobuf/":                "external/protobuf/",
+		"google/protobuf/any/":            "external/any/",
+		"google/protobuf/timestamp.proto": "timestamp.proto",
+	}
+
+	cases := []struct {
+		file     string
+		expected string
+	}{
+		{"google/protobuf/timestamp.proto", "timestamp.proto"},
+		{"google/protobuf/duration.proto", "external/protobuf/duration.proto"},
+		{"google/protobuf/any/any.proto", "external/any/any.proto"},
+		{"foo/generated.proto", "foo/generated.proto"},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expected, paths.Resolve(c.file), c.file)
+	}
+}
+
+func TestRewriteImports(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &Package{Path: "foo"}
+	pkg.importPackage("github.com/gogo/protobuf/gogoproto/gogo.proto", "gogoproto")
+	pkg.Import(&ProtoType{
+		Name:    "Timestamp",
+		Package: "google.protobuf",
+		Import:  "google/protobuf/timestamp.proto",
+	})
+	pkg.Messages = []*Message{
+		{
+			Name: "Foo",
+			Fields: []*Field{
+				{Name: "a", Type: NewNamed("google.protobuf", "Timestamp")},
+			},
+		},
+	}
+
+	pkg.rewriteImports(ImportPaths{"google/protobuf/": "external/protobuf/"})
+	pkg.PruneImports()
+	require.Equal([]string{"external/protobuf/timestamp.proto"}, pkg.Imports)
+}
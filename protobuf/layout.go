@@ -0,0 +1,311 @@
+package protobuf
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FileLayout is the path of the .proto file generated for each Go package,
+// relative to the base path, where {path} is replaced by the path of the Go
+// package, {name} by its last element, {org} and {repo} by its second and
+// third elements, like in github.com/org/repo, {module} by its first three
+// elements and {package} by the protobuf package with its dots as slashes.
+// Files import the ones of other packages from the same paths.
+type FileLayout string
+
+// DefaultFileLayout writes a generated.proto file in the folder of the Go
+// package.
+const DefaultFileLayout FileLayout = "{path}/generated.proto"
+
+// PackageTemplate is the protobuf package of each Go package, with the same
+// placeholders as FileLayout but {package}, whose elements are converted to
+// valid protobuf names, e.g. {org}.{name}.v1.
+type PackageTemplate string
+
+// PackageNames are the protobuf packages of Go packages, indexed by their
+// path, which override the package template.
+type PackageNames map[string]string
+
+// ParsePackageNames creates PackageNames from a list of "GOPKG=PACKAGE"
+// pairs.
+func ParsePackageNames(pairs []string) (PackageNames, error) {
+	names := make(PackageNames)
+	for _, p := range pairs {
+		idx := strings.Index(p, "=")
+		if idx <= 0 || idx == len(p)-1 {
+			return nil, fmt.Errorf("invalid package name %q, expected GOPKG=PACKAGE", p)
+		}
+
+		name, err := ParsePackageName(p[idx+1:])
+		if err != nil {
+			return nil, err
+		}
+		names[p[:idx]] = name
+	}
+	return names, nil
+}
+
+// Package returns the protobuf package of the Go package at the given path,
+// which is its name if it has one or the one given by the template.
+func (n PackageNames) Package(path string, tpl PackageTemplate) string {
+	if name, ok := n[path]; ok {
+		return name
+	}
+	return tpl.Package(path)
+}
+
+// Disambiguate returns the package names with a distinct protobuf package for
+// the Go packages at the given paths whose package given by the template is
+// the same as the one of another, as their type references would be
+// ambiguous. Their package is prefixed with as many of the elements of their
+// path before their name as needed to tell them apart, e.g. acme.user and
+// corp.user for github.com/acme/user and github.com/corp/user with the
+// {name} template. Packages with a name already, or whose paths can not
+// tell them apart, keep theirs.
+func (n PackageNames) Disambiguate(paths []string, tpl PackageTemplate) PackageNames {
+	result := make(PackageNames, len(n))
+	for path, name := range n {
+		result[path] = name
+	}
+
+	var (
+		groups = make(map[string][]string)
+		taken  = make(map[string]bool)
+		names  []string
+	)
+	for _, p := range paths {
+		name := n.Package(p, tpl)
+		if containsString(groups[name], p) {
+			continue
+		}
+
+		if len(groups[name]) == 0 {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], p)
+		taken[name] = true
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var candidates []string
+		for _, p := range groups[name] {
+			if _, ok := n[p]; !ok && len(groups[name]) > 1 {
+				candidates = append(candidates, p)
+			}
+		}
+		sort.Strings(candidates)
+
+		for p, pkg := range prefixedPackages(candidates, name, taken) {
+			result[p] = pkg
+		}
+	}
+	return result
+}
+
+// prefixedPackages returns the protobuf packages of the Go packages at the
+// given paths, whose package is the given one, prefixed with the fewest
+// elements of their path that make them distinct from the ones of the rest
+// at the same depth and not taken. Packages whose path can not tell them
+// apart are left out.
+func prefixedPackages(paths []string, pkg string, taken map[string]bool) map[string]string {
+	prefixed := func(path string, k int) string {
+		parts := strings.Split(path, "/")
+		if k >= len(parts) {
+			return ""
+		}
+		return toProtobufPkg(strings.Join(parts[len(parts)-1-k:len(parts)-1], "/")) + "." + pkg
+	}
+
+	result := make(map[string]string, len(paths))
+	for _, p := range paths {
+		for k := 1; prefixed(p, k) != ""; k++ {
+			name := prefixed(p, k)
+			if taken[name] {
+				continue
+			}
+
+			var clash bool
+			for _, other := range paths {
+				clash = clash || (other != p && prefixed(other, k) == name)
+			}
+
+			if !clash {
+				result[p] = name
+				taken[name] = true
+				break
+			}
+		}
+	}
+	return result
+}
+
+var placeholderExpr = regexp.MustCompile(`\{(\w+)\}`)
+
+// ParseFileLayout returns the file layout with the given path, which must
+// be relative, contain at least one known placeholder and end in .proto.
+func ParseFileLayout(layout string) (FileLayout, error) {
+	if filepath.IsAbs(layout) || !strings.HasSuffix(layout, ".proto") {
+		return "", fmt.Errorf("invalid file layout %q, it must be a relative path to a .proto file", layout)
+	}
+
+	if err := checkPlaceholders(layout, "{package}"); err != nil {
+		return "", fmt.Errorf("invalid file layout %q, %s", layout, err)
+	}
+	return FileLayout(layout), nil
+}
+
+// ParsePackageTemplate returns the package template with the given value,
+// which must contain at least one known placeholder and can only have
+// letters, digits, underscores and dots besides them.
+func ParsePackageTemplate(tpl string) (PackageTemplate, error) {
+	if err := checkPlaceholders(tpl); err != nil {
+		return "", fmt.Errorf("invalid package template %q, %s", tpl, err)
+	}
+
+	rest := placeholderExpr.ReplaceAllString(tpl, "")
+	if strings.IndexFunc(rest, func(r rune) bool {
+		return r != '.' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) >= 0 {
+		return "", fmt.Errorf("invalid package template %q, it can only have letters, digits, underscores and dots besides the placeholders", tpl)
+	}
+	return PackageTemplate(tpl), nil
+}
+
+// checkPlaceholders returns an error if the given value has no placeholders
+// or any that is not one of the path placeholders or the given extra ones.
+func checkPlaceholders(value string, extra ...string) error {
+	known := append([]string{"{path}", "{name}", "{org}", "{repo}", "{module}"}, extra...)
+	matches := placeholderExpr.FindAllString(value, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("it must contain any of %s", strings.Join(known, ", "))
+	}
+
+	for _, m := range matches {
+		if !containsString(known, m) {
+			return fmt.Errorf("unknown placeholder %s, valid placeholders are %s", m, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// File returns the path of the .proto file of the Go package at the given
+// path, whose protobuf package is the given one.
+func (l FileLayout) File(path, pkg string) string {
+	if l == "" {
+		l = DefaultFileLayout
+	}
+
+	values := pathPlaceholders(path)
+	values["{package}"] = strings.Replace(pkg, ".", "/", -1)
+	return filepath.Clean(replacePlaceholders(string(l), values))
+}
+
+// GoPath returns the path of the Go package whose .proto file is the given
+// one. It can only be found if the layout contains {path}.
+func (l FileLayout) GoPath(file string) (string, bool) {
+	if l == "" {
+		l = DefaultFileLayout
+	}
+
+	layout := filepath.Clean(string(l))
+	if !strings.Contains(layout, "{path}") {
+		return "", false
+	}
+
+	var (
+		groups []string
+		expr   bytes.Buffer
+		last   int
+	)
+	for _, m := range placeholderExpr.FindAllStringIndex(layout, -1) {
+		expr.WriteString(regexp.QuoteMeta(layout[last:m[0]]))
+		name := layout[m[0]:m[1]]
+		switch name {
+		case "{name}", "{org}", "{repo}":
+			expr.WriteString(`([^/]*)`)
+		default:
+			expr.WriteString(`(.+)`)
+		}
+		groups = append(groups, name)
+		last = m[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(layout[last:]))
+
+	m := regexp.MustCompile("^" + expr.String() + "$").FindStringSubmatch(file)
+	if m == nil {
+		return "", false
+	}
+
+	var path, pkg string
+	for i := len(groups) - 1; i >= 0; i-- {
+		switch groups[i] {
+		case "{path}":
+			path = m[i+1]
+		case "{package}":
+			pkg = strings.Replace(m[i+1], "/", ".", -1)
+		}
+	}
+
+	if l.File(path, pkg) != file {
+		return "", false
+	}
+	return path, true
+}
+
+// Package returns the protobuf package of the Go package at the given path.
+// If the template is empty, it is the whole path with its slashes as dots.
+func (t PackageTemplate) Package(path string) string {
+	if t == "" {
+		return toProtobufPkg(path)
+	}
+
+	values := pathPlaceholders(path)
+	for k, v := range values {
+		values[k] = toProtobufPkg(v)
+	}
+
+	var parts []string
+	for _, p := range strings.Split(replacePlaceholders(string(t), values), ".") {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// pathPlaceholders returns the values of the placeholders of the Go package
+// at the given path. Elements the path does not have are empty.
+func pathPlaceholders(path string) map[string]string {
+	parts := strings.Split(path, "/")
+	elem := func(i int) string {
+		if i < len(parts) {
+			return parts[i]
+		}
+		return ""
+	}
+
+	module := parts
+	if len(module) > 3 {
+		module = module[:3]
+	}
+
+	return map[string]string{
+		"{path}":   path,
+		"{name}":   parts[len(parts)-1],
+		"{org}":    elem(1),
+		"{repo}":   elem(2),
+		"{module}": strings.Join(module, "/"),
+	}
+}
+
+func replacePlaceholders(value string, values map[string]string) string {
+	return placeholderExpr.ReplaceAllStringFunc(value, func(p string) string {
+		return values[p]
+	})
+}
@@ -0,0 +1,202 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestParseFileLayout(t *testing.T) {
+	require := require.New(t)
+
+	layout, err := ParseFileLayout("{package}/{name}.proto")
+	require.NoError(err)
+	require.Equal(FileLayout("{package}/{name}.proto"), layout)
+
+	for _, l := range []string{"generated.proto", "/protos/{path}.proto", "{path}/generated.txt", ""} {
+		_, err := ParseFileLayout(l)
+		require.Error(err, l)
+	}
+}
+
+func TestFileLayoutFile(t *testing.T) {
+	cases := []struct {
+		layout FileLayout
+		file   string
+	}{
+		{"", "gitlab.com/foo/bar/generated.proto"},
+		{"{path}/{name}.proto", "gitlab.com/foo/bar/bar.proto"},
+		{"{package}/{name}.proto", "gitlab/com/foo/bar/bar.proto"},
+		{"protos/{name}.proto", "protos/bar.proto"},
+		{"{module}/{name}/v1/{name}.proto", "gitlab.com/foo/bar/bar/v1/bar.proto"},
+		{"{org}/{repo}/{name}.proto", "foo/bar/bar.proto"},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.file, c.layout.File("gitlab.com/foo/bar", "gitlab.com.foo.bar"), string(c.layout))
+	}
+}
+
+func TestFileLayoutGoPath(t *testing.T) {
+	require := require.New(t)
+
+	path, ok := FileLayout("").GoPath("gitlab.com/foo/bar/generated.proto")
+	require.True(ok)
+	require.Equal("gitlab.com/foo/bar", path)
+
+	path, ok = FileLayout("protos/{path}/{name}.proto").GoPath("protos/gitlab.com/foo/bar/bar.proto")
+	require.True(ok)
+	require.Equal("gitlab.com/foo/bar", path)
+
+	_, ok = FileLayout("protos/{path}/{name}.proto").GoPath("protos/gitlab.com/foo/bar/baz.proto")
+	require.False(ok, "the name does not match the path")
+
+	_, ok = FileLayout("{package}/{name}.proto").GoPath("gitlab/com/foo/bar/bar.proto")
+	require.False(ok, "the path is not in the layout")
+
+	_, ok = FileLayout("").GoPath("google/protobuf/timestamp.proto")
+	require.False(ok)
+}
+
+func TestParsePackageTemplate(t *testing.T) {
+	require := require.New(t)
+
+	tpl, err := ParsePackageTemplate("{org}.{name}.v1")
+	require.NoError(err)
+	require.Equal(PackageTemplate("{org}.{name}.v1"), tpl)
+
+	for _, tpl := range []string{"foo.v1", "{package}.v1", "{name}/v1", "{unknown}", ""} {
+		_, err := ParsePackageTemplate(tpl)
+		require.Error(err, tpl)
+	}
+}
+
+func TestPackageTemplatePackage(t *testing.T) {
+	cases := []struct {
+		tpl  PackageTemplate
+		path string
+		pkg  string
+	}{
+		{"", "gitlab.com/foo/bar", "gitlab.com.foo.bar"},
+		{"{org}.{name}.v1", "gitlab.com/foo/bar-baz", "foo.barbaz.v1"},
+		{"{module}.{name}", "gitlab.com/foo/bar/baz", "gitlab.com.foo.bar.baz"},
+		{"{org}.{repo}.{name}", "foo", "foo"},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.pkg, c.tpl.Package(c.path), string(c.tpl))
+	}
+}
+
+func TestImportFromPathFileLayout(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &Package{Path: "foo", fileLayout: "{path}/{name}.proto"}
+	pkg.ImportFromPath("bar/baz")
+	require.Equal([]string{"bar/baz/baz.proto"}, pkg.Imports)
+
+	pkg = &Package{Path: "foo", fileLayout: "{package}/{name}.proto", pkgTemplate: "acme.{name}.v1"}
+	pkg.ImportFromPath("bar/baz")
+	require.Equal([]string{"acme/baz/v1/baz.proto"}, pkg.Imports)
+	require.Equal("acme/baz/v1/baz.proto", pkg.pkgImports["acme.baz.v1"])
+}
+
+func TestTransformPackageTemplate(t *testing.T) {
+	require := require.New(t)
+
+	tr := NewTransformer()
+	tr.SetPackageTemplate("{org}.{name}.v1")
+	tr.SetFileLayout("{package}/{name}.proto")
+
+	pkg := tr.Transform(&scanner.Package{
+		Path: "gitlab.com/foo/bar",
+		Structs: []*scanner.Struct{
+			{
+				Name:     "Bar",
+				Generate: true,
+				Fields: []*scanner.Field{
+					{Name: "Baz", Type: scanner.NewNamed("gitlab.com/foo/baz", "Baz")},
+				},
+			},
+		},
+	})
+
+	require.Equal("foo.bar.v1", pkg.Name)
+	require.Equal("foo.baz.v1.Baz", pkg.Messages[0].Fields[0].Type.String())
+	require.Contains(pkg.Imports, "foo/baz/v1/baz.proto")
+}
+
+func TestParsePackageNames(t *testing.T) {
+	require := require.New(t)
+
+	names, err := ParsePackageNames([]string{"gitlab.com/foo/bar=acme.bar.v1"})
+	require.NoError(err)
+	require.Equal(PackageNames{"gitlab.com/foo/bar": "acme.bar.v1"}, names)
+	require.Equal("acme.bar.v1", names.Package("gitlab.com/foo/bar", "{org}.{name}"))
+	require.Equal("foo.baz", names.Package("gitlab.com/foo/baz", "{org}.{name}"))
+
+	for _, p := range []string{"gitlab.com/foo/bar", "=acme.bar", "gitlab.com/foo/bar=", "gitlab.com/foo/bar=acme/bar"} {
+		_, err := ParsePackageNames([]string{p})
+		require.Error(err, p)
+	}
+}
+
+func TestPackageNamesDisambiguate(t *testing.T) {
+	require := require.New(t)
+
+	names := PackageNames{"gitlab.com/foo/explicit": "user"}
+	result := names.Disambiguate([]string{
+		"github.com/acme/user",
+		"github.com/corp/user",
+		"gitlab.com/acme/user",
+		"gitlab.com/foo/explicit",
+		"gitlab.com/foo/group",
+		"gitlab.com/foo/group",
+		"user",
+	}, "{name}")
+
+	require.Equal(PackageNames{
+		"gitlab.com/foo/explicit": "user",
+		"github.com/acme/user":    "github.com.acme.user",
+		"github.com/corp/user":    "corp.user",
+		"gitlab.com/acme/user":    "gitlab.com.acme.user",
+	}, result)
+	require.Equal(PackageNames{"gitlab.com/foo/explicit": "user"}, names)
+
+	result = PackageNames{}.Disambiguate([]string{"gitlab.com/foo/users", "gitlab.com/bar/users"}, "{name}.v1")
+	require.Equal(PackageNames{
+		"gitlab.com/foo/users": "foo.users.v1",
+		"gitlab.com/bar/users": "bar.users.v1",
+	}, result)
+	require.Empty(PackageNames{}.Disambiguate([]string{"gitlab.com/foo/bar", "gitlab.com/foo/baz"}, ""))
+}
+
+func TestTransformPackageNames(t *testing.T) {
+	require := require.New(t)
+
+	tr := NewTransformer()
+	tr.SetPackageNames(PackageNames{
+		"gitlab.com/foo/bar": "acme.users.v1",
+		"gitlab.com/foo/baz": "acme.groups.v1",
+	})
+	tr.SetFileLayout("{package}/{name}.proto")
+
+	pkg := tr.Transform(&scanner.Package{
+		Path: "gitlab.com/foo/bar",
+		Structs: []*scanner.Struct{
+			{
+				Name:     "Bar",
+				Generate: true,
+				Fields: []*scanner.Field{
+					{Name: "Baz", Type: scanner.NewNamed("gitlab.com/foo/baz", "Baz")},
+				},
+			},
+		},
+	})
+
+	require.Equal("acme.users.v1", pkg.Name)
+	require.Equal("UsersService", pkg.ServiceName())
+	require.Equal("acme.groups.v1.Baz", pkg.Messages[0].Fields[0].Type.String())
+	require.Contains(pkg.Imports, "acme/groups/v1/baz.proto")
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/resolver"
 )
 
 // ProtoType represents a protobuf type. It can optionally have a
@@ -68,6 +70,22 @@ func (ds Decorators) Run(p *Package, m *Message, f *Field) {
 	}
 }
 
+// CustomType returns the decorators of a type that is converted by gogoproto
+// with the given Go type, like "github.com/google/uuid.UUID", which must
+// implement the methods required by the gogoproto.customtype option, such as
+// Marshal and Unmarshal. The type is usually mapped to bytes.
+func CustomType(goType string) Decorators {
+	return NewDecorators(
+		func(p *Package, m *Message, f *Field) {
+			if f.Options == nil {
+				f.Options = make(Options)
+			}
+
+			f.Options["(gogoproto.customtype)"] = NewStringValue(goType)
+		},
+	)
+}
+
 func CastToBasicType(basic string) Decorators {
 	return NewDecorators(
 		func(p *Package, m *Message, f *Field) {
@@ -161,6 +179,20 @@ var DefaultMappings = TypeMappings{
 			},
 		),
 	},
+	"encoding/json.RawMessage": &ProtoType{
+		Name:  "bytes",
+		Basic: true,
+		Decorators: NewDecorators(
+			func(p *Package, m *Message, f *Field) {
+				if f.Options == nil {
+					f.Options = make(Options)
+				}
+				f.Options["(gogoproto.casttype)"] = NewStringValue("encoding/json.RawMessage")
+				// bytes are never nullable
+				delete(f.Options, "(gogoproto.nullable)")
+			},
+		),
+	},
 	"time.Duration": &ProtoType{
 		Name:     "Duration",
 		Package:  "google.protobuf",
@@ -176,6 +208,112 @@ var DefaultMappings = TypeMappings{
 			},
 		),
 	},
+	"github.com/gogo/protobuf/types.Any": &ProtoType{
+		Name:     "Any",
+		Package:  "google.protobuf",
+		Import:   "google/protobuf/any.proto",
+		GoImport: "github.com/gogo/protobuf/types",
+	},
+	"github.com/gogo/protobuf/types.Value": &ProtoType{
+		Name:     "Value",
+		Package:  "google.protobuf",
+		Import:   "google/protobuf/struct.proto",
+		GoImport: "github.com/gogo/protobuf/types",
+	},
+	anyconvPkg + ".Any": &ProtoType{
+		Name:       "Any",
+		Package:    "google.protobuf",
+		Import:     "google/protobuf/any.proto",
+		GoImport:   "github.com/gogo/protobuf/types",
+		Decorators: CustomType(anyconvPkg + ".Any"),
+	},
+	anyconvPkg + ".Value": &ProtoType{
+		Name:       "Value",
+		Package:    "google.protobuf",
+		Import:     "google/protobuf/struct.proto",
+		GoImport:   "github.com/gogo/protobuf/types",
+		Decorators: CustomType(anyconvPkg + ".Value"),
+	},
+	customTypesPkg + ".Date":        customTypeMapping("Date", "google.type", "Date", "google/type/date.proto"),
+	customTypesPkg + ".Time":        customTypeMapping("Time", "google.type", "TimeOfDay", "google/type/timeofday.proto"),
+	customTypesPkg + ".DateTime":    customTypeMapping("DateTime", "google.type", "DateTime", "google/type/datetime.proto"),
+	customTypesPkg + ".BigInt":      customTypeMapping("BigInt", "google.type", "Decimal", "google/type/decimal.proto"),
+	customTypesPkg + ".BigFloat":    customTypeMapping("BigFloat", "google.type", "Decimal", "google/type/decimal.proto"),
+	customTypesPkg + ".NullString":  wrapperMapping("NullString", "StringValue", "google/protobuf/wrappers.proto"),
+	customTypesPkg + ".NullInt64":   wrapperMapping("NullInt64", "Int64Value", "google/protobuf/wrappers.proto"),
+	customTypesPkg + ".NullInt32":   wrapperMapping("NullInt32", "Int32Value", "google/protobuf/wrappers.proto"),
+	customTypesPkg + ".NullInt16":   wrapperMapping("NullInt16", "Int32Value", "google/protobuf/wrappers.proto"),
+	customTypesPkg + ".NullByte":    wrapperMapping("NullByte", "UInt32Value", "google/protobuf/wrappers.proto"),
+	customTypesPkg + ".NullFloat64": wrapperMapping("NullFloat64", "DoubleValue", "google/protobuf/wrappers.proto"),
+	customTypesPkg + ".NullBool":    wrapperMapping("NullBool", "BoolValue", "google/protobuf/wrappers.proto"),
+	customTypesPkg + ".NullTime":    wrapperMapping("NullTime", "Timestamp", "google/protobuf/timestamp.proto"),
+	customTypesPkg + ".UUID": &ProtoType{
+		Name:       "bytes",
+		Basic:      true,
+		Decorators: CustomType(customTypesPkg + ".UUID"),
+	},
+}
+
+// customTypesPkg is the package with the types holding the values of
+// well-known Go types the generated code can not marshal.
+const customTypesPkg = "gitlab.com/ThatTomPerson/proteus/customtypes"
+
+// anyconvPkg is the package with the types holding the values of the Any
+// and Value fields, which are converted by gogoproto.
+const anyconvPkg = "gitlab.com/ThatTomPerson/proteus/anyconv"
+
+// customTypeMapping returns the mapping of the type with the given name of
+// the customtypes package, which is converted by gogoproto and encoded as
+// the given protobuf type. The customtypes package is the Go package of its
+// .proto file, as the generated code only uses the custom type.
+func customTypeMapping(name, pkg, protoName, importPath string) *ProtoType {
+	return &ProtoType{
+		Name:       protoName,
+		Package:    pkg,
+		Import:     importPath,
+		GoImport:   customTypesPkg,
+		Decorators: CustomType(customTypesPkg + "." + name),
+	}
+}
+
+// wrapperMapping returns the mapping of the type with the given name of the
+// customtypes package encoded as the given well-known type of
+// google.protobuf. Its Go package is the one of the other well-known types,
+// so it is the same for all the fields of its .proto file.
+func wrapperMapping(name, protoName, importPath string) *ProtoType {
+	typ := customTypeMapping(name, "google.protobuf", protoName, importPath)
+	typ.GoImport = "github.com/gogo/protobuf/types"
+	return typ
+}
+
+// registeredMappings are the mappings registered with RegisterMapping.
+var registeredMappings = make(TypeMappings)
+
+// RegisterMapping maps the Go type with the given name, like
+// "github.com/google/uuid.UUID", to the given protobuf type in all the
+// transformers, overriding its default mapping, if any, but not the custom
+// mappings set in a transformer. The decorators of the protobuf type can add
+// the options needed to convert between both types, such as the ones of
+// CustomType or CastToBasicType. The type is also registered in the resolver,
+// so it is not ignored even though its package is not scanned. It must be
+// called before resolving and transforming any package.
+func RegisterMapping(goType string, typ *ProtoType) {
+	registeredMappings[goType] = typ
+	resolver.RegisterCustomType(goType)
+}
+
+// RegisteredMappings returns the default mappings along with the ones
+// registered with RegisterMapping, which take precedence.
+func RegisteredMappings() TypeMappings {
+	var mappings = make(TypeMappings)
+	for name, typ := range DefaultMappings {
+		mappings[name] = typ
+	}
+
+	for name, typ := range registeredMappings {
+		mappings[name] = typ
+	}
+	return mappings
 }
 
 // ToGoOutPath returns the set of import mappings for the --go_out family of options.
@@ -20,6 +20,52 @@ func Test_timeDurationDecorator(t *testing.T) {
 	assert.Equal(t, NewLiteralValue("true"), f.Options["(gogoproto.stdduration)"])
 }
 
+func TestCustomTypeMappings(t *testing.T) {
+	for _, name := range []string{"Date", "Time", "DateTime"} {
+		typ := DefaultMappings[customTypesPkg+"."+name]
+		assert.NotNil(t, typ, name)
+		assert.Equal(t, "google.type", typ.Package, name)
+		assert.Equal(t, customTypesPkg, typ.GoImport, name)
+
+		f := new(Field)
+		typ.Decorators.Run(&Package{}, &Message{}, f)
+		assert.Equal(t, NewStringValue(customTypesPkg+"."+name), f.Options["(gogoproto.customtype)"], name)
+	}
+	assert.Equal(t, "TimeOfDay", DefaultMappings[customTypesPkg+".Time"].Name)
+
+	assert.Equal(t, NewNamed("google.type", "Decimal"), DefaultMappings[customTypesPkg+".BigInt"].Type())
+	assert.Equal(t, NewNamed("google.type", "Decimal"), DefaultMappings[customTypesPkg+".BigFloat"].Type())
+
+	for name, protoName := range map[string]string{
+		"NullString": "StringValue",
+		"NullInt16":  "Int32Value",
+		"NullByte":   "UInt32Value",
+		"NullTime":   "Timestamp",
+	} {
+		typ := DefaultMappings[customTypesPkg+"."+name]
+		assert.Equal(t, NewNamed("google.protobuf", protoName), typ.Type(), name)
+		assert.Equal(t, "github.com/gogo/protobuf/types", typ.GoImport, name)
+	}
+
+	uuid := DefaultMappings[customTypesPkg+".UUID"]
+	assert.Equal(t, NewBasic("bytes"), uuid.Type())
+	f := new(Field)
+	uuid.Decorators.Run(&Package{}, &Message{}, f)
+	assert.Equal(t, NewStringValue(customTypesPkg+".UUID"), f.Options["(gogoproto.customtype)"])
+}
+
+func TestAnyconvMappings(t *testing.T) {
+	for _, name := range []string{"Any", "Value"} {
+		typ := DefaultMappings[anyconvPkg+"."+name]
+		assert.Equal(t, NewNamed("google.protobuf", name), typ.Type(), name)
+		assert.Equal(t, "github.com/gogo/protobuf/types", typ.GoImport, name)
+
+		f := new(Field)
+		typ.Decorators.Run(&Package{}, &Message{}, f)
+		assert.Equal(t, NewStringValue(anyconvPkg+"."+name), f.Options["(gogoproto.customtype)"], name)
+	}
+}
+
 func TestDefaultMappingUpgradeBasicDecoratos(t *testing.T) {
 	upgraded := []string{"uint8", "int8", "byte", "uint16", "int16", "uint", "int", "uintptr", "rune"}
 
@@ -34,6 +80,28 @@ func TestDefaultMappingUpgradeBasicDecoratos(t *testing.T) {
 	}
 }
 
+func TestRegisterMapping(t *testing.T) {
+	defer delete(registeredMappings, "github.com/google/uuid.UUID")
+	defer delete(registeredMappings, "int")
+
+	uuid := &ProtoType{Name: "bytes", Basic: true, Decorators: CustomType("github.com/google/uuid.UUID")}
+	RegisterMapping("github.com/google/uuid.UUID", uuid)
+	RegisterMapping("int", &ProtoType{Name: "int32", Basic: true})
+
+	tr := NewTransformer()
+	assert.Equal(t, uuid, tr.findMapping("github.com/google/uuid.UUID"))
+	assert.Equal(t, "int32", tr.findMapping("int").Name, "registered mappings override default ones")
+	assert.Equal(t, "int32", RegisteredMappings()["int"].Name)
+	assert.Equal(t, DefaultMappings["string"], RegisteredMappings()["string"])
+
+	tr.SetMappings(TypeMappings{"int": &ProtoType{Name: "sint64", Basic: true}})
+	assert.Equal(t, "sint64", tr.findMapping("int").Name, "custom mappings override registered ones")
+
+	f := new(Field)
+	uuid.Decorators.Run(&Package{}, &Message{}, f)
+	assert.Equal(t, NewStringValue("github.com/google/uuid.UUID"), f.Options["(gogoproto.customtype)"])
+}
+
 func TestToGoOutPath(t *testing.T) {
 	// Empty case
 	assert.Equal(t, "", TypeMappings{}.ToGoOutPath())
@@ -0,0 +1,248 @@
+package protobuf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gogo/protobuf/protoc-gen-gogo/generator"
+)
+
+var packageNameExpr = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// ParsePackageName returns the given protobuf package name, which must be a
+// list of valid names separated by dots, e.g. acme.api.v1.
+func ParsePackageName(name string) (string, error) {
+	if !packageNameExpr.MatchString(name) {
+		return "", fmt.Errorf("invalid protobuf package %q, it must be a list of names separated by dots", name)
+	}
+	return name, nil
+}
+
+// MergePackages merges the given packages into a single one with the given
+// protobuf package, for consumers that want a flat API surface instead of a
+// package per Go package. Messages, enums and services whose names are used
+// in more than one of the packages are prefixed with the last element of the
+// path of their Go package in camel case, or its whole path if that is not
+// enough to tell them apart, e.g. users.User and groups.User are UsersUser
+// and GroupsUser. The same goes for the values of enums, as they share the
+// scope of the package in protobuf.
+//
+// The references between the merged packages become references to the types
+// of the merged package, and their imports are dropped. As the merged file
+// does not belong to any Go package, it has no go_package option. Its path is
+// the name of the package with slashes instead of dots, so the file layout of
+// the generator is applied to it as if it was the path of a Go package. The
+// given packages are not modified.
+func MergePackages(name string, pkgs []*Package) *Package {
+	var (
+		prefixes = mergePrefixes(pkgs)
+		renamed  = make(map[string]string)
+		merged   = make(map[string]bool)
+		counts   = make(map[string]int)
+		values   = make(map[string]int)
+	)
+
+	for _, p := range pkgs {
+		merged[p.Name] = true
+		for _, n := range p.typeNames() {
+			counts[n]++
+		}
+
+		for _, e := range p.Enums {
+			for _, v := range e.Values {
+				values[v.Name]++
+			}
+		}
+	}
+
+	for _, p := range pkgs {
+		for _, n := range p.typeNames() {
+			renamed[p.Name+"."+n] = n
+			if counts[n] > 1 {
+				renamed[p.Name+"."+n] = prefixes[p.Name] + n
+			}
+		}
+	}
+
+	rename := func(n *Named) *Named {
+		if !merged[n.Package] {
+			return n
+		}
+
+		r := *n
+		r.Package = name
+		if to, ok := renamed[n.String()]; ok {
+			r.Name = to
+		}
+		return &r
+	}
+
+	result := &Package{
+		Name:        name,
+		Path:        strings.Replace(name, ".", "/", -1),
+		Options:     make(Options),
+		typeImports: make(map[string]string),
+		pkgImports:  make(map[string]string),
+	}
+
+	for _, p := range pkgs {
+		if result.fileLayout == "" {
+			result.fileLayout = p.fileLayout
+			result.pkgTemplate = p.pkgTemplate
+			result.pkgNames = p.pkgNames
+			result.fieldNaming = p.fieldNaming
+			for k, v := range p.Options {
+				if k != "go_package" {
+					result.Options[k] = v
+				}
+			}
+		}
+
+		for _, i := range p.Imports {
+			if !result.isImported(i) && !p.importsMerged(i, merged) {
+				result.Imports = append(result.Imports, i)
+			}
+		}
+
+		for typ, file := range p.typeImports {
+			result.typeImports[typ] = file
+		}
+
+		for pkg, file := range p.pkgImports {
+			if !merged[pkg] {
+				result.pkgImports[pkg] = file
+			}
+		}
+
+		for _, m := range p.Messages {
+			msg := *m
+			msg.Name = renamed[p.Name+"."+m.Name]
+			msg.Reserved = append([]uint(nil), m.Reserved...)
+			msg.ReservedNames = append([]string(nil), m.ReservedNames...)
+			msg.Fields = make([]*Field, len(m.Fields))
+			for i, f := range m.Fields {
+				field := *f
+				field.Type = renameType(f.Type, rename)
+				msg.Fields[i] = &field
+			}
+			result.Messages = append(result.Messages, &msg)
+		}
+
+		for _, e := range p.Enums {
+			enum := *e
+			enum.Name = renamed[p.Name+"."+e.Name]
+			enum.Reserved = append([]uint(nil), e.Reserved...)
+			enum.ReservedNames = append([]string(nil), e.ReservedNames...)
+			enum.Values = make([]*EnumValue, len(e.Values))
+			for i, v := range e.Values {
+				value := *v
+				if values[v.Name] > 1 {
+					value.Name = prefixValue(prefixes[p.Name], v.Name)
+				}
+				enum.Values[i] = &value
+			}
+			result.Enums = append(result.Enums, &enum)
+		}
+
+		for _, s := range p.Services {
+			svc := *s
+			svc.Name = renamed[p.Name+"."+s.Name]
+			svc.RPCs = make([]*RPC, len(s.RPCs))
+			for i, r := range s.RPCs {
+				rpc := *r
+				rpc.Input = renameType(r.Input, rename)
+				rpc.Output = renameType(r.Output, rename)
+				svc.RPCs[i] = &rpc
+			}
+			result.Services = append(result.Services, &svc)
+		}
+	}
+	result.DetectFeatures()
+	return result
+}
+
+// typeNames returns the names of the messages, enums and services of the
+// package, which share the same scope.
+func (p *Package) typeNames() []string {
+	var names []string
+	for _, m := range p.Messages {
+		names = append(names, m.Name)
+	}
+
+	for _, e := range p.Enums {
+		names = append(names, e.Name)
+	}
+
+	for _, s := range p.Services {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// importsMerged reports whether the given import of the package is the file
+// of any of the merged packages.
+func (p *Package) importsMerged(file string, merged map[string]bool) bool {
+	for pkg, f := range p.pkgImports {
+		if f == file && merged[pkg] {
+			return true
+		}
+	}
+	return false
+}
+
+// mergePrefixes returns the prefixes of the names of the given packages, by
+// package name. They are the last element of their Go path, unless there are
+// packages with the same last element.
+func mergePrefixes(pkgs []*Package) map[string]string {
+	var (
+		prefixes = make(map[string]string, len(pkgs))
+		seen     = make(map[string]int)
+	)
+
+	for _, p := range pkgs {
+		prefixes[p.Name] = pathPrefix(p.Path[strings.LastIndex(p.Path, "/")+1:])
+		seen[prefixes[p.Name]]++
+	}
+
+	for _, p := range pkgs {
+		if seen[prefixes[p.Name]] > 1 {
+			prefixes[p.Name] = pathPrefix(p.Path)
+		}
+	}
+	return prefixes
+}
+
+// pathPrefix returns the given Go path in camel case, e.g. GithubComFooBar
+// for github.com/foo/bar.
+func pathPrefix(path string) string {
+	return generator.CamelCase(strings.Replace(toProtobufPkg(path), ".", "_", -1))
+}
+
+// prefixValue prefixes the name of an enum value, in upper snake case if the
+// name is.
+func prefixValue(prefix, name string) string {
+	if strings.ToUpper(name) == name {
+		return toUpperSnakeCase(prefix) + "_" + name
+	}
+	return prefix + name
+}
+
+// renameType returns the given type with its named types replaced by the
+// result of rename, without modifying it.
+func renameType(typ Type, rename func(*Named) *Named) Type {
+	switch t := typ.(type) {
+	case *Named:
+		return rename(t)
+	case *Map:
+		m := *t
+		m.Key = renameType(t.Key, rename)
+		m.Value = renameType(t.Value, rename)
+		return &m
+	case *Alias:
+		a := *t
+		a.Underlying = renameType(t.Underlying, rename)
+		return &a
+	}
+	return typ
+}
@@ -0,0 +1,124 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePackages(t *testing.T) {
+	require := require.New(t)
+
+	users := &Package{
+		Name:    "acme.users",
+		Path:    "github.com/acme/users",
+		Imports: []string{"github.com/gogo/protobuf/gogoproto/gogo.proto", "github.com/acme/groups/generated.proto"},
+		Options: Options{
+			"go_package":            NewStringValue("users"),
+			"(gogoproto.sizer_all)": NewLiteralValue("false"),
+		},
+		pkgImports: map[string]string{
+			"gogoproto":   "github.com/gogo/protobuf/gogoproto/gogo.proto",
+			"acme.groups": "github.com/acme/groups/generated.proto",
+		},
+		Messages: []*Message{
+			{Name: "User", Reserved: []uint{3}, Fields: []*Field{
+				{Name: "groups", Pos: 1, Type: NewMap(NewBasic("string"), NewNamed("acme.groups", "Group"))},
+				{Name: "kind", Pos: 2, Type: NewNamed("acme.users", "Kind")},
+			}},
+		},
+		Enums: []*Enum{
+			{Name: "Kind", Values: []*EnumValue{{Name: "ADMIN", Value: 0}, {Name: "DEFAULT", Value: 1}}},
+		},
+		Services: []*Service{
+			{Name: "Service", RPCs: []*RPC{
+				{Name: "Get", Input: NewNamed("acme.users", "User"), Output: NewNamed("acme.users", "User")},
+			}},
+		},
+	}
+
+	groups := &Package{
+		Name:    "acme.groups",
+		Path:    "github.com/acme/groups",
+		Imports: []string{"github.com/gogo/protobuf/gogoproto/gogo.proto", "google/protobuf/timestamp.proto"},
+		pkgImports: map[string]string{
+			"gogoproto": "github.com/gogo/protobuf/gogoproto/gogo.proto",
+		},
+		typeImports: map[string]string{
+			"google.protobuf.Timestamp": "google/protobuf/timestamp.proto",
+		},
+		Messages: []*Message{
+			{Name: "Group", Fields: []*Field{
+				{Name: "owner", Pos: 1, Type: NewNamed("acme.users", "User")},
+				{Name: "created", Pos: 2, Type: NewNamed("google.protobuf", "Timestamp")},
+			}},
+		},
+		Enums: []*Enum{
+			{Name: "Level", Values: []*EnumValue{{Name: "DEFAULT", Value: 0}, {Name: "Secret", Value: 1}}},
+		},
+		Services: []*Service{
+			{Name: "Service"},
+		},
+	}
+
+	merged := MergePackages("acme.api", []*Package{users, groups})
+	require.Equal("acme.api", merged.Name)
+	require.Equal("acme/api", merged.Path)
+	require.Equal(Options{"(gogoproto.sizer_all)": NewLiteralValue("false")}, merged.Options)
+	require.Equal([]string{"github.com/gogo/protobuf/gogoproto/gogo.proto", "google/protobuf/timestamp.proto"}, merged.Imports)
+
+	require.Len(merged.Messages, 2)
+	user, group := merged.Messages[0], merged.Messages[1]
+	require.Equal("User", user.Name)
+	require.Equal("map<string, acme.api.Group>", user.Fields[0].Type.String())
+	require.Equal("acme.api.Kind", user.Fields[1].Type.String())
+	require.Equal("Group", group.Name)
+	require.Equal("acme.api.User", group.Fields[0].Type.String())
+	require.Equal("google.protobuf.Timestamp", group.Fields[1].Type.String())
+
+	require.Len(merged.Enums, 2)
+	require.Equal("Kind", merged.Enums[0].Name)
+	require.Equal("ADMIN", merged.Enums[0].Values[0].Name)
+	require.Equal("USERS_DEFAULT", merged.Enums[0].Values[1].Name)
+	require.Equal("GROUPS_DEFAULT", merged.Enums[1].Values[0].Name)
+	require.Equal("Secret", merged.Enums[1].Values[1].Name)
+
+	require.Len(merged.Services, 2)
+	require.Equal("UsersService", merged.Services[0].Name)
+	require.Equal("GroupsService", merged.Services[1].Name)
+	require.Equal("acme.api.User", merged.Services[0].RPCs[0].Input.String())
+
+	merged.Messages[0].Reserve(4)
+	require.Equal([]uint{3}, users.Messages[0].Reserved)
+	require.Equal("User", users.Services[0].RPCs[0].Input.(*Named).Name)
+	require.Equal("acme.groups.Group", users.Messages[0].Fields[0].Type.(*Map).Value.String())
+	require.Equal("DEFAULT", groups.Enums[0].Values[0].Name)
+}
+
+func TestMergePrefixes(t *testing.T) {
+	require := require.New(t)
+
+	prefixes := mergePrefixes([]*Package{
+		{Name: "a", Path: "github.com/acme/users"},
+		{Name: "b", Path: "github.com/acme/v1/groups"},
+		{Name: "c", Path: "github.com/acme/v2/groups"},
+	})
+	require.Equal(map[string]string{
+		"a": "Users",
+		"b": "GithubComAcmeV1Groups",
+		"c": "GithubComAcmeV2Groups",
+	}, prefixes)
+}
+
+func TestParsePackageName(t *testing.T) {
+	require := require.New(t)
+
+	name, err := ParsePackageName("acme.api.v1")
+	require.NoError(err)
+	require.Equal("acme.api.v1", name)
+
+	for _, n := range []string{"", "acme..api", ".acme", "acme/api", "1acme"} {
+		_, err := ParsePackageName(n)
+		require.Error(err, n)
+	}
+}
@@ -0,0 +1,84 @@
+package protobuf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// MessageFile is a .proto file published elsewhere, e.g. in a registry or
+// vendored, with the messages and enums of a Go package.
+type MessageFile struct {
+	// Import is the path the file is imported from.
+	Import string
+	// Package is the protobuf package of the file.
+	Package string
+}
+
+// MessageFiles are the .proto files of Go packages, indexed by their path.
+// Types of those packages are referenced from their file instead of the
+// generated file of the package, and the ones that already implement
+// proto.Message are kept even if their package is not scanned.
+type MessageFiles map[string]*MessageFile
+
+// ParseMessageFiles creates MessageFiles from a list of "GOPKG=FILE" pairs,
+// where the file can be followed by ":PACKAGE" with its protobuf package.
+// Otherwise, the package is the one proteus would give to the Go package.
+func ParseMessageFiles(pairs []string) (MessageFiles, error) {
+	files := make(MessageFiles)
+	for _, p := range pairs {
+		idx := strings.Index(p, "=")
+		if idx <= 0 || idx == len(p)-1 {
+			return nil, fmt.Errorf("invalid message file %q, expected GOPKG=FILE or GOPKG=FILE:PACKAGE", p)
+		}
+
+		path, file := p[:idx], p[idx+1:]
+		pkg := toProtobufPkg(path)
+		if i := strings.LastIndex(file, ":"); i >= 0 {
+			file, pkg = file[:i], file[i+1:]
+		}
+
+		if file == "" || pkg == "" {
+			return nil, fmt.Errorf("invalid message file %q, expected GOPKG=FILE or GOPKG=FILE:PACKAGE", p)
+		}
+		files[path] = &MessageFile{Import: file, Package: pkg}
+	}
+	return files, nil
+}
+
+// ToGoOutPath returns the import mappings of the files for the --go_out
+// family of options, so the generated code uses the existing types.
+func (f MessageFiles) ToGoOutPath() string {
+	var paths []string
+	for path := range f {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var strs = make([]string, len(paths))
+	for i, path := range paths {
+		strs[i] = fmt.Sprintf("M%s=%s", f[path].Import, path)
+	}
+	return strings.Join(strs, ",")
+}
+
+// messageType returns the type of the existing message of the given Go type
+// that implements proto.Message, or nil if the .proto file of its package is
+// not known.
+func (t *Transformer) messageType(typ *scanner.Named) *ProtoType {
+	file, ok := t.messageFiles[typ.Path]
+	if !ok {
+		report.Warn("type %s implements proto.Message but the .proto file of package %s is not known, ignoring it", typ, typ.Path)
+		return nil
+	}
+
+	return &ProtoType{
+		Name:     typ.Name,
+		Package:  file.Package,
+		Import:   file.Import,
+		GoImport: typ.Path,
+	}
+}
@@ -0,0 +1,89 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestParseMessageFiles(t *testing.T) {
+	require := require.New(t)
+
+	files, err := ParseMessageFiles([]string{
+		"github.com/foo/pb=foo/pb/user.proto:foo.v1",
+		"github.com/bar/pb=bar/pb.proto",
+	})
+	require.Nil(err)
+	require.Equal(MessageFiles{
+		"github.com/foo/pb": {Import: "foo/pb/user.proto", Package: "foo.v1"},
+		"github.com/bar/pb": {Import: "bar/pb.proto", Package: "github.com.bar.pb"},
+	}, files)
+
+	for _, p := range []string{"foo", "=foo.proto", "foo=", "foo=bar.proto:", "foo=:bar"} {
+		_, err := ParseMessageFiles([]string{p})
+		require.NotNil(err, p)
+	}
+}
+
+func TestMessageFilesToGoOutPath(t *testing.T) {
+	files := MessageFiles{
+		"github.com/foo/pb": {Import: "foo/pb/user.proto", Package: "foo.v1"},
+		"github.com/bar/pb": {Import: "bar/pb.proto", Package: "bar"},
+	}
+
+	require.Equal(t, "Mbar/pb.proto=github.com/bar/pb,Mfoo/pb/user.proto=github.com/foo/pb", files.ToGoOutPath())
+}
+
+func TestTransformMessageField(t *testing.T) {
+	require := require.New(t)
+
+	user := scanner.NewNamed("github.com/foo/pb", "User")
+	user.(*scanner.Named).Message = true
+	st := &scanner.Struct{
+		Name: "Session",
+		Fields: []*scanner.Field{
+			{Name: "User", Type: nullable(user)},
+			{Name: "Token", Type: scanner.NewBasic("string")},
+		},
+	}
+
+	tr := NewTransformer()
+	tr.SetMessageFiles(MessageFiles{
+		"github.com/foo/pb": {Import: "foo/pb/user.proto", Package: "foo.v1"},
+	})
+
+	pkg := &Package{Path: "bar"}
+	msg := tr.transformStruct(pkg, st)
+	require.Len(msg.Fields, 2)
+	require.Equal("foo.v1.User", msg.Fields[0].Type.String())
+	require.Equal([]string{"foo/pb/user.proto"}, pkg.Imports)
+
+	msg = NewTransformer().transformStruct(&Package{Path: "bar"}, st)
+	require.Len(msg.Fields, 1, "messages of packages without a known file are ignored")
+	require.Equal("token", msg.Fields[0].Name)
+}
+
+func TestTransformMessageFilePackage(t *testing.T) {
+	require := require.New(t)
+
+	st := &scanner.Struct{
+		Name: "Order",
+		Fields: []*scanner.Field{
+			{Name: "Money", Type: nullable(scanner.NewNamed("github.com/foo/money", "Money"))},
+			{Name: "Item", Type: nullable(scanner.NewNamed("bar", "Item"))},
+		},
+	}
+
+	tr := NewTransformer()
+	tr.SetMessageFiles(MessageFiles{
+		"github.com/foo/money": {Import: "foo/money/v1/money.proto", Package: "foo.money.v1"},
+		"bar":                  {Import: "bar/v1/bar.proto", Package: "bar.v1"},
+	})
+
+	pkg := &Package{Path: "bar"}
+	msg := tr.transformStruct(pkg, st)
+	require.Equal("foo.money.v1.Money", msg.Fields[0].Type.String())
+	require.Equal("bar.Item", msg.Fields[1].Type.String(), "types of the package itself are not imported")
+	require.Equal([]string{"foo/money/v1/money.proto"}, pkg.Imports)
+}
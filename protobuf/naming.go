@@ -0,0 +1,310 @@
+package protobuf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// EnumNaming is the strategy used to name the values of enums after their
+// Go constants.
+type EnumNaming string
+
+const (
+	// SnakeCaseNaming names values after their Go name in upper snake case,
+	// e.g. ColorRed is COLOR_RED. It is the default.
+	SnakeCaseNaming EnumNaming = "snake"
+	// PrefixNaming names values like SnakeCaseNaming, prepending the name of
+	// the enum if they do not start with it already, e.g. Red in the enum
+	// Color is COLOR_RED.
+	PrefixNaming EnumNaming = "prefix"
+	// StripNaming names values like SnakeCaseNaming, removing the prefix all
+	// of them have in common, e.g. ColorRed and ColorBlue are RED and BLUE.
+	StripNaming EnumNaming = "strip"
+	// VerbatimNaming names values exactly like their Go name.
+	VerbatimNaming EnumNaming = "verbatim"
+)
+
+// ParseEnumNaming returns the enum naming strategy with the given name.
+func ParseEnumNaming(name string) (EnumNaming, error) {
+	switch n := EnumNaming(name); n {
+	case SnakeCaseNaming, PrefixNaming, StripNaming, VerbatimNaming:
+		return n, nil
+	}
+	return "", fmt.Errorf("invalid enum naming %q, expecting snake, prefix, strip or verbatim", name)
+}
+
+// enumValueNames returns the names of the values with the given Go names of
+// the enum with the given name, following the naming strategy.
+func enumValueNames(naming EnumNaming, acronyms Acronyms, enum string, values []string) []string {
+	var names = make([]string, len(values))
+	switch naming {
+	case VerbatimNaming:
+		copy(names, values)
+	case PrefixNaming:
+		prefix := acronyms.EnumValuePrefix(enum)
+		for i, v := range values {
+			names[i] = acronyms.toUpperSnakeCase(v)
+			if !strings.HasPrefix(names[i], prefix) {
+				names[i] = prefix + names[i]
+			}
+		}
+	case StripNaming:
+		var words = make([][]string, len(values))
+		for i, v := range values {
+			words[i] = strings.Split(acronyms.toUpperSnakeCase(v), "_")
+		}
+
+		n := commonPrefixLen(words)
+		for i := range values {
+			names[i] = strings.Join(words[i][n:], "_")
+		}
+	default:
+		for i, v := range values {
+			names[i] = acronyms.toUpperSnakeCase(v)
+		}
+	}
+	return names
+}
+
+// EnumValuePrefix returns the prefix of the values of the enum with the given
+// name with PrefixNaming and the default acronyms, e.g. COLOR_ for Color.
+func EnumValuePrefix(enum string) string {
+	return Acronyms{}.EnumValuePrefix(enum)
+}
+
+// EnumValuePrefix returns the prefix of the values of the enum with the given
+// name with PrefixNaming, e.g. GRPC_MODE_ for GRPCMode if GRPC is one of the
+// acronyms.
+func (a Acronyms) EnumValuePrefix(enum string) string {
+	return a.toUpperSnakeCase(enum) + "_"
+}
+
+// commonPrefixLen returns the number of words all the lists start with,
+// leaving at least one word in every list, which can not start with a digit
+// as it would not be a valid name. A single list has no common prefix.
+func commonPrefixLen(lists [][]string) int {
+	if len(lists) < 2 {
+		return 0
+	}
+
+	var n int
+	for ; ; n++ {
+		for _, l := range lists {
+			if n+1 >= len(l) || l[n] != lists[0][n] || startsWithDigit(l[n+1]) {
+				return n
+			}
+		}
+	}
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && unicode.IsDigit(rune(s[0]))
+}
+
+// FieldNaming is the strategy used to name the fields of messages after the
+// fields of their Go structs.
+type FieldNaming string
+
+const (
+	// SnakeCaseFieldNaming names fields after their Go name in lower snake
+	// case, e.g. UserName is user_name. It is the default.
+	SnakeCaseFieldNaming FieldNaming = "snake"
+	// KeepCaseFieldNaming names fields exactly like their Go name.
+	KeepCaseFieldNaming FieldNaming = "keep"
+	// JSONFieldNaming names fields after the name in their json tag, using
+	// SnakeCaseFieldNaming for the fields without one that is a valid proto
+	// name.
+	JSONFieldNaming FieldNaming = "json"
+)
+
+// ParseFieldNaming returns the field naming strategy with the given name.
+func ParseFieldNaming(name string) (FieldNaming, error) {
+	switch n := FieldNaming(name); n {
+	case SnakeCaseFieldNaming, KeepCaseFieldNaming, JSONFieldNaming:
+		return n, nil
+	}
+	return "", fmt.Errorf("invalid field naming %q, expecting snake, keep or json", name)
+}
+
+// fieldName returns the name of the field following the naming strategy,
+// unless it has a name set in its tag, which is always used.
+func fieldName(naming FieldNaming, acronyms Acronyms, field *scanner.Field) string {
+	if field.ProtoName != "" {
+		return field.ProtoName
+	}
+
+	switch naming {
+	case KeepCaseFieldNaming:
+		return field.Name
+	case JSONFieldNaming:
+		if protoNameRegex.MatchString(field.JSONName) {
+			return field.JSONName
+		}
+	}
+	return acronyms.toLowerSnakeCase(field.Name)
+}
+
+// JSONCasing is the casing of the names of the fields of messages in their
+// JSON encoding.
+type JSONCasing string
+
+const (
+	// CamelCaseJSON names fields in JSON after their proto name in lower
+	// camel case, e.g. user_id is userId, as protobuf does. It is the
+	// default.
+	CamelCaseJSON JSONCasing = "camel"
+	// SnakeCaseJSON names fields in JSON after their proto name in lower
+	// snake case, e.g. user_id.
+	SnakeCaseJSON JSONCasing = "snake"
+	// GoCaseJSON names fields in JSON exactly like their Go name, as
+	// encoding/json does for fields without a json tag, e.g. UserID.
+	GoCaseJSON JSONCasing = "go"
+)
+
+// ParseJSONCasing returns the JSON casing with the given name.
+func ParseJSONCasing(name string) (JSONCasing, error) {
+	switch c := JSONCasing(name); c {
+	case CamelCaseJSON, SnakeCaseJSON, GoCaseJSON:
+		return c, nil
+	}
+	return "", fmt.Errorf("invalid JSON casing %q, expecting camel, snake or go", name)
+}
+
+// jsonFieldName returns the name in JSON of the field with the given proto
+// name following the casing, unless it has a name in its json tag, which is
+// always used.
+func jsonFieldName(casing JSONCasing, acronyms Acronyms, field *scanner.Field, name string) string {
+	if field.JSONName != "" {
+		return field.JSONName
+	}
+
+	switch casing {
+	case SnakeCaseJSON:
+		return acronyms.toLowerSnakeCase(name)
+	case GoCaseJSON:
+		return field.Name
+	}
+	return jsonName(name)
+}
+
+var protoNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonName returns the name protoc gives in JSON to the field with the given
+// name, which is the name in lower camel case, e.g. user_id is userId.
+func jsonName(name string) string {
+	var buf bytes.Buffer
+	var upper bool
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// defaultAcronyms are the acronyms known by default, which are the common
+// initialisms of golint.
+var defaultAcronyms = []string{
+	"ACL", "API", "ASCII", "CPU", "CSS", "DNS", "EOF", "GUID", "HTML", "HTTP",
+	"HTTPS", "ID", "IP", "JSON", "LHS", "QPS", "RAM", "RHS", "RPC", "SLA",
+	"SMTP", "SQL", "SSH", "TCP", "TLS", "TTL", "UDP", "UI", "UID", "UUID",
+	"URI", "URL", "UTF8", "VM", "XML", "XMPP", "XSRF", "XSS",
+}
+
+// defaultAcronymList are the default acronyms, longest first.
+var defaultAcronymList = sortAcronyms(defaultAcronyms)
+
+// Acronyms are the acronyms kept as a single word when names are converted
+// to snake case. The zero Acronyms are the default ones.
+type Acronyms struct {
+	// list are the acronyms, longest first so that a name is matched
+	// against the longest acronym it starts with.
+	list [][]rune
+}
+
+// NewAcronyms returns the default acronyms along with the given ones, e.g.
+// with GRPC, GRPCServer is grpc_server instead of grpcserver. Acronyms must
+// only contain letters and digits and start with a letter.
+func NewAcronyms(list ...string) (Acronyms, error) {
+	var all = append([]string(nil), defaultAcronyms...)
+	for _, a := range list {
+		if !isValidAcronym(a) {
+			return Acronyms{}, fmt.Errorf("invalid acronym %q, expecting letters and digits starting with a letter", a)
+		}
+
+		if !containsString(all, a) {
+			all = append(all, a)
+		}
+	}
+
+	return Acronyms{list: sortAcronyms(all)}, nil
+}
+
+func (a Acronyms) known() [][]rune {
+	if a.list == nil {
+		return defaultAcronymList
+	}
+	return a.list
+}
+
+func isValidAcronym(a string) bool {
+	for i, r := range a {
+		if !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return a != ""
+}
+
+func sortAcronyms(list []string) [][]rune {
+	var sorted = make([][]rune, len(list))
+	for i, a := range list {
+		sorted[i] = []rune(a)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	return sorted
+}
+
+// prefixLen returns the length of the acronym the runes start with,
+// including its plural "s", as in IDs, or 0 if they start with none. An
+// acronym must be followed by the end of the runes or a new word, so ID is
+// not found in IDentity.
+func (a Acronyms) prefixLen(rs []rune) int {
+	for _, acronym := range a.known() {
+		n := len(acronym)
+		if len(rs) < n || string(rs[:n]) != string(acronym) {
+			continue
+		}
+
+		if endsWord(rs, n) {
+			return n
+		}
+
+		if rs[n] == 's' && endsWord(rs, n+1) {
+			return n + 1
+		}
+	}
+	return 0
+}
+
+// endsWord reports whether a word of the runes ends at the given position,
+// that is, a lowercase letter or a digit does not follow it.
+func endsWord(rs []rune, pos int) bool {
+	return pos >= len(rs) || !(unicode.IsLower(rs[pos]) || unicode.IsDigit(rs[pos]))
+}
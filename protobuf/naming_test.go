@@ -0,0 +1,148 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestParseEnumNaming(t *testing.T) {
+	require := require.New(t)
+
+	for _, n := range []EnumNaming{SnakeCaseNaming, PrefixNaming, StripNaming, VerbatimNaming} {
+		naming, err := ParseEnumNaming(string(n))
+		require.Nil(err)
+		require.Equal(n, naming)
+	}
+
+	_, err := ParseEnumNaming("camel")
+	require.NotNil(err)
+}
+
+func TestEnumValueNames(t *testing.T) {
+	cases := []struct {
+		naming   EnumNaming
+		enum     string
+		values   []string
+		expected []string
+	}{
+		{"", "Color", []string{"ColorRed", "Blue"}, []string{"COLOR_RED", "BLUE"}},
+		{SnakeCaseNaming, "Color", []string{"ColorRed", "Blue"}, []string{"COLOR_RED", "BLUE"}},
+		{PrefixNaming, "Color", []string{"ColorRed", "Blue"}, []string{"COLOR_RED", "COLOR_BLUE"}},
+		{PrefixNaming, "PageSize", []string{"Mobile"}, []string{"PAGE_SIZE_MOBILE"}},
+		{StripNaming, "Color", []string{"ColorRed", "ColorDarkBlue"}, []string{"RED", "DARK_BLUE"}},
+		{StripNaming, "Status", []string{"StatusUserActive", "StatusUserDeleted"}, []string{"ACTIVE", "DELETED"}},
+		{StripNaming, "Color", []string{"ColorRed", "Blue"}, []string{"COLOR_RED", "BLUE"}},
+		{StripNaming, "Color", []string{"ColorRed"}, []string{"COLOR_RED"}},
+		{StripNaming, "Color", []string{"Color", "ColorRed"}, []string{"COLOR", "COLOR_RED"}},
+		{StripNaming, "Size", []string{"Size1x", "Size2x"}, []string{"SIZE1X", "SIZE2X"}},
+		{VerbatimNaming, "Color", []string{"ColorRed", "Blue"}, []string{"ColorRed", "Blue"}},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expected, enumValueNames(c.naming, Acronyms{}, c.enum, c.values), "%s %v", c.naming, c.values)
+	}
+}
+
+func TestParseFieldNaming(t *testing.T) {
+	require := require.New(t)
+
+	for _, n := range []FieldNaming{SnakeCaseFieldNaming, KeepCaseFieldNaming, JSONFieldNaming} {
+		naming, err := ParseFieldNaming(string(n))
+		require.Nil(err)
+		require.Equal(n, naming)
+	}
+
+	_, err := ParseFieldNaming("camel")
+	require.NotNil(err)
+}
+
+func TestFieldName(t *testing.T) {
+	cases := []struct {
+		naming   FieldNaming
+		field    *scanner.Field
+		expected string
+	}{
+		{"", &scanner.Field{Name: "UserName"}, "user_name"},
+		{SnakeCaseFieldNaming, &scanner.Field{Name: "UserName", JSONName: "username"}, "user_name"},
+		{KeepCaseFieldNaming, &scanner.Field{Name: "UserName"}, "UserName"},
+		{JSONFieldNaming, &scanner.Field{Name: "UserName", JSONName: "username"}, "username"},
+		{JSONFieldNaming, &scanner.Field{Name: "UserName"}, "user_name"},
+		{JSONFieldNaming, &scanner.Field{Name: "UserName", JSONName: "user-name"}, "user_name"},
+		{JSONFieldNaming, &scanner.Field{Name: "UserName", JSONName: "username", ProtoName: "login"}, "login"},
+		{KeepCaseFieldNaming, &scanner.Field{Name: "UserName", ProtoName: "login"}, "login"},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expected, fieldName(c.naming, Acronyms{}, c.field), "%s with %q naming", c.field.Name, c.naming)
+	}
+}
+
+func TestNewAcronyms(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("grpcserver", Acronyms{}.toLowerSnakeCase("GRPCServer"))
+	acronyms, err := NewAcronyms("GRPC", "ID")
+	require.Nil(err)
+	require.Equal("grpc_server", acronyms.toLowerSnakeCase("GRPCServer"))
+	require.Equal("GRPC_SERVER_ID", acronyms.toUpperSnakeCase("GRPCServerID"))
+	require.Equal("GRPC_MODE_", acronyms.EnumValuePrefix("GRPCMode"))
+	require.Equal("grpcserver", toLowerSnakeCase("GRPCServer"), "the default acronyms are not changed")
+
+	for _, a := range []string{"", "1X", "foo_bar"} {
+		_, err := NewAcronyms(a)
+		require.NotNil(err, "acronym %q", a)
+	}
+}
+
+func TestJSONName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"name", "name"},
+		{"user_id", "userId"},
+		{"user_ID", "userID"},
+		{"UserID", "UserID"},
+		{"foo__bar_", "fooBar"},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expected, jsonName(c.name), c.name)
+	}
+}
+
+func TestParseJSONCasing(t *testing.T) {
+	require := require.New(t)
+
+	for _, c := range []JSONCasing{CamelCaseJSON, SnakeCaseJSON, GoCaseJSON} {
+		casing, err := ParseJSONCasing(string(c))
+		require.Nil(err)
+		require.Equal(c, casing)
+	}
+
+	_, err := ParseJSONCasing("kebab")
+	require.NotNil(err)
+}
+
+func TestJSONFieldName(t *testing.T) {
+	cases := []struct {
+		casing   JSONCasing
+		field    *scanner.Field
+		name     string
+		expected string
+	}{
+		{"", &scanner.Field{Name: "UserID"}, "user_id", "userId"},
+		{CamelCaseJSON, &scanner.Field{Name: "UserID"}, "user_id", "userId"},
+		{SnakeCaseJSON, &scanner.Field{Name: "UserID"}, "user_id", "user_id"},
+		{SnakeCaseJSON, &scanner.Field{Name: "UserID"}, "UserID", "user_id"},
+		{GoCaseJSON, &scanner.Field{Name: "UserID"}, "user_id", "UserID"},
+		{GoCaseJSON, &scanner.Field{Name: "UserID", JSONName: "uid"}, "user_id", "uid"},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expected, jsonFieldName(c.casing, Acronyms{}, c.field, c.name), "%s with %q casing", c.field.Name, c.casing)
+	}
+}
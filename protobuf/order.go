@@ -0,0 +1,117 @@
+package protobuf
+
+import "sort"
+
+// Sort orders the imports, messages, enums, services and RPCs of the package
+// deterministically, so the file generated from it is the same on every run,
+// whatever order they were added in. Imports are sorted by path, enums,
+// services and RPCs by name, and messages come after the messages of the
+// package they have, if any, and by name otherwise. Messages that have each
+// other come after the rest, by name. The slices of the package are replaced
+// with sorted copies.
+func (p *Package) Sort() {
+	p.Imports = append([]string(nil), p.Imports...)
+	sort.Strings(p.Imports)
+
+	p.Enums = append([]*Enum(nil), p.Enums...)
+	sort.SliceStable(p.Enums, func(i, j int) bool {
+		return p.Enums[i].Name < p.Enums[j].Name
+	})
+
+	p.Services = append([]*Service(nil), p.Services...)
+	sort.SliceStable(p.Services, func(i, j int) bool {
+		return p.Services[i].Name < p.Services[j].Name
+	})
+
+	for _, svc := range p.Services {
+		rpcs := append([]*RPC(nil), svc.RPCs...)
+		sort.SliceStable(rpcs, func(i, j int) bool {
+			return rpcs[i].Name < rpcs[j].Name
+		})
+		svc.RPCs = rpcs
+	}
+
+	p.Messages = p.messagesInDependencyOrder()
+}
+
+// messagesInDependencyOrder returns the messages of the package sorted by
+// name, but with every message after the messages of the package it has.
+// When the remaining messages have each other, the first one by name is
+// taken.
+func (p *Package) messagesInDependencyOrder() []*Message {
+	msgs := append([]*Message(nil), p.Messages...)
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].Name < msgs[j].Name
+	})
+
+	local := make(map[string]bool, len(msgs))
+	for _, m := range msgs {
+		local[m.Name] = true
+	}
+
+	deps := make(map[string]map[string]bool, len(msgs))
+	for _, m := range msgs {
+		v := &namedCollector{pkg: p.Name, names: make(map[string]bool)}
+		for _, f := range m.Fields {
+			WalkType(f.Type, v)
+		}
+
+		delete(v.names, m.Name)
+		for n := range v.names {
+			if !local[n] {
+				delete(v.names, n)
+			}
+		}
+		deps[m.Name] = v.names
+	}
+
+	var (
+		result = make([]*Message, 0, len(msgs))
+		done   = make(map[string]bool, len(msgs))
+	)
+	for len(result) < len(msgs) {
+		next := -1
+		for i, m := range msgs {
+			if done[m.Name] {
+				continue
+			}
+
+			if next < 0 {
+				next = i
+			}
+
+			if ready(deps[m.Name], done) {
+				next = i
+				break
+			}
+		}
+
+		done[msgs[next].Name] = true
+		result = append(result, msgs[next])
+	}
+	return result
+}
+
+// ready reports whether all the given dependencies are done.
+func ready(deps, done map[string]bool) bool {
+	for d := range deps {
+		if !done[d] {
+			return false
+		}
+	}
+	return true
+}
+
+// namedCollector collects the names of the types of the given package that
+// the walked types have.
+type namedCollector struct {
+	BaseVisitor
+	pkg   string
+	names map[string]bool
+}
+
+func (c *namedCollector) VisitNamed(n *Named) {
+	if n.Package == "" || n.Package == c.pkg {
+		c.names[n.Name] = true
+	}
+}
@@ -0,0 +1,50 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageSort(t *testing.T) {
+	require := require.New(t)
+
+	msg := func(name string, types ...Type) *Message {
+		m := &Message{Name: name}
+		for i, typ := range types {
+			m.Fields = append(m.Fields, &Field{Name: "f", Pos: i + 1, Type: typ})
+		}
+		return m
+	}
+
+	rpcs := []*RPC{{Name: "Update"}, {Name: "Get"}}
+	pkg := &Package{
+		Name:    "foo",
+		Imports: []string{"google/protobuf/timestamp.proto", "bar/generated.proto", "github.com/gogo/protobuf/gogoproto/gogo.proto"},
+		Messages: []*Message{
+			msg("User", NewNamed("foo", "Group"), NewMap(NewBasic("string"), NewNamed("", "Tag"))),
+			msg("Tag"),
+			msg("Group", NewNamed("bar", "Zone")),
+			msg("Node", NewNamed("", "Tree")),
+			msg("Tree", NewNamed("", "Node"), NewNamed("", "Tree")),
+			msg("Account", NewAlias(NewNamed("", "UserID"), NewNamed("", "User"))),
+			msg("Zone"),
+		},
+		Enums:    []*Enum{{Name: "Status"}, {Name: "Role"}},
+		Services: []*Service{{Name: "UsersService", RPCs: rpcs}, {Name: "GroupsService"}},
+	}
+	pkg.Sort()
+
+	require.Equal([]string{"bar/generated.proto", "github.com/gogo/protobuf/gogoproto/gogo.proto", "google/protobuf/timestamp.proto"}, pkg.Imports)
+
+	var names []string
+	for _, m := range pkg.Messages {
+		names = append(names, m.Name)
+	}
+	require.Equal([]string{"Group", "Tag", "User", "Account", "Zone", "Node", "Tree"}, names, "messages that have each other come last")
+
+	require.Equal("Role", pkg.Enums[0].Name)
+	require.Equal("GroupsService", pkg.Services[0].Name)
+	require.Equal("Get", pkg.Services[1].RPCs[0].Name)
+	require.Equal("Update", rpcs[0].Name, "the slices are not sorted in place")
+}
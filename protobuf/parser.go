@@ -0,0 +1,672 @@
+package protobuf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// parsedStream is the Stream of the server-streaming RPCs parsed from .proto
+// files, as the kind of stream of their Go functions is not known.
+const parsedStream scanner.Stream = "stream"
+
+// ParseFile parses the .proto file at the given path. See Parse.
+func ParseFile(path string) (*Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse reads a .proto file and returns its representation as a Package.
+// Only the subset of the proto3 language emitted by Generator is supported,
+// which is enough to compare a previously generated file with the new model.
+// Comments are discarded, so the resulting package has no docs.
+func Parse(r io.Reader) (*Package, error) {
+	toks, err := tokenize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	pkg, err := p.parsePackage()
+	if err != nil {
+		return nil, err
+	}
+
+	pkg.DetectFeatures()
+	return pkg, nil
+}
+
+type token struct {
+	text string
+	str  bool
+	line int
+}
+
+func tokenize(r io.Reader) ([]token, error) {
+	var (
+		toks []token
+		line int
+	)
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line++
+		l := sc.Text()
+		for i := 0; i < len(l); {
+			c := rune(l[i])
+			switch {
+			case unicode.IsSpace(c):
+				i++
+			case strings.HasPrefix(l[i:], "//"):
+				i = len(l)
+			case c == '"':
+				j := i + 1
+				for j < len(l) && l[j] != '"' {
+					if l[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				if j >= len(l) {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+				s, err := strconv.Unquote(l[i : j+1])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid string: %s", line, err)
+				}
+				toks = append(toks, token{text: s, str: true, line: line})
+				i = j + 1
+			case strings.ContainsRune("{}[]()<>=;,:", c):
+				toks = append(toks, token{text: string(c), line: line})
+				i++
+			default:
+				j := i
+				for j < len(l) && isIdentChar(rune(l[j])) {
+					j++
+				}
+				if j == i {
+					return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+				}
+				toks = append(toks, token{text: l[i:j], line: line})
+				i = j
+			}
+		}
+	}
+
+	return toks, sc.Err()
+}
+
+func isIdentChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-' || r == '+'
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *parser) peek() token {
+	if p.eof() {
+		return token{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() (token, error) {
+	if p.eof() {
+		return token{}, io.ErrUnexpectedEOF
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *parser) expect(text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+
+	if t.str || t.text != text {
+		return fmt.Errorf("line %d: expected %q, found %q", t.line, text, t.text)
+	}
+	return nil
+}
+
+func (p *parser) accept(text string) bool {
+	if t := p.peek(); !t.str && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parsePackage() (*Package, error) {
+	pkg := &Package{Options: make(Options)}
+
+	for !p.eof() {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t.text {
+		case "syntax":
+			if _, err := p.parseAssignment(); err != nil {
+				return nil, err
+			}
+		case "package":
+			var name token
+			if name, err = p.next(); err == nil {
+				pkg.Name = name.text
+				err = p.expect(";")
+			}
+		case "import":
+			var path token
+			if path, err = p.next(); err == nil {
+				pkg.Imports = append(pkg.Imports, path.text)
+				err = p.expect(";")
+			}
+		case "option":
+			err = p.parseOption(pkg.Options)
+		case "message":
+			var msg *Message
+			if msg, err = p.parseMessage(); err == nil {
+				pkg.Messages = append(pkg.Messages, msg)
+			}
+		case "enum":
+			var enum *Enum
+			if enum, err = p.parseEnum(); err == nil {
+				pkg.Enums = append(pkg.Enums, enum)
+			}
+		case "service":
+			err = p.parseService(pkg)
+		default:
+			err = fmt.Errorf("line %d: unexpected %q", t.line, t.text)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pkg, nil
+}
+
+// parseAssignment parses "= value;" and returns the value.
+func (p *parser) parseAssignment() (OptionValue, error) {
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return v, p.expect(";")
+}
+
+func (p *parser) parseOptionName() (string, error) {
+	var name string
+	for {
+		t, err := p.next()
+		if err != nil {
+			return "", err
+		}
+		name += t.text
+
+		if t.text == "(" {
+			continue
+		}
+
+		if next := p.peek(); next.text != ")" && !strings.HasPrefix(next.text, ".") {
+			return name, nil
+		}
+	}
+}
+
+func (p *parser) parseValue() (OptionValue, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.str {
+		return NewStringValue(t.text), nil
+	}
+
+	if t.text == "{" {
+		return p.parseAggregate()
+	}
+
+	return NewLiteralValue(t.text), nil
+}
+
+// parseAggregate parses the fields of an aggregate value until its closing
+// brace, which is consumed. The elements of lists are added as repeated
+// fields with the same name.
+func (p *parser) parseAggregate() (OptionValue, error) {
+	var value AggregateValue
+	for !p.accept("}") {
+		if p.accept(",") || p.accept(";") {
+			continue
+		}
+
+		name, err := p.parseAggregateFieldName()
+		if err != nil {
+			return nil, err
+		}
+
+		hasColon := p.accept(":")
+		if p.accept("[") {
+			for !p.accept("]") {
+				if p.accept(",") {
+					continue
+				}
+
+				v, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				value = value.With(name, v)
+			}
+			continue
+		}
+
+		if !hasColon && p.peek().text != "{" {
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+		}
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		value = value.With(name, v)
+	}
+
+	return value, nil
+}
+
+// parseAggregateFieldName parses the name of a field of an aggregate, which
+// is either an identifier or an extension name between brackets.
+func (p *parser) parseAggregateFieldName() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+
+	if t.text != "[" {
+		return t.text, nil
+	}
+
+	name, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	return "[" + name.text + "]", p.expect("]")
+}
+
+func (p *parser) parseOption(opts Options) error {
+	name, err := p.parseOptionName()
+	if err != nil {
+		return err
+	}
+
+	v, err := p.parseAssignment()
+	if err != nil {
+		return err
+	}
+
+	opts.Add(name, v)
+	return nil
+}
+
+// parseFieldOptions parses the options between brackets, if any.
+func (p *parser) parseFieldOptions() (Options, error) {
+	opts := make(Options)
+	if !p.accept("[") {
+		return opts, nil
+	}
+
+	for {
+		name, err := p.parseOptionName()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		opts.Add(name, v)
+
+		if p.accept("]") {
+			return opts, nil
+		}
+
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseNumber() (uint, error) {
+	t, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseUint(t.text, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid number %q", t.line, t.text)
+	}
+	return uint(n), nil
+}
+
+func (p *parser) parseMessage() (*Message, error) {
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{Name: name.text, Options: make(Options)}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for !p.accept("}") {
+		switch {
+		case p.accept("option"):
+			err = p.parseOption(msg.Options)
+		case p.accept("reserved"):
+			err = p.parseReserved(msg)
+		default:
+			var f *Field
+			if f, err = p.parseField(); err == nil {
+				msg.Fields = append(msg.Fields, f)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
+
+// reservable is a message or enum, which may have reserved numbers and names.
+type reservable interface {
+	Reserve(uint)
+	ReserveName(string)
+}
+
+// parseReserved parses a reserved statement, which is either a list of
+// numbers or a list of names.
+func (p *parser) parseReserved(r reservable) error {
+	for {
+		if t := p.peek(); t.str {
+			p.pos++
+			r.ReserveName(t.text)
+		} else {
+			n, err := p.parseNumber()
+			if err != nil {
+				return err
+			}
+			r.Reserve(n)
+		}
+
+		if p.accept(";") {
+			return nil
+		}
+
+		if err := p.expect(","); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseField() (*Field, error) {
+	f := &Field{Repeated: p.accept("repeated")}
+	if !f.Repeated {
+		f.Optional = p.accept("optional")
+	}
+
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	f.Type = typ
+
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	f.Name = name.text
+
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+
+	pos, err := p.parseNumber()
+	if err != nil {
+		return nil, err
+	}
+	f.Pos = int(pos)
+
+	if f.Options, err = p.parseFieldOptions(); err != nil {
+		return nil, err
+	}
+
+	return f, p.expect(";")
+}
+
+func (p *parser) parseType() (Type, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.text != "map" {
+		return parseTypeName(t.text), nil
+	}
+
+	if err := p.expect("<"); err != nil {
+		return nil, err
+	}
+
+	k, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+
+	v, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMap(k, v), p.expect(">")
+}
+
+var basicTypes = map[string]struct{}{
+	"double": {}, "float": {}, "int32": {}, "int64": {}, "uint32": {},
+	"uint64": {}, "sint32": {}, "sint64": {}, "fixed32": {}, "fixed64": {},
+	"sfixed32": {}, "sfixed64": {}, "bool": {}, "string": {}, "bytes": {},
+}
+
+func parseTypeName(name string) Type {
+	if _, ok := basicTypes[name]; ok {
+		return NewBasic(name)
+	}
+
+	idx := strings.LastIndex(name, ".")
+	if idx < 0 {
+		return NewNamed("", name)
+	}
+	return NewNamed(name[:idx], name[idx+1:])
+}
+
+func (p *parser) parseEnum() (*Enum, error) {
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	enum := &Enum{Name: name.text, Options: make(Options)}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for !p.accept("}") {
+		if p.accept("option") {
+			if err := p.parseOption(enum.Options); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if p.accept("reserved") {
+			if err := p.parseReserved(enum); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		name, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+
+		val, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+
+		opts, err := p.parseFieldOptions()
+		if err != nil {
+			return nil, err
+		}
+
+		enum.Values = append(enum.Values, &EnumValue{
+			Name:    name.text,
+			Value:   val,
+			Options: opts,
+		})
+
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+	}
+
+	return enum, nil
+}
+
+func (p *parser) parseService(pkg *Package) error {
+	name, err := p.next()
+	if err != nil {
+		return err
+	}
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	svc := &Service{Name: name.text}
+	pkg.Services = append(pkg.Services, svc)
+
+	for !p.accept("}") {
+		if err := p.expect("rpc"); err != nil {
+			return err
+		}
+
+		name, err := p.next()
+		if err != nil {
+			return err
+		}
+
+		rpc := &RPC{Name: name.text, Options: make(Options)}
+		if rpc.Input, err = p.parseRPCType(); err != nil {
+			return err
+		}
+
+		if err := p.expect("returns"); err != nil {
+			return err
+		}
+
+		if err := p.expect("("); err != nil {
+			return err
+		}
+
+		if p.accept("stream") {
+			rpc.Stream = parsedStream
+		}
+
+		if rpc.Output, err = p.parseRPCTypeName(); err != nil {
+			return err
+		}
+
+		if p.accept("{") {
+			for !p.accept("}") {
+				if err := p.expect("option"); err != nil {
+					return err
+				}
+
+				if err := p.parseOption(rpc.Options); err != nil {
+					return err
+				}
+			}
+		} else if err := p.expect(";"); err != nil {
+			return err
+		}
+
+		svc.RPCs = append(svc.RPCs, rpc)
+	}
+
+	return nil
+}
+
+func (p *parser) parseRPCType() (Type, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	return p.parseRPCTypeName()
+}
+
+func (p *parser) parseRPCTypeName() (Type, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTypeName(t.text), p.expect(")")
+}
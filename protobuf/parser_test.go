@@ -0,0 +1,135 @@
+package protobuf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	require := require.New(t)
+
+	pkg, err := Parse(strings.NewReader(expectedProto))
+	require.Nil(err)
+
+	require.Equal("foo.bar", pkg.Name)
+	require.Equal([]string{"google/protobuf/timestamp.proto"}, pkg.Imports)
+	require.Equal(Options{"foo": NewLiteralValue("true")}, pkg.Options)
+
+	require.Len(pkg.Messages, 1)
+	msg := pkg.Messages[0]
+	require.Equal("Pony", msg.Name)
+	require.Equal([]uint{5, 6}, msg.Reserved)
+	require.Equal([]string{"weight", "height"}, msg.ReservedNames)
+	require.Equal(Options{"is_cute": NewLiteralValue("true")}, msg.Options)
+	require.Len(msg.Fields, 5)
+	for i, f := range mockMsg.Fields {
+		require.Equal(f.Name, msg.Fields[i].Name)
+		require.Equal(f.Pos, msg.Fields[i].Pos)
+		require.Equal(f.Repeated, msg.Fields[i].Repeated)
+		require.Equal(f.Optional, msg.Fields[i].Optional)
+		require.Equal(f.Type.String(), msg.Fields[i].Type.String())
+	}
+	require.Equal(mockMsg.Fields[0].Options, msg.Fields[0].Options)
+
+	require.Len(pkg.Enums, 1)
+	enum := pkg.Enums[0]
+	require.Equal("PonyRace", enum.Name)
+	require.Equal([]uint{2}, enum.Reserved)
+	require.Equal([]string{"BLUE_THUNDER"}, enum.ReservedNames)
+	require.Len(enum.Values, 2)
+	require.Equal("RED_FURY", enum.Values[1].Name)
+	require.Equal(uint(1), enum.Values[1].Value)
+	require.Equal(mockEnum.Values[1].Options, enum.Values[1].Options)
+
+	require.Len(pkg.Services, 1)
+	svc := pkg.Services[0]
+	require.Equal("BarService", svc.Name)
+	require.Len(svc.RPCs, 2)
+	require.Equal("DoBar", svc.RPCs[0].Name)
+	require.Equal("foo.bar.DoBarRequest", svc.RPCs[0].Input.String())
+	require.Equal("foo.bar.DoBarResponse", svc.RPCs[0].Output.String())
+}
+
+const protoWithMaps = `syntax = "proto3";
+package foo;
+
+message Foo {
+	option (gogoproto.typedecl) = false;
+	map<string, foo.Bar> bars = 1 [(gogoproto.nullable) = false, (gogoproto.customname) = "Bars"];
+	bytes data = 2;
+}
+`
+
+func TestParseMap(t *testing.T) {
+	require := require.New(t)
+
+	pkg, err := Parse(strings.NewReader(protoWithMaps))
+	require.Nil(err)
+	require.Len(pkg.Messages, 1)
+
+	msg := pkg.Messages[0]
+	require.Equal(NewLiteralValue("false"), msg.Options["(gogoproto.typedecl)"])
+	require.Len(msg.Fields, 2)
+	require.Equal("map<string, foo.Bar>", msg.Fields[0].Type.String())
+	require.Equal(Options{
+		"(gogoproto.nullable)":   NewLiteralValue("false"),
+		"(gogoproto.customname)": NewStringValue("Bars"),
+	}, msg.Fields[0].Options)
+	require.Equal(NewBasic("bytes"), msg.Fields[1].Type)
+}
+
+const protoWithAggregates = `syntax = "proto3";
+package foo;
+
+service FooService {
+	rpc GetFoo (foo.GetFooRequest) returns (foo.Foo) {
+		option (google.api.http) = { get: "/v1/foos/{id}", additional_bindings { get: "/v2/foos/{id}" } };
+		option (foo.rules) = { in: ["a", "b"]; [foo.ext]: -1 };
+		option (google.api.method_signature) = "id";
+		option (google.api.method_signature) = "id,name";
+	}
+}
+`
+
+func TestParseAggregate(t *testing.T) {
+	require := require.New(t)
+
+	pkg, err := Parse(strings.NewReader(protoWithAggregates))
+	require.Nil(err)
+	require.Len(pkg.Services, 1)
+
+	opts := pkg.Services[0].RPCs[0].Options
+	require.Equal(NewAggregateValue(
+		AggregateField{"get", NewStringValue("/v1/foos/{id}")},
+		AggregateField{"additional_bindings", NewAggregateValue(
+			AggregateField{"get", NewStringValue("/v2/foos/{id}")},
+		)},
+	), opts["(google.api.http)"])
+	require.Equal(NewAggregateValue(
+		AggregateField{"in", NewStringValue("a")},
+		AggregateField{"in", NewStringValue("b")},
+		AggregateField{"[foo.ext]", NewLiteralValue("-1")},
+	), opts["(foo.rules)"])
+	require.Equal(NewRepeatedValue(
+		NewStringValue("id"),
+		NewStringValue("id,name"),
+	), opts["(google.api.method_signature)"])
+}
+
+func TestParseError(t *testing.T) {
+	cases := []string{
+		`package foo`,
+		`message Foo { string foo = bar; }`,
+		`message Foo { string foo = 1 [foo = "bar"; }`,
+		`enum Foo { FOO = 0 }`,
+		`option foo = "bar;`,
+		`foo bar;`,
+	}
+
+	for _, c := range cases {
+		_, err := Parse(strings.NewReader(c))
+		require.NotNil(t, err, c)
+	}
+}
@@ -0,0 +1,162 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// FieldProfile holds how often the fields of messages are set, indexed by the
+// full name of their struct, like "github.com/foo/bar.User", and the Go name
+// of the field. Frequencies can be ratios or counts, as only their order is
+// taken into account. Fields that are not in the profile are never set.
+type FieldProfile map[string]map[string]float64
+
+// LoadFieldProfile reads the field profile in the JSON file at the given path.
+func LoadFieldProfile(path string) (FieldProfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile FieldProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("invalid field profile %q: %s", path, err)
+	}
+
+	for msg, fields := range profile {
+		for f, freq := range fields {
+			if freq < 0 {
+				return nil, fmt.Errorf("invalid field profile %q: field %s of %s has a negative frequency", path, f, msg)
+			}
+		}
+	}
+	return profile, nil
+}
+
+// fieldOrder returns the indexes of the fields of the struct in the order
+// they are numbered, following their frequencies. Small fields that are set
+// go first, as tags 1 to 15 take a single byte and they are the ones that
+// save more in proportion, followed by the rest of the fields that are set,
+// the most frequent first. Fields that are never set keep their order last.
+func (t *Transformer) fieldOrder(s *scanner.Struct, freqs map[string]float64) []int {
+	var order = make([]int, len(s.Fields))
+	for i := range order {
+		order[i] = i
+	}
+
+	rank := func(f *scanner.Field) int {
+		switch {
+		case freqs[f.Name] <= 0:
+			return 2
+		case f.Type != nil && t.isSmallType(f.Type):
+			return 0
+		default:
+			return 1
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := s.Fields[order[i]], s.Fields[order[j]]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		return freqs[a.Name] > freqs[b.Name]
+	})
+	return order
+}
+
+// isSmallType reports whether the type is encoded in a few bytes, that is,
+// it is a number, a boolean or an enum that is not repeated.
+func (t *Transformer) isSmallType(typ scanner.Type) bool {
+	if typ.IsRepeated() {
+		return false
+	}
+
+	switch ty := typ.(type) {
+	case *scanner.Basic:
+		return ty.Name != "string"
+	case *scanner.Named:
+		return t.IsEnum(ty.Path, ty.Name)
+	case *scanner.Alias:
+		return t.isSmallType(ty.Underlying)
+	}
+	return false
+}
+
+// KeepProfiledNumbers makes the messages of the current version of a package
+// that were numbered following a field profile keep the numbers their fields
+// had in the previous version, so they are only ordered by the profile the
+// first time they are generated and a different profile later does not break
+// them. Fields added since then take the lowest numbers that were not used
+// nor reserved, and fields with an explicit id always keep it. The numbers of
+// the fields removed since then are reserved.
+func KeepProfiledNumbers(prev, curr *Package) {
+	for _, msg := range curr.Messages {
+		if !msg.profiled {
+			continue
+		}
+
+		if old := prev.findMessage(msg.Name); old != nil {
+			keepNumbers(old, msg)
+		}
+	}
+}
+
+// keepNumbers numbers the fields of msg as they were in old. The numbers in
+// the current reserved list of msg are not taken into account, as they were
+// given following the profile, so the list is computed again from the
+// numbers old used or reserved that are not used anymore.
+func keepNumbers(old, msg *Message) {
+	var used = make(map[int]bool)
+	for _, f := range msg.Fields {
+		if !f.autoNumbered {
+			used[f.Pos] = true
+		}
+	}
+
+	var added []*Field
+	for _, f := range msg.Fields {
+		if !f.autoNumbered {
+			continue
+		}
+
+		if prev := old.fieldByName(f.Name); prev != nil && !used[prev.Pos] {
+			f.Pos = prev.Pos
+			used[f.Pos] = true
+		} else {
+			added = append(added, f)
+		}
+	}
+
+	var taken = make(map[int]bool)
+	for _, n := range old.Reserved {
+		taken[int(n)] = true
+	}
+
+	for _, f := range old.Fields {
+		taken[f.Pos] = true
+	}
+
+	next := 1
+	for _, f := range added {
+		for taken[next] || used[next] {
+			next++
+		}
+		f.Pos = next
+		used[next] = true
+	}
+
+	msg.Reserved = nil
+	for n := range taken {
+		if !used[n] {
+			msg.Reserved = append(msg.Reserved, uint(n))
+		}
+	}
+	sort.Slice(msg.Reserved, func(i, j int) bool {
+		return msg.Reserved[i] < msg.Reserved[j]
+	})
+}
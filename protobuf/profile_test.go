@@ -0,0 +1,206 @@
+package protobuf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestLoadFieldProfile(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-profile")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "profile.json")
+	require.Nil(ioutil.WriteFile(file, []byte(`{"foo.User": {"ID": 0.9, "Bio": 0.1}}`), 0644))
+
+	profile, err := LoadFieldProfile(file)
+	require.Nil(err)
+	require.Equal(FieldProfile{"foo.User": {"ID": 0.9, "Bio": 0.1}}, profile)
+
+	require.Nil(ioutil.WriteFile(file, []byte(`{"foo.User": {"ID": -1}}`), 0644))
+	_, err = LoadFieldProfile(file)
+	require.NotNil(err, "negative frequency")
+
+	require.Nil(ioutil.WriteFile(file, []byte(`["foo.User"]`), 0644))
+	_, err = LoadFieldProfile(file)
+	require.NotNil(err, "invalid format")
+
+	_, err = LoadFieldProfile(filepath.Join(dir, "missing.json"))
+	require.True(os.IsNotExist(err))
+}
+
+func TestTransformFieldProfile(t *testing.T) {
+	require := require.New(t)
+
+	status := scanner.NewNamed("foo", "Status")
+	p := &scanner.Package{
+		Path: "foo",
+		Name: "foo",
+		Structs: []*scanner.Struct{
+			{
+				Name: "User",
+				Fields: []*scanner.Field{
+					{Name: "Bio", Type: scanner.NewBasic("string")},
+					{Name: "Avatar", Type: scanner.NewBasic("string")},
+					{Name: "Extra", Type: scanner.NewBasic("int64"), ProtoID: 2},
+					{Name: "Status", Type: status},
+					{Name: "Age", Type: scanner.NewBasic("int32")},
+					{Name: "ID", Type: scanner.NewBasic("int64")},
+				},
+			},
+			{
+				Name: "Group",
+				Fields: []*scanner.Field{
+					{Name: "Name", Type: scanner.NewBasic("string")},
+					{Name: "ID", Type: scanner.NewBasic("int64")},
+				},
+			},
+		},
+	}
+
+	tr := NewTransformer()
+	enums := NewTypeSet()
+	enums.Add("foo", "Status")
+	tr.SetEnumSet(enums)
+	tr.SetFieldProfile(FieldProfile{
+		"foo.User": {"Bio": 0.5, "Status": 0.2, "ID": 1, "Avatar": 0},
+	})
+
+	pkg := tr.Transform(p)
+	require.Equal(map[string]int{
+		"id":     1,
+		"extra":  2,
+		"status": 3,
+		"bio":    4,
+		"avatar": 5,
+		"age":    6,
+	}, fieldPositionsByName(pkg.Messages[0]))
+	require.True(pkg.Messages[0].profiled)
+
+	require.Equal(map[string]int{"name": 1, "id": 2}, fieldPositionsByName(pkg.Messages[1]), "messages not in the profile keep their order")
+	require.False(pkg.Messages[1].profiled)
+}
+
+func TestKeepProfiledNumbers(t *testing.T) {
+	require := require.New(t)
+
+	prev := &Package{
+		Messages: []*Message{
+			{
+				Name:     "User",
+				Reserved: []uint{5},
+				Fields: []*Field{
+					{Name: "id", Pos: 1},
+					{Name: "bio", Pos: 2},
+					{Name: "age", Pos: 3},
+					{Name: "deleted", Pos: 4},
+				},
+			},
+			{
+				Name: "Group",
+				Fields: []*Field{
+					{Name: "id", Pos: 2},
+				},
+			},
+		},
+	}
+
+	curr := &Package{
+		Messages: []*Message{
+			{
+				Name:     "User",
+				profiled: true,
+				Fields: []*Field{
+					{Name: "age", Pos: 1, autoNumbered: true},
+					{Name: "name", Pos: 2, autoNumbered: true},
+					{Name: "id", Pos: 3, autoNumbered: true},
+					{Name: "extra", Pos: 7},
+					{Name: "bio", Pos: 4, autoNumbered: true},
+				},
+			},
+			{
+				Name:     "Account",
+				profiled: true,
+				Fields: []*Field{
+					{Name: "id", Pos: 1, autoNumbered: true},
+				},
+			},
+			{
+				Name: "Group",
+				Fields: []*Field{
+					{Name: "id", Pos: 1, autoNumbered: true},
+				},
+			},
+		},
+	}
+
+	KeepProfiledNumbers(prev, curr)
+	require.Equal(map[string]int{
+		"id":    1,
+		"bio":   2,
+		"age":   3,
+		"name":  6,
+		"extra": 7,
+	}, fieldPositionsByName(curr.Messages[0]))
+	require.Equal([]uint{4, 5}, curr.Messages[0].Reserved, "removed fields are reserved")
+	require.Equal(map[string]int{"id": 1}, fieldPositionsByName(curr.Messages[1]), "new messages keep their numbers")
+	require.Equal(map[string]int{"id": 1}, fieldPositionsByName(curr.Messages[2]), "messages not profiled keep their numbers")
+}
+
+func TestKeepProfiledNumbersRemovedField(t *testing.T) {
+	require := require.New(t)
+
+	prev := &Package{
+		Messages: []*Message{
+			{
+				Name: "User",
+				Fields: []*Field{
+					{Name: "id", Pos: 1},
+					{Name: "name", Pos: 2},
+					{Name: "email", Pos: 3},
+				},
+			},
+		},
+	}
+
+	// name was removed and the profile now puts email first, so the
+	// transformer numbered the fields and reserved a placeholder taking the
+	// numbers of the previous version.
+	curr := &Package{
+		Messages: []*Message{
+			{
+				Name:     "User",
+				profiled: true,
+				Reserved: []uint{3},
+				Fields: []*Field{
+					{Name: "email", Pos: 1, autoNumbered: true},
+					{Name: "id", Pos: 2, autoNumbered: true},
+					{Name: "bio", Pos: 4, autoNumbered: true},
+				},
+			},
+		},
+	}
+
+	KeepProfiledNumbers(prev, curr)
+	require.Equal(map[string]int{
+		"id":    1,
+		"email": 3,
+		"bio":   4,
+	}, fieldPositionsByName(curr.Messages[0]))
+	require.Equal([]uint{2}, curr.Messages[0].Reserved)
+}
+
+func fieldPositionsByName(msg *Message) map[string]int {
+	var positions = make(map[string]int)
+	for _, f := range msg.Fields {
+		positions[f.Name] = f.Pos
+	}
+	return positions
+}
@@ -2,10 +2,13 @@ package protobuf // import "gitlab.com/ThatTomPerson/proteus/protobuf"
 
 import (
 	"fmt"
-	"path/filepath"
+	gotoken "go/token"
+	"regexp"
 	"sort"
 	"strings"
 
+	"github.com/gogo/protobuf/protoc-gen-gogo/generator"
+
 	"gitlab.com/ThatTomPerson/proteus/scanner"
 )
 
@@ -17,60 +20,190 @@ type Package struct {
 	Options  Options
 	Messages []*Message
 	Enums    []*Enum
-	RPCs     []*RPC
+	Services []*Service
+	// Features are the protobuf features used by the messages and services
+	// of the package.
+	Features Features
+
+	// typeImports are the files imported for a single type, indexed by the
+	// full name of the type.
+	typeImports map[string]string
+	// pkgImports are the files imported for all the types of a package,
+	// indexed by the name of the package.
+	pkgImports map[string]string
+	// fieldNaming is the naming strategy of the fields of the messages of
+	// the package whose structs do not have one.
+	fieldNaming FieldNaming
+	// fileLayout is the layout of the files imported from other packages.
+	fileLayout FileLayout
+	// pkgTemplate is the template of the packages of the imported files.
+	pkgTemplate PackageTemplate
+	// pkgNames are the packages of the imported files that do not follow
+	// the template.
+	pkgNames PackageNames
+	// file is the name of the file of the package, in the folder given by
+	// its layout, if it is one of the files it was split into.
+	file string
 }
 
 // Import tries to import the given protobuf type to the current package.
 // If the type requires no import at all, nothing will be done.
 func (p *Package) Import(typ *ProtoType) {
-	if typ.Import != "" && !p.isImported(typ.Import) {
-		p.Imports = append(p.Imports, typ.Import)
+	if typ.Import == "" {
+		return
+	}
+
+	if p.typeImports == nil {
+		p.typeImports = make(map[string]string)
+	}
+	file := normalizeImport(typ.Import)
+	p.typeImports[typ.Type().String()] = file
+
+	if !p.isImported(file) {
+		p.Imports = append(p.Imports, file)
 	}
 }
 
 // ImportFromPath adds a new import from a Go path.
 func (p *Package) ImportFromPath(path string) {
-	file := filepath.Join(path, "generated.proto")
-	if path != p.Path && !p.isImported(file) {
-		p.Imports = append(p.Imports, filepath.Join(path, "generated.proto"))
+	if path != p.Path {
+		pkg := p.pkgNames.Package(path, p.pkgTemplate)
+		p.importPackage(p.fileLayout.File(path, pkg), pkg)
+	}
+}
+
+// importPackage adds a new import of the file that defines the given
+// package.
+func (p *Package) importPackage(file, pkg string) {
+	if p.pkgImports == nil {
+		p.pkgImports = make(map[string]string)
+	}
+	file = normalizeImport(file)
+	p.pkgImports[pkg] = file
+
+	if !p.isImported(file) {
+		p.Imports = append(p.Imports, file)
 	}
 }
 
+// isImported reports whether the package already imports the given file,
+// with the same path as the one given by importKey.
 func (p *Package) isImported(file string) bool {
+	key := importKey(file)
 	for _, i := range p.Imports {
-		if i == file {
+		if importKey(i) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRPCs reports whether any of the services of the package has RPCs.
+func (p *Package) HasRPCs() bool {
+	for _, s := range p.Services {
+		if len(s.RPCs) > 0 {
 			return true
 		}
 	}
 	return false
 }
 
-// ServiceName returns the service name of the package.
+var versionExpr = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]*)?$`)
+
+// IsVersioned reports whether the last element of the package is a version,
+// like v1 or v2beta1.
+func (p *Package) IsVersioned() bool {
+	parts := strings.Split(p.Name, ".")
+	return versionExpr.MatchString(parts[len(parts)-1])
+}
+
+// ServiceName returns the name of the default service of the package, which
+// holds the RPCs of all the functions of the package. It is named after the
+// last element of the package that is not a version, like v1 or v2beta1, so
+// the service of acme.users.v1 is UsersService.
 func (p *Package) ServiceName() string {
 	parts := strings.Split(p.Name, ".")
 	last := parts[len(parts)-1]
+	for i := len(parts) - 1; i >= 0; i-- {
+		if !versionExpr.MatchString(parts[i]) {
+			last = parts[i]
+			break
+		}
+	}
 	return strings.ToUpper(string(last[0])) + last[1:] + "Service"
 }
 
+// service returns the service of the package with the given name. If there
+// is no such service, it is added to the package.
+func (p *Package) service(name string) *Service {
+	for _, s := range p.Services {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	svc := &Service{Name: name}
+	p.Services = append(p.Services, svc)
+	return svc
+}
+
+// Service is the representation of a protobuf service.
+type Service struct {
+	Docs []string
+	Name string
+	RPCs []*RPC
+}
+
 // Message is the representation of a Protobuf message.
 type Message struct {
 	Docs     []string
 	Name     string
 	Reserved []uint
-	Options  Options
-	Fields   []*Field
+	// ReservedNames are the field names that can not be used anymore.
+	ReservedNames []string
+	Options       Options
+	Fields        []*Field
+	// Position is the position of the Go struct of the message in its
+	// source file, which is not valid if it has none.
+	Position gotoken.Position
+
+	// profiled reports whether the fields were numbered following a field
+	// profile.
+	profiled bool
+	// fieldNaming is the naming strategy of the fields.
+	fieldNaming FieldNaming
+	// jsonCasing is the casing of the names of the fields in JSON.
+	jsonCasing JSONCasing
+	// goFile is the name of the Go source file of the struct, if any.
+	goFile string
 }
 
 // Reserve reserves a position in the message.
 func (m *Message) Reserve(pos uint) {
-	if !m.isReserved(pos) {
+	if !containsUint(m.Reserved, pos) {
 		m.Reserved = append(m.Reserved, pos)
 	}
 }
 
-func (m *Message) isReserved(pos uint) bool {
-	for _, r := range m.Reserved {
-		if r == pos {
+// ReserveName reserves a field name in the message.
+func (m *Message) ReserveName(name string) {
+	if !containsString(m.ReservedNames, name) {
+		m.ReservedNames = append(m.ReservedNames, name)
+	}
+}
+
+func containsUint(list []uint, n uint) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
 			return true
 		}
 	}
@@ -83,8 +216,53 @@ type Field struct {
 	Name     string
 	Pos      int
 	Repeated bool
+	// Optional fields have explicit presence, that is, they can be told
+	// apart from fields set to their zero value.
+	Optional bool
 	Type     Type
 	Options  Options
+	// Position is the position of the Go field in its source file, which
+	// is not valid if it has none. Pos is the number of the field.
+	Position gotoken.Position
+
+	// autoNumbered reports whether the number of the field was not given
+	// explicitly.
+	autoNumbered bool
+}
+
+// JSONName returns the name of the field in JSON, which is the one given with
+// the json_name option or the one protobuf gives to its name.
+func (f *Field) JSONName() string {
+	if v, ok := f.Options["json_name"].(StringValue); ok {
+		return v.val
+	}
+	return jsonName(f.Name)
+}
+
+// GoName returns the name of the field in the Go code generated by gogo,
+// which is the one given with the (gogoproto.customname) option or the one
+// gogo gives to its name.
+func (f *Field) GoName() string {
+	if v, ok := f.Options["(gogoproto.customname)"].(StringValue); ok {
+		return v.val
+	}
+	return generator.CamelCase(f.Name)
+}
+
+// Unit returns the unit of the values of the field given with the
+// (proteus.unit) option, if any.
+func (f *Field) Unit() string {
+	if v, ok := f.Options[unitOption].(StringValue); ok {
+		return v.val
+	}
+	return ""
+}
+
+// Sensitive reports whether the field has the (proteus.sensitive) option,
+// so its values are redacted in the logs.
+func (f *Field) Sensitive() bool {
+	v, ok := f.Options[sensitiveOption].(LiteralValue)
+	return ok && v.String() == "true"
 }
 
 // Options are the set of options given to a field, message or enum value.
@@ -96,7 +274,8 @@ type Option struct {
 	Value OptionValue
 }
 
-// Sorted returns a sorted set of options.
+// Sorted returns a sorted set of options. Options with repeated values are
+// returned once per value, in the order the values were given.
 func (o Options) Sorted() []*Option {
 	var names = make([]string, 0, len(o))
 	for k := range o {
@@ -104,16 +283,36 @@ func (o Options) Sorted() []*Option {
 	}
 
 	sort.Stable(sort.StringSlice(names))
-	var opts = make([]*Option, len(o))
-	for i, n := range names {
-		opts[i] = &Option{Name: n, Value: o[n]}
+	var opts = make([]*Option, 0, len(o))
+	for _, n := range names {
+		if r, ok := o[n].(RepeatedValue); ok {
+			for _, v := range r.values {
+				opts = append(opts, &Option{Name: n, Value: v})
+			}
+		} else {
+			opts = append(opts, &Option{Name: n, Value: o[n]})
+		}
 	}
 
 	return opts
 }
 
+// Add adds a value to the option with the given name. If the option already
+// has a value, both are kept as a repeated value.
+func (o Options) Add(name string, v OptionValue) {
+	switch prev := o[name].(type) {
+	case nil:
+		o[name] = v
+	case RepeatedValue:
+		o[name] = prev.With(v)
+	default:
+		o[name] = NewRepeatedValue(prev, v)
+	}
+}
+
 // OptionValue is the common interface for the value of an option, which can be
-// a literal value (a number, true, etc) or a string value ("foo").
+// a literal value (a number, true, etc), a string value ("foo"), an
+// aggregate value ({ foo: "bar" }) or a repeated value.
 type OptionValue interface {
 	fmt.Stringer
 	isOptionValue()
@@ -149,6 +348,86 @@ func (v StringValue) String() string {
 	return fmt.Sprintf("%q", v.val)
 }
 
+// AggregateField is a single name and value pair of an aggregate value.
+type AggregateField struct {
+	Name  string
+	Value OptionValue
+}
+
+// AggregateValue is a message literal option value, such as
+// `{ get: "/v1/users" body: "*" }`. Its fields are kept in the order they
+// were given and their values can be aggregates themselves. The same name can
+// be given more than once for repeated fields.
+type AggregateValue struct {
+	fields []AggregateField
+}
+
+// NewAggregateValue creates a new aggregate option value with the given
+// fields.
+func NewAggregateValue(fields ...AggregateField) AggregateValue {
+	return AggregateValue{fields}
+}
+
+// With returns a copy of the aggregate value with a new field added at the
+// end.
+func (v AggregateValue) With(name string, val OptionValue) AggregateValue {
+	fields := make([]AggregateField, len(v.fields), len(v.fields)+1)
+	copy(fields, v.fields)
+	return AggregateValue{append(fields, AggregateField{name, val})}
+}
+
+// Fields returns the fields of the aggregate value in order.
+func (v AggregateValue) Fields() []AggregateField {
+	return v.fields
+}
+
+func (AggregateValue) isOptionValue() {}
+func (v AggregateValue) String() string {
+	if len(v.fields) == 0 {
+		return "{}"
+	}
+
+	var fields = make([]string, len(v.fields))
+	for i, f := range v.fields {
+		fields[i] = fmt.Sprintf("%s: %s", f.Name, f.Value)
+	}
+	return fmt.Sprintf("{ %s }", strings.Join(fields, ", "))
+}
+
+// RepeatedValue is the value of an option that is given more than once, such
+// as `(google.api.method_signature)`. It is written as an option per value,
+// in order, or as a list inside aggregate values.
+type RepeatedValue struct {
+	values []OptionValue
+}
+
+// NewRepeatedValue creates a new repeated option value with the given values.
+func NewRepeatedValue(values ...OptionValue) RepeatedValue {
+	return RepeatedValue{values}
+}
+
+// With returns a copy of the repeated value with a new value added at the
+// end.
+func (v RepeatedValue) With(val OptionValue) RepeatedValue {
+	values := make([]OptionValue, len(v.values), len(v.values)+1)
+	copy(values, v.values)
+	return RepeatedValue{append(values, val)}
+}
+
+// Values returns the values in order.
+func (v RepeatedValue) Values() []OptionValue {
+	return v.values
+}
+
+func (RepeatedValue) isOptionValue() {}
+func (v RepeatedValue) String() string {
+	var values = make([]string, len(v.values))
+	for i, val := range v.values {
+		values[i] = val.String()
+	}
+	return fmt.Sprintf("[%s]", strings.Join(values, ", "))
+}
+
 // Type is the common interface of all possible types, which are named types,
 // maps and basic types.
 type Type interface {
@@ -309,10 +588,34 @@ func (*Alias) isType() {}
 
 // Enum is the representation of a protobuf enumeration.
 type Enum struct {
-	Docs    []string
-	Name    string
-	Options Options
-	Values  []*EnumValue
+	Docs []string
+	Name string
+	// Reserved are the values that can not be used anymore.
+	Reserved []uint
+	// ReservedNames are the value names that can not be used anymore.
+	ReservedNames []string
+	Options       Options
+	Values        []*EnumValue
+	// Position is the position of the Go type of the enum in its source
+	// file, which is not valid if it has none.
+	Position gotoken.Position
+
+	// goFile is the name of the Go source file of the enum, if any.
+	goFile string
+}
+
+// Reserve reserves a value in the enum.
+func (e *Enum) Reserve(val uint) {
+	if !containsUint(e.Reserved, val) {
+		e.Reserved = append(e.Reserved, val)
+	}
+}
+
+// ReserveName reserves a value name in the enum.
+func (e *Enum) ReserveName(name string) {
+	if !containsString(e.ReservedNames, name) {
+		e.ReservedNames = append(e.ReservedNames, name)
+	}
 }
 
 // EnumValue is a single value in an enumeration.
@@ -340,4 +643,45 @@ type RPC struct {
 	Input      Type
 	Output     Type
 	Options    Options
+	// HTTP is the HTTP mapping of the RPC, which is also written as its
+	// google.api.http option. Nil if it has none.
+	HTTP *scanner.HTTPRule
+	// MaxConcurrency is the maximum number of concurrent calls allowed by
+	// the generated server. Zero if there is no limit.
+	MaxConcurrency int
+	// Idempotent reports whether the RPC can be called more than once with
+	// the same request, which is also written as its idempotency_level
+	// option, so the generated clients can hedge its calls.
+	Idempotent bool
+	// FuncOptions are the functional options of the Go function sent in the
+	// request, whose fields are the last ones of the request, in order.
+	FuncOptions []*FuncOption
+	// Stream is the kind of stream of values the Go function returns, if
+	// any, in which case the RPC is server-streaming and Output is the
+	// message of the values.
+	Stream scanner.Stream
+	// StreamBuffer is the buffering of the values of the stream between the
+	// Go function and the client in the generated server.
+	StreamBuffer scanner.StreamBuffer
+	// Position is the position of the Go function in its source file, which
+	// is not valid if it has none.
+	Position gotoken.Position
+}
+
+// IsStreaming reports whether the RPC is server-streaming.
+func (r *RPC) IsStreaming() bool {
+	return r.Stream != scanner.NoStream
+}
+
+// FuncOption is a functional option of a Go function that is sent as an
+// optional field of the request of its RPC.
+type FuncOption struct {
+	// Func is the name of the Go func that creates the option.
+	Func string
+	// Field is the Go name of the field of the request.
+	Field string
+	// Implicit reports whether the field is a basic type or an enum that is
+	// not optional, which has no presence, so the option is only passed if
+	// the field does not have its zero value.
+	Implicit bool
 }
@@ -15,6 +15,18 @@ func TestReserve(t *testing.T) {
 	require.Equal(t, []uint{1}, msg.Reserved)
 }
 
+func TestFieldJSONName(t *testing.T) {
+	require.Equal(t, "userId", (&Field{Name: "user_id"}).JSONName())
+	f := &Field{Name: "user_id", Options: Options{"json_name": NewStringValue("user_id")}}
+	require.Equal(t, "user_id", f.JSONName())
+}
+
+func TestFieldGoName(t *testing.T) {
+	require.Equal(t, "UserId", (&Field{Name: "user_id"}).GoName())
+	f := &Field{Name: "user_id", Options: Options{"(gogoproto.customname)": NewStringValue("UserID")}}
+	require.Equal(t, "UserID", f.GoName())
+}
+
 func TestImport(t *testing.T) {
 	pkg := new(Package)
 	require := require.New(t)
@@ -41,6 +53,21 @@ func TestImportFromPath(t *testing.T) {
 	require.Equal("bar/generated.proto", pkg.Imports[0])
 }
 
+func TestServiceName(t *testing.T) {
+	cases := map[string]string{
+		"foo":                  "FooService",
+		"gitlab.com.foo.users": "UsersService",
+		"acme.users.v1":        "UsersService",
+		"acme.users.v2beta1":   "UsersService",
+		"acme.v1":              "AcmeService",
+		"v1":                   "V1Service",
+	}
+
+	for name, expected := range cases {
+		require.Equal(t, expected, (&Package{Name: name}).ServiceName(), name)
+	}
+}
+
 func TestTypesString(t *testing.T) {
 	require.Equal(t, "int32", NewBasic("int32").String())
 	require.Equal(t, "foo.Bar", NewNamed("foo", "Bar").String())
@@ -108,3 +135,45 @@ func TestIsNullable(t *testing.T) {
 	typ = NewMap(notNullableType, notNullableType)
 	require.False(t, typ.IsNullable(), "map<notNullable>NotNullable is not nullable")
 }
+
+func TestAggregateValue(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("{}", NewAggregateValue().String())
+
+	base := NewAggregateValue(AggregateField{"a", NewLiteralValue("1")})
+	v := base.With("b", NewStringValue("c")).
+		With("d", NewAggregateValue().With("e", NewLiteralValue("true")))
+	require.Equal(`{ a: 1, b: "c", d: { e: true } }`, v.String())
+	require.Len(v.Fields(), 3)
+	require.Len(base.Fields(), 1, "With must not modify the original value")
+}
+
+func TestOptionsAdd(t *testing.T) {
+	require := require.New(t)
+
+	opts := make(Options)
+	opts.Add("foo", NewLiteralValue("true"))
+	require.Equal(NewLiteralValue("true"), opts["foo"])
+
+	opts.Add("(google.api.method_signature)", NewStringValue("b"))
+	opts.Add("(google.api.method_signature)", NewStringValue("a"))
+	opts.Add("(google.api.method_signature)", NewStringValue("c"))
+	require.Equal(NewRepeatedValue(
+		NewStringValue("b"),
+		NewStringValue("a"),
+		NewStringValue("c"),
+	), opts["(google.api.method_signature)"])
+	require.Equal(`["b", "a", "c"]`, opts["(google.api.method_signature)"].String())
+
+	var sorted []string
+	for _, o := range opts.Sorted() {
+		sorted = append(sorted, o.Name+"="+o.Value.String())
+	}
+	require.Equal([]string{
+		`(google.api.method_signature)="b"`,
+		`(google.api.method_signature)="a"`,
+		`(google.api.method_signature)="c"`,
+		"foo=true",
+	}, sorted)
+}
@@ -0,0 +1,325 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// Elements the rules are applied to, which are the value of the element
+// variable of their expressions.
+const (
+	messageElement = "message"
+	enumElement    = "enum"
+	fieldElement   = "field"
+)
+
+// scalarGoTypes are the Go types of the protobuf scalars fields can be
+// retyped to. Fields whose Go type is not the one of their new type are cast
+// to it by gogoproto.
+var scalarGoTypes = map[string]string{
+	"double":   "float64",
+	"float":    "float32",
+	"int32":    "int32",
+	"sint32":   "int32",
+	"sfixed32": "int32",
+	"int64":    "int64",
+	"sint64":   "int64",
+	"sfixed64": "int64",
+	"uint32":   "uint32",
+	"fixed32":  "uint32",
+	"uint64":   "uint64",
+	"fixed64":  "uint64",
+	"bool":     "bool",
+	"string":   "string",
+}
+
+// Rule is a transformation rule applied to the messages, enums and fields
+// whose attributes match its CEL expression, as a declarative way to follow
+// conventions across packages.
+//
+// Expressions have the following variables:
+//   - element: "message", "enum" or "field".
+//   - pkg: the path and the protobuf name of the package, e.g. pkg.path.
+//   - typ: the name, kind ("message" or "enum"), file, doc and deprecated of
+//     the message or enum, or the message of the field.
+//   - field: the name, goType, protoName, jsonName, repeated, doc and
+//     deprecated of the field, which is empty for messages and enums.
+type Rule struct {
+	// Match is the expression telling whether the rule applies to an
+	// element, e.g. `element == "field" && field.goType == "time.Time"`.
+	Match string `json:"match"`
+	// Options are set to the matching elements, overriding the ones they
+	// would have. Their values are written like the ones of FileOptions.
+	Options map[string]string `json:"options,omitempty"`
+	// Rename is an expression returning the name in protobuf of the
+	// matching fields, e.g. `field.protoName + "_id"`.
+	Rename string `json:"rename,omitempty"`
+	// Retype is the protobuf scalar type of the matching fields, which must
+	// have a basic Go type that gogoproto can cast to it.
+	Retype string `json:"retype,omitempty"`
+	// Skip leaves the matching elements out of the generated files, without
+	// reserving the numbers of fields.
+	Skip bool `json:"skip,omitempty"`
+
+	match   cel.Program
+	rename  cel.Program
+	options Options
+}
+
+// Rules are the transformation rules applied to the scanned packages, in
+// order, so the later ones override what the former ones set.
+type Rules []*Rule
+
+// LoadRules reads the rules in the JSON file at the given path, which is a
+// list of rules, and compiles their expressions.
+func LoadRules(path string) (Rules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules %q: %s", path, err)
+	}
+
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("invalid rules %q: %s", path, err)
+	}
+	return rules, nil
+}
+
+func (rs Rules) compile() error {
+	env, err := cel.NewEnv(
+		cel.Variable("element", cel.StringType),
+		cel.Variable("pkg", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("typ", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("field", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range rs {
+		if err := r.compile(env); err != nil {
+			return fmt.Errorf("rule %d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) compile(env *cel.Env) error {
+	if r.Match == "" {
+		return fmt.Errorf("the match expression can not be empty")
+	}
+
+	if len(r.Options) == 0 && r.Rename == "" && r.Retype == "" && !r.Skip {
+		return fmt.Errorf("it must set options, rename, retype or skip")
+	}
+
+	var err error
+	if r.match, err = compileExpr(env, r.Match, cel.BoolType); err != nil {
+		return fmt.Errorf("invalid match expression: %s", err)
+	}
+
+	if r.Rename != "" {
+		if r.rename, err = compileExpr(env, r.Rename, cel.StringType); err != nil {
+			return fmt.Errorf("invalid rename expression: %s", err)
+		}
+	}
+
+	if _, ok := scalarGoTypes[r.Retype]; r.Retype != "" && !ok {
+		return fmt.Errorf("invalid type %q, only fields of basic types can be retyped to a scalar other than bytes", r.Retype)
+	}
+
+	r.options = make(Options)
+	for name, v := range r.Options {
+		if !fileOptionNameExpr.MatchString(name) {
+			return fmt.Errorf("invalid option %q, the name must be a standard option or a gogoproto one", name)
+		}
+
+		value := fileOptionValue(name, v)
+		if value == nil {
+			return fmt.Errorf("invalid option %q, the value can not be empty", name)
+		}
+		r.options[name] = value
+	}
+	return nil
+}
+
+// compileExpr compiles the given expression, which must return a value of
+// the given type.
+func compileExpr(env *cel.Env, expr string, typ *cel.Type) (cel.Program, error) {
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+
+	if !ast.OutputType().IsExactType(typ) {
+		return nil, fmt.Errorf("it returns %s instead of %s", ast.OutputType(), typ)
+	}
+	return env.Program(ast)
+}
+
+// ruleResult is what the rules matching an element do with it.
+type ruleResult struct {
+	skip    bool
+	options Options
+	rename  string
+	retype  string
+}
+
+// setOptions sets the options given by the rules in the given ones.
+func (r *ruleResult) setOptions(opts Options) {
+	for name, v := range r.options {
+		opts[name] = v
+	}
+}
+
+// apply evaluates the rules for the element with the given variables and
+// returns what the ones that match do with it. Rules that can not be
+// evaluated, e.g. because they use an attribute the element does not have,
+// are reported and do not match.
+func (rs Rules) apply(desc string, vars map[string]interface{}) *ruleResult {
+	result := &ruleResult{options: make(Options)}
+	for i, r := range rs {
+		out, _, err := r.match.Eval(vars)
+		if err != nil {
+			report.Warn("rule %d could not be evaluated for %s, ignoring it: %s", i+1, desc, err)
+			continue
+		}
+
+		if matched, ok := out.Value().(bool); !ok || !matched {
+			continue
+		}
+
+		result.skip = result.skip || r.Skip
+		for name, v := range r.options {
+			result.options[name] = v
+		}
+
+		if vars["element"] != fieldElement {
+			if r.rename != nil || r.Retype != "" {
+				report.Warn("rule %d renames or retypes %s, but only fields can be, ignoring it", i+1, desc)
+			}
+			continue
+		}
+
+		if r.Retype != "" {
+			result.retype = r.Retype
+		}
+
+		if r.rename != nil {
+			out, _, err := r.rename.Eval(vars)
+			if err != nil {
+				report.Warn("rule %d could not rename %s, ignoring it: %s", i+1, desc, err)
+				continue
+			}
+
+			name, _ := out.Value().(string)
+			if !protoNameRegex.MatchString(name) {
+				report.Warn("rule %d renames %s to %q, which is not a valid name, ignoring it", i+1, desc, name)
+				continue
+			}
+			result.rename = name
+		}
+	}
+	return result
+}
+
+// forStruct returns what the rules do with the message of the given struct.
+func (rs Rules) forStruct(pkg *Package, s *scanner.Struct) *ruleResult {
+	if len(rs) == 0 {
+		return new(ruleResult)
+	}
+
+	return rs.apply(fmt.Sprintf("struct %q", s.Name), map[string]interface{}{
+		"element": messageElement,
+		"pkg":     pkgVars(pkg),
+		"typ":     typeVars(messageElement, s.Name, s.File, s.Docs),
+		"field":   map[string]interface{}{},
+	})
+}
+
+// forEnum returns what the rules do with the given enum.
+func (rs Rules) forEnum(pkg *Package, e *scanner.Enum) *ruleResult {
+	if len(rs) == 0 {
+		return new(ruleResult)
+	}
+
+	return rs.apply(fmt.Sprintf("enum %q", e.Name), map[string]interface{}{
+		"element": enumElement,
+		"pkg":     pkgVars(pkg),
+		"typ":     typeVars(enumElement, e.Name, e.File, e.Docs),
+		"field":   map[string]interface{}{},
+	})
+}
+
+// forField returns what the rules do with the given field of the struct,
+// named protoName in protobuf.
+func (rs Rules) forField(pkg *Package, s *scanner.Struct, f *scanner.Field, protoName string) *ruleResult {
+	if len(rs) == 0 {
+		return new(ruleResult)
+	}
+
+	return rs.apply(fmt.Sprintf("field %q of struct %q", f.Name, s.Name), map[string]interface{}{
+		"element": fieldElement,
+		"pkg":     pkgVars(pkg),
+		"typ":     typeVars(messageElement, s.Name, s.File, s.Docs),
+		"field": map[string]interface{}{
+			"name":       f.Name,
+			"goType":     goType(f.Type),
+			"protoName":  protoName,
+			"jsonName":   f.JSONName,
+			"repeated":   f.Type.IsRepeated(),
+			"doc":        strings.Join(f.Doc, "\n"),
+			"deprecated": f.Deprecated,
+		},
+	})
+}
+
+func pkgVars(pkg *Package) map[string]interface{} {
+	return map[string]interface{}{
+		"path": pkg.Path,
+		"name": pkg.Name,
+	}
+}
+
+func typeVars(kind, name, file string, docs scanner.Docs) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       name,
+		"kind":       kind,
+		"file":       file,
+		"doc":        strings.Join(docs.Doc, "\n"),
+		"deprecated": docs.Deprecated,
+	}
+}
+
+// retypeField changes the type of the given field, whose Go field is the
+// given one, to the scalar the rules give it. Its Go type is cast to it if
+// it is not the Go type of the scalar.
+func (r *ruleResult) retypeField(msg *Message, field *scanner.Field, f *Field) {
+	if r.retype == "" {
+		return
+	}
+
+	basic, ok := field.Type.(*scanner.Basic)
+	if !ok {
+		report.WarnAt(report.CodeInvalidRule, field.Position, msg.Name+"."+field.Name, "field %q of struct %q can not be retyped to %s, only fields of basic types can, ignoring it", field.Name, msg.Name, r.retype)
+		return
+	}
+
+	f.Type = NewBasic(r.retype)
+	if scalarGoTypes[r.retype] == basic.Name {
+		delete(f.Options, castTypeOption)
+	} else {
+		f.Options[castTypeOption] = NewStringValue(basic.Name)
+	}
+}
@@ -0,0 +1,124 @@
+package protobuf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestLoadRules(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-rules")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "rules.json")
+	require.Nil(ioutil.WriteFile(file, []byte(`[
+		{"match": "element == 'field' && field.goType == 'int'", "retype": "int32"},
+		{"match": "typ.name.endsWith('Internal')", "skip": true},
+		{"match": "element == 'field'", "rename": "field.protoName + '_x'", "options": {"(gogoproto.moretags)": "db:\"x\""}}
+	]`), 0644))
+
+	rules, err := LoadRules(file)
+	require.Nil(err)
+	require.Len(rules, 3)
+	require.Equal("int32", rules[0].Retype)
+	require.True(rules[1].Skip)
+	require.Equal(Options{"(gogoproto.moretags)": NewStringValue(`db:"x"`)}, rules[2].options)
+
+	invalid := map[string]string{
+		"invalid format":         `{"match": "true"}`,
+		"empty match":            `[{"skip": true}]`,
+		"no action":              `[{"match": "true"}]`,
+		"invalid expression":     `[{"match": "element ==", "skip": true}]`,
+		"match is not bool":      `[{"match": "typ.name", "skip": true}]`,
+		"unknown variable":       `[{"match": "foo == 'bar'", "skip": true}]`,
+		"rename is not a string": `[{"match": "true", "rename": "1 + 2"}]`,
+		"invalid type":           `[{"match": "true", "retype": "bytes"}]`,
+		"invalid option":         `[{"match": "true", "options": {"(foo.bar)": "true"}}]`,
+		"empty option":           `[{"match": "true", "options": {"deprecated": ""}}]`,
+	}
+	for name, content := range invalid {
+		require.Nil(ioutil.WriteFile(file, []byte(content), 0644))
+		_, err = LoadRules(file)
+		require.NotNil(err, name)
+	}
+
+	_, err = LoadRules(filepath.Join(dir, "missing.json"))
+	require.True(os.IsNotExist(err))
+}
+
+func TestTransformRules(t *testing.T) {
+	require := require.New(t)
+
+	rules := Rules{
+		{Match: `typ.name.endsWith("Internal")`, Skip: true},
+		{Match: `element == "field" && field.name == "Secret"`, Skip: true},
+		{Match: `element == "field" && field.name.endsWith("ID")`, Rename: `field.protoName + "entifier"`},
+		{Match: `element == "field" && field.goType == "int"`, Retype: "sint32"},
+		{Match: `element == "field" && field.goType == "int32"`, Retype: "int32"},
+		{Match: `element == "enum" && pkg.path.startsWith("gitlab.com/foo/")`, Options: map[string]string{"allow_alias": "true"}},
+		{Match: `typ.kind == "message" && field.size() == 0`, Options: map[string]string{"deprecated": "true"}},
+		{Match: `element == "message" && field.name == "Foo"`, Skip: true},
+		{Match: `element == "message"`, Rename: `"Foo"`},
+	}
+	require.Nil(rules.compile())
+
+	p := &scanner.Package{
+		Path: "gitlab.com/foo/bar",
+		Name: "bar",
+		Structs: []*scanner.Struct{
+			{
+				Name: "User",
+				Fields: []*scanner.Field{
+					{Name: "UserID", Type: scanner.NewBasic("string")},
+					{Name: "Secret", Type: scanner.NewBasic("string")},
+					{Name: "Age", Type: scanner.NewBasic("int")},
+					{Name: "Score", Type: scanner.NewBasic("int32")},
+				},
+			},
+			{Name: "UserInternal"},
+		},
+		Enums: []*scanner.Enum{
+			{
+				Name: "Status",
+				Values: []*scanner.EnumValue{
+					{Name: "Active", Value: 0},
+				},
+			},
+			{Name: "StatusInternal"},
+		},
+	}
+
+	tr := NewTransformer()
+	tr.SetRules(rules)
+	pkg := tr.Transform(p)
+
+	require.Len(pkg.Messages, 1)
+	msg := pkg.Messages[0]
+	require.Equal("User", msg.Name)
+	require.Equal(NewLiteralValue("true"), msg.Options["deprecated"])
+
+	require.Len(msg.Fields, 3)
+	require.Equal("user_identifier", msg.Fields[0].Name)
+	require.Equal(1, msg.Fields[0].Pos)
+	require.Equal(NewStringValue("UserID"), msg.Fields[0].Options["(gogoproto.customname)"])
+
+	require.Equal("age", msg.Fields[1].Name)
+	require.Equal(3, msg.Fields[1].Pos)
+	require.Equal(NewBasic("sint32"), msg.Fields[1].Type)
+	require.Equal(NewStringValue("int"), msg.Fields[1].Options[castTypeOption])
+
+	require.Equal(NewBasic("int32"), msg.Fields[2].Type)
+	_, ok := msg.Fields[2].Options[castTypeOption]
+	require.False(ok)
+
+	require.Len(pkg.Enums, 1)
+	require.Equal("Status", pkg.Enums[0].Name)
+	require.Equal(NewLiteralValue("true"), pkg.Enums[0].Options["allow_alias"])
+}
@@ -0,0 +1,96 @@
+package protobuf
+
+import (
+	"fmt"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// EnumSemantics is how the values of an enum that are not one of its values
+// are handled when they are received.
+type EnumSemantics string
+
+const (
+	// OpenEnum keeps the unknown values as they are, which is what proto3
+	// does with all the enums.
+	OpenEnum EnumSemantics = "open"
+	// ClosedEnum rejects the unknown values. As proto3 can not declare closed
+	// enums, the fields of the enum are validated to only have its values
+	// with protoc-gen-validate.
+	ClosedEnum EnumSemantics = "closed"
+)
+
+// ParseEnumSemantics returns the enum semantics with the given name.
+func ParseEnumSemantics(name string) (EnumSemantics, error) {
+	switch s := EnumSemantics(name); s {
+	case OpenEnum, ClosedEnum:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid enum semantics %q, expecting open or closed", name)
+}
+
+// EnumSemanticsOf returns the semantics of the given enum, given with a
+// comment like `//proteus:enum-semantics closed` in the enum or its package,
+// or the given default if they have none. Invalid semantics are ignored with
+// a warning. It is empty if the enum has none and there is no default.
+func EnumSemanticsOf(e *scanner.Enum, def EnumSemantics) EnumSemantics {
+	if e.Semantics == "" {
+		return def
+	}
+
+	semantics, err := ParseEnumSemantics(e.Semantics)
+	if err != nil {
+		report.WarnAt(report.CodeInvalidComment, e.Position, e.Name, "enum %s has invalid enum semantics, ignoring them: %s", e.Name, err)
+		return def
+	}
+	return semantics
+}
+
+// ClosedEnums returns the set of the closed enums of the given packages,
+// given the default semantics of the enums.
+func ClosedEnums(pkgs []*scanner.Package, def EnumSemantics) TypeSet {
+	ts := NewTypeSet()
+	for _, p := range pkgs {
+		for _, e := range p.Enums {
+			if EnumSemanticsOf(e, def) == ClosedEnum {
+				ts.Add(p.Path, e.Name)
+			}
+		}
+	}
+	return ts
+}
+
+// definedOnlyOption returns the name of the protoc-gen-validate option that
+// makes the given field only accept the values of the closed enum it has,
+// or of its elements if it is repeated or a map. It is empty if the field
+// does not have a closed enum.
+func (t *Transformer) definedOnlyOption(f *Field) string {
+	typ := f.Type
+	prefix := "(validate.rules)"
+	if m, ok := typ.(*Map); ok {
+		typ = m.Value
+		prefix += ".map.values"
+	} else if f.Repeated {
+		prefix += ".repeated.items"
+	}
+
+	if !t.isClosedEnum(typ) {
+		return ""
+	}
+	return prefix + ".enum.defined_only"
+}
+
+// isClosedEnum reports whether the protobuf type is a closed enum.
+func (t *Transformer) isClosedEnum(typ Type) bool {
+	if a, ok := typ.(*Alias); ok {
+		return t.isClosedEnum(a.Underlying)
+	}
+
+	if n, ok := typ.(*Named); ok {
+		if src, ok := n.Source().(*scanner.Named); ok {
+			return t.closedEnums.Contains(src.Path, src.Name)
+		}
+	}
+	return false
+}
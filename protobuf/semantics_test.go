@@ -0,0 +1,65 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestParseEnumSemantics(t *testing.T) {
+	s, err := ParseEnumSemantics("closed")
+	require.NoError(t, err)
+	require.Equal(t, ClosedEnum, s)
+
+	_, err = ParseEnumSemantics("ajar")
+	require.Error(t, err)
+}
+
+func TestEnumSemanticsOf(t *testing.T) {
+	require := require.New(t)
+	require.Equal(EnumSemantics(""), EnumSemanticsOf(&scanner.Enum{Name: "Color"}, ""))
+	require.Equal(OpenEnum, EnumSemanticsOf(&scanner.Enum{Name: "Color"}, OpenEnum))
+	require.Equal(ClosedEnum, EnumSemanticsOf(&scanner.Enum{Name: "Color", Semantics: "closed"}, OpenEnum))
+	require.Equal(OpenEnum, EnumSemanticsOf(&scanner.Enum{Name: "Color", Semantics: "ajar"}, OpenEnum))
+}
+
+func TestClosedEnums(t *testing.T) {
+	pkgs := []*scanner.Package{
+		{Path: "foo", Enums: []*scanner.Enum{{Name: "Color"}, {Name: "Size", Semantics: "closed"}}},
+		{Path: "bar", Enums: []*scanner.Enum{{Name: "Kind", Semantics: "open"}}},
+	}
+
+	require.Equal(t, TypeSet{"foo": {"Size": {}}}, ClosedEnums(pkgs, ""))
+	require.Equal(t, TypeSet{"foo": {"Color": {}, "Size": {}}}, ClosedEnums(pkgs, ClosedEnum))
+}
+
+func TestTransformFieldClosedEnum(t *testing.T) {
+	require := require.New(t)
+
+	tr := NewTransformer()
+	tr.SetEnumSet(TypeSet{"foo": {"Size": {}, "Color": {}}})
+	tr.SetClosedEnums(TypeSet{"foo": {"Size": {}}})
+
+	cases := []struct {
+		name     string
+		typ      scanner.Type
+		expected string
+	}{
+		{"enum", scanner.NewNamed("foo", "Size"), "(validate.rules).enum.defined_only"},
+		{"repeated", repeated(scanner.NewNamed("foo", "Size")), "(validate.rules).repeated.items.enum.defined_only"},
+		{"map", scanner.NewMap(scanner.NewBasic("string"), scanner.NewNamed("foo", "Size")), "(validate.rules).map.values.enum.defined_only"},
+	}
+
+	for _, c := range cases {
+		pkg := &Package{Path: "foo"}
+		f := tr.transformField(pkg, &Message{Name: "Foo"}, &scanner.Field{Name: "Size", Type: c.typ}, 1)
+		require.Equal(NewLiteralValue("true"), f.Options[c.expected], c.name)
+		require.Equal([]string{"validate/validate.proto"}, pkg.Imports, c.name)
+	}
+
+	pkg := &Package{Path: "foo"}
+	f := tr.transformField(pkg, &Message{Name: "Foo"}, &scanner.Field{Name: "Color", Type: scanner.NewNamed("foo", "Color")}, 1)
+	require.NotContains(f.Options, "(validate.rules).enum.defined_only", "open enums are not validated")
+	require.Empty(pkg.Imports)
+}
@@ -0,0 +1,250 @@
+package protobuf
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SplitByFile splits the given package into a file per Go source file of its
+// structs and enums, named after it, e.g. user.proto for user.go, in the
+// folder of the file of the package in its layout. The first file returned
+// keeps the name of the file of the package, with its services and the
+// messages and enums that do not come from a Go file, like the requests and
+// responses of the RPCs. The files share the protobuf package and options of
+// the package, and import the others to use their types. As protobuf does
+// not allow import cycles, the files whose types use each other are written
+// as a single one. The given package is not modified, but its messages and
+// enums are shared with the files.
+func SplitByFile(pkg *Package) []*Package {
+	var (
+		layoutFile = pkg.fileLayout.File(pkg.Path, pkg.Name)
+		dir        = filepath.Dir(layoutFile)
+		main       = filepath.Base(layoutFile)
+		// typeFiles are the names of the files of the messages and enums,
+		// indexed by the full name of the type.
+		typeFiles = make(map[string]string)
+	)
+
+	fileName := func(goFile string) string {
+		if goFile == "" {
+			return main
+		}
+		return strings.TrimSuffix(goFile, ".go") + ".proto"
+	}
+
+	for _, m := range pkg.Messages {
+		typeFiles[pkg.Name+"."+m.Name] = fileName(m.goFile)
+	}
+
+	for _, e := range pkg.Enums {
+		typeFiles[pkg.Name+"."+e.Name] = fileName(e.goFile)
+	}
+
+	merged := mergeImportCycles(fileDeps(pkg, typeFiles, main), main)
+	for typ, f := range typeFiles {
+		typeFiles[typ] = merged[f]
+	}
+
+	var (
+		files = map[string]*Package{main: pkg.splitFile("")}
+		names []string
+	)
+	files[main].Services = pkg.Services
+
+	fileOf := func(typ string) *Package {
+		name := typeFiles[typ]
+		f, ok := files[name]
+		if !ok {
+			f = pkg.splitFile(name)
+			files[name] = f
+			names = append(names, name)
+		}
+		return f
+	}
+
+	for _, m := range pkg.Messages {
+		f := fileOf(pkg.Name + "." + m.Name)
+		f.Messages = append(f.Messages, m)
+	}
+
+	for _, e := range pkg.Enums {
+		f := fileOf(pkg.Name + "." + e.Name)
+		f.Enums = append(f.Enums, e)
+	}
+
+	var types []string
+	for typ := range typeFiles {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	sort.Strings(names)
+
+	result := []*Package{files[main]}
+	for _, n := range names {
+		result = append(result, files[n])
+	}
+
+	for _, f := range result {
+		own := f.file
+		if own == "" {
+			own = main
+		}
+
+		for _, typ := range types {
+			if name := typeFiles[typ]; name != own {
+				file := normalizeImport(filepath.Join(dir, name))
+				f.typeImports[typ] = file
+				if !f.isImported(file) {
+					f.Imports = append(f.Imports, file)
+				}
+			}
+		}
+		f.DetectFeatures()
+	}
+	return result
+}
+
+// splitFile returns an empty package with the package data and imports of
+// the package, to hold the types written to the file with the given name.
+func (p *Package) splitFile(name string) *Package {
+	f := &Package{
+		Name:        p.Name,
+		Path:        p.Path,
+		Imports:     append([]string(nil), p.Imports...),
+		Options:     p.Options,
+		typeImports: make(map[string]string, len(p.typeImports)),
+		pkgImports:  make(map[string]string, len(p.pkgImports)),
+		fieldNaming: p.fieldNaming,
+		fileLayout:  p.fileLayout,
+		pkgTemplate: p.pkgTemplate,
+		pkgNames:    p.pkgNames,
+		file:        name,
+	}
+
+	for k, v := range p.typeImports {
+		f.typeImports[k] = v
+	}
+
+	for k, v := range p.pkgImports {
+		f.pkgImports[k] = v
+	}
+	return f
+}
+
+// fileDeps returns the files whose types are used by the types of each file,
+// and by the services, which are in the main file, indexed by file name.
+func fileDeps(pkg *Package, typeFiles map[string]string, main string) map[string]map[string]bool {
+	deps := map[string]map[string]bool{main: {}}
+	use := func(from string) func(*Named) {
+		if deps[from] == nil {
+			deps[from] = make(map[string]bool)
+		}
+
+		return func(n *Named) {
+			if to, ok := typeFiles[n.String()]; ok && to != from {
+				deps[from][to] = true
+			}
+		}
+	}
+
+	for _, m := range pkg.Messages {
+		fn := use(typeFiles[pkg.Name+"."+m.Name])
+		for _, f := range m.Fields {
+			walkType(f.Type, fn)
+		}
+	}
+
+	for _, e := range pkg.Enums {
+		use(typeFiles[pkg.Name+"."+e.Name])
+	}
+
+	for _, s := range pkg.Services {
+		fn := use(main)
+		for _, rpc := range s.RPCs {
+			walkType(rpc.Input, fn)
+			walkType(rpc.Output, fn)
+		}
+	}
+	return deps
+}
+
+// mergeImportCycles returns the file every file is merged into, so there are
+// no import cycles between them. All the files of a cycle are merged into the
+// main file, if it is in the cycle, or the first of them by name.
+func mergeImportCycles(deps map[string]map[string]bool, main string) map[string]string {
+	var names []string
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		merged  = make(map[string]string, len(names))
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		visit   func(string)
+	)
+
+	// visit is Tarjan's algorithm to find the strongly connected components
+	// of the graph of dependencies, which are the cycles.
+	visit = func(name string) {
+		index[name] = len(index)
+		lowlink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		var to []string
+		for dep := range deps[name] {
+			to = append(to, dep)
+		}
+		sort.Strings(to)
+
+		for _, dep := range to {
+			if _, ok := index[dep]; !ok {
+				visit(dep)
+				if lowlink[dep] < lowlink[name] {
+					lowlink[name] = lowlink[dep]
+				}
+			} else if onStack[dep] && index[dep] < lowlink[name] {
+				lowlink[name] = index[dep]
+			}
+		}
+
+		if lowlink[name] != index[name] {
+			return
+		}
+
+		var cycle []string
+		for {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[n] = false
+			cycle = append(cycle, n)
+			if n == name {
+				break
+			}
+		}
+		sort.Strings(cycle)
+
+		into := cycle[0]
+		for _, n := range cycle {
+			if n == main {
+				into = main
+			}
+		}
+
+		for _, n := range cycle {
+			merged[n] = into
+		}
+	}
+
+	for _, name := range names {
+		if _, ok := index[name]; !ok {
+			visit(name)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,101 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitByFile(t *testing.T) {
+	require := require.New(t)
+
+	named := func(name string) *Named {
+		return NewNamed("foo.bar", name)
+	}
+	field := func(name string, typ Type) *Field {
+		return &Field{Name: name, Pos: 1, Type: typ}
+	}
+
+	pkg := &Package{
+		Name:        "foo.bar",
+		Path:        "foo/bar",
+		Imports:     []string{"google/protobuf/timestamp.proto"},
+		typeImports: make(map[string]string),
+		pkgImports:  make(map[string]string),
+		fileLayout:  DefaultFileLayout,
+		Messages: []*Message{
+			{Name: "User", goFile: "user.go", Fields: []*Field{field("group", named("Group")), field("kind", named("Kind"))}},
+			{Name: "Group", goFile: "group.go", Fields: []*Field{field("owner", named("User"))}},
+			{Name: "Item", goFile: "item.go", Fields: []*Field{field("kind", named("Kind"))}},
+			{Name: "GetItemRequest", Fields: []*Field{field("arg1", NewBasic("string"))}},
+		},
+		Enums: []*Enum{
+			{Name: "Kind", goFile: "kind.go"},
+		},
+		Services: []*Service{
+			{Name: "BarService", RPCs: []*RPC{
+				{Name: "GetItem", Input: named("GetItemRequest"), Output: named("Item")},
+			}},
+		},
+	}
+
+	files := SplitByFile(pkg)
+	require.Len(files, 4)
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.file)
+	}
+	require.Equal([]string{"", "group.proto", "item.proto", "kind.proto"}, names)
+
+	messages := func(f *Package) []string {
+		var result []string
+		for _, m := range f.Messages {
+			result = append(result, m.Name)
+		}
+		return result
+	}
+
+	main := files[0]
+	require.Equal(pkg.Services, main.Services)
+	require.Equal([]string{"GetItemRequest"}, messages(main))
+	require.Equal("foo/bar/generated.proto", main.fileLayout.File(main.Path, main.Name))
+	require.Equal("foo/bar/item.proto", main.typeImports["foo.bar.Item"])
+
+	// user.go and group.go use each other's types, so they are merged
+	group := files[1]
+	require.Equal([]string{"User", "Group"}, messages(group))
+	require.Empty(group.Services)
+	require.Equal("foo/bar/kind.proto", group.typeImports["foo.bar.Kind"])
+	require.NotContains(group.typeImports, "foo.bar.User")
+	require.Contains(group.Imports, "google/protobuf/timestamp.proto")
+	require.Contains(group.Imports, "foo/bar/kind.proto")
+	require.NotContains(group.Imports, "foo/bar/group.proto")
+
+	require.Equal([]string{"Item"}, messages(files[2]))
+	require.Len(files[3].Enums, 1)
+	require.Equal("Kind", files[3].Enums[0].Name)
+
+	require.Equal([]string{"google/protobuf/timestamp.proto"}, pkg.Imports)
+	require.Empty(pkg.typeImports)
+}
+
+func TestMergeImportCycles(t *testing.T) {
+	require := require.New(t)
+
+	merged := mergeImportCycles(map[string]map[string]bool{
+		"a.proto":    {"b.proto": true},
+		"b.proto":    {"a.proto": true},
+		"c.proto":    {"main.proto": true},
+		"main.proto": {"a.proto": true, "c.proto": true},
+		"d.proto":    {},
+	}, "main.proto")
+
+	require.Equal(map[string]string{
+		"main.proto": "main.proto",
+		"a.proto":    "a.proto",
+		"b.proto":    "a.proto",
+		"c.proto":    "main.proto",
+		"d.proto":    "d.proto",
+	}, merged)
+}
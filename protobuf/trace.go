@@ -0,0 +1,223 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// Trace records the decisions taken while transforming the fields of the
+// structs, so it can be found why a field got its type, name, number or
+// options without reading the source of proteus. It is meant to be written
+// as JSON and queried with tools like jq.
+//
+// The numbers recorded are the ones given by the transformer. Fields of
+// messages numbered by a field profile may keep the numbers they had in the
+// previous version of the file instead.
+type Trace struct {
+	Decisions []*Decision `json:"decisions"`
+}
+
+// Decision is a single choice taken for a field.
+type Decision struct {
+	// Package is the Go package of the struct.
+	Package string `json:"package"`
+	// Message is the name of the message of the struct.
+	Message string `json:"message"`
+	// Field is the Go name of the field.
+	Field string `json:"field"`
+	// Kind is what was decided, one of type, name, number, option or
+	// ignored.
+	Kind string `json:"kind"`
+	// Value is the result of the decision, like the protobuf type or the
+	// number of the field.
+	Value string `json:"value"`
+	// Reason explains why the decision was taken.
+	Reason string `json:"reason"`
+}
+
+// NewTrace creates a new empty trace.
+func NewTrace() *Trace {
+	return &Trace{Decisions: []*Decision{}}
+}
+
+// Find returns the decisions taken for the field with the given Go name of
+// the given message.
+func (t *Trace) Find(message, field string) []*Decision {
+	var result []*Decision
+	for _, d := range t.Decisions {
+		if d.Message == message && d.Field == field {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// WriteFile writes the trace as JSON to the file at the given path.
+func (t *Trace) WriteFile(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func (t *Trace) add(pkg *Package, msg *Message, field *scanner.Field, kind, value, reason string) {
+	t.Decisions = append(t.Decisions, &Decision{
+		Package: pkg.Path,
+		Message: msg.Name,
+		Field:   field.Name,
+		Kind:    kind,
+		Value:   value,
+		Reason:  reason,
+	})
+}
+
+// optionReasons are the reasons fields get the options set by the
+// transformer, by the name of the option or the prefix of its name.
+var optionReasons = []struct {
+	prefix string
+	reason string
+}{
+	{"(gogoproto.customname)", "the Go name of the field is not the one gogoproto gives to the proto name"},
+	{"json_name", "the name in JSON is not the one protobuf gives to the proto name"},
+	{"(gogoproto.nullable)", "the Go field is not a pointer"},
+	{"(gogoproto.casttype)", "the Go type is converted from the protobuf type"},
+	{"(gogoproto.castkey)", "the Go type of the keys is converted from the protobuf type"},
+	{"(gogoproto.castvalue)", "the Go type of the values is converted from the protobuf type"},
+	{"(gogoproto.customtype)", "the Go type implements its own encoding"},
+	{"(gogoproto.stdtime)", "the Go type is time.Time"},
+	{"(gogoproto.stdduration)", "the Go type is time.Duration"},
+	{deprecatedOption, "the docs of the field have a Deprecated paragraph"},
+	{lazyOption, "the field is tagged as lazy"},
+	{"(validate.rules)", "the field has a validate tag"},
+}
+
+func optionReason(name string) string {
+	for _, r := range optionReasons {
+		if strings.HasPrefix(name, r.prefix) {
+			return r.reason
+		}
+	}
+	return "set by a type mapping"
+}
+
+// traceField records the decisions taken for the given struct field, which
+// became the given message field, or nil if it was ignored, with the given
+// number.
+func (t *Transformer) traceField(pkg *Package, msg *Message, field *scanner.Field, f *Field, pos int) {
+	if t.trace == nil {
+		return
+	}
+
+	if field.Reserved {
+		t.trace.add(pkg, msg, field, "number", fmt.Sprint(pos), "the field is skipped or its type is not supported, only its number is reserved")
+		return
+	}
+
+	if f == nil {
+		t.trace.add(pkg, msg, field, "ignored", fmt.Sprint(pos), fmt.Sprintf("Go type %s is not supported, its number is reserved", goType(field.Type)))
+		return
+	}
+
+	typ := f.Type.String()
+	if f.Repeated {
+		typ = "repeated " + typ
+	}
+	t.trace.add(pkg, msg, field, "type", typ, t.typeReason(field.Type))
+
+	naming := msg.fieldNaming
+	if naming == "" {
+		naming = SnakeCaseFieldNaming
+	}
+
+	nameReason := fmt.Sprintf("the field naming is %s", naming)
+	if field.ProtoName != "" {
+		nameReason = "the name is given in the proteus tag"
+	}
+	t.trace.add(pkg, msg, field, "name", f.Name, nameReason)
+
+	var numberReason string
+	switch {
+	case field.ProtoID != 0 && field.ProtoID == pos:
+		numberReason = "the id is given in the proteus tag"
+	case field.ProtoID != 0:
+		numberReason = fmt.Sprintf("the id %d of the proteus tag is taken by another field", field.ProtoID)
+	case msg.profiled:
+		numberReason = "the fields are numbered following the field profile"
+	default:
+		numberReason = "the fields are numbered in the order of the struct"
+	}
+	t.trace.add(pkg, msg, field, "number", fmt.Sprint(pos), numberReason)
+
+	var names []string
+	for name := range f.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.trace.add(pkg, msg, field, "option", fmt.Sprintf("%s = %s", name, f.Options[name]), optionReason(name))
+	}
+}
+
+// typeReason returns why a field of the given Go type gets its protobuf type.
+func (t *Transformer) typeReason(typ scanner.Type) string {
+	name := goType(typ)
+	if isByteSlice(typ) {
+		return fmt.Sprintf("Go type %s is a byte slice", name)
+	}
+
+	switch ty := typ.(type) {
+	case *scanner.Named:
+		if t.hasMapping(ty.String()) {
+			return fmt.Sprintf("Go type %s has a type mapping", name)
+		}
+
+		if t.IsEnum(ty.Path, ty.Name) {
+			return fmt.Sprintf("Go type %s is an enum", name)
+		}
+
+		if ty.Message {
+			return fmt.Sprintf("Go type %s already implements proto.Message, its message is imported", name)
+		}
+		return fmt.Sprintf("Go type %s is a message", name)
+	case *scanner.Basic:
+		return fmt.Sprintf("Go type %s has a type mapping", name)
+	case *scanner.Map:
+		return fmt.Sprintf("Go type %s is a map", name)
+	case *scanner.Set:
+		return fmt.Sprintf("Go type %s is a set, whose elements are repeated", name)
+	case *scanner.Alias:
+		return fmt.Sprintf("Go type %s is defined as %s", name, goType(ty.Underlying))
+	}
+	return fmt.Sprintf("Go type %s", name)
+}
+
+// goType returns the type as it is written in Go.
+func goType(typ scanner.Type) string {
+	var prefix string
+	if _, ok := typ.(*scanner.Set); !ok && typ.IsRepeated() {
+		prefix = "[]"
+	}
+
+	if isPointer(typ) {
+		prefix += "*"
+	}
+
+	if a, ok := typ.(*scanner.Alias); ok {
+		return prefix + a.Type.String()
+	}
+	return prefix + typ.String()
+}
+
+// hasMapping reports whether there is a mapping for the Go type with the
+// given name, without reporting the warning of the mapping.
+func (t *Transformer) hasMapping(name string) bool {
+	return t.mappings[name] != nil || registeredMappings[name] != nil || DefaultMappings[name] != nil
+}
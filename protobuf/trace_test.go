@@ -0,0 +1,71 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestTrace(t *testing.T) {
+	require := require.New(t)
+	report.TestMode()
+	defer report.EndTestMode()
+
+	tr := NewTransformer()
+	trace := NewTrace()
+	tr.SetTrace(trace)
+
+	st := &scanner.Struct{
+		Name: "User",
+		Fields: []*scanner.Field{
+			{Name: "Avatar", Type: repeated(scanner.NewBasic("byte"))},
+			{Name: "Age", Type: scanner.NewBasic("int"), ProtoID: 5, ProtoName: "years"},
+			{Name: "Skipped", Reserved: true},
+			{Name: "Invalid", Type: scanner.NewBasic("complex128")},
+		},
+	}
+	tr.transformStruct(&Package{Path: "foo"}, st)
+
+	require.Equal([]*Decision{
+		{Package: "foo", Message: "User", Field: "Avatar", Kind: "type", Value: "bytes", Reason: "Go type []byte is a byte slice"},
+		{Package: "foo", Message: "User", Field: "Avatar", Kind: "name", Value: "avatar", Reason: "the field naming is snake"},
+		{Package: "foo", Message: "User", Field: "Avatar", Kind: "number", Value: "1", Reason: "the fields are numbered in the order of the struct"},
+	}, trace.Find("User", "Avatar"))
+
+	require.Equal([]*Decision{
+		{Package: "foo", Message: "User", Field: "Age", Kind: "type", Value: "int64", Reason: "Go type int has a type mapping"},
+		{Package: "foo", Message: "User", Field: "Age", Kind: "name", Value: "years", Reason: "the name is given in the proteus tag"},
+		{Package: "foo", Message: "User", Field: "Age", Kind: "number", Value: "5", Reason: "the id is given in the proteus tag"},
+		{Package: "foo", Message: "User", Field: "Age", Kind: "option", Value: `(gogoproto.casttype) = "int"`, Reason: "the Go type is converted from the protobuf type"},
+		{Package: "foo", Message: "User", Field: "Age", Kind: "option", Value: `(gogoproto.customname) = "Age"`, Reason: "the Go name of the field is not the one gogoproto gives to the proto name"},
+	}, trace.Find("User", "Age"))
+
+	skipped := trace.Find("User", "Skipped")
+	require.Len(skipped, 1)
+	require.Equal("number", skipped[0].Kind)
+	require.Equal("2", skipped[0].Value)
+
+	invalid := trace.Find("User", "Invalid")
+	require.Len(invalid, 1)
+	require.Equal("ignored", invalid[0].Kind)
+
+	dir, err := ioutil.TempDir("", "proteus-trace")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "trace.json")
+	require.Nil(trace.WriteFile(file))
+
+	data, err := ioutil.ReadFile(file)
+	require.Nil(err)
+
+	var written Trace
+	require.Nil(json.Unmarshal(data, &written))
+	require.Equal(trace, &written)
+}
@@ -3,6 +3,8 @@ package protobuf
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -16,15 +18,97 @@ import (
 	"gitlab.com/ThatTomPerson/proteus/scanner"
 )
 
+// deprecatedOption is the option set to true in the entities marked as
+// deprecated.
+const deprecatedOption = "deprecated"
+
+// idempotencyOption is the option of the RPCs telling whether their calls
+// have side effects, which is set to IDEMPOTENT for idempotent funcs.
+const idempotencyOption = "idempotency_level"
+
+// lazyOption is the option set to true in the message fields marked to be
+// decoded lazily.
+const lazyOption = "lazy"
+
+const (
+	// jsonOmitOption is the option set to true in the message fields left
+	// out of the JSON encoding.
+	jsonOmitOption = "(proteus.json_omit)"
+	// unitOption is the option with the unit of the values of the message
+	// fields that have one.
+	unitOption = "(proteus.unit)"
+	// sensitiveOption is the option set to true in the message fields
+	// redacted in the logs.
+	sensitiveOption = "(proteus.sensitive)"
+	optionsImport   = "gitlab.com/ThatTomPerson/proteus/options/options.proto"
+	optionsPackage  = "proteus"
+)
+
 // Transformer is in charge of converting scanned Go entities to protobuf
 // entities as well as mapping between Go and Protobuf types.
 // Take into account that custom mappings are used first to check for the
-// corresponding type mapping, and then the default mappings to give the user
-// ability to override any kind of type.
+// corresponding type mapping, then the mappings registered with
+// RegisterMapping and then the default mappings to give the user ability to
+// override any kind of type.
 type Transformer struct {
 	mappings  TypeMappings
 	structSet TypeSet
 	enumSet   TypeSet
+	// arraySet are the named array types generated as bytes fields.
+	arraySet TypeSet
+	// lazyMessages are the messages of the lazy types, by the name of the
+	// lazy type with its package path.
+	lazyMessages map[string]*scanner.Named
+	// closedEnums are the enums whose unknown values are rejected.
+	closedEnums TypeSet
+	importPaths ImportPaths
+	// messageFiles are the .proto files of the Go packages whose types are
+	// imported from files published elsewhere.
+	messageFiles MessageFiles
+	// fileLayout is where the files of other packages are imported from.
+	fileLayout FileLayout
+	// pkgTemplate is the template of the protobuf packages.
+	pkgTemplate PackageTemplate
+	// pkgNames are the protobuf packages of the Go packages that do not
+	// follow the template.
+	pkgNames PackageNames
+	// fileOptions are the options of the files of the packages.
+	fileOptions FileOptions
+	// unspecified reports whether an unspecified value is added to the
+	// enums without a zero value that do not tell it themselves.
+	unspecified bool
+	// constValues reports whether the values of the enums are numbered
+	// with the values of their consts instead of the order they are
+	// declared in.
+	constValues bool
+	// optionalFields reports whether pointers to scalar types are
+	// transformed into proto3 optional fields.
+	optionalFields bool
+	// enumNaming is the naming strategy of the enums that do not have one.
+	enumNaming EnumNaming
+	// fieldNaming is the naming strategy of the fields of the packages that
+	// do not have one.
+	fieldNaming FieldNaming
+	// jsonCasing is the casing in JSON of the fields of the structs that do
+	// not have one.
+	jsonCasing JSONCasing
+	// acronyms are the acronyms kept as a single word in the names of the
+	// fields and enum values.
+	acronyms Acronyms
+	// profile holds how often the fields of the messages are set.
+	profile FieldProfile
+	// trace, if not nil, gets the decisions taken for the fields of the
+	// structs recorded.
+	trace *Trace
+	// rules are the transformation rules applied to the messages, enums and
+	// fields.
+	rules Rules
+	// hooks are the callbacks that run over the messages, fields and RPCs
+	// of the packages once they are transformed.
+	hooks hooks
+	// goImportPaths reports whether the go_package of the files has the
+	// import path of the Go package along with its name.
+	goImportPaths bool
 }
 
 // NewTransformer creates a new transformer instance.
@@ -43,6 +127,121 @@ func (t *Transformer) SetMappings(m TypeMappings) {
 	t.mappings = m
 }
 
+// SetImportPaths sets the paths that will be used to import files instead of
+// the default ones in the generated packages.
+func (t *Transformer) SetImportPaths(paths ImportPaths) {
+	t.importPaths = paths
+}
+
+// SetMessageFiles sets the .proto files published elsewhere of Go packages,
+// which are imported instead of the generated ones to reference their types.
+func (t *Transformer) SetMessageFiles(files MessageFiles) {
+	t.messageFiles = files
+}
+
+// SetFileLayout sets the layout of the generated files, so the files of other
+// packages are imported from their paths in it.
+func (t *Transformer) SetFileLayout(layout FileLayout) {
+	t.fileLayout = layout
+}
+
+// SetPackageTemplate sets the template of the protobuf packages of the Go
+// packages. By default, they are the Go paths with dots instead of slashes.
+func (t *Transformer) SetPackageTemplate(tpl PackageTemplate) {
+	t.pkgTemplate = tpl
+}
+
+// SetPackageNames sets the protobuf packages of the Go packages that do not
+// follow the package template, given in their docs or to proteus.
+func (t *Transformer) SetPackageNames(names PackageNames) {
+	t.pkgNames = names
+}
+
+// SetFileOptions sets the options written to the files of the packages,
+// which override the default ones.
+func (t *Transformer) SetFileOptions(opts FileOptions) {
+	t.fileOptions = opts
+}
+
+// SetRules sets the transformation rules applied to the messages, enums and
+// fields, in order.
+func (t *Transformer) SetRules(rules Rules) {
+	t.rules = rules
+}
+
+// SetUnspecifiedEnumValues sets whether a value named {ENUM}_UNSPECIFIED with
+// the number 0 is added to the enums that do not have any value with that
+// number, which proto3 requires to be the first one, unless the enums tell it
+// in their own docs or in their package's.
+func (t *Transformer) SetUnspecifiedEnumValues(unspecified bool) {
+	t.unspecified = unspecified
+}
+
+// SetEnumConstValues sets whether the values of the enums are numbered with
+// the values of their consts, which are the ones sent by the generated Go
+// code, instead of the order they are declared in. It changes the numbers of
+// the enums whose consts do not start at 0 and increase by 1, so it must only
+// be set for new enums or if their previous numbers were not in use.
+func (t *Transformer) SetEnumConstValues(constValues bool) {
+	t.constValues = constValues
+}
+
+// SetOptionalFields sets whether the pointers to basic types and enums are
+// transformed into proto3 optional fields, which tell an unset field from a
+// field set to its zero value. protoc-gen-gofast does not support them, so
+// they can only be used with the files that are not compiled with it.
+func (t *Transformer) SetOptionalFields(optional bool) {
+	t.optionalFields = optional
+}
+
+// SetGoImportPaths sets whether the go_package option of the files is the
+// import path of the Go package followed by its name, like
+// github.com/acme/app/user;user, instead of just its name, so the Go code is
+// written to the folder of the package by any generator that honors it.
+func (t *Transformer) SetGoImportPaths(importPaths bool) {
+	t.goImportPaths = importPaths
+}
+
+// SetEnumNaming sets the naming strategy of the values of the enums that do
+// not have one, neither in their own docs nor in their package's.
+func (t *Transformer) SetEnumNaming(naming EnumNaming) {
+	t.enumNaming = naming
+}
+
+// SetAcronyms sets the acronyms kept as a single word when the names of the
+// fields and enum values are converted to snake case. By default, they are
+// the common initialisms, such as ID or HTTP.
+func (t *Transformer) SetAcronyms(acronyms Acronyms) {
+	t.acronyms = acronyms
+}
+
+// SetFieldNaming sets the naming strategy of the fields of the messages whose
+// structs do not have one, neither in their own docs nor in their package's.
+func (t *Transformer) SetFieldNaming(naming FieldNaming) {
+	t.fieldNaming = naming
+}
+
+// SetJSONCasing sets the casing of the names in JSON of the fields of the
+// structs that do not have one, neither in their own docs nor in their
+// package's.
+func (t *Transformer) SetJSONCasing(casing JSONCasing) {
+	t.jsonCasing = casing
+}
+
+// SetFieldProfile sets the profile used to number the fields of the messages
+// in it, so their small and frequent fields get the numbers 1 to 15, which
+// take a single byte. The messages not in the profile are numbered in the
+// order of their fields.
+func (t *Transformer) SetFieldProfile(profile FieldProfile) {
+	t.profile = profile
+}
+
+// SetTrace sets the trace the decisions taken for the fields of the structs
+// are recorded in. If nil, they are not recorded.
+func (t *Transformer) SetTrace(trace *Trace) {
+	t.trace = trace
+}
+
 // SetStructSet sets the passed TypeSet as a known list of structs.
 func (t *Transformer) SetStructSet(ts TypeSet) {
 	t.structSet = ts
@@ -63,22 +262,70 @@ func (t *Transformer) SetEnumSet(ts TypeSet) {
 	t.enumSet = ts
 }
 
-// Transform converts a scanned package to a protobuf package.
+// SetArraySet sets the passed TypeSet as the list of named array types,
+// like `type Hash [32]byte`, which are generated as bytes fields converted
+// with the methods of gogoproto.customtype generated for them.
+func (t *Transformer) SetArraySet(ts TypeSet) {
+	t.arraySet = ts
+}
+
+// IsArray checks if the given pkg path and name is a known array type.
+func (t *Transformer) IsArray(pkg, name string) bool {
+	return t.arraySet.Contains(pkg, name)
+}
+
+// SetLazyMessages sets the messages of the lazy types, like
+// `type LazyReport lazy.Message[Report]` for Report, by the name of the lazy
+// type with its package path. Their fields are generated as fields of their
+// message with the lazy option, converted with the methods of
+// gogoproto.customtype generated for the lazy type.
+func (t *Transformer) SetLazyMessages(messages map[string]*scanner.Named) {
+	t.lazyMessages = messages
+}
+
+// SetClosedEnums sets the passed TypeSet as the list of enums whose unknown
+// values are rejected, whose fields are validated to only have their values.
+func (t *Transformer) SetClosedEnums(ts TypeSet) {
+	t.closedEnums = ts
+}
+
+// Transform converts a scanned package to a protobuf package. The imports
+// of the package are pruned after the hooks are run, so it only imports the
+// files of the types and options it uses, and its features are detected.
 func (t *Transformer) Transform(p *scanner.Package) *Package {
 	pkg := &Package{
-		Name:    toProtobufPkg(p.Path),
-		Path:    p.Path,
-		Imports: []string{"github.com/gogo/protobuf/gogoproto/gogo.proto"},
-		Options: t.defaultOptionsForPackage(p),
+		Name:        t.pkgNames.Package(p.Path, t.pkgTemplate),
+		Path:        p.Path,
+		Options:     t.defaultOptionsForPackage(p),
+		fieldNaming: t.fieldNamingOf(p),
+		fileLayout:  t.fileLayout,
+		pkgTemplate: t.pkgTemplate,
+		pkgNames:    t.pkgNames,
+	}
+	pkg.importPackage("github.com/gogo/protobuf/gogoproto/gogo.proto", "gogoproto")
+	for name, v := range t.fileOptions.Package(pkg.Path, pkg.Name) {
+		pkg.Options[name] = v
 	}
 
 	for _, s := range p.Structs {
+		rule := t.rules.forStruct(pkg, s)
+		if rule.skip {
+			continue
+		}
+
 		msg := t.transformStruct(pkg, s)
+		rule.setOptions(msg.Options)
 		pkg.Messages = append(pkg.Messages, msg)
 	}
 
 	for _, e := range p.Enums {
+		rule := t.rules.forEnum(pkg, e)
+		if rule.skip {
+			continue
+		}
+
 		enum := t.transformEnum(e)
+		rule.setOptions(enum.Options)
 		pkg.Enums = append(pkg.Enums, enum)
 	}
 
@@ -86,14 +333,64 @@ func (t *Transformer) Transform(p *scanner.Package) *Package {
 	for _, f := range p.Funcs {
 		rpc := t.transformFunc(pkg, f, names)
 		if rpc != nil {
-			pkg.RPCs = append(pkg.RPCs, rpc)
+			svc := pkg.service(serviceNameForRPC(pkg, rpc))
+			svc.RPCs = append(svc.RPCs, rpc)
 		}
 	}
 
+	for _, i := range p.Interfaces {
+		pkg.Services = append(pkg.Services, t.transformInterface(pkg, i, names))
+	}
+
+	t.hooks.run(pkg)
+	pkg.rewriteImports(t.importPaths)
+	pkg.PruneImports()
+	pkg.DetectFeatures()
 	return pkg
 }
 
+// transformInterface converts an interface into a service with an RPC for
+// each one of its methods. The messages generated for the methods are
+// prefixed with the name of the interface.
+func (t *Transformer) transformInterface(pkg *Package, i *scanner.Interface, names nameSet) *Service {
+	svc := &Service{
+		Docs: i.Doc,
+		Name: i.Name,
+	}
+
+	for _, m := range i.Methods {
+		rpc := t.transformRPC(pkg, m, names, fmt.Sprintf("%s_%s", i.Name, m.Name))
+		if rpc != nil {
+			svc.RPCs = append(svc.RPCs, rpc)
+		}
+	}
+
+	return svc
+}
+
+// transformFunc converts a func into an RPC. The messages generated for
+// methods are prefixed with the name of their receiver.
 func (t *Transformer) transformFunc(pkg *Package, f *scanner.Func, names nameSet) *RPC {
+	msgName := f.Name
+	if n, ok := f.Receiver.(*scanner.Named); ok {
+		msgName = fmt.Sprintf("%s_%s", n.Name, f.Name)
+	}
+	return t.transformRPC(pkg, f, names, msgName)
+}
+
+// serviceNameForRPC returns the name of the service the RPC of a func
+// belongs to. Methods are grouped in a service per receiver and the rest of
+// funcs belong to the default service of the package.
+func serviceNameForRPC(pkg *Package, rpc *RPC) string {
+	if rpc.Recv != "" {
+		return rpc.Recv + "Service"
+	}
+	return pkg.ServiceName()
+}
+
+// transformRPC converts a func into an RPC. The messages generated for its
+// input and output, if needed, are named after msgName.
+func (t *Transformer) transformRPC(pkg *Package, f *scanner.Func, names nameSet, msgName string) *RPC {
 	var (
 		name         = f.Name
 		receiverName string
@@ -102,38 +399,124 @@ func (t *Transformer) transformFunc(pkg *Package, f *scanner.Func, names nameSet
 	if f.Receiver != nil {
 		n, ok := f.Receiver.(*scanner.Named)
 		if !ok {
-			report.Warn("invalid receiver type for func %s", f.Name)
+			report.SkipAt(f.Position, f.Name, "invalid receiver type for func %s", f.Name)
 			return nil
 		}
 
-		name = fmt.Sprintf("%s", name)
 		receiverName = n.Name
 	}
 
 	input, hasCtx := removeFirstCtx(f.Input)
 	output, hasError := removeLastError(f.Output)
+
+	var (
+		in      Type
+		options []*Field
+	)
+	if len(f.FuncOptions) > 0 {
+		in, options = t.transformInputWithOptions(pkg, input, f.FuncOptions, names, msgName)
+	} else {
+		in = t.transformInputTypes(pkg, input, names, msgName)
+	}
+
+	if f.Stream != scanner.NoStream && (len(output) != 1 || output[0].IsRepeated() || !isNamed(output[0]) || t.isNotMessage(output[0])) {
+		report.SkipAt(f.Position, f.Name, "the values of the stream returned by func %s are not messages. RPC %s will not be generated", f.Name, name)
+		return nil
+	}
+
 	rpc := &RPC{
-		Docs:       f.Doc,
-		Name:       name,
-		Recv:       receiverName,
-		Method:     f.Name,
-		HasCtx:     hasCtx,
-		HasError:   hasError,
-		IsVariadic: f.IsVariadic,
-		Input:      t.transformInputTypes(pkg, input, names, name),
-		Output:     t.transformOutputTypes(pkg, output, names, name),
+		Docs:           f.Doc,
+		Name:           name,
+		Recv:           receiverName,
+		Method:         f.Name,
+		HasCtx:         hasCtx,
+		HasError:       hasError,
+		IsVariadic:     f.IsVariadic,
+		Input:          in,
+		Output:         t.transformOutputTypes(pkg, output, names, msgName),
+		MaxConcurrency: f.MaxConcurrency,
+		Idempotent:     f.Idempotent,
+		Stream:         f.Stream,
+		StreamBuffer:   f.StreamBuffer,
+		Position:       f.Position,
 	}
+
 	if rpc.Input == nil || rpc.Output == nil {
 		return nil
 	}
 
+	for i, o := range f.FuncOptions {
+		rpc.FuncOptions = append(rpc.FuncOptions, &FuncOption{
+			Func:     o.Name,
+			Field:    o.Field,
+			Implicit: !options[i].Optional && !options[i].Repeated && t.isScalar(options[i].Type),
+		})
+	}
+
+	if f.HTTP != nil {
+		rpc.HTTP = f.HTTP
+		rpc.Options = Options{"(google.api.http)": httpRuleValue(f.HTTP)}
+		pkg.importPackage("google/api/annotations.proto", "google.api")
+	}
+
+	if f.Deprecated {
+		if rpc.Options == nil {
+			rpc.Options = make(Options)
+		}
+		rpc.Options[deprecatedOption] = NewLiteralValue("true")
+	}
+
+	if f.Idempotent {
+		if rpc.Options == nil {
+			rpc.Options = make(Options)
+		}
+		rpc.Options[idempotencyOption] = NewLiteralValue("IDEMPOTENT")
+	}
+
 	return rpc
 }
 
+// httpRuleValue returns the value of the google.api.http option for the
+// given HTTP rule.
+func httpRuleValue(rule *scanner.HTTPRule) OptionValue {
+	value := NewAggregateValue().With(rule.Method, NewStringValue(rule.Path))
+	if rule.Body != "" {
+		value = value.With("body", NewStringValue(rule.Body))
+	}
+	return value
+}
+
 func (t *Transformer) transformInputTypes(pkg *Package, types []scanner.Type, names nameSet, name string) Type {
 	return t.transformTypeList(pkg, types, names, name, "Request", "arg")
 }
 
+// transformInputWithOptions returns the request of a func with functional
+// options, which is always a generated message with the fields of the
+// arguments followed by the fields of the options, and the fields of the
+// options. If the type of any option is not supported, nil is returned.
+func (t *Transformer) transformInputWithOptions(pkg *Package, types []scanner.Type, options []*scanner.FuncOption, names nameSet, name string) (Type, []*Field) {
+	msgName := name + "Request"
+	if _, ok := names[msgName]; ok {
+		report.Skip("tried to register message %s, but there is already a message with that name. RPC %s will not be generated", msgName, name)
+		return nil, nil
+	}
+
+	msg := t.createMessageFromTypes(pkg, msgName, types, "arg")
+	var fields []*Field
+	for i, o := range options {
+		f := t.transformField(pkg, msg, &scanner.Field{Name: o.Field, Type: o.Type}, len(types)+i+1)
+		if f == nil {
+			report.Skip("option %s of func %s has an unsupported type. RPC %s will not be generated", o.Name, name, name)
+			return nil, nil
+		}
+		msg.Fields = append(msg.Fields, f)
+		fields = append(fields, f)
+	}
+
+	pkg.Messages = append(pkg.Messages, msg)
+	return NewGeneratedNamed(t.pkgNames.Package(pkg.Path, t.pkgTemplate), msgName), fields
+}
+
 func (t *Transformer) transformOutputTypes(pkg *Package, types []scanner.Type, names nameSet, name string) Type {
 	return t.transformTypeList(pkg, types, names, name, "Response", "result")
 }
@@ -143,23 +526,28 @@ func (t *Transformer) transformTypeList(pkg *Package, types []scanner.Type, name
 	// - there is more than one element
 	// - there is one element and it is repeated, as this is not supported in protobuf
 	// - there is one element and it is not a message, as protobuf expects messages as input/output
-	if len(types) != 1 || types[0].IsRepeated() || !isNamed(types[0]) {
+	// - there is one element and it is mapped to a basic type or it is a custom type, as its Go type is not a message
+	if len(types) != 1 || types[0].IsRepeated() || !isNamed(types[0]) || t.isNotMessage(types[0]) {
 		msgName := name + msgNameSuffix
 		if _, ok := names[msgName]; ok {
-			report.Warn("tried to register message %s, but there is already a message with that name. RPC %s will not be generated", msgName, name)
+			report.Skip("tried to register message %s, but there is already a message with that name. RPC %s will not be generated", msgName, name)
 			return nil
 		}
 
 		msg := t.createMessageFromTypes(pkg, msgName, types, msgFieldPrefix)
 		pkg.Messages = append(pkg.Messages, msg)
-		return NewGeneratedNamed(toProtobufPkg(pkg.Path), msgName)
+		return NewGeneratedNamed(t.pkgNames.Package(pkg.Path, t.pkgTemplate), msgName)
 	}
 
-	return t.transformType(pkg, types[0], &Message{}, &Field{})
+	typ := t.transformType(pkg, types[0], &Message{}, &Field{})
+	if typ == nil {
+		report.Skip("type %s of func %s is not supported. RPC %s will not be generated", types[0], name, name)
+	}
+	return typ
 }
 
 func (t *Transformer) createMessageFromTypes(pkg *Package, name string, types []scanner.Type, fieldPrefix string) *Message {
-	msg := &Message{Name: name}
+	msg := &Message{Name: name, fieldNaming: pkg.fieldNaming, jsonCasing: t.jsonCasing}
 	for i, typ := range types {
 		f := t.transformField(pkg, msg, &scanner.Field{
 			Name: fmt.Sprintf("%s%d", capitalize(fieldPrefix), i+1),
@@ -167,6 +555,8 @@ func (t *Transformer) createMessageFromTypes(pkg *Package, name string, types []
 		}, i+1)
 		if f != nil {
 			msg.Fields = append(msg.Fields, f)
+		} else {
+			report.Skip("type %s of message %s is not supported, ignoring its field", typ, name)
 		}
 	}
 	return msg
@@ -178,24 +568,101 @@ func capitalize(s string) string {
 
 func (t *Transformer) transformEnum(e *scanner.Enum) *Enum {
 	enum := &Enum{
-		Docs:    e.Doc,
-		Name:    e.Name,
-		Options: t.defaultOptionsForScannedEnum(e),
+		Docs:     e.Doc,
+		Name:     e.Name,
+		Options:  t.defaultOptionsForScannedEnum(e),
+		Position: e.Position,
+		goFile:   e.File,
+	}
+
+	var values []*scanner.EnumValue
+	for _, v := range e.Values {
+		if t.constValues && v.Value < 0 {
+			report.SkipAt(e.Position, v.Name, "value %s of enum %s is negative, ignoring it", v.Name, e.Name)
+			continue
+		}
+		values = append(values, v)
+	}
+
+	var goNames = make([]string, len(values))
+	for i, v := range values {
+		goNames[i] = v.Name
 	}
+	names := enumValueNames(t.enumNamingOf(e), t.acronyms, e.Name, goNames)
 
-	for i, v := range e.Values {
-		enum.Values = append(enum.Values, &EnumValue{
+	numbers := make(map[uint]bool, len(values))
+	for i, v := range values {
+		val := &EnumValue{
 			Docs:  v.Doc,
-			Name:  toUpperSnakeCase(v.Name),
+			Name:  names[i],
 			Value: uint(i),
 			Options: Options{
 				"(gogoproto.enumvalue_customname)": NewStringValue(v.Name),
 			},
-		})
+		}
+		if t.constValues {
+			val.Value = uint(v.Value)
+		}
+		if v.Deprecated {
+			val.Options[deprecatedOption] = NewLiteralValue("true")
+		}
+
+		if numbers[val.Value] {
+			enum.Options["allow_alias"] = NewLiteralValue("true")
+		}
+		numbers[val.Value] = true
+		enum.Values = append(enum.Values, val)
 	}
+
+	// proto3 requires the first value to be zero
+	sort.SliceStable(enum.Values, func(i, j int) bool {
+		return enum.Values[i].Value == 0 && enum.Values[j].Value != 0
+	})
+
+	if len(enum.Values) == 0 || enum.Values[0].Value != 0 {
+		if t.unspecifiedOf(e) {
+			enum.Values = append([]*EnumValue{{
+				Name: t.acronyms.toUpperSnakeCase(e.Name) + "_UNSPECIFIED",
+			}}, enum.Values...)
+		} else {
+			report.WarnAt(report.CodeMissingZeroValue, e.Position, e.Name, "enum %s does not have a zero value, which is required by proto3", e.Name)
+		}
+	}
+
 	return enum
 }
 
+// enumNamingOf returns the naming strategy of the given enum, which is the
+// transformer's one unless the enum has its own.
+func (t *Transformer) enumNamingOf(e *scanner.Enum) EnumNaming {
+	if e.Naming == "" {
+		return t.enumNaming
+	}
+
+	naming, err := ParseEnumNaming(e.Naming)
+	if err != nil {
+		report.WarnAt(report.CodeInvalidComment, e.Position, e.Name, "enum %s has an invalid naming, ignoring it: %s", e.Name, err)
+		return t.enumNaming
+	}
+	return naming
+}
+
+// unspecifiedOf reports whether an unspecified value is added to the given
+// enum if it does not have a zero value, which is the transformer's choice
+// unless the enum has its own.
+func (t *Transformer) unspecifiedOf(e *scanner.Enum) bool {
+	if e.Unspecified == "" {
+		return t.unspecified
+	}
+
+	unspecified, err := strconv.ParseBool(e.Unspecified)
+	if err != nil {
+		report.WarnAt(report.CodeInvalidComment, e.Position, e.Name, "enum %s has an invalid enum-unspecified comment, ignoring it: %q is not a boolean", e.Name, e.Unspecified)
+		return t.unspecified
+	}
+	return unspecified
+}
+
 func (t *Transformer) defaultOptionsForScannedEnum(e *scanner.Enum) (opts Options) {
 	opts = Options{
 		"(gogoproto.enumdecl)":            NewLiteralValue("false"),
@@ -206,22 +673,61 @@ func (t *Transformer) defaultOptionsForScannedEnum(e *scanner.Enum) (opts Option
 		opts["(gogoproto.goproto_enum_stringer)"] = NewLiteralValue("false")
 	}
 
+	if e.Deprecated {
+		opts[deprecatedOption] = NewLiteralValue("true")
+	}
+
 	return
 }
 
 func (t *Transformer) transformStruct(pkg *Package, s *scanner.Struct) *Message {
 	msg := &Message{
-		Docs:    s.Doc,
-		Name:    s.Name,
-		Options: t.defaultOptionsForScannedMessage(s),
+		Docs:        s.Doc,
+		Name:        s.Name,
+		Options:     t.defaultOptionsForScannedMessage(s),
+		fieldNaming: t.structFieldNamingOf(pkg, s),
+		jsonCasing:  t.jsonCasingOf(s),
+		Position:    s.Position,
+		goFile:      s.File,
 	}
 
+	var order []int
+	if freqs, ok := t.profile[pkg.Path+"."+s.Name]; ok {
+		order = t.fieldOrder(s, freqs)
+		msg.profiled = true
+	}
+
+	positions := fieldPositions(s, order)
 	for i, f := range s.Fields {
-		field := t.transformField(pkg, msg, f, i+1)
+		if f.Reserved {
+			msg.Reserve(uint(positions[i]))
+			t.traceField(pkg, msg, f, nil, positions[i])
+			continue
+		}
+
+		rule := t.rules.forField(pkg, s, f, fieldName(msg.fieldNaming, t.acronyms, f))
+		if rule.skip {
+			continue
+		}
+
+		if rule.rename != "" {
+			renamed := *f
+			renamed.ProtoName = rule.rename
+			f = &renamed
+		}
+
+		field := t.transformField(pkg, msg, f, positions[i])
+		if field != nil {
+			rule.retypeField(msg, f, field)
+			rule.setOptions(field.Options)
+		}
+
+		t.traceField(pkg, msg, f, field, positions[i])
 		if field == nil {
-			msg.Reserve(uint(i) + 1)
-			report.Warn("field %q of struct %q has an invalid type, ignoring field but reserving its position", f.Name, s.Name)
+			msg.Reserve(uint(positions[i]))
+			report.SkipAt(f.Position, s.Name+"."+f.Name, "field %q of struct %q has an invalid type, ignoring field but reserving its position", f.Name, s.Name)
 		} else {
+			field.autoNumbered = positions[i] != f.ProtoID
 			msg.Fields = append(msg.Fields, field)
 		}
 	}
@@ -229,16 +735,72 @@ func (t *Transformer) transformStruct(pkg *Package, s *scanner.Struct) *Message
 	return msg
 }
 
+// fieldPositions returns the position of every field of the struct. Fields
+// with an explicit id keep it and the rest are numbered sequentially in the
+// given order of their indexes, or in their own order if it is nil, skipping
+// the ids already taken.
+func fieldPositions(s *scanner.Struct, order []int) []int {
+	var (
+		positions = make([]int, len(s.Fields))
+		taken     = make(map[int]bool)
+	)
+
+	for i, f := range s.Fields {
+		if f.ProtoID == 0 {
+			continue
+		}
+
+		if taken[f.ProtoID] {
+			report.WarnAt(report.CodeDuplicateID, f.Position, s.Name+"."+f.Name, "field %q of struct %q has the id %d, which is already taken, numbering it automatically", f.Name, s.Name, f.ProtoID)
+			continue
+		}
+
+		taken[f.ProtoID] = true
+		positions[i] = f.ProtoID
+	}
+
+	if order == nil {
+		order = make([]int, len(s.Fields))
+		for i := range order {
+			order[i] = i
+		}
+	}
+
+	next := 1
+	for _, i := range order {
+		if positions[i] != 0 {
+			continue
+		}
+
+		for taken[next] {
+			next++
+		}
+		positions[i] = next
+		taken[next] = true
+	}
+
+	return positions
+}
+
 func (t *Transformer) defaultOptionsForScannedMessage(s *scanner.Struct) (opts Options) {
 	opts = Options{
 		"(gogoproto.typedecl)":        NewLiteralValue("false"),
 		"(gogoproto.goproto_getters)": NewLiteralValue("false"),
 	}
 
+	// The Go types of inline structs are declared by the generated code.
+	if s.Inline {
+		delete(opts, "(gogoproto.typedecl)")
+	}
+
 	if s.IsStringer {
 		opts["(gogoproto.goproto_stringer)"] = NewLiteralValue("false")
 	}
 
+	if s.Deprecated {
+		opts[deprecatedOption] = NewLiteralValue("true")
+	}
+
 	return
 }
 
@@ -248,12 +810,14 @@ func (t *Transformer) transformField(pkg *Package, msg *Message, field *scanner.
 		repeated = field.Type.IsRepeated()
 	)
 
+	name := fieldName(msg.fieldNaming, t.acronyms, field)
 	f := &Field{
 		Docs:     field.Doc,
-		Name:     toLowerSnakeCase(field.Name),
-		Options:  t.defaultOptionsForStructField(field),
+		Name:     name,
+		Options:  t.defaultOptionsForStructField(field, name, msg.jsonCasing),
 		Pos:      pos,
 		Repeated: repeated,
+		Position: field.Position,
 	}
 
 	// []byte is the only repeated type that maps to
@@ -262,6 +826,9 @@ func (t *Transformer) transformField(pkg *Package, msg *Message, field *scanner.
 	if isByteSlice(field.Type) {
 		typ = NewBasic("bytes")
 		f.Repeated = false
+		if alias, ok := field.Type.(*scanner.Alias); ok {
+			f.Options[castTypeOption] = NewStringValue(sourceCastType(pkg, alias.Type))
+		}
 	} else {
 		typ = t.transformType(pkg, field.Type, msg, f)
 		if typ == nil {
@@ -270,16 +837,150 @@ func (t *Transformer) transformField(pkg *Package, msg *Message, field *scanner.
 	}
 
 	f.Type = typ
+	f.Optional = t.optionalFields && !f.Repeated && isPointer(field.Type) && t.isScalar(typ)
+
+	if field.Lazy {
+		if t.isMessage(typ) {
+			f.Options[lazyOption] = NewLiteralValue("true")
+		} else {
+			report.WarnAt(report.CodeInvalidTag, field.Position, msg.Name+"."+field.Name, "field %q of struct %q is marked as lazy but it is not a message, ignoring it", field.Name, msg.Name)
+		}
+	}
+
+	if field.JSONOmit {
+		f.Options[jsonOmitOption] = NewLiteralValue("true")
+		pkg.importPackage(optionsImport, optionsPackage)
+	}
+
+	if field.Unit != "" {
+		f.Options[unitOption] = NewStringValue(field.Unit)
+		pkg.importPackage(optionsImport, optionsPackage)
+	}
+
+	if field.Sensitive {
+		f.Options[sensitiveOption] = NewLiteralValue("true")
+		pkg.importPackage(optionsImport, optionsPackage)
+	}
+
+	if len(field.Validate) > 0 {
+		opts := t.validateOptions(msg.Name, f, field.Validate)
+		for name, v := range opts {
+			f.Options[name] = v
+		}
+
+		if len(opts) > 0 {
+			pkg.importPackage(validateImport, validatePackage)
+		}
+	}
+
+	if opt := t.definedOnlyOption(f); opt != "" {
+		f.Options[opt] = NewLiteralValue("true")
+		pkg.importPackage(validateImport, validatePackage)
+	}
 
 	return f
 }
 
-func (t *Transformer) defaultOptionsForStructField(field *scanner.Field) Options {
+// isPointer reports whether the type is behind a pointer. Unlike IsNullable,
+// it is false for basic types that are not pointers.
+func isPointer(typ scanner.Type) bool {
+	switch ty := typ.(type) {
+	case *scanner.Basic:
+		return ty.Nullable
+	case *scanner.Named:
+		return ty.Nullable
+	case *scanner.Alias:
+		return isPointer(ty.Type)
+	}
+	return false
+}
+
+// isScalar reports whether the protobuf type is a scalar, that is, a basic
+// type other than bytes or an enum.
+func (t *Transformer) isScalar(typ Type) bool {
+	switch ty := typ.(type) {
+	case *Basic:
+		return ty.Name != "bytes"
+	case *Alias:
+		return t.isScalar(ty.Underlying)
+	case *Named:
+		if src, ok := ty.Source().(*scanner.Named); ok {
+			return t.IsEnum(src.Path, src.Name)
+		}
+	}
+	return false
+}
+
+// isMessage reports whether the type is a message, which are the only types
+// that can be decoded lazily.
+func (t *Transformer) isMessage(typ Type) bool {
+	n, ok := typ.(*Named)
+	return ok && !t.isScalar(n)
+}
+
+// fieldNamingOf returns the naming strategy of the fields of the given
+// package, which is the transformer's one unless the package has its own.
+func (t *Transformer) fieldNamingOf(p *scanner.Package) FieldNaming {
+	if p.FieldNaming == "" {
+		return t.fieldNaming
+	}
+
+	naming, err := ParseFieldNaming(p.FieldNaming)
+	if err != nil {
+		report.Warn("package %s has an invalid field naming, ignoring it: %s", p.Path, err)
+		return t.fieldNaming
+	}
+	return naming
+}
+
+// structFieldNamingOf returns the naming strategy of the fields of the given
+// struct, which is the one of its package unless the struct has its own.
+func (t *Transformer) structFieldNamingOf(pkg *Package, s *scanner.Struct) FieldNaming {
+	if s.FieldNaming == "" {
+		return pkg.fieldNaming
+	}
+
+	naming, err := ParseFieldNaming(s.FieldNaming)
+	if err != nil {
+		report.WarnAt(report.CodeInvalidComment, s.Position, s.Name, "struct %s has an invalid field naming, ignoring it: %s", s.Name, err)
+		return pkg.fieldNaming
+	}
+	return naming
+}
+
+// jsonCasingOf returns the casing in JSON of the fields of the given struct,
+// which is the transformer's one unless the struct has its own.
+func (t *Transformer) jsonCasingOf(s *scanner.Struct) JSONCasing {
+	if s.JSONCasing == "" {
+		return t.jsonCasing
+	}
+
+	casing, err := ParseJSONCasing(s.JSONCasing)
+	if err != nil {
+		report.WarnAt(report.CodeInvalidComment, s.Position, s.Name, "struct %s has an invalid JSON casing, ignoring it: %s", s.Name, err)
+		return t.jsonCasing
+	}
+	return casing
+}
+
+// defaultOptionsForStructField returns the options of the field with the
+// given name in protobuf. Fields whose name in JSON, which is the one in
+// their json tag or the one given by the casing, is not the one protobuf
+// would give them get it with the json_name option.
+func (t *Transformer) defaultOptionsForStructField(field *scanner.Field, name string, casing JSONCasing) Options {
 	opts := make(Options)
-	if generator.CamelCase(toLowerSnakeCase(field.Name)) != field.Name {
+	if generator.CamelCase(name) != field.Name {
 		opts["(gogoproto.customname)"] = NewStringValue(field.Name)
 	}
 
+	if n := jsonFieldName(casing, t.acronyms, field, name); n != jsonName(name) {
+		opts["json_name"] = NewStringValue(n)
+	}
+
+	if field.Deprecated {
+		opts[deprecatedOption] = NewLiteralValue("true")
+	}
+
 	if t.needsNotNullableOption(field.Type) {
 		opts["(gogoproto.nullable)"] = NewLiteralValue("false")
 	}
@@ -311,6 +1012,25 @@ func (t *Transformer) transformType(pkg *Package, typ scanner.Type, msg *Message
 	switch ty := typ.(type) {
 	case *scanner.Named:
 		protoType := t.findMapping(ty.String())
+		if protoType == nil && t.IsArray(ty.Path, ty.Name) {
+			protoType = &ProtoType{
+				Name:       "bytes",
+				Basic:      true,
+				Decorators: CustomType(sourceCastType(pkg, ty)),
+			}
+		}
+
+		if msgType, ok := t.lazyMessages[ty.String()]; ok && protoType == nil {
+			return t.transformLazy(pkg, ty, msgType, msg, field)
+		}
+
+		if protoType == nil && ty.Message {
+			protoType = t.messageType(ty)
+			if protoType == nil {
+				return nil
+			}
+		}
+
 		if protoType != nil {
 			pkg.Import(protoType)
 			protoType.Decorate(pkg, msg, field)
@@ -319,8 +1039,15 @@ func (t *Transformer) transformType(pkg *Package, typ scanner.Type, msg *Message
 			return n
 		}
 
+		if file, ok := t.messageFiles[ty.Path]; ok && ty.Path != pkg.Path {
+			pkg.importPackage(file.Import, file.Package)
+			n := NewNamed(file.Package, ty.Name)
+			n.SetSource(ty)
+			return n
+		}
+
 		pkg.ImportFromPath(ty.Path)
-		n := NewNamed(toProtobufPkg(ty.Path), ty.Name)
+		n := NewNamed(t.pkgNames.Package(ty.Path, t.pkgTemplate), ty.Name)
 		n.SetSource(ty)
 		return n
 	case *scanner.Basic:
@@ -335,12 +1062,33 @@ func (t *Transformer) transformType(pkg *Package, typ scanner.Type, msg *Message
 
 		report.Warn("basic type %q is not defined in the mappings, ignoring", ty.Name)
 	case *scanner.Map:
-		m := NewMap(
-			t.transformType(pkg, ty.Key, msg, field),
-			t.transformType(pkg, ty.Value, msg, field),
-		)
+		if v, ok := ty.Value.(*scanner.Named); ok && t.lazyMessages[v.String()] != nil {
+			report.Warn("lazy type %s can not be the value of a map, ignoring", v.Name)
+			return nil
+		}
+
+		key := t.transformMapKey(pkg, ty.Key, msg, field)
+		if key == nil {
+			return nil
+		}
+
+		value := t.transformMapEntry(pkg, ty.Value, msg, field, castValueOption)
+		if value == nil {
+			return nil
+		}
+
+		m := NewMap(key, value)
 		m.SetSource(ty)
 		return m
+	case *scanner.Set:
+		// Sets are represented as a repeated field of their elements, the
+		// field is already marked as repeated because the set is.
+		elem := t.transformType(pkg, ty.Elem, msg, field)
+		if elem == nil {
+			return nil
+		}
+		elem.SetSource(ty)
+		return elem
 	case *scanner.Alias:
 		n := NewAlias(
 			t.transformType(pkg, ty.Type, msg, field),
@@ -353,7 +1101,7 @@ func (t *Transformer) transformType(pkg *Package, typ scanner.Type, msg *Message
 
 		// Repeated types cannot use casttype :(
 		if !ty.IsRepeated() {
-			field.Options["(gogoproto.casttype)"] = NewStringValue(castType(pkg, n.Type))
+			field.Options[castTypeOption] = NewStringValue(castType(pkg, n.Type))
 		}
 		return n
 	}
@@ -361,19 +1109,118 @@ func (t *Transformer) transformType(pkg *Package, typ scanner.Type, msg *Message
 	return nil
 }
 
-func castType(pkg *Package, typ Type) string {
-	switch t := typ.Source().(type) {
-	case *scanner.Named:
-		if pkg.Path == t.Path {
-			return t.Name
+// transformLazy returns the type of a field of the given lazy type, which is
+// its message, converted with the methods of gogoproto.customtype of the lazy
+// type and marked as lazy. Slices of pointers to lazy types are not
+// supported, as gogo can not append them.
+func (t *Transformer) transformLazy(pkg *Package, ty, msgType *scanner.Named, msg *Message, field *Field) Type {
+	if ty.IsRepeated() && ty.IsNullable() {
+		report.Warn("lazy type %s can not be repeated as a pointer, use a slice of values instead, ignoring", ty.Name)
+		return nil
+	}
+
+	n := t.transformType(pkg, msgType, msg, field)
+	if n == nil {
+		return nil
+	}
+
+	CustomType(sourceCastType(pkg, ty)).Run(pkg, msg, field)
+	field.Options[lazyOption] = NewLiteralValue("true")
+	n.SetSource(ty)
+	return n
+}
+
+const (
+	castTypeOption  = "(gogoproto.casttype)"
+	castKeyOption   = "(gogoproto.castkey)"
+	castValueOption = "(gogoproto.castvalue)"
+)
+
+// transformMapKey returns the type of the keys of a map, which protobuf only
+// allows to be integers, bools or strings, or nil if they are not. Enums are
+// not valid keys either, so their keys become int32 and are cast back to the
+// enum with the castkey option, like the rest of the keys that have to be
+// cast.
+func (t *Transformer) transformMapKey(pkg *Package, typ scanner.Type, msg *Message, field *Field) Type {
+	if n, ok := typ.(*scanner.Named); ok && t.IsEnum(n.Path, n.Name) {
+		key := NewBasic("int32")
+		key.SetSource(n)
+		setOption(field, castKeyOption, NewStringValue(sourceCastType(pkg, n)))
+		return key
+	}
+
+	key := t.transformMapEntry(pkg, typ, msg, field, castKeyOption)
+	if key == nil {
+		return nil
+	}
+
+	if !isMapKeyType(key) {
+		report.WarnAt(report.CodeUnsupportedType, field.Position, msg.Name+"."+field.Name, "map key type %s of field %q is not supported by protobuf, expecting an integer, a bool or a string, ignoring the field", typ, field.Name)
+		return nil
+	}
+	return key
+}
+
+// transformMapEntry returns the type of the keys or the values of a map,
+// moving the casttype option they need, if any, to the given cast option of
+// the field, as casttype would cast the whole map.
+func (t *Transformer) transformMapEntry(pkg *Package, typ scanner.Type, msg *Message, field *Field, castOption string) Type {
+	entry := &Field{Name: field.Name, Options: make(Options)}
+	result := t.transformType(pkg, typ, msg, entry)
+	for name, v := range entry.Options {
+		if name == castTypeOption {
+			name = castOption
 		}
-		return t.TypeString()
+		setOption(field, name, v)
 	}
-	return typ.Source().TypeString()
+	return result
+}
+
+// isMapKeyType reports whether the type can be the key of a map in protobuf.
+func isMapKeyType(typ Type) bool {
+	if a, ok := typ.(*Alias); ok {
+		return isMapKeyType(a.Underlying)
+	}
+
+	b, ok := typ.(*Basic)
+	if !ok {
+		return false
+	}
+
+	switch b.Name {
+	case "int32", "int64", "uint32", "uint64", "sint32", "sint64",
+		"fixed32", "fixed64", "sfixed32", "sfixed64", "bool", "string":
+		return true
+	}
+	return false
+}
+
+func setOption(field *Field, name string, v OptionValue) {
+	if field.Options == nil {
+		field.Options = make(Options)
+	}
+	field.Options[name] = v
+}
+
+func castType(pkg *Package, typ Type) string {
+	return sourceCastType(pkg, typ.Source())
+}
+
+// sourceCastType returns the name of the Go type to cast a field to in the
+// package, which is not qualified if the type belongs to it.
+func sourceCastType(pkg *Package, typ scanner.Type) string {
+	if t, ok := typ.(*scanner.Named); ok && pkg.Path == t.Path {
+		return t.Name
+	}
+	return typ.TypeString()
 }
 
 func (t *Transformer) findMapping(name string) *ProtoType {
 	typ := t.mappings[name]
+	if typ == nil {
+		typ = registeredMappings[name]
+	}
+
 	if typ == nil {
 		typ = DefaultMappings[name]
 	}
@@ -408,6 +1255,20 @@ func removeLastError(types []scanner.Type) ([]scanner.Type, bool) {
 	return types, false
 }
 
+// isNotMessage reports whether the given named type is mapped to a basic
+// type, like time.Duration, or it is one of the custom types of the
+// customtypes or anyconv packages, an array type or a lazy type, so its Go
+// type is not a message even though it has a name.
+func (t *Transformer) isNotMessage(typ scanner.Type) bool {
+	n := typ.(*scanner.Named)
+	if n.Path == customTypesPkg || n.Path == anyconvPkg || t.IsArray(n.Path, n.Name) || t.lazyMessages[n.String()] != nil {
+		return true
+	}
+
+	protoType := t.findMapping(n.String())
+	return protoType != nil && protoType.Basic
+}
+
 func isNamed(typ scanner.Type) bool {
 	_, ok := typ.(*scanner.Named)
 	return ok
@@ -427,9 +1288,14 @@ func isError(typ scanner.Type) bool {
 	return false
 }
 
+// isByteSlice reports whether the type is a []byte or a named type declared
+// as one, like `type Blob []byte`, but not a slice of them.
 func isByteSlice(typ scanner.Type) bool {
-	if t, ok := typ.(*scanner.Basic); ok && typ.IsRepeated() {
-		return t.Name == "byte"
+	switch t := typ.(type) {
+	case *scanner.Basic:
+		return t.IsRepeated() && t.Name == "byte"
+	case *scanner.Alias:
+		return !t.Type.IsRepeated() && isByteSlice(t.Underlying)
 	}
 	return false
 }
@@ -452,10 +1318,35 @@ func toProtobufPkg(path string) string {
 	return pkg
 }
 
+// toLowerSnakeCase converts the name to lower snake case with the default
+// acronyms.
 func toLowerSnakeCase(s string) string {
+	return Acronyms{}.toLowerSnakeCase(s)
+}
+
+func toUpperSnakeCase(s string) string {
+	return Acronyms{}.toUpperSnakeCase(s)
+}
+
+// toLowerSnakeCase converts the name to lower snake case. An uppercase letter
+// starts a new word unless it follows another one, so the acronyms are the
+// only way to split a run of uppercase letters, e.g. HTTPServerURL is
+// http_server_url.
+func (a Acronyms) toLowerSnakeCase(s string) string {
 	var buf bytes.Buffer
 	var lastWasUpper bool
-	for i, r := range s {
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		if n := a.prefixLen(rs[i:]); n > 0 && !lastWasUpper {
+			if i != 0 {
+				buf.WriteRune('_')
+			}
+			buf.WriteString(strings.ToLower(string(rs[i : i+n])))
+			i += n - 1
+			continue
+		}
+
+		r := rs[i]
 		if unicode.IsUpper(r) && i != 0 && !lastWasUpper {
 			buf.WriteRune('_')
 		}
@@ -465,13 +1356,18 @@ func toLowerSnakeCase(s string) string {
 	return buf.String()
 }
 
-func toUpperSnakeCase(s string) string {
-	return strings.ToUpper(toLowerSnakeCase(s))
+func (a Acronyms) toUpperSnakeCase(s string) string {
+	return strings.ToUpper(a.toLowerSnakeCase(s))
 }
 
 func (t *Transformer) defaultOptionsForPackage(p *scanner.Package) Options {
+	goPackage := p.Name
+	if t.goImportPaths {
+		goPackage = p.Path + ";" + p.Name
+	}
+
 	return Options{
-		"go_package":                 NewStringValue(p.Name),
+		"go_package":                 NewStringValue(goPackage),
 		"(gogoproto.sizer_all)":      NewLiteralValue("false"),
 		"(gogoproto.protosizer_all)": NewLiteralValue("true"),
 	}
@@ -2,6 +2,7 @@ package protobuf
 
 import (
 	"fmt"
+	gotoken "go/token"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -23,10 +24,19 @@ func TestToLowerSnakeCase(t *testing.T) {
 		{"foo1barBaz", "foo1bar_baz"},
 		{"fooBAR", "foo_bar"},
 		{"FBar", "fbar"},
+		{"ID", "id"},
+		{"UserID", "user_id"},
+		{"UserIDs", "user_ids"},
+		{"HTTPServerURL", "http_server_url"},
+		{"APIKey", "api_key"},
+		{"UUIDString", "uuid_string"},
+		{"Identity", "identity"},
+		{"IDentity", "identity"},
+		{"HTTP2Server", "http2_server"},
 	}
 
 	for _, c := range cases {
-		require.Equal(t, c.expected, toLowerSnakeCase(c.input))
+		require.Equal(t, c.expected, toLowerSnakeCase(c.input), c.input)
 	}
 }
 
@@ -121,6 +131,24 @@ func (s *TransformerSuite) TestIsStruct() {
 	s.False(s.t.IsStruct("paquete", "Type"), "paquete.Type is not an enum")
 }
 
+func (s *TransformerSuite) TestTransformFieldArray() {
+	ts := NewTypeSet()
+	ts.Add("foo", "Hash")
+	s.t.SetArraySet(ts)
+
+	s.True(s.t.IsArray("foo", "Hash"), "foo.Hash is an array")
+	s.False(s.t.IsArray("foo", "Point"), "foo.Point is not an array")
+
+	f := s.t.transformField(&Package{Path: "foo"}, &Message{}, &scanner.Field{
+		Name: "Hash",
+		Type: scanner.NewNamed("foo", "Hash"),
+	}, 1)
+
+	s.assertType(NewBasic("bytes"), f.Type, "type")
+	s.Equal(NewStringValue("Hash"), f.Options["(gogoproto.customtype)"], "the array type is in the same package")
+	s.Equal(NewLiteralValue("false"), f.Options["(gogoproto.nullable)"], "value arrays are not nullable")
+}
+
 func (s *TransformerSuite) TestFindMapping() {
 	cases := []struct {
 		name         string
@@ -226,6 +254,16 @@ func (s *TransformerSuite) TestTransformType() {
 			NewBasic("string"),
 			"",
 		},
+		{
+			scanner.NewNamed("github.com/gogo/protobuf/types", "Any"),
+			NewNamed("google.protobuf", "Any"),
+			"google/protobuf/any.proto",
+		},
+		{
+			scanner.NewNamed("github.com/gogo/protobuf/types", "Value"),
+			NewNamed("google.protobuf", "Value"),
+			"google/protobuf/struct.proto",
+		},
 		{
 			scanner.NewMap(
 				scanner.NewBasic("string"),
@@ -239,6 +277,11 @@ func (s *TransformerSuite) TestTransformType() {
 			NewBasic("int64"),
 			"",
 		},
+		{
+			scanner.NewSet(scanner.NewBasic("string")),
+			NewBasic("string"),
+			"",
+		},
 		{
 			scanner.NewAlias(
 				scanner.NewNamed("foo", "Bar"),
@@ -289,6 +332,16 @@ func (s *TransformerSuite) TestTransformField() {
 				Options: Options{},
 			},
 		},
+		{
+			"Tags",
+			scanner.NewSet(scanner.NewBasic("string")),
+			&Field{
+				Name:     "tags",
+				Type:     NewBasic("string"),
+				Repeated: true,
+				Options:  Options{},
+			},
+		},
 		{
 			"BazBar",
 			repeated(scanner.NewBasic("int")),
@@ -320,6 +373,26 @@ func (s *TransformerSuite) TestTransformField() {
 				Options: Options{},
 			},
 		},
+		{
+			"NullableInt",
+			nullable(scanner.NewBasic("int64")),
+			&Field{
+				Name:     "nullable_int",
+				Type:     NewBasic("int64"),
+				Optional: true,
+				Options:  Options{},
+			},
+		},
+		{
+			"NullableEnum",
+			nullable(scanner.NewNamed("my/pckg", "MyEnum")),
+			&Field{
+				Name:     "nullable_enum",
+				Type:     NewNamed("my.pckg", "MyEnum"),
+				Optional: true,
+				Options:  Options{},
+			},
+		},
 		{
 			"NonNullableType",
 			scanner.NewNamed("my/pckg", "hello"),
@@ -377,11 +450,37 @@ func (s *TransformerSuite) TestTransformField() {
 				Options: Options{},
 			},
 		},
+		{
+			"Blob",
+			scanner.NewAlias(
+				scanner.NewNamed("my/pckg", "Blob"),
+				repeated(scanner.NewBasic("byte")),
+			),
+			&Field{
+				Name: "blob",
+				Type: NewBasic("bytes"),
+				Options: Options{
+					"(gogoproto.casttype)": NewStringValue("my/pckg.Blob"),
+				},
+			},
+		},
+		{
+			"Raw",
+			scanner.NewNamed("encoding/json", "RawMessage"),
+			&Field{
+				Name: "raw",
+				Type: NewBasic("bytes"),
+				Options: Options{
+					"(gogoproto.casttype)": NewStringValue("encoding/json.RawMessage"),
+				},
+			},
+		},
 	}
 
 	ts := NewTypeSet()
 	ts.Add("my/pckg", "MyEnum")
 	s.t.SetEnumSet(ts)
+	s.t.SetOptionalFields(true)
 
 	for _, c := range cases {
 		f := s.t.transformField(&Package{}, &Message{}, &scanner.Field{
@@ -394,10 +493,21 @@ func (s *TransformerSuite) TestTransformField() {
 			s.Equal(c.expected.Name, f.Name, fmt.Sprintf("Name in %s", c.name))
 			s.assertType(c.expected.Type, f.Type, c.name)
 			s.Equal(c.expected.Options, f.Options, fmt.Sprintf("Options in %s", c.name))
+			s.Equal(c.expected.Optional, f.Optional, fmt.Sprintf("Optional in %s", c.name))
 		}
 	}
 }
 
+func (s *TransformerSuite) TestTransformFieldOptionalDisabled() {
+	for _, typ := range []scanner.Type{
+		nullable(scanner.NewBasic("int64")),
+		nullable(scanner.NewBasic("string")),
+	} {
+		f := s.t.transformField(&Package{}, &Message{}, &scanner.Field{Name: "Foo", Type: typ}, 0)
+		s.False(f.Optional, "pointers are not optional fields unless enabled")
+	}
+}
+
 func (s *TransformerSuite) TestTransformStruct() {
 	st := &scanner.Struct{
 		Docs: mkDocs("fancy struct"),
@@ -431,6 +541,64 @@ func (s *TransformerSuite) TestTransformStruct() {
 	s.Equal(NewLiteralValue("false"), msg.Options["(gogoproto.goproto_getters)"], "should drop getters by default")
 }
 
+func (s *TransformerSuite) TestTransformStructInline() {
+	st := &scanner.Struct{
+		Name:   "PageUser",
+		Inline: true,
+		Fields: []*scanner.Field{{Name: "Token", Type: scanner.NewBasic("string")}},
+	}
+
+	msg := s.t.transformStruct(&Package{}, st)
+	s.NotContains(msg.Options, "(gogoproto.typedecl)", "should declare the type")
+	s.Equal(NewLiteralValue("false"), msg.Options["(gogoproto.goproto_getters)"])
+}
+
+func (s *TransformerSuite) TestTransformStructReservedField() {
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "Bar", Type: scanner.NewBasic("string")},
+			{Name: "Ch", Reserved: true},
+			{Name: "Baz", Type: scanner.NewBasic("string")},
+		},
+	}
+
+	msg := s.t.transformStruct(&Package{}, st)
+	s.Equal([]uint{2}, msg.Reserved)
+	s.Equal(2, len(msg.Fields))
+	s.Equal(3, msg.Fields[1].Pos)
+}
+
+func (s *TransformerSuite) TestTransformStructFieldTags() {
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "Bar", Type: scanner.NewBasic("string"), ProtoID: 2},
+			{Name: "Baz", Type: scanner.NewBasic("string"), ProtoName: "qux"},
+			{Name: "Other", Type: scanner.NewBasic("string")},
+			{Name: "Dup", Type: scanner.NewBasic("string"), ProtoID: 2},
+		},
+	}
+
+	report.TestMode()
+	msg := s.t.transformStruct(&Package{}, st)
+	s.Len(report.MessageStack(), 1)
+	report.EndTestMode()
+
+	var (
+		names     []string
+		positions []int
+	)
+	for _, f := range msg.Fields {
+		names = append(names, f.Name)
+		positions = append(positions, f.Pos)
+	}
+
+	s.Equal([]string{"bar", "qux", "other", "dup"}, names)
+	s.Equal([]int{2, 1, 3, 4}, positions)
+	s.Equal(NewStringValue("Baz"), msg.Fields[1].Options["(gogoproto.customname)"])
+}
+
 func (s *TransformerSuite) TestTransformStructIsStringer() {
 	st := &scanner.Struct{
 		Name: "Foo",
@@ -495,6 +663,65 @@ func (s *TransformerSuite) TestTransformFuncMultiple() {
 	s.assertField(msg.Fields[1], "result2", NewBasic("bool"))
 }
 
+func (s *TransformerSuite) TestTransformInterface() {
+	iface := &scanner.Interface{
+		Docs: mkDocs("Store stores things"),
+		Name: "Store",
+		Methods: []*scanner.Func{
+			{
+				Name:     "Get",
+				Receiver: scanner.NewNamed("baz", "Store"),
+				Input:    []scanner.Type{scanner.NewBasic("string")},
+				Output:   []scanner.Type{nullable(scanner.NewNamed("baz", "Thing"))},
+			},
+		},
+	}
+	pkg := &Package{Path: "baz"}
+	svc := s.t.transformInterface(pkg, iface, nameSet{})
+
+	s.Equal("Store", svc.Name)
+	s.Equal([]string{"Store stores things"}, svc.Docs)
+	s.Equal(1, len(svc.RPCs))
+
+	rpc := svc.RPCs[0]
+	s.Equal("Get", rpc.Name)
+	s.Equal("Store", rpc.Recv)
+	s.assertType(NewGeneratedNamed("baz", "Store_GetRequest"), rpc.Input, "rpc input")
+	s.assertType(NewNamed("baz", "Thing"), rpc.Output, "rpc output")
+	s.Equal(1, len(pkg.Messages))
+	s.Equal("Store_GetRequest", pkg.Messages[0].Name)
+}
+
+func (s *TransformerSuite) TestTransformGroupsMethodsByReceiver() {
+	pkg := s.t.Transform(&scanner.Package{
+		Path: "baz",
+		Funcs: []*scanner.Func{
+			{Name: "DoFoo"},
+			{Name: "Get", Receiver: scanner.NewNamed("baz", "Store")},
+			{Name: "DoBar"},
+			{Name: "Put", Receiver: nullable(scanner.NewNamed("baz", "Store"))},
+			{Name: "Get", Receiver: scanner.NewNamed("baz", "Cache")},
+		},
+	})
+
+	s.Equal(3, len(pkg.Services))
+	s.Equal("BazService", pkg.Services[0].Name)
+	s.Equal([]string{"DoFoo", "DoBar"}, rpcNames(pkg.Services[0]))
+	s.Equal("StoreService", pkg.Services[1].Name)
+	s.Equal([]string{"Get", "Put"}, rpcNames(pkg.Services[1]))
+	s.Equal("CacheService", pkg.Services[2].Name)
+	s.Equal([]string{"Get"}, rpcNames(pkg.Services[2]))
+	s.assertType(NewGeneratedNamed("baz", "Cache_GetRequest"), pkg.Services[2].RPCs[0].Input, "rpc input")
+}
+
+func rpcNames(svc *Service) []string {
+	var names []string
+	for _, rpc := range svc.RPCs {
+		names = append(names, rpc.Name)
+	}
+	return names
+}
+
 func (s *TransformerSuite) TestTransformFuncInputRegistered() {
 	fn := &scanner.Func{
 		Name: "DoFoo",
@@ -531,6 +758,27 @@ func (s *TransformerSuite) TestTransformFuncOutputRegistered() {
 	s.Nil(rpc)
 }
 
+func (s *TransformerSuite) TestTransformFuncSkipped() {
+	report.TestMode()
+	defer report.EndTestMode()
+
+	fn := &scanner.Func{
+		Name:   "DoFoo",
+		Input:  []scanner.Type{scanner.NewBasic("complex128")},
+		Output: []scanner.Type{scanner.NewNamed("foo", "Foo")},
+	}
+	s.NotNil(s.t.transformFunc(&Package{}, fn, nameSet{}))
+
+	fn.Receiver = scanner.NewBasic("int")
+	fn.Position = gotoken.Position{Filename: "foo.go", Line: 7, Column: 6}
+	s.Nil(s.t.transformFunc(&Package{}, fn, nameSet{}))
+
+	s.Equal(report.Problems{
+		{Message: "type complex128 of message DoFooRequest is not supported, ignoring its field"},
+		{Position: fn.Position, Symbol: "DoFoo", Message: "invalid receiver type for func DoFoo"},
+	}, report.Skipped())
+}
+
 func (s *TransformerSuite) TestTransformFuncEmpty() {
 	fn := &scanner.Func{Name: "DoFoo"}
 	pkg := &Package{Path: "baz"}
@@ -602,14 +850,69 @@ func (s *TransformerSuite) TestTransformFunc1NamedArg() {
 	s.assertSource(rpc.Output, fn.Output[0])
 }
 
+func (s *TransformerSuite) TestTransformFuncNotMessageNamedArgs() {
+	fn := &scanner.Func{
+		Name: "DoFoo",
+		Input: []scanner.Type{
+			scanner.NewNamed(customTypesPkg, "Date"),
+		},
+		Output: []scanner.Type{
+			scanner.NewNamed("url", "URL"),
+		},
+	}
+	pkg := new(Package)
+	rpc := s.t.transformFunc(pkg, fn, nameSet{})
+
+	s.NotNil(rpc)
+	s.assertType(NewGeneratedNamed("", "DoFooRequest"), rpc.Input, "rpc input")
+	s.assertType(NewGeneratedNamed("", "DoFooResponse"), rpc.Output, "rpc output")
+	s.Equal(2, len(pkg.Messages), "two messages should have been created")
+	s.assertField(pkg.Messages[0].Fields[0], "arg1", NewNamed("google.type", "Date"))
+	s.assertField(pkg.Messages[1].Fields[0], "result1", NewBasic("string"))
+}
+
+func (s *TransformerSuite) TestTransformFuncStream() {
+	fn := &scanner.Func{
+		Name:         "Watch",
+		Input:        []scanner.Type{scanner.NewBasic("int")},
+		Output:       []scanner.Type{scanner.NewNamed("foo", "Event"), scanner.NewNamed("", "error")},
+		Stream:       scanner.ChanStream,
+		StreamBuffer: scanner.StreamBuffer{Size: 16, Drop: true},
+	}
+	pkg := new(Package)
+	rpc := s.t.transformFunc(pkg, fn, nameSet{})
+
+	s.NotNil(rpc)
+	s.True(rpc.IsStreaming())
+	s.True(rpc.HasError)
+	s.Equal(scanner.StreamBuffer{Size: 16, Drop: true}, rpc.StreamBuffer)
+	s.assertType(NewGeneratedNamed("", "WatchRequest"), rpc.Input, "rpc input")
+	s.assertType(NewNamed("foo", "Event"), rpc.Output, "rpc output")
+	s.Equal(1, len(pkg.Messages), "only the request is created")
+
+	for _, output := range [][]scanner.Type{
+		{scanner.NewBasic("int")},
+		{scanner.NewNamed("url", "URL")},
+		{scanner.NewNamed("", "error")},
+	} {
+		fn := &scanner.Func{Name: "Watch", Output: output, Stream: scanner.SeqStream}
+		s.Nil(s.t.transformFunc(new(Package), fn, nameSet{}), "streams of values that are not messages are skipped")
+	}
+}
+
 func (s *TransformerSuite) TestTransformFuncReceiver() {
 	fn := &scanner.Func{
 		Name:     "DoFoo",
 		Receiver: scanner.NewNamed("foo", "Fooer"),
 	}
-	rpc := s.t.transformFunc(new(Package), fn, nameSet{})
+	pkg := new(Package)
+	rpc := s.t.transformFunc(pkg, fn, nameSet{})
 	s.NotNil(rpc)
-	s.Equal("Fooer_DoFoo", rpc.Name)
+	s.Equal("DoFoo", rpc.Name)
+	s.Equal("Fooer", rpc.Recv)
+	s.assertType(NewGeneratedNamed("", "Fooer_DoFooRequest"), rpc.Input, "rpc input")
+	s.assertType(NewGeneratedNamed("", "Fooer_DoFooResponse"), rpc.Output, "rpc output")
+	s.Equal("FooerService", serviceNameForRPC(pkg, rpc))
 }
 
 func (s *TransformerSuite) TestTransformFuncComments() {
@@ -620,10 +923,264 @@ func (s *TransformerSuite) TestTransformFuncComments() {
 	}
 	rpc := s.t.transformFunc(new(Package), fn, nameSet{})
 	s.NotNil(rpc)
-	s.Equal("Fooer_DoFoo", rpc.Name)
+	s.Equal("DoFoo", rpc.Name)
 	s.Equal("fooo bar", strings.Join(rpc.Docs, "\n"))
 }
 
+func (s *TransformerSuite) TestTransformFuncHTTPRule() {
+	fn := &scanner.Func{
+		Name:   "CreateUser",
+		Input:  []scanner.Type{nullable(scanner.NewNamed("foo", "User"))},
+		Output: []scanner.Type{nullable(scanner.NewNamed("foo", "User"))},
+		HTTP:   &scanner.HTTPRule{Method: "post", Path: "/v1/users", Body: "*"},
+	}
+	pkg := &Package{Path: "foo"}
+	rpc := s.t.transformFunc(pkg, fn, nameSet{})
+
+	s.NotNil(rpc)
+	s.Equal(fn.HTTP, rpc.HTTP)
+	s.Equal(
+		NewAggregateValue(
+			AggregateField{"post", NewStringValue("/v1/users")},
+			AggregateField{"body", NewStringValue("*")},
+		),
+		rpc.Options["(google.api.http)"],
+	)
+	s.Equal(`{ post: "/v1/users", body: "*" }`, rpc.Options["(google.api.http)"].String())
+	s.Equal([]string{"google/api/annotations.proto"}, pkg.Imports)
+
+	pkg.Services = []*Service{{Name: "FooService", RPCs: []*RPC{rpc}}}
+	pkg.PruneImports()
+	s.Equal([]string{"google/api/annotations.proto"}, pkg.Imports)
+}
+
+func (s *TransformerSuite) TestTransformFuncMaxConcurrency() {
+	fn := &scanner.Func{
+		Name:           "Expensive",
+		Input:          []scanner.Type{nullable(scanner.NewNamed("foo", "User"))},
+		Output:         []scanner.Type{nullable(scanner.NewNamed("foo", "User"))},
+		MaxConcurrency: 32,
+	}
+	rpc := s.t.transformFunc(&Package{Path: "foo"}, fn, nameSet{})
+
+	s.NotNil(rpc)
+	s.Equal(32, rpc.MaxConcurrency)
+}
+
+func (s *TransformerSuite) TestTransformFuncIdempotent() {
+	fn := &scanner.Func{
+		Name:       "GetUser",
+		Input:      []scanner.Type{nullable(scanner.NewNamed("foo", "User"))},
+		Output:     []scanner.Type{nullable(scanner.NewNamed("foo", "User"))},
+		Idempotent: true,
+	}
+	rpc := s.t.transformFunc(&Package{Path: "foo"}, fn, nameSet{})
+
+	s.NotNil(rpc)
+	s.True(rpc.Idempotent)
+	s.Equal("IDEMPOTENT", rpc.Options["idempotency_level"].String())
+}
+
+func (s *TransformerSuite) TestTransformDeprecated() {
+	deprecated := scanner.Docs{Doc: []string{"Deprecated: use Bar"}, Deprecated: true}
+	st := &scanner.Struct{
+		Docs: deprecated,
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Docs: deprecated, Name: "Old", Type: scanner.NewBasic("int")},
+			{Name: "New", Type: scanner.NewBasic("int")},
+		},
+	}
+
+	msg := s.t.transformStruct(&Package{}, st)
+	s.Equal([]string{"Deprecated: use Bar"}, msg.Docs)
+	s.Equal(NewLiteralValue("true"), msg.Options["deprecated"])
+	s.Equal(NewLiteralValue("true"), msg.Fields[0].Options["deprecated"])
+	s.Nil(msg.Fields[1].Options["deprecated"])
+
+	val := mkEnumVal("", "Old", 1)
+	val.Deprecated = true
+	enum := s.t.transformEnum(&scanner.Enum{
+		Docs:   deprecated,
+		Name:   "Status",
+		Values: []*scanner.EnumValue{mkEnumVal("", "New", 0), val},
+	})
+	s.Equal(NewLiteralValue("true"), enum.Options["deprecated"])
+	s.Nil(enum.Values[0].Options["deprecated"])
+	s.Equal(NewLiteralValue("true"), enum.Values[1].Options["deprecated"])
+
+	rpc := s.t.transformFunc(&Package{Path: "foo"}, &scanner.Func{
+		Docs:   deprecated,
+		Name:   "DoFoo",
+		Input:  []scanner.Type{nullable(scanner.NewNamed("foo", "User"))},
+		Output: []scanner.Type{nullable(scanner.NewNamed("foo", "User"))},
+	}, nameSet{})
+	s.Equal(Options{"deprecated": NewLiteralValue("true")}, rpc.Options)
+}
+
+func (s *TransformerSuite) TestTransformLazyField() {
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "Report", Type: nullable(scanner.NewNamed("foo", "Report")), Lazy: true},
+			{Name: "Count", Type: scanner.NewBasic("int"), Lazy: true},
+			{Name: "Other", Type: nullable(scanner.NewNamed("foo", "Report"))},
+		},
+	}
+
+	msg := s.t.transformStruct(&Package{Path: "foo"}, st)
+	s.Equal(NewLiteralValue("true"), msg.Fields[0].Options["lazy"])
+	s.Nil(msg.Fields[1].Options["lazy"], "only messages can be lazy")
+	s.Nil(msg.Fields[2].Options["lazy"])
+}
+
+func (s *TransformerSuite) TestTransformLazyType() {
+	s.t.SetLazyMessages(map[string]*scanner.Named{
+		"foo.LazyReport": scanner.NewNamed("foo", "Report").(*scanner.Named),
+	})
+	defer s.t.SetLazyMessages(nil)
+
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "Report", Type: nullable(scanner.NewNamed("foo", "LazyReport"))},
+			{Name: "Last", Type: scanner.NewNamed("foo", "LazyReport")},
+			{Name: "Reports", Type: repeated(scanner.NewNamed("foo", "LazyReport"))},
+			{Name: "Pointers", Type: repeated(nullable(scanner.NewNamed("foo", "LazyReport")))},
+			{Name: "ByName", Type: scanner.NewMap(scanner.NewBasic("string"), scanner.NewNamed("foo", "LazyReport"))},
+		},
+	}
+
+	msg := s.t.transformStruct(&Package{Path: "foo"}, st)
+	s.Len(msg.Fields, 3, "lazy types in maps and slices of pointers are ignored")
+	for _, f := range msg.Fields {
+		s.assertType(NewNamed("foo", "Report"), f.Type, f.Name)
+		s.Equal(NewLiteralValue("true"), f.Options["lazy"], f.Name)
+		s.Equal(NewStringValue("LazyReport"), f.Options["(gogoproto.customtype)"], f.Name)
+	}
+	s.Nil(msg.Fields[0].Options["(gogoproto.nullable)"])
+	s.Equal(NewLiteralValue("false"), msg.Fields[1].Options["(gogoproto.nullable)"])
+	s.Equal(NewLiteralValue("false"), msg.Fields[2].Options["(gogoproto.nullable)"])
+	s.True(msg.Fields[2].Repeated)
+
+	s.True(s.t.isNotMessage(scanner.NewNamed("foo", "LazyReport")), "the Go type of a lazy type is not a message")
+	s.True(s.t.isNotMessage(scanner.NewNamed(anyconvPkg, "Any")), "the Go type of an Any is not a message")
+}
+
+func (s *TransformerSuite) TestTransformJSONOmitField() {
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "Secret", Type: scanner.NewBasic("string"), JSONOmit: true},
+			{Name: "Name", Type: scanner.NewBasic("string")},
+		},
+	}
+
+	pkg := &Package{Path: "foo"}
+	msg := s.t.transformStruct(pkg, st)
+	s.Equal(NewLiteralValue("true"), msg.Fields[0].Options["(proteus.json_omit)"])
+	s.Nil(msg.Fields[1].Options["(proteus.json_omit)"])
+	s.Equal([]string{"gitlab.com/ThatTomPerson/proteus/options/options.proto"}, pkg.Imports)
+}
+
+func (s *TransformerSuite) TestTransformUnitField() {
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "Timeout", Type: scanner.NewBasic("int64"), Unit: "ms"},
+			{Name: "Name", Type: scanner.NewBasic("string")},
+		},
+	}
+
+	pkg := &Package{Path: "foo"}
+	msg := s.t.transformStruct(pkg, st)
+	s.Equal(NewStringValue("ms"), msg.Fields[0].Options["(proteus.unit)"])
+	s.Equal("ms", msg.Fields[0].Unit())
+	s.Nil(msg.Fields[1].Options["(proteus.unit)"])
+	s.Equal("", msg.Fields[1].Unit())
+	s.Equal([]string{"gitlab.com/ThatTomPerson/proteus/options/options.proto"}, pkg.Imports)
+}
+
+func (s *TransformerSuite) TestTransformSensitiveField() {
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "Password", Type: scanner.NewBasic("string"), Sensitive: true},
+			{Name: "Name", Type: scanner.NewBasic("string")},
+		},
+	}
+
+	pkg := &Package{Path: "foo"}
+	msg := s.t.transformStruct(pkg, st)
+	s.Equal(NewLiteralValue("true"), msg.Fields[0].Options["(proteus.sensitive)"])
+	s.True(msg.Fields[0].Sensitive())
+	s.False(msg.Fields[1].Sensitive())
+	s.Equal([]string{"gitlab.com/ThatTomPerson/proteus/options/options.proto"}, pkg.Imports)
+}
+
+func (s *TransformerSuite) TestTransformPositions() {
+	report.TestMode()
+	defer report.EndTestMode()
+
+	st := &scanner.Struct{
+		Name:     "Foo",
+		Position: gotoken.Position{Filename: "foo.go", Line: 3, Column: 6},
+		Fields: []*scanner.Field{
+			{
+				Name:     "Name",
+				Type:     scanner.NewBasic("string"),
+				Position: gotoken.Position{Filename: "foo.go", Line: 4, Column: 2},
+			},
+			{
+				Name:     "Ch",
+				Type:     scanner.NewBasic("string"),
+				Lazy:     true,
+				Position: gotoken.Position{Filename: "foo.go", Line: 5, Column: 2},
+			},
+		},
+	}
+
+	msg := s.t.transformStruct(&Package{Path: "foo"}, st)
+	s.Equal(st.Position, msg.Position)
+	s.Equal(st.Fields[0].Position, msg.Fields[0].Position)
+	s.Equal([]string{
+		`WARN: foo.go:5:2: field "Ch" of struct "Foo" is marked as lazy but it is not a message, ignoring it`,
+	}, report.MessageStack())
+}
+
+func (s *TransformerSuite) TestTransformMapField() {
+	enums := NewTypeSet()
+	enums.Add("foo", "Color")
+	s.t.SetEnumSet(enums)
+
+	color := scanner.NewNamed("foo", "Color")
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "ByColor", Type: scanner.NewMap(color, scanner.NewBasic("string"))},
+			{Name: "Colors", Type: scanner.NewMap(scanner.NewBasic("string"), color)},
+			{Name: "Counts", Type: scanner.NewMap(scanner.NewBasic("int"), scanner.NewBasic("int"))},
+			{Name: "Rates", Type: scanner.NewMap(scanner.NewBasic("float64"), scanner.NewBasic("string"))},
+			{Name: "Reports", Type: scanner.NewMap(scanner.NewNamed("foo", "Report"), scanner.NewBasic("string"))},
+		},
+	}
+
+	msg := s.t.transformStruct(&Package{Path: "foo"}, st)
+	s.Len(msg.Fields, 3, "maps with keys that are not integers, bools or strings are ignored")
+
+	s.Equal("map<int32, string>", msg.Fields[0].Type.String())
+	s.Equal(Options{"(gogoproto.castkey)": NewStringValue("Color")}, msg.Fields[0].Options)
+
+	s.Equal("map<string, foo.Color>", msg.Fields[1].Type.String())
+	s.Equal(Options{}, msg.Fields[1].Options)
+
+	s.Equal("map<int64, int64>", msg.Fields[2].Type.String())
+	s.Equal(Options{
+		"(gogoproto.castkey)":   NewStringValue("int"),
+		"(gogoproto.castvalue)": NewStringValue("int"),
+	}, msg.Fields[2].Options)
+}
+
 func (s *TransformerSuite) TestTransformFuncReceiverInvalid() {
 	fn := &scanner.Func{
 		Name:     "DoFoo",
@@ -633,6 +1190,43 @@ func (s *TransformerSuite) TestTransformFuncReceiverInvalid() {
 	s.Nil(rpc)
 }
 
+func (s *TransformerSuite) TestTransformFuncOptions() {
+	fn := &scanner.Func{
+		Name:       "List",
+		IsVariadic: true,
+		Input:      []scanner.Type{scanner.NewBasic("string")},
+		Output:     []scanner.Type{repeated(scanner.NewBasic("string"))},
+		FuncOptions: []*scanner.FuncOption{
+			{Name: "WithLimit", Field: "Limit", Type: nullable(scanner.NewBasic("int64"))},
+		},
+	}
+	pkg := new(Package)
+	rpc := s.t.transformFunc(pkg, fn, nameSet{})
+
+	s.NotNil(rpc)
+	s.True(rpc.IsVariadic)
+	s.Equal([]*FuncOption{{Func: "WithLimit", Field: "Limit", Implicit: true}}, rpc.FuncOptions)
+	s.assertType(NewGeneratedNamed("", "ListRequest"), rpc.Input, "rpc input")
+
+	msg := pkg.Messages[0]
+	s.Equal("ListRequest", msg.Name)
+	s.Len(msg.Fields, 2)
+	s.assertField(msg.Fields[0], "arg1", NewBasic("string"))
+	s.assertField(msg.Fields[1], "limit", NewBasic("int64"))
+	s.False(msg.Fields[1].Optional, "options are not optional fields unless enabled")
+	s.Equal(2, msg.Fields[1].Pos)
+
+	s.t.SetOptionalFields(true)
+	pkg = new(Package)
+	rpc = s.t.transformFunc(pkg, fn, nameSet{})
+	s.Equal([]*FuncOption{{Func: "WithLimit", Field: "Limit"}}, rpc.FuncOptions)
+	s.True(pkg.Messages[0].Fields[1].Optional, "options are optional fields if enabled")
+
+	fn.Name = "Count"
+	fn.FuncOptions[0].Type = scanner.NewMap(scanner.NewBasic("float64"), scanner.NewBasic("string"))
+	s.Nil(s.t.transformFunc(new(Package), fn, nameSet{}), "RPCs with options of unsupported types are not generated")
+}
+
 func (s *TransformerSuite) TestTransformFuncRepeatedSingle() {
 	fn := &scanner.Func{
 		Name:       "DoFoo",
@@ -673,9 +1267,9 @@ func (s *TransformerSuite) TestTransformEnum() {
 		Docs: mkDocs("foo bar baz"),
 		Name: "Foo",
 		Values: []*scanner.EnumValue{
-			mkEnumVal("fooo bar", "Foo"),
-			mkEnumVal("baaar bar", "Bar"),
-			mkEnumVal("barbaz bar", "BarBaz"),
+			mkEnumVal("fooo bar", "Foo", 0),
+			mkEnumVal("baaar bar", "Bar", 1),
+			mkEnumVal("barbaz bar", "BarBaz", 2),
 		},
 	})
 
@@ -693,9 +1287,9 @@ func (s *TransformerSuite) TestTransformEnumIsStringer() {
 	enum := s.t.transformEnum(&scanner.Enum{
 		Name: "Foo",
 		Values: []*scanner.EnumValue{
-			mkEnumVal("fooo bar", "Foo"),
-			mkEnumVal("baaar bar", "Bar"),
-			mkEnumVal("barbaz bar", "BarBaz"),
+			mkEnumVal("fooo bar", "Foo", 0),
+			mkEnumVal("baaar bar", "Bar", 1),
+			mkEnumVal("barbaz bar", "BarBaz", 2),
 		},
 		IsStringer: true,
 	})
@@ -710,6 +1304,233 @@ func (s *TransformerSuite) TestTransformEnumIsStringer() {
 	s.Equal(NewLiteralValue("false"), enum.Options["(gogoproto.goproto_enum_stringer)"], "should drop declaration by default")
 }
 
+func (s *TransformerSuite) TestTransformEnumValues() {
+	e := &scanner.Enum{
+		Name: "Color",
+		Values: []*scanner.EnumValue{
+			mkEnumVal("", "Red", 1),
+			mkEnumVal("", "Default", 0),
+			mkEnumVal("", "Blue", 5),
+			mkEnumVal("", "Invalid", -1),
+		},
+	}
+
+	enum := s.t.transformEnum(e)
+	s.Equal([]string{"RED", "DEFAULT", "BLUE", "INVALID"}, enumNames(enum), "values are numbered in order by default")
+	for i, v := range enum.Values {
+		s.Equal(uint(i), v.Value, v.Name)
+	}
+	s.NotContains(enum.Options, "allow_alias")
+
+	s.t.SetEnumConstValues(true)
+	defer s.t.SetEnumConstValues(false)
+
+	enum = s.t.transformEnum(e)
+	s.Equal(3, len(enum.Values), "negative values should be ignored")
+	s.Equal("DEFAULT", enum.Values[0].Name, "zero value should be the first")
+	s.Equal(uint(0), enum.Values[0].Value)
+	s.Equal("RED", enum.Values[1].Name)
+	s.Equal(uint(1), enum.Values[1].Value)
+	s.Equal("BLUE", enum.Values[2].Name)
+	s.Equal(uint(5), enum.Values[2].Value)
+	s.NotContains(enum.Options, "allow_alias")
+
+	e.Values = append(e.Values, mkEnumVal("", "Navy", 5))
+	enum = s.t.transformEnum(e)
+	s.Equal(4, len(enum.Values))
+	s.Equal(uint(5), enum.Values[3].Value)
+	s.Equal(NewLiteralValue("true"), enum.Options["allow_alias"], "repeated values are aliases")
+}
+
+func (s *TransformerSuite) TestTransformEnumUnspecified() {
+	e := &scanner.Enum{
+		Name: "FooBar",
+		Values: []*scanner.EnumValue{
+			mkEnumVal("", "Foo", 1),
+			mkEnumVal("", "Bar", 2),
+		},
+	}
+
+	s.t.SetEnumConstValues(true)
+	defer s.t.SetEnumConstValues(false)
+
+	enum := s.t.transformEnum(e)
+	s.Equal(2, len(enum.Values), "unspecified value is not added by default")
+
+	s.t.SetUnspecifiedEnumValues(true)
+	defer s.t.SetUnspecifiedEnumValues(false)
+
+	enum = s.t.transformEnum(e)
+	s.Equal(3, len(enum.Values))
+	s.Equal("FOO_BAR_UNSPECIFIED", enum.Values[0].Name)
+	s.Equal(uint(0), enum.Values[0].Value)
+	s.Equal("FOO", enum.Values[1].Name)
+
+	e.Unspecified = "false"
+	enum = s.t.transformEnum(e)
+	s.Equal(2, len(enum.Values), "enum overrides the transformer's choice")
+
+	s.t.SetUnspecifiedEnumValues(false)
+	e.Unspecified = "true"
+	enum = s.t.transformEnum(e)
+	s.Equal(3, len(enum.Values), "enum overrides the transformer's choice")
+
+	e.Unspecified = "invalid"
+	enum = s.t.transformEnum(e)
+	s.Equal(2, len(enum.Values), "invalid choice is ignored")
+
+	s.t.SetUnspecifiedEnumValues(true)
+	e.Unspecified = ""
+	e.Values = append(e.Values, mkEnumVal("", "Baz", 0))
+	enum = s.t.transformEnum(e)
+	s.Equal(3, len(enum.Values), "unspecified value is not added if there is a zero value")
+	s.Equal("BAZ", enum.Values[0].Name)
+}
+
+func (s *TransformerSuite) TestTransformEnumNaming() {
+	e := &scanner.Enum{
+		Name: "Color",
+		Values: []*scanner.EnumValue{
+			mkEnumVal("", "ColorRed", 0),
+			mkEnumVal("", "ColorBlue", 1),
+		},
+	}
+
+	s.Equal([]string{"COLOR_RED", "COLOR_BLUE"}, enumNames(s.t.transformEnum(e)))
+
+	s.t.SetEnumNaming(StripNaming)
+	defer s.t.SetEnumNaming("")
+	s.Equal([]string{"RED", "BLUE"}, enumNames(s.t.transformEnum(e)))
+
+	e.Naming = "verbatim"
+	enum := s.t.transformEnum(e)
+	s.Equal([]string{"ColorRed", "ColorBlue"}, enumNames(enum))
+	s.Equal(NewStringValue("ColorRed"), enum.Values[0].Options["(gogoproto.enumvalue_customname)"])
+
+	e.Naming = "invalid"
+	s.Equal([]string{"RED", "BLUE"}, enumNames(s.t.transformEnum(e)), "invalid naming is ignored")
+}
+
+func (s *TransformerSuite) TestTransformFieldNaming() {
+	p := &scanner.Package{
+		Path: "foo",
+		Name: "foo",
+		Structs: []*scanner.Struct{
+			{
+				Name: "User",
+				Fields: []*scanner.Field{
+					{Name: "UserID", Type: scanner.NewBasic("int"), JSONName: "uid"},
+					{Name: "Name", Type: scanner.NewBasic("string")},
+				},
+			},
+		},
+	}
+
+	fields := s.t.Transform(p).Messages[0].Fields
+	s.Equal([]string{"user_id", "name"}, fieldNames(fields))
+
+	s.t.SetFieldNaming(KeepCaseFieldNaming)
+	defer s.t.SetFieldNaming("")
+	fields = s.t.Transform(p).Messages[0].Fields
+	s.Equal([]string{"UserID", "Name"}, fieldNames(fields))
+	s.Nil(fields[0].Options["(gogoproto.customname)"])
+
+	p.FieldNaming = "json"
+	fields = s.t.Transform(p).Messages[0].Fields
+	s.Equal([]string{"uid", "name"}, fieldNames(fields))
+	s.Equal(NewStringValue("UserID"), fields[0].Options["(gogoproto.customname)"])
+	s.Nil(fields[0].Options["json_name"])
+
+	p.Structs[0].FieldNaming = "snake"
+	fields = s.t.Transform(p).Messages[0].Fields
+	s.Equal([]string{"user_id", "name"}, fieldNames(fields), "struct naming overrides the package's")
+
+	p.Structs[0].FieldNaming = ""
+	p.FieldNaming = "invalid"
+	fields = s.t.Transform(p).Messages[0].Fields
+	s.Equal([]string{"UserID", "Name"}, fieldNames(fields), "invalid naming is ignored")
+}
+
+func (s *TransformerSuite) TestTransformFieldJSONName() {
+	st := &scanner.Struct{
+		Name: "User",
+		Fields: []*scanner.Field{
+			{Name: "UserID", Type: scanner.NewBasic("int"), JSONName: "userId"},
+			{Name: "Name", Type: scanner.NewBasic("string"), JSONName: "full-name"},
+			{Name: "Nick", Type: scanner.NewBasic("string")},
+		},
+	}
+
+	msg := s.t.transformStruct(&Package{}, st)
+	s.Nil(msg.Fields[0].Options["json_name"], "json name is the default one")
+	s.Equal(NewStringValue("full-name"), msg.Fields[1].Options["json_name"])
+	s.Nil(msg.Fields[2].Options["json_name"])
+}
+
+func (s *TransformerSuite) TestTransformJSONCasing() {
+	st := &scanner.Struct{
+		Name: "User",
+		Fields: []*scanner.Field{
+			{Name: "UserID", Type: scanner.NewBasic("int")},
+			{Name: "Name", Type: scanner.NewBasic("string"), JSONName: "fullName"},
+		},
+	}
+
+	msg := s.t.transformStruct(&Package{}, st)
+	s.Nil(msg.Fields[0].Options["json_name"])
+
+	s.t.SetJSONCasing(SnakeCaseJSON)
+	defer s.t.SetJSONCasing("")
+	msg = s.t.transformStruct(&Package{}, st)
+	s.Equal(NewStringValue("user_id"), msg.Fields[0].Options["json_name"])
+	s.Equal(NewStringValue("fullName"), msg.Fields[1].Options["json_name"], "json tag is kept")
+
+	st.JSONCasing = "go"
+	msg = s.t.transformStruct(&Package{}, st)
+	s.Equal(NewStringValue("UserID"), msg.Fields[0].Options["json_name"])
+
+	st.JSONCasing = "invalid"
+	msg = s.t.transformStruct(&Package{}, st)
+	s.Equal(NewStringValue("user_id"), msg.Fields[0].Options["json_name"], "invalid casing is ignored")
+}
+
+func (s *TransformerSuite) TestTransformAcronyms() {
+	st := &scanner.Struct{
+		Name:   "Server",
+		Fields: []*scanner.Field{{Name: "GRPCAddr", Type: scanner.NewBasic("string")}},
+	}
+	enum := &scanner.Enum{
+		Name:   "Mode",
+		Values: []*scanner.EnumValue{mkEnumVal("", "GRPCOn", 0)},
+	}
+
+	s.Equal([]string{"grpcaddr"}, fieldNames(s.t.transformStruct(&Package{}, st).Fields))
+
+	acronyms, err := NewAcronyms("GRPC")
+	s.Nil(err)
+	s.t.SetAcronyms(acronyms)
+	defer s.t.SetAcronyms(Acronyms{})
+	s.Equal([]string{"grpc_addr"}, fieldNames(s.t.transformStruct(&Package{}, st).Fields))
+	s.Equal("GRPC_ON", s.t.transformEnum(enum).Values[0].Name)
+	s.Equal("grpcaddr", toLowerSnakeCase("GRPCAddr"), "other transformers are not changed")
+}
+
+func fieldNames(fields []*Field) []string {
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func enumNames(e *Enum) []string {
+	var names []string
+	for _, v := range e.Values {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
 func (s *TransformerSuite) TestTransform() {
 	pkgs := s.fixtures()
 	pkg := s.t.Transform(pkgs[0])
@@ -726,7 +1547,7 @@ func (s *TransformerSuite) TestTransform() {
 	}, pkg.Imports)
 	s.Equal(1, len(pkg.Enums))
 	s.Equal(5, len(pkg.Messages))
-	s.Equal(0, len(pkg.RPCs))
+	s.Equal(0, len(pkg.Services))
 
 	pkg = s.t.Transform(pkgs[1])
 	s.Equal("gopkg.in.srcd.proteus.v1.fixtures.subpkg", pkg.Name)
@@ -749,7 +1570,21 @@ func (s *TransformerSuite) TestTransform() {
 		s.True(hasString(m.Name, msgs), fmt.Sprintf("should have message %s", m.Name))
 	}
 
-	s.Equal(4, len(pkg.RPCs))
+	var services = map[string]int{
+		"SubpkgService":      1,
+		"MyContainerService": 1,
+		"PointService":       2,
+	}
+	s.Equal(len(services), len(pkg.Services))
+	for _, svc := range pkg.Services {
+		s.Equal(services[svc.Name], len(svc.RPCs), fmt.Sprintf("rpcs of service %s", svc.Name))
+	}
+}
+
+func (s *TransformerSuite) TestTransformGoImportPaths() {
+	s.t.SetGoImportPaths(true)
+	pkg := s.t.Transform(s.fixtures()[1])
+	s.Equal(NewStringValue("gitlab.com/ThatTomPerson/proteus/fixtures/subpkg;subpkg"), pkg.Options["go_package"])
 }
 
 func hasString(str string, coll []string) bool {
@@ -845,10 +1680,11 @@ func nullable(t scanner.Type) scanner.Type {
 	return t
 }
 
-func mkEnumVal(doc, name string) *scanner.EnumValue {
+func mkEnumVal(doc, name string, value int64) *scanner.EnumValue {
 	return &scanner.EnumValue{
 		mkDocs(doc),
 		name,
+		value,
 	}
 }
 
@@ -0,0 +1,198 @@
+package protobuf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+const (
+	validateImport  = "validate/validate.proto"
+	validatePackage = "validate"
+)
+
+// validateKind returns the kind of protoc-gen-validate rules that apply to a
+// field, e.g. "string" or "repeated". It is empty if no rules apply.
+func (t *Transformer) validateKind(f *Field) string {
+	if f.Repeated {
+		return "repeated"
+	}
+
+	typ := f.Type
+	if a, ok := typ.(*Alias); ok {
+		typ = a.Underlying
+	}
+
+	switch ty := typ.(type) {
+	case *Map:
+		return "map"
+	case *Basic:
+		return ty.Name
+	case *Named:
+		if t.isScalar(ty) {
+			return "enum"
+		}
+		return "message"
+	}
+	return ""
+}
+
+// validateOptions converts the rules of the validate tag of a field into
+// protoc-gen-validate options, e.g. "max=10" on a string field is
+// "(validate.rules).string.max_len = 10". Unsupported rules are ignored with
+// a warning.
+func (t *Transformer) validateOptions(msgName string, f *Field, rules []string) Options {
+	var (
+		opts = make(Options)
+		kind = t.validateKind(f)
+	)
+
+	for _, r := range rules {
+		if r == "omitempty" {
+			continue
+		}
+
+		if r == "dive" {
+			report.WarnAt(report.CodeUnsupportedValidation, f.Position, msgName+"."+f.Name, "validation rules of the elements of field %s.%s are not supported, ignoring them", msgName, f.Name)
+			break
+		}
+
+		name, value := r, ""
+		if idx := strings.Index(r, "="); idx >= 0 {
+			name, value = r[:idx], r[idx+1:]
+		}
+
+		rule, val, ok := validateRule(kind, name, value)
+		if !ok {
+			report.WarnAt(report.CodeUnsupportedValidation, f.Position, msgName+"."+f.Name, "validation rule %q of field %s.%s is not supported, ignoring it", r, msgName, f.Name)
+			continue
+		}
+
+		for _, r := range rule {
+			key := fmt.Sprintf("(validate.rules).%s.%s", kind, r)
+			if prev, ok := opts[key]; ok && minRules[r] && lessUint(val, prev) {
+				continue
+			}
+			opts[key] = val
+		}
+	}
+
+	return opts
+}
+
+// minRules are the protoc-gen-validate rules of the minimum lengths, which
+// keep the strictest value when several validate rules set them, e.g. the
+// min_len of 3 of "min=3,required" instead of the 1 of required.
+var minRules = map[string]bool{
+	"min_len":   true,
+	"min_items": true,
+	"min_pairs": true,
+}
+
+func lessUint(a, b OptionValue) bool {
+	x, _ := strconv.ParseUint(a.String(), 10, 64)
+	y, _ := strconv.ParseUint(b.String(), 10, 64)
+	return x < y
+}
+
+var stringFormats = map[string]string{
+	"email":    "email",
+	"uri":      "uri",
+	"url":      "uri",
+	"hostname": "hostname",
+	"ip":       "ip",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"uuid":     "uuid",
+}
+
+// validateRule returns the names of the protoc-gen-validate rules of the
+// given kind for a validate rule with the given name and value, and the value
+// those rules have. It returns false if the rule is not supported.
+func validateRule(kind, name, value string) ([]string, OptionValue, bool) {
+	var (
+		literal = NewLiteralValue(value)
+		yes     = NewLiteralValue("true")
+		one     = NewLiteralValue("1")
+	)
+
+	switch kind {
+	case "string", "bytes":
+		if format, ok := stringFormats[name]; ok && kind == "string" && value == "" {
+			return []string{format}, yes, true
+		}
+
+		switch name {
+		case "required":
+			return []string{"min_len"}, one, value == ""
+		case "min":
+			return []string{"min_len"}, literal, isUint(value)
+		case "max":
+			return []string{"max_len"}, literal, isUint(value)
+		case "len":
+			return []string{"len"}, literal, isUint(value)
+		case "eq":
+			if kind == "string" {
+				return []string{"const"}, NewStringValue(value), true
+			}
+		}
+	case "repeated", "map":
+		var min, max = "min_items", "max_items"
+		if kind == "map" {
+			min, max = "min_pairs", "max_pairs"
+		}
+
+		switch name {
+		case "required":
+			return []string{min}, one, value == ""
+		case "min":
+			return []string{min}, literal, isUint(value)
+		case "max":
+			return []string{max}, literal, isUint(value)
+		case "len":
+			return []string{min, max}, literal, isUint(value)
+		}
+	case "message":
+		if name == "required" {
+			return []string{"required"}, yes, value == ""
+		}
+	case "int32", "int64", "sint32", "sint64", "sfixed32", "sfixed64",
+		"uint32", "uint64", "fixed32", "fixed64", "float", "double":
+		var rule string
+		switch name {
+		case "min", "gte":
+			rule = "gte"
+		case "max", "lte":
+			rule = "lte"
+		case "gt", "lt":
+			rule = name
+		case "eq":
+			rule = "const"
+		default:
+			return nil, nil, false
+		}
+		return []string{rule}, literal, isNumber(kind, value)
+	}
+
+	return nil, nil, false
+}
+
+func isUint(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 64)
+	return err == nil
+}
+
+func isNumber(kind, value string) bool {
+	var err error
+	switch {
+	case kind == "float" || kind == "double":
+		_, err = strconv.ParseFloat(value, 64)
+	case strings.HasPrefix(kind, "u") || strings.HasPrefix(kind, "fixed"):
+		_, err = strconv.ParseUint(value, 10, 64)
+	default:
+		_, err = strconv.ParseInt(value, 10, 64)
+	}
+	return err == nil
+}
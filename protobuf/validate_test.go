@@ -0,0 +1,124 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func TestValidateOptions(t *testing.T) {
+	cases := []struct {
+		name     string
+		field    *Field
+		rules    []string
+		expected Options
+	}{
+		{
+			"string",
+			&Field{Name: "a", Type: NewBasic("string")},
+			[]string{"required", "max=10", "email", "omitempty"},
+			Options{
+				"(validate.rules).string.min_len": NewLiteralValue("1"),
+				"(validate.rules).string.max_len": NewLiteralValue("10"),
+				"(validate.rules).string.email":   NewLiteralValue("true"),
+			},
+		},
+		{
+			"string min and required",
+			&Field{Name: "a", Type: NewBasic("string")},
+			[]string{"min=3", "required"},
+			Options{"(validate.rules).string.min_len": NewLiteralValue("3")},
+		},
+		{
+			"repeated required and len",
+			&Field{Name: "a", Type: NewBasic("string"), Repeated: true},
+			[]string{"required", "len=2"},
+			Options{
+				"(validate.rules).repeated.min_items": NewLiteralValue("2"),
+				"(validate.rules).repeated.max_items": NewLiteralValue("2"),
+			},
+		},
+		{
+			"string const",
+			&Field{Name: "a", Type: NewBasic("string")},
+			[]string{"eq=foo"},
+			Options{"(validate.rules).string.const": NewStringValue("foo")},
+		},
+		{
+			"repeated",
+			&Field{Name: "a", Type: NewBasic("string"), Repeated: true},
+			[]string{"len=3", "dive", "email"},
+			Options{
+				"(validate.rules).repeated.min_items": NewLiteralValue("3"),
+				"(validate.rules).repeated.max_items": NewLiteralValue("3"),
+			},
+		},
+		{
+			"map",
+			&Field{Name: "a", Type: NewMap(NewBasic("string"), NewBasic("int32"))},
+			[]string{"min=1"},
+			Options{"(validate.rules).map.min_pairs": NewLiteralValue("1")},
+		},
+		{
+			"numbers",
+			&Field{Name: "a", Type: NewBasic("int64")},
+			[]string{"min=-5", "lt=10", "max=foo", "email"},
+			Options{
+				"(validate.rules).int64.gte": NewLiteralValue("-5"),
+				"(validate.rules).int64.lt":  NewLiteralValue("10"),
+			},
+		},
+		{
+			"unsigned",
+			&Field{Name: "a", Type: NewBasic("uint32")},
+			[]string{"gte=-1", "lte=8"},
+			Options{"(validate.rules).uint32.lte": NewLiteralValue("8")},
+		},
+		{
+			"alias",
+			&Field{Name: "a", Type: NewAlias(NewNamed("foo", "Score"), NewBasic("double"))},
+			[]string{"gt=0.5"},
+			Options{"(validate.rules).double.gt": NewLiteralValue("0.5")},
+		},
+		{
+			"message",
+			&Field{Name: "a", Type: NewNamed("foo", "Bar")},
+			[]string{"required"},
+			Options{"(validate.rules).message.required": NewLiteralValue("true")},
+		},
+		{
+			"bool",
+			&Field{Name: "a", Type: NewBasic("bool")},
+			[]string{"required"},
+			Options{},
+		},
+	}
+
+	tr := NewTransformer()
+	for _, c := range cases {
+		require.Equal(t, c.expected, tr.validateOptions("Foo", c.field, c.rules), c.name)
+	}
+}
+
+func TestTransformFieldValidate(t *testing.T) {
+	require := require.New(t)
+
+	pkg := new(Package)
+	f := NewTransformer().transformField(pkg, &Message{Name: "Foo"}, &scanner.Field{
+		Name:     "Name",
+		Type:     scanner.NewBasic("string"),
+		Validate: []string{"required"},
+	}, 1)
+
+	require.Equal(NewLiteralValue("1"), f.Options["(validate.rules).string.min_len"])
+	require.Equal([]string{"validate/validate.proto"}, pkg.Imports)
+
+	pkg = new(Package)
+	NewTransformer().transformField(pkg, &Message{Name: "Foo"}, &scanner.Field{
+		Name:     "Name",
+		Type:     scanner.NewBasic("bool"),
+		Validate: []string{"required"},
+	}, 1)
+	require.Empty(pkg.Imports)
+}
@@ -0,0 +1,123 @@
+package protobuf
+
+// Visitor has a method for every element of a package, which Walk calls in
+// the order in which they are in the package. The methods of the elements
+// with children return whether their children are walked. Embed BaseVisitor
+// to only implement the methods of the elements you need.
+type Visitor interface {
+	VisitPackage(pkg *Package) bool
+	VisitMessage(msg *Message) bool
+	VisitField(msg *Message, field *Field) bool
+	VisitEnum(enum *Enum) bool
+	VisitEnumValue(enum *Enum, value *EnumValue)
+	VisitService(svc *Service) bool
+	VisitRPC(svc *Service, rpc *RPC) bool
+	VisitNamed(typ *Named)
+	VisitBasic(typ *Basic)
+	VisitMap(typ *Map) bool
+	VisitAlias(typ *Alias) bool
+}
+
+// BaseVisitor is a Visitor that walks all the elements and does nothing with
+// them.
+type BaseVisitor struct{}
+
+// VisitPackage implements the Visitor interface.
+func (BaseVisitor) VisitPackage(*Package) bool { return true }
+
+// VisitMessage implements the Visitor interface.
+func (BaseVisitor) VisitMessage(*Message) bool { return true }
+
+// VisitField implements the Visitor interface.
+func (BaseVisitor) VisitField(*Message, *Field) bool { return true }
+
+// VisitEnum implements the Visitor interface.
+func (BaseVisitor) VisitEnum(*Enum) bool { return true }
+
+// VisitEnumValue implements the Visitor interface.
+func (BaseVisitor) VisitEnumValue(*Enum, *EnumValue) {}
+
+// VisitService implements the Visitor interface.
+func (BaseVisitor) VisitService(*Service) bool { return true }
+
+// VisitRPC implements the Visitor interface.
+func (BaseVisitor) VisitRPC(*Service, *RPC) bool { return true }
+
+// VisitNamed implements the Visitor interface.
+func (BaseVisitor) VisitNamed(*Named) {}
+
+// VisitBasic implements the Visitor interface.
+func (BaseVisitor) VisitBasic(*Basic) {}
+
+// VisitMap implements the Visitor interface.
+func (BaseVisitor) VisitMap(*Map) bool { return true }
+
+// VisitAlias implements the Visitor interface.
+func (BaseVisitor) VisitAlias(*Alias) bool { return true }
+
+// Walk walks the given package with the visitor: its messages and their
+// fields, its enums and their values and its services and their RPCs, along
+// with the types of the fields and the requests and responses of the RPCs.
+// The key and value of maps and the type and underlying type of aliases are
+// walked too. The elements must not be added or removed while they are
+// walked, but they can be modified.
+func Walk(pkg *Package, v Visitor) {
+	if !v.VisitPackage(pkg) {
+		return
+	}
+
+	for _, msg := range pkg.Messages {
+		if !v.VisitMessage(msg) {
+			continue
+		}
+
+		for _, f := range msg.Fields {
+			if v.VisitField(msg, f) {
+				WalkType(f.Type, v)
+			}
+		}
+	}
+
+	for _, e := range pkg.Enums {
+		if !v.VisitEnum(e) {
+			continue
+		}
+
+		for _, val := range e.Values {
+			v.VisitEnumValue(e, val)
+		}
+	}
+
+	for _, svc := range pkg.Services {
+		if !v.VisitService(svc) {
+			continue
+		}
+
+		for _, rpc := range svc.RPCs {
+			if v.VisitRPC(svc, rpc) {
+				WalkType(rpc.Input, v)
+				WalkType(rpc.Output, v)
+			}
+		}
+	}
+}
+
+// WalkType walks the given type with the visitor.
+func WalkType(typ Type, v Visitor) {
+	switch t := typ.(type) {
+	case *Named:
+		v.VisitNamed(t)
+	case *Basic:
+		v.VisitBasic(t)
+	case *Map:
+		if v.VisitMap(t) {
+			WalkType(t.Key, v)
+			WalkType(t.Value, v)
+		}
+	case *Alias:
+		if v.VisitAlias(t) {
+			WalkType(t.Type, v)
+			WalkType(t.Underlying, v)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingVisitor struct {
+	BaseVisitor
+	visits []string
+}
+
+func (v *recordingVisitor) VisitPackage(pkg *Package) bool {
+	v.visits = append(v.visits, "package "+pkg.Name)
+	return true
+}
+
+func (v *recordingVisitor) VisitMessage(msg *Message) bool {
+	v.visits = append(v.visits, "message "+msg.Name)
+	return msg.Name != "Skipped"
+}
+
+func (v *recordingVisitor) VisitField(msg *Message, f *Field) bool {
+	v.visits = append(v.visits, "field "+msg.Name+"."+f.Name)
+	return true
+}
+
+func (v *recordingVisitor) VisitEnumValue(e *Enum, val *EnumValue) {
+	v.visits = append(v.visits, "value "+e.Name+"."+val.Name)
+}
+
+func (v *recordingVisitor) VisitRPC(svc *Service, rpc *RPC) bool {
+	v.visits = append(v.visits, "rpc "+svc.Name+"."+rpc.Name)
+	return true
+}
+
+func (v *recordingVisitor) VisitNamed(typ *Named) {
+	v.visits = append(v.visits, "named "+typ.Name)
+}
+
+func (v *recordingVisitor) VisitBasic(typ *Basic) {
+	v.visits = append(v.visits, "basic "+typ.Name)
+}
+
+func (v *recordingVisitor) VisitMap(typ *Map) bool {
+	v.visits = append(v.visits, "map")
+	return true
+}
+
+func (v *recordingVisitor) VisitAlias(typ *Alias) bool {
+	v.visits = append(v.visits, "alias")
+	return false
+}
+
+func TestWalk(t *testing.T) {
+	pkg := &Package{
+		Name: "foo",
+		Messages: []*Message{
+			{
+				Name: "User",
+				Fields: []*Field{
+					{Name: "id", Type: NewBasic("string")},
+					{Name: "tags", Type: NewMap(NewBasic("string"), NewNamed("", "Tag"))},
+					{Name: "age", Type: NewAlias(NewNamed("", "Age"), NewBasic("int32"))},
+				},
+			},
+			{
+				Name:   "Skipped",
+				Fields: []*Field{{Name: "foo", Type: NewBasic("string")}},
+			},
+		},
+		Enums: []*Enum{
+			{Name: "Status", Values: []*EnumValue{{Name: "ACTIVE"}, {Name: "INACTIVE", Value: 1}}},
+		},
+		Services: []*Service{
+			{
+				Name: "FooService",
+				RPCs: []*RPC{
+					{Name: "GetUser", Input: NewGeneratedNamed("foo", "GetUserRequest"), Output: NewNamed("", "User")},
+				},
+			},
+		},
+	}
+
+	v := new(recordingVisitor)
+	Walk(pkg, v)
+	require.Equal(t, []string{
+		"package foo",
+		"message User",
+		"field User.id",
+		"basic string",
+		"field User.tags",
+		"map",
+		"basic string",
+		"named Tag",
+		"field User.age",
+		"alias",
+		"message Skipped",
+		"value Status.ACTIVE",
+		"value Status.INACTIVE",
+		"rpc FooService.GetUser",
+		"named GetUserRequest",
+		"named User",
+	}, v.visits)
+}
@@ -0,0 +1,116 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"os"
+)
+
+// Code identifies the kind of problem a warning or an error is about, so the
+// tools reading the diagnostics can tell them apart.
+type Code string
+
+const (
+	// CodeSkipped is the code of the types, fields, funcs and RPCs skipped
+	// because they can not be generated.
+	CodeSkipped Code = "skipped"
+	// CodeInvalidTag is the code of the invalid options of struct tags.
+	CodeInvalidTag Code = "invalid-tag"
+	// CodeInvalidComment is the code of the invalid //proteus: comments.
+	CodeInvalidComment Code = "invalid-comment"
+	// CodeInvalidRule is the code of the transformation rules that can not
+	// be applied.
+	CodeInvalidRule Code = "invalid-rule"
+	// CodeUnsupportedType is the code of the types that are not supported
+	// where they are used.
+	CodeUnsupportedType Code = "unsupported-type"
+	// CodeUnsupportedValidation is the code of the validation rules that
+	// are not supported.
+	CodeUnsupportedValidation Code = "unsupported-validation"
+	// CodeDuplicateID is the code of the proto ids already taken.
+	CodeDuplicateID Code = "duplicate-id"
+	// CodeMissingZeroValue is the code of the enums without a zero value.
+	CodeMissingZeroValue Code = "missing-zero-value"
+)
+
+// Format is the format the messages are written in.
+type Format string
+
+const (
+	// TextFormat writes the messages as colored lines of text. It is the
+	// default.
+	TextFormat Format = "text"
+	// JSONFormat writes every message as a Diagnostic encoded in JSON, in a
+	// line of its own.
+	JSONFormat Format = "json"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case TextFormat, JSONFormat:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid diagnostics format %q, expecting text or json", name)
+}
+
+var format = TextFormat
+
+// SetFormat sets the format the messages are written in.
+func SetFormat(f Format) {
+	format = f
+}
+
+// Diagnostic is a message as it is written in the JSON format.
+type Diagnostic struct {
+	// Severity is error, warning or info.
+	Severity string `json:"severity"`
+	// Code is the kind of problem, if it is known.
+	Code Code `json:"code,omitempty"`
+	// Position is the position of the Go declaration the message is about,
+	// if it is known.
+	Position *Position `json:"position,omitempty"`
+	// Symbol is the name of the Go declaration the message is about, like
+	// User or User.Name, if it is known.
+	Symbol  string `json:"symbol,omitempty"`
+	Message string `json:"message"`
+}
+
+// Position is a position in a Go source file.
+type Position struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func (p Position) String() string {
+	return token.Position{Filename: p.File, Line: p.Line, Column: p.Column}.String()
+}
+
+var severities = map[string]string{
+	"ERROR": "error",
+	"WARN":  "warning",
+	"INFO":  "info",
+}
+
+func newDiagnostic(code Code, pos token.Position, symbol string, format string, args ...interface{}) Diagnostic {
+	d := Diagnostic{
+		Code:    code,
+		Symbol:  symbol,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if pos.IsValid() {
+		d.Position = &Position{File: pos.Filename, Line: pos.Line, Column: pos.Column}
+	}
+	return d
+}
+
+func writeJSON(d Diagnostic) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to encode diagnostic: %s\n", err)
+		return
+	}
+	fmt.Println(string(data))
+}
@@ -2,6 +2,7 @@ package report // import "gitlab.com/ThatTomPerson/proteus/report"
 
 import (
 	"fmt"
+	"go/token"
 
 	"github.com/fatih/color"
 )
@@ -26,6 +27,7 @@ func EndTestMode() {
 
 func ResetTestModeStack() {
 	msgStack = make([]string, 0)
+	ResetSkipped()
 }
 
 func MessageStack() []string {
@@ -49,14 +51,45 @@ func Info(format string, args ...interface{}) {
 	report(color.GreenString, "INFO", format, args...)
 }
 
+// WarnAt prints a formatted warn message with the given code to stdout about
+// the Go declaration with the given symbol, such as User.Name, prefixed with
+// its position if it is valid.
+func WarnAt(code Code, pos token.Position, symbol string, format string, args ...interface{}) {
+	diagnose(color.YellowString, "WARN", newDiagnostic(code, pos, symbol, format, args...))
+}
+
+// ErrorAt prints a formatted error message with the given code to stdout
+// about the Go declaration with the given symbol, prefixed with its position
+// if it is valid.
+func ErrorAt(code Code, pos token.Position, symbol string, format string, args ...interface{}) {
+	diagnose(color.RedString, "ERROR", newDiagnostic(code, pos, symbol, format, args...))
+}
+
 func report(color colorFunc, lvl string, format string, args ...interface{}) {
-	fmt.Sprintf("%s: %s", color(lvl), fmt.Sprintf(format, args...))
+	diagnose(color, lvl, Diagnostic{Message: fmt.Sprintf(format, args...)})
+}
+
+func diagnose(color colorFunc, lvl string, d Diagnostic) {
+	msg := d.Message
+	if d.Position != nil {
+		msg = fmt.Sprintf("%s: %s", d.Position, msg)
+	}
 
 	if testing {
-		msgStack = append(msgStack, fmt.Sprintf("%s: %s", lvl, fmt.Sprintf(format, args...)))
+		msgStack = append(msgStack, fmt.Sprintf("%s: %s", lvl, msg))
+	}
+
+	if format == JSONFormat {
+		// Warnings and errors are always written for the tools reading
+		// them, and the rest only if not silent.
+		if !silent || lvl != "INFO" {
+			d.Severity = severities[lvl]
+			writeJSON(d)
+		}
+		return
 	}
 
 	if !silent || lvl == "ERROR" {
-		fmt.Println(fmt.Sprintf("%s: %s", color(lvl), fmt.Sprintf(format, args...)))
+		fmt.Println(fmt.Sprintf("%s: %s", color(lvl), msg))
 	}
 }
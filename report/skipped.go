@@ -0,0 +1,77 @@
+package report
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+var skipped Problems
+
+// Problem is a type, field, func or RPC of the Go source that could not be
+// generated and was skipped.
+type Problem struct {
+	// Position is the position of its Go declaration, which is not valid if
+	// it is not known.
+	Position token.Position
+	// Symbol is the name of its Go declaration, if it is known.
+	Symbol string
+	// Message tells what was skipped and why.
+	Message string
+}
+
+func (p Problem) String() string {
+	if !p.Position.IsValid() {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", p.Position, p.Message)
+}
+
+// Problems are all the problems found during a generation. As an error, it
+// lists every one of them instead of only the first.
+type Problems []Problem
+
+func (p Problems) Error() string {
+	var lines = make([]string, len(p))
+	for i, problem := range p {
+		lines[i] = "\t" + problem.String()
+	}
+
+	noun := "elements were"
+	if len(p) == 1 {
+		noun = "element was"
+	}
+	return fmt.Sprintf("%d %s skipped:\n%s", len(p), noun, strings.Join(lines, "\n"))
+}
+
+// Err returns the problems as an error, or nil if there are none.
+func (p Problems) Err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
+
+// Skip prints a formatted warn message to stdout about something that had to
+// be skipped, which is recorded as a problem.
+func Skip(format string, args ...interface{}) {
+	SkipAt(token.Position{}, "", format, args...)
+}
+
+// SkipAt prints a formatted warn message to stdout about the Go declaration
+// with the given symbol that had to be skipped, prefixed with its position if
+// it is valid, and records it as a problem.
+func SkipAt(pos token.Position, symbol string, format string, args ...interface{}) {
+	WarnAt(CodeSkipped, pos, symbol, format, args...)
+	skipped = append(skipped, Problem{Position: pos, Symbol: symbol, Message: fmt.Sprintf(format, args...)})
+}
+
+// Skipped returns the problems recorded since the last call to ResetSkipped.
+func Skipped() Problems {
+	return skipped
+}
+
+// ResetSkipped forgets the problems recorded so far.
+func ResetSkipped() {
+	skipped = nil
+}
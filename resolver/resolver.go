@@ -2,6 +2,7 @@ package resolver // import "gitlab.com/ThatTomPerson/proteus/resolver"
 
 import (
 	"fmt"
+	"strings"
 
 	"gitlab.com/ThatTomPerson/proteus/report"
 	"gitlab.com/ThatTomPerson/proteus/scanner"
@@ -18,18 +19,42 @@ type Resolver struct {
 	customTypes map[string]struct{}
 }
 
+// registeredTypes are the custom types registered with RegisterCustomType.
+var registeredTypes []string
+
+// RegisterCustomType makes the resolvers created afterwards consider correct
+// the Go type with the given name, like "github.com/google/uuid.UUID", even
+// though its package is not in any of the packages given.
+func RegisterCustomType(name string) {
+	registeredTypes = append(registeredTypes, name)
+}
+
 // New creates a new Resolver with the default custom types registered.
-// These are time.Time and time.Duration. Those types will be considered correct
-// even though their packages are not in any of the packages given.
+// These are time.Time, time.Duration, json.RawMessage, the protobuf Any
+// and Value types, the ones of anyconv holding them and the types of the
+// customtypes package, along with the ones registered with
+// RegisterCustomType.
+// Those types will be considered correct even though their packages are not
+// in any of the packages given.
 func New() *Resolver {
-	return &Resolver{
+	r := &Resolver{
 		customTypes: map[string]struct{}{
-			"time.Time":       {},
-			"time.Duration":   {},
-			"context.Context": {},
-			"error":           {},
+			"time.Time":                            {},
+			"time.Duration":                        {},
+			"context.Context":                      {},
+			"error":                                {},
+			"github.com/gogo/protobuf/types.Any":   {},
+			"github.com/gogo/protobuf/types.Value": {},
+			"gitlab.com/ThatTomPerson/proteus/anyconv.Any":   {},
+			"gitlab.com/ThatTomPerson/proteus/anyconv.Value": {},
+			"encoding/json.RawMessage":                       {},
 		},
 	}
+
+	for _, name := range registeredTypes {
+		r.customTypes[name] = struct{}{}
+	}
+	return r
 }
 
 // Resolve checks the types of all the packages passed in a global manner.
@@ -41,11 +66,59 @@ func (r *Resolver) Resolve(pkgs []*scanner.Package) {
 	for _, p := range pkgs {
 		r.resolvePackage(p, info)
 	}
+
+	markRequiredStructs(pkgs, info)
+	for _, p := range pkgs {
+		r.removeUnmarkedStructs(p, info)
+		p.Resolved = true
+	}
 }
 
+// customTypesPkg is the package with the types holding the values of
+// well-known Go types the generated code can not marshal, which are all
+// custom types.
+const customTypesPkg = "gitlab.com/ThatTomPerson/proteus/customtypes"
+
 func (r *Resolver) isCustomType(n *scanner.Named) bool {
 	_, ok := r.customTypes[n.String()]
-	return ok
+	return ok || n.Path == customTypesPkg
+}
+
+// unsupportedType is a well-known type of other packages that can not be
+// generated, as the generated messages use the Go types of the fields and
+// gogoproto has no way to marshal it.
+type unsupportedType struct {
+	path string
+	// prefix is the prefix of the names of the types.
+	prefix string
+	// advice tells what to use instead.
+	advice string
+}
+
+const customTypeAdvice = "wrap it in a type with the methods required by gogoproto.customtype and map it with protobuf.RegisterMapping instead"
+
+const uuidAdvice = "convert it to customtypes.UUID instead, which is generated with its text form"
+
+var unsupportedTypes = []unsupportedType{
+	{"cloud.google.com/go/civil", "", "convert it to the type of customtypes with the same name instead, which is generated as a message of google.type"},
+	{"github.com/google/uuid", "UUID", uuidAdvice},
+	{"github.com/gofrs/uuid", "UUID", uuidAdvice},
+	{"database/sql", "Null", "convert it to the type of customtypes with the same name instead, which is generated as a wrapper of google.protobuf, or use a pointer like *string, which is generated as an optional field"},
+	{"math/big", "Int", "convert a pointer to it to a *customtypes.BigInt instead, which is generated as a google.type.Decimal"},
+	{"math/big", "Float", "convert a pointer to it to a *customtypes.BigFloat instead, which is generated as a google.type.Decimal"},
+	{"math/big", "Rat", customTypeAdvice},
+	{"github.com/shopspring/decimal", "Decimal", "convert the result of its BigFloat method to a *customtypes.BigFloat, or " + customTypeAdvice},
+}
+
+// unsupportedTypeAdvice returns what to use instead of the type if it is one
+// of the unsupported well-known types.
+func unsupportedTypeAdvice(t *scanner.Named) (string, bool) {
+	for _, u := range unsupportedTypes {
+		if t.Path == u.path && strings.HasPrefix(t.Name, u.prefix) {
+			return u.advice, true
+		}
+	}
+	return "", false
 }
 
 func (r *Resolver) resolvePackage(p *scanner.Package, info *packagesInfo) {
@@ -58,13 +131,26 @@ func (r *Resolver) resolvePackage(p *scanner.Package, info *packagesInfo) {
 		if r.resolveFunc(f, info) {
 			funcs = append(funcs, f)
 		} else {
-			report.Warn("func %s had an unresolvable type and it will not be generated", f.Name)
+			report.SkipAt(f.Position, f.Name, "func %s had an unresolvable type and it will not be generated", f.Name)
 		}
 	}
 	p.Funcs = funcs
 
-	r.removeUnmarkedStructs(p, info)
-	p.Resolved = true
+	for _, i := range p.Interfaces {
+		r.resolveInterface(i, info)
+	}
+}
+
+func (r *Resolver) resolveInterface(i *scanner.Interface, info *packagesInfo) {
+	var methods = make([]*scanner.Func, 0, len(i.Methods))
+	for _, m := range i.Methods {
+		if r.resolveFunc(m, info) {
+			methods = append(methods, m)
+		} else {
+			report.SkipAt(m.Position, i.Name+"."+m.Name, "method %s of interface %s had an unresolvable type and it will not be generated", m.Name, i.Name)
+		}
+	}
+	i.Methods = methods
 }
 
 func (r *Resolver) resolveFunc(f *scanner.Func, info *packagesInfo) bool {
@@ -78,9 +164,26 @@ func (r *Resolver) resolveFunc(f *scanner.Func, info *packagesInfo) bool {
 		return false
 	}
 
+	for _, o := range f.FuncOptions {
+		o.Type = r.resolveType(o.Type, info)
+		if o.Type == nil {
+			return false
+		}
+	}
+
 	return true
 }
 
+// funcTypes returns the types of the parameters, results and options of the
+// func.
+func funcTypes(f *scanner.Func) []scanner.Type {
+	types := append(append([]scanner.Type{}, f.Input...), f.Output...)
+	for _, o := range f.FuncOptions {
+		types = append(types, o.Type)
+	}
+	return types
+}
+
 func (r *Resolver) resolveTypeList(types []scanner.Type, info *packagesInfo) []scanner.Type {
 	var result = make([]scanner.Type, 0, len(types))
 	for _, t := range types {
@@ -93,6 +196,89 @@ func (r *Resolver) resolveTypeList(types []scanner.Type, info *packagesInfo) []s
 	return result
 }
 
+// markRequiredStructs marks the structs used by the generated structs, funcs
+// and interfaces of the packages, directly or through other structs or lazy
+// types, so they are generated as well. Every struct is visited once, so the
+// structs that reference themselves or each other, like trees, are marked
+// only if they are used.
+func markRequiredStructs(pkgs []*scanner.Package, info *packagesInfo) {
+	var (
+		structs = make(map[string]*scanner.Struct)
+		lazies  = make(map[string]*scanner.Lazy)
+		pending []scanner.Type
+	)
+
+	for _, p := range pkgs {
+		for _, l := range p.Lazies {
+			lazies[fmt.Sprintf("%s.%s", p.Path, l.Name)] = l
+		}
+
+		for _, s := range p.Structs {
+			name := fmt.Sprintf("%s.%s", p.Path, s.Name)
+			structs[name] = s
+			if info.isStructMarked(name) {
+				pending = append(pending, fieldTypes(s)...)
+			}
+		}
+
+		for _, f := range p.Funcs {
+			pending = append(pending, funcTypes(f)...)
+		}
+
+		for _, i := range p.Interfaces {
+			for _, m := range i.Methods {
+				pending = append(pending, funcTypes(m)...)
+			}
+		}
+	}
+
+	for len(pending) > 0 {
+		typ := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for _, n := range namedTypes(typ) {
+			name := n.String()
+			if l, ok := lazies[name]; ok {
+				pending = append(pending, l.Message)
+				continue
+			}
+
+			s, ok := structs[name]
+			if !ok || info.isStructMarked(name) {
+				continue
+			}
+
+			info.markStruct(name)
+			pending = append(pending, fieldTypes(s)...)
+		}
+	}
+}
+
+func fieldTypes(s *scanner.Struct) []scanner.Type {
+	var types []scanner.Type
+	for _, f := range s.Fields {
+		if !f.Reserved {
+			types = append(types, f.Type)
+		}
+	}
+	return types
+}
+
+// namedTypes returns the named types the type is made of.
+func namedTypes(typ scanner.Type) []*scanner.Named {
+	switch t := typ.(type) {
+	case *scanner.Named:
+		return []*scanner.Named{t}
+	case *scanner.Alias:
+		return namedTypes(t.Underlying)
+	case *scanner.Map:
+		return append(namedTypes(t.Key), namedTypes(t.Value)...)
+	case *scanner.Set:
+		return namedTypes(t.Elem)
+	}
+	return nil
+}
+
 func (r *Resolver) removeUnmarkedStructs(p *scanner.Package, info *packagesInfo) {
 	var structs []*scanner.Struct
 	for _, s := range p.Structs {
@@ -108,9 +294,13 @@ func (r *Resolver) resolveStruct(s *scanner.Struct, info *packagesInfo) {
 	var result = make([]*scanner.Field, 0, len(s.Fields))
 
 	for _, f := range s.Fields {
-		if typ := r.resolveType(f.Type, info); typ != nil {
+		if f.Reserved {
+			result = append(result, f)
+		} else if typ := r.resolveType(f.Type, info); typ != nil {
 			f.Type = typ
 			result = append(result, f)
+		} else {
+			report.SkipAt(f.Position, s.Name+"."+f.Name, "field %q of struct %q had an unresolvable type and it will not be generated", f.Name, s.Name)
 		}
 	}
 
@@ -124,11 +314,25 @@ func (r *Resolver) resolveType(typ scanner.Type, info *packagesInfo) (result sca
 			return t
 		}
 
+		if advice, ok := unsupportedTypeAdvice(t); ok {
+			report.Warn("type %q of package %s will be ignored because the generated code can not marshal it, %s.", t.Name, t.Path, advice)
+			return nil
+		}
+
 		if !info.hasPackage(t.Path) {
+			if t.Message {
+				return t
+			}
+
 			report.Warn("type %q of package %s will be ignored because it was not present on the scan path.", t.Name, t.Path)
 			return nil
 		}
 
+		// The types of the scanned packages are generated again even if
+		// they implement proto.Message, which they do once the code of
+		// their previous .proto file is generated.
+		t.Message = false
+
 		alias := info.aliasOf(t)
 		if alias != nil {
 			if alias.IsRepeated() && t.IsRepeated() {
@@ -143,16 +347,21 @@ func (r *Resolver) resolveType(typ scanner.Type, info *packagesInfo) (result sca
 			return scanner.NewAlias(t, r.resolveType(alias, info))
 		}
 
-		if info.isStruct(t.String()) {
-			info.markStruct(t.String())
-		}
-
 		result = t
 	case *scanner.Basic:
 		result = t
 	case *scanner.Map:
 		t.Key = r.resolveType(t.Key, info)
 		t.Value = r.resolveType(t.Value, info)
+		if t.Key == nil || t.Value == nil {
+			return nil
+		}
+		result = t
+	case *scanner.Set:
+		t.Elem = r.resolveType(t.Elem, info)
+		if t.Elem == nil {
+			return nil
+		}
 		result = t
 	}
 
@@ -217,11 +426,6 @@ func (i *packagesInfo) aliasOf(named *scanner.Named) scanner.Type {
 	return alias
 }
 
-func (i *packagesInfo) isStruct(name string) bool {
-	_, ok := i.structs[name]
-	return ok
-}
-
 func (i *packagesInfo) markStruct(name string) {
 	i.structs[name] = true
 }
@@ -1,6 +1,7 @@
 package resolver
 
 import (
+	"go/token"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -97,13 +98,27 @@ func (s *ResolverSuite) TestIsCustomType() {
 		{"net/url", "URL", false},
 		{"time", "Time", true},
 		{"time", "Duration", true},
+		{customTypesPkg, "Date", true},
+		{"gitlab.com/ThatTomPerson/proteus/anyconv", "Any", true},
+		{"gitlab.com/ThatTomPerson/proteus/anyconv", "Value", true},
 	}
 
 	for _, c := range cases {
-		s.Equal(c.result, s.r.isCustomType(&scanner.Named{nil, c.path, c.name}), "%s.%s", c.path, c.name)
+		s.Equal(c.result, s.r.isCustomType(&scanner.Named{Path: c.path, Name: c.name}), "%s.%s", c.path, c.name)
 	}
 }
 
+func (s *ResolverSuite) TestRegisterCustomType() {
+	defer func(prev []string) { registeredTypes = prev }(registeredTypes)
+
+	uuid := &scanner.Named{Path: "github.com/google/uuid", Name: "UUID"}
+	s.False(New().isCustomType(uuid))
+
+	RegisterCustomType("github.com/google/uuid.UUID")
+	s.True(New().isCustomType(uuid))
+	s.False(s.r.isCustomType(uuid), "resolvers created before are not affected")
+}
+
 func (s *ResolverSuite) TestNotInScanPathWarning() {
 	report.TestMode()
 
@@ -121,6 +136,64 @@ func (s *ResolverSuite) TestNotInScanPathWarning() {
 	report.EndTestMode()
 }
 
+func (s *ResolverSuite) TestUnresolvableFieldWarning() {
+	report.TestMode()
+
+	st := &scanner.Struct{
+		Name: "User",
+		Fields: []*scanner.Field{
+			{
+				Name:     "Avatar",
+				Type:     scanner.NewNamed("image", "Image"),
+				Position: token.Position{Filename: "user.go", Line: 12, Column: 2},
+			},
+		},
+	}
+	s.r.resolveStruct(st, &packagesInfo{})
+	s.Len(st.Fields, 0)
+	s.Equal([]string{
+		`WARN: type "Image" of package image will be ignored because it was not present on the scan path.`,
+		`WARN: user.go:12:2: field "Avatar" of struct "User" had an unresolvable type and it will not be generated`,
+	}, report.MessageStack())
+
+	problems := report.Skipped()
+	s.Len(problems, 1, "only the field is skipped")
+	s.EqualError(problems.Err(), "1 element was skipped:\n\tuser.go:12:2: field \"Avatar\" of struct \"User\" had an unresolvable type and it will not be generated")
+
+	report.EndTestMode()
+}
+
+func (s *ResolverSuite) TestResolveProtoMessage() {
+	info := &packagesInfo{packages: map[string]struct{}{"foo": {}}}
+
+	external := scanner.NewNamed("github.com/bar/pb", "User")
+	external.(*scanner.Named).Message = true
+	s.Equal(external, s.r.resolveType(external, info), "messages of packages not scanned are kept")
+	s.True(external.(*scanner.Named).Message)
+
+	scanned := scanner.NewNamed("foo", "User")
+	scanned.(*scanner.Named).Message = true
+	s.Equal(scanned, s.r.resolveType(scanned, info))
+	s.False(scanned.(*scanner.Named).Message, "messages of scanned packages are generated again")
+}
+
+func (s *ResolverSuite) TestUnsupportedTypeWarning() {
+	report.TestMode()
+
+	info := &packagesInfo{packages: map[string]struct{}{"database/sql": {}}}
+	s.Nil(s.r.resolveType(scanner.NewNamed("database/sql", "NullString"), info))
+	s.Nil(s.r.resolveType(scanner.NewNamed("math/big", "Int"), info))
+	s.Nil(s.r.resolveType(scanner.NewNamed("cloud.google.com/go/civil", "Date"), info))
+	s.Nil(s.r.resolveType(scanner.NewNamed("github.com/google/uuid", "UUID"), info))
+	s.Len(report.MessageStack(), 4, "it contains four messages")
+	s.Contains(report.MessageStack()[0], "customtypes with the same name")
+	s.Contains(report.MessageStack()[1], "customtypes.BigInt")
+	s.Contains(report.MessageStack()[2], "type of customtypes")
+	s.Contains(report.MessageStack()[3], "customtypes.UUID")
+
+	report.EndTestMode()
+}
+
 func (s *ResolverSuite) TestAliasToRepeatedFieldWarning() {
 	report.TestMode()
 
@@ -156,6 +229,34 @@ func (s *ResolverSuite) TestAliasToRepeatedFieldWithoutWarning() {
 	report.EndTestMode()
 }
 
+func (s *ResolverSuite) TestSetNotInScanPath() {
+	report.TestMode()
+
+	info := &packagesInfo{
+		packages: map[string]struct{}{"": struct{}{}},
+	}
+	s.Nil(s.r.resolveType(scanner.NewSet(scanner.NewNamed("foo", "Bar")), info))
+	s.NotNil(s.r.resolveType(scanner.NewSet(scanner.NewNamed("", "Bar")), info))
+
+	report.EndTestMode()
+}
+
+func (s *ResolverSuite) TestResolveStructReservedField() {
+	st := &scanner.Struct{
+		Name: "Foo",
+		Fields: []*scanner.Field{
+			{Name: "Bar", Type: scanner.NewNamed("foo", "Bar")},
+			{Name: "Ch", Reserved: true},
+		},
+	}
+
+	report.TestMode()
+	s.r.resolveStruct(st, &packagesInfo{})
+	report.EndTestMode()
+
+	s.Equal([]*scanner.Field{{Name: "Ch", Reserved: true}}, st.Fields)
+}
+
 func (s *ResolverSuite) TestResolve() {
 	sc, err := scanner.New(projectPath("fixtures"), projectPath("fixtures/subpkg"))
 	s.Nil(err)
@@ -191,6 +292,10 @@ func (s *ResolverSuite) TestResolve() {
 
 	s.Equal(1, len(pkgs[1].Structs), "a struct of subpkg should have been removed")
 	s.Equal(4, len(pkgs[1].Funcs), "num of funcs in subpkg")
+	for _, fn := range pkgs[1].Funcs {
+		s.True(fn.Position.IsValid(), fn.Name)
+		fn.Position = token.Position{}
+	}
 
 	s.Equal(&scanner.Func{
 		Docs: mkDocs("Generated ..."),
@@ -239,6 +344,87 @@ func (s *ResolverSuite) TestResolve() {
 	}, findFuncByName("Name", pkgs[1].Funcs))
 }
 
+func (s *ResolverSuite) TestResolveRecursiveStructs() {
+	node := func(name string, fields ...*scanner.Field) *scanner.Struct {
+		return &scanner.Struct{Name: name, Fields: fields}
+	}
+
+	pkgs := []*scanner.Package{
+		{
+			Path: "a",
+			Structs: []*scanner.Struct{
+				node("Leaf", &scanner.Field{Name: "Value", Type: scanner.NewBasic("string")}),
+				node("Unused",
+					&scanner.Field{Name: "Next", Type: nullable(scanner.NewNamed("a", "Unused"))},
+				),
+			},
+		},
+		{
+			Path: "b",
+			Structs: []*scanner.Struct{
+				{
+					Name:     "Tree",
+					Generate: true,
+					Fields: []*scanner.Field{
+						{Name: "Root", Type: nullable(scanner.NewNamed("b", "Node"))},
+					},
+				},
+				node("Node",
+					&scanner.Field{Name: "Children", Type: repeated(nullable(scanner.NewNamed("b", "Node")))},
+					&scanner.Field{Name: "Edge", Type: nullable(scanner.NewNamed("b", "Edge"))},
+				),
+				node("Edge",
+					&scanner.Field{Name: "To", Type: nullable(scanner.NewNamed("b", "Node"))},
+					&scanner.Field{Name: "Leaf", Type: scanner.NewNamed("a", "Leaf")},
+				),
+			},
+		},
+	}
+
+	s.r.Resolve(pkgs)
+
+	var names []string
+	for _, p := range pkgs {
+		for _, st := range p.Structs {
+			names = append(names, p.Path+"."+st.Name)
+		}
+	}
+
+	s.Equal([]string{"a.Leaf", "b.Tree", "b.Node", "b.Edge"}, names, "structs required through cycles and by later packages are kept")
+}
+
+func (s *ResolverSuite) TestResolveLazyMessages() {
+	pkgs := []*scanner.Package{
+		{
+			Path: "a",
+			Structs: []*scanner.Struct{
+				{
+					Name:     "Summary",
+					Generate: true,
+					Fields: []*scanner.Field{
+						{Name: "Report", Type: nullable(scanner.NewNamed("a", "LazyReport"))},
+					},
+				},
+				{Name: "Report", Fields: []*scanner.Field{{Name: "Text", Type: scanner.NewBasic("string")}}},
+				{Name: "Unused"},
+			},
+			Lazies: []*scanner.Lazy{
+				{Name: "LazyReport", Message: scanner.NewNamed("a", "Report").(*scanner.Named)},
+			},
+		},
+	}
+
+	s.r.Resolve(pkgs)
+
+	var names []string
+	for _, st := range pkgs[0].Structs {
+		names = append(names, st.Name)
+	}
+
+	s.Equal([]string{"Summary", "Report"}, names, "the messages of the lazy types used are kept")
+	s.Equal(nullable(scanner.NewNamed("a", "LazyReport")), pkgs[0].Structs[0].Fields[0].Type)
+}
+
 func (s *ResolverSuite) assertStruct(st *scanner.Struct, name string, fields ...string) {
 	s.Equal(name, st.Name, "struct name")
 	s.Equal(len(fields), len(st.Fields), "should have same struct fields")
@@ -281,6 +467,11 @@ func findFuncByName(name string, fns []*scanner.Func) *scanner.Func {
 	return nil
 }
 
+func repeated(t scanner.Type) scanner.Type {
+	t.SetRepeated(true)
+	return t
+}
+
 func nullable(t scanner.Type) scanner.Type {
 	t.SetNullable(true)
 	return t
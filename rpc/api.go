@@ -0,0 +1,274 @@
+package rpc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// API is the Go API of the code generated by protoc for the messages and
+// services of a package, which the generated server is registered with.
+type API string
+
+const (
+	// GoGo is the API of the code generated by gogo/protobuf, whose messages
+	// are the Go types of the package. It is the default.
+	GoGo API = "gogo"
+	// APIv2 is the API of the code generated by protoc-gen-go and
+	// protoc-gen-go-grpc for google.golang.org/protobuf, whose messages are
+	// types of their own in another package.
+	APIv2 API = "apiv2"
+)
+
+// ParseAPI returns the protobuf API with the given name.
+func ParseAPI(name string) (API, error) {
+	switch api := API(name); api {
+	case GoGo, APIv2:
+		return api, nil
+	}
+	return "", fmt.Errorf("invalid protobuf API %q, expecting gogo or apiv2", name)
+}
+
+// APIv2Folder is the folder of a package with its APIv2 code, unless another
+// import path is given.
+const APIv2Folder = "pb"
+
+// PackageAPI is the protobuf API a package is served with.
+type PackageAPI struct {
+	API API
+	// Path is the import path of the APIv2 code of the package, if its API
+	// is APIv2.
+	Path string
+}
+
+// APIOf returns the protobuf API of the given package, given with a comment
+// like `//proteus:protobuf-api apiv2` in its docs, or the given API if it
+// has none. The APIv2 code is expected in the pb folder of the package,
+// unless its import path follows the API, as in
+// `//proteus:protobuf-api apiv2 acme/users/v2pb`. Invalid APIs are ignored
+// with a warning.
+func APIOf(p *scanner.Package, api API) PackageAPI {
+	target := path.Join(p.Path, APIv2Folder)
+	if args := strings.Fields(p.ProtobufAPI); len(args) > 0 {
+		parsed, err := ParseAPI(args[0])
+		if err != nil || len(args) > 2 || (parsed == GoGo && len(args) > 1) {
+			report.Warn("package %s has an invalid protobuf-api comment, ignoring it: %q", p.Path, p.ProtobufAPI)
+		} else {
+			api = parsed
+			if len(args) == 2 {
+				target = args[1]
+			}
+		}
+	}
+
+	if api != APIv2 {
+		return PackageAPI{API: GoGo}
+	}
+	return PackageAPI{API: APIv2, Path: target}
+}
+
+const (
+	// apiv2Import is the name the APIv2 code of the package is imported
+	// with.
+	apiv2Import = "pbv2"
+	// protov2Import is the name the APIv2 proto package is imported with,
+	// as the one of gogo/protobuf is also imported.
+	protov2Import = "protov2"
+	protov2Pkg    = "google.golang.org/protobuf/proto"
+	gogoProtoPkg  = "github.com/gogo/protobuf/proto"
+
+	fromAPIv2Name = "fromAPIv2"
+	toAPIv2Name   = "toAPIv2"
+)
+
+// apiv2ImplName returns the name of the type serving the given service with
+// the APIv2 messages.
+func apiv2ImplName(service string) string {
+	return serviceImplName(service) + "APIv2"
+}
+
+// declAPIv2Service returns the declarations of the type serving the given
+// service with the APIv2 messages, which embeds the unimplemented server of
+// protoc-gen-go-grpc, and its methods. They convert the requests to the gogo
+// messages through their wire encoding, call the methods of the gogo server
+// and convert their responses back. RPCs with messages of other packages are
+// left unimplemented with a warning, as their APIv2 code is not known, and so
+// are the server-streaming ones.
+func (g *Generator) declAPIv2Service(ctx *context, svc *protobuf.Service) []ast.Decl {
+	name := apiv2ImplName(svc.Name)
+	if ctx.isNameDefined(name) {
+		return nil
+	}
+
+	ctx.addNamedImport(apiv2Import, g.api.Path)
+	decls := []ast.Decl{&ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{
+				Name: ast.NewIdent(name),
+				Type: &ast.StructType{
+					Fields: fields(
+						&ast.Field{Type: ast.NewIdent(fmt.Sprintf("%s.Unimplemented%sServer", apiv2Import, svc.Name))},
+						field("server", ptr(ast.NewIdent(serviceImplName(svc.Name)))),
+					),
+				},
+			},
+		},
+	}}
+
+	for _, rpc := range svc.RPCs {
+		if isForeign(rpc.Input) || isForeign(rpc.Output) {
+			report.Warn("RPC %s of service %s has messages of another package, it is left unimplemented in the APIv2 server", rpc.Name, svc.Name)
+			continue
+		}
+
+		if rpc.IsStreaming() {
+			report.Warn("RPC %s of service %s is server-streaming, it is left unimplemented in the APIv2 server", rpc.Name, svc.Name)
+			continue
+		}
+		decls = append(decls, g.declAPIv2Method(ctx, name, rpc))
+	}
+	return decls
+}
+
+// declAPIv2Method declares the method serving the given RPC with the APIv2
+// messages in the type with the given name.
+func (g *Generator) declAPIv2Method(ctx *context, implName string, rpc *protobuf.RPC) ast.Decl {
+	in := g.genMethodType(ctx, rpc).Params.List[1].Type.(*ast.StarExpr).X
+
+	return &ast.FuncDecl{
+		Recv: fields(field("s", ptr(ast.NewIdent(implName)))),
+		Name: ast.NewIdent(rpc.Name),
+		Type: &ast.FuncType{
+			Params: fields(
+				field("ctx", ast.NewIdent("xcontext.Context")),
+				field("in", ptr(ast.NewIdent(apiv2Import+"."+typeName(rpc.Input)))),
+			),
+			Results: fields(
+				&ast.Field{Type: ptr(ast.NewIdent(apiv2Import + "." + typeName(rpc.Output)))},
+				&ast.Field{Type: ast.NewIdent("error")},
+			),
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Tok: token.DEFINE,
+					Lhs: []ast.Expr{ast.NewIdent("req")},
+					Rhs: []ast.Expr{&ast.CallExpr{Fun: ast.NewIdent("new"), Args: []ast.Expr{in}}},
+				},
+				returnIfErr(&ast.CallExpr{
+					Fun:  ast.NewIdent(fromAPIv2Name),
+					Args: []ast.Expr{ast.NewIdent("in"), ast.NewIdent("req")},
+				}),
+				&ast.AssignStmt{
+					Tok: token.DEFINE,
+					Lhs: []ast.Expr{ast.NewIdent("res"), ast.NewIdent("err")},
+					Rhs: []ast.Expr{&ast.CallExpr{
+						Fun:  ast.NewIdent("s.server." + rpc.Name),
+						Args: []ast.Expr{ast.NewIdent("ctx"), ast.NewIdent("req")},
+					}},
+				},
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+					Body: &ast.BlockStmt{List: []ast.Stmt{returnNilErr()}},
+				},
+				&ast.AssignStmt{
+					Tok: token.DEFINE,
+					Lhs: []ast.Expr{ast.NewIdent("out")},
+					Rhs: []ast.Expr{&ast.CallExpr{
+						Fun:  ast.NewIdent("new"),
+						Args: []ast.Expr{ast.NewIdent(apiv2Import + "." + typeName(rpc.Output))},
+					}},
+				},
+				returnIfErr(&ast.CallExpr{
+					Fun:  ast.NewIdent(toAPIv2Name),
+					Args: []ast.Expr{ast.NewIdent("res"), ast.NewIdent("out")},
+				}),
+				&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("out"), ast.NewIdent("nil")}},
+			},
+		},
+	}
+}
+
+// declAPIv2Conversions declares the funcs converting the gogo messages from
+// and to the APIv2 ones through their wire encoding, which is the same as
+// both are generated from the same .proto file.
+func (g *Generator) declAPIv2Conversions(ctx *context) []ast.Decl {
+	var decls []ast.Decl
+	if !ctx.isNameDefined(fromAPIv2Name) {
+		decls = append(decls, declConversion(fromAPIv2Name, protov2Import, "proto"))
+	}
+
+	if !ctx.isNameDefined(toAPIv2Name) {
+		decls = append(decls, declConversion(toAPIv2Name, "proto", protov2Import))
+	}
+
+	if len(decls) > 0 {
+		ctx.addImport(gogoProtoPkg)
+		ctx.addNamedImport(protov2Import, protov2Pkg)
+	}
+	return decls
+}
+
+// declConversion declares a func with the given name that marshals a message
+// with the proto package imported with the name from and unmarshals it with
+// the one imported with the name to.
+func declConversion(name, from, to string) ast.Decl {
+	return &ast.FuncDecl{
+		Name: ast.NewIdent(name),
+		Type: &ast.FuncType{
+			Params: fields(
+				field("in", ast.NewIdent(from+".Message")),
+				field("out", ast.NewIdent(to+".Message")),
+			),
+			Results: fields(&ast.Field{Type: ast.NewIdent("error")}),
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Tok: token.DEFINE,
+					Lhs: []ast.Expr{ast.NewIdent("data"), ast.NewIdent("err")},
+					Rhs: []ast.Expr{ast.NewIdent(from + ".Marshal(in)")},
+				},
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+					Body: &ast.BlockStmt{List: []ast.Stmt{
+						&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("err")}},
+					}},
+				},
+				&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent(to + ".Unmarshal(data, out)")}},
+			},
+		},
+	}
+}
+
+// returnIfErr returns the statement that returns nil and the error of the
+// given call, if any.
+func returnIfErr(call ast.Expr) ast.Stmt {
+	return &ast.IfStmt{
+		Init: &ast.AssignStmt{
+			Tok: token.DEFINE,
+			Lhs: []ast.Expr{ast.NewIdent("err")},
+			Rhs: []ast.Expr{call},
+		},
+		Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+		Body: &ast.BlockStmt{List: []ast.Stmt{returnNilErr()}},
+	}
+}
+
+func returnNilErr() ast.Stmt {
+	return &ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("nil"), ast.NewIdent("err")}}
+}
+
+// isForeign reports whether the type is a message of another protobuf
+// package.
+func isForeign(t protobuf.Type) bool {
+	n, ok := t.(*protobuf.Named)
+	return !ok || n.Package != ""
+}
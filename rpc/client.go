@@ -0,0 +1,345 @@
+package rpc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+)
+
+const (
+	clientConfigName        = "GRPCClientConfig"
+	defaultClientConfigName = "DefaultGRPCClientConfig"
+	newClientConnName       = "NewGRPCClientConn"
+	newClientPoolName       = "NewGRPCClientPool"
+)
+
+const (
+	hedgingPkg  = "gitlab.com/ThatTomPerson/proteus/hedging"
+	connPoolPkg = "gitlab.com/ThatTomPerson/proteus/connpool"
+)
+
+// defaultClientConfig is the default config of the clients. gRPC itself
+// sends all the calls to the first address the target resolves to and never
+// pings idle connections, so the calls are not balanced when the target is
+// scaled and broken connections are only noticed by the next call. The
+// clients ping every minute, which the default servers allow.
+const defaultClientConfig = `GRPCClientConfig{
+		Balancer: "round_robin",
+		Keepalive: keepalive.ClientParameters{
+			Time:                time.Minute,
+			Timeout:             20 * time.Second,
+			PermitWithoutStream: true,
+		},
+		MaxRecvMsgSize: 4 << 20,
+		MaxSendMsgSize: 4 << 20,
+	}`
+
+// clientOptions are the options of the client connection given by its
+// config.
+const clientOptions = `[]grpc.DialOption{
+		grpc.WithResolvers(config.Resolvers...),
+		grpc.WithKeepaliveParams(config.Keepalive),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(config.MaxRecvMsgSize),
+			grpc.MaxCallSendMsgSize(config.MaxSendMsgSize),
+		),%s
+	}`
+
+// balancerServiceConfig is the service config selecting the load balancing
+// policy of the config, which is only given if it has one, so the one of
+// the service config of the resolver is used otherwise.
+const balancerServiceConfig = `if config.Balancer != "" {
+		defaults = append(defaults, grpc.WithDefaultServiceConfig(fmt.Sprintf(` + "`" + `{"loadBalancingConfig": [{%q: {}}]}` + "`" + `, config.Balancer)))
+	}`
+
+// tracingClientOptions are the options added to the client connection to
+// send the IDs of the calls.
+const tracingClientOptions = `
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(tracing.StreamClientInterceptor()),`
+
+// errStatusClientOptions are the options added to the client connection to
+// return the errors of the calls as the errors of the errstatus package,
+// with the details the servers send.
+const errStatusClientOptions = `
+		grpc.WithChainUnaryInterceptor(errstatus.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(errstatus.StreamClientInterceptor()),`
+
+// hedgingClientOptions are the options added to the client connection to
+// hedge the calls to the idempotent RPCs with the policy of the config. It
+// is the last interceptor, so every attempt goes through the rest.
+const hedgingClientOptions = `
+		grpc.WithChainUnaryInterceptor(hedging.UnaryClientInterceptor(
+			config.Hedging,%s
+		)),`
+
+// declClient returns the declarations of the scaffold of the gRPC clients of
+// the package, that is, the config type, the func returning its defaults,
+// the constructor of a client connection following it and the one of a pool
+// of them, and, unless the messages are the ones of APIv2, a client of every
+// given service sending its calls to the connections of a pool in turn. If
+// the given services have idempotent RPCs, the config also has the policy
+// hedging their calls. The ones already defined in the package are not
+// generated.
+func (g *Generator) declClient(ctx *context, services []*protobuf.Service) []ast.Decl {
+	hedged := idempotentMethods(ctx, services)
+
+	var decls []ast.Decl
+	if !ctx.isNameDefined(clientConfigName) {
+		ctx.addImport("google.golang.org/grpc/keepalive")
+		ctx.addImport("google.golang.org/grpc/resolver")
+		if len(hedged) > 0 {
+			ctx.addImport(hedgingPkg)
+		}
+		decls = append(decls, g.declClientConfig(len(hedged) > 0))
+	}
+
+	if !ctx.isNameDefined(defaultClientConfigName) {
+		ctx.addImport("time")
+		ctx.addImport("google.golang.org/grpc/keepalive")
+		decls = append(decls, g.declDefaultClientConfig())
+	}
+
+	if !ctx.isNameDefined(newClientConnName) {
+		ctx.addImport("fmt")
+		ctx.addImport("google.golang.org/grpc")
+		if g.tracing {
+			ctx.addImport(tracingPkg)
+		}
+		if g.errStatus {
+			ctx.addImport(errStatusPkg)
+		}
+		if len(hedged) > 0 {
+			ctx.addImport(hedgingPkg)
+		}
+		decls = append(decls, g.declNewClientConn(hedged))
+	}
+
+	if !ctx.isNameDefined(newClientPoolName) {
+		ctx.addImport("google.golang.org/grpc")
+		ctx.addImport(connPoolPkg)
+		decls = append(decls, g.declNewClientPool())
+	}
+
+	if g.api.API != APIv2 {
+		for _, svc := range services {
+			decls = append(decls, g.declServiceClientPool(ctx, svc)...)
+		}
+	}
+	return decls
+}
+
+// idempotentMethods returns the full names of the idempotent unary RPCs of
+// the given services, as only those are hedged by the interceptor.
+func idempotentMethods(ctx *context, services []*protobuf.Service) []string {
+	var methods []string
+	for _, svc := range services {
+		for _, rpc := range svc.RPCs {
+			if rpc.Idempotent && !rpc.IsStreaming() {
+				methods = append(methods, fullMethodName(ctx.proto, svc, rpc))
+			}
+		}
+	}
+	return methods
+}
+
+func (g *Generator) declClientConfig(hedging bool) ast.Decl {
+	config := fields(
+		field("Balancer", ast.NewIdent("string")),
+		field("Resolvers", ast.NewIdent("[]resolver.Builder")),
+		field("Keepalive", ast.NewIdent("keepalive.ClientParameters")),
+		field("MaxRecvMsgSize", ast.NewIdent("int")),
+		field("MaxSendMsgSize", ast.NewIdent("int")),
+	)
+	if hedging {
+		config.List = append(config.List, field("Hedging", ast.NewIdent("hedging.Policy")))
+	}
+
+	return &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{
+				Name: ast.NewIdent(clientConfigName),
+				Type: &ast.StructType{Fields: config},
+			},
+		},
+	}
+}
+
+func (g *Generator) declDefaultClientConfig() ast.Decl {
+	return &ast.FuncDecl{
+		Name: ast.NewIdent(defaultClientConfigName),
+		Type: &ast.FuncType{
+			Params:  fields(),
+			Results: fields(&ast.Field{Type: ast.NewIdent(clientConfigName)}),
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.ReturnStmt{
+					Results: []ast.Expr{ast.NewIdent(defaultClientConfig)},
+				},
+			},
+		},
+	}
+}
+
+// declNewClientConn declares the constructor of the client connections,
+// which applies the given config and then the given dial options, so they
+// can override it. The target is resolved with the resolvers of the config
+// or the ones registered, such as dns or xds, by its scheme. With tracing,
+// the client interceptors of the tracing package are also added, and with
+// error statuses, the ones of the errstatus package. The calls to the given
+// methods are hedged with the hedging policy of the config.
+func (g *Generator) declNewClientConn(hedged []string) ast.Decl {
+	var interceptors string
+	if g.tracing {
+		interceptors += tracingClientOptions
+	}
+	if g.errStatus {
+		interceptors += errStatusClientOptions
+	}
+	if len(hedged) > 0 {
+		var methods strings.Builder
+		for _, m := range hedged {
+			fmt.Fprintf(&methods, "\n\t\t\t%q,", m)
+		}
+		interceptors += fmt.Sprintf(hedgingClientOptions, methods.String())
+	}
+
+	stmts := []ast.Stmt{
+		&ast.AssignStmt{
+			Tok: token.DEFINE,
+			Lhs: []ast.Expr{ast.NewIdent("defaults")},
+			Rhs: []ast.Expr{ast.NewIdent(fmt.Sprintf(clientOptions, interceptors))},
+		},
+		&ast.ExprStmt{X: ast.NewIdent(balancerServiceConfig)},
+		&ast.ReturnStmt{
+			Results: []ast.Expr{ast.NewIdent("grpc.NewClient(target, append(defaults, opts...)...)")},
+		},
+	}
+
+	return &ast.FuncDecl{
+		Name: ast.NewIdent(newClientConnName),
+		Type: &ast.FuncType{
+			Params: fields(
+				field("target", ast.NewIdent("string")),
+				field("config", ast.NewIdent(clientConfigName)),
+				field("opts", ast.NewIdent("...grpc.DialOption")),
+			),
+			Results: fields(
+				&ast.Field{Type: ptr(ast.NewIdent("grpc.ClientConn"))},
+				&ast.Field{Type: ast.NewIdent("error")},
+			),
+		},
+		Body: &ast.BlockStmt{List: stmts},
+	}
+}
+
+func (g *Generator) declNewClientPool() ast.Decl {
+	return &ast.FuncDecl{
+		Name: ast.NewIdent(newClientPoolName),
+		Type: &ast.FuncType{
+			Params: fields(
+				field("target", ast.NewIdent("string")),
+				field("size", ast.NewIdent("int")),
+				field("config", ast.NewIdent(clientConfigName)),
+				field("opts", ast.NewIdent("...grpc.DialOption")),
+			),
+			Results: fields(
+				&ast.Field{Type: ptr(ast.NewIdent("connpool.Pool"))},
+				&ast.Field{Type: ast.NewIdent("error")},
+			),
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.ReturnStmt{
+					Results: []ast.Expr{ast.NewIdent(`connpool.Dial(size, func() (*grpc.ClientConn, error) {
+		return ` + newClientConnName + `(target, config, opts...)
+	})`)},
+				},
+			},
+		},
+	}
+}
+
+// clientPoolName returns the name of the type of the client of the given
+// service that sends its calls to the connections of a pool.
+func clientPoolName(service string) string {
+	return strings.ToLower(string(service[0])) + service[1:] + "ClientPool"
+}
+
+// clientPoolConstructorName returns the name of the constructor of the client
+// of the given service that sends its calls to the connections of a pool.
+func clientPoolConstructorName(service string) string {
+	return fmt.Sprintf("New%sClientPool", service)
+}
+
+// declServiceClientPool returns the declarations of the client of the given
+// service sending its calls to the connections of a pool in turn, which
+// implements the client interface generated by gogo with the client of the
+// connection of every call. The streams of its server-streaming RPCs are
+// the ones of the connection they were opened with.
+func (g *Generator) declServiceClientPool(ctx *context, svc *protobuf.Service) []ast.Decl {
+	name := clientPoolName(svc.Name)
+	constructor := clientPoolConstructorName(svc.Name)
+	iface := svc.Name + "Client"
+
+	var decls []ast.Decl
+	if !ctx.isNameDefined(name) {
+		decls = append(decls, &ast.GenDecl{
+			Tok: token.TYPE,
+			Specs: []ast.Spec{
+				&ast.TypeSpec{
+					Name: ast.NewIdent(name),
+					Type: &ast.StructType{
+						Fields: fields(field("pool", ptr(ast.NewIdent("connpool.Pool")))),
+					},
+				},
+			},
+		})
+	}
+
+	if !ctx.isNameDefined(constructor) {
+		ctx.addImport(connPoolPkg)
+		decls = append(decls, &ast.FuncDecl{
+			Name: ast.NewIdent(constructor),
+			Type: &ast.FuncType{
+				Params:  fields(field("pool", ptr(ast.NewIdent("connpool.Pool")))),
+				Results: fields(&ast.Field{Type: ast.NewIdent(iface)}),
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ReturnStmt{
+						Results: []ast.Expr{ast.NewIdent(fmt.Sprintf("&%s{pool: pool}", name))},
+					},
+				},
+			},
+		})
+	}
+
+	for _, rpc := range svc.RPCs {
+		var typ *ast.FuncType
+		if rpc.IsStreaming() {
+			typ = g.genStreamClientMethodType(ctx, svc.Name, rpc)
+		} else {
+			typ = g.genMethodType(ctx, rpc)
+			typ.Params.List = append(typ.Params.List, field("opts", ast.NewIdent("...grpc.CallOption")))
+		}
+		decls = append(decls, &ast.FuncDecl{
+			Recv: fields(field("c", ptr(ast.NewIdent(name)))),
+			Name: ast.NewIdent(rpc.Name),
+			Type: typ,
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ReturnStmt{
+						Results: []ast.Expr{ast.NewIdent(fmt.Sprintf("New%s(c.pool.Next()).%s(ctx, in, opts...)", iface, rpc.Name))},
+					},
+				},
+			},
+		})
+	}
+	return decls
+}
@@ -9,11 +9,23 @@ import (
 )
 
 type context struct {
+	serviceName     string
 	implName        string
 	constructorName string
 	proto           *protobuf.Package
 	pkg             *types.Package
 	imports         []string
+	// namedImports are the names of the imports that need one, indexed by
+	// their path, which are also in imports.
+	namedImports map[string]string
+	// pools are the names of the messages with a pool the results of the
+	// methods are taken from.
+	pools map[string]bool
+}
+
+// isPooled reports whether the message with the given name has a pool.
+func (c *context) isPooled(name string) bool {
+	return c.pools[name]
 }
 
 func (c *context) isNameDefined(name string) bool {
@@ -76,6 +88,49 @@ func (c *context) objectNameInContext(obj types.Object) string {
 	}
 }
 
+// typeString returns the representation of the given type in the package
+// being generated, adding the imports needed by it.
+func (c *context) typeString(typ types.Type) string {
+	return types.TypeString(typ, func(pkg *types.Package) string {
+		if pkg.Path() == c.pkgPath() {
+			return ""
+		}
+
+		c.addImport(pkg.Path())
+		return pkg.Name()
+	})
+}
+
+// paramType returns the Go type of the i-th parameter of the RPC function
+// that is sent in the request. That is, not counting the context.
+func (c *context) paramType(rpc *protobuf.RPC, i int) types.Type {
+	if rpc.HasCtx {
+		i++
+	}
+	return c.findSignature(rpc).Params().At(i).Type()
+}
+
+// optionParamType returns the Go type of the parameter of the func creating
+// the given functional option.
+func (c *context) optionParamType(option *protobuf.FuncOption) types.Type {
+	fn := c.pkg.Scope().Lookup(option.Func)
+	return fn.Type().(*types.Signature).Params().At(0).Type()
+}
+
+// resultType returns the Go type of the i-th result of the RPC function.
+func (c *context) resultType(rpc *protobuf.RPC, i int) types.Type {
+	return c.findSignature(rpc).Results().At(i).Type()
+}
+
+// streamElem returns the Go type of the values of the channel or iterator
+// returned by the function of the given server-streaming RPC.
+func (c *context) streamElem(rpc *protobuf.RPC) types.Type {
+	if ch, ok := c.resultType(rpc, 0).(*types.Chan); ok {
+		return ch.Elem()
+	}
+	return c.resultType(rpc, 0).(*types.Named).TypeArgs().At(0)
+}
+
 func firstTypeName(skip int, tuple *types.Tuple) types.Object {
 	t := tuple.At(skip).Type()
 	if inner, ok := t.(*types.Pointer); ok {
@@ -105,11 +160,25 @@ func (c *context) addImport(path string) {
 	c.imports = append(c.imports, path)
 }
 
-func serviceImplName(pkg *protobuf.Package) string {
-	n := pkg.ServiceName()
-	return strings.ToLower(string(n[0])) + n[1:] + "Server"
+// addNamedImport adds the import of the given path with the given name.
+func (c *context) addNamedImport(name, path string) {
+	if c.namedImports == nil {
+		c.namedImports = make(map[string]string)
+	}
+	c.namedImports[path] = name
+	c.addImport(path)
+}
+
+func serviceImplName(service string) string {
+	return strings.ToLower(string(service[0])) + service[1:] + "Server"
+}
+
+// serverParamName returns the name of the parameter of NewGRPCServer with the
+// server of the given service.
+func serverParamName(service string) string {
+	return strings.ToLower(string(service[0])) + service[1:]
 }
 
-func constructorName(pkg *protobuf.Package) string {
-	return fmt.Sprintf("New%sServer", pkg.ServiceName())
+func constructorName(service string) string {
+	return fmt.Sprintf("New%sServer", service)
 }
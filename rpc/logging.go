@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+)
+
+const newLoggingInterceptorName = "NewGRPCLoggingInterceptor"
+
+// loggingPkg is the package with the interceptor that logs a sample of the
+// calls with their sensitive fields redacted.
+const loggingPkg = "gitlab.com/ThatTomPerson/proteus/logging"
+
+// declLoggingInterceptor declares the constructor of the interceptor logging
+// the calls chosen by a sampler, which passes the paths of the sensitive
+// fields of the requests and responses of every RPC to the logging package.
+// It is not generated if it is already defined.
+func (g *Generator) declLoggingInterceptor(ctx *context, services []*protobuf.Service) []ast.Decl {
+	if ctx.isNameDefined(newLoggingInterceptorName) {
+		return nil
+	}
+
+	ctx.addImport("google.golang.org/grpc")
+	ctx.addImport(loggingPkg)
+
+	var buf bytes.Buffer
+	buf.WriteString("map[string]logging.Redaction{")
+	for _, svc := range services {
+		for _, rpc := range svc.RPCs {
+			req := sensitivePaths(ctx.proto, rpc.Input)
+			res := sensitivePaths(ctx.proto, rpc.Output)
+			if len(req) == 0 && len(res) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(&buf, "\n\t\t%q: {", fullMethodName(ctx.proto, svc, rpc))
+			if len(req) > 0 && len(res) > 0 {
+				fmt.Fprintf(&buf, "\n\t\t\tRequest:  %s,", stringSlice(req))
+			} else if len(req) > 0 {
+				fmt.Fprintf(&buf, "\n\t\t\tRequest: %s,", stringSlice(req))
+			}
+			if len(res) > 0 {
+				fmt.Fprintf(&buf, "\n\t\t\tResponse: %s,", stringSlice(res))
+			}
+			buf.WriteString("\n\t\t},")
+		}
+	}
+	if strings.HasSuffix(buf.String(), ",") {
+		buf.WriteString("\n\t")
+	}
+	buf.WriteString("}")
+
+	return []ast.Decl{
+		&ast.FuncDecl{
+			Name: ast.NewIdent(newLoggingInterceptorName),
+			Type: &ast.FuncType{
+				Params:  fields(field("sampler", ast.NewIdent("logging.Sampler"))),
+				Results: fields(&ast.Field{Type: ast.NewIdent("grpc.UnaryServerInterceptor")}),
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ReturnStmt{
+						Results: []ast.Expr{
+							&ast.CallExpr{
+								Fun: ast.NewIdent("logging.UnaryServerInterceptor"),
+								Args: []ast.Expr{
+									ast.NewIdent("sampler"),
+									ast.NewIdent(buf.String()),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// fullMethodName returns the name gRPC gives to the given RPC, e.g.
+// /users.UsersService/GetUser.
+func fullMethodName(proto *protobuf.Package, svc *protobuf.Service, rpc *protobuf.RPC) string {
+	return fmt.Sprintf("/%s.%s/%s", proto.Name, svc.Name, rpc.Name)
+}
+
+func stringSlice(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprintf("[]string{%s}", strings.Join(quoted, ", "))
+}
+
+// sensitivePaths returns the paths, as the logging package expects them, of
+// the sensitive fields of the given type and the messages it has. Only the
+// messages of the package are looked into, and the messages that have
+// themselves are only looked into once in every path.
+func sensitivePaths(proto *protobuf.Package, typ protobuf.Type) []string {
+	messages := make(map[string]*protobuf.Message, len(proto.Messages))
+	for _, m := range proto.Messages {
+		messages[m.Name] = m
+	}
+	return typeSensitivePaths(messages, typ, "", make(map[string]bool))
+}
+
+func typeSensitivePaths(messages map[string]*protobuf.Message, typ protobuf.Type, prefix string, visiting map[string]bool) []string {
+	switch t := typ.(type) {
+	case *protobuf.Alias:
+		return typeSensitivePaths(messages, t.Underlying, prefix, visiting)
+	case *protobuf.Map:
+		return typeSensitivePaths(messages, t.Value, prefix+"*.", visiting)
+	case *protobuf.Named:
+		msg, ok := messages[t.Name]
+		if t.Package != "" || !ok || visiting[t.Name] {
+			return nil
+		}
+
+		visiting[t.Name] = true
+		defer delete(visiting, t.Name)
+
+		var paths []string
+		for _, f := range msg.Fields {
+			path := prefix + f.JSONName()
+			if f.Sensitive() {
+				paths = append(paths, path)
+				continue
+			}
+			paths = append(paths, typeSensitivePaths(messages, f.Type, path+".", visiting)...)
+		}
+		return paths
+	}
+	return nil
+}
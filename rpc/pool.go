@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+// poolPkg is the package with the pools of the messages and the stats
+// handler that puts back the responses taken from them.
+const poolPkg = "gitlab.com/ThatTomPerson/proteus/pool"
+
+// poolServerOptions are the options added to the server to put back the
+// responses taken from the pools once their calls have ended.
+const poolServerOptions = `
+		grpc.StatsHandler(pool.StatsHandler()),`
+
+// poolName returns the name of the variable with the pool of the message
+// with the given name.
+func poolName(msg string) string {
+	return msg + "Pool"
+}
+
+// declPools declares the pool of every message of the package, which the
+// methods take their responses from. The pools whose names are already
+// defined are not generated, with a warning, and their messages are
+// allocated as without pools.
+func (g *Generator) declPools(ctx *context) []ast.Decl {
+	var specs []ast.Spec
+	for _, msg := range ctx.proto.Messages {
+		name := poolName(msg.Name)
+		if ctx.isNameDefined(name) {
+			report.Warn("%s is already defined, not generating the pool of %s", name, msg.Name)
+			continue
+		}
+
+		if ctx.pools == nil {
+			ctx.pools = make(map[string]bool)
+		}
+		ctx.pools[msg.Name] = true
+		specs = append(specs, &ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  ast.NewIdent(fmt.Sprintf("pool.Pool[%s, *%s]", msg.Name, msg.Name)),
+		})
+	}
+
+	if len(specs) == 0 {
+		return nil
+	}
+
+	ctx.addImport(poolPkg)
+	return []ast.Decl{
+		&ast.GenDecl{
+			Tok:    token.VAR,
+			Lparen: token.Pos(1),
+			Specs:  specs,
+		},
+	}
+}
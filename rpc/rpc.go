@@ -1,16 +1,21 @@
 package rpc // import "gitlab.com/ThatTomPerson/proteus/rpc"
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/printer"
 	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"gitlab.com/ThatTomPerson/proteus/output"
 	"gitlab.com/ThatTomPerson/proteus/protobuf"
 	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
 
 	"gopkg.in/src-d/go-parse-utils.v1"
 )
@@ -23,16 +28,17 @@ import (
 // Same happens with the constructor. If it does not exist, a function named
 // new{ServiceName}Server with no parameters and a single result of the type
 // {serviceName}Server will be generated. It can be defined, to avoid a default
-// implementation, but the same signature is required, as it is used for
-// registering the server implementation.
+// implementation, with the parameters the server needs, as the servers are
+// given to NewGRPCServer.
 //
 // So, if you have a service named FooService, you can implement
 // `fooServiceServer` and `func newFooServiceServer() *fooServiceServer`.
 //
 // All generated methods will use as receiver a field in the server
 // implementation with the same name as the type of the receiver.
-// For example, the method generated for `func (*Foo) Bar()` will be require
-// that our `fooServiceServer` had a field with that name.
+// For example, the method generated for `func (*Foo) Bar()`, which belongs to
+// the FooService service, will require that our `fooServiceServer` had a
+// field with that name.
 //
 // 	type fooServiceServer struct {
 //		Foo *Foo
@@ -42,22 +48,114 @@ import (
 // implement its receiver by yourself in the server implementation type and the
 // constructor.
 //
+// A NewGRPCServer func returning a gRPC server with the servers of all the
+// services registered is also generated, configured with a GRPCServerConfig
+// whose defaults, returned by DefaultGRPCServerConfig, set keepalive, max
+// concurrent streams and max message sizes suited for production. It takes
+// the servers after the config, created with their constructors, which may
+// need their receivers. As the server types, they are only generated if they
+// do not exist.
+//
+// The methods of the server-streaming RPCs, whose funcs return a channel or
+// an iterator, send their values to the stream through a buffer of the
+// streaming package declared for every RPC, named after the server type with
+// the name of the RPC and a Buffer suffix, e.g. fooServiceServerWatchBuffer.
+//
+// With the APIv2 protobuf API, a type serving every service with the messages
+// generated by protoc-gen-go is also generated, named after the server type
+// with an APIv2 suffix, e.g. fooServiceServerAPIv2, and NewGRPCServer
+// registers it instead. It converts the messages from and to the gogo ones,
+// so the same server works with both during a migration.
+//
+// With logging, a NewGRPCLoggingInterceptor func is also generated, which
+// returns an interceptor logging a sample of the calls with the fields of
+// the messages of the package marked as sensitive redacted.
+//
+// With error statuses, NewGRPCServer also adds the interceptors of the
+// errstatus package, which send the errors of the calls with the code and
+// the ErrorInfo and BadRequest details of their domain errors.
+//
+// With pools, a pool of the pool package is declared for every message of
+// the package, named after it with a Pool suffix, e.g. FooPool, and the
+// methods take the responses they return from them. NewGRPCServer adds the
+// stats handler of the pool package, which resets them and puts them back
+// once their calls have ended.
+//
+// With clients, a NewGRPCClientConn func is also generated, returning a
+// client connection to a target configured with a GRPCClientConfig, whose
+// defaults, returned by DefaultGRPCClientConfig, balance the calls between
+// the addresses of the target and set keepalive and max message sizes. With
+// tracing and error statuses, it adds the client interceptors of their
+// packages. If the package has idempotent RPCs, the config also has a
+// Hedging policy of the hedging package, which hedges their calls. A
+// NewGRPCClientPool func returns a pool of the connpool package with several
+// of those connections and, unless the messages are the ones of APIv2, whose
+// clients take the pool as it is, a client of every service sends its calls
+// to them in turn, e.g. the one returned by `NewFooServiceClientPool(pool)`.
+// As the server scaffold, they are only generated if they do not exist.
+//
 // A single file per package will be generated containing all the RPC methods.
 // The file will be written to the package path and it will be named
 // "server.proteus.go"
 type Generator struct {
-	importer *parseutil.Importer
+	importer  *parseutil.Importer
+	tracing   bool
+	errStatus bool
+	logging   bool
+	pools     bool
+	clients   bool
+	api       PackageAPI
 }
 
 // NewGenerator creates a new Generator.
 func NewGenerator() *Generator {
-	return &Generator{parseutil.NewImporter()}
+	return &Generator{importer: parseutil.NewImporter(), api: PackageAPI{API: GoGo}}
+}
+
+// SetAPI sets the protobuf API the servers of the next packages are
+// registered with, which is GoGo by default.
+func (g *Generator) SetAPI(api PackageAPI) {
+	g.api = api
+}
+
+// SetTracing sets whether the generated gRPC server propagates the trace
+// context and the request IDs of the calls with the interceptors of the
+// tracing package.
+func (g *Generator) SetTracing(enabled bool) {
+	g.tracing = enabled
+}
+
+// SetErrorStatus sets whether the generated gRPC server sends the errors of
+// the calls with the code and details of their domain errors with the
+// interceptors of the errstatus package.
+func (g *Generator) SetErrorStatus(enabled bool) {
+	g.errStatus = enabled
+}
+
+// SetLogging sets whether a NewGRPCLoggingInterceptor func is generated,
+// returning the interceptor of the logging package that logs a sample of the
+// calls with the sensitive fields of their messages redacted.
+func (g *Generator) SetLogging(enabled bool) {
+	g.logging = enabled
+}
+
+// SetPools sets whether a pool is declared for every message of the next
+// packages, which the methods take their responses from, and the generated
+// gRPC server puts them back in once their calls have ended.
+func (g *Generator) SetPools(enabled bool) {
+	g.pools = enabled
+}
+
+// SetClients sets whether the NewGRPCClientConn func and its config are
+// generated for the next packages.
+func (g *Generator) SetClients(enabled bool) {
+	g.clients = enabled
 }
 
 // Generate creates a new file in the package at the given path and implements
 // the server according to the given proto package.
 func (g *Generator) Generate(proto *protobuf.Package, path string) error {
-	if len(proto.RPCs) == 0 {
+	if !proto.HasRPCs() {
 		report.Warn("no RPCs in the given proto file, not generating anything")
 		return nil
 	}
@@ -78,11 +176,49 @@ func (g *Generator) Generate(proto *protobuf.Package, path string) error {
 	}
 
 	ctx := &context{
-		implName:        serviceImplName(proto),
-		constructorName: constructorName(proto),
-		proto:           proto,
-		pkg:             pkg,
+		proto: proto,
+		pkg:   pkg,
+	}
+
+	var (
+		decls    []ast.Decl
+		pools    []ast.Decl
+		services []*protobuf.Service
+	)
+	if g.pools {
+		pools = g.declPools(ctx)
+	}
+	for _, svc := range proto.Services {
+		if len(svc.RPCs) > 0 {
+			decls = append(decls, g.declService(ctx, svc)...)
+			services = append(services, svc)
+		}
+	}
+
+	if g.api.API == APIv2 {
+		for _, svc := range services {
+			decls = append(decls, g.declAPIv2Service(ctx, svc)...)
+		}
+		decls = append(decls, g.declAPIv2Conversions(ctx)...)
 	}
+	decls = append(decls, g.declServer(ctx, services)...)
+	if g.clients {
+		decls = append(decls, g.declClient(ctx, services)...)
+	}
+	if g.logging {
+		decls = append(decls, g.declLoggingInterceptor(ctx, services)...)
+	}
+	decls = append(decls, pools...)
+
+	return g.writeFile(g.buildFile(ctx, decls), path)
+}
+
+// declService returns the declarations of the implementation of the server
+// of the given service, that is, its type, its constructor and its methods.
+func (g *Generator) declService(ctx *context, svc *protobuf.Service) []ast.Decl {
+	ctx.serviceName = svc.Name
+	ctx.implName = serviceImplName(svc.Name)
+	ctx.constructorName = constructorName(svc.Name)
 
 	var decls []ast.Decl
 	if !ctx.isNameDefined(ctx.implName) {
@@ -94,11 +230,89 @@ func (g *Generator) Generate(proto *protobuf.Package, path string) error {
 		decls = append(decls, g.declConstructor(ctx.implName, ctx.constructorName))
 	}
 
-	for _, rpc := range proto.RPCs {
+	for _, rpc := range svc.RPCs {
+		if rpc.MaxConcurrency > 0 {
+			decls = append(decls, g.declSemaphore(ctx, rpc))
+		}
+
+		if rpc.IsStreaming() {
+			decls = append(decls, g.declBuffer(ctx, rpc), g.declStreamMethod(ctx, rpc))
+			continue
+		}
 		decls = append(decls, g.declMethod(ctx, rpc))
 	}
 
-	return g.writeFile(g.buildFile(ctx, decls), path)
+	return decls
+}
+
+// semaphoreName returns the name of the variable holding the semaphore that
+// limits the concurrent calls of the given RPC.
+func semaphoreName(ctx *context, rpc *protobuf.RPC) string {
+	return fmt.Sprintf("%s%sSemaphore", ctx.implName, rpc.Name)
+}
+
+// declSemaphore declares the semaphore of an RPC with a limit of concurrent
+// calls, which is a channel with as much capacity as calls are allowed.
+func (g *Generator) declSemaphore(ctx *context, rpc *protobuf.RPC) ast.Decl {
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{
+			&ast.ValueSpec{
+				Names: []*ast.Ident{ast.NewIdent(semaphoreName(ctx, rpc))},
+				Values: []ast.Expr{
+					&ast.CallExpr{
+						Fun: ast.NewIdent("make"),
+						Args: []ast.Expr{
+							ast.NewIdent("chan struct{}"),
+							ast.NewIdent(fmt.Sprint(rpc.MaxConcurrency)),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// genAcquireSemaphore generates the code that waits until the RPC can acquire
+// its semaphore, releasing it when the method returns, or returns the error
+// of the context if it is done before.
+func (g *Generator) genAcquireSemaphore(ctx *context, rpc *protobuf.RPC) ast.Stmt {
+	sem := semaphoreName(ctx, rpc)
+	return &ast.SelectStmt{
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.CommClause{
+					Comm: &ast.SendStmt{
+						Chan:  ast.NewIdent(sem),
+						Value: ast.NewIdent("struct{}{}"),
+					},
+					Body: []ast.Stmt{
+						&ast.DeferStmt{
+							Call: &ast.CallExpr{
+								Fun: ast.NewIdent(fmt.Sprintf("func() { <-%s }", sem)),
+							},
+						},
+					},
+				},
+				&ast.CommClause{
+					Comm: &ast.ExprStmt{
+						X: &ast.UnaryExpr{
+							Op: token.ARROW,
+							X:  ast.NewIdent("ctx.Done()"),
+						},
+					},
+					Body: []ast.Stmt{
+						&ast.AssignStmt{
+							Tok: token.ASSIGN,
+							Lhs: []ast.Expr{ast.NewIdent("err")},
+							Rhs: []ast.Expr{ast.NewIdent("ctx.Err()")},
+						},
+						new(ast.ReturnStmt),
+					},
+				},
+			},
+		},
+	}
 }
 
 func (g *Generator) declImplType(implName string) ast.Decl {
@@ -141,16 +355,22 @@ func (g *Generator) declConstructor(implName, constructorName string) ast.Decl {
 	}
 }
 
-func (g *Generator) genMethodType(ctx *context, rpc *protobuf.RPC) *ast.FuncType {
-	var in, out string
-
-	if isGenerated(rpc.Input) {
-		in = typeName(rpc.Input)
-	} else {
-		in = ctx.argumentType(rpc)
+// inputTypeName returns the name of the Go type of the request of the given
+// RPC.
+func (g *Generator) inputTypeName(ctx *context, rpc *protobuf.RPC) string {
+	if isGenerated(rpc.Input) || isInline(rpc.Input) {
+		return typeName(rpc.Input)
 	}
+	return ctx.argumentType(rpc)
+}
+
+func (g *Generator) genMethodType(ctx *context, rpc *protobuf.RPC) *ast.FuncType {
+	var (
+		in  = g.inputTypeName(ctx, rpc)
+		out string
+	)
 
-	if isGenerated(rpc.Output) {
+	if isGenerated(rpc.Output) || isInline(rpc.Output) {
 		out = typeName(rpc.Output)
 	} else {
 		out = ctx.returnType(rpc)
@@ -188,38 +408,321 @@ func (g *Generator) genMethodCall(ctx *context, rpc *protobuf.RPC) ast.Expr {
 				X: in,
 			}
 		}
+
+		if isInline(rpc.Input) {
+			in = g.genConversion(ctx, ctx.paramType(rpc, 0), in)
+		}
 		call.Args = append(call.Args, in)
 	} else {
 		msg := ctx.findMessage(typeName(rpc.Input))
-		for i := range msg.Fields {
-			call.Args = append(call.Args, ast.NewIdent(fmt.Sprintf(
-				"in.Arg%d", i+1,
-			)))
+		for i, f := range msg.Fields[:len(msg.Fields)-len(rpc.FuncOptions)] {
+			var arg ast.Expr = ast.NewIdent(fmt.Sprintf("in.Arg%d", i+1))
+			if isSet(f) {
+				arg = ast.NewIdent(fmt.Sprintf("arg%d", i+1))
+			} else if isInline(f.Type) {
+				arg = g.genConversion(ctx, ctx.paramType(rpc, i), arg)
+			}
+			call.Args = append(call.Args, arg)
+		}
+
+		if len(rpc.FuncOptions) > 0 {
+			call.Args = append(call.Args, ast.NewIdent("opts"))
 		}
 	}
 
 	return call
 }
 
-func (g *Generator) genBaseMethodBody(methodType *ast.FuncType) *ast.BlockStmt {
+// genConversion returns the conversion of the given expression, whose type is
+// the Go type declared by the generated code for an inline struct, or a
+// pointer to it, to the given type of the func it was scanned from.
+func (g *Generator) genConversion(ctx *context, typ types.Type, x ast.Expr) ast.Expr {
+	if p, ok := typ.(*types.Pointer); ok {
+		return conversion(ptr(ast.NewIdent(ctx.typeString(p.Elem()))), x)
+	}
+	return conversion(ast.NewIdent(ctx.typeString(typ)), x)
+}
+
+// genInlineConversion returns the conversion of the given expression, whose
+// type is a type of the func, to the Go type declared by the generated code
+// for the inline struct with the given name it was scanned as, or to a
+// pointer to it.
+func (g *Generator) genInlineConversion(name string, pointer bool, x ast.Expr) ast.Expr {
+	if pointer {
+		return conversion(ptr(ast.NewIdent(name)), x)
+	}
+	return conversion(ast.NewIdent(name), x)
+}
+
+// genInputConversions returns the statements needed to convert the fields of
+// the request into the types of the arguments of the function, when they can
+// not be passed directly. The converted arguments are stored in variables
+// named argN.
+func (g *Generator) genInputConversions(ctx *context, rpc *protobuf.RPC) (stmts []ast.Stmt) {
+	if !isGenerated(rpc.Input) {
+		return
+	}
+
+	msg := ctx.findMessage(typeName(rpc.Input))
+	for i, f := range msg.Fields {
+		if !isSet(f) {
+			continue
+		}
+
+		arg := fmt.Sprintf("arg%d", i+1)
+		field := fmt.Sprintf("in.Arg%d", i+1)
+		stmts = append(stmts, g.genSliceToSet(ctx.typeString(ctx.paramType(rpc, i)), arg, field, setOf(f).Bool)...)
+	}
+
+	if len(rpc.FuncOptions) > 0 {
+		stmts = append(stmts, g.genFuncOptions(ctx, rpc)...)
+	}
+
+	return
+}
+
+// genFuncOptions generates the code to store in a new variable named opts
+// the functional options of the function whose fields are set in the
+// request.
+func (g *Generator) genFuncOptions(ctx *context, rpc *protobuf.RPC) []ast.Stmt {
+	params := ctx.findSignature(rpc).Params()
+	option := params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+
+	stmts := []ast.Stmt{
+		&ast.DeclStmt{
+			Decl: &ast.GenDecl{
+				Tok: token.VAR,
+				Specs: []ast.Spec{
+					&ast.ValueSpec{
+						Names: []*ast.Ident{ast.NewIdent("opts")},
+						Type:  ast.NewIdent("[]" + ctx.typeString(option)),
+					},
+				},
+			},
+		},
+	}
+
+	for _, o := range rpc.FuncOptions {
+		field := "in." + o.Field
+		var arg ast.Expr = ast.NewIdent(field)
+		var cond ast.Expr = &ast.BinaryExpr{
+			X:  ast.NewIdent(field),
+			Op: token.NEQ,
+			Y:  ast.NewIdent("nil"),
+		}
+
+		switch typ := ctx.optionParamType(o); {
+		case o.Implicit:
+			cond = isNotZero(field, typ)
+		case !isNilable(typ):
+			arg = &ast.StarExpr{X: arg}
+		}
+
+		stmts = append(stmts, &ast.IfStmt{
+			Cond: cond,
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Tok: token.ASSIGN,
+						Lhs: []ast.Expr{ast.NewIdent("opts")},
+						Rhs: []ast.Expr{
+							&ast.CallExpr{
+								Fun: ast.NewIdent("append"),
+								Args: []ast.Expr{
+									ast.NewIdent("opts"),
+									&ast.CallExpr{
+										Fun:  ast.NewIdent(o.Func),
+										Args: []ast.Expr{arg},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return stmts
+}
+
+// isNotZero returns the expression reporting whether the given field, of a
+// basic type or an enum, does not have its zero value.
+func isNotZero(field string, typ types.Type) ast.Expr {
+	zero := "0"
+	if basic, ok := typ.Underlying().(*types.Basic); ok {
+		switch {
+		case basic.Info()&types.IsBoolean != 0:
+			return ast.NewIdent(field)
+		case basic.Info()&types.IsString != 0:
+			zero = `""`
+		}
+	}
+
+	return &ast.BinaryExpr{
+		X:  ast.NewIdent(field),
+		Op: token.NEQ,
+		Y:  ast.NewIdent(zero),
+	}
+}
+
+// isNilable reports whether the fields of the given type are nil when they
+// are not set in a request, without being pointers to the type.
+func isNilable(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map:
+		return true
+	}
+	return false
+}
+
+// genOutputConversions returns the statements needed to convert the results of
+// the function stored in variables named resultN into the fields of the
+// response, along with the declarations of said variables.
+func (g *Generator) genOutputConversions(ctx *context, rpc *protobuf.RPC, msg *protobuf.Message) (decls, stmts []ast.Stmt) {
+	for i, f := range msg.Fields {
+		if f == nil || !(isSet(f) || isInline(f.Type)) {
+			continue
+		}
+
+		res := fmt.Sprintf("result%d", i+1)
+		decls = append(decls, &ast.DeclStmt{
+			Decl: &ast.GenDecl{
+				Tok: token.VAR,
+				Specs: []ast.Spec{
+					&ast.ValueSpec{
+						Names: []*ast.Ident{ast.NewIdent(res)},
+						Type:  ast.NewIdent(ctx.typeString(ctx.resultType(rpc, i))),
+					},
+				},
+			},
+		})
+		if isInline(f.Type) {
+			stmts = append(stmts, &ast.AssignStmt{
+				Tok: token.ASSIGN,
+				Lhs: []ast.Expr{ast.NewIdent(fmt.Sprintf("result.Result%d", i+1))},
+				Rhs: []ast.Expr{g.genInlineConversion(typeName(f.Type), f.Type.IsNullable(), ast.NewIdent(res))},
+			})
+			continue
+		}
+		stmts = append(stmts, g.genSetToSlice(fmt.Sprintf("result.Result%d", i+1), res, setOf(f).Bool))
+	}
+
+	return
+}
+
+// genSliceToSet generates the code to store in a new variable named set of
+// the given type all the elements in slice. The values of bool sets are true
+// and the ones of the rest, empty structs.
+func (g *Generator) genSliceToSet(typ, set, slice string, isBool bool) []ast.Stmt {
+	value := "struct{}{}"
+	if isBool {
+		value = "true"
+	}
+
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Tok: token.DEFINE,
+			Lhs: []ast.Expr{ast.NewIdent(set)},
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun: ast.NewIdent("make"),
+					Args: []ast.Expr{
+						ast.NewIdent(typ),
+						&ast.CallExpr{
+							Fun:  ast.NewIdent("len"),
+							Args: []ast.Expr{ast.NewIdent(slice)},
+						},
+					},
+				},
+			},
+		},
+		&ast.RangeStmt{
+			Key:   ast.NewIdent("_"),
+			Value: ast.NewIdent("v"),
+			Tok:   token.DEFINE,
+			X:     ast.NewIdent(slice),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Tok: token.ASSIGN,
+						Lhs: []ast.Expr{
+							&ast.IndexExpr{
+								X:     ast.NewIdent(set),
+								Index: ast.NewIdent("v"),
+							},
+						},
+						Rhs: []ast.Expr{ast.NewIdent(value)},
+					},
+				},
+			},
+		},
+	}
+}
+
+// genSetToSlice generates the code to append to slice all the elements in set.
+// Only the keys set to true are elements of bool sets.
+func (g *Generator) genSetToSlice(slice, set string, isBool bool) ast.Stmt {
+	var appendStmt ast.Stmt = &ast.AssignStmt{
+		Tok: token.ASSIGN,
+		Lhs: []ast.Expr{ast.NewIdent(slice)},
+		Rhs: []ast.Expr{
+			&ast.CallExpr{
+				Fun:  ast.NewIdent("append"),
+				Args: []ast.Expr{ast.NewIdent(slice), ast.NewIdent("v")},
+			},
+		},
+	}
+
+	loop := &ast.RangeStmt{
+		Key:  ast.NewIdent("v"),
+		Tok:  token.DEFINE,
+		X:    ast.NewIdent(set),
+		Body: &ast.BlockStmt{List: []ast.Stmt{appendStmt}},
+	}
+
+	if isBool {
+		loop.Value = ast.NewIdent("ok")
+		loop.Body.List = []ast.Stmt{
+			&ast.IfStmt{
+				Cond: ast.NewIdent("ok"),
+				Body: &ast.BlockStmt{List: []ast.Stmt{appendStmt}},
+			},
+		}
+	}
+	return loop
+}
+
+func (g *Generator) genBaseMethodBody(ctx *context, methodType *ast.FuncType) *ast.BlockStmt {
 	return &ast.BlockStmt{
 		List: []ast.Stmt{
 			&ast.AssignStmt{
 				Tok: token.ASSIGN,
 				Lhs: []ast.Expr{ast.NewIdent("result")},
 				Rhs: []ast.Expr{
-					&ast.CallExpr{
-						Fun: ast.NewIdent("new"),
-						Args: []ast.Expr{
-							methodType.Results.List[0].Type.(*ast.StarExpr).X,
-						},
-					},
+					g.genNewResult(ctx, methodType.Results.List[0].Type.(*ast.StarExpr).X),
 				},
 			},
 		},
 	}
 }
 
+// genNewResult returns the expression allocating a result of the given type,
+// which is taken from its pool if it has one.
+func (g *Generator) genNewResult(ctx *context, typ ast.Expr) ast.Expr {
+	if id, ok := typ.(*ast.Ident); ok && ctx.isPooled(id.Name) {
+		return &ast.CallExpr{
+			Fun:  ast.NewIdent(poolName(id.Name) + ".GetFor"),
+			Args: []ast.Expr{ast.NewIdent("ctx")},
+		}
+	}
+
+	return &ast.CallExpr{
+		Fun:  ast.NewIdent("new"),
+		Args: []ast.Expr{typ},
+	}
+}
+
 func (g *Generator) genMethodBody(ctx *context, rpc *protobuf.RPC, typ *ast.FuncType) *ast.BlockStmt {
 	if !isGenerated(rpc.Output) {
 		return g.genMethodBodyForNotGeneratedOutput(ctx, rpc, typ)
@@ -232,6 +735,8 @@ func (g *Generator) genMethodBodyAssignmentsForGeneratedOutput(ctx *context, rpc
 	for i, f := range msg.Fields {
 		if f == nil {
 			lhs = append(lhs, ast.NewIdent("_"))
+		} else if isSet(f) || isInline(f.Type) {
+			lhs = append(lhs, ast.NewIdent(fmt.Sprintf("result%d", i+1)))
 		} else {
 			lhs = append(lhs, ast.NewIdent(fmt.Sprintf(
 				"result.Result%d", i+1,
@@ -241,18 +746,19 @@ func (g *Generator) genMethodBodyAssignmentsForGeneratedOutput(ctx *context, rpc
 	return
 }
 
-func emptyBodyForMethodCall(body *ast.BlockStmt, methodCall ast.Expr) *ast.BlockStmt {
-	body.List = []ast.Stmt{
+func emptyBodyForMethodCall(body *ast.BlockStmt, conversions []ast.Stmt, methodCall ast.Expr) *ast.BlockStmt {
+	body.List = append(conversions,
 		&ast.ExprStmt{
 			X: methodCall,
 		},
 		new(ast.ReturnStmt),
-	}
+	)
 	return body
 }
 
 func (g *Generator) genMethodBodyForGeneratedOutput(ctx *context, rpc *protobuf.RPC, typ *ast.FuncType) *ast.BlockStmt {
-	body := g.genBaseMethodBody(typ)
+	body := g.genBaseMethodBody(ctx, typ)
+	conversions := g.genInputConversions(ctx, rpc)
 	methodCall := g.genMethodCall(ctx, rpc)
 	call := &ast.AssignStmt{
 		Tok: token.ASSIGN,
@@ -262,11 +768,14 @@ func (g *Generator) genMethodBodyForGeneratedOutput(ctx *context, rpc *protobuf.
 	msg := ctx.findMessage(typeName(rpc.Output))
 
 	if len(msg.Fields) == 0 && !rpc.HasError {
-		return emptyBodyForMethodCall(body, methodCall)
+		return emptyBodyForMethodCall(body, conversions, methodCall)
 	} else if len(msg.Fields) == 0 {
 		body.List = nil
 	}
 
+	decls, outConversions := g.genOutputConversions(ctx, rpc, msg)
+	body.List = append(body.List, conversions...)
+	body.List = append(body.List, decls...)
 	body.List = append(body.List, call)
 	lhs := g.genMethodBodyAssignmentsForGeneratedOutput(ctx, rpc, msg)
 	call.Lhs = append(call.Lhs, lhs...)
@@ -275,19 +784,34 @@ func (g *Generator) genMethodBodyForGeneratedOutput(ctx *context, rpc *protobuf.
 		call.Lhs = append(call.Lhs, ast.NewIdent("err"))
 	}
 
+	body.List = append(body.List, outConversions...)
 	body.List = append(body.List, new(ast.ReturnStmt))
 	return body
 }
 
+// genMethodBodyForNotGeneratedOutput generates the body of a method whose
+// result is returned as is. It is not allocated beforehand, as the call
+// always overwrites it, unless it is a value of a message with a pool, which
+// the result of the call is stored in.
 func (g *Generator) genMethodBodyForNotGeneratedOutput(ctx *context, rpc *protobuf.RPC, typ *ast.FuncType) *ast.BlockStmt {
-	body := g.genBaseMethodBody(typ)
+	body := new(ast.BlockStmt)
+	body.List = append(body.List, g.genInputConversions(ctx, rpc)...)
 	methodCall := g.genMethodCall(ctx, rpc)
 	call := &ast.AssignStmt{Tok: token.ASSIGN}
 
 	needToAddressOutput := !isGenerated(rpc.Output) && !rpc.Output.IsNullable()
+	needToConvertOutput := isInline(rpc.Output)
 
-	// Specific code
-	if needToAddressOutput {
+	resultType := typ.Results.List[0].Type.(*ast.StarExpr).X
+	if id, ok := resultType.(*ast.Ident); ok && needToAddressOutput && !needToConvertOutput && ctx.isPooled(id.Name) {
+		body.List = append(body.List, &ast.AssignStmt{
+			Tok: token.ASSIGN,
+			Lhs: []ast.Expr{ast.NewIdent("result")},
+			Rhs: []ast.Expr{g.genNewResult(ctx, resultType)},
+		})
+		call.Lhs = append(call.Lhs, ast.NewIdent("*result"))
+		needToAddressOutput = false
+	} else if needToAddressOutput || needToConvertOutput {
 		call.Lhs = append(call.Lhs, ast.NewIdent("aux"))
 		call.Tok = token.DEFINE
 	} else {
@@ -301,16 +825,23 @@ func (g *Generator) genMethodBodyForNotGeneratedOutput(ctx *context, rpc *protob
 	call.Rhs = append(call.Rhs, methodCall)
 	body.List = append(body.List, call)
 
-	if needToAddressOutput {
+	if needToAddressOutput || needToConvertOutput {
+		var aux ast.Expr = ast.NewIdent("aux")
+		if needToAddressOutput {
+			aux = &ast.UnaryExpr{
+				Op: token.AND,
+				X:  aux,
+			}
+		}
+
+		if needToConvertOutput {
+			aux = g.genInlineConversion(typeName(rpc.Output), true, aux)
+		}
+
 		body.List = append(body.List, &ast.AssignStmt{
 			Tok: token.ASSIGN,
 			Lhs: []ast.Expr{ast.NewIdent("result")},
-			Rhs: []ast.Expr{
-				&ast.UnaryExpr{
-					Op: token.AND,
-					X:  ast.NewIdent("aux"),
-				},
-			},
+			Rhs: []ast.Expr{aux},
 		})
 	}
 	body.List = append(body.List, new(ast.ReturnStmt))
@@ -319,11 +850,16 @@ func (g *Generator) genMethodBodyForNotGeneratedOutput(ctx *context, rpc *protob
 
 func (g *Generator) declMethod(ctx *context, rpc *protobuf.RPC) ast.Decl {
 	typ := g.genMethodType(ctx, rpc)
+	body := g.genMethodBody(ctx, rpc, typ)
+	if rpc.MaxConcurrency > 0 {
+		body.List = append([]ast.Stmt{g.genAcquireSemaphore(ctx, rpc)}, body.List...)
+	}
+
 	return &ast.FuncDecl{
 		Recv: fields(field("s", ptr(ast.NewIdent(ctx.implName)))),
 		Name: ast.NewIdent(rpc.Name),
 		Type: typ,
-		Body: g.genMethodBody(ctx, rpc, typ),
+		Body: body,
 	}
 }
 
@@ -334,7 +870,11 @@ func (g *Generator) buildFile(ctx *context, decls []ast.Decl) *ast.File {
 
 	var specs = []ast.Spec{newNamedImport("xcontext", "golang.org/x/net/context")}
 	for _, i := range ctx.imports {
-		specs = append(specs, newImport(i))
+		if name, ok := ctx.namedImports[i]; ok {
+			specs = append(specs, newNamedImport(name, i))
+		} else {
+			specs = append(specs, newImport(i))
+		}
 	}
 
 	f.Decls = append(f.Decls, &ast.GenDecl{
@@ -347,15 +887,35 @@ func (g *Generator) buildFile(ctx *context, decls []ast.Decl) *ast.File {
 	return f
 }
 
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, "server.proteus.go")
+}
+
+// writeFile writes the file of the package at the given path with a header
+// that marks it as generated and every declaration separated by a blank line,
+// so tools can recognize the generated code. It is formatted like gofmt does,
+// with the imports sorted.
 func (g *Generator) writeFile(file *ast.File, path string) error {
-	fileName := filepath.Join(goSrc, path, "server.proteus.go")
-	f, err := os.Create(fileName)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s\n\npackage %s\n", protobuf.GeneratedBy(path), file.Name.Name)
+
+	fset := token.NewFileSet()
+	for _, decl := range file.Decls {
+		buf.WriteString("\n")
+		if err := printer.Fprint(&buf, fset, decl); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+
+	src, err := format.Source(buf.Bytes())
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	return printer.Fprint(f, token.NewFileSet(), file)
+	return output.WriteFile(g.FileName(path), src, 0644)
 }
 
 func typeName(t protobuf.Type) string {
@@ -365,6 +925,32 @@ func typeName(t protobuf.Type) string {
 	return ""
 }
 
+// isSet reports whether the field was a Go set, which needs to be converted
+// from and to a slice.
+func isSet(f *protobuf.Field) bool {
+	return setOf(f) != nil
+}
+
+// setOf returns the Go set the field was, or nil if it was not a set.
+func setOf(f *protobuf.Field) *scanner.Set {
+	switch src := f.Type.Source().(type) {
+	case *scanner.Set:
+		return src
+	case *scanner.Alias:
+		set, _ := src.Underlying.(*scanner.Set)
+		return set
+	}
+	return nil
+}
+
+// isInline reports whether the type is an inline struct, whose Go type is
+// declared by the generated code and converted from and to the one of the
+// func.
+func isInline(t protobuf.Type) bool {
+	n, ok := t.Source().(*scanner.Named)
+	return ok && n.Inline && !n.IsRepeated()
+}
+
 func isGenerated(t protobuf.Type) bool {
 	if typ, ok := t.(*protobuf.Named); ok {
 		return typ.Generated
@@ -408,6 +994,13 @@ func ptr(expr ast.Expr) ast.Expr {
 	return &ast.StarExpr{X: expr}
 }
 
+func conversion(typ, x ast.Expr) ast.Expr {
+	if _, ok := typ.(*ast.StarExpr); ok {
+		typ = &ast.ParenExpr{X: typ}
+	}
+	return &ast.CallExpr{Fun: typ, Args: []ast.Expr{x}}
+}
+
 var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
 
 func removeGoPath(path string) string {
@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -48,26 +49,34 @@ func (s *RPCSuite) TestDeclConstructor() {
 }
 
 const expectedFuncNotGenerated = `func (s *FooServer) DoFoo(ctx xcontext.Context, in *Foo) (result *Bar, err error) {
-	result = new(Bar)
 	result = DoFoo(in)
 	return
 }`
 
 const expectedFuncNotGeneratedCtx = `func (s *FooServer) DoFooCtx(ctx xcontext.Context, in *Foo) (result *Bar, err error) {
-	result = new(Bar)
 	result = DoFooCtx(ctx, in)
 	return
 }`
 
 const expectedFuncNotGeneratedAndNotNullable = `func (s *FooServer) DoFoo(ctx xcontext.Context, in *Foo) (result *Bar, err error) {
-	result = new(Bar)
 	aux := DoFoo(in)
 	result = &aux
 	return
 }`
 
+const expectedFuncWithMaxConcurrency = `func (s *FooServer) DoFoo(ctx xcontext.Context, in *Foo) (result *Bar, err error) {
+	select {
+	case FooServerDoFooSemaphore <- struct{}{}:
+		defer func() { <-FooServerDoFooSemaphore }()
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+	result = DoFoo(in)
+	return
+}`
+
 const expectedFuncNotGeneratedAndNotNullableIn = `func (s *FooServer) DoFoo(ctx xcontext.Context, in *Foo) (result *Bar, err error) {
-	result = new(Bar)
 	result = DoFoo(*in)
 	return
 }`
@@ -90,6 +99,62 @@ const expectedFuncGeneratedWithError = `func (s *FooServer) DoFoo(ctx xcontext.C
 	return
 }`
 
+const expectedFuncGeneratedWithSets = `func (s *FooServer) Tags(ctx xcontext.Context, in *TagsRequest) (result *TagsResponse, err error) {
+	result = new(TagsResponse)
+	arg1 := make(map[int64]struct{}, len(in.Arg1))
+	for _, v := range in.Arg1 {
+		arg1[v] = struct{}{}
+	}
+	var result1 map[string]struct{}
+	result1, err = Tags(ctx, arg1, in.Arg2)
+	for v := range result1 {
+		result.Result1 = append(result.Result1, v)
+	}
+	return
+}`
+
+const expectedFuncGeneratedWithBoolSets = `func (s *FooServer) Flags(ctx xcontext.Context, in *FlagsRequest) (result *FlagsResponse, err error) {
+	result = new(FlagsResponse)
+	arg1 := make(map[string]bool, len(in.Arg1))
+	for _, v := range in.Arg1 {
+		arg1[v] = true
+	}
+	var result1 map[string]bool
+	result1 = Flags(arg1)
+	for v, ok := range result1 {
+		if ok {
+			result.Result1 = append(result.Result1, v)
+		}
+	}
+	return
+}`
+
+const expectedFuncGeneratedWithOptions = `func (s *FooServer) List(ctx xcontext.Context, in *ListRequest) (result *ListResponse, err error) {
+	result = new(ListResponse)
+	var opts []ListOption
+	if in.Limit != nil {
+		opts = append(opts, WithLimit(*in.Limit))
+	}
+	if in.Tags != nil {
+		opts = append(opts, WithTags(in.Tags))
+	}
+	result.Result1 = List(in.Arg1, opts...)
+	return
+}`
+
+const expectedFuncGeneratedWithImplicitOptions = `func (s *FooServer) List(ctx xcontext.Context, in *ListRequest) (result *ListResponse, err error) {
+	result = new(ListResponse)
+	var opts []ListOption
+	if in.Limit != 0 {
+		opts = append(opts, WithLimit(in.Limit))
+	}
+	if in.Tags != nil {
+		opts = append(opts, WithTags(in.Tags))
+	}
+	result.Result1 = List(in.Arg1, opts...)
+	return
+}`
+
 const expectedMethod = `func (s *FooServer) Fooer_DoFoo(ctx xcontext.Context, in *FooRequest) (result *FooResponse, err error) {
 	result = new(FooResponse)
 	result.Result1, result.Result2, result.Result3, err = s.Fooer.DoFoo(in.Arg1, in.Arg2, in.Arg3)
@@ -102,6 +167,12 @@ const expectedMethodExternalInput = `func (s *FooServer) T_Foo(ctx xcontext.Cont
 	return
 }`
 
+const expectedFuncInline = `func (s *FooServer) Rename(ctx xcontext.Context, in *PageFoo) (result *PageFoo, err error) {
+	aux := Rename(Page[Foo](*in))
+	result = (*PageFoo)(aux)
+	return
+}`
+
 const expectedFuncEmptyInAndOut = `func (s *FooServer) Empty(ctx xcontext.Context, in *Empty) (result *Empty, err error) {
 	Empty()
 	return
@@ -112,6 +183,92 @@ const expectedFuncEmptyInAndOutWithError = `func (s *FooServer) Empty(ctx xconte
 	return
 }`
 
+func (s *RPCSuite) TestDeclService() {
+	ctx := &context{
+		proto: &protobuf.Package{},
+		pkg:   s.fakePkg(),
+	}
+
+	decls := s.g.declService(ctx, &protobuf.Service{Name: "StoreService"})
+	s.Equal(2, len(decls))
+	s.Equal("storeServiceServer", ctx.implName)
+	s.Equal("NewStoreServiceServer", ctx.constructorName)
+}
+
+func (s *RPCSuite) TestDeclSemaphore() {
+	ctx := &context{implName: "FooServer"}
+	output, err := render(s.g.declSemaphore(ctx, &protobuf.RPC{Name: "DoFoo", MaxConcurrency: 32}))
+	s.Nil(err)
+	s.Equal("var FooServerDoFooSemaphore = make(chan struct{}, 32)", output)
+}
+
+const expectedStreamMethodChan = `func (s *FooServer) Watch(in *WatchRequest, stream FooService_WatchServer) (err error) {
+	ctx := stream.Context()
+	select {
+	case FooServerWatchSemaphore <- struct{}{}:
+		defer func() { <-FooServerWatchSemaphore }()
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+	result, err := Watch(ctx, in.Arg1)
+	if err != nil {
+		return
+	}
+	return streaming.SendChan(ctx, FooServerWatchBuffer, result, stream.Send)
+}`
+
+const expectedStreamMethodSeq = `func (s *FooServer) Events(in *Foo, stream FooService_EventsServer) (err error) {
+	ctx := stream.Context()
+	result := Events(in)
+	return streaming.SendSeq(ctx, FooServerEventsBuffer, result, func(v Event) error {
+		return stream.Send(&v)
+	})
+}`
+
+func (s *RPCSuite) TestDeclStreamMethod() {
+	ctx := &context{implName: "FooServer", serviceName: "FooService", pkg: s.fakePkg(), proto: &protobuf.Package{
+		Messages: []*protobuf.Message{
+			{Name: "WatchRequest", Fields: []*protobuf.Field{{Name: "arg1", Type: protobuf.NewBasic("int64")}}},
+		},
+	}}
+
+	watch := &protobuf.RPC{
+		Name:           "Watch",
+		Method:         "Watch",
+		HasCtx:         true,
+		HasError:       true,
+		Input:          protobuf.NewGeneratedNamed("", "WatchRequest"),
+		Output:         nullable(protobuf.NewNamed("", "Event")),
+		MaxConcurrency: 4,
+		Stream:         scanner.ChanStream,
+		StreamBuffer:   scanner.StreamBuffer{Size: 16, Drop: true},
+	}
+	output, err := render(s.g.declBuffer(ctx, watch))
+	s.Nil(err)
+	s.Equal("var FooServerWatchBuffer = streaming.Buffer{Size: 16, Policy: streaming.Drop}", output)
+
+	output, err = render(s.g.declStreamMethod(ctx, watch))
+	s.Nil(err)
+	s.Equal(expectedStreamMethodChan, output)
+	s.Contains(ctx.imports, streamingPkg)
+
+	events := &protobuf.RPC{
+		Name:   "Events",
+		Method: "Events",
+		Input:  nullable(protobuf.NewNamed("", "Foo")),
+		Output: notNullable(protobuf.NewNamed("", "Event")),
+		Stream: scanner.SeqStream,
+	}
+	output, err = render(s.g.declBuffer(ctx, events))
+	s.Nil(err)
+	s.Equal("var FooServerEventsBuffer = streaming.Buffer{Size: 0, Policy: streaming.Block}", output)
+
+	output, err = render(s.g.declStreamMethod(ctx, events))
+	s.Nil(err)
+	s.Equal(expectedStreamMethodSeq, output)
+}
+
 func (s *RPCSuite) TestDeclMethod() {
 	cases := []struct {
 		name   string
@@ -128,6 +285,17 @@ func (s *RPCSuite) TestDeclMethod() {
 			},
 			expectedFuncNotGenerated,
 		},
+		{
+			"func with max concurrency",
+			&protobuf.RPC{
+				Name:           "DoFoo",
+				Method:         "DoFoo",
+				Input:          nullable(protobuf.NewNamed("", "Foo")),
+				Output:         nullable(protobuf.NewNamed("", "Bar")),
+				MaxConcurrency: 32,
+			},
+			expectedFuncWithMaxConcurrency,
+		},
 		{
 			"func not generated with ctx",
 			&protobuf.RPC{
@@ -191,6 +359,58 @@ func (s *RPCSuite) TestDeclMethod() {
 			},
 			expectedFuncGeneratedWithError,
 		},
+		{
+			"func generated with sets",
+			&protobuf.RPC{
+				Name:     "Tags",
+				Method:   "Tags",
+				HasCtx:   true,
+				HasError: true,
+				Input:    nullable(protobuf.NewGeneratedNamed("", "TagsRequest")),
+				Output:   nullable(protobuf.NewGeneratedNamed("", "TagsResponse")),
+			},
+			expectedFuncGeneratedWithSets,
+		},
+		{
+			"func generated with bool sets",
+			&protobuf.RPC{
+				Name:   "Flags",
+				Method: "Flags",
+				Input:  nullable(protobuf.NewGeneratedNamed("", "FlagsRequest")),
+				Output: nullable(protobuf.NewGeneratedNamed("", "FlagsResponse")),
+			},
+			expectedFuncGeneratedWithBoolSets,
+		},
+		{
+			"func generated with options",
+			&protobuf.RPC{
+				Name:       "List",
+				Method:     "List",
+				IsVariadic: true,
+				Input:      nullable(protobuf.NewGeneratedNamed("", "ListRequest")),
+				Output:     nullable(protobuf.NewGeneratedNamed("", "ListResponse")),
+				FuncOptions: []*protobuf.FuncOption{
+					{Func: "WithLimit", Field: "Limit"},
+					{Func: "WithTags", Field: "Tags"},
+				},
+			},
+			expectedFuncGeneratedWithOptions,
+		},
+		{
+			"func generated with implicit options",
+			&protobuf.RPC{
+				Name:       "List",
+				Method:     "List",
+				IsVariadic: true,
+				Input:      nullable(protobuf.NewGeneratedNamed("", "ListRequest")),
+				Output:     nullable(protobuf.NewGeneratedNamed("", "ListResponse")),
+				FuncOptions: []*protobuf.FuncOption{
+					{Func: "WithLimit", Field: "Limit", Implicit: true},
+					{Func: "WithTags", Field: "Tags"},
+				},
+			},
+			expectedFuncGeneratedWithImplicitOptions,
+		},
 		{
 			"method call",
 			&protobuf.RPC{
@@ -215,6 +435,16 @@ func (s *RPCSuite) TestDeclMethod() {
 			},
 			expectedMethodExternalInput,
 		},
+		{
+			"func with inline input and output",
+			&protobuf.RPC{
+				Name:   "Rename",
+				Method: "Rename",
+				Input:  inline(protobuf.NewNamed("", "PageFoo"), false),
+				Output: inline(protobuf.NewNamed("", "PageFoo"), true),
+			},
+			expectedFuncInline,
+		},
 		{
 			"func with empty input and output",
 			&protobuf.RPC{
@@ -286,6 +516,88 @@ func (s *RPCSuite) TestDeclMethod() {
 					},
 				},
 			},
+			&protobuf.Message{
+				Name: "TagsRequest",
+				Fields: []*protobuf.Field{
+					&protobuf.Field{
+						Name:     "Arg1",
+						Pos:      1,
+						Repeated: true,
+						Type:     set(protobuf.NewBasic("int64")),
+					},
+					&protobuf.Field{
+						Name:     "Arg2",
+						Pos:      2,
+						Repeated: false,
+						Type:     protobuf.NewBasic("string"),
+					},
+				},
+			},
+			&protobuf.Message{
+				Name: "TagsResponse",
+				Fields: []*protobuf.Field{
+					&protobuf.Field{
+						Name:     "Result1",
+						Pos:      1,
+						Repeated: true,
+						Type:     set(protobuf.NewBasic("string")),
+					},
+				},
+			},
+			&protobuf.Message{
+				Name: "FlagsRequest",
+				Fields: []*protobuf.Field{
+					&protobuf.Field{
+						Name:     "Arg1",
+						Pos:      1,
+						Repeated: true,
+						Type:     boolSet(protobuf.NewBasic("string")),
+					},
+				},
+			},
+			&protobuf.Message{
+				Name: "FlagsResponse",
+				Fields: []*protobuf.Field{
+					&protobuf.Field{
+						Name:     "Result1",
+						Pos:      1,
+						Repeated: true,
+						Type:     boolSet(protobuf.NewBasic("string")),
+					},
+				},
+			},
+			&protobuf.Message{
+				Name: "ListRequest",
+				Fields: []*protobuf.Field{
+					&protobuf.Field{
+						Name: "Arg1",
+						Pos:  1,
+						Type: protobuf.NewBasic("string"),
+					},
+					&protobuf.Field{
+						Name: "Limit",
+						Pos:  2,
+						Type: protobuf.NewBasic("int64"),
+					},
+					&protobuf.Field{
+						Name:     "Tags",
+						Pos:      3,
+						Repeated: true,
+						Type:     protobuf.NewBasic("string"),
+					},
+				},
+			},
+			&protobuf.Message{
+				Name: "ListResponse",
+				Fields: []*protobuf.Field{
+					&protobuf.Field{
+						Name:     "Result1",
+						Pos:      1,
+						Repeated: true,
+						Type:     protobuf.NewBasic("string"),
+					},
+				},
+			},
 			&protobuf.Message{
 				Name:   "T_FooResponse",
 				Fields: make([]*protobuf.Field, 1),
@@ -309,10 +621,104 @@ func (s *RPCSuite) TestDeclMethod() {
 	}
 }
 
-const expectedGeneratedFile = `package subpkg
+func (s *RPCSuite) TestDeclPools() {
+	ctx := &context{
+		proto: &protobuf.Package{Messages: []*protobuf.Message{
+			{Name: "FooResponse"},
+			{Name: "Bar"},
+			{Name: "Point_GeneratedMethodRequest"},
+		}},
+		pkg: s.fakePkg(),
+	}
+
+	decls := s.g.declPools(ctx)
+	s.Len(decls, 1)
+	s.Equal([]string{poolPkg}, ctx.imports)
+	s.True(ctx.isPooled("FooResponse"))
+	s.False(ctx.isPooled("Bar"), "the name of the pool is already defined")
+	s.False(ctx.isPooled("Foo"))
+
+	output, err := render(decls[0])
+	s.Nil(err)
+	s.Equal(expectedPools, output)
+
+	s.Nil(s.g.declPools(&context{proto: &protobuf.Package{}, pkg: s.fakePkg()}), "no messages, no pools")
+}
+
+const expectedPools = `var (
+	FooResponsePool				pool.Pool[FooResponse, *FooResponse]
+	Point_GeneratedMethodRequestPool	pool.Pool[Point_GeneratedMethodRequest, *Point_GeneratedMethodRequest]
+)`
+
+const expectedFuncGeneratedWithPool = `func (s *FooServer) DoFoo(ctx xcontext.Context, in *FooRequest) (result *FooResponse, err error) {
+	result = FooResponsePool.GetFor(ctx)
+	result.Result1, result.Result2, result.Result3, err = DoFoo(in.Arg1, in.Arg2, in.Arg3)
+	return
+}`
+
+const expectedFuncNotGeneratedAndNotNullableWithPool = `func (s *FooServer) DoFoo(ctx xcontext.Context, in *Foo) (result *Bar, err error) {
+	result = BarPool.GetFor(ctx)
+	*result = DoFoo(in)
+	return
+}`
+
+func (s *RPCSuite) TestDeclMethodWithPools() {
+	ctx := &context{
+		implName: "FooServer",
+		proto: &protobuf.Package{Messages: []*protobuf.Message{
+			{Name: "FooRequest", Fields: []*protobuf.Field{
+				{Name: "Arg1", Type: protobuf.NewBasic("int64")},
+				{Name: "Arg2", Type: protobuf.NewBasic("string")},
+				{Name: "Arg3", Type: protobuf.NewBasic("string")},
+			}},
+			{Name: "FooResponse", Fields: []*protobuf.Field{
+				{Name: "Result1", Type: protobuf.NewBasic("int64")},
+				{Name: "Result2", Type: protobuf.NewBasic("string")},
+				{Name: "Result3", Type: protobuf.NewBasic("string")},
+			}},
+		}},
+		pkg:   s.fakePkg(),
+		pools: map[string]bool{"FooResponse": true, "Bar": true},
+	}
+
+	output, err := render(s.g.declMethod(ctx, &protobuf.RPC{
+		Name:     "DoFoo",
+		Method:   "DoFoo",
+		HasError: true,
+		Input:    nullable(protobuf.NewGeneratedNamed("", "FooRequest")),
+		Output:   nullable(protobuf.NewGeneratedNamed("", "FooResponse")),
+	}))
+	s.Nil(err)
+	s.Equal(expectedFuncGeneratedWithPool, output)
+
+	output, err = render(s.g.declMethod(ctx, &protobuf.RPC{
+		Name:   "DoFoo",
+		Method: "DoFoo",
+		Input:  nullable(protobuf.NewNamed("", "Foo")),
+		Output: notNullable(protobuf.NewNamed("", "Bar")),
+	}))
+	s.Nil(err)
+	s.Equal(expectedFuncNotGeneratedAndNotNullableWithPool, output)
+
+	output, err = render(s.g.declMethod(ctx, &protobuf.RPC{
+		Name:   "DoFoo",
+		Method: "DoFoo",
+		Input:  nullable(protobuf.NewNamed("", "Foo")),
+		Output: nullable(protobuf.NewNamed("", "Bar")),
+	}))
+	s.Nil(err)
+	s.Equal(expectedFuncNotGenerated, output, "the pointers returned by the funcs are not reused")
+}
+
+const expectedGeneratedFile = `// Code generated by proteus from gitlab.com/ThatTomPerson/proteus/fixtures/subpkg. DO NOT EDIT.
+
+package subpkg
 
 import (
 	xcontext "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"time"
 )
 
 type subpkgServiceServer struct {
@@ -321,26 +727,82 @@ type subpkgServiceServer struct {
 func NewSubpkgServiceServer() *subpkgServiceServer {
 	return &subpkgServiceServer{}
 }
+
 func (s *subpkgServiceServer) Generated(ctx xcontext.Context, in *GeneratedRequest) (result *GeneratedResponse, err error) {
 	result = new(GeneratedResponse)
 	result.Result1, err = Generated(in.Arg1)
 	return
 }
-func (s *subpkgServiceServer) MyContainer_Name(ctx xcontext.Context, in *MyContainer_NameRequest) (result *MyContainer_NameResponse, err error) {
+
+type myContainerServiceServer struct {
+}
+
+func NewMyContainerServiceServer() *myContainerServiceServer {
+	return &myContainerServiceServer{}
+}
+
+func (s *myContainerServiceServer) Name(ctx xcontext.Context, in *MyContainer_NameRequest) (result *MyContainer_NameResponse, err error) {
 	result = new(MyContainer_NameResponse)
 	result.Result1 = s.MyContainer.Name()
 	return
 }
-func (s *subpkgServiceServer) Point_GeneratedMethod(ctx xcontext.Context, in *Point_GeneratedMethodRequest) (result *Point, err error) {
-	result = new(Point)
+
+type pointServiceServer struct {
+}
+
+func NewPointServiceServer() *pointServiceServer {
+	return &pointServiceServer{}
+}
+
+func (s *pointServiceServer) GeneratedMethod(ctx xcontext.Context, in *Point_GeneratedMethodRequest) (result *Point, err error) {
 	result = s.Point.GeneratedMethod(in.Arg1)
 	return
 }
-func (s *subpkgServiceServer) Point_GeneratedMethodOnPointer(ctx xcontext.Context, in *Point_GeneratedMethodOnPointerRequest) (result *Point, err error) {
-	result = new(Point)
+
+func (s *pointServiceServer) GeneratedMethodOnPointer(ctx xcontext.Context, in *Point_GeneratedMethodOnPointerRequest) (result *Point, err error) {
 	result = s.Point.GeneratedMethodOnPointer(in.Arg1)
 	return
 }
+
+type GRPCServerConfig struct {
+	Keepalive            keepalive.ServerParameters
+	KeepalivePolicy      keepalive.EnforcementPolicy
+	MaxConcurrentStreams uint32
+	MaxRecvMsgSize       int
+	MaxSendMsgSize       int
+}
+
+func DefaultGRPCServerConfig() GRPCServerConfig {
+	return GRPCServerConfig{
+		Keepalive: keepalive.ServerParameters{
+			MaxConnectionIdle: 15 * time.Minute,
+			Time:              2 * time.Minute,
+			Timeout:           20 * time.Second,
+		},
+		KeepalivePolicy: keepalive.EnforcementPolicy{
+			MinTime:             30 * time.Second,
+			PermitWithoutStream: true,
+		},
+		MaxConcurrentStreams: 1000,
+		MaxRecvMsgSize:       4 << 20,
+		MaxSendMsgSize:       4 << 20,
+	}
+}
+
+func NewGRPCServer(config GRPCServerConfig, subpkgService *subpkgServiceServer, myContainerService *myContainerServiceServer, pointService *pointServiceServer, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.KeepaliveParams(config.Keepalive),
+		grpc.KeepaliveEnforcementPolicy(config.KeepalivePolicy),
+		grpc.MaxConcurrentStreams(config.MaxConcurrentStreams),
+		grpc.MaxRecvMsgSize(config.MaxRecvMsgSize),
+		grpc.MaxSendMsgSize(config.MaxSendMsgSize),
+	}, opts...)
+	s := grpc.NewServer(opts...)
+	RegisterSubpkgServiceServer(s, subpkgService)
+	RegisterMyContainerServiceServer(s, myContainerService)
+	RegisterPointServiceServer(s, pointService)
+	return s
+}
 `
 
 func (s *RPCSuite) TestGenerate() {
@@ -364,26 +826,399 @@ func (s *RPCSuite) TestGenerate() {
 	s.Nil(os.Remove(projectPath("fixtures/subpkg/server.proteus.go")))
 }
 
+func (s *RPCSuite) TestDeclServer() {
+	ctx := &context{pkg: s.fakePkg()}
+	decls := s.g.declServer(ctx, []*protobuf.Service{{Name: "FooService"}, {Name: "BarService"}})
+	s.Len(decls, 2, "the config type is already defined")
+	s.Equal([]string{"time", "google.golang.org/grpc/keepalive", "google.golang.org/grpc"}, ctx.imports)
+
+	output, err := render(decls[1])
+	s.Nil(err)
+	s.Contains(output, "func NewGRPCServer(config GRPCServerConfig, fooService *fooServiceServer, barService *barServiceServer, opts ...grpc.ServerOption) *grpc.Server {\n")
+	s.Contains(output, "\tRegisterFooServiceServer(s, fooService)\n\tRegisterBarServiceServer(s, barService)\n")
+}
+
+func (s *RPCSuite) TestDeclServerWithTracing() {
+	ctx := &context{pkg: s.fakePkg()}
+	s.g.SetTracing(true)
+	decls := s.g.declServer(ctx, []*protobuf.Service{{Name: "FooService"}})
+	s.Equal([]string{"time", "google.golang.org/grpc/keepalive", "google.golang.org/grpc", tracingPkg}, ctx.imports)
+
+	output, err := render(decls[len(decls)-1])
+	s.Nil(err)
+	s.Contains(output, "\t\tgrpc.MaxSendMsgSize(config.MaxSendMsgSize),\n\t\tgrpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor()),\n\t\tgrpc.ChainStreamInterceptor(tracing.StreamServerInterceptor()),\n\t}, opts...)")
+}
+
+func (s *RPCSuite) TestDeclServerWithErrorStatus() {
+	ctx := &context{pkg: s.fakePkg()}
+	s.g.SetTracing(true)
+	s.g.SetErrorStatus(true)
+	decls := s.g.declServer(ctx, []*protobuf.Service{{Name: "FooService"}})
+	s.Equal([]string{"time", "google.golang.org/grpc/keepalive", "google.golang.org/grpc", tracingPkg, errStatusPkg}, ctx.imports)
+
+	output, err := render(decls[len(decls)-1])
+	s.Nil(err)
+	s.Contains(output, "\t\tgrpc.ChainStreamInterceptor(tracing.StreamServerInterceptor()),\n\t\tgrpc.ChainUnaryInterceptor(errstatus.UnaryServerInterceptor()),\n\t\tgrpc.ChainStreamInterceptor(errstatus.StreamServerInterceptor()),\n\t}, opts...)")
+}
+
+func (s *RPCSuite) TestDeclServerWithPools() {
+	ctx := &context{pkg: s.fakePkg()}
+	s.g.SetPools(true)
+	decls := s.g.declServer(ctx, []*protobuf.Service{{Name: "FooService"}})
+	s.Equal([]string{"time", "google.golang.org/grpc/keepalive", "google.golang.org/grpc", poolPkg}, ctx.imports)
+
+	output, err := render(decls[len(decls)-1])
+	s.Nil(err)
+	s.Contains(output, "\t\tgrpc.MaxSendMsgSize(config.MaxSendMsgSize),\n\t\tgrpc.StatsHandler(pool.StatsHandler()),\n\t}, opts...)")
+}
+
+func (s *RPCSuite) TestDeclServerWithAPIv2() {
+	ctx := &context{pkg: s.fakePkg()}
+	s.g.SetAPI(PackageAPI{API: APIv2, Path: "foo/pb"})
+	decls := s.g.declServer(ctx, []*protobuf.Service{{Name: "FooService"}})
+	s.Equal("pbv2", ctx.namedImports["foo/pb"])
+
+	output, err := render(decls[len(decls)-1])
+	s.Nil(err)
+	s.Contains(output, "\tpbv2.RegisterFooServiceServer(s, &fooServiceServerAPIv2{server: fooService})\n")
+}
+
+const expectedClientConn = `func NewGRPCClientConn(target string, config GRPCClientConfig, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	defaults := []grpc.DialOption{
+		grpc.WithResolvers(config.Resolvers...),
+		grpc.WithKeepaliveParams(config.Keepalive),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(config.MaxRecvMsgSize),
+			grpc.MaxCallSendMsgSize(config.MaxSendMsgSize),
+		),
+	}
+	if config.Balancer != "" {
+		defaults = append(defaults, grpc.WithDefaultServiceConfig(fmt.Sprintf(` + "`" + `{"loadBalancingConfig": [{%q: {}}]}` + "`" + `, config.Balancer)))
+	}
+	return grpc.NewClient(target, append(defaults, opts...)...)
+}`
+
+func (s *RPCSuite) TestDeclClient() {
+	ctx := &context{pkg: s.fakePkg()}
+	decls := s.g.declClient(ctx, nil)
+	s.Len(decls, 4)
+	s.Equal([]string{"google.golang.org/grpc/keepalive", "google.golang.org/grpc/resolver", "time", "fmt", "google.golang.org/grpc", connPoolPkg}, ctx.imports)
+
+	output, err := render(decls[0])
+	s.Nil(err)
+	s.Equal("type GRPCClientConfig struct {\n\tBalancer\tstring\n\tResolvers\t[]resolver.Builder\n\tKeepalive\tkeepalive.ClientParameters\n\tMaxRecvMsgSize\tint\n\tMaxSendMsgSize\tint\n}", output)
+
+	output, err = render(decls[1])
+	s.Nil(err)
+	s.Contains(output, "\t\tBalancer: \"round_robin\",\n")
+
+	output, err = render(decls[2])
+	s.Nil(err)
+	s.Equal(expectedClientConn, output)
+
+	output, err = render(decls[3])
+	s.Nil(err)
+	s.Equal(expectedClientPool, output)
+}
+
+const expectedClientPool = `func NewGRPCClientPool(target string, size int, config GRPCClientConfig, opts ...grpc.DialOption) (*connpool.Pool, error) {
+	return connpool.Dial(size, func() (*grpc.ClientConn, error) {
+		return NewGRPCClientConn(target, config, opts...)
+	})
+}`
+
+const expectedServiceClientPool = `type fooServiceClientPool struct {
+	pool *connpool.Pool
+}
+
+func NewFooServiceClientPool(pool *connpool.Pool) FooServiceClient {
+	return &fooServiceClientPool{pool: pool}
+}
+
+func (c *fooServiceClientPool) DoFoo(ctx xcontext.Context, in *Foo, opts ...grpc.CallOption) (result *Bar, err error) {
+	return NewFooServiceClient(c.pool.Next()).DoFoo(ctx, in, opts...)
+}
+
+func (c *fooServiceClientPool) GetFoo(ctx xcontext.Context, in *FooRequest, opts ...grpc.CallOption) (result *FooResponse, err error) {
+	return NewFooServiceClient(c.pool.Next()).GetFoo(ctx, in, opts...)
+}
+
+func (c *fooServiceClientPool) Events(ctx xcontext.Context, in *Foo, opts ...grpc.CallOption) (stream FooService_EventsClient, err error) {
+	return NewFooServiceClient(c.pool.Next()).Events(ctx, in, opts...)
+}`
+
+func (s *RPCSuite) TestDeclServiceClientPool() {
+	ctx := &context{pkg: s.fakePkg(), proto: &protobuf.Package{Name: "foo"}}
+	svc := &protobuf.Service{Name: "FooService", RPCs: []*protobuf.RPC{
+		{
+			Name:   "DoFoo",
+			Method: "DoFoo",
+			Input:  nullable(protobuf.NewNamed("", "Foo")),
+			Output: nullable(protobuf.NewNamed("", "Bar")),
+		},
+		{
+			Name:   "GetFoo",
+			Method: "GetFoo",
+			Input:  nullable(protobuf.NewGeneratedNamed("", "FooRequest")),
+			Output: nullable(protobuf.NewGeneratedNamed("", "FooResponse")),
+		},
+		{
+			Name:   "Events",
+			Method: "Events",
+			Input:  nullable(protobuf.NewNamed("", "Foo")),
+			Output: notNullable(protobuf.NewNamed("", "Event")),
+			Stream: scanner.SeqStream,
+		},
+	}}
+
+	decls := s.g.declClient(ctx, []*protobuf.Service{svc})
+	s.Len(decls, 9)
+
+	var outputs []string
+	for _, decl := range decls[4:] {
+		output, err := render(decl)
+		s.Nil(err)
+		outputs = append(outputs, output)
+	}
+	s.Equal(expectedServiceClientPool, strings.Join(outputs, "\n\n"))
+
+	s.g.SetAPI(PackageAPI{API: APIv2, Path: "foo/pb"})
+	s.Len(s.g.declClient(&context{pkg: s.fakePkg(), proto: ctx.proto}, []*protobuf.Service{svc}), 4, "APIv2 clients take the pool")
+}
+
+func (s *RPCSuite) TestDeclClientWithInterceptors() {
+	ctx := &context{pkg: s.fakePkg()}
+	s.g.SetTracing(true)
+	s.g.SetErrorStatus(true)
+	decls := s.g.declClient(ctx, nil)
+	s.Contains(ctx.imports, tracingPkg)
+	s.Contains(ctx.imports, errStatusPkg)
+
+	output, err := render(decls[2])
+	s.Nil(err)
+	s.Contains(output, "\t\t),\n\t\tgrpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor()),\n\t\tgrpc.WithChainStreamInterceptor(tracing.StreamClientInterceptor()),\n\t\tgrpc.WithChainUnaryInterceptor(errstatus.UnaryClientInterceptor()),\n\t\tgrpc.WithChainStreamInterceptor(errstatus.StreamClientInterceptor()),\n\t}\n")
+}
+
+func (s *RPCSuite) TestDeclClientWithHedging() {
+	ctx := &context{pkg: s.fakePkg(), proto: &protobuf.Package{Name: "foo"}}
+	s.g.SetErrorStatus(true)
+	rpc := func(name string, idempotent bool) *protobuf.RPC {
+		return &protobuf.RPC{
+			Name:       name,
+			Input:      nullable(protobuf.NewGeneratedNamed("", name+"Request")),
+			Output:     nullable(protobuf.NewGeneratedNamed("", name+"Response")),
+			Idempotent: idempotent,
+		}
+	}
+	watch := rpc("WatchFoos", true)
+	watch.Stream = scanner.ChanStream
+	decls := s.g.declClient(ctx, []*protobuf.Service{
+		{Name: "FooService", RPCs: []*protobuf.RPC{
+			rpc("GetFoo", true),
+			rpc("CreateFoo", false),
+			rpc("ListFoos", true),
+			watch,
+		}},
+	})
+	s.Contains(ctx.imports, hedgingPkg)
+
+	output, err := render(decls[0])
+	s.Nil(err)
+	s.Contains(output, "\tMaxSendMsgSize\tint\n\tHedging\t\thedging.Policy\n}")
+
+	output, err = render(decls[2])
+	s.Nil(err)
+	s.Contains(output, "\t\tgrpc.WithChainStreamInterceptor(errstatus.StreamClientInterceptor()),\n\t\tgrpc.WithChainUnaryInterceptor(hedging.UnaryClientInterceptor(\n\t\t\tconfig.Hedging,\n\t\t\t\"/foo.FooService/GetFoo\",\n\t\t\t\"/foo.FooService/ListFoos\",\n\t\t)),\n\t}\n")
+}
+
+const expectedLoggingInterceptor = `func NewGRPCLoggingInterceptor(sampler logging.Sampler) grpc.UnaryServerInterceptor {
+	return logging.UnaryServerInterceptor(sampler, map[string]logging.Redaction{
+		"/foo.FooService/Login": {
+			Request:  []string{"password", "user.tokens.*.secret"},
+			Response: []string{"password", "user.tokens.*.secret"},
+		},
+		"/foo.FooService/GetUser": {
+			Request: []string{"tokens.*.secret"},
+		},
+	})
+}`
+
+func (s *RPCSuite) TestDeclLoggingInterceptor() {
+	sensitive := protobuf.Options{"(proteus.sensitive)": protobuf.NewLiteralValue("true")}
+	ctx := &context{
+		pkg: s.fakePkg(),
+		proto: &protobuf.Package{
+			Name: "foo",
+			Messages: []*protobuf.Message{
+				{Name: "LoginRequest", Fields: []*protobuf.Field{
+					{Name: "name", Type: protobuf.NewBasic("string")},
+					{Name: "pass_word", Type: protobuf.NewBasic("string"), Options: protobuf.Options{
+						"(proteus.sensitive)": protobuf.NewLiteralValue("true"),
+						"json_name":           protobuf.NewStringValue("password"),
+					}},
+					{Name: "user", Type: protobuf.NewNamed("", "User")},
+					{Name: "group", Type: protobuf.NewNamed("bar", "Group")},
+				}},
+				{Name: "User", Fields: []*protobuf.Field{
+					{Name: "tokens", Type: protobuf.NewMap(protobuf.NewBasic("string"), protobuf.NewNamed("", "Token"))},
+					{Name: "parent", Type: protobuf.NewNamed("", "User")},
+				}},
+				{Name: "Token", Fields: []*protobuf.Field{
+					{Name: "secret", Type: protobuf.NewBasic("string"), Options: sensitive},
+				}},
+			},
+		},
+	}
+	decls := s.g.declLoggingInterceptor(ctx, []*protobuf.Service{{
+		Name: "FooService",
+		RPCs: []*protobuf.RPC{
+			{Name: "Login", Input: protobuf.NewGeneratedNamed("", "LoginRequest"), Output: protobuf.NewGeneratedNamed("", "LoginRequest")},
+			{Name: "GetUser", Input: protobuf.NewNamed("", "User"), Output: protobuf.NewNamed("bar", "Group")},
+		},
+	}})
+	s.Len(decls, 1)
+	s.Equal([]string{"google.golang.org/grpc", loggingPkg}, ctx.imports)
+
+	output, err := render(decls[0])
+	s.Nil(err)
+	s.Equal(expectedLoggingInterceptor, output)
+}
+
+const expectedAPIv2Method = `func (s *fooServiceServerAPIv2) DoFoo(ctx xcontext.Context, in *pbv2.Foo) (*pbv2.Bar, error) {
+	req := new(Foo)
+	if err := fromAPIv2(in, req); err != nil {
+		return nil, err
+	}
+	res, err := s.server.DoFoo(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	out := new(pbv2.Bar)
+	if err := toAPIv2(res, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}`
+
+func (s *RPCSuite) TestDeclAPIv2Service() {
+	ctx := &context{pkg: s.fakePkg()}
+	s.g.SetAPI(PackageAPI{API: APIv2, Path: "foo/pb"})
+	decls := s.g.declAPIv2Service(ctx, &protobuf.Service{
+		Name: "FooService",
+		RPCs: []*protobuf.RPC{
+			{
+				Name:   "DoFoo",
+				Method: "DoFoo",
+				Input:  nullable(protobuf.NewNamed("", "Foo")),
+				Output: nullable(protobuf.NewNamed("", "Bar")),
+			},
+			{
+				Name:   "DoOther",
+				Method: "DoOther",
+				Input:  nullable(protobuf.NewNamed("other", "Foo")),
+				Output: nullable(protobuf.NewNamed("", "Bar")),
+			},
+			{
+				Name:   "Events",
+				Method: "Events",
+				Input:  nullable(protobuf.NewNamed("", "Foo")),
+				Output: notNullable(protobuf.NewNamed("", "Event")),
+				Stream: scanner.SeqStream,
+			},
+		},
+	})
+	s.Len(decls, 2, "RPCs with messages of other packages and server-streaming RPCs are skipped")
+
+	output, err := render(decls[0])
+	s.Nil(err)
+	s.Equal("type fooServiceServerAPIv2 struct {\n\tpbv2.UnimplementedFooServiceServer\n\tserver\t*fooServiceServer\n}", output)
+
+	output, err = render(decls[1])
+	s.Nil(err)
+	s.Equal(expectedAPIv2Method, output)
+
+	decls = s.g.declAPIv2Conversions(ctx)
+	s.Len(decls, 2)
+	s.Equal("protov2", ctx.namedImports["google.golang.org/protobuf/proto"])
+	s.Contains(ctx.imports, "github.com/gogo/protobuf/proto")
+
+	output, err = render(decls[0])
+	s.Nil(err)
+	s.Equal("func fromAPIv2(in protov2.Message, out proto.Message) error {\n\tdata, err := protov2.Marshal(in)\n\tif err != nil {\n\t\treturn err\n\t}\n\treturn proto.Unmarshal(data, out)\n}", output)
+}
+
+func TestAPIOf(t *testing.T) {
+	cases := []struct {
+		comment  string
+		api      API
+		expected PackageAPI
+	}{
+		{"", GoGo, PackageAPI{API: GoGo}},
+		{"", APIv2, PackageAPI{API: APIv2, Path: "acme/users/pb"}},
+		{"apiv2", GoGo, PackageAPI{API: APIv2, Path: "acme/users/pb"}},
+		{"apiv2 acme/userspb", GoGo, PackageAPI{API: APIv2, Path: "acme/userspb"}},
+		{"gogo", APIv2, PackageAPI{API: GoGo}},
+		{"grpc", GoGo, PackageAPI{API: GoGo}},
+		{"gogo acme/userspb", APIv2, PackageAPI{API: APIv2, Path: "acme/users/pb"}},
+	}
+
+	for _, c := range cases {
+		p := &scanner.Package{Path: "acme/users", ProtobufAPI: c.comment}
+		require.Equal(t, c.expected, APIOf(p, c.api), c.comment)
+	}
+}
+
+func TestParseAPI(t *testing.T) {
+	api, err := ParseAPI("apiv2")
+	require.NoError(t, err)
+	require.Equal(t, APIv2, api)
+
+	_, err = ParseAPI("v2")
+	require.Error(t, err)
+}
+
 func TestServiceImplName(t *testing.T) {
-	require.Equal(t, "fooServiceServer", serviceImplName(&protobuf.Package{
-		Name: "foo",
-	}))
+	require.Equal(t, "fooServiceServer", serviceImplName("FooService"))
+}
+
+func TestServerParamName(t *testing.T) {
+	require.Equal(t, "fooService", serverParamName("FooService"))
 }
 
 func TestConstructorName(t *testing.T) {
-	require.Equal(t, "NewFooServiceServer", constructorName(&protobuf.Package{
-		Name: "foo",
-	}))
+	require.Equal(t, "NewFooServiceServer", constructorName("FooService"))
+}
+
+func TestIsNotZero(t *testing.T) {
+	cases := []struct {
+		typ      types.Type
+		expected string
+	}{
+		{types.Typ[types.Int], "in.Foo != 0"},
+		{types.Typ[types.Float64], "in.Foo != 0"},
+		{types.Typ[types.String], `in.Foo != ""`},
+		{types.Typ[types.Bool], "in.Foo"},
+		{types.NewNamed(types.NewTypeName(0, nil, "Status", nil), types.Typ[types.Int32], nil), "in.Foo != 0"},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		require.Nil(t, printer.Fprint(&buf, token.NewFileSet(), isNotZero("in.Foo", c.typ)))
+		require.Equal(t, c.expected, buf.String(), c.typ.String())
+	}
 }
 
 const testPkg = `package fake
 
 import "go/ast"
 import "context"
+import "iter"
 
 type Foo struct{}
 type Bar struct {}
 
+var BarPool struct{}
+
 func DoFoo(in *Foo) *Bar {
 	return nil
 }
@@ -396,11 +1231,58 @@ func MoreFoo(a int) *ast.BlockStmt {
 	return nil
 }
 
+func Tags(ctx context.Context, ids map[int64]struct{}, name string) (map[string]struct{}, error) {
+	return nil, nil
+}
+
+func Flags(flags map[string]bool) map[string]bool {
+	return nil
+}
+
+type ListOption func(*listOptions)
+
+type listOptions struct {
+	limit int
+	tags  []string
+}
+
+func WithLimit(n int) ListOption {
+	return func(o *listOptions) { o.limit = n }
+}
+
+func WithTags(tags []string) ListOption {
+	return func(o *listOptions) { o.tags = tags }
+}
+
+func List(query string, opts ...ListOption) []string {
+	return nil
+}
+
+type Page[T any] struct {
+	Items []T
+}
+
+func Rename(p Page[Foo]) *Page[Foo] {
+	return nil
+}
+
 type T struct{}
 
 func (*T) Foo(s *ast.BlockStmt) int {
 	return 0
 }
+
+type Event struct{}
+
+func Watch(ctx context.Context, n int) (<-chan *Event, error) {
+	return nil, nil
+}
+
+func Events(e *Foo) iter.Seq[Event] {
+	return nil
+}
+
+type GRPCServerConfig struct{}
 `
 
 func (s *RPCSuite) fakePkg() *types.Package {
@@ -435,6 +1317,24 @@ func notNullable(t protobuf.Type) protobuf.Type {
 	return t
 }
 
+func inline(t protobuf.Type, nullable bool) protobuf.Type {
+	src := scanner.NewNamed("", typeName(t)).(*scanner.Named)
+	src.Inline = true
+	src.SetNullable(nullable)
+	t.SetSource(src)
+	return t
+}
+
+func set(t protobuf.Type) protobuf.Type {
+	t.SetSource(scanner.NewSet(scanner.NewBasic(t.(*protobuf.Basic).Name)))
+	return t
+}
+
+func boolSet(t protobuf.Type) protobuf.Type {
+	t.SetSource(scanner.NewBoolSet(scanner.NewBasic(t.(*protobuf.Basic).Name)))
+	return t
+}
+
 func render(decl ast.Decl) (string, error) {
 	var buf bytes.Buffer
 	if err := printer.Fprint(&buf, token.NewFileSet(), decl); err != nil {
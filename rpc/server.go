@@ -0,0 +1,214 @@
+package rpc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+)
+
+const (
+	serverConfigName        = "GRPCServerConfig"
+	defaultServerConfigName = "DefaultGRPCServerConfig"
+	newServerName           = "NewGRPCServer"
+)
+
+// defaultServerConfig is the default config of the server. The defaults of
+// gRPC itself never close idle connections, only let clients ping every five
+// minutes and allow messages of any size to be sent, which usually shows up
+// in production under load balancers and with big messages.
+const defaultServerConfig = `GRPCServerConfig{
+		Keepalive: keepalive.ServerParameters{
+			MaxConnectionIdle: 15 * time.Minute,
+			Time:              2 * time.Minute,
+			Timeout:           20 * time.Second,
+		},
+		KeepalivePolicy: keepalive.EnforcementPolicy{
+			MinTime:             30 * time.Second,
+			PermitWithoutStream: true,
+		},
+		MaxConcurrentStreams: 1000,
+		MaxRecvMsgSize:       4 << 20,
+		MaxSendMsgSize:       4 << 20,
+	}`
+
+// serverOptions are the options of the server given by its config.
+const serverOptions = `[]grpc.ServerOption{
+		grpc.KeepaliveParams(config.Keepalive),
+		grpc.KeepaliveEnforcementPolicy(config.KeepalivePolicy),
+		grpc.MaxConcurrentStreams(config.MaxConcurrentStreams),
+		grpc.MaxRecvMsgSize(config.MaxRecvMsgSize),
+		grpc.MaxSendMsgSize(config.MaxSendMsgSize),%s
+	}`
+
+// tracingPkg is the package with the interceptors that propagate the trace
+// context and the request IDs of the calls.
+const tracingPkg = "gitlab.com/ThatTomPerson/proteus/tracing"
+
+// tracingServerOptions are the options added to the server to propagate the
+// IDs of the calls. They are chained, so the interceptors given in the
+// options of the constructor are still used.
+const tracingServerOptions = `
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor()),`
+
+// errStatusPkg is the package with the interceptors that send the errors of
+// the calls with the status and details of their domain errors.
+const errStatusPkg = "gitlab.com/ThatTomPerson/proteus/errstatus"
+
+// errStatusServerOptions are the options added to the server to send the
+// errors of the calls with their status. They are chained after the tracing
+// ones, so the other interceptors get the errors with their status as well.
+const errStatusServerOptions = `
+		grpc.ChainUnaryInterceptor(errstatus.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(errstatus.StreamServerInterceptor()),`
+
+// declServer returns the declarations of the scaffold of the gRPC server of
+// the package, that is, the config type, the func returning its defaults and
+// the constructor of a server with all the services registered. The ones
+// already defined in the package are not generated.
+func (g *Generator) declServer(ctx *context, services []*protobuf.Service) []ast.Decl {
+	var decls []ast.Decl
+	if !ctx.isNameDefined(serverConfigName) {
+		ctx.addImport("google.golang.org/grpc/keepalive")
+		decls = append(decls, g.declServerConfig())
+	}
+
+	if !ctx.isNameDefined(defaultServerConfigName) {
+		ctx.addImport("time")
+		ctx.addImport("google.golang.org/grpc/keepalive")
+		decls = append(decls, g.declDefaultServerConfig())
+	}
+
+	if !ctx.isNameDefined(newServerName) {
+		ctx.addImport("google.golang.org/grpc")
+		if g.tracing {
+			ctx.addImport(tracingPkg)
+		}
+		if g.errStatus {
+			ctx.addImport(errStatusPkg)
+		}
+		if g.pools {
+			ctx.addImport(poolPkg)
+		}
+		if g.api.API == APIv2 {
+			ctx.addNamedImport(apiv2Import, g.api.Path)
+		}
+		decls = append(decls, g.declNewServer(services))
+	}
+	return decls
+}
+
+func (g *Generator) declServerConfig() ast.Decl {
+	return &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{
+				Name: ast.NewIdent(serverConfigName),
+				Type: &ast.StructType{
+					Fields: fields(
+						field("Keepalive", ast.NewIdent("keepalive.ServerParameters")),
+						field("KeepalivePolicy", ast.NewIdent("keepalive.EnforcementPolicy")),
+						field("MaxConcurrentStreams", ast.NewIdent("uint32")),
+						field("MaxRecvMsgSize", ast.NewIdent("int")),
+						field("MaxSendMsgSize", ast.NewIdent("int")),
+					),
+				},
+			},
+		},
+	}
+}
+
+func (g *Generator) declDefaultServerConfig() ast.Decl {
+	return &ast.FuncDecl{
+		Name: ast.NewIdent(defaultServerConfigName),
+		Type: &ast.FuncType{
+			Params:  fields(),
+			Results: fields(&ast.Field{Type: ast.NewIdent(serverConfigName)}),
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.ReturnStmt{
+					Results: []ast.Expr{ast.NewIdent(defaultServerConfig)},
+				},
+			},
+		},
+	}
+}
+
+// declNewServer declares the constructor of the gRPC server, which applies
+// the given config and then the given server options, so they can override
+// it, and registers the given server of every service, as only their
+// constructors know what they need to be created. With tracing, the
+// interceptors of the tracing package are also added, with error statuses,
+// the ones of the errstatus package, and with pools, the stats handler of
+// the pool package. With the APIv2 protobuf API, the servers are registered
+// with the APIv2 code, wrapped in the types converting their messages.
+func (g *Generator) declNewServer(services []*protobuf.Service) ast.Decl {
+	var interceptors string
+	if g.tracing {
+		interceptors += tracingServerOptions
+	}
+	if g.errStatus {
+		interceptors += errStatusServerOptions
+	}
+	if g.pools {
+		interceptors += poolServerOptions
+	}
+
+	stmts := []ast.Stmt{
+		&ast.AssignStmt{
+			Tok: token.ASSIGN,
+			Lhs: []ast.Expr{ast.NewIdent("opts")},
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun: ast.NewIdent("append"),
+					Args: []ast.Expr{
+						ast.NewIdent(fmt.Sprintf(serverOptions, interceptors)),
+						ast.NewIdent("opts"),
+					},
+					Ellipsis: token.Pos(1),
+				},
+			},
+		},
+		&ast.AssignStmt{
+			Tok: token.DEFINE,
+			Lhs: []ast.Expr{ast.NewIdent("s")},
+			Rhs: []ast.Expr{ast.NewIdent("grpc.NewServer(opts...)")},
+		},
+	}
+
+	params := []*ast.Field{field("config", ast.NewIdent(serverConfigName))}
+	for _, svc := range services {
+		register := "Register" + svc.Name + "Server"
+		server := serverParamName(svc.Name)
+		params = append(params, field(server, ptr(ast.NewIdent(serviceImplName(svc.Name)))))
+		if g.api.API == APIv2 {
+			register = apiv2Import + "." + register
+			server = fmt.Sprintf("&%s{server: %s}", apiv2ImplName(svc.Name), server)
+		}
+
+		stmts = append(stmts, &ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun: ast.NewIdent(register),
+				Args: []ast.Expr{
+					ast.NewIdent("s"),
+					ast.NewIdent(server),
+				},
+			},
+		})
+	}
+
+	stmts = append(stmts, &ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("s")}})
+	params = append(params, field("opts", ast.NewIdent("...grpc.ServerOption")))
+
+	return &ast.FuncDecl{
+		Name: ast.NewIdent(newServerName),
+		Type: &ast.FuncType{
+			Params:  fields(params...),
+			Results: fields(&ast.Field{Type: ptr(ast.NewIdent("grpc.Server"))}),
+		},
+		Body: &ast.BlockStmt{List: stmts},
+	}
+}
@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/gogo/protobuf/protoc-gen-gogo/generator"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// streamingPkg is the package sending the values of the streams returned by
+// the funcs through their buffers.
+const streamingPkg = "gitlab.com/ThatTomPerson/proteus/streaming"
+
+// bufferName returns the name of the variable holding the buffer of the
+// values of the stream of the given RPC.
+func bufferName(ctx *context, rpc *protobuf.RPC) string {
+	return fmt.Sprintf("%s%sBuffer", ctx.implName, rpc.Name)
+}
+
+// streamTypeName returns the name of the type of the stream of the given
+// server-streaming RPC of the given service generated by gogo for the server
+// or the client, e.g. FooService_WatchServer.
+func streamTypeName(service string, rpc *protobuf.RPC, side string) string {
+	return fmt.Sprintf("%s_%s%s", generator.CamelCase(service), generator.CamelCase(rpc.Name), side)
+}
+
+// declBuffer declares the buffer of the values of the stream of an RPC,
+// with the size and policy given in its func, so it can be changed before
+// serving it.
+func (g *Generator) declBuffer(ctx *context, rpc *protobuf.RPC) ast.Decl {
+	policy := "streaming.Block"
+	if rpc.StreamBuffer.Drop {
+		policy = "streaming.Drop"
+	}
+
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{
+			&ast.ValueSpec{
+				Names: []*ast.Ident{ast.NewIdent(bufferName(ctx, rpc))},
+				Values: []ast.Expr{
+					ast.NewIdent(fmt.Sprintf("streaming.Buffer{Size: %d, Policy: %s}", rpc.StreamBuffer.Size, policy)),
+				},
+			},
+		},
+	}
+}
+
+// declStreamMethod declares the method of a server-streaming RPC, which
+// calls the func with the request and sends the values of the channel or
+// iterator it returns to the stream through the buffer of the RPC, until it
+// ends, the client goes away or sending a value fails.
+func (g *Generator) declStreamMethod(ctx *context, rpc *protobuf.RPC) ast.Decl {
+	ctx.addImport(streamingPkg)
+
+	body := []ast.Stmt{
+		&ast.AssignStmt{
+			Tok: token.DEFINE,
+			Lhs: []ast.Expr{ast.NewIdent("ctx")},
+			Rhs: []ast.Expr{ast.NewIdent("stream.Context()")},
+		},
+	}
+	if rpc.MaxConcurrency > 0 {
+		body = append(body, g.genAcquireSemaphore(ctx, rpc))
+	}
+	body = append(body, g.genInputConversions(ctx, rpc)...)
+
+	call := &ast.AssignStmt{
+		Tok: token.DEFINE,
+		Lhs: []ast.Expr{ast.NewIdent("result")},
+		Rhs: []ast.Expr{g.genMethodCall(ctx, rpc)},
+	}
+	body = append(body, call)
+	if rpc.HasError {
+		call.Lhs = append(call.Lhs, ast.NewIdent("err"))
+		body = append(body, &ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: []ast.Stmt{new(ast.ReturnStmt)}},
+		})
+	}
+
+	send := map[scanner.Stream]string{
+		scanner.ChanStream:   "streaming.SendChan",
+		scanner.SeqStream:    "streaming.SendSeq",
+		scanner.SeqErrStream: "streaming.SendSeq2",
+	}[rpc.Stream]
+	body = append(body, &ast.ReturnStmt{
+		Results: []ast.Expr{
+			&ast.CallExpr{
+				Fun: ast.NewIdent(send),
+				Args: []ast.Expr{
+					ast.NewIdent("ctx"),
+					ast.NewIdent(bufferName(ctx, rpc)),
+					ast.NewIdent("result"),
+					g.genStreamSend(ctx, rpc),
+				},
+			},
+		},
+	})
+
+	return &ast.FuncDecl{
+		Recv: fields(field("s", ptr(ast.NewIdent(ctx.implName)))),
+		Name: ast.NewIdent(rpc.Name),
+		Type: &ast.FuncType{
+			Params: fields(
+				field("in", ptr(ast.NewIdent(g.inputTypeName(ctx, rpc)))),
+				field("stream", ast.NewIdent(streamTypeName(ctx.serviceName, rpc, "Server"))),
+			),
+			Results: fields(field("err", ast.NewIdent("error"))),
+		},
+		Body: &ast.BlockStmt{List: body},
+	}
+}
+
+// genStreamSend returns the func sending a value of the stream of the func
+// of the given RPC, which is the Send method of the stream for pointers to
+// messages. Values are sent by their address and the ones of inline structs
+// are converted to the Go type declared for them by the generated code.
+func (g *Generator) genStreamSend(ctx *context, rpc *protobuf.RPC) ast.Expr {
+	elem := ctx.streamElem(rpc)
+	_, pointer := elem.(*types.Pointer)
+	if pointer && !isInline(rpc.Output) {
+		return ast.NewIdent("stream.Send")
+	}
+
+	var v ast.Expr = ast.NewIdent("v")
+	if !pointer {
+		v = &ast.UnaryExpr{Op: token.AND, X: v}
+	}
+	if isInline(rpc.Output) {
+		v = g.genInlineConversion(typeName(rpc.Output), true, v)
+	}
+
+	return &ast.FuncLit{
+		Type: &ast.FuncType{
+			Params:  fields(field("v", ast.NewIdent(ctx.typeString(elem)))),
+			Results: fields(&ast.Field{Type: ast.NewIdent("error")}),
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.ReturnStmt{
+					Results: []ast.Expr{&ast.CallExpr{Fun: ast.NewIdent("stream.Send"), Args: []ast.Expr{v}}},
+				},
+			},
+		},
+	}
+}
+
+// genStreamClientMethodType returns the type of the method of a client of
+// the given service calling the given server-streaming RPC, which returns
+// the stream of the client generated by gogo.
+func (g *Generator) genStreamClientMethodType(ctx *context, service string, rpc *protobuf.RPC) *ast.FuncType {
+	return &ast.FuncType{
+		Params: fields(
+			field("ctx", ast.NewIdent("xcontext.Context")),
+			field("in", ptr(ast.NewIdent(g.inputTypeName(ctx, rpc)))),
+			field("opts", ast.NewIdent("...grpc.CallOption")),
+		),
+		Results: fields(
+			field("stream", ast.NewIdent(streamTypeName(service, rpc, "Client"))),
+			field("err", ast.NewIdent("error")),
+		),
+	}
+}
@@ -0,0 +1,282 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+
+	"gopkg.in/src-d/go-parse-utils.v1"
+)
+
+// Cache keeps the packages scanned by the scanners using it, so a package is
+// only scanned again after it is invalidated, e.g. because its files changed.
+// Scanners get a copy of the packages in the cache, as the packages are
+// modified when they are resolved and transformed. The elements skipped in a
+// package are reported again every time it is taken from the cache, but the
+// rest of its warnings are only reported when it is scanned.
+//
+// A cache created with NewDiskCache also persists the packages in a
+// directory, keyed by a hash of their Go files, the files of the packages
+// they import that are scanned along with them and the options of the
+// scanner, so they are not scanned again in later runs until any of them
+// changes.
+type Cache struct {
+	mut      sync.Mutex
+	packages map[string]*scanned
+	// dir is the directory the packages are persisted in, if any.
+	dir string
+}
+
+// scanned is the result of the scan of a package, as it is kept in a Cache.
+type scanned struct {
+	// Key is the hash the package is persisted with, if it is.
+	Key     string   `json:"key"`
+	Package *Package `json:"package"`
+	// Unsupported are the qualified names of the fields of channel or func
+	// types of the package that were ignored.
+	Unsupported []string `json:"unsupported,omitempty"`
+	// Skipped are the elements of the package skipped during the scan.
+	Skipped report.Problems `json:"skipped,omitempty"`
+	// Imports are the paths of the packages it imports.
+	Imports []string `json:"imports,omitempty"`
+}
+
+// copy returns a copy of the result whose package can be modified.
+func (s *scanned) copy() *scanned {
+	c := *s
+	c.Package = s.Package.Copy()
+	return &c
+}
+
+// NewCache creates a new empty Cache.
+func NewCache() *Cache {
+	return &Cache{packages: make(map[string]*scanned)}
+}
+
+// NewDiskCache creates a new Cache that persists the packages in the given
+// directory, which is created if it does not exist, and takes them from it
+// if they were persisted in previous runs.
+func NewDiskCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating scan cache %q: %s", dir, err)
+	}
+
+	c := NewCache()
+	c.dir = dir
+	return c, nil
+}
+
+// Has reports whether the package with the given path is in the cache.
+func (c *Cache) Has(path string) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	_, ok := c.packages[path]
+	return ok
+}
+
+// Invalidate removes the packages with the given paths from the cache, along
+// with the packages importing them, directly or through other packages in
+// the cache, as they may embed their structs. It returns the given paths and
+// the paths of the packages importing them, sorted. The packages persisted
+// on disk are not removed, as their keys change with their files.
+func (c *Cache) Invalidate(paths ...string) []string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var (
+		removed = make(map[string]bool)
+		queue   = paths
+	)
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if removed[p] {
+			continue
+		}
+
+		removed[p] = true
+		delete(c.packages, p)
+		for path, cached := range c.packages {
+			if containsString(cached.Imports, p) {
+				queue = append(queue, path)
+			}
+		}
+	}
+
+	var result = make([]string, 0, len(removed))
+	for p := range removed {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// get returns a copy of the result of the scan of the package with the given
+// path, if it is in the cache with the given key or persisted with it.
+func (c *Cache) get(path, key string) (*scanned, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if cached, ok := c.packages[path]; ok && cached.Key == key {
+		return cached.copy(), true
+	}
+
+	if c.dir == "" {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(c.file(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var cached scanned
+	if err := json.Unmarshal(data, &cached); err != nil || cached.Key != key {
+		report.Warn("ignoring the invalid scan of package %s persisted in %s", path, c.file(key))
+		return nil, false
+	}
+
+	c.packages[path] = &cached
+	return cached.copy(), true
+}
+
+// add keeps a copy of the result of the scan of the package with the given
+// path, persisting it if the cache has a directory.
+func (c *Cache) add(path string, result *scanned) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	cached := result.copy()
+	c.packages[path] = cached
+	if c.dir == "" {
+		return
+	}
+
+	data, err := json.Marshal(cached)
+	if err == nil {
+		err = ioutil.WriteFile(c.file(cached.Key), data, 0644)
+	}
+
+	if err != nil {
+		report.Warn("unable to persist the scan of package %s: %s", path, err)
+	}
+}
+
+func (c *Cache) file(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// cacheVersion is the version of the format the packages are persisted
+// with, which is part of their keys, so the packages persisted with other
+// versions are not used.
+const cacheVersion = 7
+
+// cacheKeys returns the keys the given packages are kept in the cache with,
+// which are empty if the cache does not persist them. The key of a package
+// is a hash of the version of the format, the path of the package, the
+// options of the scanner, its Go files and the keys of the packages it
+// imports that are scanned too.
+func (s *Scanner) cacheKeys() (map[string]string, error) {
+	var keys = make(map[string]string, len(s.packages))
+	if s.cache == nil || s.cache.dir == "" {
+		return keys, nil
+	}
+
+	var (
+		hashes  = make(map[string][]byte, len(s.packages))
+		imports = make(map[string][]string, len(s.packages))
+	)
+	for _, p := range s.packages {
+		hash, pkgImports, err := hashPackageFiles(p)
+		if err != nil {
+			return nil, fmt.Errorf("error hashing package %q: %s", p, err)
+		}
+		hashes[p] = hash
+		imports[p] = pkgImports
+	}
+
+	var key func(p string) string
+	key = func(p string) string {
+		if k, ok := keys[p]; ok {
+			return k
+		}
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%d\n%s\n%s\n%t\n%t\n", cacheVersion, p, s.fieldPolicy, s.boolSets, s.inlineTypes)
+		h.Write(hashes[p])
+		for _, i := range imports[p] {
+			// Go packages can not import each other, so this always ends.
+			if _, ok := hashes[i]; ok {
+				fmt.Fprintf(h, "%s %s\n", i, key(i))
+			}
+		}
+
+		keys[p] = hex.EncodeToString(h.Sum(nil))
+		return keys[p]
+	}
+
+	for _, p := range s.packages {
+		key(p)
+	}
+	return keys, nil
+}
+
+// hashPackageFiles returns the hash of the names and contents of the Go
+// files of the package at the given path that are not tests or generated by
+// protoc or proteus, along with the paths of the packages they import,
+// sorted.
+func hashPackageFiles(path string) ([]byte, []string, error) {
+	dir, err := parseutil.DefaultGoPath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		h       = sha256.New()
+		fset    = token.NewFileSet()
+		imports []string
+	)
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") ||
+			strings.HasSuffix(name, ".pb.go") || strings.HasSuffix(name, ".proteus.go") {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(h, "%s %d\n", name, len(data))
+		h.Write(data)
+
+		f, err := parser.ParseFile(fset, name, data, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+
+		for _, i := range f.Imports {
+			if p, err := strconv.Unquote(i.Path.Value); err == nil && !containsString(imports, p) {
+				imports = append(imports, p)
+			}
+		}
+	}
+
+	sort.Strings(imports)
+	return h.Sum(nil), imports, nil
+}
@@ -0,0 +1,137 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const cacheBaseFile = `package base
+
+//proteus:generate
+type Model struct {
+	ID int
+}
+`
+
+const cacheUserFile = `package user
+
+import "gitlab.com/ThatTomPerson/proteus/fixtures/cache/base"
+
+//proteus:generate
+type User struct {
+	base.Model
+	Name string
+}
+`
+
+func TestScannerCache(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/cache/base"), 0777))
+	require.Nil(os.MkdirAll(absPath("fixtures/cache/user"), 0777))
+	defer os.RemoveAll(absPath("fixtures/cache"))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/cache/base/base.go"), []byte(cacheBaseFile), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/cache/user/user.go"), []byte(cacheUserFile), 0777))
+
+	base, user := projectPkg("fixtures/cache/base"), projectPkg("fixtures/cache/user")
+	cache := NewCache()
+	scan := func() []*Package {
+		scanner, err := New(base, user)
+		require.Nil(err)
+		scanner.SetCache(cache)
+
+		pkgs, err := scanner.Scan()
+		require.Nil(err)
+		return pkgs
+	}
+
+	fieldNames := func(s *Struct) []string {
+		var names []string
+		for _, f := range s.Fields {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	pkgs := scan()
+	require.True(cache.Has(base))
+	require.True(cache.Has(user))
+	require.Equal([]string{"ID", "Name"}, fieldNames(pkgs[1].Structs[0]))
+
+	// the packages returned are copies of the ones in the cache
+	pkgs[1].Structs[0].Fields = nil
+
+	require.Nil(ioutil.WriteFile(
+		absPath("fixtures/cache/base/base.go"),
+		[]byte(cacheBaseFile[:len(cacheBaseFile)-2]+"\tVersion int\n}\n"),
+		0777,
+	))
+
+	pkgs = scan()
+	require.Equal([]string{"ID", "Name"}, fieldNames(pkgs[1].Structs[0]))
+
+	require.Equal([]string{base, user}, cache.Invalidate(base))
+	require.False(cache.Has(user))
+
+	pkgs = scan()
+	require.Equal([]string{"ID", "Version"}, fieldNames(pkgs[0].Structs[0]))
+	require.Equal([]string{"ID", "Version", "Name"}, fieldNames(pkgs[1].Structs[0]))
+
+	require.Equal([]string{user}, cache.Invalidate(user))
+	require.True(cache.Has(base))
+}
+
+func TestScannerDiskCache(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/cache/base"), 0777))
+	require.Nil(os.MkdirAll(absPath("fixtures/cache/user"), 0777))
+	defer os.RemoveAll(absPath("fixtures/cache"))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/cache/base/base.go"), []byte(cacheBaseFile), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/cache/user/user.go"), []byte(cacheUserFile), 0777))
+
+	dir, err := ioutil.TempDir("", "proteus-scan-cache")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	base, user := projectPkg("fixtures/cache/base"), projectPkg("fixtures/cache/user")
+	scan := func() []*Package {
+		cache, err := NewDiskCache(dir)
+		require.Nil(err)
+
+		scanner, err := New(base, user)
+		require.Nil(err)
+		scanner.SetCache(cache)
+
+		pkgs, err := scanner.Scan()
+		require.Nil(err)
+		return pkgs
+	}
+
+	files := func() int {
+		fs, err := ioutil.ReadDir(dir)
+		require.Nil(err)
+		return len(fs)
+	}
+
+	scanned := scan()
+	require.Equal(2, files())
+
+	// a new cache takes the packages persisted by the previous one
+	require.Equal(scanned, scan())
+	require.Equal(2, files())
+
+	require.Nil(ioutil.WriteFile(
+		absPath("fixtures/cache/base/base.go"),
+		[]byte(cacheBaseFile[:len(cacheBaseFile)-2]+"\tVersion int\n}\n"),
+		0777,
+	))
+
+	// the keys of the changed package and the ones importing it change
+	pkgs := scan()
+	require.Equal(4, files())
+	require.Len(pkgs[1].Structs[0].Fields, 3)
+}
@@ -0,0 +1,176 @@
+package scanner
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// ClosedInterface is an interface of a package whose implementations are
+// told apart in type switches or type assertions of the package, so they
+// can be seen as a closed set and the interface can be generated as a oneof
+// of their messages, or an enum if they hold no data.
+type ClosedInterface struct {
+	// Package is the path of the package of the interface.
+	Package string
+	// Name is the name of the interface.
+	Name string
+	// Types are the names of the implementations of the package found in
+	// type switches or type assertions, sorted.
+	Types []string
+	// Missing are the names of the implementations of the package that are
+	// never found in type switches or type assertions, sorted. If there are
+	// any, the set may not be as closed as it seems.
+	Missing []string
+	// Enum reports whether all the types are empty structs, so the
+	// interface can be an enum instead of a oneof.
+	Enum bool
+}
+
+// String returns the proposed mapping of the interface.
+func (c *ClosedInterface) String() string {
+	kind := "a oneof of their messages"
+	if c.Enum {
+		kind = "an enum with a value for each of them"
+	}
+
+	s := fmt.Sprintf("interface %s.%s is only told apart as %v, it could be generated as %s", c.Package, c.Name, c.Types, kind)
+	if len(c.Missing) > 0 {
+		s += fmt.Sprintf(", but %v also implement it", c.Missing)
+	}
+	return s
+}
+
+// FindClosedInterfaces analyses the type switches and type assertions of
+// the scanned packages and returns their exported interfaces with at least
+// two implementations told apart in them. Nothing is generated from the
+// result, it is meant to be reported to help adopting proteus in packages
+// that were not written for it.
+func (s *Scanner) FindClosedInterfaces() ([]*ClosedInterface, error) {
+	var result []*ClosedInterface
+	for _, p := range s.packages {
+		pkg, err := s.importPackage(p)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning package %q: %s", p, err)
+		}
+
+		ctx, err := newContext(p)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning package %q: %s", p, err)
+		}
+
+		result = append(result, findClosedInterfaces(ctx, pkg)...)
+	}
+	return result, nil
+}
+
+func findClosedInterfaces(ctx *context, gopkg *types.Package) []*ClosedInterface {
+	var (
+		scope    = gopkg.Scope()
+		switched = ctx.switchedTypes()
+		result   []*ClosedInterface
+	)
+
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !obj.Exported() {
+			continue
+		}
+
+		iface, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok || iface.NumMethods() == 0 {
+			continue
+		}
+
+		c := &ClosedInterface{
+			Package: removeGoPath(gopkg),
+			Name:    name,
+			Enum:    true,
+		}
+		for _, n := range scope.Names() {
+			impl, ok := scope.Lookup(n).(*types.TypeName)
+			if !ok || isInterface(impl.Type()) || !implements(impl.Type(), iface) {
+				continue
+			}
+
+			if !switched[n] {
+				c.Missing = append(c.Missing, n)
+				continue
+			}
+
+			c.Types = append(c.Types, n)
+			if st, ok := impl.Type().Underlying().(*types.Struct); !ok || st.NumFields() > 0 {
+				c.Enum = false
+			}
+		}
+
+		if len(c.Types) > 1 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// implements reports whether the type or a pointer to it implements the
+// interface.
+func implements(typ types.Type, iface *types.Interface) bool {
+	return types.Implements(typ, iface) || types.Implements(types.NewPointer(typ), iface)
+}
+
+// switchedTypes returns the names of the types of the package found in the
+// cases of the type switches and in the type assertions of all the funcs of
+// the package.
+func (ctx *context) switchedTypes() map[string]bool {
+	var names = make(map[string]bool)
+	add := func(expr ast.Expr) {
+		if name, ok := localTypeName(expr); ok {
+			names[name] = true
+		}
+	}
+
+	var funcs []string
+	for name := range ctx.funcs {
+		funcs = append(funcs, name)
+	}
+	sort.Strings(funcs)
+
+	for _, name := range funcs {
+		fn := ctx.funcs[name]
+		if fn.Body == nil {
+			continue
+		}
+
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.TypeSwitchStmt:
+				for _, stmt := range n.Body.List {
+					for _, expr := range stmt.(*ast.CaseClause).List {
+						add(expr)
+					}
+				}
+			case *ast.TypeAssertExpr:
+				// the type is nil in the assertion of a type switch
+				if n.Type != nil {
+					add(n.Type)
+				}
+			}
+			return true
+		})
+	}
+	return names
+}
+
+// localTypeName returns the name of the type of the package in the given
+// expression, which can be a pointer to it.
+func localTypeName(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return localTypeName(e.X)
+	case *ast.ParenExpr:
+		return localTypeName(e.X)
+	case *ast.Ident:
+		return e.Name, true
+	}
+	return "", false
+}
@@ -3,9 +3,14 @@ package scanner
 import (
 	"fmt"
 	"go/ast"
+	"go/parser"
 	"go/token"
+	"path/filepath"
+	"strconv"
 	"strings"
 
+	"gitlab.com/ThatTomPerson/proteus/report"
+
 	"gopkg.in/src-d/go-parse-utils.v1"
 )
 
@@ -28,26 +33,161 @@ type context struct {
 	// enumValues contains all the values found until a point in time.
 	// It is indexed by qualified type name e.g: time.Time
 	enumValues map[string][]string
+	// enumNumbers holds the value of the enum constants of integer types,
+	// indexed by the const name.
+	enumNumbers map[string]int64
 	// enums with string method
 	enumWithString []string
+	// constDecls holds the declarations of the constants indexed by their
+	// name.
+	constDecls map[string]*constDecl
+	// pkgDocs holds the docs of the package in all of its files.
+	pkgDocs []*ast.CommentGroup
+	// typeFiles holds the name of the source file each type is declared in,
+	// indexed by the type name.
+	typeFiles map[string]string
+	// fieldPolicy is the policy for fields of channel or func types of the
+	// structs that do not have their own.
+	fieldPolicy FieldPolicy
+	// boolSets reports whether maps with bool values are scanned as sets in
+	// the packages that do not tell it in their docs.
+	boolSets bool
+	// unsupportedFields contains the qualified names of all the fields of
+	// channel or func types found that were ignored, e.g: Struct.Field
+	unsupportedFields []string
+	// failedFields contains the qualified names of the fields of channel or
+	// func types found in structs whose field policy is to fail.
+	failedFields []string
+	// setFields contains the qualified names of the fields of set types
+	// found in the structs to generate.
+	setFields []string
+	// skipped are the elements of the package skipped during the scan.
+	skipped report.Problems
+	// funcVars holds the package-level vars as func declarations indexed by
+	// their name, which are only generated with inline types.
+	funcVars map[string]*ast.FuncDecl
+	// inline holds the inline types found, if they are scanned.
+	inline *inlineTypes
+	// fset is the file set the AST of the package was parsed with, which
+	// holds the positions of its declarations.
+	fset *token.FileSet
 }
 
 func newContext(path string) (*context, error) {
-	pkg, err := parseutil.PackageAST(path)
+	pkg, fset, err := parsePackageAST(path)
 	if err != nil {
 		return nil, err
 	}
 
 	types, funcs := findPkgTypesAndFuncs(pkg)
 	return &context{
+		fset:           fset,
 		types:          types,
 		funcs:          funcs,
 		consts:         findObjectsOfType(pkg, ast.Con),
 		enumValues:     make(map[string][]string),
+		enumNumbers:    make(map[string]int64),
 		enumWithString: []string{},
+		constDecls:     findConstDecls(pkg),
+		pkgDocs:        findPackageDocs(pkg),
+		typeFiles:      findTypeFiles(pkg),
+		funcVars:       findFuncVars(pkg),
 	}, nil
 }
 
+// skipAt reports the Go declaration with the given symbol that had to be
+// skipped, as report.SkipAt does, and adds it to the skipped elements of the
+// package, so they can be reported again when it is taken from a Cache.
+func (ctx *context) skipAt(pos token.Position, symbol string, format string, args ...interface{}) {
+	report.SkipAt(pos, symbol, format, args...)
+	ctx.skipped = append(ctx.skipped, report.Problem{Position: pos, Symbol: symbol, Message: fmt.Sprintf(format, args...)})
+}
+
+// parsePackageAST parses the files of the package at the given path, but not
+// the ones of its tests, as parseutil.PackageAST does, returning the file set
+// they were parsed with too.
+func parsePackageAST(path string) (*ast.Package, *token.FileSet, error) {
+	dir, err := parseutil.DefaultGoPath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, nil, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pkg *ast.Package
+	for name, p := range pkgs {
+		if strings.HasSuffix(name, "_test") {
+			continue
+		}
+
+		if pkg != nil {
+			return nil, nil, parseutil.ErrTooManyPackages
+		}
+		pkg = p
+	}
+
+	if pkg == nil {
+		return nil, nil, parseutil.ErrTooManyPackages
+	}
+	return pkg, fset, nil
+}
+
+// position returns the position in the source files of the type, func,
+// method, by its qualified name, or constant with the given name, which is
+// not valid if there is no such declaration.
+func (ctx *context) position(name string) token.Position {
+	if ctx.fset == nil {
+		return token.Position{}
+	}
+
+	if typ, ok := ctx.types[name]; ok {
+		return ctx.fset.Position(typ.Name.Pos())
+	} else if fn, ok := ctx.funcs[name]; ok {
+		return ctx.fset.Position(fn.Name.Pos())
+	} else if v, ok := ctx.consts[name]; ok {
+		return ctx.fset.Position(v.Pos())
+	}
+	return token.Position{}
+}
+
+// fieldPosition returns the position in the source files of the field with
+// the given name of the struct type with the given name, which is not valid
+// if there is no such field.
+func (ctx *context) fieldPosition(typeName, name string) token.Position {
+	f := ctx.findField(typeName, name)
+	if f == nil || ctx.fset == nil {
+		return token.Position{}
+	}
+
+	for _, n := range f.Names {
+		if n.Name == name {
+			return ctx.fset.Position(n.Pos())
+		}
+	}
+	return ctx.fset.Position(f.Type.Pos())
+}
+
+func findTypeFiles(pkg *ast.Package) map[string]string {
+	var files = make(map[string]string)
+	for name, f := range pkg.Files {
+		for _, d := range f.Decls {
+			decl, ok := d.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, s := range decl.Specs {
+				files[s.(*ast.TypeSpec).Name.Name] = filepath.Base(name)
+			}
+		}
+	}
+	return files
+}
+
 func findPkgTypesAndFuncs(pkg *ast.Package) (map[string]*ast.TypeSpec, map[string]*ast.FuncDecl) {
 	f := ast.MergePackageFiles(pkg, 0)
 
@@ -109,6 +249,42 @@ func findObjectsOfType(pkg *ast.Package, kind ast.ObjKind) map[string]*ast.Objec
 	return objects
 }
 
+// constDecl holds the docs of the declaration of a constant.
+type constDecl struct {
+	// doc is the doc of the constant, which is the one of the whole
+	// declaration if it is not declared in a group.
+	doc *ast.CommentGroup
+	// groupDoc is the doc of the group the constant is declared in, if any.
+	groupDoc *ast.CommentGroup
+}
+
+func findConstDecls(pkg *ast.Package) map[string]*constDecl {
+	var decls = make(map[string]*constDecl)
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			decl, ok := d.(*ast.GenDecl)
+			if !ok || decl.Tok != token.CONST {
+				continue
+			}
+
+			for _, s := range decl.Specs {
+				spec := s.(*ast.ValueSpec)
+				cd := &constDecl{doc: spec.Doc}
+				if decl.Lparen.IsValid() {
+					cd.groupDoc = decl.Doc
+				} else {
+					cd.doc = decl.Doc
+				}
+
+				for _, n := range spec.Names {
+					decls[n.Name] = cd
+				}
+			}
+		}
+	}
+	return decls
+}
+
 func (ctx *context) trySetDocs(name string, obj Documentable) {
 	if typ, ok := ctx.types[name]; ok && typ.Doc != nil {
 		obj.SetDocs(typ.Doc)
@@ -121,7 +297,12 @@ func (ctx *context) trySetDocs(name string, obj Documentable) {
 	}
 }
 
-const genComment = `//proteus:generate`
+const (
+	commentPrefix     = `//proteus:`
+	genComment        = `//proteus:generate`
+	serviceComment    = `//proteus:service`
+	deprecatedComment = `//proteus:deprecated`
+)
 
 func (ctx *context) shouldGenerateType(name string) bool {
 	if typ, ok := ctx.types[name]; ok && typ.Doc != nil {
@@ -130,6 +311,45 @@ func (ctx *context) shouldGenerateType(name string) bool {
 	return false
 }
 
+func (ctx *context) shouldGenerateService(name string) bool {
+	if typ, ok := ctx.types[name]; ok {
+		return hasComment(typ.Doc, serviceComment)
+	}
+	return false
+}
+
+// trySetMethodDocs sets the docs of the method with the given name of the
+// interface type with the given name.
+func (ctx *context) trySetMethodDocs(typeName, name string, obj Documentable) {
+	typ, ok := ctx.types[typeName]
+	if !ok {
+		return
+	}
+
+	iface, ok := typ.Type.(*ast.InterfaceType)
+	if !ok {
+		return
+	}
+
+	for _, m := range iface.Methods.List {
+		for _, n := range m.Names {
+			if n.Name == name && m.Doc != nil {
+				obj.SetDocs(m.Doc)
+			}
+		}
+	}
+}
+
+// shouldGenerateConst reports whether the constant with the given name has
+// the generate comment, either in its own docs or in the ones of the group
+// it is declared in.
+func (ctx *context) shouldGenerateConst(name string) bool {
+	if d, ok := ctx.constDecls[name]; ok {
+		return hasGenerateComment(d.doc) || hasGenerateComment(d.groupDoc)
+	}
+	return false
+}
+
 func (ctx *context) shouldGenerateFunc(name string) bool {
 	if fn, ok := ctx.funcs[name]; ok && fn.Doc != nil {
 		return hasGenerateComment(fn.Doc)
@@ -137,9 +357,249 @@ func (ctx *context) shouldGenerateFunc(name string) bool {
 	return false
 }
 
+const skipComment = `//proteus:skip`
+
+// shouldSkipField reports whether the field with the given name of the
+// struct type with the given name has the skip comment.
+func (ctx *context) shouldSkipField(typeName, name string) bool {
+	f := ctx.findField(typeName, name)
+	if f == nil {
+		return false
+	}
+	return hasComment(f.Doc, skipComment) || hasComment(f.Comment, skipComment)
+}
+
+// trySetFieldDocs sets the docs of the field with the given name of the
+// struct type with the given name.
+func (ctx *context) trySetFieldDocs(typeName, name string, obj Documentable) {
+	if f := ctx.findField(typeName, name); f != nil && f.Doc != nil {
+		obj.SetDocs(f.Doc)
+	}
+}
+
+// findField returns the field with the given name of the struct type with
+// the given name, or nil if there is no such field.
+func (ctx *context) findField(typeName, name string) *ast.Field {
+	typ, ok := ctx.types[typeName]
+	if !ok {
+		return nil
+	}
+
+	st, ok := typ.Type.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 && embeddedTypeName(f.Type) == name {
+			return f
+		}
+
+		for _, n := range f.Names {
+			if n.Name == name {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// embeddedTypeName returns the name of the type of an embedded field, which
+// is the name of the field.
+func embeddedTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedTypeName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+const maxConcurrencyComment = `//proteus:max-concurrency`
+
+// maxConcurrency returns the maximum number of concurrent calls of the func
+// with the given name, given with a comment like `//proteus:max-concurrency 32`
+// in the func, or zero if it has none. Invalid limits are ignored with a
+// warning.
+func (ctx *context) maxConcurrency(name string) int {
+	fn, ok := ctx.funcs[name]
+	if !ok {
+		return 0
+	}
+
+	arg, ok := commentArg(fn.Doc, maxConcurrencyComment)
+	if !ok {
+		return 0
+	}
+
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		report.WarnAt(report.CodeInvalidComment, ctx.position(name), name, "func %s has an invalid max-concurrency comment, ignoring it: %q is not a positive number", name, arg)
+		return 0
+	}
+	return n
+}
+
+const streamBufferComment = `//proteus:stream-buffer`
+
+// streamBuffer returns the buffering of the stream of values returned by the
+// func with the given name, given with a comment like
+// `//proteus:stream-buffer 16 drop` in the func, with the size of the buffer
+// and, optionally, whether the values produced while it is full are dropped
+// or the func blocks, which is the default. Invalid buffers are ignored with
+// a warning, as are the ones of funcs that do not return a stream.
+func (ctx *context) streamBuffer(name string, stream Stream) StreamBuffer {
+	fn, ok := ctx.funcs[name]
+	if !ok {
+		return StreamBuffer{}
+	}
+
+	arg, ok := commentArg(fn.Doc, streamBufferComment)
+	if !ok {
+		return StreamBuffer{}
+	}
+
+	if stream == NoStream {
+		report.WarnAt(report.CodeInvalidComment, ctx.position(name), name, "func %s has a stream-buffer comment but it does not return a channel or an iterator, ignoring it", name)
+		return StreamBuffer{}
+	}
+
+	args := strings.Fields(arg)
+	if len(args) == 0 {
+		args = []string{""}
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 || len(args) > 2 || (len(args) == 2 && args[1] != "drop" && args[1] != "block") {
+		report.WarnAt(report.CodeInvalidComment, ctx.position(name), name, "func %s has an invalid stream-buffer comment, ignoring it: %q is not a size optionally followed by drop or block", name, arg)
+		return StreamBuffer{}
+	}
+	return StreamBuffer{Size: n, Drop: len(args) == 2 && args[1] == "drop"}
+}
+
+const idempotentComment = `//proteus:idempotent`
+
+// isIdempotent reports whether the func with the given name has no side
+// effects when it is called more than once with the same arguments, which
+// is given with the `//proteus:idempotent` comment in the func.
+func (ctx *context) isIdempotent(name string) bool {
+	fn, ok := ctx.funcs[name]
+	return ok && hasComment(fn.Doc, idempotentComment)
+}
+
+const (
+	enumNamingComment      = `//proteus:enum-naming`
+	enumUnspecifiedComment = `//proteus:enum-unspecified`
+	enumSemanticsComment   = `//proteus:enum-semantics`
+	fieldNamingComment     = `//proteus:field-naming`
+	fieldPolicyComment     = `//proteus:field-policy`
+	jsonCasingComment      = `//proteus:json-casing`
+	boolSetsComment        = `//proteus:bool-sets`
+	packageComment         = `//proteus:package`
+	protobufAPIComment     = `//proteus:protobuf-api`
+)
+
+// packageOption returns the argument of the given option comment in the docs
+// of the package, such as `//proteus:field-naming json`, or an empty string
+// if the package does not have it.
+func (ctx *context) packageOption(comment string) string {
+	for _, doc := range ctx.pkgDocs {
+		if arg, ok := commentArg(doc, comment); ok {
+			return arg
+		}
+	}
+	return ""
+}
+
+// typeOption returns the argument of the given option comment in the docs of
+// the type with the given name or, if it has none, in the docs of the
+// package. Options of types override the ones of their package, which
+// override the ones given to proteus.
+func (ctx *context) typeOption(name, comment string) string {
+	if typ, ok := ctx.types[name]; ok {
+		if arg, ok := commentArg(typ.Doc, comment); ok {
+			return arg
+		}
+	}
+	return ctx.packageOption(comment)
+}
+
+// fieldPolicyOf returns the policy for the fields of channel or func types of
+// the struct type with the given name, given with a comment like
+// `//proteus:field-policy placeholder` in the type or its package, or the
+// policy of the scanner if they have none. Invalid policies are ignored with
+// a warning.
+func (ctx *context) fieldPolicyOf(name string) FieldPolicy {
+	arg := ctx.typeOption(name, fieldPolicyComment)
+	if arg == "" {
+		return ctx.fieldPolicy
+	}
+
+	policy, err := ParseFieldPolicy(arg)
+	if err != nil {
+		report.WarnAt(report.CodeInvalidComment, ctx.position(name), name, "struct %s has an invalid field policy, ignoring it: %s", name, err)
+		return ctx.fieldPolicy
+	}
+	return policy
+}
+
+// useBoolSets reports whether the maps with bool values of the package are
+// scanned as sets, given with a comment like `//proteus:bool-sets true` in
+// the package, or the choice of the scanner if it has none. Invalid choices
+// are ignored with a warning.
+func (ctx *context) useBoolSets() bool {
+	arg := ctx.packageOption(boolSetsComment)
+	if arg == "" {
+		return ctx.boolSets
+	}
+
+	enabled, err := strconv.ParseBool(arg)
+	if err != nil {
+		report.Warn("package has an invalid bool-sets comment, ignoring it: %q is not a boolean", arg)
+		return ctx.boolSets
+	}
+	return enabled
+}
+
+func findPackageDocs(pkg *ast.Package) []*ast.CommentGroup {
+	var docs []*ast.CommentGroup
+	for _, f := range pkg.Files {
+		if f.Doc != nil {
+			docs = append(docs, f.Doc)
+		}
+	}
+	return docs
+}
+
+// commentArg returns the argument of the given comment in the docs, if the
+// docs have it.
+func commentArg(doc *ast.CommentGroup, comment string) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+
+	for _, l := range doc.List {
+		if strings.HasPrefix(l.Text, comment+" ") {
+			return strings.TrimSpace(strings.TrimPrefix(l.Text, comment)), true
+		}
+	}
+	return "", false
+}
+
 func hasGenerateComment(doc *ast.CommentGroup) bool {
+	return hasComment(doc, genComment)
+}
+
+func hasComment(doc *ast.CommentGroup, comment string) bool {
+	if doc == nil {
+		return false
+	}
+
 	for _, l := range doc.List {
-		if strings.HasPrefix(l.Text, genComment) {
+		if strings.HasPrefix(l.Text, comment) {
 			return true
 		}
 	}
@@ -0,0 +1,197 @@
+package scanner
+
+// Copy returns a deep copy of the package, which can be resolved and
+// transformed without modifying the package. It is the package returned
+// for the packages kept in a Cache.
+func (p *Package) Copy() *Package {
+	c := *p
+	if p.Structs != nil {
+		c.Structs = make([]*Struct, len(p.Structs))
+		for i, s := range p.Structs {
+			c.Structs[i] = s.copy()
+		}
+	}
+
+	if p.Enums != nil {
+		c.Enums = make([]*Enum, len(p.Enums))
+		for i, e := range p.Enums {
+			c.Enums[i] = e.copy()
+		}
+	}
+
+	if p.Funcs != nil {
+		c.Funcs = make([]*Func, len(p.Funcs))
+		for i, f := range p.Funcs {
+			c.Funcs[i] = f.copy()
+		}
+	}
+
+	if p.Interfaces != nil {
+		c.Interfaces = make([]*Interface, len(p.Interfaces))
+		for i, iface := range p.Interfaces {
+			c.Interfaces[i] = iface.copy()
+		}
+	}
+
+	if p.Consts != nil {
+		c.Consts = make([]*Const, len(p.Consts))
+		for i, k := range p.Consts {
+			k := *k
+			k.Docs = k.Docs.copy()
+			c.Consts[i] = &k
+		}
+	}
+
+	if p.Arrays != nil {
+		c.Arrays = make([]*Array, len(p.Arrays))
+		for i, a := range p.Arrays {
+			a := *a
+			c.Arrays[i] = &a
+		}
+	}
+
+	if p.Lazies != nil {
+		c.Lazies = make([]*Lazy, len(p.Lazies))
+		for i, l := range p.Lazies {
+			l := *l
+			l.Message = CopyType(l.Message).(*Named)
+			c.Lazies[i] = &l
+		}
+	}
+
+	if p.Aliases != nil {
+		c.Aliases = make(map[string]Type, len(p.Aliases))
+		for name, typ := range p.Aliases {
+			c.Aliases[name] = CopyType(typ)
+		}
+	}
+	return &c
+}
+
+// CopyType returns a deep copy of the type.
+func CopyType(typ Type) Type {
+	switch t := typ.(type) {
+	case *Basic:
+		c := *t
+		c.BaseType = t.BaseType.copy()
+		return &c
+	case *Named:
+		c := *t
+		c.BaseType = t.BaseType.copy()
+		return &c
+	case *Alias:
+		c := *t
+		c.BaseType = t.BaseType.copy()
+		c.Type = CopyType(t.Type)
+		c.Underlying = CopyType(t.Underlying)
+		return &c
+	case *Map:
+		c := *t
+		c.BaseType = t.BaseType.copy()
+		c.Key = CopyType(t.Key)
+		c.Value = CopyType(t.Value)
+		return &c
+	case *Set:
+		c := *t
+		c.BaseType = t.BaseType.copy()
+		c.Elem = CopyType(t.Elem)
+		return &c
+	}
+	return typ
+}
+
+func copyTypes(types []Type) []Type {
+	if types == nil {
+		return nil
+	}
+
+	var result = make([]Type, len(types))
+	for i, t := range types {
+		result[i] = CopyType(t)
+	}
+	return result
+}
+
+func (t *BaseType) copy() *BaseType {
+	if t == nil {
+		return nil
+	}
+
+	c := *t
+	return &c
+}
+
+func (d Docs) copy() Docs {
+	if d.Doc != nil {
+		d.Doc = append([]string(nil), d.Doc...)
+	}
+	return d
+}
+
+func (s *Struct) copy() *Struct {
+	c := *s
+	c.Docs = s.Docs.copy()
+	if s.Fields != nil {
+		c.Fields = make([]*Field, len(s.Fields))
+		for i, f := range s.Fields {
+			f := *f
+			f.Docs = f.Docs.copy()
+			f.Type = CopyType(f.Type)
+			if f.Validate != nil {
+				f.Validate = append([]string(nil), f.Validate...)
+			}
+			c.Fields[i] = &f
+		}
+	}
+	return &c
+}
+
+func (e *Enum) copy() *Enum {
+	c := *e
+	c.Docs = e.Docs.copy()
+	if e.Values != nil {
+		c.Values = make([]*EnumValue, len(e.Values))
+		for i, v := range e.Values {
+			v := *v
+			v.Docs = v.Docs.copy()
+			c.Values[i] = &v
+		}
+	}
+	return &c
+}
+
+func (fn *Func) copy() *Func {
+	c := *fn
+	c.Docs = fn.Docs.copy()
+	if fn.Receiver != nil {
+		c.Receiver = CopyType(fn.Receiver)
+	}
+	c.Input = copyTypes(fn.Input)
+	c.Output = copyTypes(fn.Output)
+	if fn.HTTP != nil {
+		http := *fn.HTTP
+		c.HTTP = &http
+	}
+
+	if fn.FuncOptions != nil {
+		c.FuncOptions = make([]*FuncOption, len(fn.FuncOptions))
+		for i, o := range fn.FuncOptions {
+			o := *o
+			o.Type = CopyType(o.Type)
+			c.FuncOptions[i] = &o
+		}
+	}
+	return &c
+}
+
+func (i *Interface) copy() *Interface {
+	c := *i
+	c.Docs = i.Docs.copy()
+	if i.Methods != nil {
+		c.Methods = make([]*Func, len(i.Methods))
+		for j, m := range i.Methods {
+			c.Methods[j] = m.copy()
+		}
+	}
+	return &c
+}
@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageCopy(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &Package{
+		Path: "foo",
+		Name: "foo",
+		Structs: []*Struct{
+			{
+				Docs: Docs{Doc: []string{"Foo is a foo."}},
+				Name: "Foo",
+				Fields: []*Field{
+					{Name: "Bar", Type: NewMap(NewBasic("string"), NewNamed("foo", "Bar"))},
+					{Name: "Baz", Type: NewAlias(NewNamed("foo", "Baz"), NewBasic("int")), Validate: []string{"gt=0"}},
+				},
+			},
+		},
+		Enums: []*Enum{
+			{Name: "Kind", Values: []*EnumValue{{Name: "A"}, {Name: "B", Value: 1}}},
+		},
+		Funcs: []*Func{
+			{
+				Name:        "DoFoo",
+				Input:       []Type{NewNamed("foo", "Foo")},
+				Output:      []Type{NewSet(NewBasic("string"))},
+				HTTP:        &HTTPRule{Method: "get", Path: "/foo"},
+				FuncOptions: []*FuncOption{{Name: "WithLimit", Field: "Limit", Type: NewBasic("int")}},
+			},
+		},
+		Interfaces: []*Interface{
+			{Name: "Service", Methods: []*Func{{Name: "Get", Receiver: NewNamed("foo", "Service")}}},
+		},
+		Consts:  []*Const{{Name: "Max", Kind: IntConst, Value: "100"}},
+		Aliases: map[string]Type{"foo.Baz": NewBasic("int")},
+	}
+
+	c := pkg.Copy()
+	require.Equal(pkg, c)
+
+	c.Structs[0].Fields[0].Type.(*Map).Key = nil
+	c.Structs[0].Fields[1].Type.(*Alias).Type.SetRepeated(true)
+	c.Structs[0].Fields[1].Validate[0] = "lt=0"
+	c.Structs[0].Doc[0] = "Foo is not a foo."
+	c.Enums[0].Values[1].Value = 2
+	c.Funcs[0].Input[0].(*Named).Message = true
+	c.Funcs[0].HTTP.Method = "post"
+	c.Funcs[0].FuncOptions[0].Type = nil
+	c.Interfaces[0].Methods[0].Name = "List"
+	c.Consts[0].Value = "200"
+	c.Aliases["foo.Baz"].SetRepeated(true)
+
+	require.Equal(NewBasic("string"), pkg.Structs[0].Fields[0].Type.(*Map).Key)
+	require.False(pkg.Structs[0].Fields[1].Type.(*Alias).Type.IsRepeated())
+	require.Equal("gt=0", pkg.Structs[0].Fields[1].Validate[0])
+	require.Equal("Foo is a foo.", pkg.Structs[0].Doc[0])
+	require.Equal(int64(1), pkg.Enums[0].Values[1].Value)
+	require.False(pkg.Funcs[0].Input[0].(*Named).Message)
+	require.Equal("get", pkg.Funcs[0].HTTP.Method)
+	require.NotNil(pkg.Funcs[0].FuncOptions[0].Type)
+	require.Equal("Get", pkg.Interfaces[0].Methods[0].Name)
+	require.Equal("100", pkg.Consts[0].Value)
+	require.False(pkg.Aliases["foo.Baz"].IsRepeated())
+}
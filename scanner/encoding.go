@@ -0,0 +1,339 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonType is the serializable form of a Type, which is any of the types
+// along with the kind telling which one it is.
+type jsonType struct {
+	Kind       string    `json:"kind"`
+	Base       *BaseType `json:"base,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Message    bool      `json:"message,omitempty"`
+	Inline     bool      `json:"inline,omitempty"`
+	Bool       bool      `json:"bool,omitempty"`
+	Type       *jsonType `json:"type,omitempty"`
+	Underlying *jsonType `json:"underlying,omitempty"`
+	Key        *jsonType `json:"key,omitempty"`
+	Value      *jsonType `json:"value,omitempty"`
+	Elem       *jsonType `json:"elem,omitempty"`
+}
+
+const (
+	basicKind = "basic"
+	namedKind = "named"
+	aliasKind = "alias"
+	mapKind   = "map"
+	setKind   = "set"
+)
+
+// toJSONType returns the serializable form of the type, which is nil if the
+// type is.
+func toJSONType(typ Type) (*jsonType, error) {
+	switch t := typ.(type) {
+	case nil:
+		return nil, nil
+	case *Basic:
+		if t == nil {
+			return nil, nil
+		}
+		return &jsonType{Kind: basicKind, Base: t.BaseType, Name: t.Name}, nil
+	case *Named:
+		if t == nil {
+			return nil, nil
+		}
+		return &jsonType{
+			Kind:    namedKind,
+			Base:    t.BaseType,
+			Path:    t.Path,
+			Name:    t.Name,
+			Message: t.Message,
+			Inline:  t.Inline,
+		}, nil
+	case *Alias:
+		if t == nil {
+			return nil, nil
+		}
+		aliased, err := toJSONType(t.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		underlying, err := toJSONType(t.Underlying)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonType{Kind: aliasKind, Base: t.BaseType, Type: aliased, Underlying: underlying}, nil
+	case *Map:
+		if t == nil {
+			return nil, nil
+		}
+		key, err := toJSONType(t.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := toJSONType(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonType{Kind: mapKind, Base: t.BaseType, Key: key, Value: value}, nil
+	case *Set:
+		if t == nil {
+			return nil, nil
+		}
+		elem, err := toJSONType(t.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonType{Kind: setKind, Base: t.BaseType, Elem: elem, Bool: t.Bool}, nil
+	}
+	return nil, fmt.Errorf("unable to serialize type %T", typ)
+}
+
+// toType returns the type with the given serializable form, which is nil if
+// the form is.
+func (t *jsonType) toType() (Type, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	switch t.Kind {
+	case basicKind:
+		return &Basic{BaseType: t.Base, Name: t.Name}, nil
+	case namedKind:
+		return &Named{
+			BaseType: t.Base,
+			Path:     t.Path,
+			Name:     t.Name,
+			Message:  t.Message,
+			Inline:   t.Inline,
+		}, nil
+	case aliasKind:
+		aliased, err := t.Type.toType()
+		if err != nil {
+			return nil, err
+		}
+
+		underlying, err := t.Underlying.toType()
+		if err != nil {
+			return nil, err
+		}
+		return &Alias{BaseType: t.Base, Type: aliased, Underlying: underlying}, nil
+	case mapKind:
+		key, err := t.Key.toType()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := t.Value.toType()
+		if err != nil {
+			return nil, err
+		}
+		return &Map{BaseType: t.Base, Key: key, Value: value}, nil
+	case setKind:
+		elem, err := t.Elem.toType()
+		if err != nil {
+			return nil, err
+		}
+		return &Set{BaseType: t.Base, Elem: elem, Bool: t.Bool}, nil
+	}
+	return nil, fmt.Errorf("unknown kind of type %q", t.Kind)
+}
+
+func toJSONTypes(types []Type) ([]*jsonType, error) {
+	if types == nil {
+		return nil, nil
+	}
+
+	var result = make([]*jsonType, len(types))
+	for i, t := range types {
+		jt, err := toJSONType(t)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = jt
+	}
+	return result, nil
+}
+
+func toTypes(types []*jsonType) ([]Type, error) {
+	if types == nil {
+		return nil, nil
+	}
+
+	var result = make([]Type, len(types))
+	for i, jt := range types {
+		t, err := jt.toType()
+		if err != nil {
+			return nil, err
+		}
+		result[i] = t
+	}
+	return result, nil
+}
+
+// MarshalJSON implements json.Marshaler, so packages can be persisted.
+func (p *Package) MarshalJSON() ([]byte, error) {
+	type pkg Package
+	var aliases map[string]*jsonType
+	if p.Aliases != nil {
+		aliases = make(map[string]*jsonType, len(p.Aliases))
+		for name, typ := range p.Aliases {
+			t, err := toJSONType(typ)
+			if err != nil {
+				return nil, err
+			}
+			aliases[name] = t
+		}
+	}
+
+	return json.Marshal(struct {
+		*pkg
+		Aliases map[string]*jsonType
+	}{(*pkg)(p), aliases})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Package) UnmarshalJSON(data []byte) error {
+	type pkg Package
+	var v struct {
+		*pkg
+		Aliases map[string]*jsonType
+	}
+	v.pkg = (*pkg)(p)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	p.Aliases = nil
+	if v.Aliases != nil {
+		p.Aliases = make(map[string]Type, len(v.Aliases))
+		for name, jt := range v.Aliases {
+			t, err := jt.toType()
+			if err != nil {
+				return err
+			}
+			p.Aliases[name] = t
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f *Field) MarshalJSON() ([]byte, error) {
+	type field Field
+	typ, err := toJSONType(f.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		*field
+		Type *jsonType
+	}{(*field)(f), typ})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *Field) UnmarshalJSON(data []byte) error {
+	type field Field
+	var v struct {
+		*field
+		Type *jsonType
+	}
+	v.field = (*field)(f)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	typ, err := v.Type.toType()
+	f.Type = typ
+	return err
+}
+
+// MarshalJSON implements json.Marshaler.
+func (fn *Func) MarshalJSON() ([]byte, error) {
+	type fun Func
+	receiver, err := toJSONType(fn.Receiver)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := toJSONTypes(fn.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := toJSONTypes(fn.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		*fun
+		Receiver *jsonType
+		Input    []*jsonType
+		Output   []*jsonType
+	}{(*fun)(fn), receiver, input, output})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (fn *Func) UnmarshalJSON(data []byte) error {
+	type fun Func
+	var v struct {
+		*fun
+		Receiver *jsonType
+		Input    []*jsonType
+		Output   []*jsonType
+	}
+	v.fun = (*fun)(fn)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var err error
+	if fn.Receiver, err = v.Receiver.toType(); err != nil {
+		return err
+	}
+
+	if fn.Input, err = toTypes(v.Input); err != nil {
+		return err
+	}
+
+	fn.Output, err = toTypes(v.Output)
+	return err
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o *FuncOption) MarshalJSON() ([]byte, error) {
+	type option FuncOption
+	typ, err := toJSONType(o.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		*option
+		Type *jsonType
+	}{(*option)(o), typ})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (o *FuncOption) UnmarshalJSON(data []byte) error {
+	type option FuncOption
+	var v struct {
+		*option
+		Type *jsonType
+	}
+	v.option = (*option)(o)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	typ, err := v.Type.toType()
+	o.Type = typ
+	return err
+}
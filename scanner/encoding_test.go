@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"encoding/json"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageJSON(t *testing.T) {
+	require := require.New(t)
+
+	repeated := NewNamed("foo", "Bar")
+	repeated.SetRepeated(true)
+	pkg := &Package{
+		Resolved: true,
+		Path:     "foo",
+		Name:     "foo",
+		Structs: []*Struct{
+			{
+				Docs:     Docs{Doc: []string{"Foo is a foo."}, Deprecated: true},
+				Generate: true,
+				Name:     "Foo",
+				Fields: []*Field{
+					{Name: "Bar", Type: NewMap(NewBasic("string"), repeated)},
+					{Name: "Baz", Type: NewAlias(NewNamed("foo", "Baz"), NewBasic("int")), Validate: []string{"gt=0"}},
+					{Name: "Qux", Type: NewBoolSet(&Named{BaseType: newBaseType(), Path: "foo", Name: "Qux", Message: true})},
+					{Name: "Reserved", Reserved: true},
+				},
+				Position: token.Position{Filename: "foo.go", Line: 3, Column: 6},
+			},
+		},
+		Enums: []*Enum{
+			{Name: "Kind", Values: []*EnumValue{{Name: "A"}, {Name: "B", Value: 1}}},
+		},
+		Funcs: []*Func{
+			{
+				Name:        "DoFoo",
+				Receiver:    NewNamed("foo", "Foo"),
+				Input:       []Type{NewNamed("foo", "Foo")},
+				Output:      []Type{NewSet(NewBasic("string")), NewNamed("", "error")},
+				HTTP:        &HTTPRule{Method: "get", Path: "/foo"},
+				FuncOptions: []*FuncOption{{Name: "WithLimit", Field: "Limit", Type: NewBasic("int")}},
+			},
+		},
+		Interfaces: []*Interface{
+			{Name: "Service", Methods: []*Func{{Name: "Get", Input: []Type{}}}},
+		},
+		Consts:       []*Const{{Name: "Max", Kind: IntConst, Value: "100"}},
+		Aliases:      map[string]Type{"foo.Baz": NewBasic("int")},
+		ProtoPackage: "foo.v1",
+	}
+
+	data, err := json.Marshal(pkg)
+	require.Nil(err)
+
+	var decoded Package
+	require.Nil(json.Unmarshal(data, &decoded))
+	require.Equal(pkg, &decoded)
+}
+
+func TestPackageJSONUnknownKind(t *testing.T) {
+	var pkg Package
+	err := json.Unmarshal([]byte(`{"Aliases": {"foo.Bar": {"kind": "chan"}}}`), &pkg)
+	require.Error(t, err)
+}
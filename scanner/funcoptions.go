@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"go/types"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+const funcOptionsComment = `//proteus:options`
+
+// FuncOption is a functional option of a variadic func that is sent as an
+// optional field of its request, given with a comment like
+// `//proteus:options WithLimit WithTag` in the func. The option is only
+// passed to the func if the field is set.
+type FuncOption struct {
+	// Name is the name of the func that creates the option, e.g. WithLimit.
+	Name string
+	// Field is the name of the field of the request, which is the name of
+	// the func without the With prefix, e.g. Limit.
+	Field string
+	// Type is the type of the only parameter of the func that creates the
+	// option. It is always nullable, so it can be told whether it is set.
+	Type Type
+}
+
+// funcOptions returns the functional options of the func with the given
+// name and signature that are sent in its request. The funcs creating them
+// must be declared in the given scope, take a single parameter and return
+// the type of the variadic parameter of the func. The rest are ignored with
+// a warning.
+func (ctx *context) funcOptions(name string, signature *types.Signature, scope *types.Scope) []*FuncOption {
+	fn, ok := ctx.funcs[name]
+	if !ok {
+		return nil
+	}
+
+	arg, ok := commentArg(fn.Doc, funcOptionsComment)
+	if !ok {
+		return nil
+	}
+
+	if !signature.Variadic() {
+		report.WarnAt(report.CodeInvalidComment, ctx.position(name), name, "func %s has an options comment but it is not variadic, ignoring it", name)
+		return nil
+	}
+
+	params := signature.Params()
+	option := params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+
+	var options []*FuncOption
+	for _, n := range strings.Fields(arg) {
+		typ, ok := optionParamType(scope.Lookup(n), option)
+		if !ok {
+			report.WarnAt(report.CodeInvalidComment, ctx.position(name), name, "func %s has an invalid option %s, ignoring it: it must be a func taking a single parameter and returning %s", name, n, option)
+			continue
+		}
+
+		t := scanType(typ)
+		if t == nil {
+			continue
+		}
+
+		if isSet(t) {
+			report.WarnAt(report.CodeUnsupportedType, ctx.position(name), name, "func %s has an option %s whose parameter is a set, which is not supported, ignoring it", name, n)
+			continue
+		}
+		t.SetNullable(true)
+
+		options = append(options, &FuncOption{
+			Name:  n,
+			Field: optionField(n),
+			Type:  t,
+		})
+	}
+	return options
+}
+
+// optionParamType returns the type of the parameter of the given object if
+// it is a func that creates an option of the given type.
+func optionParamType(obj types.Object, option types.Type) (types.Type, bool) {
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil, false
+	}
+
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() != nil || sig.Variadic() || sig.Params().Len() != 1 ||
+		sig.Results().Len() != 1 || !types.Identical(sig.Results().At(0).Type(), option) {
+		return nil, false
+	}
+	return sig.Params().At(0).Type(), true
+}
+
+// optionField returns the name of the field of the option created by the
+// func with the given name.
+func optionField(name string) string {
+	field := strings.TrimPrefix(name, "With")
+	if field == "" {
+		return name
+	}
+	return strings.ToUpper(field[:1]) + field[1:]
+}
@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+const httpComment = `//proteus:http`
+
+// HTTPRule is the HTTP mapping of an RPC, given with a comment like
+// `//proteus:http GET /v1/users/{id}` in the func.
+type HTTPRule struct {
+	// Method is the HTTP method in lower case, e.g. get.
+	Method string
+	// Path is the URL path template, e.g. /v1/users/{id}.
+	Path string
+	// Body is the name of the request field mapped to the HTTP body, or "*"
+	// for all the fields not bound by the path. Empty if there is no body.
+	Body string
+}
+
+// httpRule returns the HTTP rule of the func with the given name, if any.
+// Invalid rules are ignored with a warning.
+func (ctx *context) httpRule(name string) *HTTPRule {
+	fn, ok := ctx.funcs[name]
+	if !ok || fn.Doc == nil {
+		return nil
+	}
+
+	for _, c := range fn.Doc.List {
+		if !strings.HasPrefix(c.Text, httpComment+" ") {
+			continue
+		}
+
+		rule, err := parseHTTPRule(strings.TrimPrefix(c.Text, httpComment))
+		if err != nil {
+			report.WarnAt(report.CodeInvalidComment, ctx.position(name), name, "func %s has an invalid http comment, ignoring it: %s", name, err)
+			return nil
+		}
+		return rule
+	}
+
+	return nil
+}
+
+// parseHTTPRule parses a rule with the format `METHOD PATH [BODY]`. If the
+// body is not given, it is "*" for the methods that accept a body.
+func parseHTTPRule(text string) (*HTTPRule, error) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("expected METHOD PATH [BODY], got %q", strings.TrimSpace(text))
+	}
+
+	rule := &HTTPRule{
+		Method: strings.ToLower(parts[0]),
+		Path:   parts[1],
+	}
+
+	if !strings.HasPrefix(rule.Path, "/") {
+		return nil, fmt.Errorf("path %q must start with /", rule.Path)
+	}
+
+	switch rule.Method {
+	case "get", "delete":
+		if len(parts) == 3 {
+			return nil, fmt.Errorf("method %s can not have a body", parts[0])
+		}
+	case "post", "put", "patch":
+		rule.Body = "*"
+		if len(parts) == 3 {
+			rule.Body = parts[2]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported method %s", parts[0])
+	}
+
+	return rule, nil
+}
@@ -0,0 +1,41 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTTPRule(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected *HTTPRule
+	}{
+		{" GET /v1/users/{id}", &HTTPRule{Method: "get", Path: "/v1/users/{id}"}},
+		{" delete /v1/users/{id}", &HTTPRule{Method: "delete", Path: "/v1/users/{id}"}},
+		{" POST /v1/users", &HTTPRule{Method: "post", Path: "/v1/users", Body: "*"}},
+		{" PATCH /v1/users/{user.id} user", &HTTPRule{Method: "patch", Path: "/v1/users/{user.id}", Body: "user"}},
+	}
+
+	for _, c := range cases {
+		rule, err := parseHTTPRule(c.text)
+		require.NoError(t, err, c.text)
+		require.Equal(t, c.expected, rule, c.text)
+	}
+}
+
+func TestParseHTTPRuleInvalid(t *testing.T) {
+	cases := []string{
+		"",
+		" GET",
+		" GET v1/users",
+		" GET /v1/users body",
+		" FETCH /v1/users",
+		" POST /v1/users body extra",
+	}
+
+	for _, c := range cases {
+		_, err := parseHTTPRule(c)
+		require.Error(t, err, c)
+	}
+}
@@ -0,0 +1,193 @@
+package scanner
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// inlineTypes holds the struct types without a declaration of their own used
+// by the funcs generated, which are scanned as structs of the package if
+// Scanner.SetInlineTypes is enabled. They are the struct types written in
+// the signatures of the funcs, like the ones of the package-level vars
+// initialized with func literals, and the instantiations of generic struct
+// types, such as Page[User], along with the types their fields use.
+type inlineTypes struct {
+	// path is the path of the package being scanned.
+	path string
+	// declared are the names of the types declared in the package, which
+	// inline types can not be named with.
+	declared map[string]*ast.TypeSpec
+	types    []types.Type
+	names    []string
+	// scanned is the number of types already scanned as structs.
+	scanned int
+	// ctx is the context of the scan of the package.
+	ctx *context
+}
+
+// useInlineTypes makes the scan of the package at the given path scan the
+// inline types, and generate the package-level vars initialized with funcs
+// like funcs.
+func (ctx *context) useInlineTypes(path string) {
+	ctx.inline = &inlineTypes{path: path, declared: ctx.types, ctx: ctx}
+	for name, fn := range ctx.funcVars {
+		if _, ok := ctx.funcs[name]; !ok {
+			ctx.funcs[name] = fn
+		}
+	}
+}
+
+// inlineTypesOf returns the inline types of the package if the given struct
+// is one of them, as the fields of the rest can not use them.
+func (ctx *context) inlineTypesOf(s *Struct) *inlineTypes {
+	if s.Inline {
+		return ctx.inline
+	}
+	return nil
+}
+
+// named returns the named type the given inline type is scanned as, which
+// has the given name unless the type was already found with another. If the
+// name is already taken, the type is ignored with a warning.
+func (i *inlineTypes) named(typ types.Type, name string) Type {
+	for j, t := range i.types {
+		if types.Identical(t, typ) {
+			return i.newNamed(i.names[j])
+		}
+	}
+
+	if _, ok := i.declared[name]; ok || containsString(i.names, name) {
+		i.ctx.skipAt(token.Position{}, "", "ignoring type %s, its name %s is already taken", typ, name)
+		return nil
+	}
+
+	i.types = append(i.types, typ)
+	i.names = append(i.names, name)
+	return i.newNamed(name)
+}
+
+func (i *inlineTypes) newNamed(name string) Type {
+	t := NewNamed(i.path, name)
+	t.(*Named).Inline = true
+	return t
+}
+
+// scanInlineTypes adds the inline types found to the structs of the
+// package, including the ones found while scanning them. The instantiations
+// of generic types declared in the package get the docs of their type.
+func (p *Package) scanInlineTypes(ctx *context) {
+	inline := ctx.inline
+	for ; inline.scanned < len(inline.types); inline.scanned++ {
+		var (
+			typ  = inline.types[inline.scanned]
+			s    = &Struct{Name: inline.names[inline.scanned], Generate: true, Inline: true}
+			name = s.Name
+		)
+
+		if named, ok := typ.(*types.Named); ok && removeGoPath(named.Obj().Pkg()) == p.Path {
+			name = named.Obj().Name()
+			ctx.trySetDocs(name, s)
+			s.File = ctx.typeFiles[name]
+		}
+		p.Structs = append(p.Structs, scanStructFields(ctx, s, name, typ.Underlying().(*types.Struct), nil))
+	}
+}
+
+// isInstance reports whether the given type is an instantiation of a
+// generic struct type.
+func isInstance(typ *types.Named) bool {
+	if typ.TypeArgs().Len() == 0 {
+		return false
+	}
+	_, ok := typ.Underlying().(*types.Struct)
+	return ok
+}
+
+// instanceName returns the name the given instantiation of a generic type is
+// scanned as, which is the name of the type followed by the ones of its type
+// arguments, e.g. PageUser for Page[User] and PairStringInt64 for
+// Pair[string, int64]. It is empty if any type argument can not be named.
+func instanceName(typ *types.Named) string {
+	name := typ.Obj().Name()
+	args := typ.TypeArgs()
+	for i := 0; i < args.Len(); i++ {
+		arg := typeArgName(args.At(i))
+		if arg == "" {
+			return ""
+		}
+		name += arg
+	}
+	return name
+}
+
+func typeArgName(typ types.Type) string {
+	switch t := typ.(type) {
+	case *types.Basic:
+		return capitalize(t.Name())
+	case *types.Named:
+		return instanceName(t)
+	case *types.Slice:
+		if name := typeArgName(t.Elem()); name != "" {
+			return name + "List"
+		}
+	}
+	return ""
+}
+
+// inlineTypeName returns the name of the struct types written inline in the
+// variable at the given position of the params or results of the func with
+// the given name, which is the name of the func followed by the one of the
+// variable, or by the given kind and the position if it has no name, e.g.
+// CreateUserReq or CreateUserResult1.
+func inlineTypeName(fn, kind string, v *types.Var, i int) string {
+	fn = strings.Replace(fn, ".", "", -1)
+	if v.Name() == "" || v.Name() == "_" {
+		return fmt.Sprintf("%s%s%d", fn, kind, i+1)
+	}
+	return fn + capitalize(v.Name())
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// findFuncVars returns the package-level vars of the package as func
+// declarations indexed by their name, with the docs of their spec, or of
+// their declaration if it has a single spec, and the body of the func
+// literal they are initialized with, if any.
+func findFuncVars(pkg *ast.Package) map[string]*ast.FuncDecl {
+	var vars = make(map[string]*ast.FuncDecl)
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			decl, ok := d.(*ast.GenDecl)
+			if !ok || decl.Tok != token.VAR {
+				continue
+			}
+
+			for _, s := range decl.Specs {
+				spec := s.(*ast.ValueSpec)
+				doc := spec.Doc
+				if doc == nil && len(decl.Specs) == 1 {
+					doc = decl.Doc
+				}
+
+				for i, n := range spec.Names {
+					fn := &ast.FuncDecl{Doc: doc, Name: n, Type: &ast.FuncType{}}
+					if i < len(spec.Values) {
+						if lit, ok := spec.Values[i].(*ast.FuncLit); ok {
+							fn.Type, fn.Body = lit.Type, lit.Body
+						}
+					}
+					vars[n.Name] = fn
+				}
+			}
+		}
+	}
+	return vars
+}
@@ -3,6 +3,7 @@ package scanner
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
 	"strings"
 )
 
@@ -16,7 +17,69 @@ type Package struct {
 	Structs  []*Struct
 	Enums    []*Enum
 	Funcs    []*Func
-	Aliases  map[string]Type
+	// Interfaces are the interfaces marked to be generated as services.
+	Interfaces []*Interface
+	// Consts are the constants of basic types marked to be generated.
+	Consts []*Const
+	// Arrays are the named array types of booleans or numbers, like
+	// `type Hash [32]byte`.
+	Arrays []*Array
+	// Lazies are the named types of the messages decoded lazily, like
+	// `type LazyReport lazy.Message[Report]`.
+	Lazies  []*Lazy
+	Aliases map[string]Type
+	// FieldNaming is the naming strategy of the fields of the structs given
+	// in the docs of the package, if any.
+	FieldNaming string
+	// ProtoPackage is the protobuf package given in the docs of the package
+	// with `//proteus:package my.company.users.v1`, if any.
+	ProtoPackage string
+	// ProtobufAPI is the API of the Go code generated by protoc that the
+	// generated server is written against, given in the docs of the package
+	// with `//proteus:protobuf-api apiv2`, if any.
+	ProtobufAPI string
+}
+
+// useBoolSets replaces the maps with bool values used directly as the types
+// of the parameters and results of the funcs and methods of the package, like
+// map[string]bool, with bool sets. The fields of structs and the types
+// declared with a map type are kept as maps, as the messages of the structs
+// are generated for the Go structs, which can not hold a repeated field in a
+// map.
+func (p *Package) useBoolSets() {
+	for _, fn := range p.Funcs {
+		fn.useBoolSets()
+	}
+
+	for _, i := range p.Interfaces {
+		for _, m := range i.Methods {
+			m.useBoolSets()
+		}
+	}
+}
+
+func (fn *Func) useBoolSets() {
+	for i, t := range fn.Input {
+		fn.Input[i] = toBoolSet(t)
+	}
+
+	for i, t := range fn.Output {
+		fn.Output[i] = toBoolSet(t)
+	}
+}
+
+// toBoolSet returns a bool set with the keys of the type if it is a map with
+// bool values that is not repeated, or the type itself otherwise.
+func toBoolSet(t Type) Type {
+	m, ok := t.(*Map)
+	if !ok || m.IsRepeated() {
+		return t
+	}
+
+	if v, ok := m.Value.(*Basic); ok && v.Name == "bool" && !v.Repeated && !v.Nullable {
+		return NewBoolSet(m.Key)
+	}
+	return t
 }
 
 // collectEnums finds the enum values collected during the scan and generates
@@ -139,6 +202,13 @@ type Named struct {
 	*BaseType
 	Path string
 	Name string
+	// Message reports whether the type already implements proto.Message,
+	// because it was generated from a .proto file.
+	Message bool
+	// Inline reports whether the type is an inline struct of the package,
+	// whose Go type is declared by the generated code and converted from
+	// the one it was scanned from.
+	Inline bool
 }
 
 // String returns a string representation for the type
@@ -162,9 +232,9 @@ func (n Named) UnqualifiedName() string {
 // NewNamed creates a new named type given its package path and name.
 func NewNamed(path, name string) Type {
 	return &Named{
-		newBaseType(),
-		path,
-		name,
+		BaseType: newBaseType(),
+		Path:     path,
+		Name:     name,
 	}
 }
 
@@ -233,6 +303,53 @@ func (m Map) UnqualifiedName() string {
 	return m.String()
 }
 
+// Set is a map whose values carry no information, like map[string]struct{},
+// which is the common way of representing a set in Go. It is represented as
+// a repeated list of its elements.
+type Set struct {
+	*BaseType
+	Elem Type
+	// Bool sets are maps with bool values, like map[string]bool, whose
+	// elements are the keys set to true.
+	Bool bool
+}
+
+// NewSet creates a new set type with the given element type.
+func NewSet(elem Type) Type {
+	return &Set{
+		BaseType: &BaseType{Repeated: true},
+		Elem:     elem,
+	}
+}
+
+// NewBoolSet creates a new set type with the given element type whose values
+// are bools.
+func NewBoolSet(elem Type) Type {
+	return &Set{
+		BaseType: &BaseType{Repeated: true},
+		Elem:     elem,
+		Bool:     true,
+	}
+}
+
+// String returns a string representation for the type
+func (s Set) String() string {
+	if s.Bool {
+		return fmt.Sprintf("map[%s]bool", s.Elem.String())
+	}
+	return fmt.Sprintf("map[%s]struct{}", s.Elem.String())
+}
+
+// TypeString returns a string representation for the type casting
+func (s Set) TypeString() string {
+	return s.String()
+}
+
+// UnqualifiedName returns the bare name, without the package.
+func (s Set) UnqualifiedName() string {
+	return s.String()
+}
+
 // Documentable is something whose documentation can be set.
 type Documentable interface {
 	// SetDocs sets the documentation from an AST comment group.
@@ -242,15 +359,28 @@ type Documentable interface {
 // Docs holds the documentation of a struct, enum, value, field, etc.
 type Docs struct {
 	Doc []string
+	// Deprecated reports whether the documented entity is marked as
+	// deprecated with a comment like `//proteus:deprecated use Bar instead`.
+	Deprecated bool
 }
 
 // SetDocs sets the documentation from an AST comment group.
-// It removes the //proteus:generate comment from the comments.
+// It removes the proteus comments, such as //proteus:generate, from the
+// comments. The reason given in the deprecated comment, if any, is added to
+// the documentation as a "Deprecated: reason" paragraph.
 func (d *Docs) SetDocs(comments *ast.CommentGroup) {
-	var list []*ast.Comment
+	var (
+		list   []*ast.Comment
+		reason string
+	)
 	if comments != nil {
 		for _, c := range comments.List {
-			if !strings.HasPrefix(c.Text, genComment) {
+			if c.Text == deprecatedComment || strings.HasPrefix(c.Text, deprecatedComment+" ") {
+				d.Deprecated = true
+				reason = strings.TrimSpace(strings.TrimPrefix(c.Text, deprecatedComment))
+			}
+
+			if !strings.HasPrefix(c.Text, commentPrefix) {
 				list = append(list, c)
 			}
 		}
@@ -261,6 +391,13 @@ func (d *Docs) SetDocs(comments *ast.CommentGroup) {
 			(&ast.CommentGroup{List: list}).Text(),
 		), "\n")
 	}
+
+	if reason != "" {
+		if len(d.Doc) > 0 {
+			d.Doc = append(d.Doc, "")
+		}
+		d.Doc = append(d.Doc, "Deprecated: "+reason)
+	}
 }
 
 // Enum consists of a list of possible values.
@@ -269,12 +406,64 @@ type Enum struct {
 	Name       string
 	Values     []*EnumValue
 	IsStringer bool
+	// Naming is the naming strategy of the values given in the docs of the
+	// enum or its package, if any.
+	Naming string
+	// Unspecified is "true" or "false" if the docs of the enum or its
+	// package tell whether an unspecified value must be added to it, if any.
+	Unspecified string
+	// Semantics is "open" or "closed" if the docs of the enum or its package
+	// tell how its unknown values are handled, if any.
+	Semantics string
+	// Integer reports whether the values of the enum are integers, instead
+	// of their position in the enum.
+	Integer bool
+	// File is the name of the source file the enum is declared in.
+	File string
+	// Position is the position of the name of the enum in its source file.
+	Position token.Position
+}
+
+// Array is a named array type of booleans or numbers, like
+// `type Hash [32]byte`, which is generated as a bytes field with the
+// methods of gogoproto.customtype.
+type Array struct {
+	Name string
+	// Elem is the name of the basic type of the elements, which is uint8 for
+	// bytes.
+	Elem string
+	// Len is the length of the array.
+	Len int
+	// Position is the position of the name of the array in its source file.
+	Position token.Position
+}
+
+// IsBytes reports whether the array is an array of bytes.
+func (a *Array) IsBytes() bool {
+	return a.Elem == "uint8"
+}
+
+// Lazy is a named type of lazy.Message, like
+// `type LazyReport lazy.Message[Report]`, whose fields are generated as
+// fields of its message with the lazy option and the methods of
+// gogoproto.customtype, so the message is only decoded when it is accessed.
+type Lazy struct {
+	Name string
+	// Message is the type of the message, which is declared in the same
+	// package.
+	Message *Named
+	// Position is the position of the name of the lazy type in its source
+	// file.
+	Position token.Position
 }
 
 // EnumValue is a possible value of an enum.
 type EnumValue struct {
 	Docs
 	Name string
+	// Value is the value of the constant if its type is an integer, or its
+	// position in the enum otherwise.
+	Value int64
 }
 
 // Struct represents a Go struct with its name and fields.
@@ -285,6 +474,21 @@ type Struct struct {
 	Name       string
 	Fields     []*Field
 	IsStringer bool
+	// FieldNaming is the naming strategy of the fields given in the docs of
+	// the struct or its package, if any.
+	FieldNaming string
+	// JSONCasing is the casing of the names of the fields in JSON given in
+	// the docs of the struct or its package, if any.
+	JSONCasing string
+	// File is the name of the source file the struct is declared in.
+	File string
+	// Position is the position of the name of the struct in its source file,
+	// which is not valid if it is inline.
+	Position token.Position
+	// Inline reports whether the struct has no declaration of its own, as
+	// its type is written inline in a signature or is an instantiation of a
+	// generic type, so its Go type is declared by the generated code.
+	Inline bool
 }
 
 // HasField reports wether a struct has a given field name.
@@ -301,7 +505,62 @@ func (s *Struct) HasField(name string) bool {
 type Field struct {
 	Docs
 	Name string
+	// Type is nil if the field is reserved.
 	Type Type
+	// Reserved fields are not generated, but their position is reserved
+	// in the message.
+	Reserved bool
+	// ProtoName is the name the field will have in protobuf. If empty, it
+	// is derived from the Go name.
+	ProtoName string
+	// JSONName is the name in the json tag of the field, if it has one.
+	JSONName string
+	// JSONOmit fields are left out of the JSON encoding of the message, but
+	// still sent in the wire format.
+	JSONOmit bool
+	// Unit is the unit of the values of the field given in its proteus tag,
+	// such as ms or bytes, if any.
+	Unit string
+	// ProtoID is the position the field will have in protobuf. If zero, the
+	// field is numbered automatically.
+	ProtoID int
+	// Validate are the rules in the validate tag of the field, such as
+	// "required" or "max=10".
+	Validate []string
+	// Lazy fields are marked to be decoded only when they are accessed by
+	// the protobuf runtimes that support it.
+	Lazy bool
+	// Sensitive fields hold data, such as passwords or personal details,
+	// that is redacted when the messages are logged.
+	Sensitive bool
+	// Position is the position of the name of the field in its source file,
+	// which is not valid if its struct is inline.
+	Position token.Position
+}
+
+// ConstKind is the kind of the value of a constant.
+type ConstKind string
+
+const (
+	// BoolConst is the kind of boolean constants.
+	BoolConst ConstKind = "bool"
+	// StringConst is the kind of string constants.
+	StringConst ConstKind = "string"
+	// IntConst is the kind of integer and rune constants.
+	IntConst ConstKind = "int"
+	// FloatConst is the kind of floating point constants.
+	FloatConst ConstKind = "float"
+)
+
+// Const is a constant of a basic type, typed or not, that is not an enum
+// value.
+type Const struct {
+	Docs
+	Name string
+	Kind ConstKind
+	// Value is the value of the constant, which is not quoted for strings and
+	// is exact for integers, even if they do not fit in 64 bits.
+	Value string
 }
 
 // Func is either a function or a method. Receiver will be nil in functions,
@@ -315,4 +574,73 @@ type Func struct {
 	Output   []Type
 	// IsVariadic will be true if the last input parameter is variadic.
 	IsVariadic bool
+	// HTTP is the HTTP mapping of the func. Nil if it has none.
+	HTTP *HTTPRule
+	// MaxConcurrency is the maximum number of concurrent calls to the func
+	// allowed by the RPC server. Zero if there is no limit.
+	MaxConcurrency int
+	// Idempotent reports whether calling the func more than once with the
+	// same arguments has the same effects as calling it once, so its calls
+	// can be hedged or retried.
+	Idempotent bool
+	// FuncOptions are the functional options sent in the request of the
+	// func. If there are any, the variadic parameter is not in Input.
+	FuncOptions []*FuncOption
+	// Stream is the kind of stream of values the func returns, if any, which
+	// is generated as a server-streaming RPC. The first type of Output is
+	// the type of the values instead of the one of the stream.
+	Stream Stream
+	// StreamBuffer is the buffering of the values of the stream between the
+	// func and the client given with `//proteus:stream-buffer 16 drop`.
+	StreamBuffer StreamBuffer
+	// Position is the position of the name of the func in its source file,
+	// which is not valid for the methods of interfaces.
+	Position token.Position
+}
+
+// Stream is the kind of stream of values returned by a func.
+type Stream string
+
+const (
+	// NoStream is the Stream of the funcs that do not return a stream.
+	NoStream Stream = ""
+	// ChanStream is the Stream of the funcs returning a channel, like
+	// `<-chan *Event`, which ends when it is closed.
+	ChanStream Stream = "chan"
+	// SeqStream is the Stream of the funcs returning an iterator, like
+	// `iter.Seq[*User]`.
+	SeqStream Stream = "seq"
+	// SeqErrStream is the Stream of the funcs returning an iterator of
+	// values and errors, like `iter.Seq2[*User, error]`, which ends with the
+	// first error.
+	SeqErrStream Stream = "seq2"
+)
+
+// StreamBuffer is the buffering of the values of a stream between the func
+// producing them and the client, with room for Size values. When it is
+// full, the func is blocked until there is room again, unless Drop is true,
+// in which case the values produced are dropped instead.
+type StreamBuffer struct {
+	Size int
+	Drop bool
+}
+
+// setFuncOptions sets the functional options sent in the request of the
+// func, replacing its variadic parameter.
+func (fn *Func) setFuncOptions(options []*FuncOption) {
+	if len(options) == 0 {
+		return
+	}
+
+	fn.FuncOptions = options
+	fn.Input = fn.Input[:len(fn.Input)-1]
+}
+
+// Interface is an interface whose methods will be generated as the RPCs of
+// its own service.
+type Interface struct {
+	Docs
+	Name string
+	// Methods of the interface. Their receiver is the interface.
+	Methods []*Func
 }
@@ -3,10 +3,12 @@ package scanner
 import (
 	"errors"
 	"fmt"
+	"go/constant"
 	"go/types"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -20,8 +22,37 @@ var goPath = os.Getenv("GOPATH")
 // Scanner scans packages looking for Go source files to parse
 // and extract types and structs from.
 type Scanner struct {
-	packages []string
-	importer *parseutil.Importer
+	packages    []string
+	importer    *parseutil.Importer
+	fieldPolicy FieldPolicy
+	boolSets    bool
+	inlineTypes bool
+	cache       *Cache
+}
+
+// FieldPolicy defines what to do with struct fields whose type is a channel
+// or a func, which can not be represented in protobuf.
+type FieldPolicy string
+
+const (
+	// SkipField ignores the field. This is the default policy.
+	SkipField FieldPolicy = "skip"
+	// FailOnField makes the scan fail.
+	FailOnField FieldPolicy = "error"
+	// PlaceholderField ignores the field but reserves its position in the
+	// generated message, so the rest of the fields keep their numbers if
+	// its type is changed later.
+	PlaceholderField FieldPolicy = "placeholder"
+)
+
+// ParseFieldPolicy returns the field policy with the given name.
+func ParseFieldPolicy(name string) (FieldPolicy, error) {
+	switch p := FieldPolicy(name); p {
+	case SkipField, FailOnField, PlaceholderField:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("invalid field policy %q, valid policies are: %s, %s and %s", name, SkipField, FailOnField, PlaceholderField)
 }
 
 // ErrNoGoPathSet is the error returned when the GOPATH variable is not
@@ -47,19 +78,58 @@ func New(packages ...string) (*Scanner, error) {
 	}
 
 	return &Scanner{
-		packages: packages,
-		importer: parseutil.NewImporter(),
+		packages:    packages,
+		importer:    parseutil.NewImporter(),
+		fieldPolicy: SkipField,
 	}, nil
 }
 
+// SetFieldPolicy sets the policy used for struct fields of channel or func
+// types.
+func (s *Scanner) SetFieldPolicy(policy FieldPolicy) {
+	s.fieldPolicy = policy
+}
+
+// SetBoolSets sets whether maps with bool values, like map[string]bool, in the
+// params and results of funcs are scanned as sets of the keys set to true in
+// the packages that do not tell it in their docs.
+func (s *Scanner) SetBoolSets(enabled bool) {
+	s.boolSets = enabled
+}
+
+// SetInlineTypes sets whether the struct types without a declaration of their
+// own used by the funcs generated are scanned as structs of the package,
+// instead of being ignored. They are the struct types written in the
+// signatures of the funcs, named after the func and the param or result, and
+// the instantiations of generic struct types, named after the type and its
+// type arguments, e.g. PageUser for Page[User]. Package-level vars
+// initialized with funcs are also generated like funcs.
+func (s *Scanner) SetInlineTypes(enabled bool) {
+	s.inlineTypes = enabled
+}
+
+// SetCache sets the cache the scanned packages are kept in and taken from,
+// if they are already in it. The packages in a cache created with NewCache
+// must have been scanned with the same options, while the ones persisted by
+// a cache created with NewDiskCache are keyed by them.
+func (s *Scanner) SetCache(cache *Cache) {
+	s.cache = cache
+}
+
 // Scan retrieves the scanned packages containing the extracted
 // go types and structs.
 func (s *Scanner) Scan() ([]*Package, error) {
+	keys, err := s.cacheKeys()
+	if err != nil {
+		return nil, err
+	}
+
 	var (
-		pkgs   = make([]*Package, len(s.packages))
-		errors errorList
-		mut    sync.Mutex
-		wg     = new(sync.WaitGroup)
+		pkgs        = make([]*Package, len(s.packages))
+		unsupported = make([][]string, len(s.packages))
+		errors      errorList
+		mut         sync.Mutex
+		wg          = new(sync.WaitGroup)
 	)
 
 	wg.Add(len(s.packages))
@@ -67,7 +137,21 @@ func (s *Scanner) Scan() ([]*Package, error) {
 		go func(p string, i int) {
 			defer wg.Done()
 
-			pkg, err := s.scanPackage(p)
+			if s.cache != nil {
+				if cached, ok := s.cache.get(p, keys[p]); ok {
+					for _, skipped := range cached.Skipped {
+						report.SkipAt(skipped.Position, skipped.Symbol, "%s", skipped.Message)
+					}
+
+					mut.Lock()
+					defer mut.Unlock()
+					pkgs[i] = cached.Package
+					unsupported[i] = cached.Unsupported
+					return
+				}
+			}
+
+			result, err := s.scanPackage(p)
 			mut.Lock()
 			defer mut.Unlock()
 			if err != nil {
@@ -75,7 +159,13 @@ func (s *Scanner) Scan() ([]*Package, error) {
 				return
 			}
 
-			pkgs[i] = pkg
+			if s.cache != nil {
+				result.Key = keys[p]
+				s.cache.add(p, result)
+			}
+
+			pkgs[i] = result.Package
+			unsupported[i] = result.Unsupported
 		}(p, i)
 	}
 
@@ -84,21 +174,37 @@ func (s *Scanner) Scan() ([]*Package, error) {
 		return nil, errors.err()
 	}
 
+	s.reportUnsupportedFields(unsupported)
 	return pkgs, nil
 }
 
-func (s *Scanner) scanPackage(p string) (*Package, error) {
-	pkg, err := s.importer.ImportWithFilters(
-		p,
-		parseutil.FileFilters{
-			func(pkg, file string, typ parseutil.FileType) bool {
-				return !strings.HasSuffix(file, ".pb.go")
-			},
-			func(pkg, file string, typ parseutil.FileType) bool {
-				return !strings.HasSuffix(file, ".proteus.go")
-			},
-		},
+// reportUnsupportedFields prints a single summary of all the fields of
+// channel or func types found in all packages.
+func (s *Scanner) reportUnsupportedFields(unsupported [][]string) {
+	var fields []string
+	for _, f := range unsupported {
+		fields = append(fields, f...)
+	}
+
+	if len(fields) == 0 {
+		return
+	}
+
+	action := "ignored"
+	if s.fieldPolicy == PlaceholderField {
+		action = "ignored reserving their position"
+	}
+
+	report.Skip(
+		"%d fields with channel or func types were %s: %s",
+		len(fields),
+		action,
+		strings.Join(fields, ", "),
 	)
+}
+
+func (s *Scanner) scanPackage(p string) (*scanned, error) {
+	pkg, err := s.importPackage(p)
 	if err != nil {
 		return nil, err
 	}
@@ -107,17 +213,70 @@ func (s *Scanner) scanPackage(p string) (*Package, error) {
 	if err != nil {
 		return nil, err
 	}
+	ctx.fieldPolicy = s.fieldPolicy
+	ctx.boolSets = s.boolSets
+	if s.inlineTypes {
+		ctx.useInlineTypes(removeGoPath(pkg))
+	}
+
+	result, err := buildPackage(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
 
-	return buildPackage(ctx, pkg)
+	if len(ctx.failedFields) > 0 {
+		return nil, fmt.Errorf(
+			"fields with channel or func types are not allowed: %s",
+			strings.Join(ctx.failedFields, ", "),
+		)
+	}
+
+	if len(ctx.setFields) > 0 {
+		return nil, fmt.Errorf(
+			"fields with set types are only supported in the params and results of funcs, use slices in structs instead: %s",
+			strings.Join(ctx.setFields, ", "),
+		)
+	}
+
+	var imports []string
+	for _, i := range pkg.Imports() {
+		imports = append(imports, removeGoPath(i))
+	}
+
+	return &scanned{
+		Package:     result,
+		Unsupported: ctx.unsupportedFields,
+		Skipped:     ctx.skipped,
+		Imports:     imports,
+	}, nil
+}
+
+// importPackage imports the package at the given path, leaving out the files
+// generated by protoc and proteus.
+func (s *Scanner) importPackage(p string) (*types.Package, error) {
+	return s.importer.ImportWithFilters(
+		p,
+		parseutil.FileFilters{
+			func(pkg, file string, typ parseutil.FileType) bool {
+				return !strings.HasSuffix(file, ".pb.go")
+			},
+			func(pkg, file string, typ parseutil.FileType) bool {
+				return !strings.HasSuffix(file, ".proteus.go")
+			},
+		},
+	)
 }
 
 func buildPackage(ctx *context, gopkg *types.Package) (*Package, error) {
 	objs := objectsInScope(gopkg.Scope())
 
 	pkg := &Package{
-		Path:    removeGoPath(gopkg),
-		Name:    gopkg.Name(),
-		Aliases: make(map[string]Type),
+		Path:         removeGoPath(gopkg),
+		Name:         gopkg.Name(),
+		Aliases:      make(map[string]Type),
+		FieldNaming:  ctx.packageOption(fieldNamingComment),
+		ProtoPackage: ctx.packageOption(packageComment),
+		ProtobufAPI:  ctx.packageOption(protobufAPIComment),
 	}
 
 	for _, o := range objs {
@@ -126,7 +285,14 @@ func buildPackage(ctx *context, gopkg *types.Package) (*Package, error) {
 		}
 	}
 
+	if ctx.inline != nil {
+		pkg.scanInlineTypes(ctx)
+	}
+
 	pkg.collectEnums(ctx)
+	if ctx.useBoolSets() {
+		pkg.useBoolSets()
+	}
 	return pkg, nil
 }
 
@@ -144,15 +310,27 @@ func (p *Package) scanObject(ctx *context, o types.Object) error {
 		switch o.(type) {
 		case *types.Const:
 			if _, ok := t.Underlying().(*types.Basic); ok {
-				scanEnumValue(ctx, o.Name(), t, hasStringMethod)
+				scanEnumValue(ctx, o.(*types.Const), t, hasStringMethod)
 			}
 		case *types.TypeName:
+			if l, ok := scanLazy(ctx, o.Pkg(), o.Name(), t.Underlying()); ok {
+				if l != nil {
+					p.Lazies = append(p.Lazies, l)
+				}
+				return nil
+			}
+
 			if s, ok := t.Underlying().(*types.Struct); ok {
 				st := scanStruct(
+					ctx,
 					&Struct{
-						Name:       o.Name(),
-						Generate:   ctx.shouldGenerateType(o.Name()),
-						IsStringer: hasStringMethod,
+						Name:        o.Name(),
+						Generate:    ctx.shouldGenerateType(o.Name()),
+						IsStringer:  hasStringMethod,
+						FieldNaming: ctx.typeOption(o.Name(), fieldNamingComment),
+						JSONCasing:  ctx.typeOption(o.Name(), jsonCasingComment),
+						File:        ctx.typeFiles[o.Name()],
+						Position:    ctx.position(o.Name()),
 					},
 					s,
 				)
@@ -161,12 +339,33 @@ func (p *Package) scanObject(ctx *context, o types.Object) error {
 				return nil
 			}
 
+			if i, ok := t.Underlying().(*types.Interface); ok && ctx.shouldGenerateService(o.Name()) {
+				p.Interfaces = append(p.Interfaces, scanInterface(ctx, p.Path, o.Name(), i))
+				return nil
+			}
+
+			if a := scanArray(ctx, o.Name(), t.Underlying()); a != nil {
+				p.Arrays = append(p.Arrays, a)
+				return nil
+			}
+
 			p.Aliases[objName(t.Obj())] = scanType(t.Underlying())
 		}
+	case *types.Basic:
+		if c, ok := o.(*types.Const); ok && ctx.shouldGenerateConst(c.Name()) {
+			if k := ctx.scanConst(c, t); k != nil {
+				p.Consts = append(p.Consts, k)
+			}
+		}
 	case *types.Signature:
 		if ctx.shouldGenerateFunc(nameForFunc(o)) {
-			fn := scanFunc(&Func{Name: o.Name()}, t)
+			fn := scanInlineFunc(ctx.inline, &Func{Name: o.Name(), Position: ctx.position(nameForFunc(o))}, nameForFunc(o), t)
 			ctx.trySetDocs(nameForFunc(o), fn)
+			fn.HTTP = ctx.httpRule(nameForFunc(o))
+			fn.MaxConcurrency = ctx.maxConcurrency(nameForFunc(o))
+			fn.Idempotent = ctx.isIdempotent(nameForFunc(o))
+			fn.StreamBuffer = ctx.streamBuffer(nameForFunc(o), fn.Stream)
+			fn.setFuncOptions(ctx.funcOptions(nameForFunc(o), t, o.Pkg().Scope()))
 			p.Funcs = append(p.Funcs, fn)
 		}
 	}
@@ -222,32 +421,84 @@ func nameForType(o types.Type) (name string) {
 	return
 }
 
-func scanType(typ types.Type) (t Type) {
+func scanType(typ types.Type) Type {
+	return scanInlineType(nil, typ, "")
+}
+
+// scanInlineType scans the given type like scanType, scanning the inline
+// types it uses as the given ones if they are not nil. The struct types
+// written inline are named with the given name.
+func scanInlineType(inline *inlineTypes, typ types.Type, name string) (t Type) {
 	switch u := typ.(type) {
 	case *types.Basic:
 		t = NewBasic(u.Name())
 	case *types.Named:
+		if inline != nil && isInstance(u) {
+			if n := instanceName(u); n != "" {
+				return inline.named(u, n)
+			}
+			report.Warn("ignoring type %s, its type arguments can not be named", typ.String())
+			return nil
+		}
+
 		t = NewNamed(
 			removeGoPath(u.Obj().Pkg()),
 			u.Obj().Name(),
 		)
+		t.(*Named).Message = isProtoMessage(u)
 	case *types.Slice:
-		t = scanType(u.Elem())
+		t = scanInlineType(inline, u.Elem(), name)
+		if t == nil {
+			return nil
+		}
+		if isSet(t) {
+			report.Warn("ignoring repeated set %s", typ.String())
+			return nil
+		}
 		t.SetRepeated(true)
 	case *types.Array:
-		t = scanType(u.Elem())
+		t = scanInlineType(inline, u.Elem(), name)
+		if t == nil {
+			return nil
+		}
+		if isSet(t) {
+			report.Warn("ignoring repeated set %s", typ.String())
+			return nil
+		}
+		report.Warn("array type %s is generated as a repeated field, but the generated code can only decode it into a slice, use a slice or declare a named array type, like type ID [16]byte, instead", typ.String())
 		t.SetRepeated(true)
 	case *types.Pointer:
-		t = scanType(u.Elem())
+		t = scanInlineType(inline, u.Elem(), name)
+		if t == nil {
+			return nil
+		}
 		t.SetNullable(true)
 	case *types.Map:
-		key := scanType(u.Key())
-		val := scanType(u.Elem())
+		key := scanInlineType(inline, u.Key(), name)
+		if isEmptyStruct(u.Elem()) {
+			if key == nil {
+				return nil
+			}
+			return NewSet(key)
+		}
+
+		val := scanInlineType(inline, u.Elem(), name)
+		if isSet(val) {
+			report.Warn("ignoring map with set value type %s", typ.String())
+			return nil
+		}
+
 		if val == nil {
 			report.Warn("ignoring map with value type %s", typ.String())
 			return nil
 		}
 		t = NewMap(key, val)
+	case *types.Struct:
+		if inline == nil {
+			report.Warn("ignoring type %s", typ.String())
+			return nil
+		}
+		return inline.named(u, name)
 	default:
 		report.Warn("ignoring type %s", typ.String())
 		return nil
@@ -256,18 +507,133 @@ func scanType(typ types.Type) (t Type) {
 	return
 }
 
-func scanEnumValue(ctx *context, name string, named *types.Named, hasStringMethod bool) {
+// protoMessageMethods are the methods of the proto.Message interface.
+var protoMessageMethods = []string{"Reset", "String", "ProtoMessage"}
+
+// isProtoMessage reports whether a pointer to the type implements
+// proto.Message, which the types generated from .proto files do.
+func isProtoMessage(typ *types.Named) bool {
+	methods := types.NewMethodSet(types.NewPointer(typ))
+	for _, name := range protoMessageMethods {
+		if methods.Lookup(typ.Obj().Pkg(), name) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// syncPackages are the packages whose types are used for synchronization
+// and hold no data worth serializing.
+var syncPackages = map[string]struct{}{
+	"sync":        {},
+	"sync/atomic": {},
+}
+
+func isSyncType(typ types.Type) bool {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+
+	_, ok = syncPackages[named.Obj().Pkg().Path()]
+	return ok
+}
+
+func isChanOrFunc(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Chan, *types.Signature:
+		return true
+	}
+	return false
+}
+
+func isEmptyStruct(typ types.Type) bool {
+	s, ok := typ.Underlying().(*types.Struct)
+	return ok && s.NumFields() == 0
+}
+
+// isSetType reports whether the type, or the type it points to, is a map
+// with empty struct values, which is scanned as a set.
+func isSetType(typ types.Type) bool {
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = p.Elem()
+	}
+
+	m, ok := typ.Underlying().(*types.Map)
+	return ok && isEmptyStruct(m.Elem())
+}
+
+func isSet(t Type) bool {
+	_, ok := t.(*Set)
+	return ok
+}
+
+func scanEnumValue(ctx *context, c *types.Const, named *types.Named, hasStringMethod bool) {
 	typ := objName(named.Obj())
-	ctx.enumValues[typ] = append(ctx.enumValues[typ], name)
+	ctx.enumValues[typ] = append(ctx.enumValues[typ], c.Name())
+	if n, ok := constant.Int64Val(constant.ToInt(c.Val())); ok {
+		ctx.enumNumbers[c.Name()] = n
+	}
 	ctx.enumWithString = append(ctx.enumWithString, typ)
 }
 
-func scanStruct(s *Struct, elem *types.Struct) *Struct {
+// scanConst returns the constant with its value, or nil if its type is not
+// supported, which only happens with complex numbers.
+func (ctx *context) scanConst(c *types.Const, typ *types.Basic) *Const {
+	k := &Const{Name: c.Name()}
+	switch info := typ.Info(); {
+	case info&types.IsBoolean != 0:
+		k.Kind, k.Value = BoolConst, c.Val().String()
+	case info&types.IsString != 0:
+		k.Kind, k.Value = StringConst, constant.StringVal(c.Val())
+	case info&types.IsInteger != 0:
+		k.Kind, k.Value = IntConst, constant.ToInt(c.Val()).ExactString()
+	case info&types.IsFloat != 0:
+		f, _ := constant.Float64Val(constant.ToFloat(c.Val()))
+		k.Kind, k.Value = FloatConst, strconv.FormatFloat(f, 'g', -1, 64)
+	default:
+		ctx.skipAt(ctx.position(c.Name()), c.Name(), "constant %s has the unsupported type %s, it will not be generated", c.Name(), typ)
+		return nil
+	}
+
+	if d, ok := ctx.constDecls[c.Name()]; ok {
+		k.SetDocs(d.doc)
+	}
+	return k
+}
+
+func scanStruct(ctx *context, s *Struct, elem *types.Struct) *Struct {
+	return scanStructFields(ctx, s, s.Name, elem, nil)
+}
+
+// scanStructFields adds to s the fields of elem, which is the struct type
+// declared with the given name. The name is different from the name of s
+// in embedded structs, which are scanned with the structs embedding them, so
+// structs that embed themselves are not scanned forever.
+func scanStructFields(ctx *context, s *Struct, name string, elem *types.Struct, embedding []*types.Struct) *Struct {
+	embedding = append(embedding, elem)
 	for i := 0; i < elem.NumFields(); i++ {
 		v := elem.Field(i)
 		tags := findProtoTags(elem.Tag(i))
 
-		if isIgnoredField(v, tags) {
+		if !v.Exported() {
+			continue
+		}
+
+		pos := ctx.fieldPosition(name, v.Name())
+		if isSyncType(v.Type()) {
+			report.Info("ignoring field %q of %s with synchronization type %s", v.Name(), structOf(s, name), v.Type())
+			continue
+		}
+
+		if isSkippedField(tags) || ctx.shouldSkipField(name, v.Name()) {
+			if !s.HasField(v.Name()) {
+				s.Fields = append(s.Fields, &Field{Name: v.Name(), Reserved: true, Position: pos})
+			}
 			continue
 		}
 
@@ -277,27 +643,69 @@ func scanStruct(s *Struct, elem *types.Struct) *Struct {
 		// completely ignored and a warning is printed to give
 		// feedback to the user.
 		if s.HasField(v.Name()) {
-			report.Warn("struct %q already has a field %q", s.Name, v.Name())
+			ctx.skipAt(pos, s.Name+"."+v.Name(), "struct %q already has a field %q", s.Name, v.Name())
 			continue
 		}
 
 		if v.Anonymous() {
 			embedded := findStruct(v.Type())
 			if embedded == nil {
-				report.Warn("field %q with type %q is not a valid embedded type", v.Name(), v.Type())
+				ctx.skipAt(pos, s.Name+"."+v.Name(), "field %q with type %q is not a valid embedded type", v.Name(), v.Type())
+			} else if containsStruct(embedding, embedded) {
+				ctx.skipAt(pos, s.Name+"."+v.Name(), "field %q of struct %q embeds a struct that embeds it, ignoring it", v.Name(), s.Name)
+			} else {
+				s = scanStructFields(ctx, s, embeddedName(v), embedded, embedding)
+			}
+			continue
+		}
+
+		if isChanOrFunc(v.Type()) {
+			name := fmt.Sprintf("%s.%s", s.Name, v.Name())
+			switch ctx.fieldPolicyOf(s.Name) {
+			case FailOnField:
+				ctx.failedFields = append(ctx.failedFields, name)
+			case PlaceholderField:
+				ctx.unsupportedFields = append(ctx.unsupportedFields, name)
+				s.Fields = append(s.Fields, &Field{Name: v.Name(), Reserved: true, Position: pos})
+			default:
+				ctx.unsupportedFields = append(ctx.unsupportedFields, name)
+			}
+			continue
+		}
+
+		// The Go field of a set can not hold the repeated field it would
+		// be generated as, so sets are only converted in the RPC servers.
+		if isSetType(v.Type()) {
+			name := fmt.Sprintf("%s.%s", s.Name, v.Name())
+			if s.Generate {
+				ctx.setFields = append(ctx.setFields, name)
 			} else {
-				s = scanStruct(s, embedded)
+				ctx.skipAt(pos, name, "field %q of struct %q has the set type %s, which is only supported in the params and results of funcs, ignoring it", v.Name(), s.Name, v.Type())
 			}
 			continue
 		}
 
-		f := &Field{
-			Name: v.Name(),
-			Type: scanType(v.Type()),
+		f := &Field{Name: v.Name(), Position: pos}
+		if option, typ := tagGogoType(tags); option != "" && !isGogoType(v.Type(), typ) && !isAnyconvType(v.Type(), anyconvTypes[option]) {
+			ctx.skipAt(pos, s.Name+"."+v.Name(), "field %q of struct %q is marked as %s but its type %s is not %s, or a slice of them, declare it as such and convert its values with anyconv, ignoring it", v.Name(), s.Name, option, v.Type(), tagTypes(option, typ))
+			continue
+		}
+		f.Type = scanInlineType(ctx.inlineTypesOf(s), v.Type(), s.Name+v.Name())
+		switch {
+		case f.Type == nil && isInterface(v.Type()):
+			ctx.skipAt(pos, s.Name+"."+v.Name(), "field %q of struct %q has the interface type %s, declare it as anyconv.Any for messages or anyconv.Value for JSON-like data, or a slice of them, ignoring it", v.Name(), s.Name, v.Type())
+		case f.Type == nil:
+			ctx.skipAt(pos, s.Name+"."+v.Name(), "field %q of struct %q has the unsupported type %s, ignoring it", v.Name(), s.Name, v.Type())
 		}
 		if f.Type == nil {
 			continue
 		}
+		setFieldTags(s.Name, f, tags)
+		f.Lazy = hasTagOption(tags, lazyOption)
+		f.Sensitive = hasTagOption(tags, sensitiveOption)
+		f.JSONName = findJSONName(elem.Tag(i))
+		f.Validate = findValidateRules(elem.Tag(i))
+		ctx.trySetFieldDocs(name, v.Name(), f)
 
 		s.Fields = append(s.Fields, f)
 	}
@@ -306,26 +714,107 @@ func scanStruct(s *Struct, elem *types.Struct) *Struct {
 }
 
 func scanFunc(fn *Func, signature *types.Signature) *Func {
+	return scanInlineFunc(nil, fn, "", signature)
+}
+
+// scanInlineFunc scans the func with the given name and signature like
+// scanFunc, scanning the inline types of its params and results as the given
+// ones if they are not nil.
+func scanInlineFunc(inline *inlineTypes, fn *Func, name string, signature *types.Signature) *Func {
 	if signature.Recv() != nil {
 		fn.Receiver = scanType(signature.Recv().Type())
 	}
-	fn.Input = scanTuple(signature.Params())
-	fn.Output = scanTuple(signature.Results())
+	fn.Input = scanInlineTuple(inline, name, "Param", signature.Params())
 	fn.IsVariadic = signature.Variadic()
 
+	results := signature.Results()
+	fn.Output = make([]Type, 0, results.Len())
+	for i := 0; i < results.Len(); i++ {
+		v, typ := results.At(i), results.At(i).Type()
+		if i == 0 {
+			if stream, elem := streamOf(typ); stream != NoStream {
+				fn.Stream, typ = stream, elem
+			}
+		}
+		fn.Output = append(fn.Output, scanInlineType(inline, typ, inlineTypeName(name, "Result", v, i)))
+	}
+
 	return fn
 }
 
+// streamOf returns the kind of stream of values of the given result type of
+// a func and the type of the values, if it is a receive or bidirectional
+// channel, an iter.Seq or an iter.Seq2 of values and errors.
+func streamOf(typ types.Type) (Stream, types.Type) {
+	if ch, ok := typ.(*types.Chan); ok && ch.Dir() != types.SendOnly {
+		return ChanStream, ch.Elem()
+	}
+
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "iter" {
+		return NoStream, nil
+	}
+
+	args := named.TypeArgs()
+	switch {
+	case named.Obj().Name() == "Seq" && args.Len() == 1:
+		return SeqStream, args.At(0)
+	case named.Obj().Name() == "Seq2" && args.Len() == 2 && isError(args.At(1)):
+		return SeqErrStream, args.At(0)
+	}
+	return NoStream, nil
+}
+
+func isError(typ types.Type) bool {
+	return types.Identical(typ, types.Universe.Lookup("error").Type())
+}
+
+func scanInterface(ctx *context, path, name string, elem *types.Interface) *Interface {
+	iface := &Interface{Name: name}
+	ctx.trySetDocs(name, iface)
+
+	for i := 0; i < elem.NumMethods(); i++ {
+		m := elem.Method(i)
+		if !m.Exported() {
+			continue
+		}
+
+		fn := scanFunc(&Func{Name: m.Name()}, m.Type().(*types.Signature))
+		fn.Receiver = NewNamed(path, name)
+		ctx.trySetMethodDocs(name, m.Name(), fn)
+		iface.Methods = append(iface.Methods, fn)
+	}
+
+	return iface
+}
+
 func scanTuple(tuple *types.Tuple) []Type {
+	return scanInlineTuple(nil, "", "", tuple)
+}
+
+// scanInlineTuple scans the params or results, which is the given kind, of
+// the func with the given name like scanTuple, scanning their inline types
+// as the given ones if they are not nil.
+func scanInlineTuple(inline *inlineTypes, fn, kind string, tuple *types.Tuple) []Type {
 	result := make([]Type, 0, tuple.Len())
 
 	for i := 0; i < tuple.Len(); i++ {
-		result = append(result, scanType(tuple.At(i).Type()))
+		v := tuple.At(i)
+		result = append(result, scanInlineType(inline, v.Type(), inlineTypeName(fn, kind, v, i)))
 	}
 
 	return result
 }
 
+func containsStruct(list []*types.Struct, s *types.Struct) bool {
+	for _, st := range list {
+		if st == s {
+			return true
+		}
+	}
+	return false
+}
+
 func findStruct(t types.Type) *types.Struct {
 	switch elem := t.(type) {
 	case *types.Pointer:
@@ -344,7 +833,15 @@ func findStruct(t types.Type) *types.Struct {
 // they will be added as enum values.
 // All values are guaranteed to be sorted by their iota.
 func newEnum(ctx *context, name string, vals []string, hasStringMethod bool) *Enum {
-	enum := &Enum{Name: name, IsStringer: hasStringMethod}
+	enum := &Enum{
+		Name:        name,
+		IsStringer:  hasStringMethod,
+		Naming:      ctx.typeOption(name, enumNamingComment),
+		Unspecified: ctx.typeOption(name, enumUnspecifiedComment),
+		Semantics:   ctx.typeOption(name, enumSemanticsComment),
+		File:        ctx.typeFiles[name],
+		Position:    ctx.position(name),
+	}
 	ctx.trySetDocs(name, enum)
 	var values enumValues
 	for _, v := range vals {
@@ -358,8 +855,14 @@ func newEnum(ctx *context, name string, vals []string, hasStringMethod bool) *En
 
 	sort.Stable(values)
 
-	for _, v := range values {
-		val := &EnumValue{Name: v.name}
+	enum.Integer = len(values) > 0
+	for i, v := range values {
+		val := &EnumValue{Name: v.name, Value: int64(i)}
+		if n, ok := ctx.enumNumbers[v.name]; ok {
+			val.Value = n
+		} else {
+			enum.Integer = false
+		}
 		ctx.trySetDocs(v.name, val)
 		enum.Values = append(enum.Values, val)
 	}
@@ -386,8 +889,198 @@ func (v enumValues) Less(i, j int) bool {
 	return v[i].pos < v[j].pos
 }
 
-func isIgnoredField(f *types.Var, tags []string) bool {
-	return !f.Exported() || (len(tags) > 0 && tags[0] == "-")
+// anyOption is the tag option to check that a field is represented with the
+// protobuf Any type.
+const anyOption = "any"
+
+// valueOption is the tag option to check that a field is represented with the
+// protobuf Value type.
+const valueOption = "value"
+
+// lazyOption is the tag option to mark a message field to be decoded lazily.
+const lazyOption = "lazy"
+
+// sensitiveOption is the tag option to mark a field whose values are
+// redacted in the logs.
+const sensitiveOption = "sensitive"
+
+// tagGogoType returns the option of the tags marking a field to be
+// represented with a type of github.com/gogo/protobuf/types and the name of
+// the type, or empty strings if it has none.
+func tagGogoType(tags []string) (string, string) {
+	switch {
+	case hasTagOption(tags, anyOption):
+		return anyOption, "Any"
+	case hasTagOption(tags, valueOption):
+		return valueOption, "Value"
+	}
+	return "", ""
+}
+
+// anyconvTypes are the names of the types of the anyconv package that hold
+// the values of the fields marked with the tag options, besides the types
+// of github.com/gogo/protobuf/types.
+var anyconvTypes = map[string]string{
+	anyOption:   "Any",
+	valueOption: "Value",
+}
+
+// tagTypes describes the types of the fields that can be marked with the
+// given tag option, whose type of github.com/gogo/protobuf/types has the
+// given name.
+func tagTypes(option, typ string) string {
+	desc := fmt.Sprintf("*types.%s of github.com/gogo/protobuf/types", typ)
+	if name, ok := anyconvTypes[option]; ok {
+		desc = fmt.Sprintf("anyconv.%s or %s", name, desc)
+	}
+	return desc
+}
+
+// scanArray returns the array type declared with the given name and
+// underlying type, or nil if it is not an array of booleans or numbers.
+func scanArray(ctx *context, name string, typ types.Type) *Array {
+	arr, ok := typ.(*types.Array)
+	if !ok {
+		return nil
+	}
+
+	elem, ok := arr.Elem().(*types.Basic)
+	if !ok || elem.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat) == 0 || elem.Kind() == types.Uintptr {
+		return nil
+	}
+
+	return &Array{
+		Name:     name,
+		Elem:     types.Typ[elem.Kind()].Name(),
+		Len:      int(arr.Len()),
+		Position: ctx.position(name),
+	}
+}
+
+// lazyPkg is the package of lazy.Message, whose named types are the
+// messages decoded lazily.
+const lazyPkg = "gitlab.com/ThatTomPerson/proteus/lazy"
+
+// scanLazy returns the lazy type with the given name and underlying type, if
+// it is a type of lazy.Message, like `type LazyReport lazy.Message[Report]`.
+// It reports whether it is one, and returns a nil lazy type with a warning if
+// its message is not a struct of the same package, as the conversion methods
+// generated for it are declared in the same file.
+func scanLazy(ctx *context, pkg *types.Package, name string, typ types.Type) (*Lazy, bool) {
+	s, ok := typ.(*types.Struct)
+	if !ok || s.NumFields() != 2 || removeGoPath(s.Field(0).Pkg()) != lazyPkg {
+		return nil, false
+	}
+
+	arr, ok := s.Field(1).Type().(*types.Array)
+	if !ok {
+		return nil, false
+	}
+
+	ptr, ok := arr.Elem().(*types.Pointer)
+	if !ok {
+		return nil, false
+	}
+
+	msg, ok := ptr.Elem().(*types.Named)
+	if ok {
+		_, ok = msg.Underlying().(*types.Struct)
+	}
+	if !ok || msg.Obj().Pkg() != pkg {
+		report.WarnAt(report.CodeUnsupportedType, ctx.position(name), name, "lazy type %s is ignored, its message %s must be a struct declared in the same package", name, ptr.Elem())
+		return nil, true
+	}
+
+	return &Lazy{
+		Name:     name,
+		Message:  NewNamed(removeGoPath(pkg), msg.Obj().Name()).(*Named),
+		Position: ctx.position(name),
+	}, true
+}
+
+// isInterface reports whether the type is an interface or a slice of
+// interfaces.
+func isInterface(typ types.Type) bool {
+	switch t := typ.(type) {
+	case *types.Slice:
+		typ = t.Elem()
+	case *types.Array:
+		typ = t.Elem()
+	}
+
+	_, ok := typ.Underlying().(*types.Interface)
+	return ok
+}
+
+// isGogoType reports whether the type is a pointer to the type with the given
+// name of github.com/gogo/protobuf/types, or a slice of them.
+func isGogoType(typ types.Type, name string) bool {
+	switch t := typ.(type) {
+	case *types.Slice:
+		typ = t.Elem()
+	case *types.Array:
+		typ = t.Elem()
+	}
+
+	ptr, ok := typ.(*types.Pointer)
+	if !ok {
+		return false
+	}
+
+	named, ok := ptr.Elem().(*types.Named)
+	if !ok {
+		return false
+	}
+
+	return removeGoPath(named.Obj().Pkg()) == "github.com/gogo/protobuf/types" && named.Obj().Name() == name
+}
+
+// anyconvPkg is the package holding the values of the Any and Value fields.
+const anyconvPkg = "gitlab.com/ThatTomPerson/proteus/anyconv"
+
+// isAnyconvType reports whether the type is the type with the given name of
+// the anyconv package, a pointer to it or a slice of them.
+func isAnyconvType(typ types.Type, name string) bool {
+	switch t := typ.(type) {
+	case *types.Slice:
+		typ = t.Elem()
+	case *types.Array:
+		typ = t.Elem()
+	}
+
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+
+	named, ok := typ.(*types.Named)
+	return ok && name != "" && removeGoPath(named.Obj().Pkg()) == anyconvPkg && named.Obj().Name() == name
+}
+
+// structOf describes the struct with the given name whose fields are added
+// to s, which is s itself unless they are the fields of a struct it embeds.
+func structOf(s *Struct, name string) string {
+	if name != "" && name != s.Name {
+		return fmt.Sprintf("struct %q embedded in struct %q", name, s.Name)
+	}
+	return fmt.Sprintf("struct %q", s.Name)
+}
+
+// embeddedName returns the name of the type of the embedded field if it is
+// declared in the same package as the field, otherwise it is empty.
+func embeddedName(v *types.Var) string {
+	typ := v.Type()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+
+	if named, ok := typ.(*types.Named); ok && named.Obj().Pkg() == v.Pkg() {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+func isSkippedField(tags []string) bool {
+	return len(tags) > 0 && tags[0] == "-"
 }
 
 func objectsInScope(scope *types.Scope) (objs []types.Object) {
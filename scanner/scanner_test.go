@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/report"
 )
 
 var gopath = os.Getenv("GOPATH")
@@ -93,6 +94,16 @@ func TestScanType(t *testing.T) {
 			types.NewInterface(nil, nil),
 			nil,
 		},
+		{
+			"set of basic",
+			types.NewMap(types.Typ[types.String], types.NewStruct(nil, nil)),
+			NewSet(NewBasic("string")),
+		},
+		{
+			"slice of sets",
+			types.NewSlice(types.NewMap(types.Typ[types.String], types.NewStruct(nil, nil))),
+			nil,
+		},
 		{
 			"map interface",
 			types.NewMap(types.Typ[types.String], &types.Interface{}),
@@ -105,6 +116,27 @@ func TestScanType(t *testing.T) {
 	}
 }
 
+func TestScanProtoMessage(t *testing.T) {
+	require := require.New(t)
+
+	pkg := types.NewPackage("github.com/foo/pb", "pb")
+	newType := func(name string, methods ...string) *types.Named {
+		typ := types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), types.NewStruct(nil, nil), nil)
+		recv := types.NewVar(token.NoPos, pkg, "m", types.NewPointer(typ))
+		for _, m := range methods {
+			sig := types.NewSignature(recv, nil, nil, false)
+			typ.AddMethod(types.NewFunc(token.NoPos, pkg, m, sig))
+		}
+		return typ
+	}
+
+	user := scanType(types.NewPointer(newType("User", "Reset", "String", "ProtoMessage")))
+	require.True(user.(*Named).Message)
+
+	group := scanType(newType("Group", "Reset", "String"))
+	require.False(group.(*Named).Message, "types without all the methods of proto.Message")
+}
+
 func TestScanStruct(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -154,6 +186,167 @@ func TestScanStruct(t *testing.T) {
 			&Struct{
 				Fields: []*Field{
 					{Name: "Foo", Type: NewBasic("int")},
+					{Name: "Bar", Reserved: true},
+				},
+			},
+		},
+		{
+			"struct with name and id tags",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Foo", types.Typ[types.Int], false),
+					mkField("Bar", types.Typ[types.String], false),
+					mkField("Baz", types.Typ[types.String], false),
+				},
+				[]string{`proteus:"name=foo_bar,id=7"`, `proteus:"id=19500"`, `proteus:"name=1baz"`},
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Foo", Type: NewBasic("int"), ProtoName: "foo_bar", ProtoID: 7},
+					{Name: "Bar", Type: NewBasic("string")},
+					{Name: "Baz", Type: NewBasic("string")},
+				},
+			},
+		},
+		{
+			"struct with validate tags",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Foo", types.Typ[types.String], false),
+					mkField("Bar", types.Typ[types.String], false),
+				},
+				[]string{`validate:"required, max=10" json:"foo"`, `validate:""`},
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Foo", Type: NewBasic("string"), JSONName: "foo", Validate: []string{"required", "max=10"}},
+					{Name: "Bar", Type: NewBasic("string")},
+				},
+			},
+		},
+		{
+			"struct with sync types",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Mutex", newNamedWithUnderlying("sync", "Mutex", types.NewStruct(nil, nil)), true),
+					mkField("Count", newNamedWithUnderlying("sync/atomic", "Int64", types.NewStruct(nil, nil)), false),
+					mkField("Lock", types.NewPointer(newNamedWithUnderlying("sync", "RWMutex", types.NewStruct(nil, nil))), false),
+					mkField("Foo", types.Typ[types.Int], false),
+				},
+				nil,
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Foo", Type: NewBasic("int")},
+				},
+			},
+		},
+		{
+			"struct with any fields",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Shapes", types.NewSlice(newNamedWithUnderlying("/foo", "Shape", types.NewInterface(nil, nil))), false),
+					mkField("Value", types.NewInterface(nil, nil), false),
+					mkField("Packed", types.NewPointer(newNamedWithUnderlying("github.com/gogo/protobuf/types", "Any", types.NewStruct(nil, nil))), false),
+					mkField("All", types.NewSlice(types.NewPointer(newNamedWithUnderlying("github.com/gogo/protobuf/types", "Any", types.NewStruct(nil, nil)))), false),
+					mkField("Wrapped", types.NewSlice(newNamedWithUnderlying("gitlab.com/ThatTomPerson/proteus/anyconv", "Any", types.NewStruct(nil, nil))), false),
+					mkField("Foo", types.Typ[types.Int], false),
+				},
+				[]string{`proteus:"any"`, `proteus:"any"`, `proteus:"any"`, `proteus:"any"`, `proteus:"any"`, `proteus:"any"`},
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Packed", Type: nullable(NewNamed("github.com/gogo/protobuf/types", "Any"))},
+					{Name: "All", Type: repeated(nullable(NewNamed("github.com/gogo/protobuf/types", "Any")))},
+					{Name: "Wrapped", Type: repeated(NewNamed("gitlab.com/ThatTomPerson/proteus/anyconv", "Any"))},
+				},
+			},
+		},
+		{
+			"struct with value fields",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Values", types.NewSlice(types.NewInterface(nil, nil)), false),
+					mkField("Value", types.NewInterface(nil, nil), false),
+					mkField("Any", types.NewPointer(newNamedWithUnderlying("github.com/gogo/protobuf/types", "Any", types.NewStruct(nil, nil))), false),
+					mkField("Packed", types.NewPointer(newNamedWithUnderlying("github.com/gogo/protobuf/types", "Value", types.NewStruct(nil, nil))), false),
+					mkField("Wrapped", newNamedWithUnderlying("gitlab.com/ThatTomPerson/proteus/anyconv", "Value", types.NewStruct(nil, nil)), false),
+					mkField("Foo", types.Typ[types.Int], false),
+				},
+				[]string{`proteus:"value"`, `proteus:"value"`, `proteus:"value"`, `proteus:"value"`, `proteus:"value"`, `proteus:"value"`},
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Packed", Type: nullable(NewNamed("github.com/gogo/protobuf/types", "Value"))},
+					{Name: "Wrapped", Type: NewNamed("gitlab.com/ThatTomPerson/proteus/anyconv", "Value")},
+				},
+			},
+		},
+		{
+			"struct with lazy fields",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Report", types.NewPointer(newNamedWithUnderlying("/foo", "Report", types.NewStruct(nil, nil))), false),
+					mkField("Foo", types.Typ[types.Int], false),
+				},
+				[]string{`proteus:"lazy"`, ""},
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Report", Type: nullable(NewNamed("/foo", "Report")), Lazy: true},
+					{Name: "Foo", Type: NewBasic("int")},
+				},
+			},
+		},
+		{
+			"struct with sensitive fields",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Password", types.Typ[types.String], false),
+					mkField("Foo", types.Typ[types.Int], false),
+				},
+				[]string{`proteus:"sensitive"`, ""},
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Password", Type: NewBasic("string"), Sensitive: true},
+					{Name: "Foo", Type: NewBasic("int")},
+				},
+			},
+		},
+		{
+			"struct with fields omitted from json",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Secret", types.Typ[types.String], false),
+					mkField("Token", types.Typ[types.String], false),
+					mkField("Foo", types.Typ[types.Int], false),
+				},
+				[]string{`json:"secret" proteus:"json=omit"`, `proteus:"json=skip"`, ""},
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Secret", Type: NewBasic("string"), JSONName: "secret", JSONOmit: true},
+					{Name: "Token", Type: NewBasic("string")},
+					{Name: "Foo", Type: NewBasic("int")},
+				},
+			},
+		},
+		{
+			"struct with units",
+			types.NewStruct(
+				[]*types.Var{
+					mkField("Timeout", types.Typ[types.Int64], false),
+					mkField("Size", types.Typ[types.Int], false),
+					mkField("Foo", types.Typ[types.Int], false),
+				},
+				[]string{`proteus:"unit=ms"`, `proteus:"unit=KiB,id=3"`, `proteus:"unit=m s"`},
+			),
+			&Struct{
+				Fields: []*Field{
+					{Name: "Timeout", Type: NewBasic("int64"), Unit: "ms"},
+					{Name: "Size", Type: NewBasic("int"), Unit: "KiB", ProtoID: 3},
+					{Name: "Foo", Type: NewBasic("int")},
 				},
 			},
 		},
@@ -272,8 +465,108 @@ func TestScanStruct(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		require.Equal(t, c.expected, scanStruct(&Struct{}, c.elem), c.name)
+		require.Equal(t, c.expected, scanStruct(&context{}, &Struct{}, c.elem), c.name)
+	}
+}
+
+func TestScanStructEmbeddingItself(t *testing.T) {
+	obj := types.NewTypeName(token.NoPos, nil, "Node", nil)
+	node := types.NewNamed(obj, nil, nil)
+	elem := types.NewStruct(
+		[]*types.Var{
+			mkField("Node", types.NewPointer(node), true),
+			mkField("Value", types.Typ[types.Int], false),
+		},
+		nil,
+	)
+	node.SetUnderlying(elem)
+
+	report.TestMode()
+	defer report.EndTestMode()
+
+	s := scanStruct(&context{}, &Struct{Name: "Node"}, elem)
+	require.Equal(t, []*Field{{Name: "Value", Type: NewBasic("int")}}, s.Fields)
+}
+
+func TestScanStructSyncFields(t *testing.T) {
+	pkg := types.NewPackage("/foo", "mock")
+	base := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "Base", nil), nil, nil)
+	base.SetUnderlying(types.NewStruct(
+		[]*types.Var{
+			types.NewField(token.NoPos, pkg, "Lock", newNamedWithUnderlying("sync", "RWMutex", types.NewStruct(nil, nil)), false),
+			types.NewField(token.NoPos, pkg, "ID", types.Typ[types.Int], false),
+		},
+		nil,
+	))
+	elem := types.NewStruct(
+		[]*types.Var{
+			types.NewField(token.NoPos, pkg, "Mutex", newNamedWithUnderlying("sync", "Mutex", types.NewStruct(nil, nil)), true),
+			types.NewField(token.NoPos, pkg, "Base", base, true),
+		},
+		nil,
+	)
+
+	report.TestMode()
+	defer report.EndTestMode()
+
+	s := scanStruct(&context{}, &Struct{Name: "Counter"}, elem)
+	require.Equal(t, []*Field{{Name: "ID", Type: NewBasic("int")}}, s.Fields)
+	require.Equal(t, []string{
+		`INFO: ignoring field "Mutex" of struct "Counter" with synchronization type sync.Mutex`,
+		`INFO: ignoring field "Lock" of struct "Base" embedded in struct "Counter" with synchronization type sync.RWMutex`,
+	}, report.MessageStack())
+}
+
+func TestScanStructFieldPolicy(t *testing.T) {
+	elem := types.NewStruct(
+		[]*types.Var{
+			mkField("Foo", types.Typ[types.Int], false),
+			mkField("Ch", types.NewChan(types.SendRecv, types.Typ[types.Int]), false),
+			mkField("Fn", types.NewSignature(nil, nil, nil, false), false),
+			mkField("Bar", types.Typ[types.String], false),
+		},
+		nil,
+	)
+
+	cases := []struct {
+		policy   FieldPolicy
+		expected []*Field
+	}{
+		{
+			SkipField,
+			[]*Field{
+				{Name: "Foo", Type: NewBasic("int")},
+				{Name: "Bar", Type: NewBasic("string")},
+			},
+		},
+		{
+			PlaceholderField,
+			[]*Field{
+				{Name: "Foo", Type: NewBasic("int")},
+				{Name: "Ch", Reserved: true},
+				{Name: "Fn", Reserved: true},
+				{Name: "Bar", Type: NewBasic("string")},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		ctx := &context{fieldPolicy: c.policy}
+		s := scanStruct(ctx, &Struct{Name: "Baz"}, elem)
+		require.Equal(t, c.expected, s.Fields, string(c.policy))
+		require.Equal(t, []string{"Baz.Ch", "Baz.Fn"}, ctx.unsupportedFields, string(c.policy))
+	}
+}
+
+func TestParseFieldPolicy(t *testing.T) {
+	for _, p := range []FieldPolicy{SkipField, FailOnField, PlaceholderField} {
+		policy, err := ParseFieldPolicy(string(p))
+		require.Nil(t, err)
+		require.Equal(t, p, policy)
 	}
+
+	_, err := ParseFieldPolicy("foo")
+	require.NotNil(t, err)
 }
 
 func TestScannerScanFunc(t *testing.T) {
@@ -405,115 +698,1162 @@ func TestScannerError(t *testing.T) {
 	require.Nil(os.RemoveAll(absPath("fixtures/error")))
 }
 
-func TestScanner(t *testing.T) {
-	require := require.New(t)
+const chanFile = `package chans
 
-	scanner, err := New(projectPkg("fixtures"), projectPkg("fixtures/subpkg"))
-	require.Nil(err)
+type Bar struct {
+	Foo  int
+	Done chan struct{}
+	Fn   func() error
+}
+`
 
-	pkgs, err := scanner.Scan()
-	require.Nil(err)
-	require.Equal(2, len(pkgs), "scan packages")
+const skipFile = `package skip
 
-	pkg := pkgs[0]
-	subpkg := pkgs[1]
+type Model struct {
+	ID int
+	//proteus:skip
+	Secret string
+}
 
-	require.Equal(5, len(pkg.Structs), "pkg")
-	assertStruct(t, findStructByName("Bar", pkg.Structs), "Bar", true, "Bar", "Baz")
-	assertStruct(t, findStructByName("Foo", pkg.Structs), "Foo", true, "Bar", "Baz", "IntList", "IntArray", "Map", "AliasedMap", "Timestamp", "External", "Duration", "Aliased")
-	assertStruct(t, findStructByName("Qux", pkg.Structs), "Qux", false, "A", "B")
-	assertStruct(t, findStructByName("Saz", pkg.Structs), "Saz", true, "Point", "Foo")
-	assertStruct(t, findStructByName("Jur", pkg.Structs), "Jur", false, "A")
+type Bar struct {
+	Model
+	Foo int ` + "`proteus:\"-\"`" + `
+	Bar int
+	Baz int //proteus:skip
+}
+`
 
-	require.Equal(3, len(subpkg.Structs), "subpkg")
-	assertStruct(t, findStructByName("MyContainer", subpkg.Structs), "MyContainer", false)
-	assertStruct(t, findStructByName("NotGenerated", subpkg.Structs), "NotGenerated", false)
-	assertStruct(t, findStructByName("Point", subpkg.Structs), "Point", true, "X", "Y")
+const positionsFile = `package positions
 
-	_, ok := pkg.Aliases[fmt.Sprintf("%s.%s", projectPath("fixtures"), "Baz")]
-	require.False(ok, "Baz should not be an alias anymore")
+//proteus:generate
+type Color int
 
-	require.Equal(1, len(pkg.Enums), "pkg enums")
-	require.Equal("Baz", pkg.Enums[0].Name)
+const (
+	Red Color = iota
+	Blue
+)
 
-	assertEnumValues(t, pkg.Enums[0].Values, "ABaz", "BBaz", "CBaz", "DBaz")
+//proteus:generate
+type User struct {
+	Name  string
+	Color Color ` + "`proteus:\"id=0\"`" + `
+}
 
-	require.Equal(0, len(pkg.Funcs), "pkg funcs")
-	require.Equal(4, len(subpkg.Funcs), "subpkg funcs")
-	assertFunc(t, findFuncByName("Generated", subpkg.Funcs), "Generated", "", []string{"string"}, []string{"bool", "error"}, false)
-	assertFunc(t, findFuncByName("GeneratedMethod", subpkg.Funcs), "GeneratedMethod", "Point", []string{"int32"}, []string{"Point"}, false)
-	assertFunc(t, findFuncByName("GeneratedMethodOnPointer", subpkg.Funcs), "GeneratedMethodOnPointer", "Point", []string{"bool"}, []string{"Point"}, false)
-	assertFunc(t, findFuncByName("Name", subpkg.Funcs), "Name", "MyContainer", []string{}, []string{"string"}, false)
+//proteus:generate
+func GetUser(name string) *User {
+	return nil
 }
+`
 
-func assertEnumValues(t *testing.T, values []*EnumValue, expected ...string) {
+func TestScannerPositions(t *testing.T) {
 	require := require.New(t)
-	require.Len(values, len(expected), "expected same enum values")
-	for i := range values {
-		require.Equal(expected[i], values[i].Name, "expected same enum value name")
-		require.Equal(fmt.Sprintf("%s ...", values[i].Name), strings.TrimSpace(strings.Join(values[i].Doc, "\n")))
-	}
 
-}
+	require.Nil(os.MkdirAll(absPath("fixtures/positions"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/positions/foo.go"), []byte(positionsFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/positions"))
 
-func findFuncByName(name string, fns []*Func) *Func {
-	for _, f := range fns {
-		if f.Name == name {
-			return f
-		}
-	}
+	scanner, err := New(projectPkg("fixtures/positions"))
+	require.Nil(err)
 
-	return nil
-}
+	report.TestMode()
+	defer report.EndTestMode()
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
 
-func findStructByName(name string, coll []*Struct) *Struct {
-	for _, s := range coll {
-		if s.Name == name {
-			return s
-		}
+	file := absPath("fixtures/positions/foo.go")
+	pos := func(line, column int) string {
+		return fmt.Sprintf("%s:%d:%d", file, line, column)
 	}
 
-	return nil
+	user := findStructByName("User", pkgs[0].Structs)
+	require.Equal(pos(12, 6), user.Position.String())
+	require.Equal(pos(13, 2), user.Fields[0].Position.String())
+	require.Equal(pos(14, 2), user.Fields[1].Position.String())
+	require.Equal(pos(4, 6), pkgs[0].Enums[0].Position.String())
+	require.Equal(pos(18, 6), pkgs[0].Funcs[0].Position.String())
+	require.Equal([]string{
+		fmt.Sprintf(`WARN: %s: field "Color" of struct "User" has an invalid proto id "0", ignoring it`, pos(14, 2)),
+	}, report.MessageStack())
 }
 
-func assertStruct(t *testing.T, s *Struct, name string, generate bool, fields ...string) {
-	require.Equal(
-		t,
-		name,
-		s.Name,
-		"struct name",
-	)
-	require.Equal(t, generate, s.Generate, "struct has been asked to be generated (before resolver)")
+func TestScannerSkippedFields(t *testing.T) {
+	require := require.New(t)
 
-	require.Equal(t, len(fields), len(s.Fields), "should have same struct fields")
-	for _, f := range fields {
-		require.True(t, s.HasField(f), "should have struct field %q", f)
-	}
+	require.Nil(os.MkdirAll(absPath("fixtures/skip"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/skip/foo.go"), []byte(skipFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/skip"))
 
-	doc := strings.TrimSpace(strings.Join(s.Doc, "\n"))
-	require.True(t, strings.HasPrefix(doc, s.Name), "Doc for %s starts with its name", s.Name)
-}
+	scanner, err := New(projectPkg("fixtures/skip"))
+	require.Nil(err)
 
-func assertFunc(t *testing.T, fn *Func, name string, recv string, input []string, result []string, variadic bool) {
-	require.Equal(t, name, fn.Name, "func name")
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
 
-	if fn.Receiver != nil {
-		require.Equal(t, recv, typeFrom(fn.Receiver), "receiver")
-	}
+	require.Equal([]*Field{
+		{Name: "ID", Type: NewBasic("int")},
+		{Name: "Secret", Reserved: true},
+		{Name: "Foo", Reserved: true},
+		{Name: "Bar", Type: NewBasic("int")},
+		{Name: "Baz", Reserved: true},
+	}, withoutPositions(findStructByName("Bar", pkgs[0].Structs).Fields))
+}
 
-	for idx, in := range fn.Input {
-		require.Equal(t, input[idx], typeFrom(in), fmt.Sprintf("input %d", idx))
-	}
+const serviceFile = `package service
 
-	for idx, out := range fn.Output {
-		require.Equal(t, result[idx], typeFrom(out), fmt.Sprintf("output %d", idx))
-	}
+type Thing struct {
+	Name string
+}
 
-	require.Equal(t, variadic, fn.IsVariadic, "is variadic")
-	require.Equal(t, fmt.Sprintf("%s ...", fn.Name), strings.TrimSpace(strings.Join(fn.Doc, "\n")))
+// Store stores things.
+//proteus:service
+type Store interface {
+	// Get returns a thing.
+	Get(name string) (*Thing, error)
+	Put(*Thing) error
 }
 
-func typeFrom(t Type) string {
+type NotGenerated interface {
+	Get(name string) (*Thing, error)
+}
+`
+
+func TestScannerInterfaces(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/service"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/service/foo.go"), []byte(serviceFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/service"))
+
+	scanner, err := New(projectPkg("fixtures/service"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+	require.Len(pkgs[0].Interfaces, 1)
+
+	iface := pkgs[0].Interfaces[0]
+	require.Equal("Store", iface.Name)
+	require.Equal([]string{"Store stores things."}, iface.Doc)
+	require.Len(iface.Methods, 2)
+	require.Equal("Get", iface.Methods[0].Name)
+	require.Equal([]string{"Get returns a thing."}, iface.Methods[0].Doc)
+	require.Equal(NewNamed(projectPkg("fixtures/service"), "Store"), iface.Methods[0].Receiver)
+	require.Equal("Put", iface.Methods[1].Name)
+}
+
+const httpFile = `package http
+
+type User struct {
+	Name string
+}
+
+// GetUser returns an user.
+//proteus:generate
+//proteus:http GET /v1/users/{name}
+func GetUser(name string) (*User, error) {
+	return nil, nil
+}
+
+//proteus:generate
+//proteus:http POST /v1/users
+func CreateUser(u *User) error {
+	return nil
+}
+
+//proteus:generate
+//proteus:http FETCH /v1/users
+func ListUsers() []*User {
+	return nil
+}
+`
+
+func TestScannerHTTPRules(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/http"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/http/foo.go"), []byte(httpFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/http"))
+
+	scanner, err := New(projectPkg("fixtures/http"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	var rules = make(map[string]*HTTPRule)
+	for _, fn := range pkgs[0].Funcs {
+		rules[fn.Name] = fn.HTTP
+	}
+
+	require.Equal(&HTTPRule{Method: "get", Path: "/v1/users/{name}"}, rules["GetUser"])
+	require.Equal(&HTTPRule{Method: "post", Path: "/v1/users", Body: "*"}, rules["CreateUser"])
+	require.Nil(rules["ListUsers"])
+}
+
+const enumNamingFile = `// Package naming has enums with naming strategies.
+//proteus:enum-naming strip
+package naming
+
+//proteus:generate
+type Color int
+
+const (
+	ColorRed Color = iota
+	ColorBlue
+)
+
+//proteus:generate
+//proteus:enum-naming prefix
+type Size int
+
+const (
+	Small Size = iota
+	Big
+)
+`
+
+func TestScannerEnumNaming(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/naming"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/naming/foo.go"), []byte(enumNamingFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/naming"))
+
+	scanner, err := New(projectPkg("fixtures/naming"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	var namings = make(map[string]string)
+	for _, e := range pkgs[0].Enums {
+		namings[e.Name] = e.Naming
+		require.Nil(e.Doc, "proteus comments are not docs")
+	}
+
+	require.Equal(map[string]string{"Color": "strip", "Size": "prefix"}, namings)
+}
+
+const fieldNamingFile = `// Package fieldnaming has structs named after their json tags.
+//proteus:field-naming json
+//proteus:package acme.users.v1
+//proteus:protobuf-api apiv2 acme/users/pb
+package fieldnaming
+
+//proteus:generate
+type User struct {
+	ID       int    ` + "`json:\"user_id\"`" + `
+	Name     string ` + "`json:\"name,omitempty\"`" + `
+	Email    string ` + "`json:\",omitempty\"`" + `
+	Password string ` + "`json:\"-\"`" + `
+	Nick     string ` + "`json:\"nick-name\"`" + `
+	Age      int
+}
+`
+
+func TestScannerFieldNaming(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/fieldnaming"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/fieldnaming/foo.go"), []byte(fieldNamingFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/fieldnaming"))
+
+	scanner, err := New(projectPkg("fixtures/fieldnaming"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+	require.Equal("json", pkgs[0].FieldNaming)
+	require.Equal("acme.users.v1", pkgs[0].ProtoPackage)
+	require.Equal("apiv2 acme/users/pb", pkgs[0].ProtobufAPI)
+
+	var names = make(map[string]string)
+	for _, f := range pkgs[0].Structs[0].Fields {
+		names[f.Name] = f.JSONName
+	}
+
+	require.Equal(map[string]string{
+		"ID":       "user_id",
+		"Name":     "name",
+		"Email":    "",
+		"Password": "",
+		"Nick":     "nick-name",
+		"Age":      "",
+	}, names)
+}
+
+const jsonCasingFile = `//proteus:json-casing snake
+package casing
+
+//proteus:generate
+type User struct {
+	Name string
+}
+
+//proteus:generate
+//proteus:json-casing go
+type Account struct {
+	Name string
+}
+`
+
+func TestScannerJSONCasing(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/casing"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/casing/foo.go"), []byte(jsonCasingFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/casing"))
+
+	scanner, err := New(projectPkg("fixtures/casing"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	var casings = make(map[string]string)
+	for _, s := range pkgs[0].Structs {
+		casings[s.Name] = s.JSONCasing
+		require.Nil(s.Doc, "proteus comments are not docs")
+	}
+
+	require.Equal(map[string]string{"User": "snake", "Account": "go"}, casings)
+}
+
+const constsFile = `package consts
+
+// MaxPageSize is the maximum size of a page.
+//proteus:generate
+const MaxPageSize = 100
+
+//proteus:generate
+const (
+	// DefaultRegion is the region used by default.
+	DefaultRegion        = "eu"
+	Ratio        float64 = 1.5
+	Enabled              = true
+	Huge                 = 1 << 70
+)
+
+const NotGenerated = 1
+
+//proteus:generate
+const Imaginary = 2i
+`
+
+func TestScannerConsts(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/consts"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/consts/foo.go"), []byte(constsFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/consts"))
+
+	scanner, err := New(projectPkg("fixtures/consts"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	var consts = make(map[string]*Const)
+	for _, c := range pkgs[0].Consts {
+		consts[c.Name] = c
+	}
+
+	require.Equal(map[string]*Const{
+		"MaxPageSize": {
+			Docs:  Docs{Doc: []string{"MaxPageSize is the maximum size of a page."}},
+			Name:  "MaxPageSize",
+			Kind:  IntConst,
+			Value: "100",
+		},
+		"DefaultRegion": {
+			Docs:  Docs{Doc: []string{"DefaultRegion is the region used by default."}},
+			Name:  "DefaultRegion",
+			Kind:  StringConst,
+			Value: "eu",
+		},
+		"Ratio":   {Name: "Ratio", Kind: FloatConst, Value: "1.5"},
+		"Enabled": {Name: "Enabled", Kind: BoolConst, Value: "true"},
+		"Huge":    {Name: "Huge", Kind: IntConst, Value: "1180591620717411303424"},
+	}, consts)
+}
+
+const deprecatedFile = `package deprecated
+
+// User is a user.
+//proteus:generate
+//proteus:deprecated use Account instead
+type User struct {
+	// Name is the name of the user.
+	//proteus:deprecated
+	Name string
+	Age  int
+}
+
+//proteus:generate
+type Status int
+
+const (
+	Active Status = iota
+	//proteus:deprecated no longer used
+	Banned
+)
+
+//proteus:generate
+//proteus:deprecated
+func GetUser() {}
+`
+
+func TestScannerDeprecated(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/deprecated"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/deprecated/foo.go"), []byte(deprecatedFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/deprecated"))
+
+	scanner, err := New(projectPkg("fixtures/deprecated"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+	pkg := pkgs[0]
+
+	user := pkg.Structs[0]
+	require.Equal(Docs{
+		Doc:        []string{"User is a user.", "", "Deprecated: use Account instead"},
+		Deprecated: true,
+	}, user.Docs)
+	require.Equal(Docs{Doc: []string{"Name is the name of the user."}, Deprecated: true}, user.Fields[0].Docs)
+	require.Equal(Docs{}, user.Fields[1].Docs)
+
+	values := pkg.Enums[0].Values
+	require.False(pkg.Enums[0].Deprecated)
+	require.False(values[0].Deprecated)
+	require.Equal(Docs{Doc: []string{"Deprecated: no longer used"}, Deprecated: true}, values[1].Docs)
+
+	require.Equal(Docs{Deprecated: true}, pkg.Funcs[0].Docs)
+}
+
+const concurrencyFile = `package concurrency
+
+//proteus:generate
+//proteus:max-concurrency 32
+func Expensive() {}
+
+//proteus:generate
+//proteus:max-concurrency lots
+func Invalid() {}
+
+//proteus:generate
+func Unlimited() {}
+`
+
+func TestScannerMaxConcurrency(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/concurrency"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/concurrency/foo.go"), []byte(concurrencyFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/concurrency"))
+
+	scanner, err := New(projectPkg("fixtures/concurrency"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	var limits = make(map[string]int)
+	for _, fn := range pkgs[0].Funcs {
+		limits[fn.Name] = fn.MaxConcurrency
+	}
+
+	require.Equal(map[string]int{"Expensive": 32, "Invalid": 0, "Unlimited": 0}, limits)
+}
+
+const idempotentFile = `package idempotent
+
+//proteus:generate
+//proteus:idempotent
+func Get() {}
+
+//proteus:generate
+func Create() {}
+`
+
+func TestScannerIdempotent(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/idempotent"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/idempotent/foo.go"), []byte(idempotentFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/idempotent"))
+
+	scanner, err := New(projectPkg("fixtures/idempotent"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	var idempotent = make(map[string]bool)
+	for _, fn := range pkgs[0].Funcs {
+		idempotent[fn.Name] = fn.Idempotent
+	}
+
+	require.Equal(map[string]bool{"Get": true, "Create": false}, idempotent)
+	require.Empty(pkgs[0].Funcs[0].Doc)
+}
+
+const streamFile = `package stream
+
+import (
+	"context"
+	"iter"
+)
+
+type Event struct {
+	ID int
+}
+
+//proteus:generate
+//proteus:stream-buffer 16 drop
+func Watch(ctx context.Context) <-chan *Event {
+	return nil
+}
+
+//proteus:generate
+//proteus:stream-buffer 4
+func List() iter.Seq[Event] {
+	return nil
+}
+
+//proteus:generate
+//proteus:stream-buffer lots
+func Read() (iter.Seq2[int, error], error) {
+	return nil, nil
+}
+
+//proteus:generate
+//proteus:stream-buffer 2
+func Get() *Event {
+	return nil
+}
+`
+
+func TestScannerStream(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/stream"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/stream/foo.go"), []byte(streamFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/stream"))
+
+	scanner, err := New(projectPkg("fixtures/stream"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	var (
+		streams = make(map[string]Stream)
+		buffers = make(map[string]StreamBuffer)
+		outputs = make(map[string][]string)
+	)
+	for _, fn := range pkgs[0].Funcs {
+		streams[fn.Name] = fn.Stream
+		buffers[fn.Name] = fn.StreamBuffer
+		for _, o := range fn.Output {
+			outputs[fn.Name] = append(outputs[fn.Name], o.String())
+		}
+	}
+
+	require.Equal(map[string]Stream{
+		"Watch": ChanStream,
+		"List":  SeqStream,
+		"Read":  SeqErrStream,
+		"Get":   NoStream,
+	}, streams)
+	require.Equal(map[string]StreamBuffer{
+		"Watch": {Size: 16, Drop: true},
+		"List":  {Size: 4},
+		"Read":  {},
+		"Get":   {},
+	}, buffers)
+
+	pkg := projectPkg("fixtures/stream")
+	require.Equal(map[string][]string{
+		"Watch": {pkg + ".Event"},
+		"List":  {pkg + ".Event"},
+		"Read":  {"int", "error"},
+		"Get":   {pkg + ".Event"},
+	}, outputs)
+	require.True(pkgs[0].Funcs[0].Output[0].IsNullable())
+}
+
+func TestScannerFieldPolicy(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/chans"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/chans/foo.go"), []byte(chanFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/chans"))
+
+	scanner, err := New(projectPkg("fixtures/chans"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+	require.Equal([]*Field{{Name: "Foo", Type: NewBasic("int")}}, withoutPositions(pkgs[0].Structs[0].Fields))
+
+	scanner.SetFieldPolicy(FailOnField)
+	_, err = scanner.Scan()
+	require.NotNil(err)
+	require.Contains(err.Error(), "Bar.Done, Bar.Fn")
+}
+
+const setFieldsFile = `package sets
+
+type Tags map[string]struct{}
+
+//proteus:generate
+type User struct {
+	Name  string
+	Roles map[string]struct{}
+	Tags  *Tags
+}
+
+type Group struct {
+	Name    string
+	Members map[int64]struct{}
+}
+
+//proteus:generate
+func Tag(tags map[string]struct{}, more Tags) map[string]struct{} {
+	return tags
+}
+`
+
+func TestScannerSetFields(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/sets"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/sets/foo.go"), []byte(setFieldsFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/sets"))
+
+	scanner, err := New(projectPkg("fixtures/sets"))
+	require.Nil(err)
+
+	_, err = scanner.Scan()
+	require.NotNil(err, "sets can not be fields of the structs to generate")
+	require.Contains(err.Error(), "User.Roles, User.Tags")
+	require.NotContains(err.Error(), "Group.Members")
+
+	require.Nil(ioutil.WriteFile(absPath("fixtures/sets/foo.go"), []byte(strings.Replace(setFieldsFile, "//proteus:generate\ntype User", "type User", 1)), 0777))
+	report.ResetSkipped()
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	var fields = make(map[string][]*Field)
+	for _, s := range pkgs[0].Structs {
+		fields[s.Name] = withoutPositions(s.Fields)
+	}
+	require.Equal([]*Field{{Name: "Name", Type: NewBasic("string")}}, fields["User"], "set fields of the rest of structs are ignored")
+	require.Equal([]*Field{{Name: "Name", Type: NewBasic("string")}}, fields["Group"])
+	require.Len(report.Skipped(), 3)
+	require.Equal([]Type{NewSet(NewBasic("string")), NewNamed(projectPkg("fixtures/sets"), "Tags")}, pkgs[0].Funcs[0].Input, "sets are kept in funcs")
+}
+
+const optionsFile = `//proteus:field-naming json
+//proteus:field-policy placeholder
+//proteus:enum-unspecified true
+package options
+
+//proteus:generate
+type User struct {
+	Name string
+	Done chan bool
+}
+
+//proteus:generate
+//proteus:field-naming keep
+//proteus:field-policy skip
+type Account struct {
+	Name string
+	Fn   func()
+}
+
+//proteus:generate
+type Color int
+
+const (
+	Red Color = iota + 1
+	Blue
+)
+
+//proteus:generate
+//proteus:enum-unspecified false
+//proteus:enum-semantics closed
+type Size int
+
+const (
+	Small Size = iota
+	Large
+)
+`
+
+func TestScannerOptionOverrides(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/options"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/options/foo.go"), []byte(optionsFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/options"))
+
+	scanner, err := New(projectPkg("fixtures/options"))
+	require.Nil(err)
+	scanner.SetFieldPolicy(FailOnField)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err, "package and type policies override the scanner's")
+	require.Equal("json", pkgs[0].FieldNaming)
+
+	var namings = make(map[string]string)
+	var fields = make(map[string][]*Field)
+	for _, s := range pkgs[0].Structs {
+		namings[s.Name] = s.FieldNaming
+		fields[s.Name] = withoutPositions(s.Fields)
+		require.Nil(s.Doc, "proteus comments are not docs")
+	}
+
+	require.Equal(map[string]string{"User": "json", "Account": "keep"}, namings)
+	require.Equal(map[string][]*Field{
+		"User": {
+			{Name: "Name", Type: NewBasic("string")},
+			{Name: "Done", Reserved: true},
+		},
+		"Account": {
+			{Name: "Name", Type: NewBasic("string")},
+		},
+	}, fields)
+
+	var unspecified = make(map[string]string)
+	var semantics = make(map[string]string)
+	for _, e := range pkgs[0].Enums {
+		unspecified[e.Name] = e.Unspecified
+		semantics[e.Name] = e.Semantics
+		require.True(e.Integer, e.Name)
+	}
+
+	require.Equal(map[string]string{"Color": "true", "Size": "false"}, unspecified)
+	require.Equal(map[string]string{"Color": "", "Size": "closed"}, semantics)
+}
+
+const boolSetsFile = `//proteus:bool-sets %s
+package boolsets
+
+//proteus:generate
+type User struct {
+	Flags   map[string]bool
+	Votes   map[int64]*bool
+	Counts  map[string]int
+	History []map[string]bool
+}
+
+type Flags map[string]bool
+
+//proteus:generate
+func Enabled(flags map[string]bool, all bool) map[string]bool {
+	return flags
+}
+`
+
+func TestScannerBoolSets(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/boolsets"), 0777))
+	defer os.RemoveAll(absPath("fixtures/boolsets"))
+
+	scan := func(directive string, enabled bool) *Package {
+		file := fmt.Sprintf(boolSetsFile, directive)
+		require.Nil(ioutil.WriteFile(absPath("fixtures/boolsets/foo.go"), []byte(file), 0777))
+
+		scanner, err := New(projectPkg("fixtures/boolsets"))
+		require.Nil(err)
+		scanner.SetBoolSets(enabled)
+
+		pkgs, err := scanner.Scan()
+		require.Nil(err)
+		return pkgs[0]
+	}
+
+	pkg := scan("true", false)
+	for _, f := range pkg.Structs[0].Fields {
+		require.IsType(&Map{}, f.Type, "fields are never sets")
+	}
+	require.Equal([]Type{NewBoolSet(NewBasic("string")), NewBasic("bool")}, pkg.Funcs[0].Input)
+	require.Equal([]Type{NewBoolSet(NewBasic("string"))}, pkg.Funcs[0].Output)
+	require.Equal(NewMap(NewBasic("string"), NewBasic("bool")), pkg.Aliases[projectPkg("fixtures/boolsets")+".Flags"], "declared types are not sets")
+
+	pkg = scan("false", true)
+	require.IsType(&Map{}, pkg.Funcs[0].Input[0], "directive overrides the scanner")
+
+	pkg = scan("invalid", true)
+	require.IsType(&Set{}, pkg.Funcs[0].Input[0], "invalid directives are ignored")
+}
+
+const funcOptionsFile = `package funcoptions
+
+type Option func(*options)
+
+type options struct {
+	limit int
+	tags  []string
+}
+
+func WithLimit(n int) Option {
+	return func(o *options) { o.limit = n }
+}
+
+func WithTags(tags ...string) Option {
+	return func(o *options) { o.tags = tags }
+}
+
+func Tagged(tags []string) Option {
+	return func(o *options) { o.tags = tags }
+}
+
+//proteus:generate
+//proteus:options WithLimit WithTags Tagged Missing
+func List(query string, opts ...Option) []string {
+	return nil
+}
+
+//proteus:generate
+//proteus:options WithLimit
+func Count(query string) int {
+	return 0
+}
+`
+
+func TestScannerFuncOptions(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/funcoptions"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/funcoptions/foo.go"), []byte(funcOptionsFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/funcoptions"))
+
+	scanner, err := New(projectPkg("fixtures/funcoptions"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	list := findFuncByName("List", pkgs[0].Funcs)
+	require.Equal([]Type{NewBasic("string")}, list.Input, "the variadic parameter is replaced by the options")
+	require.True(list.IsVariadic)
+	require.Equal([]*FuncOption{
+		{Name: "WithLimit", Field: "Limit", Type: nullable(NewBasic("int"))},
+		{Name: "Tagged", Field: "Tagged", Type: nullable(repeated(NewBasic("string")))},
+	}, list.FuncOptions, "invalid options are ignored")
+
+	count := findFuncByName("Count", pkgs[0].Funcs)
+	require.Nil(count.FuncOptions, "funcs that are not variadic have no options")
+	require.Len(count.Input, 1)
+}
+
+const arraysFile = `package arrays
+
+type Hash [32]byte
+
+type Point [3]float64
+
+type Pointers [2]*int
+
+type Names [2]string
+
+type Block struct {
+	Hash  Hash
+	Point Point
+}
+`
+
+func TestScannerArrays(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/arrays"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/arrays/foo.go"), []byte(arraysFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/arrays"))
+
+	scanner, err := New(projectPkg("fixtures/arrays"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	arrays := pkgs[0].Arrays
+	require.Len(arrays, 2, "only arrays of booleans and numbers are scanned")
+	require.Equal("Hash", arrays[0].Name)
+	require.Equal("uint8", arrays[0].Elem)
+	require.Equal(32, arrays[0].Len)
+	require.True(arrays[0].IsBytes())
+	require.Equal("Point", arrays[1].Name)
+	require.Equal("float64", arrays[1].Elem)
+	require.Equal(3, arrays[1].Len)
+	require.False(arrays[1].IsBytes())
+
+	block := findStructByName("Block", pkgs[0].Structs)
+	require.Equal(NewNamed(projectPkg("fixtures/arrays"), "Hash"), block.Fields[0].Type)
+	require.NotContains(pkgs[0].Aliases, projectPkg("fixtures/arrays")+".Hash", "arrays are not aliases")
+}
+
+const laziesFile = `package lazies
+
+import (
+	"time"
+
+	"gitlab.com/ThatTomPerson/proteus/lazy"
+)
+
+type Report struct {
+	Lines []string
+}
+
+type LazyReport lazy.Message[Report]
+
+type LazyTime lazy.Message[time.Time]
+
+type Summary struct {
+	Total  int64
+	Report *LazyReport
+}
+`
+
+func TestScannerLazies(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/lazies"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/lazies/foo.go"), []byte(laziesFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/lazies"))
+
+	scanner, err := New(projectPkg("fixtures/lazies"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	lazies := pkgs[0].Lazies
+	require.Len(lazies, 1, "only the messages of the same package can be lazy")
+	require.Equal("LazyReport", lazies[0].Name)
+	require.Equal(NewNamed(projectPkg("fixtures/lazies"), "Report"), lazies[0].Message)
+	require.Nil(findStructByName("LazyReport", pkgs[0].Structs), "lazy types are not structs")
+	require.Nil(findStructByName("LazyTime", pkgs[0].Structs))
+
+	summary := findStructByName("Summary", pkgs[0].Structs)
+	require.Equal(nullable(NewNamed(projectPkg("fixtures/lazies"), "LazyReport")), summary.Fields[1].Type)
+}
+
+func TestOptionField(t *testing.T) {
+	require.Equal(t, "Limit", optionField("WithLimit"))
+	require.Equal(t, "Tagged", optionField("Tagged"))
+	require.Equal(t, "With", optionField("With"))
+	require.Equal(t, "Limit", optionField("Withlimit"))
+}
+
+const closedSetsFile = `package closedsets
+
+type Shape interface {
+	Area() float64
+}
+
+type Circle struct{ Radius float64 }
+
+func (c Circle) Area() float64 { return 3.14 * c.Radius * c.Radius }
+
+type Square struct{ Side float64 }
+
+func (s *Square) Area() float64 { return s.Side * s.Side }
+
+type Triangle struct{ Base, Height float64 }
+
+func (t Triangle) Area() float64 { return t.Base * t.Height / 2 }
+
+type Color interface {
+	isColor()
+}
+
+type Red struct{}
+
+func (Red) isColor() {}
+
+type Blue struct{}
+
+func (Blue) isColor() {}
+
+type Named interface {
+	Name() string
+}
+
+type Dog struct{}
+
+func (Dog) Name() string { return "dog" }
+
+func Describe(s Shape, c Color, n Named) string {
+	switch s.(type) {
+	case Circle:
+		return "circle"
+	case *Square, nil:
+		return "square"
+	}
+
+	if _, ok := c.(Red); ok {
+		return "red"
+	}
+
+	if _, ok := c.(Blue); ok {
+		return "blue"
+	}
+
+	if _, ok := n.(Dog); ok {
+		return "dog"
+	}
+	return ""
+}
+`
+
+func TestScannerFindClosedInterfaces(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/closedsets"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/closedsets/foo.go"), []byte(closedSetsFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/closedsets"))
+
+	scanner, err := New(projectPkg("fixtures/closedsets"))
+	require.Nil(err)
+
+	ifaces, err := scanner.FindClosedInterfaces()
+	require.Nil(err)
+
+	pkg := projectPkg("fixtures/closedsets")
+	require.Equal([]*ClosedInterface{
+		{Package: pkg, Name: "Color", Types: []string{"Blue", "Red"}, Enum: true},
+		{Package: pkg, Name: "Shape", Types: []string{"Circle", "Square"}, Missing: []string{"Triangle"}},
+	}, ifaces, "interfaces with a single implementation told apart are not closed sets")
+}
+
+func TestScanner(t *testing.T) {
+	require := require.New(t)
+
+	scanner, err := New(projectPkg("fixtures"), projectPkg("fixtures/subpkg"))
+	require.Nil(err)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+	require.Equal(2, len(pkgs), "scan packages")
+
+	pkg := pkgs[0]
+	subpkg := pkgs[1]
+
+	require.Equal(5, len(pkg.Structs), "pkg")
+	assertStruct(t, findStructByName("Bar", pkg.Structs), "Bar", true, "Bar", "Baz")
+	assertStruct(t, findStructByName("Foo", pkg.Structs), "Foo", true, "Bar", "Baz", "IntList", "IntArray", "Map", "AliasedMap", "Timestamp", "External", "Duration", "Aliased")
+	assertStruct(t, findStructByName("Qux", pkg.Structs), "Qux", false, "A", "B")
+	assertStruct(t, findStructByName("Saz", pkg.Structs), "Saz", true, "Point", "Foo")
+	assertStruct(t, findStructByName("Jur", pkg.Structs), "Jur", false, "A")
+	require.Equal("foo.go", findStructByName("Foo", pkg.Structs).File)
+	require.Equal("bar.go", findStructByName("Bar", pkg.Structs).File)
+
+	require.Equal(3, len(subpkg.Structs), "subpkg")
+	assertStruct(t, findStructByName("MyContainer", subpkg.Structs), "MyContainer", false)
+	assertStruct(t, findStructByName("NotGenerated", subpkg.Structs), "NotGenerated", false)
+	assertStruct(t, findStructByName("Point", subpkg.Structs), "Point", true, "X", "Y")
+
+	_, ok := pkg.Aliases[fmt.Sprintf("%s.%s", projectPath("fixtures"), "Baz")]
+	require.False(ok, "Baz should not be an alias anymore")
+
+	require.Equal(1, len(pkg.Enums), "pkg enums")
+	require.Equal("Baz", pkg.Enums[0].Name)
+
+	assertEnumValues(t, pkg.Enums[0].Values, "ABaz", "BBaz", "CBaz", "DBaz")
+
+	require.Equal(0, len(pkg.Funcs), "pkg funcs")
+	require.Equal(4, len(subpkg.Funcs), "subpkg funcs")
+	assertFunc(t, findFuncByName("Generated", subpkg.Funcs), "Generated", "", []string{"string"}, []string{"bool", "error"}, false)
+	assertFunc(t, findFuncByName("GeneratedMethod", subpkg.Funcs), "GeneratedMethod", "Point", []string{"int32"}, []string{"Point"}, false)
+	assertFunc(t, findFuncByName("GeneratedMethodOnPointer", subpkg.Funcs), "GeneratedMethodOnPointer", "Point", []string{"bool"}, []string{"Point"}, false)
+	assertFunc(t, findFuncByName("Name", subpkg.Funcs), "Name", "MyContainer", []string{}, []string{"string"}, false)
+}
+
+func assertEnumValues(t *testing.T, values []*EnumValue, expected ...string) {
+	require := require.New(t)
+	require.Len(values, len(expected), "expected same enum values")
+	for i := range values {
+		require.Equal(expected[i], values[i].Name, "expected same enum value name")
+		require.Equal(int64(i), values[i].Value, "expected enum value to be its iota")
+		require.Equal(fmt.Sprintf("%s ...", values[i].Name), strings.TrimSpace(strings.Join(values[i].Doc, "\n")))
+	}
+
+}
+
+func findFuncByName(name string, fns []*Func) *Func {
+	for _, f := range fns {
+		if f.Name == name {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// withoutPositions clears the positions of the given fields, so they can be
+// compared with the expected ones.
+func withoutPositions(fields []*Field) []*Field {
+	for _, f := range fields {
+		f.Position = token.Position{}
+	}
+	return fields
+}
+
+func findStructByName(name string, coll []*Struct) *Struct {
+	for _, s := range coll {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func assertStruct(t *testing.T, s *Struct, name string, generate bool, fields ...string) {
+	require.Equal(
+		t,
+		name,
+		s.Name,
+		"struct name",
+	)
+	require.Equal(t, generate, s.Generate, "struct has been asked to be generated (before resolver)")
+
+	require.Equal(t, len(fields), len(s.Fields), "should have same struct fields")
+	for _, f := range fields {
+		require.True(t, s.HasField(f), "should have struct field %q", f)
+	}
+
+	doc := strings.TrimSpace(strings.Join(s.Doc, "\n"))
+	require.True(t, strings.HasPrefix(doc, s.Name), "Doc for %s starts with its name", s.Name)
+}
+
+func assertFunc(t *testing.T, fn *Func, name string, recv string, input []string, result []string, variadic bool) {
+	require.Equal(t, name, fn.Name, "func name")
+
+	if fn.Receiver != nil {
+		require.Equal(t, recv, typeFrom(fn.Receiver), "receiver")
+	}
+
+	for idx, in := range fn.Input {
+		require.Equal(t, input[idx], typeFrom(in), fmt.Sprintf("input %d", idx))
+	}
+
+	for idx, out := range fn.Output {
+		require.Equal(t, result[idx], typeFrom(out), fmt.Sprintf("output %d", idx))
+	}
+
+	require.Equal(t, variadic, fn.IsVariadic, "is variadic")
+	require.Equal(t, fmt.Sprintf("%s ...", fn.Name), strings.TrimSpace(strings.Join(fn.Doc, "\n")))
+}
+
+func typeFrom(t Type) string {
 	switch t.(type) {
 	case *Named:
 		return t.(*Named).Name
@@ -570,3 +1910,99 @@ func projectPkg(pkg string) string {
 func absPath(path string) string {
 	return filepath.Join(goPath, "src", project, path)
 }
+
+const inlineFile = `package inline
+
+//proteus:generate
+type User struct {
+	Name string
+}
+
+// Page is a page of items.
+type Page[T any] struct {
+	Items []T
+	Next  string
+}
+
+// CreateUser creates an user.
+//proteus:generate
+var CreateUser = func(req struct{ Name string }) (*struct{ ID string }, error) {
+	return nil, nil
+}
+
+//proteus:generate
+func ListUsers(token string) (Page[User], error) {
+	return Page[User]{}, nil
+}
+
+//proteus:generate
+func ListNames(token string) (Page[*User], *Page[User], error) {
+	return Page[*User]{}, nil, nil
+}
+
+var notGenerated = func(struct{ Name string }) {}
+`
+
+func TestScannerInlineTypes(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(os.MkdirAll(absPath("fixtures/inline"), 0777))
+	require.Nil(ioutil.WriteFile(absPath("fixtures/inline/foo.go"), []byte(inlineFile), 0777))
+	defer os.RemoveAll(absPath("fixtures/inline"))
+
+	path := projectPkg("fixtures/inline")
+	inline := func(name string, nullable bool) Type {
+		t := NewNamed(path, name)
+		t.(*Named).Inline = true
+		t.SetNullable(nullable)
+		return t
+	}
+
+	scanner, err := New(path)
+	require.Nil(err)
+	scanner.SetInlineTypes(true)
+
+	pkgs, err := scanner.Scan()
+	require.Nil(err)
+
+	pkg := pkgs[0]
+	require.Len(pkg.Funcs, 3)
+	funcs := make(map[string]*Func)
+	for _, fn := range pkg.Funcs {
+		funcs[fn.Name] = fn
+	}
+
+	require.Equal([]string{"CreateUser creates an user."}, funcs["CreateUser"].Doc)
+	require.Equal([]Type{inline("CreateUserReq", false)}, funcs["CreateUser"].Input)
+	require.Equal([]Type{inline("CreateUserResult1", true), NewNamed("", "error")}, funcs["CreateUser"].Output)
+	require.Equal([]Type{inline("PageUser", false), NewNamed("", "error")}, funcs["ListUsers"].Output)
+	require.Equal([]Type{nil, inline("PageUser", true), NewNamed("", "error")}, funcs["ListNames"].Output)
+
+	structs := make(map[string]*Struct)
+	for _, s := range pkg.Structs {
+		structs[s.Name] = s
+	}
+	require.Len(structs, 5)
+
+	for _, name := range []string{"CreateUserReq", "CreateUserResult1", "PageUser"} {
+		require.True(structs[name].Inline, name)
+		require.True(structs[name].Generate, name)
+	}
+
+	page := structs["PageUser"]
+	require.Equal([]string{"Page is a page of items."}, page.Doc)
+	require.Equal("foo.go", page.File)
+	require.Len(page.Fields, 2)
+	items := NewNamed(path, "User")
+	items.SetRepeated(true)
+	require.Equal(items, page.Fields[0].Type)
+	require.Equal("Next", page.Fields[1].Name)
+	require.False(structs["User"].Inline)
+
+	scanner, err = New(path)
+	require.Nil(err)
+	pkgs, err = scanner.Scan()
+	require.Nil(err)
+	require.Len(pkgs[0].Funcs, 2)
+	require.Len(pkgs[0].Structs, 2)
+}
@@ -1,8 +1,12 @@
 package scanner
 
 import (
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/report"
 )
 
 var protoTagRegex = regexp.MustCompile(`proteus:"([^"]+)"`)
@@ -18,3 +22,92 @@ func findProtoTags(tag string) []string {
 	}
 	return tags
 }
+
+// findValidateRules returns the rules in the validate tag, that is,
+// `validate:"required,max=10"`.
+func findValidateRules(tag string) []string {
+	var rules []string
+	for _, r := range strings.Split(reflect.StructTag(tag).Get("validate"), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// findJSONName returns the name in the json tag, that is,
+// `json:"fooBar,omitempty"`, unless the field is skipped with "-".
+func findJSONName(tag string) string {
+	name := strings.Split(reflect.StructTag(tag).Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// jsonOmit is the value of the json option of the proteus tag of the fields
+// left out of the JSON encoding.
+const jsonOmit = "omit"
+
+var protoNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// unitRegex matches the units of the fields, like ms, bytes, KiB or m/s.
+var unitRegex = regexp.MustCompile(`^[A-Za-z%][A-Za-z0-9_%/]*$`)
+
+const (
+	maxFieldID         = 1<<29 - 1
+	firstReservedRange = 19000
+	lastReservedRange  = 19999
+)
+
+// setFieldTags sets the proto name, id, JSON encoding and unit of the field
+// from the options in its proteus tag, that is, `proteus:"name=foo_bar,id=7"`,
+// `proteus:"json=omit"` or `proteus:"unit=ms"`. Invalid options are ignored
+// with a warning.
+func setFieldTags(structName string, f *Field, tags []string) {
+	for _, t := range tags {
+		kv := strings.SplitN(t, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key, val := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "name":
+			if !protoNameRegex.MatchString(val) {
+				report.WarnAt(report.CodeInvalidTag, f.Position, structName+"."+f.Name, "field %q of struct %q has an invalid proto name %q, ignoring it", f.Name, structName, val)
+				continue
+			}
+			f.ProtoName = val
+		case "id":
+			id, err := strconv.Atoi(val)
+			if err != nil || id < 1 || id > maxFieldID || (id >= firstReservedRange && id <= lastReservedRange) {
+				report.WarnAt(report.CodeInvalidTag, f.Position, structName+"."+f.Name, "field %q of struct %q has an invalid proto id %q, ignoring it", f.Name, structName, val)
+				continue
+			}
+			f.ProtoID = id
+		case "json":
+			if val != jsonOmit {
+				report.WarnAt(report.CodeInvalidTag, f.Position, structName+"."+f.Name, "field %q of struct %q has an invalid json option %q, only %q is supported, ignoring it", f.Name, structName, val, jsonOmit)
+				continue
+			}
+			f.JSONOmit = true
+		case "unit":
+			if !unitRegex.MatchString(val) {
+				report.WarnAt(report.CodeInvalidTag, f.Position, structName+"."+f.Name, "field %q of struct %q has an invalid unit %q, ignoring it", f.Name, structName, val)
+				continue
+			}
+			f.Unit = val
+		}
+	}
+}
+
+// hasTagOption reports whether the given option is in the tags.
+func hasTagOption(tags []string, option string) bool {
+	for _, t := range tags {
+		if t == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package schemahash // import "gitlab.com/ThatTomPerson/proteus/schemahash"
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// FileName is the name of the file generated in every package.
+const FileName = "proteus_schema.go"
+
+// Suffix is the suffix of the name of the constants, which are named after
+// their struct, e.g. UserSchemaHash.
+const Suffix = "SchemaHash"
+
+// Generator generates a constant with the schema hash of every message of a
+// package generated from one of its structs, so the caches and stores of
+// serialized messages can tell the ones serialized with another schema
+// apart, e.g. by having the hash in their keys.
+//
+// The hash is the SHA-256 of the name, number, label, type and options of
+// the fields of the message, and of the ones of all the messages it has,
+// directly or through other messages, along with the values of the enums
+// they have, so it changes whenever the encoding of the message may change.
+// Docs and reserved numbers are not part of it. The messages of packages
+// that are not generated in the same run are only part of it by name.
+//
+// Structs with the constant already declared are skipped with a warning.
+// The file will be written to the package path and it will be named
+// "proteus_schema.go".
+type Generator struct {
+	types map[string]interface{}
+}
+
+// NewGenerator creates a new Generator with the given protobuf packages,
+// whose messages and enums are part of the hashes of the messages that have
+// them.
+func NewGenerator(pkgs []*protobuf.Package) *Generator {
+	types := make(map[string]interface{})
+	for _, pkg := range pkgs {
+		for _, msg := range pkg.Messages {
+			types[fullName(pkg.Name, msg.Name)] = msg
+		}
+
+		for _, e := range pkg.Enums {
+			types[fullName(pkg.Name, e.Name)] = e
+		}
+	}
+	return &Generator{types: types}
+}
+
+// Generate writes the schema hash constants of the messages of the given
+// protobuf package to its Go package. Nothing is written if none of its
+// messages is generated from one of its structs. It reports whether the file
+// was written.
+func (g *Generator) Generate(p *scanner.Package, pkg *protobuf.Package) (bool, error) {
+	structs := make(map[string]bool)
+	for _, s := range p.Structs {
+		structs[s.Name] = true
+	}
+
+	var msgs []*protobuf.Message
+	for _, msg := range pkg.Messages {
+		if structs[msg.Name] {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	if len(msgs) == 0 {
+		return false, nil
+	}
+
+	declared, err := findDecls(filepath.Join(goSrc, p.Path))
+	if err != nil {
+		return false, err
+	}
+
+	var hashed []*protobuf.Message
+	for _, msg := range msgs {
+		if declared[msg.Name+Suffix] {
+			report.Warn("struct %s already has a %s%s declaration, no schema hash is generated for it", msg.Name, msg.Name, Suffix)
+			continue
+		}
+		hashed = append(hashed, msg)
+	}
+
+	if len(hashed) == 0 {
+		return false, nil
+	}
+
+	data, err := g.buildFile(p, pkg, hashed)
+	if err != nil {
+		return false, err
+	}
+
+	file := g.FileName(p.Path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return false, err
+	}
+
+	report.Info("Generated schema hashes: %s", file)
+	return true, nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, FileName)
+}
+
+func (g *Generator) buildFile(p *scanner.Package, pkg *protobuf.Package, msgs []*protobuf.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(p.Path)))
+	buf.WriteString(fmt.Sprintf("package %s\n\n", p.Name))
+	buf.WriteString("// Schema hashes of the messages generated from the structs of the package,\n")
+	buf.WriteString("// which change whenever the encoding of the message may change.\n")
+	buf.WriteString("const (\n")
+	for i, msg := range msgs {
+		if i > 0 {
+			buf.WriteRune('\n')
+		}
+
+		name := fullName(pkg.Name, msg.Name)
+		buf.WriteString(fmt.Sprintf("\t// %s%s is the schema hash of %s.\n", msg.Name, Suffix, name))
+		buf.WriteString(fmt.Sprintf("\t%s%s = %q\n", msg.Name, Suffix, g.Hash(pkg, msg)))
+	}
+	buf.WriteString(")\n")
+	return format.Source(buf.Bytes())
+}
+
+// Hash returns the schema hash of the given message of the package, as an
+// hexadecimal string.
+func (g *Generator) Hash(pkg *protobuf.Package, msg *protobuf.Message) string {
+	name := fullName(pkg.Name, msg.Name)
+	schemas := map[string]string{name: g.describeMessage(pkg.Name, msg)}
+	g.collect(pkg.Name, msg, schemas)
+
+	names := make([]string, 0, len(schemas))
+	for n := range schemas {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	h.Write([]byte(name + "\n"))
+	for _, n := range names {
+		h.Write([]byte(schemas[n]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// collect adds the schemas of the messages and enums of the packages of the
+// generator that the given message of the package has, directly or through
+// other messages, to the given schemas, indexed by their full name.
+func (g *Generator) collect(pkg string, msg *protobuf.Message, schemas map[string]string) {
+	for _, f := range msg.Fields {
+		for _, n := range namedTypes(f.Type) {
+			name := namedName(pkg, n)
+			if _, ok := schemas[name]; ok {
+				continue
+			}
+
+			switch t := g.types[name].(type) {
+			case *protobuf.Message:
+				schemas[name] = g.describeMessage(packageOf(name), t)
+				g.collect(packageOf(name), t, schemas)
+			case *protobuf.Enum:
+				schemas[name] = describeEnum(name, t)
+			}
+		}
+	}
+}
+
+// describeMessage returns the description of the schema of the given
+// message of the package, which is what is hashed.
+func (g *Generator) describeMessage(pkg string, msg *protobuf.Message) string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("message %s\n", fullName(pkg, msg.Name)))
+	for _, f := range msg.Fields {
+		var label string
+		if f.Repeated {
+			label = "repeated "
+		} else if f.Optional {
+			label = "optional "
+		}
+
+		buf.WriteString(fmt.Sprintf("%d %s%s %s", f.Pos, label, typeName(pkg, f.Type), f.Name))
+		for _, opt := range f.Options.Sorted() {
+			buf.WriteString(fmt.Sprintf(" %s=%s", opt.Name, opt.Value))
+		}
+		buf.WriteRune('\n')
+	}
+	return buf.String()
+}
+
+// describeEnum returns the description of the schema of the enum with the
+// given full name.
+func describeEnum(name string, e *protobuf.Enum) string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("enum %s\n", name))
+	for _, v := range e.Values {
+		buf.WriteString(fmt.Sprintf("%d %s\n", v.Value, v.Name))
+	}
+	return buf.String()
+}
+
+// typeName returns the name of the given type used in the package, with the
+// full name of the named types.
+func typeName(pkg string, typ protobuf.Type) string {
+	switch t := underlying(typ).(type) {
+	case *protobuf.Map:
+		return fmt.Sprintf("map<%s, %s>", typeName(pkg, t.Key), typeName(pkg, t.Value))
+	case *protobuf.Named:
+		return namedName(pkg, t)
+	case nil:
+		return ""
+	}
+	return underlying(typ).String()
+}
+
+// namedTypes returns the named types in the given type.
+func namedTypes(typ protobuf.Type) []*protobuf.Named {
+	switch t := underlying(typ).(type) {
+	case *protobuf.Map:
+		return append(namedTypes(t.Key), namedTypes(t.Value)...)
+	case *protobuf.Named:
+		return []*protobuf.Named{t}
+	}
+	return nil
+}
+
+// namedName returns the full protobuf name of the given named type used in
+// the package.
+func namedName(pkg string, n *protobuf.Named) string {
+	if n.Package == "" {
+		return fullName(pkg, n.Name)
+	}
+	return fullName(n.Package, n.Name)
+}
+
+func underlying(typ protobuf.Type) protobuf.Type {
+	if a, ok := typ.(*protobuf.Alias); ok {
+		return underlying(a.Underlying)
+	}
+	return typ
+}
+
+func fullName(pkg, name string) string {
+	return pkg + "." + name
+}
+
+func packageOf(name string) string {
+	return name[:strings.LastIndex(name, ".")]
+}
+
+// findDecls returns the names of the top-level declarations in the Go files
+// of the given folder, but the generated one.
+func findDecls(dir string) (map[string]bool, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return fi.Name() != FileName && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for name := range file.Scope.Objects {
+				names[name] = true
+			}
+		}
+	}
+	return names, nil
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
@@ -0,0 +1,140 @@
+package schemahash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+func testPackages() []*protobuf.Package {
+	return []*protobuf.Package{
+		{
+			Name: "foo",
+			Path: "gitlab.com/foo",
+			Messages: []*protobuf.Message{
+				{Name: "User", Fields: []*protobuf.Field{
+					{Name: "id", Pos: 1, Type: protobuf.NewBasic("int64")},
+					{Name: "address", Pos: 2, Type: protobuf.NewNamed("bar", "Address")},
+					{Name: "status", Pos: 3, Type: protobuf.NewNamed("foo", "Status")},
+					{Name: "friends", Pos: 4, Repeated: true, Type: protobuf.NewNamed("foo", "User")},
+				}},
+				{Name: "Group", Fields: []*protobuf.Field{
+					{Name: "id", Pos: 1, Type: protobuf.NewBasic("int64")},
+				}},
+				{Name: "GetUserRequest", Fields: []*protobuf.Field{
+					{Name: "arg1", Pos: 1, Type: protobuf.NewBasic("int64")},
+				}},
+			},
+			Enums: []*protobuf.Enum{
+				{Name: "Status", Values: []*protobuf.EnumValue{{Name: "ACTIVE", Value: 0}}},
+			},
+		},
+		{
+			Name: "bar",
+			Path: "gitlab.com/bar",
+			Messages: []*protobuf.Message{
+				{Name: "Address", Fields: []*protobuf.Field{
+					{Name: "city", Pos: 1, Type: protobuf.NewBasic("string")},
+					{Name: "tags", Pos: 2, Type: protobuf.NewMap(protobuf.NewBasic("string"), protobuf.NewNamed("bar", "Tag"))},
+				}},
+				{Name: "Tag", Fields: []*protobuf.Field{
+					{Name: "name", Pos: 1, Type: protobuf.NewBasic("string")},
+				}},
+			},
+		},
+	}
+}
+
+func TestHash(t *testing.T) {
+	require := require.New(t)
+
+	pkgs := testPackages()
+	foo, bar := pkgs[0], pkgs[1]
+	user, group := foo.Messages[0], foo.Messages[1]
+	hash := NewGenerator(pkgs).Hash(foo, user)
+	require.Regexp(regexp.MustCompile("^[0-9a-f]{64}$"), hash)
+	require.Equal(hash, NewGenerator(testPackages()).Hash(testPackages()[0], testPackages()[0].Messages[0]), "hashes are stable")
+	require.NotEqual(NewGenerator(pkgs).Hash(foo, group), NewGenerator(pkgs).Hash(foo, foo.Messages[2]), "messages with the same fields have other hashes")
+
+	changes := map[string]func(){
+		"field number": func() { user.Fields[0].Pos = 5 },
+		"field type":   func() { user.Fields[0].Type = protobuf.NewBasic("int32") },
+		"field label":  func() { user.Fields[0].Repeated = true },
+		"field name":   func() { user.Fields[0].Name = "key" },
+		"field option": func() {
+			user.Fields[0].Options = protobuf.Options{"(gogoproto.nullable)": protobuf.NewLiteralValue("false")}
+		},
+		"nested message":        func() { bar.Messages[0].Fields[0].Pos = 3 },
+		"message through a map": func() { bar.Messages[1].Fields[0].Type = protobuf.NewBasic("bytes") },
+		"enum value":            func() { foo.Enums[0].Values[0].Value = 1 },
+	}
+	for name, change := range changes {
+		pkgs = testPackages()
+		foo, bar = pkgs[0], pkgs[1]
+		user = foo.Messages[0]
+		change()
+		require.NotEqual(hash, NewGenerator(pkgs).Hash(foo, user), name)
+	}
+
+	pkgs = testPackages()
+	pkgs[0].Messages[0].Docs = []string{"User is an user."}
+	pkgs[0].Messages[0].Reserved = []uint{8}
+	require.Equal(hash, NewGenerator(pkgs).Hash(pkgs[0], pkgs[0].Messages[0]), "docs and reserved numbers are not part of the hash")
+
+	pkgs = testPackages()
+	require.NotEqual(hash, NewGenerator(pkgs[:1]).Hash(pkgs[0], pkgs[0].Messages[0]), "messages of other runs are only hashed by name")
+}
+
+func TestBuildFile(t *testing.T) {
+	require := require.New(t)
+
+	pkgs := testPackages()
+	g := NewGenerator(pkgs)
+	p := &scanner.Package{Name: "foo", Path: "gitlab.com/foo"}
+	data, err := g.buildFile(p, pkgs[0], pkgs[0].Messages[:2])
+	require.NoError(err)
+
+	expected := `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo
+
+// Schema hashes of the messages generated from the structs of the package,
+// which change whenever the encoding of the message may change.
+const (
+	// UserSchemaHash is the schema hash of foo.User.
+	UserSchemaHash = "` + g.Hash(pkgs[0], pkgs[0].Messages[0]) + `"
+
+	// GroupSchemaHash is the schema hash of foo.Group.
+	GroupSchemaHash = "` + g.Hash(pkgs[0], pkgs[0].Messages[1]) + `"
+)
+`
+	require.Equal(expected, string(data))
+}
+
+func TestFindDecls(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-schemahash")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"user.go":  "package foo\n\ntype User struct{}\n\nconst UserSchemaHash = \"v1\"\n",
+		"group.go": "package foo\n\ntype Group struct{}\n\nfunc (Group) GroupSchemaHash() string { return \"\" }\n",
+		FileName:   "package foo\n\nconst GroupSchemaHash = \"\"\n",
+	}
+	for name, content := range files {
+		require.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	declared, err := findDecls(dir)
+	require.NoError(err)
+	require.True(declared["UserSchemaHash"])
+	require.False(declared["GroupSchemaHash"], "methods and the generated file are not taken into account")
+}
@@ -0,0 +1,137 @@
+package proteus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// Scope is the set of packages generated in a run limited with Options.Only.
+type Scope struct {
+	packages map[string]bool
+}
+
+// Has reports whether the package at the given path is in the scope.
+func (s *Scope) Has(pkg string) bool {
+	return s.packages[pkg]
+}
+
+// Packages returns the paths of the packages in the scope, sorted.
+func (s *Scope) Packages() []string {
+	var pkgs = make([]string, 0, len(s.packages))
+	for p := range s.packages {
+		pkgs = append(pkgs, p)
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// newScope returns the scope of the given packages or types, like
+// github.com/acme/app/user.User, which has their packages and the packages
+// of the types that depend on them, directly or through other types, and of
+// the services that use them. pkgs and protos are all the scanned packages
+// and the protobuf packages they were transformed to, in the same order.
+func newScope(only []string, pkgs []*scanner.Package, protos []*protobuf.Package) (*Scope, error) {
+	var (
+		paths    = make(map[string]string)
+		types    = make(map[string][]string)
+		affected = make(map[string]bool)
+		scope    = &Scope{packages: make(map[string]bool)}
+		queue    []string
+	)
+
+	for i, p := range pkgs {
+		paths[protos[i].Name] = p.Path
+		types[p.Path] = nil
+		for _, s := range p.Structs {
+			types[p.Path] = append(types[p.Path], p.Path+"."+s.Name)
+		}
+
+		for _, e := range p.Enums {
+			types[p.Path] = append(types[p.Path], p.Path+"."+e.Name)
+		}
+	}
+
+	for _, o := range only {
+		if pkgTypes, ok := types[o]; ok {
+			scope.packages[o] = true
+			queue = append(queue, pkgTypes...)
+			continue
+		}
+
+		idx := strings.LastIndex(o, ".")
+		if idx < 0 || !containsString(types[o[:idx]], o) {
+			return nil, fmt.Errorf("%q is not a generated package or type", o)
+		}
+		queue = append(queue, o)
+	}
+
+	dependents := make(map[string][]string)
+	for i, p := range pkgs {
+		for _, m := range protos[i].Messages {
+			name := p.Path + "." + m.Name
+			for _, f := range m.Fields {
+				for _, dep := range namedTypes(f.Type, paths) {
+					dependents[dep] = append(dependents[dep], name)
+				}
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if affected[t] {
+			continue
+		}
+
+		affected[t] = true
+		scope.packages[t[:strings.LastIndex(t, ".")]] = true
+		queue = append(queue, dependents[t]...)
+	}
+
+	for i, p := range pkgs {
+		for _, svc := range protos[i].Services {
+			for _, rpc := range svc.RPCs {
+				for _, typ := range []protobuf.Type{rpc.Input, rpc.Output} {
+					for _, dep := range namedTypes(typ, paths) {
+						if affected[dep] {
+							scope.packages[p.Path] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return scope, nil
+}
+
+// namedTypes returns the named types used by the given type, as the path of
+// their Go package and their name, e.g. github.com/acme/app/user.User. Types
+// of protobuf packages not in the given paths, indexed by protobuf package,
+// are left out.
+func namedTypes(typ protobuf.Type, paths map[string]string) []string {
+	switch t := typ.(type) {
+	case *protobuf.Named:
+		if p, ok := paths[t.Package]; ok {
+			return []string{p + "." + t.Name}
+		}
+	case *protobuf.Map:
+		return append(namedTypes(t.Key, paths), namedTypes(t.Value, paths)...)
+	case *protobuf.Alias:
+		return append(namedTypes(t.Type, paths), namedTypes(t.Underlying, paths)...)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
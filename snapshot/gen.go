@@ -0,0 +1,82 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+
+	"github.com/gogo/protobuf/protoc-gen-gogo/generator"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+)
+
+// Generator generates a test for a package that checks that the snapshots
+// stored with Write in previous releases can still be decoded by the current
+// messages of the package. It catches incompatible changes that are not found
+// comparing the .proto files, like fields that keep their number and type but
+// change their meaning.
+//
+// The file will be written to the package path and it will be named
+// "proteus_snapshot_test.go". As it uses the generated protobuf code of the
+// package, it is only meant to be generated after it.
+type Generator struct{}
+
+// NewGenerator creates a new Generator.
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// Generate writes the snapshot test of the Go package with the given name at
+// the given path for the messages of the given proto package.
+func (g *Generator) Generate(proto *protobuf.Package, name, path string) error {
+	if len(proto.Messages) == 0 {
+		report.Warn("no messages in the given proto file, not generating anything")
+		return nil
+	}
+
+	data, err := g.buildFile(proto, name)
+	if err != nil {
+		return err
+	}
+
+	file := g.FileName(path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return err
+	}
+
+	report.Info("Generated snapshot test: %s", file)
+	return nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, "proteus_snapshot_test.go")
+}
+
+func (g *Generator) buildFile(proto *protobuf.Package, name string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(proto.Path)))
+	buf.WriteString(fmt.Sprintf("package %s\n\n", name))
+	buf.WriteString("import (\n\t\"testing\"\n\n\t\"gitlab.com/ThatTomPerson/proteus/snapshot\"\n)\n\n")
+
+	buf.WriteString("func TestProteusSnapshots(t *testing.T) {\n")
+	buf.WriteString("\terrs := snapshot.Check(snapshot.Dir, map[string]func() snapshot.Message{\n")
+	for _, msg := range proto.Messages {
+		buf.WriteString(fmt.Sprintf(
+			"\t\t%q: func() snapshot.Message { return new(%s) },\n",
+			msg.Name,
+			generator.CamelCase(msg.Name),
+		))
+	}
+	buf.WriteString("\t})\n\n")
+	buf.WriteString("\tfor _, err := range errs {\n\t\tt.Error(err)\n\t}\n}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
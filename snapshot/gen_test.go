@@ -0,0 +1,47 @@
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+)
+
+const expectedFile = `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo
+
+import (
+	"testing"
+
+	"gitlab.com/ThatTomPerson/proteus/snapshot"
+)
+
+func TestProteusSnapshots(t *testing.T) {
+	errs := snapshot.Check(snapshot.Dir, map[string]func() snapshot.Message{
+		"User":                  func() snapshot.Message { return new(User) },
+		"User_GetFriendRequest": func() snapshot.Message { return new(User_GetFriendRequest) },
+	})
+
+	for _, err := range errs {
+		t.Error(err)
+	}
+}
+`
+
+func TestBuildFile(t *testing.T) {
+	require := require.New(t)
+
+	pkg := &protobuf.Package{
+		Name: "foo",
+		Path: "gitlab.com/foo",
+		Messages: []*protobuf.Message{
+			{Name: "User"},
+			{Name: "User_GetFriendRequest"},
+		},
+	}
+
+	data, err := NewGenerator().buildFile(pkg, "foo")
+	require.Nil(err)
+	require.Equal(expectedFile, string(data))
+}
@@ -0,0 +1,93 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DescriptorSetFile is the name of the file, in the snapshots folder,
+	// with the descriptor set of the .proto file of the package and all the
+	// files it imports.
+	DescriptorSetFile = "descriptor.pb"
+	// HarnessFile is the name of the file, in the snapshots folder, with the
+	// index of the conformance harness.
+	HarnessFile = "harness.json"
+)
+
+// Harness is the index of a conformance harness, which lets programs written
+// in other languages check that they decode the snapshots stored by Write the
+// same way the Go messages do. It is written as JSON next to the snapshots.
+type Harness struct {
+	// Package is the protobuf package of the messages.
+	Package string `json:"package"`
+	// DescriptorSet is the path, relative to the harness, of the descriptor
+	// set with the messages.
+	DescriptorSet string `json:"descriptor_set"`
+	// Fixtures are all the snapshots stored, sorted by release and message.
+	Fixtures []*Fixture `json:"fixtures"`
+}
+
+// Fixture is a snapshot of a message stored in a release.
+type Fixture struct {
+	// Release is the release the snapshot was stored in.
+	Release string `json:"release"`
+	// Message is the full name of the protobuf message, e.g. foo.bar.User.
+	Message string `json:"message"`
+	// Wire is the path, relative to the harness, of the wire format of the
+	// message.
+	Wire string `json:"wire"`
+	// JSON is the path, relative to the harness, of the encoding of the Go
+	// message with encoding/json, which has the values the message must be
+	// decoded to. It is empty if there is none.
+	JSON string `json:"json,omitempty"`
+}
+
+// WriteHarness writes the index of the conformance harness of the snapshots
+// stored in the release folders inside dir, whose messages are in the given
+// protobuf package, to the HarnessFile of dir. The descriptor set is expected
+// to be written to the DescriptorSetFile of dir, usually with protoc.
+func WriteHarness(dir, pkg string) (*Harness, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"+wireExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	h := &Harness{
+		Package:       pkg,
+		DescriptorSet: DescriptorSetFile,
+		Fixtures:      []*Fixture{},
+	}
+	for _, file := range files {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return nil, err
+		}
+
+		f := &Fixture{
+			Release: filepath.Dir(rel),
+			Message: pkg + "." + strings.TrimSuffix(filepath.Base(rel), wireExt),
+			Wire:    filepath.ToSlash(rel),
+		}
+
+		if _, err := os.Stat(strings.TrimSuffix(file, wireExt) + jsonExt); err == nil {
+			f.JSON = filepath.ToSlash(strings.TrimSuffix(rel, wireExt) + jsonExt)
+		}
+		h.Fixtures = append(h.Fixtures, f)
+	}
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, HarnessFile), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
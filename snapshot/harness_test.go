@@ -0,0 +1,51 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHarness(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-snapshot")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	require.Nil(Write(dir, "v1.0.0", map[string]Message{
+		"User": &user{Name: "jane"},
+	}))
+	require.Nil(Write(dir, "v1.1.0", map[string]Message{
+		"User": &user{Name: "jane", Age: 42},
+	}))
+	require.Nil(os.Remove(filepath.Join(dir, "v1.1.0", "User.json")))
+
+	h, err := WriteHarness(dir, "foo.bar")
+	require.Nil(err)
+
+	expected := &Harness{
+		Package:       "foo.bar",
+		DescriptorSet: "descriptor.pb",
+		Fixtures: []*Fixture{
+			{Release: "v1.0.0", Message: "foo.bar.User", Wire: "v1.0.0/User.pb", JSON: "v1.0.0/User.json"},
+			{Release: "v1.1.0", Message: "foo.bar.User", Wire: "v1.1.0/User.pb"},
+		},
+	}
+	require.Equal(expected, h)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, HarnessFile))
+	require.Nil(err)
+
+	var written Harness
+	require.Nil(json.Unmarshal(data, &written))
+	require.Equal(expected, &written)
+
+	require.Empty(Check(dir, map[string]func() Message{
+		"User": func() Message { return new(user) },
+	}), "the harness files are not snapshots")
+}
@@ -0,0 +1,153 @@
+package snapshot // import "gitlab.com/ThatTomPerson/proteus/snapshot"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dir is the folder, relative to the Go package, the snapshots are stored in
+// by default. It contains a folder per release with the snapshots taken in
+// that release.
+var Dir = filepath.Join("testdata", "proteus")
+
+const (
+	wireExt = ".pb"
+	jsonExt = ".json"
+)
+
+// Message is a message that can be encoded to and decoded from the protobuf
+// wire format, which all the generated messages are.
+type Message interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// Write stores the snapshots of the given messages, indexed by the name of
+// their protobuf message, in the folder of the given release inside dir. For
+// every message, its wire format is written to {Name}.pb and its JSON
+// encoding, which is used to detect changes of meaning, to {Name}.json.
+func Write(dir, release string, msgs map[string]Message) error {
+	dir = filepath.Join(dir, release)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for name, msg := range msgs {
+		data, err := msg.Marshal()
+		if err != nil {
+			return fmt.Errorf("unable to marshal message %s: %s", name, err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, name+wireExt), data, 0644); err != nil {
+			return err
+		}
+
+		data, err = json.MarshalIndent(msg, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode message %s as JSON: %s", name, err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, name+jsonExt), append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check decodes all the snapshots stored in the release folders inside dir
+// with the current messages, whose constructors are indexed by the name of
+// their protobuf message, and returns all the incompatibilities found. A
+// snapshot is incompatible if its message does not exist anymore, it can
+// not be decoded, it is not decoded to the same value it was encoded from,
+// according to its JSON encoding, or it does not survive a round trip.
+func Check(dir string, types map[string]func() Message) []error {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"+wireExt))
+	if err != nil {
+		return []error{err}
+	}
+	sort.Strings(files)
+
+	var errs []error
+	for _, file := range files {
+		if err := checkFile(file, types); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", file, err))
+		}
+	}
+	return errs
+}
+
+func checkFile(file string, types map[string]func() Message) error {
+	name := strings.TrimSuffix(filepath.Base(file), wireExt)
+	newMsg, ok := types[name]
+	if !ok {
+		return fmt.Errorf("message %s does not exist anymore", name)
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	msg := newMsg()
+	if err := msg.Unmarshal(data); err != nil {
+		return fmt.Errorf("unable to unmarshal message %s: %s", name, err)
+	}
+
+	if err := checkJSON(strings.TrimSuffix(file, wireExt)+jsonExt, msg); err != nil {
+		return err
+	}
+
+	if data, err = msg.Marshal(); err != nil {
+		return fmt.Errorf("unable to marshal message %s: %s", name, err)
+	}
+
+	decoded := newMsg()
+	if err := decoded.Unmarshal(data); err != nil {
+		return fmt.Errorf("unable to unmarshal message %s after a round trip: %s", name, err)
+	}
+
+	if !reflect.DeepEqual(msg, decoded) {
+		return fmt.Errorf("message %s changed after a round trip", name)
+	}
+
+	return nil
+}
+
+// checkJSON compares the JSON encoding of the message with the one stored in
+// the given file, if any. The encodings are compared by value, so the
+// formatting of the file does not matter.
+func checkJSON(file string, msg Message) error {
+	expected, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var want, got interface{}
+	if err := json.Unmarshal(expected, &want); err != nil {
+		return fmt.Errorf("invalid JSON in %s: %s", file, err)
+	}
+
+	if err := json.Unmarshal(data, &got); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("decoded message is %s, expected %s", data, strings.Join(strings.Fields(string(expected)), " "))
+	}
+
+	return nil
+}
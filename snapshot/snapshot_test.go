@@ -0,0 +1,105 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// user is a fake message whose wire format is its JSON encoding.
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age,omitempty"`
+}
+
+func (u *user) Marshal() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+func (u *user) Unmarshal(data []byte) error {
+	return json.Unmarshal(data, u)
+}
+
+// renamedUser is the fake message user after changing the meaning of the
+// "name" field.
+type renamedUser struct {
+	Name string `json:"name"`
+	Nick string `json:"nick,omitempty"`
+}
+
+func (u *renamedUser) Marshal() ([]byte, error) {
+	var v = struct {
+		Name string `json:"name"`
+	}{u.Nick}
+	return json.Marshal(v)
+}
+
+func (u *renamedUser) Unmarshal(data []byte) error {
+	var v struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	u.Nick = v.Name
+	return nil
+}
+
+func TestWriteAndCheck(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-snapshot")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	require.Nil(Write(dir, "v1.0.0", map[string]Message{
+		"User": &user{Name: "jane", Age: 42},
+	}))
+
+	_, err = os.Stat(filepath.Join(dir, "v1.0.0", "User.pb"))
+	require.Nil(err)
+	_, err = os.Stat(filepath.Join(dir, "v1.0.0", "User.json"))
+	require.Nil(err)
+
+	errs := Check(dir, map[string]func() Message{
+		"User": func() Message { return new(user) },
+	})
+	require.Empty(errs)
+
+	errs = Check(dir, map[string]func() Message{
+		"User": func() Message { return new(renamedUser) },
+	})
+	require.Len(errs, 1)
+	require.Contains(errs[0].Error(), `decoded message is {"name":"","nick":"jane"}, expected { "name": "jane", "age": 42 }`)
+
+	errs = Check(dir, map[string]func() Message{})
+	require.Len(errs, 1)
+	require.Contains(errs[0].Error(), "message User does not exist anymore")
+}
+
+func TestCheckWithoutJSON(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-snapshot")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	require.Nil(os.MkdirAll(filepath.Join(dir, "v1.0.0"), 0755))
+	file := filepath.Join(dir, "v1.0.0", "User.pb")
+	require.Nil(ioutil.WriteFile(file, []byte(`{"name":"jane"}`), 0644))
+
+	require.Empty(Check(dir, map[string]func() Message{
+		"User": func() Message { return new(user) },
+	}))
+
+	require.Nil(ioutil.WriteFile(file, []byte(`not a message`), 0644))
+	errs := Check(dir, map[string]func() Message{
+		"User": func() Message { return new(user) },
+	})
+	require.Len(errs, 1)
+	require.Contains(errs[0].Error(), "unable to unmarshal message User")
+}
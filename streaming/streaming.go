@@ -0,0 +1,154 @@
+// Package streaming sends the values of the channels and iterators returned
+// by the funcs generated as server-streaming RPCs to their streams, with a
+// bounded buffer between the func and the client. When the buffer is full,
+// because the client reads slower than the func produces, the func is
+// either blocked until there is room again or its new values are dropped,
+// instead of piling them up in memory.
+package streaming // import "gitlab.com/ThatTomPerson/proteus/streaming"
+
+import (
+	"context"
+	"iter"
+)
+
+// Policy is what is done with the values produced when the buffer is full.
+type Policy int
+
+const (
+	// Block blocks the producer until there is room in the buffer, so the
+	// client gets all the values at the pace it reads them. It is the
+	// default.
+	Block Policy = iota
+	// Drop drops the values produced while the buffer is full, so the
+	// producer is never slowed down by the client, which only gets the
+	// values there was room for, e.g. for feeds of the latest values.
+	Drop
+)
+
+// Buffer is the buffering of the values of a stream between the func that
+// produces them and the client.
+type Buffer struct {
+	// Size is the maximum number of values held while the client is not
+	// ready to get them. With no size and the Block policy, the values are
+	// sent as they are produced, without a goroutine in between.
+	Size int
+	// Policy is what is done with the values produced when the buffer is
+	// full.
+	Policy Policy
+}
+
+// SendChan sends the values received from the channel with send, which is
+// usually the Send method of the stream, until it is closed, the context is
+// done or send fails, and returns the error, if any.
+func SendChan[T any](ctx context.Context, buf Buffer, ch <-chan T, send func(T) error) error {
+	return pipe(ctx, buf, func(yield func(T) bool) error {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok || !yield(v) {
+					return nil
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}, send)
+}
+
+// SendSeq sends the values of the iterator with send, which is usually the
+// Send method of the stream, until it ends, the context is done or send
+// fails, and returns the error, if any.
+func SendSeq[T any](ctx context.Context, buf Buffer, seq iter.Seq[T], send func(T) error) error {
+	return pipe(ctx, buf, func(yield func(T) bool) error {
+		for v := range seq {
+			if !yield(v) {
+				break
+			}
+		}
+		return nil
+	}, send)
+}
+
+// SendSeq2 is like SendSeq, for iterators of values and errors, which end
+// when they yield an error, the one returned.
+func SendSeq2[T any](ctx context.Context, buf Buffer, seq iter.Seq2[T, error], send func(T) error) error {
+	return pipe(ctx, buf, func(yield func(T) bool) error {
+		for v, err := range seq {
+			if err != nil {
+				return err
+			}
+
+			if !yield(v) {
+				break
+			}
+		}
+		return nil
+	}, send)
+}
+
+// pipe sends the values yielded by produce with send through the given
+// buffer. The producer runs in a goroutine of its own if the values are
+// buffered, which is stopped the next time it yields once pipe returns.
+func pipe[T any](ctx context.Context, buf Buffer, produce func(yield func(T) bool) error, send func(T) error) error {
+	if buf.Size <= 0 && buf.Policy == Block {
+		var err error
+		if perr := produce(func(v T) bool {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			err = send(v)
+			return err == nil
+		}); perr != nil {
+			return perr
+		}
+		return err
+	}
+
+	var (
+		values = make(chan T, buf.Size)
+		done   = make(chan struct{})
+		errc   = make(chan error, 1)
+	)
+	defer close(done)
+
+	go func() {
+		defer close(values)
+		errc <- produce(func(v T) bool {
+			select {
+			case <-done:
+				return false
+			default:
+			}
+
+			if buf.Policy == Drop {
+				select {
+				case values <- v:
+				default:
+				}
+				return true
+			}
+
+			select {
+			case values <- v:
+				return true
+			case <-done:
+				return false
+			}
+		})
+	}()
+
+	for {
+		select {
+		case v, ok := <-values:
+			if !ok {
+				return <-errc
+			}
+
+			if err := send(v); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
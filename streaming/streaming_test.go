@@ -0,0 +1,152 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func seqOf(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func collect(values *[]int) func(int) error {
+	return func(v int) error {
+		*values = append(*values, v)
+		return nil
+	}
+}
+
+func TestSendChan(t *testing.T) {
+	require := require.New(t)
+
+	for _, buf := range []Buffer{{}, {Size: 2}} {
+		ch := make(chan int)
+		go func() {
+			defer close(ch)
+			for i := 0; i < 5; i++ {
+				ch <- i
+			}
+		}()
+
+		var values []int
+		require.NoError(SendChan(context.Background(), buf, ch, collect(&values)))
+		require.Equal([]int{0, 1, 2, 3, 4}, values, "buffer %+v", buf)
+	}
+}
+
+func TestSendChanContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := SendChan(ctx, Buffer{Size: 1}, make(chan int), func(int) error { return nil })
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestSendSeq(t *testing.T) {
+	require := require.New(t)
+
+	for _, buf := range []Buffer{{}, {Size: 3}} {
+		var values []int
+		require.NoError(SendSeq(context.Background(), buf, seqOf(5), collect(&values)))
+		require.Equal([]int{0, 1, 2, 3, 4}, values, "buffer %+v", buf)
+	}
+}
+
+func TestSendSeqBlock(t *testing.T) {
+	require := require.New(t)
+
+	var produced int32
+	seq := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			atomic.AddInt32(&produced, 1)
+			if !yield(i) {
+				return
+			}
+		}
+	}
+
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- SendSeq(context.Background(), Buffer{Size: 2}, seq, func(int) error {
+			<-release
+			return errors.New("client gone")
+		})
+	}()
+
+	release <- struct{}{}
+	require.EqualError(<-done, "client gone")
+	n := atomic.LoadInt32(&produced)
+	require.True(n <= 5, "the producer is blocked while the buffer is full, produced %d", n)
+}
+
+func TestSendSeqDrop(t *testing.T) {
+	require := require.New(t)
+
+	release := make(chan struct{})
+	var values []int
+	send := func(v int) error {
+		<-release
+		values = append(values, v)
+		return nil
+	}
+
+	ch := make(chan int)
+	go func() {
+		for i := 0; i < 100; i++ {
+			ch <- i
+		}
+		close(ch)
+		close(release)
+	}()
+
+	require.NoError(SendChan(context.Background(), Buffer{Size: 2, Policy: Drop}, ch, send))
+	require.True(len(values) >= 1 && len(values) <= 4, "the values produced while the buffer is full are dropped, got %v", values)
+	require.Equal(0, values[0])
+}
+
+func TestSendSeq2(t *testing.T) {
+	require := require.New(t)
+
+	seq := func(yield func(int, error) bool) {
+		if !yield(1, nil) {
+			return
+		}
+		yield(0, errors.New("can not read"))
+	}
+
+	for _, buf := range []Buffer{{}, {Size: 3}} {
+		var values []int
+		err := SendSeq2(context.Background(), buf, seq, collect(&values))
+		require.EqualError(err, "can not read")
+		require.Equal([]int{1}, values, "buffer %+v", buf)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	require := require.New(t)
+
+	for _, buf := range []Buffer{{}, {Size: 3}} {
+		var sent int
+		err := SendSeq(context.Background(), buf, seqOf(1000), func(int) error {
+			sent++
+			if sent == 2 {
+				return errors.New("client gone")
+			}
+			return nil
+		})
+		require.EqualError(err, "client gone", "buffer %+v", buf)
+		require.Equal(2, sent)
+	}
+}
@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// FromMetadata returns the valid IDs of the given gRPC metadata.
+func FromMetadata(md metadata.MD) IDs {
+	return IDs{
+		Traceparent: first(md.Get(TraceparentKey)),
+		Tracestate:  first(md.Get(TracestateKey)),
+		RequestID:   first(md.Get(RequestIDKey)),
+	}.valid()
+}
+
+// Metadata returns the gRPC metadata with the IDs that are set.
+func (ids IDs) Metadata() metadata.MD {
+	return metadata.Pairs(ids.pairs()...)
+}
+
+// OutgoingContext returns a copy of the given context whose outgoing gRPC
+// metadata has the IDs of the context, replacing the ones it had.
+func OutgoingContext(ctx context.Context) context.Context {
+	ids := FromContext(ctx)
+	if ids.IsZero() {
+		return ctx
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for k, v := range ids.Metadata() {
+		md[k] = v
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// incomingContext returns a copy of the given context of a call to the
+// server with the IDs of its incoming metadata, and a new request ID if it
+// has none.
+func incomingContext(ctx context.Context) (context.Context, IDs) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ids := FromMetadata(md).withRequestID()
+	return NewContext(ctx, ids), ids
+}
+
+// UnaryServerInterceptor returns an interceptor that adds the IDs of the
+// incoming metadata of the calls to their context, creating a request ID if
+// they do not have one, which is sent back in the header of the response.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, ids := incomingContext(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, ids.RequestID))
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is like UnaryServerInterceptor for streams.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, ids := incomingContext(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(RequestIDKey, ids.RequestID))
+		return handler(srv, &serverStream{ss, ctx})
+	}
+}
+
+// serverStream is a server stream with another context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// UnaryClientInterceptor returns an interceptor that sends the IDs of the
+// context of the calls in their outgoing metadata.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(OutgoingContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor is like UnaryClientInterceptor for streams.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(OutgoingContext(ctx), desc, cc, method, opts...)
+	}
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
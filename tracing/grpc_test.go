@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestFromMetadata(t *testing.T) {
+	md := metadata.Pairs(TraceparentKey, traceparent, TracestateKey, "a=b", RequestIDKey, "req-1", RequestIDKey, "req-2")
+	require.Equal(t, IDs{Traceparent: traceparent, Tracestate: "a=b", RequestID: "req-1"}, FromMetadata(md))
+	require.True(t, FromMetadata(metadata.Pairs(TraceparentKey, "foo", TracestateKey, "a=b")).IsZero())
+	require.True(t, FromMetadata(nil).IsZero())
+}
+
+func TestOutgoingContext(t *testing.T) {
+	require := require.New(t)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "foo", "bar", RequestIDKey, "old")
+	require.Equal(ctx, OutgoingContext(ctx))
+
+	ctx = OutgoingContext(NewContext(ctx, IDs{Traceparent: traceparent, RequestID: "req-1"}))
+	md, _ := metadata.FromOutgoingContext(ctx)
+	require.Equal(metadata.Pairs("foo", "bar", TraceparentKey, traceparent, RequestIDKey, "req-1"), md)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	require := require.New(t)
+	intercept := UnaryServerInterceptor()
+
+	var got IDs
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		got = FromContext(ctx)
+		return req, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TraceparentKey, traceparent, RequestIDKey, "req-1"))
+	resp, err := intercept(ctx, "foo", &grpc.UnaryServerInfo{}, handler)
+	require.NoError(err)
+	require.Equal("foo", resp)
+	require.Equal(IDs{Traceparent: traceparent, RequestID: "req-1"}, got)
+
+	_, err = intercept(context.Background(), "foo", &grpc.UnaryServerInfo{}, handler)
+	require.NoError(err)
+	require.Len(got.RequestID, 32)
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	require := require.New(t)
+	ss := &fakeServerStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "req-1"))}
+
+	var got IDs
+	err := StreamServerInterceptor()(nil, ss, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+		got = FromContext(stream.Context())
+		return nil
+	})
+	require.NoError(err)
+	require.Equal(IDs{RequestID: "req-1"}, got)
+	require.Equal(metadata.Pairs(RequestIDKey, "req-1"), ss.header)
+}
+
+func TestClientInterceptors(t *testing.T) {
+	require := require.New(t)
+	ctx := NewContext(context.Background(), IDs{RequestID: "req-1"})
+
+	var md metadata.MD
+	err := UnaryClientInterceptor()(ctx, "/Foo/Bar", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	require.NoError(err)
+	require.Equal(metadata.Pairs(RequestIDKey, "req-1"), md)
+
+	md = nil
+	_, err = StreamClientInterceptor()(ctx, &grpc.StreamDesc{}, nil, "/Foo/Bar", func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil, nil
+	})
+	require.NoError(err)
+	require.Equal(metadata.Pairs(RequestIDKey, "req-1"), md)
+}
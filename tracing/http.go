@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// FromHeader returns the valid IDs of the given HTTP header.
+func FromHeader(h http.Header) IDs {
+	return IDs{
+		Traceparent: h.Get(TraceparentKey),
+		Tracestate:  h.Get(TracestateKey),
+		RequestID:   h.Get(RequestIDKey),
+	}.valid()
+}
+
+// SetHeader sets the IDs that are set in the given HTTP header.
+func (ids IDs) SetHeader(h http.Header) {
+	kv := ids.pairs()
+	for i := 0; i < len(kv); i += 2 {
+		h.Set(kv[i], kv[i+1])
+	}
+}
+
+// Handler returns a handler that adds the IDs of the header of the requests
+// to their context before calling the given handler, creating a request ID
+// if they do not have one, which is sent back in the header of the response.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := FromHeader(r.Header).withRequestID()
+		w.Header().Set(RequestIDKey, ids.RequestID)
+		h.ServeHTTP(w, r.WithContext(NewContext(r.Context(), ids)))
+	})
+}
+
+// GatewayMetadata returns the gRPC metadata with the IDs of the given
+// request, taken from its context if it went through Handler or from its
+// header otherwise. It is meant to be given to grpc-gateway with
+// runtime.WithMetadata, which does not forward the trace context headers by
+// default, so the gRPC server gets the same IDs as the HTTP handler.
+func GatewayMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	ids := FromContext(r.Context())
+	if ids.IsZero() {
+		ids = FromHeader(r.Header)
+	}
+	return ids.Metadata()
+}
+
+// Transport returns a round tripper that sets the IDs of the context of the
+// requests in their header before sending them with the given round tripper,
+// or with http.DefaultTransport if it is nil.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		ids := FromContext(r.Context())
+		if ids.IsZero() {
+			return rt.RoundTrip(r)
+		}
+
+		r = r.Clone(r.Context())
+		ids.SetHeader(r.Header)
+		return rt.RoundTrip(r)
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestHeader(t *testing.T) {
+	require := require.New(t)
+
+	h := make(http.Header)
+	ids := IDs{Traceparent: traceparent, Tracestate: "a=b", RequestID: "req-1"}
+	ids.SetHeader(h)
+	require.Equal(traceparent, h.Get("Traceparent"))
+	require.Equal("req-1", h.Get("X-Request-Id"))
+	require.Equal(ids, FromHeader(h))
+	require.True(FromHeader(http.Header{"Traceparent": {"foo"}}).IsZero())
+}
+
+func TestHandler(t *testing.T) {
+	require := require.New(t)
+
+	var got IDs
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest("GET", "/v1/users", nil)
+	r.Header.Set(TraceparentKey, traceparent)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(traceparent, got.Traceparent)
+	require.Len(got.RequestID, 32)
+	require.Equal(got.RequestID, w.Header().Get(RequestIDKey))
+
+	r = httptest.NewRequest("GET", "/v1/users", nil)
+	r.Header.Set(RequestIDKey, "req-1")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(IDs{RequestID: "req-1"}, got)
+	require.Equal("req-1", w.Header().Get(RequestIDKey))
+}
+
+func TestGatewayMetadata(t *testing.T) {
+	require := require.New(t)
+
+	r := httptest.NewRequest("GET", "/v1/users", nil)
+	r.Header.Set(RequestIDKey, "req-1")
+	require.Equal(metadata.Pairs(RequestIDKey, "req-1"), GatewayMetadata(context.Background(), r))
+
+	r = r.WithContext(NewContext(r.Context(), IDs{RequestID: "req-2"}))
+	require.Equal(metadata.Pairs(RequestIDKey, "req-2"), GatewayMetadata(context.Background(), r))
+}
+
+func TestTransport(t *testing.T) {
+	require := require.New(t)
+
+	var got http.Header
+	rt := Transport(roundTripper(func(r *http.Request) (*http.Response, error) {
+		got = r.Header
+		return nil, nil
+	}))
+
+	r := httptest.NewRequest("GET", "/v1/users", nil)
+	_, err := rt.RoundTrip(r)
+	require.NoError(err)
+	require.Empty(got)
+
+	r = r.WithContext(NewContext(r.Context(), IDs{RequestID: "req-1"}))
+	_, err = rt.RoundTrip(r)
+	require.NoError(err)
+	require.Equal("req-1", got.Get(RequestIDKey))
+	require.Empty(r.Header, "the request is not modified")
+}
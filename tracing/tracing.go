@@ -0,0 +1,166 @@
+// Package tracing propagates the W3C trace context and the request IDs of
+// the requests served by the generated gRPC servers, so the logs and traces
+// of a request can be correlated across services, whether it arrives with
+// gRPC or with HTTP through grpc-gateway. No tracer is needed: the IDs are
+// forwarded as they are received, and a request ID is created for the
+// requests that do not have one.
+package tracing // import "gitlab.com/ThatTomPerson/proteus/tracing"
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// TraceparentKey is the header and metadata key of the W3C traceparent.
+	TraceparentKey = "traceparent"
+	// TracestateKey is the header and metadata key of the W3C tracestate.
+	TracestateKey = "tracestate"
+	// RequestIDKey is the header and metadata key of the request ID.
+	RequestIDKey = "x-request-id"
+)
+
+// maxRequestIDLen is the maximum length of the request IDs received, longer
+// ones are ignored.
+const maxRequestIDLen = 128
+
+// IDs are the correlation IDs of a request.
+type IDs struct {
+	// Traceparent is the W3C traceparent of the request, e.g.
+	// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+	Traceparent string
+	// Tracestate is the W3C tracestate of the request, which is only kept
+	// along with a traceparent.
+	Tracestate string
+	// RequestID is the ID of the request.
+	RequestID string
+}
+
+// IsZero reports whether there are no IDs.
+func (ids IDs) IsZero() bool {
+	return ids == IDs{}
+}
+
+// TraceID returns the trace ID of the traceparent, or an empty string if
+// there is none.
+func (ids IDs) TraceID() string {
+	if ids.Traceparent == "" {
+		return ""
+	}
+	return ids.Traceparent[3:35]
+}
+
+// valid returns the IDs without the invalid ones, which are ignored as the
+// W3C trace context asks for.
+func (ids IDs) valid() IDs {
+	if !ValidTraceparent(ids.Traceparent) {
+		ids.Traceparent = ""
+		ids.Tracestate = ""
+	}
+
+	if !validRequestID(ids.RequestID) {
+		ids.RequestID = ""
+	}
+	return ids
+}
+
+// withRequestID returns the IDs with a new request ID if they do not have
+// one.
+func (ids IDs) withRequestID() IDs {
+	if ids.RequestID == "" {
+		ids.RequestID = NewRequestID()
+	}
+	return ids
+}
+
+// pairs returns the keys and values of the IDs that are set.
+func (ids IDs) pairs() []string {
+	var kv []string
+	for _, p := range [][2]string{
+		{TraceparentKey, ids.Traceparent},
+		{TracestateKey, ids.Tracestate},
+		{RequestIDKey, ids.RequestID},
+	} {
+		if p[1] != "" {
+			kv = append(kv, p[0], p[1])
+		}
+	}
+	return kv
+}
+
+type idsKey struct{}
+
+// NewContext returns a copy of the given context with the given IDs.
+func NewContext(ctx context.Context, ids IDs) context.Context {
+	return context.WithValue(ctx, idsKey{}, ids)
+}
+
+// FromContext returns the IDs of the given context. They are zero if it has
+// none.
+func FromContext(ctx context.Context) IDs {
+	ids, _ := ctx.Value(idsKey{}).(IDs)
+	return ids
+}
+
+// NewRequestID returns a new random request ID.
+func NewRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// ValidTraceparent reports whether the given traceparent has the format of
+// the W3C trace context, that is, a version, a trace ID, a parent ID and
+// flags in lower case hex separated by dashes, with the trace and parent IDs
+// not being all zeros. Versions after 00 may have more fields after the
+// flags.
+func ValidTraceparent(s string) bool {
+	if len(s) < 55 || (len(s) > 55 && (s[:2] == "00" || s[55] != '-')) {
+		return false
+	}
+
+	parts := strings.Split(s[:55], "-")
+	if len(parts) != 4 || parts[0] == "ff" {
+		return false
+	}
+
+	for i, n := range []int{2, 32, 16, 2} {
+		if len(parts[i]) != n || !isLowerHex(parts[i]) {
+			return false
+		}
+	}
+	return !isZeros(parts[1]) && !isZeros(parts[2])
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeros(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// validRequestID reports whether the given request ID is not empty and is
+// made of at most maxRequestIDLen visible ASCII characters, so it can be
+// written to logs and headers as it is.
+func validRequestID(s string) bool {
+	if s == "" || len(s) > maxRequestIDLen {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '!' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
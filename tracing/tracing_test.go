@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestValidTraceparent(t *testing.T) {
+	cases := []struct {
+		traceparent string
+		valid       bool
+	}{
+		{traceparent, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"", false},
+		{traceparent + "-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01", false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01future", false},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.valid, ValidTraceparent(c.traceparent), c.traceparent)
+	}
+}
+
+func TestIDsValid(t *testing.T) {
+	require := require.New(t)
+
+	ids := IDs{Traceparent: "foo", Tracestate: "a=b", RequestID: "req-1"}.valid()
+	require.Equal(IDs{RequestID: "req-1"}, ids)
+
+	ids = IDs{Traceparent: traceparent, Tracestate: "a=b", RequestID: "req 1"}.valid()
+	require.Equal(IDs{Traceparent: traceparent, Tracestate: "a=b"}, ids)
+
+	ids = IDs{RequestID: strings.Repeat("a", maxRequestIDLen+1)}.valid()
+	require.True(ids.IsZero())
+}
+
+func TestTraceID(t *testing.T) {
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", IDs{Traceparent: traceparent}.TraceID())
+	require.Equal(t, "", IDs{}.TraceID())
+}
+
+func TestContext(t *testing.T) {
+	require := require.New(t)
+	require.True(FromContext(context.Background()).IsZero())
+
+	ids := IDs{Traceparent: traceparent, RequestID: "req-1"}
+	require.Equal(ids, FromContext(NewContext(context.Background(), ids)))
+}
+
+func TestNewRequestID(t *testing.T) {
+	require := require.New(t)
+	id := NewRequestID()
+	require.Len(id, 32)
+	require.True(validRequestID(id))
+	require.NotEqual(id, NewRequestID())
+	require.Equal("req-1", IDs{RequestID: "req-1"}.withRequestID().RequestID)
+}
@@ -0,0 +1,322 @@
+package units // import "gitlab.com/ThatTomPerson/proteus/units"
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// FileName is the name of the file generated in every package.
+const FileName = "proteus_units.go"
+
+// durations are the time units with conversion methods, along with the
+// time.Duration of one of them.
+var durations = map[string]string{
+	"ns":  "time.Nanosecond",
+	"us":  "time.Microsecond",
+	"ms":  "time.Millisecond",
+	"s":   "time.Second",
+	"min": "time.Minute",
+	"h":   "time.Hour",
+}
+
+// sizes are the size units with conversion methods, along with the bytes of
+// one of them.
+var sizes = map[string]string{
+	"KB":  "1000",
+	"MB":  "1000000",
+	"GB":  "1000000000",
+	"KiB": "1024",
+	"MiB": "1048576",
+	"GiB": "1073741824",
+}
+
+// Generator generates methods converting the fields of the structs of a
+// package that have a time or size unit, given with `proteus:"unit=ms"`, from
+// and to time.Duration or bytes, so their values are not mistaken for values
+// in another unit. For a field Timeout in ms of the struct Foo it generates:
+//
+//	func (m *Foo) TimeoutDuration() time.Duration
+//	func (m *Foo) SetTimeoutDuration(d time.Duration)
+//
+// And for a field Limit in KiB:
+//
+//	func (m *Foo) LimitBytes() int64
+//	func (m *Foo) SetLimitBytes(n int64)
+//
+// The time units are ns, us, ms, s, min and h, and the size units are KB, MB,
+// GB, KiB, MiB and GiB. Fields with other units, which are only documented,
+// and fields that are not numbers are skipped, as are fields whose methods
+// are already declared.
+//
+// The file will be written to the package path and it will be named
+// "proteus_units.go".
+type Generator struct{}
+
+// NewGenerator creates a new Generator.
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// conversion is a pair of methods converting a field of a struct.
+type conversion struct {
+	recv  string
+	field string
+	unit  string
+	// typ is the Go type of the field.
+	typ   string
+	float bool
+	// duration is the time.Duration of one unit, if it is a time unit.
+	duration string
+	// size is the bytes of one unit, if it is a size unit.
+	size string
+}
+
+func (c *conversion) methods() (string, string) {
+	if c.duration != "" {
+		return c.field + "Duration", "Set" + c.field + "Duration"
+	}
+	return c.field + "Bytes", "Set" + c.field + "Bytes"
+}
+
+// Generate writes the conversion methods of the fields with units of the
+// structs of the given package. Nothing is written if there are none. It
+// reports whether the file was written.
+func (g *Generator) Generate(pkg *scanner.Package) (bool, error) {
+	convs := conversions(pkg)
+	if len(convs) == 0 {
+		return false, nil
+	}
+
+	declared, err := findMethods(filepath.Join(goSrc, pkg.Path))
+	if err != nil {
+		return false, err
+	}
+
+	var result []*conversion
+	for _, c := range convs {
+		get, set := c.methods()
+		if declared[c.recv][get] || declared[c.recv][set] {
+			report.Warn("struct %s already has a %s or %s method or field, no unit conversion methods are generated for its field %s", c.recv, get, set, c.field)
+			continue
+		}
+		result = append(result, c)
+	}
+
+	if len(result) == 0 {
+		return false, nil
+	}
+
+	data, err := g.buildFile(pkg, result)
+	if err != nil {
+		return false, err
+	}
+
+	file := g.FileName(pkg.Path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return false, err
+	}
+
+	report.Info("Generated unit conversions: %s", file)
+	return true, nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, FileName)
+}
+
+// conversions returns the conversions of the fields of the generated structs
+// of the package with a time or size unit.
+func conversions(pkg *scanner.Package) []*conversion {
+	var result []*conversion
+	for _, s := range pkg.Structs {
+		if !s.Generate {
+			continue
+		}
+
+		for _, f := range s.Fields {
+			duration, size := durations[f.Unit], sizes[f.Unit]
+			if f.Reserved || (duration == "" && size == "") {
+				continue
+			}
+
+			typ, float, ok := numberType(pkg, f.Type)
+			if !ok {
+				report.Warn("field %s of struct %s has the unit %s but it is not a number, no unit conversion methods are generated for it", f.Name, s.Name, f.Unit)
+				continue
+			}
+
+			result = append(result, &conversion{
+				recv:     s.Name,
+				field:    f.Name,
+				unit:     f.Unit,
+				typ:      typ,
+				float:    float,
+				duration: duration,
+				size:     size,
+			})
+		}
+	}
+	return result
+}
+
+// numberType returns the Go type of the given type of a field of the
+// package if it is a number that is not repeated nor a pointer, and whether
+// it is a float.
+func numberType(pkg *scanner.Package, typ scanner.Type) (string, bool, bool) {
+	name := typ.TypeString()
+	if a, ok := typ.(*scanner.Alias); ok {
+		n, isNamed := a.Type.(*scanner.Named)
+		if !isNamed || n.Path != pkg.Path {
+			return "", false, false
+		}
+		name = n.Name
+		typ = a.Underlying
+	}
+
+	b, ok := typ.(*scanner.Basic)
+	if !ok || b.Repeated || b.Nullable {
+		return "", false, false
+	}
+
+	switch b.Name {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return name, false, true
+	case "float32", "float64":
+		return name, true, true
+	}
+	return "", false, false
+}
+
+func (g *Generator) buildFile(pkg *scanner.Package, convs []*conversion) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(pkg.Path)))
+	buf.WriteString(fmt.Sprintf("package %s\n", pkg.Name))
+
+	for _, c := range convs {
+		if c.duration != "" {
+			buf.WriteString("\nimport \"time\"\n")
+			break
+		}
+	}
+
+	for _, c := range convs {
+		get, set := c.methods()
+		if c.duration != "" {
+			buf.WriteString(fmt.Sprintf("\n// %s returns %s, in %s, as a time.Duration.\n", get, c.field, c.unit))
+			buf.WriteString(fmt.Sprintf("func (m *%s) %s() time.Duration {\n", c.recv, get))
+			if c.float {
+				buf.WriteString(fmt.Sprintf("\treturn time.Duration(float64(m.%s) * float64(%s))\n}\n", c.field, c.duration))
+			} else {
+				buf.WriteString(fmt.Sprintf("\treturn time.Duration(m.%s) * %s\n}\n", c.field, c.duration))
+			}
+
+			buf.WriteString(fmt.Sprintf("\n// %s sets %s, in %s, to the given time.Duration.\n", set, c.field, c.unit))
+			buf.WriteString(fmt.Sprintf("func (m *%s) %s(d time.Duration) {\n", c.recv, set))
+			if c.float && c.typ == "float64" {
+				buf.WriteString(fmt.Sprintf("\tm.%s = float64(d) / float64(%s)\n}\n", c.field, c.duration))
+			} else if c.float {
+				buf.WriteString(fmt.Sprintf("\tm.%s = %s(float64(d) / float64(%s))\n}\n", c.field, c.typ, c.duration))
+			} else {
+				buf.WriteString(fmt.Sprintf("\tm.%s = %s(d / %s)\n}\n", c.field, c.typ, c.duration))
+			}
+			continue
+		}
+
+		buf.WriteString(fmt.Sprintf("\n// %s returns %s, in %s, in bytes.\n", get, c.field, c.unit))
+		buf.WriteString(fmt.Sprintf("func (m *%s) %s() int64 {\n", c.recv, get))
+		if c.float {
+			buf.WriteString(fmt.Sprintf("\treturn int64(float64(m.%s) * %s)\n}\n", c.field, c.size))
+		} else {
+			buf.WriteString(fmt.Sprintf("\treturn int64(m.%s) * %s\n}\n", c.field, c.size))
+		}
+
+		buf.WriteString(fmt.Sprintf("\n// %s sets %s, in %s, to the given bytes.\n", set, c.field, c.unit))
+		buf.WriteString(fmt.Sprintf("func (m *%s) %s(n int64) {\n", c.recv, set))
+		if c.float && c.typ == "float64" {
+			buf.WriteString(fmt.Sprintf("\tm.%s = float64(n) / %s\n}\n", c.field, c.size))
+		} else if c.float {
+			buf.WriteString(fmt.Sprintf("\tm.%s = %s(float64(n) / %s)\n}\n", c.field, c.typ, c.size))
+		} else {
+			buf.WriteString(fmt.Sprintf("\tm.%s = %s(n / %s)\n}\n", c.field, c.typ, c.size))
+		}
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// findMethods returns the names of the methods and fields of the types in
+// the Go files of the given folder, but the generated one, indexed by the
+// name of the type.
+func findMethods(dir string) (map[string]map[string]bool, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return fi.Name() != FileName && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]map[string]bool)
+	add := func(typ, name string) {
+		if names[typ] == nil {
+			names[typ] = make(map[string]bool)
+		}
+		names[typ][name] = true
+	}
+
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					if decl.Recv == nil {
+						continue
+					}
+
+					typ := decl.Recv.List[0].Type
+					if star, ok := typ.(*ast.StarExpr); ok {
+						typ = star.X
+					}
+
+					if ident, ok := typ.(*ast.Ident); ok {
+						add(ident.Name, decl.Name.Name)
+					}
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						ts, ok := spec.(*ast.TypeSpec)
+						if !ok {
+							continue
+						}
+
+						st, ok := ts.Type.(*ast.StructType)
+						if !ok {
+							continue
+						}
+
+						for _, f := range st.Fields.List {
+							for _, n := range f.Names {
+								add(ts.Name.Name, n.Name)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
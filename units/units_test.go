@@ -0,0 +1,126 @@
+package units
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedFile = `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo
+
+import "time"
+
+// TimeoutDuration returns Timeout, in ms, as a time.Duration.
+func (m *Config) TimeoutDuration() time.Duration {
+	return time.Duration(m.Timeout) * time.Millisecond
+}
+
+// SetTimeoutDuration sets Timeout, in ms, to the given time.Duration.
+func (m *Config) SetTimeoutDuration(d time.Duration) {
+	m.Timeout = int64(d / time.Millisecond)
+}
+
+// DelayDuration returns Delay, in s, as a time.Duration.
+func (m *Config) DelayDuration() time.Duration {
+	return time.Duration(float64(m.Delay) * float64(time.Second))
+}
+
+// SetDelayDuration sets Delay, in s, to the given time.Duration.
+func (m *Config) SetDelayDuration(d time.Duration) {
+	m.Delay = float64(d) / float64(time.Second)
+}
+
+// LimitBytes returns Limit, in KiB, in bytes.
+func (m *Config) LimitBytes() int64 {
+	return int64(m.Limit) * 1024
+}
+
+// SetLimitBytes sets Limit, in KiB, to the given bytes.
+func (m *Config) SetLimitBytes(n int64) {
+	m.Limit = Size(n / 1024)
+}
+`
+
+func testPackage() *scanner.Package {
+	return &scanner.Package{
+		Name: "foo",
+		Path: "gitlab.com/foo",
+		Structs: []*scanner.Struct{
+			{Name: "Config", Generate: true, Fields: []*scanner.Field{
+				{Name: "Timeout", Type: scanner.NewBasic("int64"), Unit: "ms"},
+				{Name: "Delay", Type: scanner.NewBasic("float64"), Unit: "s"},
+				{Name: "Limit", Type: scanner.NewAlias(scanner.NewNamed("gitlab.com/foo", "Size"), scanner.NewBasic("int32")), Unit: "KiB"},
+				{Name: "Ratio", Type: scanner.NewBasic("float64"), Unit: "%"},
+				{Name: "Name", Type: scanner.NewBasic("string"), Unit: "ms"},
+				{Name: "Delays", Type: repeated(scanner.NewBasic("int64")), Unit: "ms"},
+				{Name: "Other", Type: scanner.NewAlias(scanner.NewNamed("gitlab.com/bar", "Millis"), scanner.NewBasic("int64")), Unit: "ms"},
+			}},
+			{Name: "Internal", Fields: []*scanner.Field{
+				{Name: "Timeout", Type: scanner.NewBasic("int64"), Unit: "ms"},
+			}},
+		},
+	}
+}
+
+func repeated(t scanner.Type) scanner.Type {
+	t.SetRepeated(true)
+	return t
+}
+
+func TestBuildFile(t *testing.T) {
+	require := require.New(t)
+
+	pkg := testPackage()
+	convs := conversions(pkg)
+	require.Len(convs, 3, "only the generated structs with numbers of time or size units")
+
+	data, err := NewGenerator().buildFile(pkg, convs)
+	require.NoError(err)
+	require.Equal(expectedFile, string(data))
+}
+
+func TestBuildFileSizes(t *testing.T) {
+	pkg := &scanner.Package{
+		Name: "foo",
+		Path: "gitlab.com/foo",
+		Structs: []*scanner.Struct{
+			{Name: "Quota", Generate: true, Fields: []*scanner.Field{
+				{Name: "Disk", Type: scanner.NewBasic("float32"), Unit: "GB"},
+			}},
+		},
+	}
+
+	data, err := NewGenerator().buildFile(pkg, conversions(pkg))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "import", "time is only needed by time units")
+	require.Contains(t, string(data), "return int64(float64(m.Disk) * 1000000000)")
+	require.Contains(t, string(data), "m.Disk = float32(float64(n) / 1000000000)")
+}
+
+func TestFindMethods(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "proteus-units")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config.go": "package foo\n\ntype Config struct{ Timeout int64; TimeoutDuration int }\n\nfunc (c *Config) LimitBytes() int64 { return 0 }\n",
+		FileName:    "package foo\n\nfunc (c Config) SetDelayDuration() {}\n",
+	}
+	for name, content := range files {
+		require.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	declared, err := findMethods(dir)
+	require.NoError(err)
+	require.True(declared["Config"]["TimeoutDuration"])
+	require.True(declared["Config"]["LimitBytes"])
+	require.False(declared["Config"]["SetDelayDuration"], "the generated file is not taken into account")
+}
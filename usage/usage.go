@@ -0,0 +1,237 @@
+// Package usage generates the example tests showing how to call the services
+// of a package with the gRPC clients generated for them, so go doc and
+// pkg.go.dev show working usage of the generated API.
+package usage // import "gitlab.com/ThatTomPerson/proteus/usage"
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/format"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/protoc-gen-gogo/generator"
+
+	"gitlab.com/ThatTomPerson/proteus/output"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/report"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+// Generator generates an example per service of a package, which connects to
+// a server, creates the client of the service and calls every RPC with a
+// literal of its request. The fields of the scalars of the requests generated
+// by proteus are written with their zero values, so they show what can be
+// sent.
+//
+// The file will be written to the package path and it will be named
+// "proteus_example_test.go", in the external test package of the package.
+// As it uses the gRPC clients generated by gogo/protobuf, it is only meant to
+// be generated after them. The examples have no output, so they are compiled
+// but not run by go test.
+type Generator struct{}
+
+// NewGenerator creates a new Generator.
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// Generate writes the examples of the Go package with the given name at the
+// given path for the services of the given proto package.
+func (g *Generator) Generate(proto *protobuf.Package, name, path string) error {
+	if !proto.HasRPCs() {
+		report.Warn("no RPCs in the given proto file, not generating anything")
+		return nil
+	}
+
+	data, err := g.buildFile(proto, name, path)
+	if err != nil {
+		return err
+	}
+
+	file := g.FileName(path)
+	if err := output.WriteFile(file, data, 0644); err != nil {
+		return err
+	}
+
+	report.Info("Generated usage examples: %s", file)
+	return nil
+}
+
+// FileName returns the path of the file generated for the package at the
+// given path.
+func (g *Generator) FileName(path string) string {
+	return filepath.Join(goSrc, path, "proteus_example_test.go")
+}
+
+func (g *Generator) buildFile(proto *protobuf.Package, name, pkgPath string) ([]byte, error) {
+	f := &file{proto: proto, name: name, path: pkgPath, imports: make(map[string]string)}
+
+	var body bytes.Buffer
+	for _, svc := range proto.Services {
+		if len(svc.RPCs) > 0 {
+			f.writeExample(&body, svc)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s\n\n", protobuf.GeneratedBy(proto.Path)))
+	buf.WriteString(fmt.Sprintf("package %s_test\n\n", name))
+	buf.WriteString("import (\n\t\"context\"\n\t\"log\"\n\n\t\"google.golang.org/grpc\"\n\n")
+	buf.WriteString(fmt.Sprintf("\t%q\n", pkgPath))
+	for _, i := range f.sortedImports() {
+		if f.imports[i] != path.Base(i) {
+			buf.WriteString(fmt.Sprintf("\t%s %q\n", f.imports[i], i))
+		} else {
+			buf.WriteString(fmt.Sprintf("\t%q\n", i))
+		}
+	}
+	buf.WriteString(")\n")
+	buf.Write(body.Bytes())
+
+	return format.Source(buf.Bytes())
+}
+
+// file holds the state of the file being generated.
+type file struct {
+	proto *protobuf.Package
+	// name and path are the name and import path of the Go package.
+	name string
+	path string
+	// imports are the names of the other packages imported, by path.
+	imports map[string]string
+}
+
+func (f *file) writeExample(buf *bytes.Buffer, svc *protobuf.Service) {
+	svcName := generator.CamelCase(svc.Name)
+	fmt.Fprintf(buf, "\n// Example_%s shows how to call the RPCs of %s with its generated client.\n", exampleSuffix(svcName), svcName)
+	fmt.Fprintf(buf, "func Example_%s() {\n", exampleSuffix(svcName))
+	buf.WriteString("\tconn, err := grpc.Dial(\"localhost:8001\", grpc.WithInsecure())\n")
+	buf.WriteString("\tif err != nil {\n\t\tlog.Fatal(err)\n\t}\n")
+	buf.WriteString("\tdefer conn.Close()\n\n")
+	fmt.Fprintf(buf, "\tclient := %s.New%sClient(conn)\n", f.name, svcName)
+	buf.WriteString("\tctx := context.Background()\n")
+
+	for _, rpc := range svc.RPCs {
+		fmt.Fprintf(buf, "\n\tif _, err := client.%s(ctx, %s); err != nil {\n", generator.CamelCase(rpc.Name), f.request(rpc.Input))
+		buf.WriteString("\t\tlog.Fatal(err)\n\t}\n")
+	}
+	buf.WriteString("}\n")
+}
+
+// request returns the literal of the request of the given type. Requests of
+// types whose Go package is not known are nil.
+func (f *file) request(typ protobuf.Type) string {
+	n, ok := typ.(*protobuf.Named)
+	if !ok {
+		return "nil"
+	}
+
+	if n.Generated || n.Package == f.proto.Name {
+		return fmt.Sprintf("&%s.%s{%s}", f.name, generator.CamelCase(n.Name), f.fields(n.Name, n.Generated))
+	}
+
+	src, ok := n.Src.(*scanner.Named)
+	if !ok || src.Path == "" {
+		return "nil"
+	}
+
+	if src.Path == f.path {
+		return fmt.Sprintf("&%s.%s{}", f.name, src.Name)
+	}
+	return fmt.Sprintf("&%s.%s{}", f.importName(src.Path), src.Name)
+}
+
+// fields returns the fields of scalar types of the message with the given
+// name, with their zero values. Only the ones of generated messages are
+// written, as the Go types of the rest may have them in embedded structs.
+func (f *file) fields(name string, generated bool) string {
+	if !generated {
+		return ""
+	}
+
+	var msg *protobuf.Message
+	for _, m := range f.proto.Messages {
+		if m.Name == name {
+			msg = m
+		}
+	}
+	if msg == nil {
+		return ""
+	}
+
+	var fields []string
+	for _, field := range msg.Fields {
+		val, ok := zeroValue(field)
+		if !ok {
+			continue
+		}
+
+		fields = append(fields, fmt.Sprintf("\n\t\t%s: %s,", field.GoName(), val))
+	}
+
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.Join(fields, "") + "\n\t"
+}
+
+// zeroValue returns the zero value of the field, if it is a scalar that is
+// not repeated, optional or converted to a custom type.
+func zeroValue(f *protobuf.Field) (string, bool) {
+	basic, ok := f.Type.(*protobuf.Basic)
+	if !ok || f.Repeated || f.Optional {
+		return "", false
+	}
+
+	if _, ok := f.Options["(gogoproto.customtype)"]; ok {
+		return "", false
+	}
+
+	switch basic.Name {
+	case "string":
+		return `""`, true
+	case "bool":
+		return "false", true
+	case "bytes":
+		return "", false
+	default:
+		return "0", true
+	}
+}
+
+// importName returns the name of the package with the given import path,
+// adding it to the imports of the file.
+func (f *file) importName(pkgPath string) string {
+	if name, ok := f.imports[pkgPath]; ok {
+		return name
+	}
+
+	name := path.Base(pkgPath)
+	if pkg, err := build.Import(pkgPath, "", 0); err == nil {
+		name = pkg.Name
+	}
+	f.imports[pkgPath] = name
+	return name
+}
+
+func (f *file) sortedImports() []string {
+	var paths []string
+	for p := range f.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// exampleSuffix returns the suffix of the example of the service with the
+// given name, which must start with a lower case letter.
+func exampleSuffix(svcName string) string {
+	return strings.ToLower(svcName[:1]) + svcName[1:]
+}
+
+var goSrc = filepath.Join(os.Getenv("GOPATH"), "src")
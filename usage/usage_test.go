@@ -0,0 +1,106 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/ThatTomPerson/proteus/protobuf"
+	"gitlab.com/ThatTomPerson/proteus/scanner"
+)
+
+const expectedFile = `// Code generated by proteus from gitlab.com/foo. DO NOT EDIT.
+
+package foo_test
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc"
+
+	"gitlab.com/foo"
+	"gitlab.com/foo/bar"
+)
+
+// Example_fooService shows how to call the RPCs of FooService with its generated client.
+func Example_fooService() {
+	conn, err := grpc.Dial("localhost:8001", grpc.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := foo.NewFooServiceClient(conn)
+	ctx := context.Background()
+
+	if _, err := client.GetUser(ctx, &foo.GetUserRequest{
+		Arg1:   "",
+		UserID: 0,
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := client.SaveUser(ctx, &foo.User{}); err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := client.GetBar(ctx, &bar.Bar{}); err != nil {
+		log.Fatal(err)
+	}
+}
+`
+
+func TestBuildFile(t *testing.T) {
+	require := require.New(t)
+
+	bar := protobuf.NewNamed("gitlab.com.foo.bar", "Bar")
+	bar.SetSource(scanner.NewNamed("gitlab.com/foo/bar", "Bar"))
+	pkg := &protobuf.Package{
+		Name: "foo",
+		Path: "gitlab.com/foo",
+		Messages: []*protobuf.Message{
+			{Name: "User"},
+			{
+				Name: "GetUserRequest",
+				Fields: []*protobuf.Field{
+					{Name: "arg1", Type: protobuf.NewBasic("string")},
+					{
+						Name:    "user_id",
+						Type:    protobuf.NewBasic("uint64"),
+						Options: protobuf.Options{"(gogoproto.customname)": protobuf.NewStringValue("UserID")},
+					},
+					{Name: "tags", Type: protobuf.NewBasic("string"), Repeated: true},
+					{Name: "avatar", Type: protobuf.NewBasic("bytes")},
+					{Name: "friend", Type: protobuf.NewNamed("foo", "User")},
+				},
+			},
+		},
+		Services: []*protobuf.Service{
+			{Name: "EmptyService"},
+			{
+				Name: "FooService",
+				RPCs: []*protobuf.RPC{
+					{
+						Name:   "GetUser",
+						Input:  protobuf.NewGeneratedNamed("foo", "GetUserRequest"),
+						Output: protobuf.NewNamed("foo", "User"),
+					},
+					{
+						Name:   "SaveUser",
+						Input:  protobuf.NewNamed("foo", "User"),
+						Output: protobuf.NewGeneratedNamed("foo", "SaveUserResponse"),
+					},
+					{
+						Name:   "GetBar",
+						Input:  bar,
+						Output: bar,
+					},
+				},
+			},
+		},
+	}
+
+	data, err := NewGenerator().buildFile(pkg, "foo", "gitlab.com/foo")
+	require.Nil(err)
+	require.Equal(expectedFile, string(data))
+}